@@ -0,0 +1,196 @@
+package funplugin
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// deviceDir is the writable and executable directory on android device
+const deviceDir = "/data/local/tmp"
+
+// adbPlugin runs .bin plugin on android device or emulator in sidecar mode,
+// the plugin gRPC port on device is forwarded to a localhost port with adb
+type adbPlugin struct {
+	*remotePlugin
+	cmd       *exec.Cmd // adb shell session running plugin process
+	pid       string    // plugin process id on device
+	localPort int
+	path      string // plugin file path on device
+	option    *pluginOption
+}
+
+// adbCommand returns adb command on the selected device
+func adbCommand(option *pluginOption, args ...string) *exec.Cmd {
+	if option.adbSerial != "" {
+		args = append([]string{"-s", option.adbSerial}, args...)
+	}
+	return exec.Command("adb", args...)
+}
+
+// adbShellCommand returns device shell command launching plugin at device path,
+// the shell pid is printed first and kept by exec for killing plugin on Quit
+func adbShellCommand(devicePath string, devicePort int, option *pluginOption) string {
+	return fmt.Sprintf("chmod 755 %s && echo $$ && %s exec %s",
+		shellQuote(devicePath), remoteEnvCommand(option, devicePort), shellQuote(devicePath))
+}
+
+func runADB(option *pluginOption, args ...string) error {
+	output, err := adbCommand(option, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("adb %s: %v: %s", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func newADBPlugin(pluginPath string, option *pluginOption) (*adbPlugin, error) {
+	// only go plugin cross compiled for android is supported, e.g. GOOS=android GOARCH=arm64
+	if filepath.Ext(pluginPath) != ".bin" {
+		return nil, fmt.Errorf("adb plugin only supports .bin, got %s", pluginPath)
+	}
+	option.langType = langTypeGo
+	logger := logger.ResetNamed("adb-go")
+
+	// push local plugin file, otherwise locate it on device
+	devicePath := filepath.ToSlash(pluginPath)
+	if _, err := os.Stat(pluginPath); err == nil {
+		devicePath = path.Join(deviceDir, fmt.Sprintf("funplugin-%d-%s", os.Getpid(), filepath.Base(pluginPath)))
+		logger.Info("push plugin to device", "serial", option.adbSerial, "path", devicePath)
+		if err := runADB(option, "push", pluginPath, devicePath); err != nil {
+			return nil, errors.Wrap(err, "push plugin to device failed")
+		}
+	}
+
+	if len(option.dataFiles) > 0 {
+		if err := pushDataFiles(option); err != nil {
+			return nil, err
+		}
+	}
+	if option.artifactsDir != "" {
+		option.artifactDir = remoteTempDir(deviceDir, "artifacts")
+	}
+
+	// the same port number is used on both sides
+	port, err := freeLocalPort()
+	if err != nil {
+		return nil, errors.Wrap(err, "get free port failed")
+	}
+	forward := fmt.Sprintf("tcp:%d", port)
+	if err := runADB(option, "forward", forward, forward); err != nil {
+		return nil, errors.Wrap(err, "forward plugin port failed")
+	}
+
+	p := &adbPlugin{
+		localPort: port,
+		path:      devicePath,
+		option:    option,
+	}
+	if err := p.start(port); err != nil {
+		p.removeForward()
+		return nil, err
+	}
+	remote, err := connectPlugin(fmt.Sprintf("127.0.0.1:%d", port), option.jsonNumber)
+	if err != nil {
+		p.stop()
+		return nil, err
+	}
+	p.remotePlugin = remote
+	logger.Info("start device plugin success", "serial", option.adbSerial, "path", devicePath, "pid", p.pid)
+	return p, nil
+}
+
+// pushDataFiles pushes data files to a new directory on device, which is set as data directory
+func pushDataFiles(option *pluginOption) error {
+	localDir, err := stageDataFiles(option.dataFiles)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(localDir)
+
+	deviceDataDir := remoteTempDir(deviceDir, "data")
+	logger.Info("push data files to device", "serial", option.adbSerial, "dir", deviceDataDir)
+	if err := runADB(option, "push", localDir, deviceDataDir); err != nil {
+		return errors.Wrap(err, "push data files to device failed")
+	}
+	option.dataDir = deviceDataDir
+	return nil
+}
+
+// start launches plugin process on device and reads its pid
+func (p *adbPlugin) start(devicePort int) error {
+	p.cmd = adbCommand(p.option, "shell", adbShellCommand(p.path, devicePort, p.option))
+	stdout, err := p.cmd.StdoutPipe()
+	if err != nil {
+		return errors.Wrap(err, "get adb stdout failed")
+	}
+	stderr, err := p.cmd.StderrPipe()
+	if err != nil {
+		return errors.Wrap(err, "get adb stderr failed")
+	}
+	logger.Info("start device plugin", "serial", p.option.adbSerial, "path", p.path)
+	if err := p.cmd.Start(); err != nil {
+		return errors.Wrap(err, "start adb shell failed")
+	}
+	trackProcess(p.cmd, p.stop)
+	go logPluginOutput(logger, stderr)
+
+	reader := bufio.NewReader(stdout)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		p.stop()
+		return errors.Wrap(err, "read device plugin pid failed")
+	}
+	p.pid = strings.TrimSpace(line)
+	go logPluginOutput(logger, reader)
+	return nil
+}
+
+// stop kills plugin process on device, the adb shell session and the port forwarding
+func (p *adbPlugin) stop() {
+	if p.pid != "" {
+		if err := runADB(p.option, "shell", "kill", p.pid); err != nil {
+			logger.Error("kill device plugin failed", "pid", p.pid, "error", err)
+		}
+	}
+	_ = p.cmd.Process.Kill()
+	_ = p.cmd.Wait()
+	untrackProcess(p.cmd)
+	p.removeForward()
+}
+
+func (p *adbPlugin) removeForward() {
+	if err := runADB(p.option, "forward", "--remove", fmt.Sprintf("tcp:%d", p.localPort)); err != nil {
+		logger.Error("remove port forwarding failed", "port", p.localPort, "error", err)
+	}
+}
+
+func (p *adbPlugin) Type() string {
+	return "adb-grpc-go"
+}
+
+func (p *adbPlugin) Path() string {
+	return p.path
+}
+
+func (p *adbPlugin) Quit() error {
+	logger.Info("quit device plugin", "serial", p.option.adbSerial, "pid", p.pid)
+	err := p.remotePlugin.Quit()
+	p.stop()
+	if p.option.artifactDir != "" {
+		if err := runADB(p.option, "shell", "rm", "-rf", shellQuote(p.option.artifactDir)); err != nil {
+			logger.Error("remove device artifact directory failed", "dir", p.option.artifactDir, "error", err)
+		}
+	}
+	return err
+}
+
+// downloadArtifacts pulls artifact directory on device to local path
+func (p *adbPlugin) downloadArtifacts(localPath string) error {
+	return runADB(p.option, "pull", p.option.artifactDir, localPath)
+}
@@ -0,0 +1,82 @@
+package funplugin
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestADBShellCommand(t *testing.T) {
+	assert.Equal(t,
+		"chmod 755 '/data/local/tmp/debugtalk.bin' && echo $$ && "+
+			"HRP_PLUGIN_HOST_INFO="+shellQuote(hostInfoJSON())+
+			" HRP_PLUGIN_SIDECAR_ADDR=127.0.0.1:23456 exec '/data/local/tmp/debugtalk.bin'",
+		adbShellCommand("/data/local/tmp/debugtalk.bin", 23456, &pluginOption{}))
+	assert.Equal(t,
+		"chmod 755 '/data/local/tmp/debugtalk.bin' && echo $$ && "+
+			"mkdir -p '/data/local/tmp/artifacts' && HRP_PLUGIN_ARTIFACT_DIR='/data/local/tmp/artifacts' "+
+			"HRP_PLUGIN_DATA_DIR='/data/local/tmp/data' HRP_PLUGIN_HOST_INFO="+shellQuote(hostInfoJSON())+
+			" HRP_PLUGIN_SIDECAR_ADDR=127.0.0.1:23456 exec '/data/local/tmp/debugtalk.bin'",
+		adbShellCommand("/data/local/tmp/debugtalk.bin", 23456,
+			&pluginOption{dataDir: "/data/local/tmp/data", artifactDir: "/data/local/tmp/artifacts"}))
+}
+
+func TestADBPluginUnsupported(t *testing.T) {
+	_, err := Init("debugtalk.py", WithADB("emulator-5554"))
+	assert.EqualError(t, err, "adb plugin only supports .bin, got debugtalk.py")
+}
+
+// fakeADB emulates a device with a local directory, port forwarding is
+// unnecessary since plugin listens on the same localhost port
+const fakeADB = `#!/bin/sh
+[ "$1" = "-s" ] && shift 2
+cmd=$1
+shift
+case "$cmd" in
+push) exec cp -R "$1" "$FAKE_DEVICE_ROOT$2" ;;
+pull) exec cp -R "$FAKE_DEVICE_ROOT$1" "$2" ;;
+forward) exit 0 ;;
+shell) exec sh -c "$(echo "$*" | sed "s#/data/local/tmp#$FAKE_DEVICE_ROOT/data/local/tmp#g")" ;;
+esac
+exit 1
+`
+
+func TestADBGoPlugin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake adb script requires a POSIX shell")
+	}
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	binDir := t.TempDir()
+	deviceRoot := t.TempDir()
+	assert.Nil(t, os.MkdirAll(filepath.Join(deviceRoot, "data", "local", "tmp"), 0o755))
+	assert.Nil(t, os.WriteFile(filepath.Join(binDir, "adb"), []byte(fakeADB), 0o755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_DEVICE_ROOT", deviceRoot)
+
+	artifactsDir := t.TempDir()
+	plugin, err := Init(pluginBinPath, WithADB("emulator-5554"),
+		WithDataFiles(writeDataFiles(t)), WithArtifacts(artifactsDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	assert.Equal(t, "adb-grpc-go", plugin.Type())
+	assert.Regexp(t, `^/data/local/tmp/funplugin-\d+-debugtalk.bin$`, plugin.Path())
+	assertPlugin(t, plugin)
+	content, err := plugin.Call("read_data_file", "fixtures/users.csv")
+	assert.Nil(t, err)
+	assert.Equal(t, "id,name\n1,alice\n", content)
+
+	_, err = plugin.Call("write_artifact", "screenshots/login.png", "png")
+	assert.Nil(t, err)
+	names, err := plugin.CollectArtifacts()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"screenshots/login.png"}, names)
+	assertArtifact(t, artifactsDir, "screenshots/login.png", "png")
+}
@@ -0,0 +1,492 @@
+package funplugin
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+const fungoImportPath = "github.com/lingcetech/funplugin/fungo"
+
+// severities of Diagnostic
+const (
+	SeverityError   = "error"   // plugin function fails at runtime
+	SeverityWarning = "warning" // plugin function may not work as expected
+)
+
+// Diagnostic is a problem of plugin source found by AnalyzeGoPlugin or ValidatePythonPlugin
+type Diagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s", d.File, d.Line, d.Column, d.Severity, d.Message)
+}
+
+// AnalysisError is returned by Init with WithAutoBuild when static analysis of
+// plugin sources reports errors, plugin is not built
+type AnalysisError struct {
+	Diagnostics []Diagnostic // diagnostics of error severity
+}
+
+func (e *AnalysisError) Error() string {
+	lines := make([]string, len(e.Diagnostics))
+	for i, d := range e.Diagnostics {
+		lines[i] = d.String()
+	}
+	return "plugin source analysis failed:\n" + strings.Join(lines, "\n")
+}
+
+// AnalyzeGoPlugin parses go plugin package in srcDir with go/ast before compiling it and
+// reports plugin functions with signatures unsupported by host, e.g. channel or function
+// parameters, more than 2 results, or unexported struct fields which are not transferred.
+// For hashicorp plugins using fungo, functions registered twice, exported functions not
+// registered and missing fungo.Serve are reported as well. Returned error is for
+// unparsable sources only.
+func AnalyzeGoPlugin(srcDir string) ([]Diagnostic, error) {
+	pkg, err := build.ImportDir(srcDir, 0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "load go package %s failed", srcDir)
+	}
+
+	a := &goPluginAnalyzer{
+		fset:    token.NewFileSet(),
+		funcs:   make(map[string]*ast.FuncDecl),
+		types:   make(map[string]*ast.TypeSpec),
+		methods: make(map[string][]*ast.FuncDecl),
+	}
+	for _, name := range pkg.GoFiles {
+		file, err := parser.ParseFile(a.fset, filepath.Join(srcDir, name), nil, 0)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse plugin source failed")
+		}
+		a.files = append(a.files, file)
+	}
+	a.collectDecls()
+
+	if pkg.Name != "main" {
+		a.report(a.files[0].Name, SeverityError, "plugin package should be main, got %s", pkg.Name)
+	}
+	if a.usesFungo() {
+		a.analyzeRegistrations()
+	} else {
+		// go plugin exports all exported functions
+		for _, name := range a.exportedFuncs() {
+			fn := a.funcs[name]
+			a.checkSignature(name, fn.Type, fn.Name)
+		}
+	}
+
+	sort.SliceStable(a.diagnostics, func(i, j int) bool {
+		di, dj := a.diagnostics[i], a.diagnostics[j]
+		if di.File != dj.File {
+			return di.File < dj.File
+		}
+		if di.Line != dj.Line {
+			return di.Line < dj.Line
+		}
+		return di.Column < dj.Column
+	})
+	return a.diagnostics, nil
+}
+
+// errorDiagnostics returns diagnostics of error severity
+func errorDiagnostics(diagnostics []Diagnostic) []Diagnostic {
+	var errs []Diagnostic
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			errs = append(errs, d)
+		}
+	}
+	return errs
+}
+
+// goPluginAnalyzer analyzes declarations of a go plugin package
+type goPluginAnalyzer struct {
+	fset        *token.FileSet
+	files       []*ast.File
+	funcs       map[string]*ast.FuncDecl   // top level functions by name
+	types       map[string]*ast.TypeSpec   // top level types by name
+	methods     map[string][]*ast.FuncDecl // methods by receiver type name
+	diagnostics []Diagnostic
+}
+
+func (a *goPluginAnalyzer) report(node ast.Node, severity, format string, args ...interface{}) {
+	pos := a.fset.Position(node.Pos())
+	a.diagnostics = append(a.diagnostics, Diagnostic{
+		File:     pos.Filename,
+		Line:     pos.Line,
+		Column:   pos.Column,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (a *goPluginAnalyzer) collectDecls() {
+	for _, file := range a.files {
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Recv == nil {
+					a.funcs[decl.Name.Name] = decl
+				} else if typeName := receiverTypeName(decl.Recv.List[0].Type); typeName != "" {
+					a.methods[typeName] = append(a.methods[typeName], decl)
+				}
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					if spec, ok := spec.(*ast.TypeSpec); ok {
+						a.types[spec.Name.Name] = spec
+					}
+				}
+			}
+		}
+	}
+}
+
+// exportedFuncs returns sorted names of exported top level functions
+func (a *goPluginAnalyzer) exportedFuncs() []string {
+	var names []string
+	for name := range a.funcs {
+		if ast.IsExported(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fungoName returns name of fungo package imported by file, or empty if not imported
+func fungoName(file *ast.File) string {
+	for _, spec := range file.Imports {
+		if path, _ := strconv.Unquote(spec.Path.Value); path != fungoImportPath {
+			continue
+		}
+		if spec.Name != nil {
+			return spec.Name.Name
+		}
+		return "fungo"
+	}
+	return ""
+}
+
+func (a *goPluginAnalyzer) usesFungo() bool {
+	for _, file := range a.files {
+		if fungoName(file) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// analyzeRegistrations checks functions registered with fungo.Register and fungo.RegisterStruct
+func (a *goPluginAnalyzer) analyzeRegistrations() {
+	registered := make(map[string]bool)    // registered top level functions
+	commonNames := make(map[string]string) // registered names by common name
+	names := make(map[string]token.Pos)    // registered names
+	var served bool
+
+	register := func(node ast.Node, name string) bool {
+		if _, ok := names[name]; ok {
+			a.report(node, SeverityError, "function %s is registered twice, the later registration is ignored", name)
+			return false
+		}
+		names[name] = node.Pos()
+		commonName := fungo.ConvertCommonName(name)
+		if other, ok := commonNames[commonName]; ok && other != name {
+			a.report(node, SeverityWarning, "common name %s of %s conflicts with %s", commonName, name, other)
+		}
+		commonNames[commonName] = name
+		return true
+	}
+
+	for _, file := range a.files {
+		pkgName := fungoName(file)
+		if pkgName == "" {
+			continue
+		}
+		ast.Inspect(file, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			if x, ok := sel.X.(*ast.Ident); !ok || x.Name != pkgName {
+				return true
+			}
+			switch sel.Sel.Name {
+			case "Serve", "ServeContext":
+				served = true
+			case "Register":
+				if len(call.Args) != 2 {
+					return true
+				}
+				name, ok := stringLiteral(call.Args[0])
+				if !ok || !register(call, name) {
+					return true
+				}
+				switch fn := call.Args[1].(type) {
+				case *ast.Ident:
+					if decl, ok := a.funcs[fn.Name]; ok {
+						registered[fn.Name] = true
+						a.checkSignature(name, decl.Type, decl.Name)
+					}
+				case *ast.FuncLit:
+					a.checkSignature(name, fn.Type, fn)
+				}
+			case "RegisterStruct":
+				if len(call.Args) != 2 {
+					return true
+				}
+				name, ok := stringLiteral(call.Args[0])
+				if !ok {
+					return true
+				}
+				a.analyzeStruct(call, name, register)
+			}
+			return true
+		})
+	}
+
+	for _, name := range a.exportedFuncs() {
+		if !registered[name] {
+			a.report(a.funcs[name].Name, SeverityWarning,
+				"exported function %s is not registered with fungo.Register", name)
+		}
+	}
+	if !served {
+		a.report(a.files[0].Name, SeverityWarning, "fungo.Serve is not called, plugin binary does not serve host")
+	}
+}
+
+// analyzeStruct checks methods registered with fungo.RegisterStruct(name, v),
+// v is composite literal of struct type declared in plugin package
+func (a *goPluginAnalyzer) analyzeStruct(call *ast.CallExpr, name string,
+	register func(node ast.Node, name string) bool) {
+	expr, pointer := call.Args[1], false
+	if unary, ok := expr.(*ast.UnaryExpr); ok && unary.Op == token.AND {
+		expr, pointer = unary.X, true
+	}
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return
+	}
+	typeIdent, ok := lit.Type.(*ast.Ident)
+	if !ok {
+		return
+	}
+
+	for _, method := range a.methods[typeIdent.Name] {
+		methodName := method.Name.Name
+		if !ast.IsExported(methodName) {
+			a.report(method.Name, SeverityWarning, "unexported method %s.%s is not registered",
+				typeIdent.Name, methodName)
+			continue
+		}
+		if _, ptrRecv := method.Recv.List[0].Type.(*ast.StarExpr); ptrRecv && !pointer {
+			a.report(call, SeverityWarning, "method %s.%s with pointer receiver is not registered, pass &%s{} instead",
+				typeIdent.Name, methodName, typeIdent.Name)
+			continue
+		}
+		funcName := methodName
+		if name != "" {
+			funcName = name + "." + methodName
+		}
+		if register(call, funcName) {
+			a.checkSignature(funcName, method.Type, method.Name)
+		}
+	}
+}
+
+// checkSignature reports parameters and results which can not be transferred between host and plugin
+func (a *goPluginAnalyzer) checkSignature(funcName string, fnType *ast.FuncType, node ast.Node) {
+	index := 0
+	for _, field := range fnType.Params.List {
+		count := len(field.Names)
+		if count == 0 {
+			count = 1
+		}
+		for i := 0; i < count; i++ {
+			if isContextType(field.Type) {
+				if index != 0 {
+					a.report(field, SeverityError, "function %s: context.Context should be the first parameter", funcName)
+				}
+			} else if reason := a.unsupportedType(field.Type, map[string]bool{}); reason != "" {
+				a.report(field, SeverityError, "function %s: parameter %d is %s, which is not transferable", funcName, index+1, reason)
+			}
+			index++
+		}
+		a.checkFields(funcName, field.Type, map[string]bool{})
+	}
+
+	if fnType.Results == nil {
+		return
+	}
+	var results []ast.Expr
+	for _, field := range fnType.Results.List {
+		count := len(field.Names)
+		if count == 0 {
+			count = 1
+		}
+		for i := 0; i < count; i++ {
+			results = append(results, field.Type)
+		}
+	}
+	if len(results) > 2 {
+		a.report(node, SeverityError, "function %s should return at most 2 values, got %d", funcName, len(results))
+		return
+	}
+	if len(results) == 2 {
+		if ident, ok := results[1].(*ast.Ident); !ok || ident.Name != "error" {
+			a.report(fnType.Results, SeverityError, "function %s: second result should be error", funcName)
+		}
+	}
+	if reason := a.unsupportedType(results[0], map[string]bool{}); reason != "" {
+		a.report(fnType.Results, SeverityError, "function %s: result is %s, which is not transferable", funcName, reason)
+	}
+	a.checkFields(funcName, results[0], map[string]bool{})
+}
+
+// unsupportedType returns reason if values of type can not be transferred in JSON,
+// or empty if supported or unknown, e.g. types of other packages
+func (a *goPluginAnalyzer) unsupportedType(expr ast.Expr, visited map[string]bool) string {
+	switch t := expr.(type) {
+	case *ast.ChanType:
+		return "channel"
+	case *ast.FuncType:
+		return "function"
+	case *ast.InterfaceType:
+		if t.Methods != nil && len(t.Methods.List) > 0 {
+			return "interface with methods"
+		}
+	case *ast.StarExpr:
+		return a.unsupportedType(t.X, visited)
+	case *ast.Ellipsis:
+		return a.unsupportedType(t.Elt, visited)
+	case *ast.ArrayType:
+		return a.unsupportedType(t.Elt, visited)
+	case *ast.MapType:
+		if reason := a.unsupportedType(t.Key, visited); reason != "" {
+			return reason
+		}
+		return a.unsupportedType(t.Value, visited)
+	case *ast.SelectorExpr:
+		if x, ok := t.X.(*ast.Ident); ok && x.Name == "unsafe" && t.Sel.Name == "Pointer" {
+			return "unsafe pointer"
+		}
+	case *ast.Ident:
+		if t.Name == "uintptr" {
+			return "uintptr"
+		}
+		spec, ok := a.types[t.Name]
+		if !ok || visited[t.Name] {
+			return ""
+		}
+		visited[t.Name] = true
+		if reason := a.unsupportedType(spec.Type, visited); reason != "" {
+			return fmt.Sprintf("%s (%s)", t.Name, reason)
+		}
+	case *ast.StructType:
+		for _, field := range t.Fields.List {
+			if !fieldExported(field) {
+				continue // unexported fields are ignored in JSON
+			}
+			if reason := a.unsupportedType(field.Type, visited); reason != "" {
+				return "struct with " + reason + " field"
+			}
+		}
+	}
+	return ""
+}
+
+// checkFields reports unexported fields of struct types declared in plugin package,
+// which are silently dropped in JSON
+func (a *goPluginAnalyzer) checkFields(funcName string, expr ast.Expr, visited map[string]bool) {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		a.checkFields(funcName, t.X, visited)
+	case *ast.Ellipsis:
+		a.checkFields(funcName, t.Elt, visited)
+	case *ast.ArrayType:
+		a.checkFields(funcName, t.Elt, visited)
+	case *ast.MapType:
+		a.checkFields(funcName, t.Value, visited)
+	case *ast.Ident:
+		spec, ok := a.types[t.Name]
+		if !ok || visited[t.Name] {
+			return
+		}
+		visited[t.Name] = true
+		structType, ok := spec.Type.(*ast.StructType)
+		if !ok {
+			a.checkFields(funcName, spec.Type, visited)
+			return
+		}
+		for _, field := range structType.Fields.List {
+			if fieldExported(field) {
+				a.checkFields(funcName, field.Type, visited)
+				continue
+			}
+			for _, name := range field.Names {
+				a.report(name, SeverityWarning, "function %s: unexported field %s of %s is not transferred",
+					funcName, name.Name, t.Name)
+			}
+		}
+	}
+}
+
+// fieldExported reports whether all names of struct field are exported,
+// embedded fields are treated as exported
+func fieldExported(field *ast.Field) bool {
+	for _, name := range field.Names {
+		if !ast.IsExported(name.Name) {
+			return false
+		}
+	}
+	return true
+}
+
+func isContextType(expr ast.Expr) bool {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	x, ok := sel.X.(*ast.Ident)
+	return ok && x.Name == "context" && sel.Sel.Name == "Context"
+}
+
+// receiverTypeName returns type name of method receiver, e.g. Counter of *Counter
+func receiverTypeName(expr ast.Expr) string {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+func stringLiteral(expr ast.Expr) (string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
@@ -0,0 +1,107 @@
+package funplugin
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const analyzedPlugin = `package main
+
+import (
+	"context"
+
+	fg "github.com/lingcetech/funplugin/fungo"
+)
+
+type User struct {
+	Name  string
+	email string
+}
+
+type Handler struct {
+	Callback func()
+}
+
+type Counter struct{ n int }
+
+func (c *Counter) Incr() int { return c.n }
+func (c *Counter) reset()    {}
+
+func Stream(ch chan int) {}
+func Notify(h Handler) {}
+func GetUser(ctx context.Context, id int) (*User, error) { return nil, nil }
+func Late(id int, ctx context.Context) {}
+func Triple() (int, int, error) { return 0, 0, nil }
+func Pair() (int, string) { return 0, "" }
+func Helper() {}
+
+func main() {
+	fg.Register("stream", Stream)
+	fg.Register("notify", Notify)
+	fg.Register("get_user", GetUser)
+	fg.Register("late", Late)
+	fg.Register("triple", Triple)
+	fg.Register("pair", Pair)
+	fg.Register("get_user", Pair)
+	fg.Register("inline", func(fn func()) {})
+	fg.RegisterStruct("counter", Counter{})
+}
+`
+
+func TestAnalyzeGoPlugin(t *testing.T) {
+	srcDir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(srcDir, "main.go"), []byte(analyzedPlugin), 0o644))
+
+	diagnostics, err := AnalyzeGoPlugin(srcDir)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	var messages []string
+	for _, d := range diagnostics {
+		assert.Equal(t, filepath.Join(srcDir, "main.go"), d.File)
+		messages = append(messages, d.Severity+": "+d.Message)
+	}
+	assert.Equal(t, []string{
+		"warning: fungo.Serve is not called, plugin binary does not serve host",
+		"warning: function get_user: unexported field email of User is not transferred",
+		"warning: unexported method Counter.reset is not registered",
+		"error: function stream: parameter 1 is channel, which is not transferable",
+		"error: function notify: parameter 1 is Handler (struct with function field), which is not transferable",
+		"error: function late: context.Context should be the first parameter",
+		"error: function triple should return at most 2 values, got 3",
+		"error: function pair: second result should be error",
+		"warning: exported function Helper is not registered with fungo.Register",
+		"error: function get_user is registered twice, the later registration is ignored",
+		"error: function inline: parameter 1 is function, which is not transferable",
+		"warning: method Counter.Incr with pointer receiver is not registered, pass &Counter{} instead",
+	}, messages)
+	assert.Len(t, errorDiagnostics(diagnostics), 7)
+
+	// examples are fine
+	diagnostics, err = AnalyzeGoPlugin("fungo/examples")
+	assert.Nil(t, err)
+	assert.Empty(t, diagnostics)
+
+	_, err = AnalyzeGoPlugin(t.TempDir())
+	assert.Contains(t, err.Error(), "load go package")
+}
+
+func TestAutoBuildAnalysisError(t *testing.T) {
+	srcDir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(srcDir, "main.go"), []byte(analyzedPlugin), 0o644))
+
+	path := filepath.Join(t.TempDir(), "debugtalk.bin")
+	_, err := Init(path, WithAutoBuild(srcDir))
+	var analysisErr *AnalysisError
+	if !assert.True(t, errors.As(err, &analysisErr)) {
+		t.FailNow()
+	}
+	assert.Len(t, analysisErr.Diagnostics, 7)
+	assert.Contains(t, err.Error(), "main.go:23:13: error: function stream: parameter 1 is channel")
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,115 @@
+package funplugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// containerArtifactDir is the artifact directory inside container
+const containerArtifactDir = "/tmp/funplugin-artifacts"
+
+// artifactDownloader is implemented by plugins running in container or on remote machine,
+// downloadArtifacts copies plugin artifact directory to local path which does not exist yet
+type artifactDownloader interface {
+	downloadArtifacts(localPath string) error
+}
+
+// prepareLocalArtifactDir creates artifact directory of local plugin process,
+// it is removed when plugin quits after artifacts are collected
+func (o *pluginOption) prepareLocalArtifactDir() error {
+	if o.artifactsDir == "" {
+		return nil
+	}
+	dir, err := os.MkdirTemp("", "funplugin-artifacts-")
+	if err != nil {
+		return errors.Wrap(err, "create artifact directory failed")
+	}
+	o.artifactDir, o.localArtifacts = dir, dir
+	return nil
+}
+
+// removeLocalArtifactDir removes artifact directory of local plugin process
+func (o *pluginOption) removeLocalArtifactDir() {
+	if o.localArtifacts == "" {
+		return
+	}
+	if err := os.RemoveAll(o.localArtifacts); err != nil {
+		logger.Error("remove plugin artifact directory failed", "dir", o.localArtifacts, "error", err)
+	}
+	o.localArtifacts = ""
+}
+
+// CollectArtifacts copies files written by plugin to its artifact directory to the host
+// directory specified by WithArtifacts, and returns their slash separated names in order.
+// Files collected before are collected again and overwritten if they are still in artifact directory.
+func (p *interceptedPlugin) CollectArtifacts() ([]string, error) {
+	if p.option.artifactsDir == "" {
+		return nil, fmt.Errorf("artifacts of plugin %s are not enabled, use WithArtifacts", p.Path())
+	}
+	p.artifactsMu.Lock()
+	defer p.artifactsMu.Unlock()
+
+	srcDir := p.option.localArtifacts
+	if downloader, ok := p.pluginBackend.(artifactDownloader); ok {
+		tempDir, err := os.MkdirTemp("", "funplugin-artifacts-")
+		if err != nil {
+			return nil, errors.Wrap(err, "create artifact directory failed")
+		}
+		defer os.RemoveAll(tempDir)
+		srcDir = filepath.Join(tempDir, "artifacts")
+		if err := downloader.downloadArtifacts(srcDir); err != nil {
+			return nil, errors.Wrap(err, "download plugin artifacts failed")
+		}
+	}
+	if srcDir == "" {
+		return nil, nil
+	}
+
+	names, err := copyArtifacts(srcDir, p.option.artifactsDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "collect plugin artifacts failed")
+	}
+	logger.Info("collect plugin artifacts", "dir", p.option.artifactsDir, "count", len(names))
+	return names, nil
+}
+
+// collectArtifactsOnQuit collects remaining artifacts before plugin resources are released
+func (p *interceptedPlugin) collectArtifactsOnQuit() {
+	if p.option.artifactsDir == "" {
+		return
+	}
+	if _, err := p.CollectArtifacts(); err != nil {
+		logger.Error("collect plugin artifacts on quit failed", "error", err)
+	}
+}
+
+// copyArtifacts copies regular files in srcDir to dstDir and returns their slash separated names
+func copyArtifacts(srcDir, dstDir string) ([]string, error) {
+	var names []string
+	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		if err := copyFile(path, filepath.Join(dstDir, rel)); err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
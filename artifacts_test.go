@@ -0,0 +1,89 @@
+package funplugin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// assertArtifact checks artifact content collected to host directory
+func assertArtifact(t *testing.T, artifactsDir, name, content string) {
+	actual, err := os.ReadFile(filepath.Join(artifactsDir, filepath.FromSlash(name)))
+	assert.Nil(t, err)
+	assert.Equal(t, content, string(actual))
+}
+
+func TestArtifactsGoPlugin(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	for _, transport := range []string{"", "stdio"} {
+		artifactsDir := t.TempDir()
+		plugin, err := Init(pluginBinPath, WithTransport(transport), WithArtifacts(artifactsDir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, artifactsDir, plugin.Snapshot().Options.ArtifactsDir)
+
+		_, err = plugin.Call("write_artifact", "screenshots/login.png", "png")
+		assert.Nil(t, err)
+		names, err := plugin.CollectArtifacts()
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"screenshots/login.png"}, names)
+		assertArtifact(t, artifactsDir, "screenshots/login.png", "png")
+
+		// artifacts written after last collection are collected on quit
+		_, err = plugin.Call("write_artifact", "report.csv", "id,result\n1,ok\n")
+		assert.Nil(t, err)
+		localDir := plugin.(*interceptedPlugin).option.localArtifacts
+		assert.DirExists(t, localDir)
+		assert.Nil(t, plugin.Quit())
+		assertArtifact(t, artifactsDir, "report.csv", "id,result\n1,ok\n")
+		assert.NoDirExists(t, localDir)
+	}
+}
+
+func TestCollectArtifactsDisabled(t *testing.T) {
+	plugin, err := Init("lua/examples/debugtalk.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	_, err = plugin.CollectArtifacts()
+	assert.EqualError(t, err, "artifacts of plugin lua/examples/debugtalk.lua are not enabled, use WithArtifacts")
+}
+
+func TestStageContainerDirs(t *testing.T) {
+	option := &pluginOption{
+		langType:     langTypeGo,
+		dockerImage:  "debugtalk:latest",
+		dataFiles:    writeDataFiles(t),
+		artifactsDir: t.TempDir(),
+	}
+	dirs, err := stageContainerDirs(option)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		for _, dir := range dirs {
+			os.RemoveAll(dir)
+		}
+	}()
+	assert.Len(t, dirs, 2)
+	assert.FileExists(t, filepath.Join(dirs[containerDataDir], "fixtures", "users.csv"))
+	assert.DirExists(t, dirs[containerArtifactDir])
+
+	args := dockerRunArgs("/app/debugtalk.bin", option, 12345)
+	assert.Equal(t, []string{
+		"run", "--rm", "--detach",
+		"--publish", "127.0.0.1:12345:50051",
+		"--env", "HRP_PLUGIN_SIDECAR_ADDR=0.0.0.0:50051",
+		"--env", hostInfoEnv(),
+		"--env", "HRP_PLUGIN_DATA_DIR=/tmp/funplugin-data",
+		"--env", "HRP_PLUGIN_ARTIFACT_DIR=/tmp/funplugin-artifacts",
+		"debugtalk:latest", "/app/debugtalk.bin",
+	}, args)
+}
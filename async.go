@@ -0,0 +1,235 @@
+package funplugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+var (
+	// ErrCallCanceled is returned for calls aborted with Cancel
+	ErrCallCanceled = errors.New("plugin call canceled")
+	// ErrPluginQuit is returned for calls in background aborted by quitting plugin, durable
+	// calls are kept in durable queue for replay
+	ErrPluginQuit = errors.New("plugin quit")
+)
+
+// Future is a plugin function call running in background, started by CallAsync
+type Future struct {
+	id       string
+	funcName string
+	cancel   context.CancelFunc
+	done     chan struct{} // closed when call returns or is canceled
+	once     sync.Once
+	result   interface{}
+	err      error
+}
+
+// ID returns call id of the call
+func (f *Future) ID() string {
+	return f.id
+}
+
+// Done returns a channel closed when the call returns or is canceled
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Result waits for the call and returns its result,
+// ErrCallCanceled is returned if the call is aborted with Cancel
+func (f *Future) Result() (interface{}, error) {
+	<-f.done
+	return f.result, f.err
+}
+
+// Cancel aborts the call without quitting plugin, gRPC plugins are notified via canceled
+// ctx of plugin function, calls to other plugins are abandoned and run to completion.
+// It has no effect if the call has returned.
+func (f *Future) Cancel() {
+	f.abort(ErrCallCanceled)
+}
+
+// abort finishes the call with reason and cancels its ctx
+func (f *Future) abort(reason error) {
+	f.finish(nil, fmt.Errorf("call %s failed: %w", f.funcName, reason))
+	f.cancel()
+}
+
+// finish records result of the call unless it has returned or been canceled
+func (f *Future) finish(result interface{}, err error) {
+	f.once.Do(func() {
+		f.result, f.err = result, err
+		close(f.done)
+	})
+}
+
+// CallAsync starts calling plugin function in background and returns its future, which is
+// waited for with Done or Result, thus hosts overlap plugin work with other activities.
+// Concurrent calls to gRPC plugins are multiplexed on a stream.
+func (p *interceptedPlugin) CallAsync(funcName string, args ...interface{}) (*Future, error) {
+	if !p.Has(funcName) {
+		return nil, fmt.Errorf("function %s not found", funcName)
+	}
+	return p.startCall(funcName, args...), nil
+}
+
+// startCall starts call in background with call id unique in plugin
+func (p *interceptedPlugin) startCall(funcName string, args ...interface{}) *Future {
+	return p.runCall(p.nextCallID(), nil, funcName, args...)
+}
+
+// nextCallID returns call id unique in plugin
+func (p *interceptedPlugin) nextCallID() string {
+	p.asyncMu.Lock()
+	defer p.asyncMu.Unlock()
+	p.asyncSeq++
+	return fmt.Sprintf("call-%d", p.asyncSeq)
+}
+
+// failedCall returns future of call of id failed with err without starting
+func failedCall(id, funcName string, err error) *Future {
+	future := &Future{id: id, funcName: funcName, cancel: func() {}, done: make(chan struct{})}
+	future.finish(nil, err)
+	return future
+}
+
+// runCall runs call of id in background, durable call is completed in durable queue
+// after it returns
+func (p *interceptedPlugin) runCall(id string, durable *durableCall, funcName string, args ...interface{}) *Future {
+	ctx, cancel := context.WithCancel(context.Background())
+	future := &Future{id: id, funcName: funcName, cancel: cancel, done: make(chan struct{})}
+	p.asyncMu.Lock()
+	if p.running == nil {
+		p.running = make(map[*Future]struct{})
+	}
+	p.running[future] = struct{}{}
+	p.asyncMu.Unlock()
+	go func() {
+		defer cancel()
+		future.finish(p.callContext(ctx, funcName, args...))
+		p.asyncMu.Lock()
+		delete(p.running, future)
+		p.asyncMu.Unlock()
+		if durable != nil {
+			p.completeDurable(durable, future.err)
+		}
+	}()
+	logger.Debug("start async plugin call", "callID", future.id, "funcName", funcName)
+	return future
+}
+
+// callContext calls plugin function through interceptors, the call waiting in queue or for rate
+// limit is aborted when ctx is done, and the call to plugin is canceled if plugin backend supports
+// it, e.g. gRPC plugins, otherwise it runs to completion
+func (p *interceptedPlugin) callContext(ctx context.Context, funcName string, args ...interface{}) (interface{}, error) {
+	call := callHandler(p.pluginBackend.Call)
+	if caller, ok := p.pluginBackend.(fungo.IContextCaller); ok {
+		call = func(funcName string, args ...interface{}) (interface{}, error) {
+			return caller.CallContext(ctx, funcName, args...)
+		}
+	}
+	return p.intercept(ctx, call)(funcName, args...)
+}
+
+// abortCalls aborts calls running in background with reason, e.g. ErrPluginQuit
+func (p *interceptedPlugin) abortCalls(reason error) {
+	p.asyncMu.Lock()
+	futures := make([]*Future, 0, len(p.running))
+	for future := range p.running {
+		futures = append(futures, future)
+	}
+	p.asyncMu.Unlock()
+	for _, future := range futures {
+		logger.Info("abort plugin call", "callID", future.id, "funcName", future.funcName, "reason", reason)
+		future.abort(reason)
+	}
+}
+
+// Submit starts calling plugin function in background like CallAsync and returns call id, which
+// is used to wait for result with Wait or abort the call with Cancel. Wait or Cancel must be
+// called for every submitted call to release it. Calls of functions not found fail without
+// starting. With WithDurableQueue the call is persisted before it starts, and fails if it can
+// not be persisted.
+func (p *interceptedPlugin) Submit(funcName string, args ...interface{}) string {
+	var future *Future
+	if !p.Has(funcName) {
+		future = failedCall(p.nextCallID(), funcName, fmt.Errorf("function %s not found", funcName))
+	} else if p.durable != nil {
+		future = p.submitDurable(funcName, args...)
+	} else {
+		future = p.startCall(funcName, args...)
+	}
+	p.track(future)
+	return future.id
+}
+
+// track registers submitted call for Wait and Cancel
+func (p *interceptedPlugin) track(future *Future) {
+	p.asyncMu.Lock()
+	defer p.asyncMu.Unlock()
+	if p.asyncCalls == nil {
+		p.asyncCalls = make(map[string]*Future)
+	}
+	p.asyncCalls[future.id] = future
+}
+
+// SubmittedCalls returns sorted ids of calls started by Submit and not waited for yet,
+// including calls replayed from durable queue
+func (p *interceptedPlugin) SubmittedCalls() []string {
+	p.asyncMu.Lock()
+	defer p.asyncMu.Unlock()
+	ids := make([]string, 0, len(p.asyncCalls))
+	for id := range p.asyncCalls {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Wait waits for call started by Submit and returns its result,
+// ErrCallCanceled is returned if the call is aborted with Cancel
+func (p *interceptedPlugin) Wait(callID string) (interface{}, error) {
+	future, err := p.submitted(callID)
+	if err != nil {
+		return nil, err
+	}
+	result, err := future.Result()
+	p.asyncMu.Lock()
+	delete(p.asyncCalls, callID)
+	p.asyncMu.Unlock()
+	return result, err
+}
+
+// Cancel aborts call started by Submit, see Future.Cancel, and releases it like Wait
+// regardless of whether it has returned
+func (p *interceptedPlugin) Cancel(callID string) error {
+	future, err := p.submitted(callID)
+	if err != nil {
+		return err
+	}
+	p.asyncMu.Lock()
+	delete(p.asyncCalls, callID)
+	p.asyncMu.Unlock()
+	select {
+	case <-future.done:
+		return nil
+	default:
+	}
+	future.Cancel()
+	logger.Info("cancel plugin call", "callID", callID, "funcName", future.funcName)
+	return nil
+}
+
+func (p *interceptedPlugin) submitted(callID string) (*Future, error) {
+	p.asyncMu.Lock()
+	defer p.asyncMu.Unlock()
+	future, ok := p.asyncCalls[callID]
+	if !ok {
+		return nil, fmt.Errorf("plugin call %s not found", callID)
+	}
+	return future, nil
+}
@@ -0,0 +1,174 @@
+package funplugin
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+func TestSubmitCancel(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	plugin, err := Init(pluginBinPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	callID := plugin.Submit("wait_seconds", 0.1)
+	result, err := plugin.Wait(callID)
+	assert.Nil(t, err)
+	assert.Equal(t, 0.1, result)
+
+	start := time.Now()
+	slowID := plugin.Submit("wait_seconds", 60)
+	fastID := plugin.Submit("wait_seconds", 0.2)
+	assert.NotEqual(t, slowID, fastID)
+	time.Sleep(100 * time.Millisecond)
+	assert.Nil(t, plugin.Cancel(slowID))
+	// canceled call is released
+	assert.Equal(t, []string{fastID}, plugin.SubmittedCalls())
+
+	// other calls and plugin are not affected
+	result, err = plugin.Wait(fastID)
+	assert.Nil(t, err)
+	assert.Equal(t, 0.2, result)
+	assert.Less(t, time.Since(start), 10*time.Second)
+	result, err = plugin.Call("sum_two_int", 1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(3), result)
+
+	// released calls are not found
+	_, err = plugin.Wait(slowID)
+	assert.EqualError(t, err, "plugin call "+slowID+" not found")
+	assert.EqualError(t, plugin.Cancel(fastID), "plugin call "+fastID+" not found")
+	assert.Empty(t, plugin.SubmittedCalls())
+
+	// calls of unknown functions fail without starting
+	callID = plugin.Submit("not_exist")
+	_, err = plugin.Wait(callID)
+	assert.EqualError(t, err, "function not_exist not found")
+}
+
+func TestCancelReturnedCall(t *testing.T) {
+	plugin, err := Init("lua/examples/debugtalk.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	callID := plugin.Submit("sum_two_int", 1, 2)
+	time.Sleep(100 * time.Millisecond)
+	// canceling returned call releases it
+	assert.Nil(t, plugin.Cancel(callID))
+	assert.Empty(t, plugin.SubmittedCalls())
+	_, err = plugin.Wait(callID)
+	assert.EqualError(t, err, "plugin call "+callID+" not found")
+}
+
+func TestQuitAbortsCalls(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	plugin, err := Init(pluginBinPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	future, err := plugin.CallAsync("wait_seconds", 60)
+	assert.Nil(t, err)
+	callID := plugin.Submit("wait_seconds", 60)
+	time.Sleep(100 * time.Millisecond)
+	assert.Nil(t, plugin.Quit())
+
+	_, err = future.Result()
+	assert.True(t, errors.Is(err, ErrPluginQuit))
+	assert.EqualError(t, err, "call wait_seconds failed: plugin quit")
+	_, err = plugin.Wait(callID)
+	assert.True(t, errors.Is(err, ErrPluginQuit))
+}
+
+func TestCallAsync(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	plugin, err := Init(pluginBinPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	// concurrent calls are multiplexed on the call stream
+	start := time.Now()
+	var futures []*Future
+	for i := 0; i < 5; i++ {
+		future, err := plugin.CallAsync("wait_seconds", 0.5)
+		if !assert.Nil(t, err) {
+			return
+		}
+		futures = append(futures, future)
+	}
+	for _, future := range futures {
+		result, err := future.Result()
+		assert.Nil(t, err)
+		assert.Equal(t, 0.5, result)
+	}
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	slow, err := plugin.CallAsync("wait_seconds", 60)
+	assert.Nil(t, err)
+	select {
+	case <-slow.Done():
+		t.Fatal("call should not be done")
+	case <-time.After(100 * time.Millisecond):
+	}
+	slow.Cancel()
+	<-slow.Done()
+	_, err = slow.Result()
+	assert.True(t, errors.Is(err, ErrCallCanceled))
+
+	// user errors are transferred on the stream
+	future, err := plugin.CallAsync("assert_equal", 1, 2)
+	assert.Nil(t, err)
+	_, err = future.Result()
+	var userErr *fungo.UserError
+	if assert.True(t, errors.As(err, &userErr)) {
+		assert.Equal(t, "AssertionError", userErr.Type)
+		assert.Equal(t, "expect 2, got 1", userErr.Message)
+	}
+	// canceling returned call has no effect
+	future.Cancel()
+	_, err = future.Result()
+	assert.True(t, errors.As(err, &userErr))
+
+	_, err = plugin.CallAsync("not_exist")
+	assert.EqualError(t, err, "function not_exist not found")
+
+	// host side interceptors apply to async calls
+	for _, funcStats := range plugin.Stats().Funcs {
+		if funcStats.Name == "assert_equal" {
+			assert.Equal(t, int64(1), funcStats.Calls)
+			assert.Equal(t, int64(1), funcStats.Errors)
+		}
+	}
+}
+
+func TestCallAsyncNotGRPC(t *testing.T) {
+	plugin, err := Init("lua/examples/debugtalk.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	future, err := plugin.CallAsync("sum_two_int", 1, 2)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, future.ID())
+	result, err := future.Result()
+	assert.Nil(t, err)
+	assert.Equal(t, float64(3), result)
+}
@@ -0,0 +1,193 @@
+package funplugin
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/user"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// AuditRecord records one plugin function call
+type AuditRecord struct {
+	Time       time.Time `json:"time"`
+	User       string    `json:"user"`
+	Host       string    `json:"host"`
+	PluginType string    `json:"plugin_type"`
+	PluginPath string    `json:"plugin_path"`
+	Function   string    `json:"function"`
+	ArgsHash   string    `json:"args_hash"`   // sha256 of JSON encoded arguments
+	ResultHash string    `json:"result_hash"` // sha256 of JSON encoded result
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	Signature  string    `json:"signature,omitempty"` // HMAC-SHA256 of record without signature
+}
+
+// Sign sets record signature with HMAC-SHA256
+func (r *AuditRecord) Sign(key []byte) error {
+	r.Signature = ""
+	mac, err := r.mac(key)
+	if err != nil {
+		return err
+	}
+	r.Signature = hex.EncodeToString(mac)
+	return nil
+}
+
+// Verify checks record signature with HMAC-SHA256
+func (r *AuditRecord) Verify(key []byte) bool {
+	signature, err := hex.DecodeString(r.Signature)
+	if err != nil {
+		return false
+	}
+	record := *r
+	record.Signature = ""
+	mac, err := record.mac(key)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(signature, mac)
+}
+
+func (r *AuditRecord) mac(key []byte) ([]byte, error) {
+	content, err := json.Marshal(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal audit record failed")
+	}
+	h := hmac.New(sha256.New, key)
+	h.Write(content)
+	return h.Sum(nil), nil
+}
+
+// AuditSink receives audit records, implementations must be goroutine safe
+type AuditSink interface {
+	Write(record *AuditRecord) error
+	Close() error
+}
+
+// fileAuditSink appends audit records to file as JSON lines
+type fileAuditSink struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// NewFileAuditSink opens file in append-only mode to write audit records
+func NewFileAuditSink(path string) (AuditSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, errors.Wrap(err, "open audit log file failed")
+	}
+	return &fileAuditSink{file: file}, nil
+}
+
+func (s *fileAuditSink) Write(record *AuditRecord) error {
+	content, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "marshal audit record failed")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, err = s.file.Write(append(content, '\n'))
+	return err
+}
+
+func (s *fileAuditSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.file.Close()
+}
+
+// httpAuditSink posts each audit record as JSON to url
+type httpAuditSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPAuditSink posts audit records to url, e.g. a log collector endpoint
+func NewHTTPAuditSink(url string) AuditSink {
+	return &httpAuditSink{
+		url:    url,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (s *httpAuditSink) Write(record *AuditRecord) error {
+	content, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "marshal audit record failed")
+	}
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(content))
+	if err != nil {
+		return errors.Wrap(err, "post audit record failed")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("post audit record failed, status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *httpAuditSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// auditHash returns sha256 hex digest of JSON encoded value
+func auditHash(v interface{}) string {
+	content, err := json.Marshal(v)
+	if err != nil {
+		// unserializable value, hash its go representation instead
+		content = []byte(fmt.Sprintf("%#v", v))
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func newAuditInterceptor(p pluginBackend, sink AuditSink, key []byte) callInterceptor {
+	userName := ""
+	if u, err := user.Current(); err == nil {
+		userName = u.Username
+	}
+	hostName, _ := os.Hostname()
+
+	return func(_ context.Context, next callHandler) callHandler {
+		return func(funcName string, args ...interface{}) (interface{}, error) {
+			start := time.Now()
+			result, err := next(funcName, args...)
+
+			record := &AuditRecord{
+				Time:       start.UTC(),
+				User:       userName,
+				Host:       hostName,
+				PluginType: p.Type(),
+				PluginPath: p.Path(),
+				Function:   funcName,
+				ArgsHash:   auditHash(args),
+				ResultHash: auditHash(result),
+				DurationMs: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				record.Error = err.Error()
+			}
+			if len(key) > 0 {
+				if signErr := record.Sign(key); signErr != nil {
+					logger.Error("sign audit record failed", "error", signErr)
+				}
+			}
+			if writeErr := sink.Write(record); writeErr != nil {
+				logger.Error("write audit record failed", "funcName", funcName, "error", writeErr)
+			}
+			return result, err
+		}
+	}
+}
@@ -0,0 +1,36 @@
+//go:build !windows
+
+package funplugin
+
+import (
+	"encoding/json"
+	"log/syslog"
+
+	"github.com/pkg/errors"
+)
+
+// syslogAuditSink writes audit records as JSON to local syslog
+type syslogAuditSink struct {
+	writer *syslog.Writer
+}
+
+// NewSyslogAuditSink writes audit records to local syslog daemon with tag
+func NewSyslogAuditSink(tag string) (AuditSink, error) {
+	writer, err := syslog.New(syslog.LOG_INFO|syslog.LOG_AUTH, tag)
+	if err != nil {
+		return nil, errors.Wrap(err, "connect syslog failed")
+	}
+	return &syslogAuditSink{writer: writer}, nil
+}
+
+func (s *syslogAuditSink) Write(record *AuditRecord) error {
+	content, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "marshal audit record failed")
+	}
+	return s.writer.Info(string(content))
+}
+
+func (s *syslogAuditSink) Close() error {
+	return s.writer.Close()
+}
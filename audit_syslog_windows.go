@@ -0,0 +1,9 @@
+//go:build windows
+
+package funplugin
+
+import "fmt"
+
+func NewSyslogAuditSink(tag string) (AuditSink, error) {
+	return nil, fmt.Errorf("syslog audit sink does not support windows")
+}
@@ -0,0 +1,94 @@
+package funplugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditLog(t *testing.T) {
+	auditFile := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := NewFileAuditSink(auditFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	key := []byte("secret")
+	plugin, err := Init("lua/examples/debugtalk.lua", WithAuditLog(sink, key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	assertPlugin(t, plugin)
+	_, err = plugin.Call("divide", 1, 0)
+	assert.Error(t, err)
+
+	_, ok := plugin.(IFuncLister)
+	assert.True(t, ok)
+
+	file, err := os.Open(auditFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var records []*AuditRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		record := &AuditRecord{}
+		if !assert.NoError(t, json.Unmarshal(scanner.Bytes(), record)) {
+			t.Fatal()
+		}
+		records = append(records, record)
+	}
+	if !assert.NotEmpty(t, records) {
+		t.Fatal()
+	}
+
+	first := records[0]
+	assert.Equal(t, "lua-plugin", first.PluginType)
+	assert.Equal(t, "sum_ints", first.Function)
+	assert.Equal(t, auditHash([]interface{}{1, 2, 3, 4}), first.ArgsHash)
+	assert.Equal(t, auditHash(10.0), first.ResultHash)
+	assert.True(t, first.Verify(key))
+	assert.False(t, first.Verify([]byte("wrong")))
+
+	last := records[len(records)-1]
+	assert.Equal(t, "divide", last.Function)
+	assert.NotEmpty(t, last.Error)
+	assert.True(t, last.Verify(key))
+}
+
+func TestHTTPAuditSink(t *testing.T) {
+	received := make(chan *AuditRecord, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record := &AuditRecord{}
+		json.NewDecoder(r.Body).Decode(record)
+		received <- record
+	}))
+	defer server.Close()
+
+	sink := NewHTTPAuditSink(server.URL)
+	defer sink.Close()
+
+	plugin, err := Init("lua/examples/debugtalk.lua", WithAuditLog(sink, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	_, err = plugin.Call("sum_two_int", 1, 2)
+	assert.NoError(t, err)
+
+	record := <-received
+	assert.Equal(t, "sum_two_int", record.Function)
+	assert.Empty(t, record.Signature)
+}
@@ -0,0 +1,190 @@
+package funplugin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/lingcetech/funplugin/myexec"
+)
+
+// sourceHashSuffix is suffix of file next to plugin binary recording hash of its sources
+const sourceHashSuffix = ".srchash"
+
+// autoBuild rebuilds .bin hashicorp plugin or .so go plugin from go package in srcDir
+// when the binary is missing or stale, thus outdated plugin binary is never run
+func autoBuild(path, srcDir string) error {
+	hash, stale, err := pluginStale(path, srcDir)
+	if err != nil {
+		return err
+	}
+	if stale {
+		if err := buildPlugin(path, srcDir, hash); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path+sourceHashSuffix, []byte(hash+"\n"), 0o644)
+}
+
+// pluginStale checks if plugin binary is missing or built from other sources,
+// plugin without recorded source hash is stale if any source is newer than it
+func pluginStale(path, srcDir string) (hash string, stale bool, err error) {
+	hash, modTime, err := sourceHash(srcDir)
+	if err != nil {
+		return "", false, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		logger.Info("plugin binary not found, build it", "path", path, "srcDir", srcDir)
+		return hash, true, nil
+	}
+	recorded, err := os.ReadFile(path + sourceHashSuffix)
+	if err != nil {
+		if modTime.After(info.ModTime()) {
+			logger.Info("plugin sources modified after binary built, rebuild it", "path", path, "srcDir", srcDir)
+			return hash, true, nil
+		}
+		return hash, false, nil
+	}
+	if strings.TrimSpace(string(recorded)) != hash {
+		logger.Info("plugin sources changed, rebuild it", "path", path, "srcDir", srcDir)
+		return hash, true, nil
+	}
+	return hash, false, nil
+}
+
+// sourceHash returns hash of go sources, go.mod and go.sum in srcDir and their latest
+// modification time, test files, hidden directories and testdata are skipped
+func sourceHash(srcDir string) (hash string, modTime time.Time, err error) {
+	var files []string
+	err = filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != srcDir && (strings.HasPrefix(name, ".") || name == "testdata") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if (strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go")) ||
+			name == "go.mod" || name == "go.sum" {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", modTime, errors.Wrapf(err, "walk plugin source dir %s failed", srcDir)
+	}
+	if len(files) == 0 {
+		return "", modTime, errors.Errorf("no go sources found in plugin source dir %s", srcDir)
+	}
+	sort.Strings(files)
+
+	h := sha256.New()
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", modTime, errors.Wrap(err, "stat plugin source failed")
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+		rel, _ := filepath.Rel(srcDir, file)
+		io.WriteString(h, filepath.ToSlash(rel)+"\x00")
+		f, err := os.Open(file)
+		if err != nil {
+			return "", modTime, errors.Wrap(err, "open plugin source failed")
+		}
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", modTime, errors.Wrap(err, "read plugin source failed")
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), modTime, nil
+}
+
+// buildPlugin builds plugin binary with go build in srcDir, embedding build manifest
+// reported by IPlugin.Info; go plugin is built with flags of host, e.g. -race
+func buildPlugin(path, srcDir, hash string) error {
+	output, err := filepath.Abs(path)
+	if err != nil {
+		return errors.Wrap(err, "get plugin absolute path failed")
+	}
+	args := []string{"build"}
+	if filepath.Ext(path) == ".so" {
+		args = append(args, "-buildmode=plugin")
+		if host, ok := debug.ReadBuildInfo(); ok {
+			args = append(args, pluginBuildFlags(host)...)
+		}
+	}
+	args = append(args, "-ldflags="+manifestLDFlags(srcDir, hash), "-o", output, ".")
+
+	// precise diagnostics of unsupported signatures instead of runtime reflection failures
+	diagnostics, err := AnalyzeGoPlugin(srcDir)
+	if err != nil {
+		logger.Warn("analyze plugin sources failed", "srcDir", srcDir, "error", err)
+	}
+	for _, d := range diagnostics {
+		if d.Severity == SeverityWarning {
+			logger.Warn("plugin source analysis", "diagnostic", d.String())
+		}
+	}
+	if errs := errorDiagnostics(diagnostics); len(errs) > 0 {
+		return &AnalysisError{Diagnostics: errs}
+	}
+
+	logger.Info("build plugin", "path", path, "srcDir", srcDir, "args", args)
+	if err := myexec.ExecCommandInDir(exec.Command("go", args...), srcDir); err != nil {
+		return errors.Wrapf(err, "build plugin %s from %s failed", path, srcDir)
+	}
+	return nil
+}
+
+// manifestLDFlags returns -X flags embedding build manifest read by fungo.BuildInfo,
+// version and commit are taken from git if srcDir is in a git repository
+func manifestLDFlags(srcDir, hash string) string {
+	values := map[string]string{
+		"buildTime":       time.Now().UTC().Format(time.RFC3339),
+		"buildSourceHash": hash,
+	}
+	if version, err := gitOutput(srcDir, "describe", "--tags", "--always", "--dirty"); err == nil {
+		values["buildVersion"] = version
+	}
+	if commit, err := gitOutput(srcDir, "rev-parse", "HEAD"); err == nil {
+		values["buildCommit"] = commit
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	flags := make([]string, 0, len(names))
+	for _, name := range names {
+		flags = append(flags, fmt.Sprintf("-X %s.%s=%s", fungoImportPath, name, values[name]))
+	}
+	return strings.Join(flags, " ")
+}
+
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
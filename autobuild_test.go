@@ -0,0 +1,84 @@
+package funplugin
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPluginStale(t *testing.T) {
+	srcDir := t.TempDir()
+	source := filepath.Join(srcDir, "main.go")
+	assert.Nil(t, os.WriteFile(source, []byte("package main\n\nfunc main() {}\n"), 0o644))
+	// test files and testdata do not affect plugin binary
+	assert.Nil(t, os.WriteFile(filepath.Join(srcDir, "main_test.go"), []byte("package main\n"), 0o644))
+	assert.Nil(t, os.Mkdir(filepath.Join(srcDir, "testdata"), 0o755))
+	assert.Nil(t, os.WriteFile(filepath.Join(srcDir, "testdata", "x.go"), []byte("package x\n"), 0o644))
+	path := filepath.Join(t.TempDir(), "debugtalk.bin")
+
+	// binary not found
+	hash, stale, err := pluginStale(path, srcDir)
+	assert.Nil(t, err)
+	assert.True(t, stale)
+
+	// binary built after sources without recorded hash
+	assert.Nil(t, os.WriteFile(path, []byte("binary"), 0o755))
+	future := time.Now().Add(time.Hour)
+	assert.Nil(t, os.Chtimes(path, future, future))
+	_, stale, _ = pluginStale(path, srcDir)
+	assert.False(t, stale)
+
+	// sources modified after binary without recorded hash
+	assert.Nil(t, os.Chtimes(source, future.Add(time.Hour), future.Add(time.Hour)))
+	_, stale, _ = pluginStale(path, srcDir)
+	assert.True(t, stale)
+
+	// recorded hash takes precedence over modification time
+	assert.Nil(t, os.WriteFile(path+sourceHashSuffix, []byte(hash+"\n"), 0o644))
+	_, stale, _ = pluginStale(path, srcDir)
+	assert.False(t, stale)
+	assert.Nil(t, os.WriteFile(filepath.Join(srcDir, "main_test.go"), []byte("package main\n\n"), 0o644))
+	_, stale, _ = pluginStale(path, srcDir)
+	assert.False(t, stale)
+
+	assert.Nil(t, os.WriteFile(source, []byte("package main\n\nfunc main() { println() }\n"), 0o644))
+	_, stale, _ = pluginStale(path, srcDir)
+	assert.True(t, stale)
+
+	_, _, err = pluginStale(path, t.TempDir())
+	assert.Contains(t, err.Error(), "no go sources found in plugin source dir")
+}
+
+func TestAutoBuildHashicorpPlugin(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "debugtalk.bin")
+	plugin, err := Init(path, WithAutoBuild("fungo/examples"))
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	result, err := plugin.Call("sum_ints", 1, 2, 3)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 6, result)
+	// build manifest is embedded
+	hash, err := os.ReadFile(path + sourceHashSuffix)
+	assert.Nil(t, err)
+	manifest := plugin.Info()
+	assert.Equal(t, strings.TrimSpace(string(hash)), manifest.SourceHash)
+	assert.NotEmpty(t, manifest.BuildTime)
+	assert.Nil(t, plugin.Quit())
+	built, err := os.Stat(path)
+	assert.Nil(t, err)
+
+	// up-to-date plugin is not rebuilt
+	plugin, err = Init(path, WithAutoBuild("fungo/examples"))
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	assert.Nil(t, plugin.Quit())
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, built.ModTime(), info.ModTime())
+}
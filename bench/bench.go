@@ -0,0 +1,137 @@
+// Package bench times plugin function calls across plugin flavors, e.g. go .so
+// vs hashicorp rpc vs gRPC vs python, and emits a comparison table.
+package bench
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/lingcetech/funplugin"
+)
+
+// Target is a plugin flavor to benchmark
+type Target struct {
+	Name    string             // target label, defaults to plugin type
+	Path    string             // plugin file path
+	Options []funplugin.Option // plugin init options
+	Env     map[string]string  // env set while initializing plugin, e.g. HRP_PLUGIN_TYPE=rpc
+}
+
+// Call is a plugin function call to benchmark
+type Call struct {
+	Func string
+	Args []interface{}
+}
+
+// Result is timing of a function on a target
+type Result struct {
+	Target string        `json:"target"`
+	Type   string        `json:"type"` // plugin type
+	Func   string        `json:"func"`
+	N      int64         `json:"n"`
+	Errors int64         `json:"errors"`
+	Total  time.Duration `json:"total"`
+	Mean   time.Duration `json:"mean"`
+	P50    time.Duration `json:"p50"`
+	P95    time.Duration `json:"p95"`
+}
+
+// Run times n invocations of each call on each target, targets are initialized
+// and benchmarked one by one, so that they do not compete for CPU
+func Run(targets []Target, calls []Call, n int) ([]Result, error) {
+	var results []Result
+	for _, target := range targets {
+		targetResults, err := runTarget(target, calls, n)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, targetResults...)
+	}
+	return results, nil
+}
+
+func runTarget(target Target, calls []Call, n int) ([]Result, error) {
+	plugin, err := initPlugin(target)
+	if err != nil {
+		return nil, errors.Wrapf(err, "init benchmark target %s failed", target.Path)
+	}
+	defer plugin.Quit()
+
+	name := target.Name
+	if name == "" {
+		name = plugin.Type()
+	}
+	for _, call := range calls {
+		for i := 0; i < n; i++ {
+			_, _ = plugin.Call(call.Func, call.Args...)
+		}
+	}
+
+	// timing is collected by plugin call statistics
+	var results []Result
+	for _, stats := range plugin.Stats().Funcs {
+		results = append(results, Result{
+			Target: name,
+			Type:   plugin.Type(),
+			Func:   stats.Name,
+			N:      stats.Calls,
+			Errors: stats.Errors,
+			Total:  stats.Total,
+			Mean:   stats.Total / time.Duration(stats.Calls),
+			P50:    stats.P50,
+			P95:    stats.P95,
+		})
+	}
+	return results, nil
+}
+
+// initPlugin initializes plugin with target env, which is restored afterwards
+func initPlugin(target Target) (funplugin.IPlugin, error) {
+	for key, value := range target.Env {
+		if old, ok := os.LookupEnv(key); ok {
+			defer os.Setenv(key, old)
+		} else {
+			defer os.Unsetenv(key)
+		}
+		os.Setenv(key, value)
+	}
+	return funplugin.Init(target.Path, target.Options...)
+}
+
+// Table formats results as a comparison table grouped by function, the fastest
+// target comes first and others are compared to it by mean time
+func Table(results []Result) string {
+	sorted := append([]Result{}, results...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Func != sorted[j].Func {
+			return sorted[i].Func < sorted[j].Func
+		}
+		return sorted[i].Mean < sorted[j].Mean
+	})
+
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "function\ttarget\tcalls\terrors\tmean\tp50\tp95\trelative\t")
+	var fastest time.Duration
+	for i, r := range sorted {
+		if i == 0 || r.Func != sorted[i-1].Func {
+			fastest = r.Mean
+		}
+		relative := 1.0
+		if fastest > 0 {
+			relative = float64(r.Mean) / float64(fastest)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%v\t%v\t%v\t%.2fx\t\n",
+			r.Func, r.Target, r.N, r.Errors,
+			r.Mean.Round(time.Microsecond), r.P50.Round(time.Microsecond), r.P95.Round(time.Microsecond),
+			relative)
+	}
+	w.Flush()
+	return b.String()
+}
@@ -0,0 +1,75 @@
+package bench
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	targets := []Target{
+		{Path: "../lua/examples/debugtalk.lua"},
+		{Name: "goja", Path: "../js/examples/debugtalk.js"},
+		{Path: "../starlark/examples/debugtalk.star"},
+	}
+	calls := []Call{
+		{Func: "sum_two_int", Args: []interface{}{1, 2}},
+		{Func: "not_exist"},
+	}
+	results, err := Run(targets, calls, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assert.Len(t, results, 6) {
+		t.FailNow()
+	}
+	for _, r := range results {
+		assert.EqualValues(t, 10, r.N)
+		if r.Func == "not_exist" {
+			assert.EqualValues(t, 10, r.Errors)
+		} else {
+			assert.EqualValues(t, 0, r.Errors)
+		}
+	}
+
+	table := Table(results)
+	lines := strings.Split(strings.TrimRight(table, "\n"), "\n")
+	assert.Len(t, lines, 7)
+	assert.Contains(t, table, "goja")
+	assert.Contains(t, table, "lua-plugin")
+	assert.Contains(t, lines[1], "1.00x") // fastest target of not_exist
+	assert.Contains(t, lines[4], "1.00x") // fastest target of sum_two_int
+}
+
+func TestRunInitFailed(t *testing.T) {
+	_, err := Run([]Target{{Path: "not_exist.lua"}}, nil, 1)
+	assert.Error(t, err)
+}
+
+func TestInitPluginEnv(t *testing.T) {
+	t.Setenv("FUNPLUGIN_BENCH_TEST", "old")
+	plugin, err := initPlugin(Target{
+		Path: "../lua/examples/debugtalk.lua",
+		Env:  map[string]string{"FUNPLUGIN_BENCH_TEST": "new", "FUNPLUGIN_BENCH_UNSET": "1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+	assert.Equal(t, "old", os.Getenv("FUNPLUGIN_BENCH_TEST"))
+	assert.Equal(t, "", os.Getenv("FUNPLUGIN_BENCH_UNSET"))
+}
+
+func TestTableRelative(t *testing.T) {
+	table := Table([]Result{
+		{Target: "grpc", Func: "f", N: 1, Mean: 4 * time.Millisecond},
+		{Target: "so", Func: "f", N: 1, Mean: 2 * time.Millisecond},
+	})
+	lines := strings.Split(strings.TrimRight(table, "\n"), "\n")
+	assert.Contains(t, lines[1], "so")
+	assert.Contains(t, lines[1], "1.00x")
+	assert.Contains(t, lines[2], "2.00x")
+}
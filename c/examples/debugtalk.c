@@ -0,0 +1,42 @@
+// C shared library plugin example, build with:
+// gcc -shared -fPIC -o debugtalk.so c/examples/debugtalk.c
+
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+static char *copy_string(const char *s) {
+    char *ptr = malloc(strlen(s) + 1);
+    strcpy(ptr, s);
+    return ptr;
+}
+
+char *funplugin_register(void) {
+    return copy_string("[\"sum_two_int\", \"divide\"]");
+}
+
+char *funplugin_call(const char *name, const char *args) {
+    char buf[256];
+    double a, b;
+
+    // args is JSON array, e.g. [1,2]
+    if (sscanf(args, "[%lf,%lf]", &a, &b) != 2) {
+        return copy_string("{\"error\": \"expect two number arguments\"}");
+    }
+
+    if (strcmp(name, "sum_two_int") == 0) {
+        snprintf(buf, sizeof(buf), "{\"value\": %ld}", (long)a + (long)b);
+    } else if (strcmp(name, "divide") == 0) {
+        if (b == 0) {
+            return copy_string("{\"error\": \"division by zero\"}");
+        }
+        snprintf(buf, sizeof(buf), "{\"value\": %g}", a / b);
+    } else {
+        snprintf(buf, sizeof(buf), "{\"error\": \"function %s not found\"}", name);
+    }
+    return copy_string(buf);
+}
+
+void funplugin_free(char *ptr) {
+    free(ptr);
+}
@@ -0,0 +1,248 @@
+package funplugin
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ErrCgroupUnsupported is returned when placing plugins into cgroups on platforms other than linux
+var ErrCgroupUnsupported = errors.New("cgroup is only supported on linux")
+
+// ResourceUsage is resource accounting of plugin process and its children read from
+// cgroup v2 interface files, fields of controllers not enabled are zero
+type ResourceUsage struct {
+	CPU          time.Duration `json:"cpu"`                         // total CPU time, cpu.stat usage_usec
+	MemoryBytes  uint64        `json:"memory_bytes"`                // memory.current
+	MemoryPeak   uint64        `json:"memory_peak_bytes,omitempty"` // memory.peak, linux 5.19+
+	IOReadBytes  uint64        `json:"io_read_bytes"`               // sum of io.stat rbytes
+	IOWriteBytes uint64        `json:"io_write_bytes"`              // sum of io.stat wbytes
+}
+
+func (u ResourceUsage) add(other ResourceUsage) ResourceUsage {
+	u.CPU += other.CPU
+	u.MemoryBytes += other.MemoryBytes
+	u.MemoryPeak += other.MemoryPeak
+	u.IOReadBytes += other.IOReadBytes
+	u.IOWriteBytes += other.IOWriteBytes
+	return u
+}
+
+// PluginUsage is resource usage of a plugin of Manager
+type PluginUsage struct {
+	Plugin ManagedPlugin `json:"plugin"`
+	Cgroup string        `json:"cgroup"` // cgroup directory of plugin process
+	Usage  ResourceUsage `json:"usage"`
+}
+
+// UsageReport is resource usage of plugins placed into cgroups by Manager
+type UsageReport struct {
+	Plugins []PluginUsage `json:"plugins"` // sorted by CPU time in descending order
+	Total   ResourceUsage `json:"total"`
+}
+
+// Report formats usage as a table, plugins using the most CPU time come first
+func (r UsageReport) Report() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "tenant\tplugin\tcpu\tmemory\tpeak memory\tio read\tio write\t")
+	row := func(tenant, name string, u ResourceUsage) {
+		fmt.Fprintf(w, "%s\t%s\t%v\t%d\t%d\t%d\t%d\t\n", tenant, name,
+			u.CPU.Round(time.Millisecond), u.MemoryBytes, u.MemoryPeak, u.IOReadBytes, u.IOWriteBytes)
+	}
+	for _, p := range r.Plugins {
+		row(p.Plugin.Tenant, p.Plugin.Name, p.Usage)
+	}
+	row("", "total", r.Total)
+	w.Flush()
+	return b.String()
+}
+
+// WithCgroup places each plugin process into its own child cgroup of parent, an existing
+// cgroup v2 directory delegated to host, e.g. /sys/fs/cgroup/funplugin, for per-plugin
+// CPU, memory and IO accounting reported by Manager.Usage. cpu, memory and io controllers
+// are enabled in parent when possible. Plugin processes restarted by heartbeat are moved into
+// the same cgroup. Plugins running in host process are not placed.
+func WithCgroup(parent string) ManagerOption {
+	return func(m *Manager) {
+		m.cgroupParent = parent
+	}
+}
+
+var invalidCgroupChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// cgroupName returns cgroup directory name of plugin process
+func cgroupName(plugin ManagedPlugin, pid int) string {
+	name := "funplugin-" + plugin.Name
+	if plugin.Tenant != "" {
+		name = "funplugin-" + plugin.Tenant + "-" + filepath.Base(plugin.Name)
+	}
+	return fmt.Sprintf("%s-%d", invalidCgroupChars.ReplaceAllString(name, "_"), pid)
+}
+
+// attachCgroup places process of managed plugin into its cgroup, failures are logged
+// since accounting should not prevent plugins from serving
+func (m *Manager) attachCgroup(entry *managedPlugin) {
+	if m.cgroupParent == "" {
+		return
+	}
+	snapshot := entry.plugin.Snapshot()
+	if snapshot.Process == nil {
+		logger.Debug("plugin is not a local process, skip cgroup", "tenant", entry.Tenant, "name", entry.Name)
+		return
+	}
+	dir, err := createCgroup(m.cgroupParent, cgroupName(entry.ManagedPlugin, snapshot.Process.Pid), snapshot.Process.Pid)
+	if err != nil {
+		logger.Warn("place plugin into cgroup failed", "tenant", entry.Tenant, "name", entry.Name, "error", err)
+		return
+	}
+	entry.cgroup = dir
+}
+
+// reattachCgroup moves restarted process of managed plugin into its cgroup, thus
+// accounting of the plugin continues, cgroup keeps usage of exited processes
+func (m *Manager) reattachCgroup(entry *managedPlugin) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if entry.cgroup == "" || entry.plugin == nil {
+		return
+	}
+	snapshot := entry.plugin.Snapshot()
+	if snapshot.Process == nil {
+		return
+	}
+	if err := moveToCgroup(entry.cgroup, snapshot.Process.Pid); err != nil {
+		logger.Warn("move restarted plugin into cgroup failed", "tenant", entry.Tenant, "name", entry.Name, "error", err)
+	}
+}
+
+// withRestartHook calls hook after plugin process is restarted, e.g. by heartbeat
+func withRestartHook(hook func()) Option {
+	return func(o *pluginOption) {
+		o.restartHook = hook
+	}
+}
+
+// restarted calls restart hook of plugin, it must not be called with restart lock
+// of plugin held since the hook inspects plugin
+func (o *pluginOption) restarted() {
+	if o.restartHook != nil {
+		o.restartHook()
+	}
+}
+
+// detachCgroup removes cgroup of managed plugin after its process exits
+func detachCgroup(entry *managedPlugin) {
+	if entry.cgroup == "" {
+		return
+	}
+	if err := removeCgroup(entry.cgroup); err != nil {
+		logger.Warn("remove plugin cgroup failed", "cgroup", entry.cgroup, "error", err)
+	}
+}
+
+// Usage returns resource usage of plugins placed into cgroups with WithCgroup,
+// for capacity planning on shared plugin hosts
+func (m *Manager) Usage() UsageReport {
+	m.mu.Lock()
+	var entries []*managedPlugin
+	for _, entry := range m.plugins {
+		if entry.cgroup != "" {
+			entries = append(entries, entry)
+		}
+	}
+	for _, entry := range m.shared {
+		if entry.cgroup != "" {
+			entries = append(entries, &entry.managedPlugin)
+		}
+	}
+	m.mu.Unlock()
+
+	report := UsageReport{Plugins: []PluginUsage{}}
+	for _, entry := range entries {
+		usage, err := readCgroupUsage(entry.cgroup)
+		if err != nil {
+			logger.Debug("read plugin cgroup usage failed", "cgroup", entry.cgroup, "error", err)
+			continue
+		}
+		report.Plugins = append(report.Plugins, PluginUsage{Plugin: entry.ManagedPlugin, Cgroup: entry.cgroup, Usage: usage})
+		report.Total = report.Total.add(usage)
+	}
+	sort.Slice(report.Plugins, func(i, j int) bool {
+		return report.Plugins[i].Usage.CPU > report.Plugins[j].Usage.CPU
+	})
+	return report
+}
+
+// readCgroupUsage reads resource usage from cgroup v2 interface files of dir,
+// files of controllers not enabled are skipped
+func readCgroupUsage(dir string) (ResourceUsage, error) {
+	var usage ResourceUsage
+	stat, err := readCgroupKeys(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return usage, err
+	}
+	usage.CPU = time.Duration(stat["usage_usec"]) * time.Microsecond
+	if value, err := readCgroupValue(filepath.Join(dir, "memory.current")); err == nil {
+		usage.MemoryBytes = value
+	}
+	if value, err := readCgroupValue(filepath.Join(dir, "memory.peak")); err == nil {
+		usage.MemoryPeak = value
+	}
+	// io.stat has a line of keyed values per device, e.g. 8:0 rbytes=1024 wbytes=0 rios=1
+	content, err := os.ReadFile(filepath.Join(dir, "io.stat"))
+	if err == nil {
+		for _, field := range strings.Fields(string(content)) {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			n, _ := strconv.ParseUint(value, 10, 64)
+			switch key {
+			case "rbytes":
+				usage.IOReadBytes += n
+			case "wbytes":
+				usage.IOWriteBytes += n
+			}
+		}
+	}
+	return usage, nil
+}
+
+// readCgroupKeys reads flat keyed file, e.g. cpu.stat with lines of "key value"
+func readCgroupKeys(path string) (map[string]uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if n, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[fields[0]] = n
+		}
+	}
+	return values, scanner.Err()
+}
+
+// readCgroupValue reads single value file, e.g. memory.current
+func readCgroupValue(path string) (uint64, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+}
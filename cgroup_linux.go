@@ -0,0 +1,57 @@
+//go:build linux
+
+package funplugin
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// createCgroup creates child cgroup name of parent and moves process pid into it
+func createCgroup(parent, name string, pid int) (string, error) {
+	if _, err := os.Stat(filepath.Join(parent, "cgroup.controllers")); err != nil {
+		return "", errors.Wrap(err, "parent is not a cgroup v2 directory")
+	}
+	// controllers may be enabled already, or not delegated to host
+	if err := os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte("+cpu +memory +io"), 0o644); err != nil {
+		logger.Debug("enable cgroup controllers failed", "cgroup", parent, "error", err)
+	}
+	dir := filepath.Join(parent, name)
+	if err := os.Mkdir(dir, 0o755); err != nil && !os.IsExist(err) {
+		return "", errors.Wrap(err, "create cgroup failed")
+	}
+	if err := moveToCgroup(dir, pid); err != nil {
+		os.Remove(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// moveToCgroup moves process pid into cgroup dir
+func moveToCgroup(dir string, pid int) error {
+	if err := os.WriteFile(filepath.Join(dir, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0o644); err != nil {
+		return errors.Wrap(err, "move plugin process into cgroup failed")
+	}
+	return nil
+}
+
+// removeCgroup removes cgroup, retrying while exiting processes are still in it
+func removeCgroup(dir string) error {
+	var err error
+	for i := 0; i < 10; i++ {
+		err = os.Remove(dir)
+		if err == nil || os.IsNotExist(err) {
+			return nil
+		}
+		if !errors.Is(err, syscall.EBUSY) {
+			return err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return err
+}
@@ -0,0 +1,73 @@
+//go:build linux
+
+package funplugin
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManagerCgroup(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	// interface files of cgroup v2 are faked in a plain directory
+	parent := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(parent, "cgroup.controllers"), []byte("cpu io memory pids"), 0o644))
+
+	m := NewManager(WithCgroup(parent))
+	defer m.Shutdown()
+	plugin, err := m.Load("project-a", "debugtalk", pluginBinPath)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	// plugin running in host process is not placed
+	_, err = m.Load("project-a", "lua", "lua/examples/debugtalk.lua")
+	assert.Nil(t, err)
+
+	pid := plugin.Snapshot().Process.Pid
+	dir := filepath.Join(parent, "funplugin-project-a-debugtalk-"+strconv.Itoa(pid))
+	procs, err := os.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, strconv.Itoa(pid), string(procs))
+
+	files := map[string]string{
+		"cpu.stat":       "usage_usec 1500000\nuser_usec 1000000\nsystem_usec 500000\n",
+		"memory.current": "20971520\n",
+		"io.stat":        "8:0 rbytes=4096 wbytes=1024 rios=1 wios=1\n8:16 rbytes=4096 wbytes=0 rios=1 wios=0\n",
+	}
+	for name, content := range files {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	report := m.Usage()
+	if assert.Len(t, report.Plugins, 1) {
+		assert.Equal(t, "debugtalk", report.Plugins[0].Plugin.Name)
+		assert.Equal(t, dir, report.Plugins[0].Cgroup)
+	}
+	assert.Equal(t, ResourceUsage{
+		CPU:          1500 * time.Millisecond,
+		MemoryBytes:  20971520,
+		IOReadBytes:  8192,
+		IOWriteBytes: 1024,
+	}, report.Total)
+	assert.Contains(t, report.Report(), "project-a  debugtalk  1.5s  20971520")
+
+	// restarted plugin process is moved into the same cgroup
+	assert.Nil(t, backendOf(plugin).(*hashicorpPlugin).restartProcess())
+	newPid := plugin.Snapshot().Process.Pid
+	assert.NotEqual(t, pid, newPid)
+	procs, err = os.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	assert.Nil(t, err)
+	assert.Equal(t, strconv.Itoa(newPid), string(procs))
+	assert.Equal(t, dir, m.Usage().Plugins[0].Cgroup)
+
+	assert.Nil(t, m.Unload("project-a", "debugtalk"))
+	assert.Empty(t, m.Usage().Plugins)
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package funplugin
+
+func createCgroup(parent, name string, pid int) (string, error) {
+	return "", ErrCgroupUnsupported
+}
+
+func moveToCgroup(dir string, pid int) error {
+	return ErrCgroupUnsupported
+}
+
+func removeCgroup(dir string) error {
+	return ErrCgroupUnsupported
+}
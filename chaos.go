@@ -0,0 +1,96 @@
+package funplugin
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrChaosDropped is returned for calls failed by WithChaos as if connection dropped
+	ErrChaosDropped = errors.New("chaos: plugin connection dropped")
+	// ErrChaosKilled is returned for calls failed by WithChaos killing plugin process
+	ErrChaosKilled = errors.New("chaos: plugin process killed")
+)
+
+// ChaosConfig specifies faults injected by WithChaos, rates are probabilities in [0, 1]
+// of injecting the fault to each call. Faults are drawn from random source of Seed,
+// thus the same seed injects the same faults to the same sequence of calls.
+type ChaosConfig struct {
+	Seed      int64
+	DelayRate float64       // rate of calls delayed by random duration up to MaxDelay
+	MaxDelay  time.Duration // max delay of delayed calls
+	DropRate  float64       // rate of calls failed with ErrChaosDropped
+	KillRate  float64       // rate of calls failed with ErrChaosKilled after restarting plugin process
+}
+
+// processRestarter is implemented by plugins running as restartable local processes
+type processRestarter interface {
+	restartProcess() error
+}
+
+// chaosFaults are faults drawn for one call
+type chaosFaults struct {
+	delay time.Duration
+	drop  bool
+	kill  bool
+}
+
+// chaosInjector draws faults of calls from seeded random source
+type chaosInjector struct {
+	config ChaosConfig
+
+	randMu sync.Mutex
+	rand   *rand.Rand
+}
+
+// draw returns faults of next call, random numbers are drawn for every fault
+// regardless of injected ones to keep the sequence deterministic
+func (c *chaosInjector) draw() chaosFaults {
+	c.randMu.Lock()
+	defer c.randMu.Unlock()
+	kill, drop, delay := c.rand.Float64(), c.rand.Float64(), c.rand.Float64()
+	delayRatio := c.rand.Float64()
+
+	faults := chaosFaults{
+		kill: kill < c.config.KillRate,
+		drop: drop < c.config.DropRate,
+	}
+	if delay < c.config.DelayRate {
+		faults.delay = time.Duration(delayRatio * float64(c.config.MaxDelay))
+	}
+	return faults
+}
+
+// newChaosInterceptor injects faults of config to plugin calls, plugin process is killed
+// and restarted for kill faults if supported, otherwise the call fails the same way
+func newChaosInterceptor(p pluginBackend, config ChaosConfig) callInterceptor {
+	injector := &chaosInjector{config: config, rand: rand.New(rand.NewSource(config.Seed))}
+
+	return func(_ context.Context, next callHandler) callHandler {
+		return func(funcName string, args ...interface{}) (interface{}, error) {
+			faults := injector.draw()
+			if faults.kill {
+				logger.Warn("chaos: kill plugin process", "funcName", funcName)
+				if restarter, ok := p.(processRestarter); ok {
+					if err := restarter.restartProcess(); err != nil {
+						logger.Error("chaos: restart plugin process failed", "error", err)
+					}
+				}
+				return nil, ErrChaosKilled
+			}
+
+			if faults.delay > 0 {
+				logger.Debug("chaos: delay plugin call", "funcName", funcName, "delay", faults.delay)
+				time.Sleep(faults.delay)
+			}
+			if faults.drop {
+				logger.Warn("chaos: drop plugin connection", "funcName", funcName)
+				return nil, ErrChaosDropped
+			}
+			return next(funcName, args...)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package funplugin
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// chaosErrors calls function n times and returns their errors
+func chaosErrors(t *testing.T, config ChaosConfig, n int) []error {
+	plugin, err := Init("lua/examples/debugtalk.lua", WithChaos(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	var errs []error
+	for i := 0; i < n; i++ {
+		_, err := plugin.Call("sum_two_int", 1, 2)
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func TestChaosDeterministic(t *testing.T) {
+	config := ChaosConfig{Seed: 42, DropRate: 0.3, KillRate: 0.2}
+	errs := chaosErrors(t, config, 50)
+	assert.Equal(t, errs, chaosErrors(t, config, 50))
+
+	var dropped, killed int
+	for _, err := range errs {
+		switch {
+		case errors.Is(err, ErrChaosDropped):
+			dropped++
+		case errors.Is(err, ErrChaosKilled):
+			killed++
+		default:
+			assert.Nil(t, err)
+		}
+	}
+	assert.Greater(t, dropped, 0)
+	assert.Greater(t, killed, 0)
+	assert.Less(t, dropped+killed, 50)
+
+	config.Seed = 7
+	assert.NotEqual(t, errs, chaosErrors(t, config, 50))
+}
+
+func TestChaosDelay(t *testing.T) {
+	plugin, err := Init("lua/examples/debugtalk.lua",
+		WithChaos(ChaosConfig{DelayRate: 1, MaxDelay: 50 * time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		result, err := plugin.Call("sum_two_int", 1, 2)
+		assert.Nil(t, err)
+		assert.Equal(t, float64(3), result)
+	}
+	assert.Greater(t, time.Since(start), 20*time.Millisecond)
+	assert.True(t, plugin.Snapshot().Options.Chaos)
+}
+
+func TestChaosKill(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	plugin, err := Init(pluginBinPath, WithChaos(ChaosConfig{KillRate: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	pid := plugin.Snapshot().Process.Pid
+	_, err = plugin.Call("sum_two_int", 1, 2)
+	assert.True(t, errors.Is(err, ErrChaosKilled))
+
+	// plugin process is restarted
+	process := plugin.Snapshot().Process
+	assert.NotEqual(t, pid, process.Pid)
+	assert.True(t, process.Running)
+	assert.True(t, plugin.Has("sum_two_int"))
+}
+
+func TestChaosKillConcurrent(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	plugin, err := Init(pluginBinPath, WithChaos(ChaosConfig{KillRate: 0.2, Seed: 7}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+	backend := backendOf(plugin).(*hashicorpPlugin)
+
+	var killed int32
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				_, err := plugin.Call("sum_two_int", 1, 2)
+				if errors.Is(err, ErrChaosKilled) {
+					atomic.AddInt32(&killed, 1)
+				}
+			}
+		}()
+	}
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		// health checks and heartbeat run while calls restart plugin process
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			_ = backend.ping()
+			_ = plugin.Snapshot()
+			_ = plugin.Has("sum_two_int")
+			if client, _ := backend.current(); client.Exited() {
+				assert.Nil(t, backend.restartExited(client))
+			}
+		}
+	}()
+	wg.Wait()
+	close(done)
+	<-stopped
+
+	// heartbeat does not start another process for killed ones restarted by chaos
+	assert.NotZero(t, atomic.LoadInt32(&killed))
+	process := plugin.Snapshot().Process
+	assert.Equal(t, int(atomic.LoadInt32(&killed)), process.Restarts)
+	assert.True(t, process.Running)
+}
@@ -0,0 +1,84 @@
+package funplugin
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// spawned plugin processes which should be killed when host exits,
+// key is the plugin client or command, value kills the process
+var (
+	spawnedMutex sync.Mutex
+	spawned      = make(map[interface{}]func())
+)
+
+func trackProcess(key interface{}, kill func()) {
+	spawnedMutex.Lock()
+	defer spawnedMutex.Unlock()
+	spawned[key] = kill
+}
+
+func untrackProcess(key interface{}) {
+	spawnedMutex.Lock()
+	defer spawnedMutex.Unlock()
+	delete(spawned, key)
+}
+
+// Cleanup kills all plugin processes spawned by current host process.
+// Defer it in main function to avoid orphaned plugin processes on panics.
+func Cleanup() {
+	spawnedMutex.Lock()
+	kills := make([]func(), 0, len(spawned))
+	for key, kill := range spawned {
+		kills = append(kills, kill)
+		delete(spawned, key)
+	}
+	spawnedMutex.Unlock()
+
+	if len(kills) == 0 {
+		return
+	}
+	logger.Info("kill spawned plugin processes", "count", len(kills))
+	var wg sync.WaitGroup
+	for _, kill := range kills {
+		wg.Add(1)
+		go func(kill func()) {
+			defer wg.Done()
+			kill()
+		}(kill)
+	}
+	wg.Wait()
+}
+
+var handleSignalsOnce sync.Once
+
+// HandleSignals kills all spawned plugin processes when host receives
+// the signals, default to SIGINT and SIGTERM. The signal is raised again
+// after cleanup, so that host exits as if no handler was installed.
+func HandleSignals(signals ...os.Signal) {
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+
+	handleSignalsOnce.Do(func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, signals...)
+		go func() {
+			sig := <-ch
+			logger.Warn("received signal, cleanup plugins", "signal", sig)
+			Cleanup()
+
+			signal.Stop(ch)
+			process, err := os.FindProcess(os.Getpid())
+			if err == nil {
+				err = process.Signal(sig)
+			}
+			if err != nil {
+				// signal is not supported, e.g. on windows
+				os.Exit(1)
+			}
+		}()
+	})
+}
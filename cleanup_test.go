@@ -0,0 +1,37 @@
+package funplugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanup(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	hPlugin, err := Init(pluginBinPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hPlugin.Quit()
+	sPlugin, err := Init(pluginBinPath, WithTransport("stdio"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sPlugin.Quit()
+
+	Cleanup()
+
+	assert.True(t, backendOf(hPlugin).(*hashicorpPlugin).client.Exited())
+	select {
+	case <-backendOf(sPlugin).(*stdioPlugin).current().done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stdio plugin process not killed")
+	}
+
+	spawnedMutex.Lock()
+	assert.Empty(t, spawned)
+	spawnedMutex.Unlock()
+}
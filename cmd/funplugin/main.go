@@ -0,0 +1,93 @@
+// Command funplugin exports host contract files and verifies plugin artifacts satisfy them,
+// e.g. in CI of plugin repository:
+//
+//	funplugin export-contract -o contract.json debugtalk.bin sum_ints concatenate
+//	funplugin verify-contract contract.json debugtalk.bin
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/lingcetech/funplugin"
+)
+
+const usage = `usage:
+  funplugin export-contract [-python3 path] [-o contract.json] <plugin> [function ...]
+  funplugin verify-contract [-python3 path] <contract.json> <plugin>
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "export-contract":
+		err = exportContract(os.Args[2:])
+	case "verify-contract":
+		err = verifyContract(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	python3 := fs.String("python3", "", "python3 executable for python plugins")
+	return fs, python3
+}
+
+func pluginOptions(python3 string) []funplugin.Option {
+	if python3 == "" {
+		return nil
+	}
+	return []funplugin.Option{funplugin.WithPython3(python3)}
+}
+
+func exportContract(args []string) error {
+	fs, python3 := newFlagSet("export-contract")
+	output := fs.String("o", "contract.json", "contract file path")
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	plugin, err := funplugin.Init(fs.Arg(0), pluginOptions(*python3)...)
+	if err != nil {
+		return err
+	}
+	defer plugin.Quit()
+	contract, err := funplugin.ExportContract(plugin, fs.Args()[1:])
+	if err != nil {
+		return err
+	}
+	if err := contract.WriteFile(*output); err != nil {
+		return err
+	}
+	fmt.Printf("exported %d functions of %s to %s\n", len(contract.Functions), fs.Arg(0), *output)
+	return nil
+}
+
+func verifyContract(args []string) error {
+	fs, python3 := newFlagSet("verify-contract")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	err := funplugin.VerifyContract(fs.Arg(0), fs.Arg(1), pluginOptions(*python3)...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("plugin %s satisfies contract %s\n", fs.Arg(1), fs.Arg(0))
+	return nil
+}
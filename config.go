@@ -0,0 +1,286 @@
+package funplugin
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+// ConfigEnvName specifies config file loaded by Init when WithConfigFile is not used
+const ConfigEnvName = "FUNPLUGIN_CONFIG"
+
+// Config holds plugin options tunable without code changes, it is loaded from yaml
+// config file by LoadConfig and overridden by FUNPLUGIN_* environment variables.
+// Zero values are unset and keep options specified in code.
+type Config struct {
+	LogLevel       string            `yaml:"log_level"`        // trace, debug, info, warn or error
+	LogFile        string            `yaml:"log_file"`         // log file path
+	DisableLogTime *bool             `yaml:"disable_log_time"` // whether disable log time
+	Python3        string            `yaml:"python3"`          // python3 path with funppy dependency
+	Transport      string            `yaml:"transport"`        // stdio or npipe
+	JSONNumber     string            `yaml:"json_number"`      // number or int64
+	GRPCReflection *bool             `yaml:"grpc_reflection"`  // whether expose gRPC reflection service
+	Isolation      string            `yaml:"isolation"`        // isolation backend name
+	StartTimeout   Duration          `yaml:"start_timeout"`    // timeout waiting for plugin handshake
+	MaxConcurrency int               `yaml:"max_concurrency"`  // max concurrent plugin calls
+	QueueSize      int               `yaml:"queue_size"`       // max calls waiting for concurrency slots
+	QueueTimeout   Duration          `yaml:"queue_timeout"`    // max time a call waits in queue
+	Seed           *int64            `yaml:"seed"`             // random seed of deterministic mode
+	Env            map[string]string `yaml:"env"`              // extra env of plugin process
+}
+
+// Duration is time.Duration in config file, e.g. 90s or 2m
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	duration, err := time.ParseDuration(value.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid duration %q", value.Line, value.Value)
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// LoadConfig loads plugin options from yaml config file, unknown keys are rejected
+// to catch typos. FUNPLUGIN_* environment variable overrides are not applied.
+func LoadConfig(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read config file failed")
+	}
+
+	config := &Config{}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && err != io.EOF {
+		return nil, errors.Wrapf(err, "parse config file %s failed", path)
+	}
+	if err := config.validate(); err != nil {
+		return nil, errors.Wrapf(err, "invalid config file %s", path)
+	}
+	return config, nil
+}
+
+// ApplyEnv overrides config with environment variables, e.g. FUNPLUGIN_LOG_LEVEL=debug,
+// FUNPLUGIN_START_TIMEOUT=2m, and FUNPLUGIN_ENV_<NAME>=value for plugin process env
+func (c *Config) ApplyEnv() error {
+	strs := map[string]*string{
+		"FUNPLUGIN_LOG_LEVEL":   &c.LogLevel,
+		"FUNPLUGIN_LOG_FILE":    &c.LogFile,
+		"FUNPLUGIN_PYTHON3":     &c.Python3,
+		"FUNPLUGIN_TRANSPORT":   &c.Transport,
+		"FUNPLUGIN_JSON_NUMBER": &c.JSONNumber,
+		"FUNPLUGIN_ISOLATION":   &c.Isolation,
+	}
+	for name, field := range strs {
+		if value, ok := os.LookupEnv(name); ok {
+			*field = value
+		}
+	}
+
+	bools := map[string]**bool{
+		"FUNPLUGIN_DISABLE_LOG_TIME": &c.DisableLogTime,
+		"FUNPLUGIN_GRPC_REFLECTION":  &c.GRPCReflection,
+	}
+	for name, field := range bools {
+		if value, ok := os.LookupEnv(name); ok {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %q", name, value)
+			}
+			*field = &b
+		}
+	}
+
+	ints := map[string]*int{
+		"FUNPLUGIN_MAX_CONCURRENCY": &c.MaxConcurrency,
+		"FUNPLUGIN_QUEUE_SIZE":      &c.QueueSize,
+	}
+	for name, field := range ints {
+		if value, ok := os.LookupEnv(name); ok {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %q", name, value)
+			}
+			*field = n
+		}
+	}
+
+	durations := map[string]*Duration{
+		"FUNPLUGIN_START_TIMEOUT": &c.StartTimeout,
+		"FUNPLUGIN_QUEUE_TIMEOUT": &c.QueueTimeout,
+	}
+	for name, field := range durations {
+		if value, ok := os.LookupEnv(name); ok {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %q", name, value)
+			}
+			*field = Duration(d)
+		}
+	}
+
+	if value, ok := os.LookupEnv("FUNPLUGIN_SEED"); ok {
+		seed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid FUNPLUGIN_SEED: %q", value)
+		}
+		c.Seed = &seed
+	}
+
+	const envPrefix = "FUNPLUGIN_ENV_"
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, envPrefix) {
+			continue
+		}
+		kv = strings.TrimPrefix(kv, envPrefix)
+		if i := strings.Index(kv, "="); i > 0 {
+			if c.Env == nil {
+				c.Env = make(map[string]string)
+			}
+			c.Env[kv[:i]] = kv[i+1:]
+		}
+	}
+	return c.validate()
+}
+
+func (c *Config) validate() error {
+	if c.LogLevel != "" && hclog.LevelFromString(c.LogLevel) == hclog.NoLevel {
+		return fmt.Errorf("unsupported log level: %s", c.LogLevel)
+	}
+	switch c.Transport {
+	case "", fungo.TransportStdio, fungo.TransportNamedPipe:
+	default:
+		return fmt.Errorf("unsupported plugin transport: %s", c.Transport)
+	}
+	if !fungo.NumberMode(c.JSONNumber).Valid() {
+		return fmt.Errorf("unsupported JSON number mode: %s", c.JSONNumber)
+	}
+	if c.StartTimeout < 0 || c.QueueTimeout < 0 {
+		return fmt.Errorf("timeout should not be negative")
+	}
+	if c.MaxConcurrency < 0 || c.QueueSize < 0 {
+		return fmt.Errorf("concurrency and queue size should not be negative")
+	}
+	return nil
+}
+
+// Options returns plugin options of the fields set in config,
+// they override options specified before them
+func (c *Config) Options() []Option {
+	var options []Option
+	if c.LogLevel != "" {
+		level := hclog.LevelFromString(c.LogLevel)
+		options = append(options, func(o *pluginOption) {
+			o.logLevel = level
+		})
+	}
+	if c.LogFile != "" {
+		options = append(options, WithLogFile(c.LogFile))
+	}
+	if c.DisableLogTime != nil {
+		options = append(options, WithDisableTime(*c.DisableLogTime))
+	}
+	if c.Python3 != "" {
+		options = append(options, WithPython3(c.Python3))
+	}
+	if c.Transport != "" {
+		options = append(options, WithTransport(c.Transport))
+	}
+	if c.JSONNumber != "" {
+		options = append(options, WithJSONNumber(fungo.NumberMode(c.JSONNumber)))
+	}
+	if c.GRPCReflection != nil {
+		options = append(options, WithGRPCReflection(*c.GRPCReflection))
+	}
+	if c.Isolation != "" {
+		options = append(options, WithIsolation(c.Isolation))
+	}
+	if c.StartTimeout > 0 {
+		options = append(options, WithStartTimeout(time.Duration(c.StartTimeout)))
+	}
+	if c.MaxConcurrency > 0 {
+		options = append(options, func(o *pluginOption) {
+			o.maxConcurrency = c.MaxConcurrency
+			o.queueSize = c.QueueSize
+			o.queueTimeout = time.Duration(c.QueueTimeout)
+		})
+	}
+	if c.Seed != nil {
+		options = append(options, WithSeed(*c.Seed))
+	}
+	if len(c.Env) > 0 {
+		options = append(options, WithEnv(c.Env))
+	}
+	return options
+}
+
+// loadConfig merges options with config file and environment variables,
+// precedence: environment variables > config file > options in code
+func (o *pluginOption) loadConfig() error {
+	path := o.configFile
+	if path == "" {
+		path = os.Getenv(ConfigEnvName)
+	}
+	config := &Config{}
+	if path != "" {
+		var err error
+		if config, err = LoadConfig(path); err != nil {
+			return err
+		}
+	}
+	if err := config.ApplyEnv(); err != nil {
+		return errors.Wrap(err, "invalid config environment variable")
+	}
+	for _, option := range config.Options() {
+		option(o)
+	}
+	return nil
+}
+
+// environ returns env of plugin process with host info, env set by WithEnv overrides host env
+func (o *pluginOption) environ() []string {
+	env := append(os.Environ(), hostInfoEnv())
+	return append(env, o.extraEnv()...)
+}
+
+// extraEnv returns env set by WithEnv sorted by name,
+// followed by env passing options to plugin process
+func (o *pluginOption) extraEnv() []string {
+	keys := make([]string, 0, len(o.env))
+	for key := range o.env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	env := make([]string, 0, len(keys))
+	for _, key := range keys {
+		env = append(env, fmt.Sprintf("%s=%s", key, o.env[key]))
+	}
+	if o.jsonNumber != "" {
+		env = append(env, fmt.Sprintf("%s=%s", fungo.JSONNumberEnvName, o.jsonNumber))
+	}
+	if o.dataDir != "" {
+		env = append(env, fmt.Sprintf("%s=%s", fungo.DataDirEnvName, o.dataDir))
+	}
+	if o.artifactDir != "" {
+		env = append(env, fmt.Sprintf("%s=%s", fungo.ArtifactDirEnvName, o.artifactDir))
+	}
+	if o.seed != nil {
+		env = append(env, fmt.Sprintf("%s=%d", fungo.SeedEnvName, *o.seed))
+	}
+	if o.pythonPath != nil {
+		env = append(env, o.pythonPath.env()...)
+	}
+	return env
+}
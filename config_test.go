@@ -0,0 +1,166 @@
+package funplugin
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "funplugin.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := writeConfig(t, `
+log_level: debug
+python3: /opt/venv/bin/python3
+transport: stdio
+disable_log_time: false
+start_timeout: 90s
+max_concurrency: 4
+queue_size: 10
+queue_timeout: 500ms
+env:
+  HTTP_PROXY: http://proxy:8080
+`)
+	config, err := LoadConfig(path)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "debug", config.LogLevel)
+	assert.Equal(t, "/opt/venv/bin/python3", config.Python3)
+	assert.Equal(t, "stdio", config.Transport)
+	assert.False(t, *config.DisableLogTime)
+	assert.Nil(t, config.GRPCReflection)
+	assert.Equal(t, Duration(90*time.Second), config.StartTimeout)
+	assert.Equal(t, Duration(500*time.Millisecond), config.QueueTimeout)
+	assert.Equal(t, map[string]string{"HTTP_PROXY": "http://proxy:8080"}, config.Env)
+
+	// empty config file
+	config, err = LoadConfig(writeConfig(t, ""))
+	assert.Nil(t, err)
+	assert.Empty(t, config.Options())
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	_, err := LoadConfig(writeConfig(t, "start_timout: 1m\n"))
+	assert.Contains(t, err.Error(), "field start_timout not found")
+
+	_, err = LoadConfig(writeConfig(t, "start_timeout: 1 minute\n"))
+	assert.Contains(t, err.Error(), `invalid duration "1 minute"`)
+
+	_, err = LoadConfig(writeConfig(t, "log_level: verbose\n"))
+	assert.Contains(t, err.Error(), "unsupported log level: verbose")
+
+	_, err = LoadConfig(writeConfig(t, "transport: pipe\n"))
+	assert.Contains(t, err.Error(), "unsupported plugin transport: pipe")
+
+	_, err = LoadConfig(writeConfig(t, "json_number: bogus\n"))
+	assert.Contains(t, err.Error(), "unsupported JSON number mode: bogus")
+
+	_, err = LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Contains(t, err.Error(), "read config file failed")
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	path := writeConfig(t, "python3: /config/python3\nstart_timeout: 2m\nlog_level: warn\n")
+	t.Setenv("FUNPLUGIN_START_TIMEOUT", "3m")
+	t.Setenv("FUNPLUGIN_ENV_GREETING", "hello")
+
+	option := &pluginOption{}
+	for _, o := range []Option{
+		WithPython3("/code/python3"),
+		WithStartTimeout(time.Minute),
+		WithGRPCReflection(true),
+		WithConfigFile(path),
+	} {
+		o(option)
+	}
+	assert.Nil(t, option.loadConfig())
+	// config file overrides code, env overrides config file
+	assert.Equal(t, "/config/python3", option.python3)
+	assert.Equal(t, 3*time.Minute, option.startTimeout)
+	assert.Equal(t, hclog.Warn, option.logLevel)
+	assert.Equal(t, []string{"GREETING=hello"}, option.extraEnv())
+	// unset in config keeps option in code
+	assert.True(t, option.grpcReflection)
+
+	t.Setenv("FUNPLUGIN_GRPC_REFLECTION", "yes")
+	assert.Contains(t, option.loadConfig().Error(), "invalid FUNPLUGIN_GRPC_REFLECTION")
+}
+
+func TestInitValidatesConfig(t *testing.T) {
+	t.Setenv("FUNPLUGIN_JSON_NUMBER", "bogus")
+	_, err := Init("lua/examples/debugtalk.lua")
+	assert.Contains(t, err.Error(), "unsupported JSON number mode: bogus")
+
+	// options of config are validated against plugin path
+	t.Setenv("FUNPLUGIN_JSON_NUMBER", "")
+	t.Setenv("FUNPLUGIN_TRANSPORT", "stdio")
+	_, err = Init("lua/examples/debugtalk.lua")
+	assert.Contains(t, err.Error(), "transport stdio only applies to local .bin/.py plugins")
+}
+
+func TestConfigSeed(t *testing.T) {
+	option := &pluginOption{}
+	WithConfigFile(writeConfig(t, "seed: 42\n"))(option)
+	assert.Nil(t, option.loadConfig())
+	assert.Equal(t, []string{"HRP_PLUGIN_SEED=42"}, option.extraEnv())
+
+	// reproduce a run without code changes
+	t.Setenv("FUNPLUGIN_SEED", "7")
+	assert.Nil(t, option.loadConfig())
+	assert.Equal(t, int64(7), *option.seed)
+
+	t.Setenv("FUNPLUGIN_SEED", "random")
+	assert.Contains(t, option.loadConfig().Error(), "invalid FUNPLUGIN_SEED")
+}
+
+func TestConfigEnvFile(t *testing.T) {
+	path := writeConfig(t, "transport: carrier-pigeon\n")
+	t.Setenv(ConfigEnvName, path)
+	_, err := Init("debugtalk.lua")
+	assert.EqualError(t, err, "invalid config file "+path+": unsupported plugin transport: carrier-pigeon")
+}
+
+func TestConfigPluginEnv(t *testing.T) {
+	python3, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not found")
+	}
+	// plugin prints env to stderr and never completes handshake
+	path := filepath.Join(t.TempDir(), "env.py")
+	script := "import os, sys, time\nprint('greeting:', os.environ['GREETING'], file=sys.stderr, flush=True)\ntime.sleep(30)\n"
+	assert.Nil(t, os.WriteFile(path, []byte(script), 0o644))
+
+	config := writeConfig(t, "start_timeout: 1s\nenv:\n  GREETING: hello\n")
+	_, err = Init(path, WithPython3(python3), WithConfigFile(config))
+	assert.True(t, errors.Is(err, ErrStartTimeout))
+	assert.Contains(t, err.Error(), "greeting: hello")
+}
+
+func TestDockerRunArgsEnv(t *testing.T) {
+	option := &pluginOption{
+		dockerImage: "debugtalk:latest",
+		env:         map[string]string{"B": "2", "A": "1"},
+	}
+	args := dockerRunArgs("/app/debugtalk.bin", option, 12345)
+	assert.Equal(t, []string{
+		"run", "--rm", "--detach",
+		"--publish", "127.0.0.1:12345:50051",
+		"--env", "HRP_PLUGIN_SIDECAR_ADDR=0.0.0.0:50051",
+		"--env", hostInfoEnv(),
+		"--env", "A=1", "--env", "B=2",
+		"debugtalk:latest", "/app/debugtalk.bin",
+	}, args)
+}
@@ -0,0 +1,137 @@
+package funplugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+// contractVersion is version of contract file format
+const contractVersion = 1
+
+// Contract is functions and signatures host depends on, exported by host and verified
+// against plugin artifacts, e.g. in CI of plugin repository. Functions without Params
+// are only required to exist.
+type Contract struct {
+	Version   int                       `json:"version"`
+	Functions map[string]fungo.FuncSpec `json:"functions"`
+}
+
+// ContractError is returned by VerifyContract when plugin does not satisfy contract
+type ContractError struct {
+	Path       string       // plugin file path
+	Violations []FuncChange // removed or incompatibly changed functions, sorted by name
+}
+
+func (e *ContractError) Error() string {
+	lines := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		line := fmt.Sprintf("  - %s %s", v.Kind, v.Name)
+		if len(v.Details) > 0 {
+			line += ": " + strings.Join(v.Details, "; ")
+		}
+		lines = append(lines, line)
+	}
+	return fmt.Sprintf("plugin %s violates contract with %d functions:\n%s",
+		e.Path, len(e.Violations), strings.Join(lines, "\n"))
+}
+
+// ExportContract returns contract of functions host depends on, with signatures described
+// by plugin p, e.g. functions referenced by test suites of host. All functions of plugin
+// are exported if funcNames is empty.
+func ExportContract(p IPlugin, funcNames []string) (*Contract, error) {
+	specs, err := p.Describe()
+	if err != nil {
+		return nil, err
+	}
+	contract := &Contract{Version: contractVersion, Functions: make(map[string]fungo.FuncSpec)}
+	if len(funcNames) == 0 {
+		contract.Functions = specs
+		return contract, nil
+	}
+	var missing []string
+	for _, name := range funcNames {
+		spec, ok := specs[name]
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		contract.Functions[name] = spec
+	}
+	if len(missing) > 0 {
+		return nil, &MissingFunctionsError{Path: p.Path(), Missing: missing}
+	}
+	return contract, nil
+}
+
+// LoadContract reads contract file written by WriteFile
+func LoadContract(path string) (*Contract, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read contract file failed")
+	}
+	contract := &Contract{}
+	if err := json.Unmarshal(content, contract); err != nil {
+		return nil, errors.Wrapf(err, "parse contract file %s failed", path)
+	}
+	if contract.Version > contractVersion {
+		return nil, fmt.Errorf("unsupported contract version %d of %s", contract.Version, path)
+	}
+	return contract, nil
+}
+
+// WriteFile writes contract as indented JSON, which is reviewed and committed to
+// plugin repository
+func (c *Contract) WriteFile(path string) error {
+	content, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "marshal contract failed")
+	}
+	if err := os.WriteFile(path, append(content, '\n'), 0o644); err != nil {
+		return errors.Wrap(err, "write contract file failed")
+	}
+	return nil
+}
+
+// Verify checks plugin p provides all functions of contract with compatible signatures,
+// see DiffFuncSpecs, and returns *ContractError reporting all violations
+func (c *Contract) Verify(p IPlugin) error {
+	specs, err := p.Describe()
+	if err != nil {
+		return err
+	}
+	var violations []FuncChange
+	for _, change := range DiffFuncSpecs(c.Functions, specs) {
+		if change.Breaking {
+			violations = append(violations, change)
+		}
+	}
+	if len(violations) > 0 {
+		sort.SliceStable(violations, func(i, j int) bool {
+			return violations[i].Name < violations[j].Name
+		})
+		return &ContractError{Path: p.Path(), Violations: violations}
+	}
+	return nil
+}
+
+// VerifyContract loads contract file and plugin artifact, and checks the plugin
+// satisfies the contract; it is used by `funplugin verify-contract` in CI
+func VerifyContract(contractPath, pluginPath string, options ...Option) error {
+	contract, err := LoadContract(contractPath)
+	if err != nil {
+		return err
+	}
+	plugin, err := Init(pluginPath, options...)
+	if err != nil {
+		return errors.Wrapf(err, "init plugin %s failed", pluginPath)
+	}
+	defer plugin.Quit()
+	return contract.Verify(plugin)
+}
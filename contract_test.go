@@ -0,0 +1,74 @@
+package funplugin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+func TestContract(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	plugin, err := Init(pluginBinPath)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	defer plugin.Quit()
+
+	_, err = ExportContract(plugin, []string{"sum_ints", "not_exist"})
+	var missingErr *MissingFunctionsError
+	if assert.ErrorAs(t, err, &missingErr) {
+		assert.Equal(t, []string{"not_exist"}, missingErr.Missing)
+	}
+
+	contract, err := ExportContract(plugin, []string{"sum_ints", "concatenate"})
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, 1, contract.Version)
+	assert.Len(t, contract.Functions, 2)
+	assert.Equal(t, fungo.ParamVarPositional, contract.Functions["sum_ints"].Params[0].Kind)
+
+	path := filepath.Join(t.TempDir(), "contract.json")
+	if !assert.Nil(t, contract.WriteFile(path)) {
+		t.FailNow()
+	}
+	loaded, err := LoadContract(path)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, contract, loaded)
+	assert.Nil(t, loaded.Verify(plugin))
+	assert.Nil(t, VerifyContract(path, pluginBinPath))
+
+	// contract committed for CI of examples
+	assert.Nil(t, VerifyContract("fungo/examples/contract.json", pluginBinPath))
+
+	// host depends on a function plugin does not provide, and on another signature
+	sumInts := contract.Functions["sum_ints"]
+	sumInts.Returns = "float64"
+	contract.Functions["sum_ints"] = sumInts
+	contract.Functions["get_user"] = fungo.FuncSpec{}
+	err = contract.Verify(plugin)
+	var contractErr *ContractError
+	if assert.ErrorAs(t, err, &contractErr) {
+		assert.Equal(t, []FuncChange{
+			{Name: "get_user", Kind: FuncRemoved, Breaking: true},
+			{Name: "sum_ints", Kind: FuncChanged, Breaking: true, Details: []string{"result type float64 -> int"}},
+		}, contractErr.Violations)
+	}
+	assert.Contains(t, err.Error(), "violates contract with 2 functions")
+
+	// lua plugin lists functions without signatures, which are verified by name
+	err = VerifyContract(path, "lua/examples/debugtalk.lua")
+	assert.Nil(t, err)
+
+	assert.Nil(t, os.WriteFile(path, []byte(`{"version": 2, "functions": {}}`), 0o644))
+	_, err = LoadContract(path)
+	assert.Contains(t, err.Error(), "unsupported contract version 2")
+}
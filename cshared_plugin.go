@@ -0,0 +1,169 @@
+//go:build darwin || linux
+
+package funplugin
+
+import (
+	"debug/elf"
+	"encoding/json"
+	"fmt"
+	"unsafe"
+
+	"github.com/ebitengine/purego"
+	"github.com/pkg/errors"
+)
+
+// C ABI symbols that a shared library must export to be loaded as plugin.
+//
+//	// returns JSON array of function names, e.g. ["sum_two_int"]
+//	char *funplugin_register(void);
+//	// calls function with JSON array arguments, returns JSON object
+//	// {"value": <result>} on success or {"error": "<message>"} on failure
+//	char *funplugin_call(const char *name, const char *args);
+//	// frees strings returned by funplugin_register and funplugin_call
+//	void funplugin_free(char *ptr);
+const (
+	cSymbolRegister = "funplugin_register"
+	cSymbolCall     = "funplugin_call"
+	cSymbolFree     = "funplugin_free"
+)
+
+// cSharedPlugin loads C ABI shared library via dlopen, no cgo required
+type cSharedPlugin struct {
+	handle    uintptr
+	path      string          // plugin file path
+	functions map[string]bool // registered function names
+
+	register func() *byte
+	call     func(name, args string) *byte
+	free     func(ptr *byte)
+}
+
+type cCallResult struct {
+	Value interface{} `json:"value"`
+	Error string      `json:"error"`
+}
+
+// isCSharedPlugin checks if .so file is a C shared library following the
+// funplugin symbol convention rather than a go plugin
+func isCSharedPlugin(path string) bool {
+	f, err := elf.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	symbols, err := f.DynamicSymbols()
+	if err != nil {
+		return false
+	}
+	for _, symbol := range symbols {
+		if symbol.Name == cSymbolCall {
+			return true
+		}
+	}
+	return false
+}
+
+func newCSharedPlugin(path string) (*cSharedPlugin, error) {
+	// logger
+	logger := logger.ResetNamed("cshared-plugin")
+
+	handle, err := purego.Dlopen(path, purego.RTLD_NOW|purego.RTLD_LOCAL)
+	if err != nil {
+		logger.Error("load c shared library failed", "path", path, "error", err)
+		return nil, errors.Wrap(err, "load c shared library failed")
+	}
+
+	p := &cSharedPlugin{
+		handle:    handle,
+		path:      path,
+		functions: make(map[string]bool),
+	}
+	for _, symbol := range []string{cSymbolRegister, cSymbolCall, cSymbolFree} {
+		if _, err := purego.Dlsym(handle, symbol); err != nil {
+			purego.Dlclose(handle)
+			return nil, fmt.Errorf("symbol %s not found in %s", symbol, path)
+		}
+	}
+	purego.RegisterLibFunc(&p.register, handle, cSymbolRegister)
+	purego.RegisterLibFunc(&p.call, handle, cSymbolCall)
+	purego.RegisterLibFunc(&p.free, handle, cSymbolFree)
+
+	var names []string
+	if err := json.Unmarshal([]byte(p.takeString(p.register())), &names); err != nil {
+		purego.Dlclose(handle)
+		return nil, errors.Wrap(err, "parse registered function names failed")
+	}
+	for _, name := range names {
+		p.functions[name] = true
+	}
+
+	logger.Info("load c shared library success", "path", path, "functions", names)
+	return p, nil
+}
+
+// takeString copies C string to go string and frees it with funplugin_free
+func (p *cSharedPlugin) takeString(ptr *byte) string {
+	if ptr == nil {
+		return ""
+	}
+	defer p.free(ptr)
+
+	var length int
+	for *(*byte)(unsafe.Add(unsafe.Pointer(ptr), length)) != 0 {
+		length++
+	}
+	return string(unsafe.Slice(ptr, length))
+}
+
+func (p *cSharedPlugin) Type() string {
+	return "cshared-plugin"
+}
+
+func (p *cSharedPlugin) Path() string {
+	return p.path
+}
+
+func (p *cSharedPlugin) Has(funcName string) bool {
+	logger.Debug("check if plugin has function", "funcName", funcName)
+	return p.functions[funcName]
+}
+
+func (p *cSharedPlugin) GetNames() ([]string, error) {
+	var names []string
+	for name := range p.functions {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// Call calls C function, the library functions may be called concurrently
+func (p *cSharedPlugin) Call(funcName string, args ...interface{}) (interface{}, error) {
+	if !p.Has(funcName) {
+		return nil, fmt.Errorf("function %s not found", funcName)
+	}
+
+	funcArgs, err := json.Marshal(args)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal Call() funcArgs")
+	}
+
+	resp := p.takeString(p.call(funcName, string(funcArgs)))
+	var result cCallResult
+	if err := json.Unmarshal([]byte(resp), &result); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal Call() response")
+	}
+	if result.Error != "" {
+		return nil, fmt.Errorf("%s", result.Error)
+	}
+	return result.Value, nil
+}
+
+func (p *cSharedPlugin) Quit() error {
+	logger.Info("unload c shared library")
+	return purego.Dlclose(p.handle)
+}
+
+func (p *cSharedPlugin) StartHeartbeat() {
+
+}
@@ -0,0 +1,50 @@
+//go:build linux
+
+package funplugin
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lingcetech/funplugin/myexec"
+)
+
+func buildCSharedPlugin(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "debugtalk.so")
+	err := myexec.RunCommand("gcc", "-shared", "-fPIC",
+		"-o", path, "c/examples/debugtalk.c")
+	if err != nil {
+		t.Skip("gcc is not available", err)
+	}
+	return path
+}
+
+func TestCSharedPlugin(t *testing.T) {
+	path := buildCSharedPlugin(t)
+	plugin, err := Init(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	assert.Equal(t, "cshared-plugin", plugin.Type())
+	assert.True(t, plugin.Has("sum_two_int"))
+	assert.False(t, plugin.Has("not_exist"))
+
+	v, err := plugin.Call("sum_two_int", 1, 2)
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+	assert.EqualValues(t, 3, v)
+
+	v, err = plugin.Call("divide", 6, 4)
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+	assert.Equal(t, 1.5, v)
+
+	_, err = plugin.Call("divide", 1, 0)
+	assert.EqualError(t, err, "division by zero")
+}
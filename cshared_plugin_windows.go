@@ -0,0 +1,14 @@
+//go:build windows
+
+package funplugin
+
+import "fmt"
+
+func isCSharedPlugin(path string) bool {
+	return false
+}
+
+func newCSharedPlugin(path string) (pluginBackend, error) {
+	logger.Warn("c shared library plugin does not support windows")
+	return nil, fmt.Errorf("c shared library plugin does not support windows")
+}
@@ -0,0 +1,251 @@
+package funplugin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+// DaemonRequest asks daemon for a running plugin server
+type DaemonRequest struct {
+	Path     string   `json:"path"`     // absolute plugin file path
+	LangType langType `json:"langType"` // go or py
+	Python3  string   `json:"python3"`  // python3 path for python plugin
+	RPCType  rpcType  `json:"rpcType"`  // grpc or rpc for go plugin
+}
+
+// pooledServer is a plugin server shared by host processes
+type pooledServer struct {
+	state     *ServerState
+	exited    <-chan struct{}
+	refs      int
+	idleTimer *time.Timer
+}
+
+func (s *pooledServer) alive() bool {
+	select {
+	case <-s.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// daemonPool manages plugin servers shared across host processes on the same machine,
+// servers are reference counted and shut down after being idle for idleTimeout.
+type daemonPool struct {
+	mutex       sync.Mutex
+	servers     map[string]*pooledServer // key is plugin path
+	idleTimeout time.Duration
+	logDir      string
+}
+
+func (d *daemonPool) acquire(req *DaemonRequest) (*ServerState, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if server, ok := d.servers[req.Path]; ok && server.alive() {
+		server.refs++
+		if server.idleTimer != nil {
+			server.idleTimer.Stop()
+			server.idleTimer = nil
+		}
+		logger.Info("reuse pooled plugin server", "path", req.Path, "refs", server.refs)
+		return server.state, nil
+	}
+
+	sum := sha256.Sum256([]byte(req.Path))
+	logPath := filepath.Join(d.logDir, hex.EncodeToString(sum[:8])+".log")
+	option := &pluginOption{langType: req.LangType, python3: req.Python3}
+	state, exited, err := launchDetachedPlugin(req.Path, option, req.RPCType, logPath)
+	if err != nil {
+		return nil, err
+	}
+	d.servers[req.Path] = &pooledServer{state: state, exited: exited, refs: 1}
+	logger.Info("launch pooled plugin server", "path", req.Path, "pid", state.Pid)
+	return state, nil
+}
+
+func (d *daemonPool) release(path string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	server, ok := d.servers[path]
+	if !ok {
+		return
+	}
+	server.refs--
+	logger.Info("release pooled plugin server", "path", path, "refs", server.refs)
+	if server.refs > 0 {
+		return
+	}
+	server.idleTimer = time.AfterFunc(d.idleTimeout, func() {
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+		if server.refs > 0 || d.servers[path] != server {
+			return
+		}
+		logger.Info("shutdown idle plugin server", "path", path, "pid", server.state.Pid)
+		killServer(server.state)
+		delete(d.servers, path)
+	})
+}
+
+func (d *daemonPool) shutdown() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for path, server := range d.servers {
+		if server.idleTimer != nil {
+			server.idleTimer.Stop()
+		}
+		killServer(server.state)
+		delete(d.servers, path)
+	}
+}
+
+func killServer(state *ServerState) {
+	if process, err := os.FindProcess(state.Pid); err == nil {
+		process.Kill()
+	}
+}
+
+// daemonSession serves JSON-RPC requests of one host connection,
+// plugin servers acquired by the host are released when connection closed.
+type daemonSession struct {
+	pool     *daemonPool
+	mutex    sync.Mutex
+	acquired map[string]int // plugin path -> reference count
+}
+
+func (s *daemonSession) Acquire(req *DaemonRequest, state *ServerState) error {
+	result, err := s.pool.acquire(req)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	s.acquired[req.Path]++
+	s.mutex.Unlock()
+	*state = *result
+	return nil
+}
+
+func (s *daemonSession) Release(path string, _ *struct{}) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.acquired[path] == 0 {
+		return fmt.Errorf("plugin %s not acquired", path)
+	}
+	s.acquired[path]--
+	s.pool.release(path)
+	return nil
+}
+
+func (s *daemonSession) releaseAll() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for path, refs := range s.acquired {
+		for i := 0; i < refs; i++ {
+			s.pool.release(path)
+		}
+	}
+	s.acquired = make(map[string]int)
+}
+
+// ServeDaemon serves a pool of plugin servers shared by host processes connecting
+// with WithDaemon, plugin servers idle for idleTimeout are shut down.
+// All plugin servers are killed when listener is closed.
+func ServeDaemon(listener net.Listener, idleTimeout time.Duration) error {
+	logDir, err := os.MkdirTemp("", "funplugin-daemon")
+	if err != nil {
+		return errors.Wrap(err, "create daemon log directory failed")
+	}
+	pool := &daemonPool{
+		servers:     make(map[string]*pooledServer),
+		idleTimeout: idleTimeout,
+		logDir:      logDir,
+	}
+	defer func() {
+		pool.shutdown()
+		os.RemoveAll(logDir)
+	}()
+
+	logger.Info("serve plugin daemon", "addr", listener.Addr(), "idleTimeout", idleTimeout)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go func(conn net.Conn) {
+			session := &daemonSession{pool: pool, acquired: make(map[string]int)}
+			server := rpc.NewServer()
+			server.RegisterName("Daemon", session)
+			server.ServeCodec(jsonrpc.NewServerCodec(conn))
+			// host exited or quit
+			session.releaseAll()
+		}(conn)
+	}
+}
+
+// daemonPlugin is plugin server acquired from daemon
+type daemonPlugin struct {
+	*hashicorpPlugin
+	client *rpc.Client
+}
+
+func newDaemonPlugin(path string, option *pluginOption) (*daemonPlugin, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "get plugin absolute path failed")
+	}
+	logger := logger.ResetNamed(fmt.Sprintf("daemon-%v", option.langType))
+
+	conn, err := net.Dial(option.daemonNetwork, option.daemonAddr)
+	if err != nil {
+		return nil, errors.Wrap(err, "connect plugin daemon failed")
+	}
+	client := jsonrpc.NewClient(conn)
+
+	state := &ServerState{}
+	err = client.Call("Daemon.Acquire", &DaemonRequest{
+		Path:     path,
+		LangType: option.langType,
+		Python3:  option.python3,
+		RPCType:  rpcType(os.Getenv(fungo.PluginTypeEnvName)),
+	}, state)
+	if err != nil {
+		client.Close()
+		return nil, errors.Wrap(err, "acquire plugin from daemon failed")
+	}
+
+	p, err := attachDetachedPlugin(path, option, state)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	logger.Info("attach daemon plugin success", "path", path, "pid", state.Pid)
+	return &daemonPlugin{hashicorpPlugin: p, client: client}, nil
+}
+
+func (p *daemonPlugin) Type() string {
+	return fmt.Sprintf("daemon-%s-%v", p.rpcType, p.option.langType)
+}
+
+func (p *daemonPlugin) Quit() error {
+	logger.Info("release daemon plugin", "path", p.path)
+	if err := p.client.Call("Daemon.Release", p.path, nil); err != nil {
+		logger.Warn("release daemon plugin failed", "error", err)
+	}
+	p.client.Close()
+	return p.hashicorpPlugin.Quit()
+}
@@ -0,0 +1,54 @@
+package funplugin
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDaemonPlugin(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	socket := filepath.Join(t.TempDir(), "daemon.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go ServeDaemon(listener, 200*time.Millisecond)
+
+	plugin1, err := Init(pluginBinPath, WithDaemon("unix", socket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugin2, err := Init(pluginBinPath, WithDaemon("unix", socket))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// plugin server is shared
+	pid := backendOf(plugin1).(*daemonPlugin).reattach.Pid
+	assert.Equal(t, pid, backendOf(plugin2).(*daemonPlugin).reattach.Pid)
+	assert.Equal(t, "daemon-grpc-go", plugin1.Type())
+	assertPlugin(t, plugin1)
+
+	// plugin server is still used by plugin2
+	assert.NoError(t, plugin1.Quit())
+	time.Sleep(400 * time.Millisecond)
+	assertPlugin(t, plugin2)
+
+	// plugin server is shut down after idle timeout
+	assert.NoError(t, plugin2.Quit())
+	time.Sleep(400 * time.Millisecond)
+	plugin3, err := Init(pluginBinPath, WithDaemon("unix", socket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin3.Quit()
+	assert.NotEqual(t, pid, backendOf(plugin3).(*daemonPlugin).reattach.Pid)
+	assertPlugin(t, plugin3)
+}
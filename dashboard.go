@@ -0,0 +1,294 @@
+package funplugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+const (
+	dashboardRefresh = 5        // seconds between reloads of dashboard pages
+	dashboardErrors  = 20       // recent errors shown on plugins page
+	dashboardLogSize = 64 << 10 // bytes of plugin log file tail shown on plugin page
+)
+
+// dashboardError is a recent error of a plugin shown on plugins page
+type dashboardError struct {
+	Plugin ManagedPlugin
+	CallError
+}
+
+// dashboardFunc is a plugin function shown on plugin page, Spec is nil if the
+// function is not described by plugin
+type dashboardFunc struct {
+	Name  string
+	Spec  *fungo.FuncSpec
+	Stats FuncStats
+}
+
+// DashboardHandler returns HTTP handler serving a small web dashboard of plugins loaded
+// by manager, for services embedding funplugin to expose plugin observability, e.g.
+// mounted with http.StripPrefix("/plugins", DashboardHandler(manager)):
+//
+//	GET /                      => plugins with call stats and recent errors
+//	GET /plugin?tenant=&name=  => functions, stats, recent errors and log of a plugin
+//	GET /api/plugins           => JSON snapshots of plugins, see Manager.Snapshots
+//
+// Viewing the dashboard is not counted as use of plugins for idle eviction. The handler
+// does not authenticate requests, protect it like other internal endpoints of the service.
+func DashboardHandler(manager *Manager) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		snapshots := manager.Snapshots()
+		renderDashboard(w, dashboardPlugins, map[string]interface{}{
+			"Plugins": snapshots,
+			"Errors":  dashboardErrorsOf(snapshots, dashboardErrors),
+		})
+	})
+	mux.HandleFunc("/plugin", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		managed, plugin, ok := manager.lookup(query.Get("tenant"), query.Get("name"))
+		if !ok {
+			http.Error(w, fmt.Sprintf("plugin %s of tenant %s not found",
+				query.Get("name"), query.Get("tenant")), http.StatusNotFound)
+			return
+		}
+		snapshot := plugin.Snapshot()
+		data := map[string]interface{}{
+			"Plugin":   managed,
+			"Snapshot": snapshot,
+			"Errors":   dashboardErrorsOf([]ManagedSnapshot{{Plugin: managed, Snapshot: snapshot}}, maxRecentErrors),
+		}
+		specs, err := plugin.Describe()
+		if err != nil {
+			data["DescribeError"] = err.Error()
+		}
+		data["Funcs"] = dashboardFuncs(snapshot.Stats, specs)
+		if snapshot.Options.LogFile != "" {
+			log, err := readTail(snapshot.Options.LogFile, dashboardLogSize)
+			if err != nil {
+				log = err.Error()
+			}
+			data["Log"] = log
+		}
+		renderDashboard(w, dashboardPlugin, data)
+	})
+	mux.HandleFunc("/api/plugins", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(manager.Snapshots()); err != nil {
+			logger.Error("write dashboard response failed", "error", err)
+		}
+	})
+	return mux
+}
+
+// dashboardErrorsOf returns recent errors of plugins, the latest limit errors first
+func dashboardErrorsOf(snapshots []ManagedSnapshot, limit int) []dashboardError {
+	var errs []dashboardError
+	for _, snapshot := range snapshots {
+		for _, e := range snapshot.Snapshot.RecentErrors {
+			errs = append(errs, dashboardError{Plugin: snapshot.Plugin, CallError: e})
+		}
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Time.After(errs[j].Time)
+	})
+	if len(errs) > limit {
+		errs = errs[:limit]
+	}
+	return errs
+}
+
+// dashboardFuncs merges functions described by plugin with called functions of stats,
+// called functions come first in order of stats
+func dashboardFuncs(stats PluginStats, specs map[string]fungo.FuncSpec) []dashboardFunc {
+	var funcs []dashboardFunc
+	called := make(map[string]bool)
+	for _, f := range stats.Funcs {
+		called[f.Name] = true
+		item := dashboardFunc{Name: f.Name, Stats: f}
+		if spec, ok := specs[f.Name]; ok {
+			item.Spec = &spec
+		}
+		funcs = append(funcs, item)
+	}
+	var names []string
+	for name := range specs {
+		if !called[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		spec := specs[name]
+		funcs = append(funcs, dashboardFunc{Name: name, Spec: &spec, Stats: FuncStats{Name: name}})
+	}
+	return funcs
+}
+
+// readTail reads the last size bytes of file, starting from a whole line
+func readTail(path string, size int64) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	offset := info.Size() - size
+	if offset < 0 {
+		offset = 0
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+	content := string(data)
+	if offset > 0 {
+		if i := strings.IndexByte(content, '\n'); i >= 0 {
+			content = content[i+1:]
+		}
+	}
+	return content, nil
+}
+
+func renderDashboard(w http.ResponseWriter, page *template.Template, data map[string]interface{}) {
+	data["Refresh"] = dashboardRefresh
+	data["Time"] = time.Now()
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := page.Execute(w, data); err != nil {
+		logger.Error("render dashboard failed", "error", err)
+	}
+}
+
+var dashboardTemplateFuncs = template.FuncMap{
+	"label": func(p ManagedPlugin) string {
+		if p.Tenant == "" {
+			return p.Name
+		}
+		return p.Tenant + "/" + p.Name
+	},
+	"duration": func(d time.Duration) time.Duration {
+		return d.Round(time.Microsecond)
+	},
+	"since": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return time.Since(t).Round(time.Second).String()
+	},
+	"clock": func(t time.Time) string {
+		return t.Format("2006-01-02 15:04:05")
+	},
+	"percent": func(v float64) string {
+		return fmt.Sprintf("%.1f%%", v*100)
+	},
+	"calls": func(stats PluginStats) (calls int64) {
+		for _, f := range stats.Funcs {
+			calls += f.Calls
+		}
+		return
+	},
+	"errors": func(stats PluginStats) (errors int64) {
+		for _, f := range stats.Funcs {
+			errors += f.Errors
+		}
+		return
+	},
+}
+
+const dashboardLayout = `{{define "head"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="{{.Refresh}}">
+<title>funplugin dashboard</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border-bottom: 1px solid #ddd; padding: 4px 12px; text-align: left; }
+td.num { text-align: right; }
+.error { color: #b00; }
+pre { background: #f6f6f6; padding: 1em; overflow: auto; max-height: 40em; }
+</style>
+</head>
+<body>
+{{end}}
+{{define "process"}}{{if .}}pid {{.Pid}}{{if not .Running}} exited{{end}}{{else}}-{{end}}{{end}}
+{{define "errors"}}<table>
+<tr><th>Time</th>{{if .Plugins}}<th>Plugin</th>{{end}}<th>Function</th><th>Error</th></tr>
+{{range .Errors}}<tr><td>{{clock .Time}}</td>{{if $.Plugins}}<td>{{label .Plugin}}</td>{{end}}<td>{{.Function}}</td><td class="error">{{.Error}}</td></tr>
+{{else}}<tr><td colspan="4">no errors</td></tr>
+{{end}}</table>
+{{end}}`
+
+var dashboardPlugins = template.Must(template.New("plugins").Funcs(dashboardTemplateFuncs).Parse(dashboardLayout + `
+{{template "head" .}}
+<h1>Plugins</h1>
+<p>{{len .Plugins}} plugins at {{clock .Time}}, <a href="api/plugins">JSON</a></p>
+<table>
+<tr><th>Plugin</th><th>Type</th><th>Transport</th><th>Process</th><th>Uptime</th><th>Idle</th><th>Calls</th><th>Errors</th></tr>
+{{range .Plugins}}<tr>
+<td><a href="plugin?tenant={{.Plugin.Tenant}}&amp;name={{.Plugin.Name}}">{{label .Plugin}}</a></td>
+<td>{{.Snapshot.Type}}</td><td>{{.Snapshot.Transport}}</td><td>{{template "process" .Snapshot.Process}}</td>
+<td>{{duration .Snapshot.Uptime}}</td><td>{{since .Plugin.LastUsed}}</td>
+<td class="num">{{calls .Snapshot.Stats}}</td><td class="num">{{errors .Snapshot.Stats}}</td>
+</tr>
+{{else}}<tr><td colspan="8">no plugins loaded</td></tr>
+{{end}}</table>
+<h2>Recent errors</h2>
+{{template "errors" .}}
+</body>
+</html>
+`))
+
+var dashboardPlugin = template.Must(template.New("plugin").Funcs(dashboardTemplateFuncs).Parse(dashboardLayout + `
+{{template "head" .}}
+<p><a href="./">Plugins</a></p>
+<h1>{{label .Plugin}}</h1>
+<table>
+<tr><th>Path</th><td>{{.Snapshot.Path}}</td></tr>
+<tr><th>Type</th><td>{{.Snapshot.Type}}</td></tr>
+<tr><th>Transport</th><td>{{.Snapshot.Transport}}</td></tr>
+<tr><th>Process</th><td>{{template "process" .Snapshot.Process}}</td></tr>
+<tr><th>Loaded</th><td>{{clock .Plugin.Loaded}}</td></tr>
+<tr><th>Uptime</th><td>{{duration .Snapshot.Uptime}}</td></tr>
+{{with .Snapshot.Queue}}<tr><th>Queue</th><td>{{.Running}} running, {{.Queued}} queued, {{.Rejected}} rejected, {{.TimedOut}} timed out</td></tr>{{end}}
+</table>
+<h2>Functions</h2>
+{{with .DescribeError}}<p class="error">{{.}}</p>{{end}}
+<table>
+<tr><th>Function</th><th>Signature</th><th>Calls</th><th>Errors</th><th>Error rate</th><th>P50</th><th>P95</th><th>Total</th></tr>
+{{range .Funcs}}<tr>
+<td>{{.Name}}</td>
+<td>{{with .Spec}}{{if .Params}}({{range $i, $p := .Params}}{{if $i}}, {{end}}{{$p.Name}}{{with $p.Type}} {{.}}{{end}}{{end}}){{end}}{{with .Returns}} {{.}}{{end}}{{end}}</td>
+<td class="num">{{.Stats.Calls}}</td><td class="num">{{.Stats.Errors}}</td><td class="num">{{percent .Stats.ErrorRate}}</td>
+<td class="num">{{duration .Stats.P50}}</td><td class="num">{{duration .Stats.P95}}</td><td class="num">{{duration .Stats.Total}}</td>
+</tr>
+{{else}}<tr><td colspan="8">no functions</td></tr>
+{{end}}</table>
+<h2>Recent errors</h2>
+{{template "errors" .}}
+{{with .Log}}<h2>Log</h2>
+<pre>{{.}}</pre>
+{{end}}
+</body>
+</html>
+`))
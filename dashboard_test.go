@@ -0,0 +1,83 @@
+package funplugin
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDashboardHandler(t *testing.T) {
+	m := NewManager()
+	defer m.Shutdown()
+	plugin, err := m.Load("project-a", "lua", "lua/examples/debugtalk.lua")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	_, err = plugin.Call("sum_ints", 1, 2)
+	assert.Nil(t, err)
+	_, err = plugin.Call("sum_ints", "a")
+	assert.NotNil(t, err)
+	lastUsed := m.List("project-a")[0].LastUsed
+
+	server := httptest.NewServer(http.StripPrefix("/plugins", DashboardHandler(m)))
+	defer server.Close()
+	get := func(path string) (int, string) {
+		resp, err := http.Get(server.URL + path)
+		if !assert.Nil(t, err) {
+			return 0, ""
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	code, body := get("/plugins/")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `<a href="plugin?tenant=project-a&amp;name=lua">project-a/lua</a>`)
+	assert.Contains(t, body, "<td>lua-plugin</td>")
+	assert.Contains(t, body, `<td>sum_ints</td><td class="error">`)
+
+	code, body = get("/plugins/plugin?tenant=project-a&name=lua")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "<h1>project-a/lua</h1>")
+	// called functions come first, functions not called yet are listed too
+	assert.Less(t, strings.Index(body, "<td>sum_ints</td>"), strings.Index(body, "<td>concatenate</td>"))
+	assert.Contains(t, body, `<td class="num">2</td><td class="num">1</td><td class="num">50.0%</td>`)
+
+	code, _ = get("/plugins/plugin?tenant=project-b&name=lua")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = get("/plugins/unknown")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, body = get("/plugins/api/plugins")
+	assert.Equal(t, http.StatusOK, code)
+	var snapshots []ManagedSnapshot
+	assert.Nil(t, json.Unmarshal([]byte(body), &snapshots))
+	if assert.Len(t, snapshots, 1) {
+		assert.Equal(t, "lua", snapshots[0].Plugin.Name)
+		assert.Len(t, snapshots[0].Snapshot.RecentErrors, 1)
+	}
+
+	// viewing dashboard is not counted as use of plugins
+	assert.Equal(t, lastUsed, m.List("project-a")[0].LastUsed)
+}
+
+func TestReadTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plugin.log")
+	assert.Nil(t, os.WriteFile(path, []byte("first line\nsecond line\nthird line\n"), 0o644))
+	content, err := readTail(path, 1024)
+	assert.Nil(t, err)
+	assert.Equal(t, "first line\nsecond line\nthird line\n", content)
+	content, err = readTail(path, 15)
+	assert.Nil(t, err)
+	assert.Equal(t, "third line\n", content)
+	_, err = readTail(filepath.Join(t.TempDir(), "missing.log"), 1024)
+	assert.NotNil(t, err)
+}
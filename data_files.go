@@ -0,0 +1,105 @@
+package funplugin
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// containerDataDir is the data directory inside container
+const containerDataDir = "/tmp/funplugin-data"
+
+// validateDataFiles checks names of data files are relative slash separated paths in data directory
+func validateDataFiles(files map[string]string) error {
+	for _, name := range sortedDataFiles(files) {
+		clean := path.Clean(name)
+		if name == "" || strings.Contains(name, `\`) || path.IsAbs(clean) ||
+			clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+			return fmt.Errorf("invalid data file name %s, it should be a relative slash separated path", name)
+		}
+	}
+	return nil
+}
+
+// sortedDataFiles returns names of data files in order
+func sortedDataFiles(files map[string]string) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// stageDataFiles copies data files to a new local temp directory, which is the data
+// directory of local plugin process, or transferred to remote machine and container
+func stageDataFiles(files map[string]string) (string, error) {
+	dir, err := os.MkdirTemp("", "funplugin-data-")
+	if err != nil {
+		return "", errors.Wrap(err, "create data directory failed")
+	}
+	for _, name := range sortedDataFiles(files) {
+		dst := filepath.Join(dir, filepath.FromSlash(name))
+		if err := copyFile(files[name], dst); err != nil {
+			os.RemoveAll(dir)
+			return "", errors.Wrapf(err, "copy data file %s failed", name)
+		}
+	}
+	logger.Info("stage plugin data files", "dir", dir, "count", len(files))
+	return dir, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// prepareLocalDataDir stages data files for local plugin process, the data
+// directory is removed when plugin quits
+func (o *pluginOption) prepareLocalDataDir() error {
+	if len(o.dataFiles) == 0 {
+		return nil
+	}
+	dir, err := stageDataFiles(o.dataFiles)
+	if err != nil {
+		return err
+	}
+	o.dataDir, o.localDataDir = dir, dir
+	return nil
+}
+
+// removeLocalDataDir removes data directory of local plugin process
+func (o *pluginOption) removeLocalDataDir() {
+	if o.localDataDir == "" {
+		return
+	}
+	if err := os.RemoveAll(o.localDataDir); err != nil {
+		logger.Error("remove plugin data directory failed", "dir", o.localDataDir, "error", err)
+	}
+	o.localDataDir = ""
+}
@@ -0,0 +1,82 @@
+package funplugin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeDataFiles writes data files declared with WithDataFiles in a temp directory
+func writeDataFiles(t *testing.T) map[string]string {
+	dir := t.TempDir()
+	users := filepath.Join(dir, "users.csv")
+	assert.Nil(t, os.WriteFile(users, []byte("id,name\n1,alice\n"), 0o644))
+	return map[string]string{"fixtures/users.csv": users}
+}
+
+func TestDataFilesGoPlugin(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	for _, transport := range []string{"", "stdio"} {
+		plugin, err := Init(pluginBinPath, WithTransport(transport), WithDataFiles(writeDataFiles(t)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := plugin.Call("read_data_file", "fixtures/users.csv")
+		assert.Nil(t, err)
+		assert.Equal(t, "id,name\n1,alice\n", content)
+		assert.Equal(t, []string{"fixtures/users.csv"}, plugin.Snapshot().Options.DataFiles)
+
+		// local data directory is removed after plugin quits
+		dataDir := plugin.(*interceptedPlugin).option.localDataDir
+		assert.DirExists(t, dataDir)
+		plugin.Quit()
+		assert.NoDirExists(t, dataDir)
+	}
+}
+
+func TestValidateDataFiles(t *testing.T) {
+	assert.Nil(t, validateDataFiles(map[string]string{"users.csv": "a", "fixtures/b/c.json": "b"}))
+	for _, name := range []string{"", ".", "..", "../users.csv", "/etc/passwd", `fixtures\users.csv`, "a/../../b"} {
+		assert.EqualError(t, validateDataFiles(map[string]string{name: "a"}),
+			"invalid data file name "+name+", it should be a relative slash separated path")
+	}
+}
+
+func TestStageDataFiles(t *testing.T) {
+	files := writeDataFiles(t)
+	files["users.csv"] = files["fixtures/users.csv"]
+	dir, err := stageDataFiles(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"users.csv", "fixtures/users.csv"} {
+		content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		assert.Nil(t, err)
+		assert.Equal(t, "id,name\n1,alice\n", string(content))
+	}
+
+	_, err = stageDataFiles(map[string]string{"missing.csv": filepath.Join(t.TempDir(), "missing.csv")})
+	assert.Error(t, err)
+}
+
+func TestDockerCreateArgs(t *testing.T) {
+	option := &pluginOption{
+		langType:    langTypePython,
+		dockerImage: "debugtalk:latest",
+		dataDir:     containerDataDir,
+	}
+	args := dockerCreateArgs(dockerRunArgs("/app/debugtalk.py", option, 12345))
+	assert.Equal(t, []string{
+		"create", "--rm",
+		"--publish", "127.0.0.1:12345:50051",
+		"--env", "HRP_PLUGIN_SIDECAR_ADDR=0.0.0.0:50051",
+		"--env", hostInfoEnv(),
+		"--env", "HRP_PLUGIN_DATA_DIR=/tmp/funplugin-data",
+		"debugtalk:latest", "python3", "/app/debugtalk.py",
+	}, args)
+}
@@ -0,0 +1,224 @@
+package funplugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-plugin"
+	"github.com/pkg/errors"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+// ServerState records a running plugin server, it is written to state file
+// in detached mode and returned by plugin daemon, hosts reattach the server with it
+type ServerState struct {
+	Path     string `json:"path"`     // absolute plugin file path
+	Pid      int    `json:"pid"`      // plugin server process id
+	Network  string `json:"network"`  // unix or tcp
+	Addr     string `json:"addr"`     // unix socket path or tcp address
+	Protocol string `json:"protocol"` // grpc or netrpc
+}
+
+func (s *ServerState) reattachConfig() (*plugin.ReattachConfig, error) {
+	var addr net.Addr
+	var err error
+	switch s.Network {
+	case "unix":
+		addr, err = net.ResolveUnixAddr("unix", s.Addr)
+	case "tcp":
+		addr, err = net.ResolveTCPAddr("tcp", s.Addr)
+	default:
+		err = fmt.Errorf("unsupported network: %s", s.Network)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "resolve detached plugin address failed")
+	}
+
+	return &plugin.ReattachConfig{
+		Protocol:        plugin.Protocol(s.Protocol),
+		ProtocolVersion: int(fungo.HandshakeConfig.ProtocolVersion),
+		Addr:            addr,
+		Pid:             s.Pid,
+		// test mode avoids killing the detached plugin server on Quit
+		Test: true,
+	}, nil
+}
+
+func loadDetachedState(stateFile string) (*ServerState, error) {
+	content, err := os.ReadFile(stateFile)
+	if err != nil {
+		return nil, err
+	}
+	state := &ServerState{}
+	if err := json.Unmarshal(content, state); err != nil {
+		return nil, errors.Wrap(err, "parse detached state file failed")
+	}
+	return state, nil
+}
+
+// newDetachedPlugin reattaches plugin server recorded in state file,
+// or launches a new detached plugin server which keeps running after host exits.
+func newDetachedPlugin(path string, option *pluginOption) (*hashicorpPlugin, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "get plugin absolute path failed")
+	}
+	logger := logger.ResetNamed(fmt.Sprintf("detached-%v", option.langType))
+
+	state, err := loadDetachedState(option.detachedState)
+	if err == nil && state.Path == path {
+		p, err := attachDetachedPlugin(path, option, state)
+		if err == nil {
+			logger.Info("reattach detached plugin success", "path", path, "pid", state.Pid)
+			return p, nil
+		}
+		logger.Warn("reattach detached plugin failed, launch a new one", "error", err)
+	}
+
+	state, _, err = launchDetachedPlugin(path, option,
+		rpcType(os.Getenv(fungo.PluginTypeEnvName)), option.detachedState+".log")
+	if err != nil {
+		return nil, err
+	}
+	content, _ := json.Marshal(state)
+	if err := os.WriteFile(option.detachedState, content, 0o600); err != nil {
+		return nil, errors.Wrap(err, "write detached state file failed")
+	}
+
+	p, err := attachDetachedPlugin(path, option, state)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("launch detached plugin success", "path", path, "pid", state.Pid)
+	return p, nil
+}
+
+func attachDetachedPlugin(path string, option *pluginOption, state *ServerState) (*hashicorpPlugin, error) {
+	reattach, err := state.reattachConfig()
+	if err != nil {
+		return nil, err
+	}
+	p := &hashicorpPlugin{
+		path:     path,
+		option:   option,
+		reattach: reattach,
+	}
+	if err := p.startPlugin(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// launchDetachedPlugin starts plugin server in a new session with its output
+// redirected to log file, and waits for the hashicorp handshake line.
+// The returned channel is closed when plugin server exited.
+func launchDetachedPlugin(path string, option *pluginOption, pluginRPCType rpcType, logPath string) (*ServerState, <-chan struct{}, error) {
+	cmd := option.command(path)
+	if pluginRPCType != rpcTypeRPC || option.langType == langTypePython {
+		// hashicorp python plugin only supports gRPC
+		pluginRPCType = rpcTypeGRPC
+	}
+	cmd.Env = append(option.environ(),
+		fmt.Sprintf("%s=%s", fungo.HandshakeConfig.MagicCookieKey, fungo.HandshakeConfig.MagicCookieValue),
+		fmt.Sprintf("%s=%s", fungo.PluginTypeEnvName, pluginRPCType),
+	)
+	cmd.SysProcAttr = detachedSysProcAttr()
+	cleanup, err := option.passHostData(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cleanup()
+
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "create detached plugin log file failed")
+	}
+	defer logFile.Close()
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+
+	if err := cmd.Start(); err != nil {
+		return nil, nil, errors.Wrap(err, "start detached plugin failed")
+	}
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(option.getStartTimeout())
+	for {
+		select {
+		case <-exited:
+			if option.langType == langTypePython {
+				if output, err := os.ReadFile(logPath); err == nil {
+					if startupErr := parsePythonStartupError(path, output); startupErr != nil {
+						return nil, nil, startupErr
+					}
+				}
+			}
+			return nil, nil, fmt.Errorf("detached plugin exited, see %s", logPath)
+		case <-timeout:
+			cmd.Process.Kill()
+			return nil, nil, fmt.Errorf("wait detached plugin handshake: %w after %v, see %s",
+				ErrStartTimeout, option.getStartTimeout(), logPath)
+		case <-ticker.C:
+		}
+
+		state, err := readHandshake(logPath)
+		if err != nil {
+			continue
+		}
+		state.Path = path
+		state.Pid = cmd.Process.Pid
+		return state, exited, nil
+	}
+}
+
+// readHandshake parses hashicorp plugin handshake line from plugin output,
+// e.g. 1|1|unix|/tmp/plugin123|grpc
+func readHandshake(logPath string) (*ServerState, error) {
+	file, err := os.Open(logPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		parts := strings.Split(strings.TrimSpace(scanner.Text()), "|")
+		if len(parts) < 5 || parts[0] != fmt.Sprint(plugin.CoreProtocolVersion) {
+			continue
+		}
+		return &ServerState{
+			Network:  parts[2],
+			Addr:     parts[3],
+			Protocol: parts[4],
+		}, nil
+	}
+	return nil, errors.New("handshake not found")
+}
+
+// StopDetached kills detached plugin server recorded in state file
+func StopDetached(stateFile string) error {
+	state, err := loadDetachedState(stateFile)
+	if err != nil {
+		return errors.Wrap(err, "load detached state file failed")
+	}
+	if process, err := os.FindProcess(state.Pid); err == nil {
+		process.Kill()
+	}
+	if state.Network == "unix" {
+		os.Remove(state.Addr)
+	}
+	return os.Remove(stateFile)
+}
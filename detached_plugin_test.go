@@ -0,0 +1,45 @@
+package funplugin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetachedPlugin(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	stateFile := filepath.Join(t.TempDir(), "debugtalk.state")
+	plugin, err := Init(pluginBinPath, WithDetached(stateFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPlugin(t, plugin)
+	state, err := loadDetachedState(stateFile)
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+	defer StopDetached(stateFile)
+
+	// quit does not stop detached plugin server
+	assert.NoError(t, plugin.Quit())
+	Cleanup()
+
+	plugin, err = Init(pluginBinPath, WithDetached(stateFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+	assertPlugin(t, plugin)
+
+	reattached, err := loadDetachedState(stateFile)
+	assert.NoError(t, err)
+	assert.Equal(t, state.Pid, reattached.Pid)
+
+	assert.NoError(t, StopDetached(stateFile))
+	_, err = os.Stat(stateFile)
+	assert.True(t, os.IsNotExist(err))
+}
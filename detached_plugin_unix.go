@@ -0,0 +1,11 @@
+//go:build !windows
+
+package funplugin
+
+import "syscall"
+
+// detachedSysProcAttr starts plugin server in a new session,
+// thus it won't receive signals sent to the host process group
+func detachedSysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
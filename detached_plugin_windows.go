@@ -0,0 +1,15 @@
+//go:build windows
+
+package funplugin
+
+import "syscall"
+
+const detachedProcess = 0x00000008 // DETACHED_PROCESS
+
+// detachedSysProcAttr starts plugin server without console in a new process group,
+// thus it won't receive console control events sent to the host
+func detachedSysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | detachedProcess,
+	}
+}
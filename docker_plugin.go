@@ -0,0 +1,208 @@
+package funplugin
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+// containerPort is the plugin gRPC port inside container
+const containerPort = 50051
+
+// dockerPlugin runs .bin/.py plugin inside a docker container in sidecar mode,
+// the container gRPC port is published to a random localhost port
+type dockerPlugin struct {
+	*remotePlugin
+	containerID string
+	path        string // plugin file path inside container
+	option      *pluginOption
+}
+
+// dockerRunArgs returns docker run arguments, nothing is mounted by default
+func dockerRunArgs(path string, option *pluginOption, hostPort int) []string {
+	args := []string{
+		"run", "--rm", "--detach",
+		"--publish", fmt.Sprintf("127.0.0.1:%d:%d", hostPort, containerPort),
+		"--env", fmt.Sprintf("%s=0.0.0.0:%d", fungo.SidecarAddrEnvName, containerPort),
+		"--env", hostInfoEnv(),
+	}
+	for _, kv := range option.extraEnv() {
+		args = append(args, "--env", kv)
+	}
+	args = append(args, option.dockerArgs...)
+	args = append(args, option.dockerImage)
+	if option.langType == langTypePython {
+		args = append(args, "python3", path)
+	} else {
+		args = append(args, path)
+	}
+	return args
+}
+
+func newDockerPlugin(path string, option *pluginOption) (*dockerPlugin, error) {
+	switch filepath.Ext(path) {
+	case ".bin":
+		option.langType = langTypeGo
+	case ".py":
+		option.langType = langTypePython
+	default:
+		return nil, fmt.Errorf("docker plugin only supports .bin and .py, got %s", path)
+	}
+	logger := logger.ResetNamed(fmt.Sprintf("docker-%v", option.langType))
+
+	// plugin path is the path inside container image
+	hostPort, err := freeLocalPort()
+	if err != nil {
+		return nil, errors.Wrap(err, "get free port failed")
+	}
+	dirs, err := stageContainerDirs(option)
+	if err != nil {
+		return nil, err
+	}
+	for _, localDir := range dirs {
+		defer os.RemoveAll(localDir)
+	}
+	args := dockerRunArgs(filepath.ToSlash(path), option, hostPort)
+	logger.Info("start plugin container", "image", option.dockerImage, "args", args)
+	var containerID string
+	if len(dirs) > 0 {
+		containerID, err = startContainerWithDirs(args, dirs)
+	} else {
+		containerID, err = runDocker(args...)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "start plugin container failed")
+	}
+	trackProcess(containerID, func() { removeContainer(containerID) })
+
+	remote, err := connectPlugin(fmt.Sprintf("127.0.0.1:%d", hostPort), option.jsonNumber)
+	if err != nil {
+		removeContainer(containerID)
+		untrackProcess(containerID)
+		return nil, err
+	}
+	logger.Info("start plugin container success", "container", containerID)
+	return &dockerPlugin{
+		remotePlugin: remote,
+		containerID:  containerID,
+		path:         path,
+		option:       option,
+	}, nil
+}
+
+func (p *dockerPlugin) Type() string {
+	return fmt.Sprintf("docker-grpc-%v", p.option.langType)
+}
+
+func (p *dockerPlugin) Path() string {
+	return p.path
+}
+
+func (p *dockerPlugin) Quit() error {
+	logger.Info("remove plugin container", "container", p.containerID)
+	removeContainer(p.containerID)
+	untrackProcess(p.containerID)
+	return p.remotePlugin.Quit()
+}
+
+// downloadArtifacts copies artifact directory in container to local path
+func (p *dockerPlugin) downloadArtifacts(localPath string) error {
+	_, err := runDocker("cp", p.containerID+":"+containerArtifactDir, localPath)
+	return err
+}
+
+// runDocker runs docker command and returns its trimmed output
+func runDocker(args ...string) (string, error) {
+	output, err := exec.Command("docker", args...).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			err = fmt.Errorf("%v: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// dockerCreateArgs converts docker run arguments to docker create arguments
+func dockerCreateArgs(runArgs []string) []string {
+	args := []string{"create"}
+	for _, arg := range runArgs[1:] {
+		if arg != "--detach" {
+			args = append(args, arg)
+		}
+	}
+	return args
+}
+
+// stageContainerDirs stages local directories copied into container before it starts, keyed by
+// container directory: data files, and empty artifact directory writable by plugin process
+func stageContainerDirs(option *pluginOption) (map[string]string, error) {
+	dirs := make(map[string]string)
+	if len(option.dataFiles) > 0 {
+		localDir, err := stageDataFiles(option.dataFiles)
+		if err != nil {
+			return nil, err
+		}
+		dirs[containerDataDir] = localDir
+		option.dataDir = containerDataDir
+	}
+	if option.artifactsDir != "" {
+		localDir, err := os.MkdirTemp("", "funplugin-artifacts-")
+		if err != nil {
+			for _, dir := range dirs {
+				os.RemoveAll(dir)
+			}
+			return nil, errors.Wrap(err, "create artifact directory failed")
+		}
+		dirs[containerArtifactDir] = localDir
+		option.artifactDir = containerArtifactDir
+	}
+	return dirs, nil
+}
+
+// startContainerWithDirs creates plugin container, copies local directories into it and starts it,
+// docker cp works with remote docker daemons which can't mount host directories
+func startContainerWithDirs(runArgs []string, dirs map[string]string) (string, error) {
+	containerID, err := runDocker(dockerCreateArgs(runArgs)...)
+	if err != nil {
+		return "", err
+	}
+	trackProcess(containerID, func() { removeContainer(containerID) })
+	for containerDir, localDir := range dirs {
+		logger.Info("copy directory to container", "container", containerID, "dir", containerDir)
+		if _, err := runDocker("cp", localDir+string(filepath.Separator)+".",
+			containerID+":"+containerDir); err != nil {
+			removeContainer(containerID)
+			untrackProcess(containerID)
+			return "", errors.Wrapf(err, "copy directory %s to container failed", containerDir)
+		}
+	}
+	if _, err := runDocker("start", containerID); err != nil {
+		removeContainer(containerID)
+		untrackProcess(containerID)
+		return "", err
+	}
+	return containerID, nil
+}
+
+func removeContainer(containerID string) {
+	if err := exec.Command("docker", "rm", "--force", containerID).Run(); err != nil {
+		logger.Error("remove plugin container failed", "container", containerID, "error", err)
+	}
+}
+
+func freeLocalPort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,29 @@
+package funplugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDockerRunArgs(t *testing.T) {
+	option := &pluginOption{
+		langType:    langTypePython,
+		dockerImage: "debugtalk:latest",
+		dockerArgs:  []string{"--network", "none"},
+	}
+	args := dockerRunArgs("/app/debugtalk.py", option, 12345)
+	assert.Equal(t, []string{
+		"run", "--rm", "--detach",
+		"--publish", "127.0.0.1:12345:50051",
+		"--env", "HRP_PLUGIN_SIDECAR_ADDR=0.0.0.0:50051",
+		"--env", hostInfoEnv(),
+		"--network", "none",
+		"debugtalk:latest", "python3", "/app/debugtalk.py",
+	}, args)
+}
+
+func TestDockerPluginUnsupported(t *testing.T) {
+	_, err := Init("debugtalk.lua", WithDockerImage("debugtalk:latest"))
+	assert.EqualError(t, err, "docker plugin only supports .bin and .py, got debugtalk.lua")
+}
@@ -0,0 +1,179 @@
+package funplugin
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+// durableMaxAttempts bounds starts of a durable call, calls failing in every attempt,
+// e.g. crashing plugin, are dropped instead of being replayed forever
+const durableMaxAttempts = 5
+
+// durableCall is a call started by Submit persisted in durable queue until it completes
+type durableCall struct {
+	ID        string        `json:"id"`
+	Func      string        `json:"func"`
+	Args      []interface{} `json:"args"`
+	Submitted time.Time     `json:"submitted"`
+	Attempts  int           `json:"attempts"` // starts of the call, including replays
+}
+
+// durableQueue persists durable calls as files in a directory, one file per call
+type durableQueue struct {
+	dir string
+}
+
+func openDurableQueue(dir string) (*durableQueue, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errors.Wrap(err, "create durable queue directory failed")
+	}
+	return &durableQueue{dir: dir}, nil
+}
+
+func (q *durableQueue) path(id string) string {
+	return filepath.Join(q.dir, id+".json")
+}
+
+// save writes call to disk before it returns, replacing file of the call atomically
+func (q *durableQueue) save(call *durableCall) error {
+	data, err := json.Marshal(call)
+	if err != nil {
+		return errors.Wrap(err, "marshal durable call failed")
+	}
+	tmp := q.path(call.ID) + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return errors.Wrap(err, "persist durable call failed")
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, q.path(call.ID))
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return errors.Wrap(err, "persist durable call failed")
+	}
+	return nil
+}
+
+func (q *durableQueue) remove(id string) error {
+	if err := os.Remove(q.path(id)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "remove durable call failed")
+	}
+	return nil
+}
+
+// pending returns calls left in queue sorted by submission time
+func (q *durableQueue) pending() ([]*durableCall, error) {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "read durable queue failed")
+	}
+	var calls []*durableCall
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(q.dir, entry.Name()))
+		if err != nil {
+			return nil, errors.Wrap(err, "read durable call failed")
+		}
+		call := &durableCall{}
+		if err := json.Unmarshal(data, call); err != nil {
+			logger.Warn("ignore malformed durable call", "file", entry.Name(), "error", err)
+			continue
+		}
+		calls = append(calls, call)
+	}
+	sort.SliceStable(calls, func(i, j int) bool {
+		return calls[i].Submitted.Before(calls[j].Submitted)
+	})
+	return calls, nil
+}
+
+// durableDone reports whether durable call with err needs no replay, i.e. it returned
+// result or error raised by plugin function, or it is canceled
+func durableDone(err error) bool {
+	var panicErr *fungo.PanicError
+	return err == nil || fungo.IsUserError(err) || errors.As(err, &panicErr) ||
+		errors.Is(err, ErrCallCanceled)
+}
+
+// submitDurable persists call in durable queue and starts it, the call fails
+// if it can not be persisted
+func (p *interceptedPlugin) submitDurable(funcName string, args ...interface{}) *Future {
+	id := make([]byte, 8)
+	rand.Read(id)
+	call := &durableCall{
+		ID:        "call-" + hex.EncodeToString(id),
+		Func:      funcName,
+		Args:      args,
+		Submitted: time.Now(),
+		Attempts:  1,
+	}
+	if err := p.durable.save(call); err != nil {
+		return failedCall(call.ID, funcName, err)
+	}
+	return p.runCall(call.ID, call, funcName, args...)
+}
+
+// completeDurable removes durable call once it completes, calls interrupted by crashed
+// or unreachable plugin are kept for replay by the next Init
+func (p *interceptedPlugin) completeDurable(call *durableCall, err error) {
+	if !durableDone(err) {
+		logger.Warn("plugin call kept in durable queue for replay",
+			"callID", call.ID, "funcName", call.Func, "error", err)
+		return
+	}
+	if err := p.durable.remove(call.ID); err != nil {
+		logger.Error("remove plugin call from durable queue failed", "callID", call.ID, "error", err)
+	}
+}
+
+// replayDurable opens durable queue in dir and resubmits calls left in it by previous
+// runs with their call ids, e.g. host or plugin crashed while running them
+func (p *interceptedPlugin) replayDurable(dir string) error {
+	queue, err := openDurableQueue(dir)
+	if err != nil {
+		return err
+	}
+	p.durable = queue
+	calls, err := queue.pending()
+	if err != nil {
+		return err
+	}
+	for _, call := range calls {
+		if call.Attempts >= durableMaxAttempts || !p.Has(call.Func) {
+			logger.Error("drop plugin call from durable queue",
+				"callID", call.ID, "funcName", call.Func, "attempts", call.Attempts)
+			if err := queue.remove(call.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		call.Attempts++
+		if err := queue.save(call); err != nil {
+			return err
+		}
+		logger.Info("replay plugin call from durable queue",
+			"callID", call.ID, "funcName", call.Func, "attempts", call.Attempts)
+		p.track(p.runCall(call.ID, call, call.Func, call.Args...))
+	}
+	return nil
+}
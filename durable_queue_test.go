@@ -0,0 +1,62 @@
+package funplugin
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+func TestDurableQueue(t *testing.T) {
+	dir := t.TempDir()
+	queue, err := openDurableQueue(dir)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	// calls left by crashed host
+	submitted := time.Now().Add(-time.Minute)
+	for _, call := range []*durableCall{
+		{ID: "call-crashed", Func: "sum_ints", Args: []interface{}{1, 2, 3}, Submitted: submitted, Attempts: 1},
+		{ID: "call-poison", Func: "sum_ints", Args: []interface{}{1}, Submitted: submitted, Attempts: durableMaxAttempts},
+		{ID: "call-missing", Func: "missing", Submitted: submitted, Attempts: 1},
+	} {
+		assert.Nil(t, queue.save(call))
+	}
+
+	plugin, err := Init("lua/examples/debugtalk.lua", WithDurableQueue(dir))
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	defer plugin.Quit()
+	assert.Equal(t, []string{"call-crashed"}, plugin.SubmittedCalls())
+	result, err := plugin.Wait("call-crashed")
+	assert.Nil(t, err)
+	assert.EqualValues(t, 6, result)
+
+	callID := plugin.Submit("sum_ints", 1, 2)
+	result, err = plugin.Wait(callID)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 3, result)
+	assert.Empty(t, plugin.SubmittedCalls())
+
+	// completed and dropped calls are removed
+	assert.Eventually(t, func() bool {
+		files, _ := filepath.Glob(filepath.Join(dir, "*"))
+		return len(files) == 0
+	}, time.Second, 10*time.Millisecond)
+	_, err = os.Stat(queue.path(callID))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDurableDone(t *testing.T) {
+	assert.True(t, durableDone(nil))
+	assert.True(t, durableDone(fungo.NewUserError("invalid input")))
+	assert.True(t, durableDone(ErrCallCanceled))
+	// plugin crashed or unreachable
+	assert.False(t, durableDone(errors.New("connection refused")))
+}
@@ -0,0 +1,26 @@
+package fungo
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// ArtifactDirEnvName is the artifact directory on the machine or in the container running plugin,
+// which files written to are collected to host specified by funplugin.WithArtifacts
+const ArtifactDirEnvName = "HRP_PLUGIN_ARTIFACT_DIR"
+
+// ArtifactDir returns artifact directory managed by host, it is empty if host
+// does not collect artifacts
+func ArtifactDir() string {
+	return os.Getenv(ArtifactDirEnvName)
+}
+
+// CreateArtifact creates file with slash separated name in artifact directory,
+// e.g. "screenshots/login.png", parent directories are created as needed
+func CreateArtifact(name string) (*os.File, error) {
+	path := filepath.Join(ArtifactDir(), filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	return os.Create(path)
+}
@@ -0,0 +1,62 @@
+package fungo
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+const (
+	// PluginConfigFDEnvName is the inherited file descriptor number which host
+	// writes plugin config passed with funplugin.WithPluginConfig to
+	PluginConfigFDEnvName = "HRP_PLUGIN_CONFIG_FD"
+	// PluginConfigPipeEnvName is the windows named pipe path which host writes plugin config to
+	PluginConfigPipeEnvName = "HRP_PLUGIN_CONFIG_PIPE"
+)
+
+var (
+	configOnce   sync.Once
+	pluginConfig map[string]interface{}
+)
+
+// Config returns plugin config passed by host with funplugin.WithPluginConfig,
+// it is empty if host passes no config. The returned map must not be modified.
+// Numbers are decoded according to WithJSONNumber mode of host.
+func Config() map[string]interface{} {
+	loadConfig()
+	return pluginConfig
+}
+
+// loadConfig reads plugin config written by host once
+func loadConfig() {
+	configOnce.Do(func() {
+		pluginConfig = make(map[string]interface{})
+		r, err := openHostPipe(PluginConfigFDEnvName, PluginConfigPipeEnvName, "config")
+		if err != nil {
+			logger.Error("open plugin config pipe failed", "error", err)
+			return
+		}
+		if r == nil {
+			return
+		}
+		defer r.Close()
+
+		content, err := io.ReadAll(r)
+		if err != nil {
+			logger.Error("read plugin config failed", "error", err)
+			return
+		}
+		var config interface{}
+		if err := unmarshalJSON(content, &config, pluginNumberMode()); err != nil {
+			logger.Error("decode plugin config failed", "error", err)
+			return
+		}
+		m, ok := config.(map[string]interface{})
+		if !ok {
+			logger.Error("plugin config is not an object", "type", fmt.Sprintf("%T", config))
+			return
+		}
+		pluginConfig = m
+		logger.Info("load plugin config from host", "count", len(pluginConfig))
+	})
+}
@@ -0,0 +1,22 @@
+package fungo
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// DataDirEnvName is the directory on the machine or in the container running plugin,
+// which data files declared by funplugin.WithDataFiles are transferred to
+const DataDirEnvName = "HRP_PLUGIN_DATA_DIR"
+
+// DataDir returns directory of data files transferred by host, it is empty
+// if host declares no data files
+func DataDir() string {
+	return os.Getenv(DataDirEnvName)
+}
+
+// DataFile returns path of data file transferred by host with slash separated name
+// declared in funplugin.WithDataFiles, e.g. "fixtures/users.csv"
+func DataFile(name string) string {
+	return filepath.Join(DataDir(), filepath.FromSlash(name))
+}
@@ -0,0 +1,70 @@
+package fungo
+
+import (
+	stdjson "encoding/json"
+	"reflect"
+)
+
+// DescribeFuncName is reserved function name host calls to get signatures of plugin
+// functions, it is served by fungo and funppy and not listed by GetNames
+const DescribeFuncName = "__funplugin_describe__"
+
+// kinds of FuncParam, the same as names of python inspect.Parameter kinds
+const (
+	ParamPositionalOnly      = "positional_only"
+	ParamPositionalOrKeyword = "positional_or_keyword"
+	ParamVarPositional       = "var_positional"
+	ParamKeywordOnly         = "keyword_only"
+	ParamVarKeyword          = "var_keyword"
+)
+
+// FuncParam is parameter of plugin function, parameters of go functions have no names
+type FuncParam struct {
+	Name    string             `json:"name,omitempty"`
+	Kind    string             `json:"kind"`              // e.g. ParamPositionalOnly
+	Type    string             `json:"type,omitempty"`    // go type or python type hint, empty if unknown
+	Default stdjson.RawMessage `json:"default,omitempty"` // default value of python parameter, null is kept
+}
+
+// FuncSpec is signature of plugin function, in the same structure as funppy.describe()
+type FuncSpec struct {
+	Doc     string      `json:"doc,omitempty"`
+	Params  []FuncParam `json:"params"`            // nil if plugin does not report signatures
+	Returns string      `json:"returns,omitempty"` // result type, empty if unknown or none
+}
+
+// Describe returns signatures of registered plugin functions keyed by function name,
+// context.Context parameter and error result are omitted since they are not transferred
+func Describe() map[string]FuncSpec {
+	p := &functionPlugin{logger: logger, functions: functions}
+	return p.describe()
+}
+
+func (p *functionPlugin) describe() map[string]FuncSpec {
+	specs := make(map[string]FuncSpec)
+	for _, name := range p.registeredNames() {
+		if fn := p.functions[name]; fn.IsValid() && fn.Kind() == reflect.Func {
+			specs[name] = funcSpec(fn.Type())
+		}
+	}
+	return specs
+}
+
+func funcSpec(fnType reflect.Type) FuncSpec {
+	spec := FuncSpec{Params: []FuncParam{}}
+	for i := 0; i < fnType.NumIn(); i++ {
+		in := fnType.In(i)
+		if i == 0 && in == contextType {
+			continue
+		}
+		param := FuncParam{Kind: ParamPositionalOnly, Type: in.String()}
+		if fnType.IsVariadic() && i == fnType.NumIn()-1 {
+			param.Kind, param.Type = ParamVarPositional, in.Elem().String()
+		}
+		spec.Params = append(spec.Params, param)
+	}
+	if fnType.NumOut() > 0 && fnType.Out(0) != errorType {
+		spec.Returns = fnType.Out(0).String()
+	}
+	return spec
+}
@@ -0,0 +1,33 @@
+package fungo
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribe(t *testing.T) {
+	p := &functionPlugin{logger: logger, functions: functionsMap{
+		"join": reflect.ValueOf(func(ctx context.Context, sep string, parts ...string) (string, error) {
+			return "", nil
+		}),
+		"setup": reflect.ValueOf(func() error { return nil }),
+	}}
+	assert.Equal(t, map[string]FuncSpec{
+		"join": {
+			Params: []FuncParam{
+				{Kind: ParamPositionalOnly, Type: "string"},
+				{Kind: ParamVarPositional, Type: "string"},
+			},
+			Returns: "string",
+		},
+		"setup": {Params: []FuncParam{}},
+	}, p.describe())
+
+	result, err := p.Call(DescribeFuncName)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"join":{"params":[{"kind":"positional_only","type":"string"},`+
+		`{"kind":"var_positional","type":"string"}],"returns":"string"},"setup":{"params":[]}}`, result)
+}
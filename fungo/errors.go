@@ -0,0 +1,76 @@
+package fungo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// userErrorPrefix marks user errors in plain string errors transferred
+// over RPC/JSON-RPC, e.g. "user error: AssertionError: expect 1, got 2"
+const userErrorPrefix = "user error: "
+
+// UserError is returned by plugin functions for expected failures, e.g. assertion
+// failures, so that hosts can tell them from plugin infrastructure failures.
+type UserError struct {
+	Type    string // error type, e.g. AssertionError
+	Message string
+}
+
+// NewUserError creates user error with default type UserError
+func NewUserError(format string, a ...interface{}) *UserError {
+	return &UserError{Type: "UserError", Message: fmt.Sprintf(format, a...)}
+}
+
+func (e *UserError) Error() string {
+	return fmt.Sprintf("%s%s: %s", userErrorPrefix, e.Type, e.Message)
+}
+
+// IsUserError reports whether err is user error raised by plugin function
+func IsUserError(err error) bool {
+	var userErr *UserError
+	return errors.As(err, &userErr)
+}
+
+// parseUserError converts error received from plugin to *UserError or *PanicError if it is marked
+func parseUserError(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	if s, ok := status.FromError(err); ok {
+		if s.Code() != codes.FailedPrecondition && s.Code() != codes.Internal {
+			return err
+		}
+		msg = s.Message()
+	}
+	if strings.HasPrefix(msg, panicErrorPrefix) {
+		return parsePanicError(msg)
+	}
+	if !strings.HasPrefix(msg, userErrorPrefix) {
+		return err
+	}
+
+	userErr := &UserError{Type: "UserError", Message: strings.TrimPrefix(msg, userErrorPrefix)}
+	if i := strings.Index(userErr.Message, ": "); i > 0 && !strings.Contains(userErr.Message[:i], " ") {
+		userErr.Type, userErr.Message = userErr.Message[:i], userErr.Message[i+2:]
+	}
+	return userErr
+}
+
+// toGRPCError returns user error with FailedPrecondition status code,
+// and panic error with Internal status code
+func toGRPCError(err error) error {
+	var userErr *UserError
+	if errors.As(err, &userErr) {
+		return status.Error(codes.FailedPrecondition, userErr.Error())
+	}
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		return status.Error(codes.Internal, panicErr.Error())
+	}
+	return err
+}
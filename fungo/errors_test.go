@@ -0,0 +1,31 @@
+package fungo
+
+import (
+	"errors"
+	"net/rpc"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUserError(t *testing.T) {
+	userErr := &UserError{Type: "AssertionError", Message: "expect 1, got 2"}
+	assert.Equal(t, "user error: AssertionError: expect 1, got 2", userErr.Error())
+
+	// gRPC
+	err := parseUserError(toGRPCError(userErr))
+	assert.True(t, IsUserError(err))
+	assert.Equal(t, userErr, err)
+
+	// net/rpc and JSON-RPC
+	err = parseUserError(rpc.ServerError(userErr.Error()))
+	assert.Equal(t, userErr, err)
+
+	err = parseUserError(rpc.ServerError("user error: division by zero"))
+	assert.Equal(t, &UserError{Type: "UserError", Message: "division by zero"}, err)
+
+	// infrastructure errors are kept as is
+	err = parseUserError(toGRPCError(errors.New("connection refused")))
+	assert.False(t, IsUserError(err))
+	assert.False(t, IsUserError(parseUserError(rpc.ServerError("unexpected EOF"))))
+}
@@ -1,8 +1,15 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/lingcetech/funplugin/fungo"
 )
 
 func init() {
@@ -63,3 +70,99 @@ func SetupHookExample(args string) string {
 func TeardownHookExample(args string) string {
 	return fmt.Sprintf("step name: %v, teardown...", args)
 }
+
+// AssertEqual returns user error if a is not equal to b
+func AssertEqual(a, b interface{}) error {
+	if !reflect.DeepEqual(a, b) {
+		return &fungo.UserError{
+			Type:    "AssertionError",
+			Message: fmt.Sprintf("expect %v, got %v", b, a),
+		}
+	}
+	return nil
+}
+
+// AuthHeader returns authorization header with secret token passed by host with WithSecrets,
+// secret values are masked in plugin logs
+func AuthHeader(name string) (string, error) {
+	token, ok := fungo.Secret(name)
+	if !ok {
+		return "", fmt.Errorf("secret %s not found", name)
+	}
+	log.Printf("build auth header with token %s", token)
+	return "Bearer " + token, nil
+}
+
+// ReadDataFile returns content of data file transferred by host with WithDataFiles
+func ReadDataFile(name string) (string, error) {
+	content, err := os.ReadFile(fungo.DataFile(name))
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// WriteArtifact writes content to file in artifact directory collected by host with WithArtifacts,
+// and returns the number of bytes written
+func WriteArtifact(name, content string) (int, error) {
+	f, err := fungo.CreateArtifact(name)
+	if err != nil {
+		return 0, err
+	}
+	n, err := f.WriteString(content)
+	if err != nil {
+		f.Close()
+		return 0, err
+	}
+	return n, f.Close()
+}
+
+// CallMetadata returns call metadata attached by host with CallWithMetadata,
+// ctx is passed by fungo since it is declared as the first parameter
+func CallMetadata(ctx context.Context, key string) (string, error) {
+	value, ok := fungo.Metadata(ctx)[key]
+	if !ok {
+		return "", fmt.Errorf("call metadata %s not found", key)
+	}
+	return value, nil
+}
+
+// ParseScore parses score, empty score is treated as 0 with a warning returned to host
+func ParseScore(ctx context.Context, raw string) (float64, error) {
+	if raw == "" {
+		fungo.AddWarning(ctx, "empty score, use 0")
+		return 0, nil
+	}
+	fungo.AddLog(ctx, fungo.LogLevelDebug, "parse score %s", raw)
+	return strconv.ParseFloat(raw, 64)
+}
+
+// WaitSeconds waits for seconds and returns early when host cancels the call
+func WaitSeconds(ctx context.Context, seconds float64) (float64, error) {
+	select {
+	case <-time.After(time.Duration(seconds * float64(time.Second))):
+		return seconds, nil
+	case <-ctx.Done():
+		log.Println("wait canceled")
+		return 0, ctx.Err()
+	}
+}
+
+// RandomUserID generates user id of test case, which is reproducible with funplugin.WithSeed
+func RandomUserID(caseID string) int {
+	return fungo.NewRand(caseID).Intn(1000000)
+}
+
+// HostSupports reports whether host loading plugin advertises capability
+func HostSupports(capability string) bool {
+	return fungo.HostSupports(capability)
+}
+
+// PluginConfig returns plugin config value passed by host with WithPluginConfig
+func PluginConfig(key string) (interface{}, error) {
+	value, ok := fungo.Config()[key]
+	if !ok {
+		return nil, fmt.Errorf("plugin config %s not found", key)
+	}
+	return value, nil
+}
@@ -14,6 +14,30 @@ func main() {
 	fungo.Register("concatenate", Concatenate)
 	fungo.Register("setup_hook_example", SetupHookExample)
 	fungo.Register("teardown_hook_example", TeardownHookExample)
+	fungo.Register("assert_equal", AssertEqual)
+	fungo.Register("auth_header", AuthHeader)
+	fungo.Register("plugin_config", PluginConfig)
+	fungo.Register("read_data_file", ReadDataFile)
+	fungo.Register("write_artifact", WriteArtifact)
+	fungo.Register("call_metadata", CallMetadata)
+	fungo.Register("host_supports", HostSupports)
+	fungo.Register("parse_score", ParseScore)
+	fungo.Register("wait_seconds", WaitSeconds)
+	fungo.Register("random_user_id", RandomUserID)
+
+	// sample arguments called by `debugtalk.bin --self-test`
+	fungo.RegisterSample("sum_ints", 1, 2, 3)
+	fungo.RegisterSample("sum_two_int", 1, 2)
+	fungo.RegisterSample("sum", 1, 2.5)
+	fungo.RegisterSample("sum_two_string", "a", "b")
+	fungo.RegisterSample("sum_strings", "a", "b", "c")
+	fungo.RegisterSample("concatenate", "a", 1, 2.5)
+	fungo.RegisterSample("setup_hook_example", "setup")
+	fungo.RegisterSample("teardown_hook_example", "teardown")
+	fungo.RegisterSample("assert_equal", 1, 1)
+	fungo.RegisterSample("parse_score", "98.5")
+	fungo.RegisterSample("wait_seconds", 0.01)
+	fungo.RegisterSample("random_user_id", "TC-1")
 
 	// if you want to run golang plugin over net/rpc, comment out the following line
 	// os.Setenv("HRP_PLUGIN_TYPE", "rpc")
@@ -2,6 +2,7 @@ package fungo
 
 import (
 	"context"
+	"sync"
 
 	"github.com/hashicorp/go-plugin"
 	"github.com/pkg/errors"
@@ -16,7 +17,12 @@ var json = jsoniter.ConfigCompatibleWithStandardLibrary
 
 // functionGRPCClient runs on the host side, it implements FuncCaller interface
 type functionGRPCClient struct {
-	client protoGen.DebugTalkClient
+	client     protoGen.DebugTalkClient
+	numberMode NumberMode
+
+	streamMu sync.Mutex
+	stream   *callStream // shared by calls with context, opened on first use
+	noStream bool        // plugin does not implement CallStream, e.g. built with older fungo
 }
 
 func (m *functionGRPCClient) GetNames() ([]string, error) {
@@ -31,40 +37,88 @@ func (m *functionGRPCClient) GetNames() ([]string, error) {
 }
 
 func (m *functionGRPCClient) Call(funcName string, funcArgs ...interface{}) (interface{}, error) {
-	logger.Info("gRPC_client Call() start", "funcName", funcName, "funcArgs", funcArgs)
+	return m.callValue(context.Background(), funcName, funcArgs...)
+}
+
+// CallWithMetadata calls plugin function with call metadata transferred as gRPC metadata
+func (m *functionGRPCClient) CallWithMetadata(md map[string]string, funcName string, funcArgs ...interface{}) (interface{}, error) {
+	return m.callValue(outgoingMetadata(context.Background(), md), funcName, funcArgs...)
+}
+
+// CallDetailed calls plugin function and returns its result with warnings and log entries
+func (m *functionGRPCClient) CallDetailed(funcName string, funcArgs ...interface{}) (*CallResult, error) {
+	return m.call(context.Background(), funcName, funcArgs...)
+}
+
+// CallDetailedContext calls plugin function with call metadata, canceled when ctx is done,
+// and returns its result with warnings and log entries
+func (m *functionGRPCClient) CallDetailedContext(ctx context.Context, md map[string]string, funcName string, funcArgs ...interface{}) (*CallResult, error) {
+	return m.call(outgoingMetadata(ctx, md), funcName, funcArgs...)
+}
 
-	funcArgBytes, err := json.Marshal(funcArgs)
+func (m *functionGRPCClient) callValue(ctx context.Context, funcName string, funcArgs ...interface{}) (interface{}, error) {
+	result, err := m.call(ctx, funcName, funcArgs...)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to marshal Call() funcArgs")
-	}
-	req := &protoGen.CallRequest{
-		Name: funcName,
-		Args: funcArgBytes,
+		return nil, err
 	}
+	return result.Value, nil
+}
+
+func (m *functionGRPCClient) call(ctx context.Context, funcName string, funcArgs ...interface{}) (*CallResult, error) {
+	logger.Info("gRPC_client Call() start", "funcName", funcName, "funcArgs", funcArgs)
 
-	response, err := m.client.Call(context.Background(), req)
+	req, err := newCallRequest(funcName, funcArgs)
+	if err != nil {
+		return nil, err
+	}
+	response, err := m.client.Call(ctx, req)
 	if err != nil {
 		logger.Error("gRPC_client Call() failed",
 			"funcName", funcName,
 			"funcArgs", funcArgs,
 			"error", err,
 		)
-		return nil, err
+		return nil, parseUserError(err)
 	}
+	return m.callResult(funcName, response)
+}
 
+func newCallRequest(funcName string, funcArgs []interface{}) (*protoGen.CallRequest, error) {
+	funcArgBytes, err := json.Marshal(encodeValue(funcArgs))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal Call() funcArgs")
+	}
+	return &protoGen.CallRequest{
+		Name: funcName,
+		Args: funcArgBytes,
+	}, nil
+}
+
+// callResult decodes call response with its warnings and log entries
+func (m *functionGRPCClient) callResult(funcName string, response *protoGen.CallResponse) (*CallResult, error) {
 	var resp interface{}
-	err = json.Unmarshal(response.Value, &resp)
+	err := unmarshalJSON(response.Value, &resp, m.numberMode)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to unmarshal Call() response")
 	}
+	result := &CallResult{Value: resp, Warnings: response.Warnings}
+	if len(response.Logs) > 0 {
+		if err := json.Unmarshal(response.Logs, &result.Logs); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal Call() logs")
+		}
+	}
+	for _, warning := range result.Warnings {
+		logger.Warn("plugin function warning", "funcName", funcName, "warning", warning)
+	}
 	logger.Info("gRPC_client Call() success", "result", resp)
-	return resp, nil
+	return result, nil
 }
 
 // Here is the gRPC server that functionGRPCClient talks to.
 type functionGRPCServer struct {
 	protoGen.UnimplementedDebugTalkServer
-	Impl IFuncCaller
+	Impl       IFuncCaller
+	NumberMode NumberMode
 }
 
 func (m *functionGRPCServer) GetNames(ctx context.Context, req *protoGen.Empty) (*protoGen.GetNamesResponse, error) {
@@ -82,35 +136,56 @@ func (m *functionGRPCServer) Call(ctx context.Context, req *protoGen.CallRequest
 	logger.Debug("gRPC_server Call() start")
 
 	var funcArgs []interface{}
-	if err := json.Unmarshal(req.Args, &funcArgs); err != nil {
+	if err := unmarshalJSON(req.Args, &funcArgs, m.NumberMode); err != nil {
 		return nil, errors.Wrap(err, "failed to unmarshal Call() funcArgs")
 	}
 
-	v, err := m.Impl.Call(req.Name, funcArgs...)
+	var v interface{}
+	var err error
+	details := &callDetails{}
+	if caller, ok := m.Impl.(IContextCaller); ok {
+		ctx = withCallDetails(withMetadata(ctx, incomingMetadata(ctx)), details)
+		v, err = caller.CallContext(ctx, req.Name, funcArgs...)
+	} else {
+		v, err = m.Impl.Call(req.Name, funcArgs...)
+	}
 	if err != nil {
 		logger.Error("gRPC_server Call() failed", "req", req, "error", err)
-		return nil, err
+		return nil, toGRPCError(err)
 	}
 
-	value, err := json.Marshal(v)
+	value, err := json.Marshal(encodeValue(v))
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to marshal Call() response")
 	}
+	response := &protoGen.CallResponse{Value: value}
+	details.mu.Lock()
+	defer details.mu.Unlock()
+	response.Warnings = details.warnings
+	if len(details.logs) > 0 {
+		if response.Logs, err = json.Marshal(details.logs); err != nil {
+			return nil, errors.Wrap(err, "failed to marshal Call() logs")
+		}
+	}
 	logger.Debug("gRPC_server Call() success")
-	return &protoGen.CallResponse{Value: value}, nil
+	return response, nil
 }
 
 // GRPCPlugin implements hashicorp's plugin.GRPCPlugin.
 type GRPCPlugin struct {
 	plugin.Plugin
-	Impl IFuncCaller
+	Impl       IFuncCaller
+	NumberMode NumberMode // decoding of JSON numbers in arguments on server and results on client
 }
 
 func (p *GRPCPlugin) GRPCServer(broker *plugin.GRPCBroker, s *grpc.Server) error {
-	protoGen.RegisterDebugTalkServer(s, &functionGRPCServer{Impl: p.Impl})
+	protoGen.RegisterDebugTalkServer(s, &functionGRPCServer{Impl: p.Impl, NumberMode: p.NumberMode})
 	return nil
 }
 
 func (p *GRPCPlugin) GRPCClient(ctx context.Context, broker *plugin.GRPCBroker, c *grpc.ClientConn) (interface{}, error) {
-	return &functionGRPCClient{client: protoGen.NewDebugTalkClient(c)}, nil
+	return &functionGRPCClient{
+		client:     protoGen.NewDebugTalkClient(c),
+		numberMode: p.NumberMode,
+	}, nil
 }
@@ -0,0 +1,72 @@
+package fungo
+
+import (
+	"os"
+	"sync"
+)
+
+// HostInfoEnvName is used to pass identity and capabilities of host to plugin process in JSON
+const HostInfoEnvName = "HRP_PLUGIN_HOST_INFO"
+
+// capabilities advertised by host, plugins feature-detect them with HostSupports
+// instead of guessing from host version
+const (
+	CapabilityCallMetadata = "call_metadata" // per-call metadata over gRPC, see Metadata
+	CapabilityPluginConfig = "plugin_config" // plugin config, see Config
+	CapabilitySecrets      = "secrets"       // secrets, see Secret
+	CapabilityDataFiles    = "data_files"    // data files, see DataFile
+	CapabilityArtifacts    = "artifacts"     // artifact collection, see CreateArtifact
+	CapabilityBigValues    = "big_values"    // *big.Int and *Decimal values
+	CapabilityTimeValues   = "time_values"   // time.Time and time.Duration values
+	CapabilityUserErrors   = "user_errors"   // UserError and PanicError transferred with type
+	CapabilitySeed         = "seed"          // random seed of deterministic mode, see Seed
+)
+
+// HostInfo is identity and capabilities of host loading plugin
+type HostInfo struct {
+	Name         string   `json:"name"`       // host library name, i.e. funplugin
+	Version      string   `json:"version"`    // host library version
+	GoVersion    string   `json:"go_version"` // go version host is built with
+	OS           string   `json:"os"`         // operating system of host, which may differ from that of plugin
+	Arch         string   `json:"arch"`       // architecture of host
+	Pid          int      `json:"pid"`        // host process id
+	Capabilities []string `json:"capabilities"`
+}
+
+// Supports reports whether host advertises capability
+func (h HostInfo) Supports(capability string) bool {
+	for _, c := range h.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	hostOnce sync.Once
+	hostInfo HostInfo
+)
+
+// Host returns identity and capabilities of host sent to plugin at startup, it is
+// zero value if plugin is not launched by host, e.g. sidecar started by kubernetes
+func Host() HostInfo {
+	hostOnce.Do(func() {
+		content := os.Getenv(HostInfoEnvName)
+		if content == "" {
+			return
+		}
+		if err := json.Unmarshal([]byte(content), &hostInfo); err != nil {
+			logger.Error("decode host info failed", "error", err)
+			return
+		}
+		logger.Info("load host info", "name", hostInfo.Name, "version", hostInfo.Version,
+			"capabilities", hostInfo.Capabilities)
+	})
+	return hostInfo
+}
+
+// HostSupports reports whether host advertises capability, e.g. CapabilityCallMetadata
+func HostSupports(capability string) bool {
+	return Host().Supports(capability)
+}
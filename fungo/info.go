@@ -0,0 +1,135 @@
+package fungo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// InfoFuncName is reserved function name host calls to get PluginInfo of plugin,
+// it is served by fungo and funppy and not listed by GetNames
+const InfoFuncName = "__funplugin_info__"
+
+// build manifest embedded by builder with -ldflags "-X", see funplugin WithAutoBuild, e.g.
+// go build -ldflags "-X github.com/lingcetech/funplugin/fungo.buildVersion=v1.2.0"
+var (
+	buildVersion    string // plugin version, e.g. output of git describe
+	buildCommit     string // vcs revision plugin is built from
+	buildTime       string // RFC3339 time plugin is built at
+	buildSourceHash string // hash of plugin sources computed by WithAutoBuild
+)
+
+// ldflagsPrefix is package path of -X symbols of build manifest
+const ldflagsPrefix = "github.com/lingcetech/funplugin/fungo."
+
+// PluginInfo is build manifest of plugin, which tells exactly which plugin build
+// handled calls, e.g. when investigating a failing run
+type PluginInfo struct {
+	Version    string `json:"version,omitempty"`     // plugin version, e.g. v1.2.0-3-g1a2b3c4
+	Commit     string `json:"commit,omitempty"`      // vcs revision plugin is built from
+	Modified   bool   `json:"modified,omitempty"`    // built with uncommitted changes
+	BuildTime  string `json:"build_time,omitempty"`  // RFC3339 time plugin is built at
+	SourceHash string `json:"source_hash,omitempty"` // hash of plugin sources recorded by WithAutoBuild
+	Runtime    string `json:"runtime,omitempty"`     // e.g. go1.21.0 or python 3.11.4
+	SDK        string `json:"sdk,omitempty"`         // e.g. fungo v0.5.4 or funppy v0.5.2
+}
+
+// BuildInfo returns build manifest of plugin binary, values embedded with -ldflags
+// take precedence over vcs settings recorded by go build
+func BuildInfo() PluginInfo {
+	info := PluginInfo{
+		Version:    buildVersion,
+		Commit:     buildCommit,
+		BuildTime:  buildTime,
+		SourceHash: buildSourceHash,
+		Runtime:    runtime.Version(),
+		SDK:        "fungo " + Version,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info = mergeInfo(info, InfoFromBuildInfo(bi))
+	}
+	return info
+}
+
+// InfoFromBuildInfo returns build manifest recorded in build info of go binary, which
+// is read from plugin file with debug/buildinfo, e.g. by host for go plugins
+func InfoFromBuildInfo(bi *debug.BuildInfo) PluginInfo {
+	info := PluginInfo{Runtime: bi.GoVersion}
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "-ldflags":
+			info = mergeInfo(info, infoFromLDFlags(setting.Value))
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.modified":
+			info.Modified = info.Modified || setting.Value == "true"
+		}
+	}
+	if info.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		info.Version = bi.Main.Version
+	}
+	for _, dep := range bi.Deps {
+		if dep.Path == "github.com/lingcetech/funplugin" {
+			info.SDK = "fungo " + dep.Version
+		}
+	}
+	return info
+}
+
+// infoFromLDFlags parses build manifest from -X flags of -ldflags
+func infoFromLDFlags(ldflags string) PluginInfo {
+	var info PluginInfo
+	fields := strings.Fields(ldflags)
+	for i, field := range fields {
+		var value string
+		switch {
+		case field == "-X" && i+1 < len(fields):
+			value = fields[i+1]
+		case strings.HasPrefix(field, "-X="):
+			value = strings.TrimPrefix(field, "-X=")
+		default:
+			continue
+		}
+		if !strings.HasPrefix(value, ldflagsPrefix) {
+			continue
+		}
+		name, value, _ := strings.Cut(strings.TrimPrefix(value, ldflagsPrefix), "=")
+		switch name {
+		case "buildVersion":
+			info.Version = value
+		case "buildCommit":
+			info.Commit = value
+		case "buildTime":
+			info.BuildTime = value
+		case "buildSourceHash":
+			info.SourceHash = value
+		}
+	}
+	return info
+}
+
+// mergeInfo fills empty fields of info with those of other
+func mergeInfo(info, other PluginInfo) PluginInfo {
+	if info.Version == "" {
+		info.Version = other.Version
+	}
+	if info.Commit == "" {
+		info.Commit = other.Commit
+	}
+	info.Modified = info.Modified || other.Modified
+	if info.BuildTime == "" {
+		info.BuildTime = other.BuildTime
+	}
+	if info.SourceHash == "" {
+		info.SourceHash = other.SourceHash
+	}
+	if info.Runtime == "" {
+		info.Runtime = other.Runtime
+	}
+	if info.SDK == "" {
+		info.SDK = other.SDK
+	}
+	return info
+}
@@ -0,0 +1,51 @@
+package fungo
+
+import (
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInfoFromBuildInfo(t *testing.T) {
+	bi := &debug.BuildInfo{
+		GoVersion: "go1.21.0",
+		Main:      debug.Module{Path: "example.com/debugtalk", Version: "(devel)"},
+		Deps:      []*debug.Module{{Path: "github.com/lingcetech/funplugin", Version: "v0.6.0"}},
+		Settings: []debug.BuildSetting{
+			{Key: "-ldflags", Value: "-s -w -X github.com/lingcetech/funplugin/fungo.buildVersion=v1.2.0-3-g1a2b3c4" +
+				" -X=github.com/lingcetech/funplugin/fungo.buildTime=2024-01-02T03:04:05Z" +
+				" -X main.version=ignored -X github.com/lingcetech/funplugin/fungo.buildSourceHash=abc"},
+			{Key: "vcs.revision", Value: "1a2b3c4d"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+	assert.Equal(t, PluginInfo{
+		Version:    "v1.2.0-3-g1a2b3c4",
+		Commit:     "1a2b3c4d",
+		Modified:   true,
+		BuildTime:  "2024-01-02T03:04:05Z",
+		SourceHash: "abc",
+		Runtime:    "go1.21.0",
+		SDK:        "fungo v0.6.0",
+	}, InfoFromBuildInfo(bi))
+
+	// commit embedded with -ldflags takes precedence over vcs revision
+	bi.Settings[0].Value = "-X github.com/lingcetech/funplugin/fungo.buildCommit=ffff"
+	bi.Main.Version = "v1.0.0"
+	info := InfoFromBuildInfo(bi)
+	assert.Equal(t, "ffff", info.Commit)
+	assert.Equal(t, "v1.0.0", info.Version)
+}
+
+func TestCallInfoFunc(t *testing.T) {
+	p := &functionPlugin{logger: logger, functions: make(functionsMap)}
+	result, err := p.Call(InfoFuncName)
+	assert.Nil(t, err)
+	var info PluginInfo
+	assert.Nil(t, json.Unmarshal([]byte(result.(string)), &info))
+	assert.Equal(t, "fungo "+Version, info.SDK)
+
+	names, _ := p.GetNames()
+	assert.Empty(t, names)
+}
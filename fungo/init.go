@@ -4,6 +4,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sync"
 
 	hclog "github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/go-plugin"
@@ -15,19 +16,36 @@ var (
 	logger = Logger
 )
 
-var Logger = hclog.New(&hclog.LoggerOptions{
-	Name:        "fungo",
-	Output:      hclog.DefaultOutput,
-	DisableTime: true,
-	Level:       hclog.Debug,
-	Color:       hclog.AutoColor,
-})
+// Logger is shared by plugins of host process and plugin server, see InitLogger
+var Logger hclog.Logger = &sharedLogger{}
 
-var file *os.File
+var (
+	logMu          sync.Mutex
+	logUsers       int        // plugins using Logger initialized by InitLogger
+	logFiles       []*os.File // log files of plugins using Logger
+	logLevel       hclog.Level
+	logDisableTime bool
+)
 
-func InitLogger(logLevel hclog.Level, logFile string, disableTime bool) hclog.Logger {
-	output := hclog.DefaultOutput
-	if logFile != "" {
+// InitLogger configures Logger for a plugin and returns it. Plugins initialized while others
+// are running share Logger, which writes to log files of all of them at the most verbose
+// level of them. Each call must be paired with CloseLogFile, log files are closed after
+// the last plugin using Logger closes it.
+func InitLogger(level hclog.Level, logFile string, disableTime bool) hclog.Logger {
+	if level == hclog.NoLevel {
+		level = hclog.Info
+	}
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	if logUsers == 0 {
+		logLevel, logDisableTime = level, disableTime
+	} else if level < logLevel {
+		logLevel = level
+	}
+	logUsers++
+
+	if logFile != "" && !hasLogFile(logFile) {
 		err := os.MkdirAll(filepath.Dir(logFile), os.ModePerm)
 		if err != nil {
 			logger.Error("create log file directory failed",
@@ -35,37 +53,77 @@ func InitLogger(logLevel hclog.Level, logFile string, disableTime bool) hclog.Lo
 			os.Exit(1)
 		}
 
-		file, err = os.OpenFile(logFile, os.O_CREATE|os.O_RDWR, 0666)
+		file, err := os.OpenFile(logFile, os.O_CREATE|os.O_RDWR, 0666)
 		if err != nil {
 			logger.Error("open log file failed", "error", err)
 			os.Exit(1)
 		}
-		output = io.MultiWriter(hclog.DefaultOutput, file)
+		logFiles = append(logFiles, file)
 	}
 
-	logger = hclog.New(&hclog.LoggerOptions{
-		Name:        "fungo",
-		Output:      output,
-		DisableTime: disableTime,
-		Level:       logLevel,
-		Color:       hclog.AutoColor,
-	})
+	storeBaseLogger()
 	logger.Info("set plugin log level",
 		"level", logLevel.String(), "logFile", logFile)
-	return logger
+	return Logger
+}
+
+// hasLogFile reports whether log file is opened for a plugin using Logger
+func hasLogFile(logFile string) bool {
+	for _, file := range logFiles {
+		if file.Name() == logFile {
+			return true
+		}
+	}
+	return false
 }
 
+// storeBaseLogger configures Logger with log files of plugins using it
+func storeBaseLogger() {
+	output := hclog.DefaultOutput
+	if len(logFiles) > 0 {
+		writers := []io.Writer{hclog.DefaultOutput}
+		for _, file := range logFiles {
+			writers = append(writers, file)
+		}
+		output = io.MultiWriter(writers...)
+	}
+	baseLogger.Store(newBaseLogger(logLevel, output, logDisableTime))
+}
+
+// CloseLogFile releases Logger initialized for a plugin by InitLogger,
+// closing log files if no other plugin is using it
 func CloseLogFile() error {
-	if file != nil {
-		logger.Info("close log file")
-		return file.Close()
+	logMu.Lock()
+	defer logMu.Unlock()
+	if logUsers == 0 {
+		return nil
 	}
-	return nil
+	logUsers--
+	if logUsers > 0 || len(logFiles) == 0 {
+		return nil
+	}
+
+	logger.Info("close log file")
+	files := logFiles
+	logFiles = nil
+	storeBaseLogger()
+	var err error
+	for _, file := range files {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
 }
 
 // PluginTypeEnvName is used to specify hashicorp go plugin type, rpc/grpc
 const PluginTypeEnvName = "HRP_PLUGIN_TYPE"
 
+// GRPCReflectionEnvName is used to enable gRPC reflection service on python plugin server,
+// thus tools like grpcurl can list and invoke plugin functions for debugging.
+// Go plugin server always registers reflection service via hashicorp go-plugin.
+const GRPCReflectionEnvName = "HRP_PLUGIN_GRPC_REFLECTION"
+
 // HandshakeConfig is used to just do a basic handshake between
 // a plugin and host. If the handshake fails, a user friendly error is shown.
 // This prevents users from executing bad plugins or executing a plugin
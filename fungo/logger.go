@@ -0,0 +1,94 @@
+package fungo
+
+import (
+	"io"
+	"log"
+	"sync/atomic"
+
+	hclog "github.com/hashicorp/go-hclog"
+)
+
+// baseLogger is hclog.Logger configured by InitLogger, swapped atomically since
+// plugins are initialized while others are logging
+var baseLogger atomic.Value
+
+func newBaseLogger(level hclog.Level, output io.Writer, disableTime bool) hclog.Logger {
+	return hclog.New(&hclog.LoggerOptions{
+		Name:        "fungo",
+		Output:      &maskWriter{output},
+		DisableTime: disableTime,
+		Level:       level,
+		Color:       hclog.AutoColor,
+	})
+}
+
+var defaultLogger = newBaseLogger(hclog.Debug, hclog.DefaultOutput, true)
+
+// sharedLogger is Logger shared by plugins of host process, it follows base logger
+// configured by InitLogger, as well as loggers derived from it by Named, ResetNamed and With
+type sharedLogger struct {
+	derive func(hclog.Logger) hclog.Logger // nil for Logger itself
+}
+
+func (l *sharedLogger) current() hclog.Logger {
+	base, ok := baseLogger.Load().(hclog.Logger)
+	if !ok {
+		base = defaultLogger
+	}
+	if l.derive == nil {
+		return base
+	}
+	return l.derive(base)
+}
+
+func (l *sharedLogger) then(derive func(hclog.Logger) hclog.Logger) hclog.Logger {
+	if l.derive == nil {
+		return &sharedLogger{derive: derive}
+	}
+	parentDerive := l.derive
+	return &sharedLogger{derive: func(base hclog.Logger) hclog.Logger {
+		return derive(parentDerive(base))
+	}}
+}
+
+func (l *sharedLogger) Log(level hclog.Level, msg string, args ...interface{}) {
+	l.current().Log(level, msg, args...)
+}
+
+func (l *sharedLogger) Trace(msg string, args ...interface{}) { l.current().Trace(msg, args...) }
+func (l *sharedLogger) Debug(msg string, args ...interface{}) { l.current().Debug(msg, args...) }
+func (l *sharedLogger) Info(msg string, args ...interface{})  { l.current().Info(msg, args...) }
+func (l *sharedLogger) Warn(msg string, args ...interface{})  { l.current().Warn(msg, args...) }
+func (l *sharedLogger) Error(msg string, args ...interface{}) { l.current().Error(msg, args...) }
+
+func (l *sharedLogger) IsTrace() bool { return l.current().IsTrace() }
+func (l *sharedLogger) IsDebug() bool { return l.current().IsDebug() }
+func (l *sharedLogger) IsInfo() bool  { return l.current().IsInfo() }
+func (l *sharedLogger) IsWarn() bool  { return l.current().IsWarn() }
+func (l *sharedLogger) IsError() bool { return l.current().IsError() }
+
+func (l *sharedLogger) ImpliedArgs() []interface{} { return l.current().ImpliedArgs() }
+func (l *sharedLogger) Name() string               { return l.current().Name() }
+
+func (l *sharedLogger) With(args ...interface{}) hclog.Logger {
+	return l.then(func(logger hclog.Logger) hclog.Logger { return logger.With(args...) })
+}
+
+func (l *sharedLogger) Named(name string) hclog.Logger {
+	return l.then(func(logger hclog.Logger) hclog.Logger { return logger.Named(name) })
+}
+
+func (l *sharedLogger) ResetNamed(name string) hclog.Logger {
+	return l.then(func(logger hclog.Logger) hclog.Logger { return logger.ResetNamed(name) })
+}
+
+func (l *sharedLogger) SetLevel(level hclog.Level) { l.current().SetLevel(level) }
+func (l *sharedLogger) GetLevel() hclog.Level      { return l.current().GetLevel() }
+
+func (l *sharedLogger) StandardLogger(opts *hclog.StandardLoggerOptions) *log.Logger {
+	return l.current().StandardLogger(opts)
+}
+
+func (l *sharedLogger) StandardWriter(opts *hclog.StandardLoggerOptions) io.Writer {
+	return l.current().StandardWriter(opts)
+}
@@ -0,0 +1,36 @@
+package fungo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitLogger(t *testing.T) {
+	dir := t.TempDir()
+	fileA := filepath.Join(dir, "a.log")
+	fileB := filepath.Join(dir, "b.log")
+	named := Logger.ResetNamed("plugin-a")
+
+	InitLogger(hclog.Info, fileA, true)
+	InitLogger(hclog.Debug, fileB, true)
+	// loggers derived before follow log files and level of plugins initialized later
+	named.Debug("shared by plugins")
+	assert.Nil(t, CloseLogFile())
+	named.Info("after first plugin quits")
+	assert.Nil(t, CloseLogFile())
+	named.Info("after last plugin quits")
+	assert.Nil(t, CloseLogFile())
+
+	for _, file := range []string{fileA, fileB} {
+		content, err := os.ReadFile(file)
+		assert.Nil(t, err)
+		assert.Contains(t, string(content), "plugin-a: shared by plugins")
+		assert.Contains(t, string(content), "after first plugin quits")
+		assert.Contains(t, string(content), "close log file")
+		assert.NotContains(t, string(content), "after last plugin quits")
+	}
+}
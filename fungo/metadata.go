@@ -0,0 +1,79 @@
+package fungo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// call metadata key k is transferred as gRPC metadata key hrp-md-<k>-bin, the prefix distinguishes
+// it from headers added by gRPC, and binary header allows arbitrary UTF-8 values
+const (
+	metadataKeyPrefix = "hrp-md-"
+	metadataKeySuffix = "-bin"
+)
+
+// IMetadataCaller is implemented by host side gRPC clients, which transfer per-call metadata
+// such as test case id and correlation id to plugin functions
+type IMetadataCaller interface {
+	CallWithMetadata(md map[string]string, funcName string, args ...interface{}) (interface{}, error)
+}
+
+// ValidateMetadataKey returns error if call metadata key is not a valid gRPC metadata key,
+// keys consist of lowercase letters, digits, '-', '_' and '.'
+func ValidateMetadataKey(key string) error {
+	if key == "" || strings.HasSuffix(key, metadataKeySuffix) {
+		return fmt.Errorf("invalid call metadata key %q", key)
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return fmt.Errorf("invalid call metadata key %q, it should consist of "+
+				"lowercase letters, digits, '-', '_' and '.'", key)
+		}
+	}
+	return nil
+}
+
+// outgoingMetadata attaches call metadata to ctx of gRPC call
+func outgoingMetadata(ctx context.Context, md map[string]string) context.Context {
+	if len(md) == 0 {
+		return ctx
+	}
+	pairs := make([]string, 0, 2*len(md))
+	for key, value := range md {
+		pairs = append(pairs, metadataKeyPrefix+key+metadataKeySuffix, value)
+	}
+	return metadata.AppendToOutgoingContext(ctx, pairs...)
+}
+
+// incomingMetadata returns call metadata in incoming gRPC metadata of ctx
+func incomingMetadata(ctx context.Context) map[string]string {
+	grpcMD, _ := metadata.FromIncomingContext(ctx)
+	md := make(map[string]string)
+	for key, values := range grpcMD {
+		if !strings.HasPrefix(key, metadataKeyPrefix) || !strings.HasSuffix(key, metadataKeySuffix) ||
+			len(values) == 0 {
+			continue
+		}
+		md[strings.TrimSuffix(strings.TrimPrefix(key, metadataKeyPrefix), metadataKeySuffix)] = values[0]
+	}
+	return md
+}
+
+type metadataContextKey struct{}
+
+// withMetadata returns ctx carrying call metadata
+func withMetadata(ctx context.Context, md map[string]string) context.Context {
+	return context.WithValue(ctx, metadataContextKey{}, md)
+}
+
+// Metadata returns call metadata attached by host with IPlugin.CallWithMetadata, e.g. test case
+// id for logging correlation. Plugin functions receive ctx by declaring context.Context as the
+// first parameter; metadata is empty if host attaches none or transport is not gRPC.
+// The returned map must not be modified.
+func Metadata(ctx context.Context) map[string]string {
+	md, _ := ctx.Value(metadataContextKey{}).(map[string]string)
+	return md
+}
@@ -0,0 +1,45 @@
+package fungo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestValidateMetadataKey(t *testing.T) {
+	for _, key := range []string{"case-id", "case_id", "trace.id", "user1"} {
+		assert.Nil(t, ValidateMetadataKey(key))
+	}
+	for _, key := range []string{"", "Case-ID", "case id", "用户", "data-bin"} {
+		assert.Error(t, ValidateMetadataKey(key))
+	}
+}
+
+func TestIncomingMetadata(t *testing.T) {
+	ctx := outgoingMetadata(context.Background(), map[string]string{"case-id": "TC-1", "user": "张三"})
+	md, _ := metadata.FromOutgoingContext(ctx)
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Join(md, metadata.Pairs("user-agent", "grpc-go")))
+	assert.Equal(t, map[string]string{"case-id": "TC-1", "user": "张三"}, incomingMetadata(ctx))
+}
+
+func TestCallContext(t *testing.T) {
+	Register("greet", func(ctx context.Context, name string) string {
+		return Metadata(ctx)["greeting"] + ", " + name
+	})
+	defer func() {
+		delete(functions, "greet")
+	}()
+
+	p := &functionPlugin{logger: logger, functions: functions}
+	ctx := withMetadata(context.Background(), map[string]string{"greeting": "hello"})
+	result, err := p.CallContext(ctx, "greet", "alice")
+	assert.Nil(t, err)
+	assert.Equal(t, "hello, alice", result)
+
+	// context is passed to functions called without metadata as well
+	result, err = p.Call("greet", "bob")
+	assert.Nil(t, err)
+	assert.Equal(t, ", bob", result)
+}
@@ -0,0 +1,61 @@
+package fungo
+
+import (
+	"sync"
+)
+
+// CallHandler dispatches plugin function call on the plugin side
+type CallHandler func(funcName string, args ...interface{}) (interface{}, error)
+
+// Middleware wraps plugin function dispatch on the plugin side, symmetrical to
+// host side interceptors, e.g. for auth checks, logging and metrics.
+// It calls next to continue, or returns an error to reject the call.
+type Middleware func(next CallHandler) CallHandler
+
+var (
+	middlewareMutex sync.RWMutex
+	middlewares     []Middleware
+)
+
+// Use registers middlewares around every dispatched plugin function,
+// the first registered one is the outermost
+func Use(m ...Middleware) {
+	middlewareMutex.Lock()
+	defer middlewareMutex.Unlock()
+	middlewares = append(middlewares, m...)
+}
+
+// BeforeCall registers hook called before every plugin function,
+// the call is rejected with the error if hook returns one
+func BeforeCall(hook func(funcName string, args []interface{}) error) {
+	Use(func(next CallHandler) CallHandler {
+		return func(funcName string, args ...interface{}) (interface{}, error) {
+			if err := hook(funcName, args); err != nil {
+				return nil, err
+			}
+			return next(funcName, args...)
+		}
+	})
+}
+
+// AfterCall registers hook called after every plugin function with its result and error,
+// including errors of rejected calls and recovered panics
+func AfterCall(hook func(funcName string, args []interface{}, result interface{}, err error)) {
+	Use(func(next CallHandler) CallHandler {
+		return func(funcName string, args ...interface{}) (interface{}, error) {
+			result, err := next(funcName, args...)
+			hook(funcName, args, result, err)
+			return result, err
+		}
+	})
+}
+
+// chainMiddlewares wraps handler with registered middlewares
+func chainMiddlewares(handler CallHandler) CallHandler {
+	middlewareMutex.RLock()
+	defer middlewareMutex.RUnlock()
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
@@ -0,0 +1,48 @@
+package fungo
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	defer func() { middlewares = nil }()
+	p := &functionPlugin{logger: logger, functions: functionsMap{
+		"divide": reflect.ValueOf(divideInts),
+	}}
+
+	var calls []string
+	Use(func(next CallHandler) CallHandler {
+		return func(funcName string, args ...interface{}) (interface{}, error) {
+			calls = append(calls, "outer "+funcName)
+			return next(funcName, args...)
+		}
+	})
+	BeforeCall(func(funcName string, args []interface{}) error {
+		if len(args) > 0 && args[0] == -1 {
+			return NewUserError("permission denied")
+		}
+		return nil
+	})
+	var results []interface{}
+	AfterCall(func(funcName string, args []interface{}, result interface{}, err error) {
+		results = append(results, result, err != nil)
+	})
+
+	result, err := p.Call("divide", 6, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, result)
+
+	// rejected by before hook, after hook is innermost and not called
+	_, err = p.Call("divide", -1, 1)
+	assert.True(t, IsUserError(err))
+
+	// after hook sees recovered panic
+	_, err = p.Call("divide", 1, 0)
+	assert.True(t, IsPanicError(err))
+
+	assert.Equal(t, []string{"outer divide", "outer divide", "outer divide"}, calls)
+	assert.Equal(t, []interface{}{2, false, nil, true}, results)
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package fungo
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+)
+
+func dialPipe(path string) (net.Conn, error) {
+	return nil, fmt.Errorf("named pipe transport is not supported on %s", runtime.GOOS)
+}
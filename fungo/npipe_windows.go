@@ -0,0 +1,16 @@
+//go:build windows
+
+package fungo
+
+import (
+	"net"
+	"time"
+
+	"github.com/Microsoft/go-winio"
+)
+
+// dialPipe connects to windows named pipe, e.g. \\.\pipe\funplugin-1234
+func dialPipe(path string) (net.Conn, error) {
+	timeout := 10 * time.Second
+	return winio.DialPipe(path, &timeout)
+}
@@ -0,0 +1,63 @@
+package fungo
+
+import (
+	"os"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// JSONNumberEnvName is used to pass NumberMode to plugin process
+const JSONNumberEnvName = "HRP_PLUGIN_JSON_NUMBER"
+
+// NumberMode specifies how JSON numbers in function arguments and results are decoded
+// by gRPC and JSON-RPC transports, net/rpc keeps original go types.
+type NumberMode string
+
+const (
+	NumberFloat64 NumberMode = ""       // float64, the default of encoding/json
+	NumberJSON    NumberMode = "number" // json.Number keeping the original literal
+	NumberInt64   NumberMode = "int64"  // int64 for integers in int64 range, float64 for others
+)
+
+// Valid reports whether mode is known
+func (m NumberMode) Valid() bool {
+	switch m {
+	case NumberFloat64, NumberJSON, NumberInt64:
+		return true
+	}
+	return false
+}
+
+// pluginNumberMode returns NumberMode passed by host to plugin process
+func pluginNumberMode() NumberMode {
+	return NumberMode(os.Getenv(JSONNumberEnvName))
+}
+
+var jsonUseNumber = jsoniter.Config{
+	EscapeHTML:             true,
+	SortMapKeys:            true,
+	ValidateJsonRawMessage: true,
+	UseNumber:              true,
+}.Froze()
+
+// unmarshalJSON decodes data to v with JSON numbers decoded in mode and
+// arbitrary-precision values restored, v is *interface{} or *[]interface{}
+func unmarshalJSON(data []byte, v interface{}, mode NumberMode) error {
+	var err error
+	if mode == NumberFloat64 {
+		err = json.Unmarshal(data, v)
+	} else {
+		err = jsonUseNumber.Unmarshal(data, v)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch p := v.(type) {
+	case *interface{}:
+		*p, err = decodeValue(*p, mode)
+	case *[]interface{}:
+		_, err = decodeValue(*p, mode)
+	}
+	return err
+}
@@ -0,0 +1,49 @@
+package fungo
+
+import (
+	stdjson "encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnmarshalJSONNumber(t *testing.T) {
+	data := []byte(`[1234567890123456789, 1.5, {"ids": [7]}]`)
+
+	var args []interface{}
+	assert.Nil(t, unmarshalJSON(data, &args, NumberFloat64))
+	assert.Equal(t, float64(1234567890123456789), args[0])
+
+	args = nil
+	assert.Nil(t, unmarshalJSON(data, &args, NumberJSON))
+	assert.Equal(t, stdjson.Number("1234567890123456789"), args[0])
+	assert.Equal(t, stdjson.Number("1.5"), args[1])
+
+	args = nil
+	assert.Nil(t, unmarshalJSON(data, &args, NumberInt64))
+	assert.Equal(t, []interface{}{
+		int64(1234567890123456789), 1.5,
+		map[string]interface{}{"ids": []interface{}{int64(7)}},
+	}, args)
+
+	var value interface{}
+	assert.Nil(t, unmarshalJSON([]byte("98765432109876543"), &value, NumberInt64))
+	assert.Equal(t, int64(98765432109876543), value)
+
+	assert.False(t, NumberMode("decimal").Valid())
+}
+
+func TestCallFuncJSONNumber(t *testing.T) {
+	fn := reflect.ValueOf(func(id int64, ratio float32, n uint8) int64 {
+		return id + int64(ratio) + int64(n)
+	})
+	result, err := CallFunc(fn, stdjson.Number("1234567890123456789"), stdjson.Number("2.5"), stdjson.Number("1"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1234567890123456792), result)
+
+	_, err = CallFunc(fn, stdjson.Number("1.5"), stdjson.Number("2.5"), stdjson.Number("1"))
+	assert.Error(t, err)
+	_, err = CallFunc(fn, stdjson.Number("1"), stdjson.Number("2.5"), stdjson.Number("256"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,103 @@
+package fungo
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+// panicErrorPrefix marks panics of plugin functions in errors transferred
+// over RPC/JSON-RPC, e.g. "plugin panic: divide: runtime error: integer divide by zero"
+const panicErrorPrefix = "plugin panic: "
+
+// PanicError is returned to host when plugin function panics,
+// the plugin keeps serving later calls.
+type PanicError struct {
+	Func  string // plugin function name
+	Value string // value passed to panic
+	Stack string // goroutine stack of plugin function
+}
+
+func (e *PanicError) Error() string {
+	msg := fmt.Sprintf("%s%s: %s", panicErrorPrefix, e.Func, e.Value)
+	if e.Stack != "" {
+		msg += "\n" + e.Stack
+	}
+	return msg
+}
+
+// IsPanicError reports whether err is caused by panic of plugin function
+func IsPanicError(err error) bool {
+	var panicErr *PanicError
+	return errors.As(err, &panicErr)
+}
+
+// parsePanicError converts message marked with panicErrorPrefix to *PanicError
+func parsePanicError(msg string) *PanicError {
+	panicErr := &PanicError{}
+	msg = strings.TrimPrefix(msg, panicErrorPrefix)
+	if i := strings.Index(msg, "\n"); i >= 0 {
+		msg, panicErr.Stack = msg[:i], msg[i+1:]
+	}
+	panicErr.Value = msg
+	if i := strings.Index(msg, ": "); i > 0 {
+		panicErr.Func, panicErr.Value = msg[:i], msg[i+2:]
+	}
+	return panicErr
+}
+
+// PanicHandler maps value recovered from panic of plugin function to error returned
+// to host, e.g. a *UserError for expected failures. It may panic again to crash the
+// plugin process for fatal conditions, see CrashOnPanic.
+type PanicHandler func(funcName string, recovered interface{}, stack []byte) error
+
+// RecoverPanic is the default PanicHandler, it returns *PanicError with stack
+func RecoverPanic(funcName string, recovered interface{}, stack []byte) error {
+	return &PanicError{Func: funcName, Value: fmt.Sprint(recovered), Stack: string(stack)}
+}
+
+// CrashOnPanic is PanicHandler crashing plugin process, host calls fail afterwards
+func CrashOnPanic(funcName string, recovered interface{}, stack []byte) error {
+	panic(recovered)
+}
+
+var (
+	panicMutex    sync.RWMutex
+	panicHandlers = make(map[string]PanicHandler)
+)
+
+// HandlePanic sets handler of panics in plugin function funcName,
+// or in all plugin functions without their own handler if funcName is empty.
+func HandlePanic(funcName string, handler PanicHandler) {
+	panicMutex.Lock()
+	defer panicMutex.Unlock()
+	if funcName != "" {
+		// match both registered and common name like Register
+		funcName = ConvertCommonName(funcName)
+	}
+	if handler == nil {
+		delete(panicHandlers, funcName)
+		return
+	}
+	panicHandlers[funcName] = handler
+}
+
+// handlePanic converts value recovered from panic of plugin function to error
+func handlePanic(funcName string, recovered interface{}) error {
+	stack := debug.Stack()
+	logger.Error("plugin function panicked", "funcName", funcName,
+		"panic", recovered, "stack", string(stack))
+
+	panicMutex.RLock()
+	handler, ok := panicHandlers[ConvertCommonName(funcName)]
+	if !ok {
+		handler, ok = panicHandlers[""]
+	}
+	panicMutex.RUnlock()
+	if !ok {
+		handler = RecoverPanic
+	}
+	return handler(funcName, recovered, stack)
+}
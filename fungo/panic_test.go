@@ -0,0 +1,58 @@
+package fungo
+
+import (
+	"net/rpc"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func divideInts(a, b int) int {
+	return a / b
+}
+
+func TestRecoverPanic(t *testing.T) {
+	p := &functionPlugin{logger: logger, functions: functionsMap{
+		"divide": reflect.ValueOf(divideInts),
+	}}
+
+	_, err := p.Call("divide", 1, 0)
+	assert.True(t, IsPanicError(err))
+	panicErr := err.(*PanicError)
+	assert.Equal(t, "divide", panicErr.Func)
+	assert.Equal(t, "runtime error: integer divide by zero", panicErr.Value)
+	assert.Contains(t, panicErr.Stack, "divideInts")
+
+	// plugin keeps serving later calls
+	result, err := p.Call("divide", 6, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, result)
+
+	// gRPC, net/rpc and JSON-RPC
+	assert.Equal(t, panicErr, parseUserError(toGRPCError(panicErr)))
+	assert.Equal(t, panicErr, parseUserError(rpc.ServerError(panicErr.Error())))
+	assert.False(t, IsUserError(parseUserError(toGRPCError(panicErr))))
+}
+
+func TestHandlePanic(t *testing.T) {
+	p := &functionPlugin{logger: logger, functions: functionsMap{
+		"divide":   reflect.ValueOf(divideInts),
+		"divide_2": reflect.ValueOf(divideInts),
+	}}
+	defer HandlePanic("divide", nil)
+	defer HandlePanic("", nil)
+
+	// per-function mapping
+	HandlePanic("Divide", func(funcName string, recovered interface{}, stack []byte) error {
+		return NewUserError("%s: %v", funcName, recovered)
+	})
+	_, err := p.Call("divide", 1, 0)
+	assert.Equal(t, &UserError{Type: "UserError", Message: "divide: runtime error: integer divide by zero"}, err)
+	_, err = p.Call("divide_2", 1, 0)
+	assert.True(t, IsPanicError(err))
+
+	// crash plugin for fatal conditions
+	HandlePanic("", CrashOnPanic)
+	assert.Panics(t, func() { p.Call("divide_2", 1, 0) })
+}
@@ -1,9 +1,12 @@
 package fungo
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"reflect"
+	"syscall"
 
 	hclog "github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/go-plugin"
@@ -27,18 +30,63 @@ func (p *functionPlugin) GetNames() ([]string, error) {
 	return names, nil
 }
 
+// IContextCaller is implemented by plugin side function callers receiving per-call context,
+// which carries call metadata of gRPC calls, and by host side gRPC clients, which cancel
+// the gRPC call when ctx is done
+type IContextCaller interface {
+	CallContext(ctx context.Context, funcName string, args ...interface{}) (interface{}, error)
+}
+
 func (p *functionPlugin) Call(funcName string, args ...interface{}) (interface{}, error) {
+	return p.CallContext(context.Background(), funcName, args...)
+}
+
+func (p *functionPlugin) CallContext(ctx context.Context, funcName string, args ...interface{}) (interface{}, error) {
+	if _, ok := p.functions[funcName]; !ok {
+		// reserved functions are not passed to middlewares, their results are returned
+		// as JSON string, which is transferred by all transports
+		var reserved interface{}
+		switch funcName {
+		case InfoFuncName:
+			reserved = BuildInfo()
+		case DescribeFuncName:
+			reserved = p.describe()
+		}
+		if reserved != nil {
+			content, err := json.Marshal(reserved)
+			return string(content), err
+		}
+	}
 	// notice: this is the actual place where plugin function is called
 	p.logger.Debug("plugin function execution", "funcName", funcName, "args", args)
+	dispatch := func(funcName string, args ...interface{}) (interface{}, error) {
+		return p.dispatch(ctx, funcName, args...)
+	}
+	return chainMiddlewares(dispatch)(funcName, args...)
+}
 
+// dispatch calls registered plugin function, it is wrapped by middlewares.
+// ctx is passed as the first argument to functions declaring context.Context parameter.
+func (p *functionPlugin) dispatch(ctx context.Context, funcName string, args ...interface{}) (result interface{}, err error) {
 	fn, ok := p.functions[funcName]
 	if !ok {
 		return nil, fmt.Errorf("function %s not found", funcName)
 	}
+	if fnType := fn.Type(); fnType.NumIn() > 0 && fnType.In(0) == contextType {
+		args = append([]interface{}{ctx}, args...)
+	}
 
+	// recover panics, thus plugin keeps serving later calls
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, handlePanic(funcName, r)
+		}
+	}()
 	return CallFunc(fn, args...)
 }
 
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
 var functions = make(functionsMap)
 
 // Register registers a plugin function.
@@ -53,6 +101,35 @@ func Register(funcName string, fn interface{}) {
 	functions[ConvertCommonName(funcName)] = functions[funcName]
 }
 
+// RegisterStruct registers all exported methods of struct v as plugin functions,
+// named <name>.<Method>, or <Method> if name is empty.
+// Methods are bound to v, thus plugin functions can keep state in v instead of
+// global variables; pass a pointer to register methods with pointer receiver.
+func RegisterStruct(name string, v interface{}) {
+	value := reflect.ValueOf(v)
+	structType := value.Type()
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		logger.Error("register struct failed, not a struct", "name", name, "type", value.Type())
+		return
+	}
+	if value.Kind() != reflect.Ptr && reflect.PtrTo(structType).NumMethod() > structType.NumMethod() {
+		logger.Warn("methods with pointer receiver are not registered, pass a pointer instead",
+			"name", name, "type", structType)
+	}
+
+	for i := 0; i < value.NumMethod(); i++ {
+		methodName := value.Type().Method(i).Name
+		funcName := methodName
+		if name != "" {
+			funcName = name + "." + methodName
+		}
+		Register(funcName, value.Method(i).Interface())
+	}
+}
+
 // serveRPC starts a plugin server process in RPC mode.
 func serveRPC() {
 	rpcPluginName := "rpc"
@@ -80,7 +157,7 @@ func serveGRPC() {
 		functions: functions,
 	}
 	var pluginMap = map[string]plugin.Plugin{
-		grpcPluginName: &GRPCPlugin{Impl: funcPlugin},
+		grpcPluginName: &GRPCPlugin{Impl: funcPlugin, NumberMode: pluginNumberMode()},
 	}
 	// start gRPC server
 	plugin.Serve(&plugin.ServeConfig{
@@ -90,12 +167,59 @@ func serveGRPC() {
 	})
 }
 
-// default to run plugin in gRPC mode
+// Serve starts plugin server, default to run plugin in gRPC mode.
+// It returns after host quits the plugin, see ServeContext.
 func Serve() {
-	if os.Getenv(PluginTypeEnvName) == "rpc" {
-		serveRPC()
-	} else {
-		// default
-		serveGRPC()
+	if err := ServeContext(context.Background()); err != nil {
+		logger.Error("shutdown plugin server failed", "error", err)
+	}
+}
+
+// ServeContext starts plugin server and blocks until host quits the plugin,
+// ctx is canceled or SIGTERM is received, then calls hooks registered with
+// OnShutdown and returns the first hook error. Except for sidecar servers, plugin
+// server keeps running after it returns until plugin process exits, thus plugin
+// process should exit instead of serving again.
+// Plugin binary started with SelfTestFlag runs SelfTest and exits instead of serving.
+func ServeContext(ctx context.Context) error {
+	if selfTestRequested() {
+		runSelfTest()
+	}
+	// read secrets before serving, thus they are masked in logs of plugin functions
+	loadSecrets()
+	// read plugin config before serving, host closes windows named pipe after start timeout
+	loadConfig()
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGTERM)
+	defer stop()
+
+	if os.Getenv(SidecarAddrEnvName) != "" {
+		// sidecar server stops gracefully when ctx is done
+		serveSidecar(ctx)
+		return runShutdownHooks()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if os.Getenv(PluginTransportEnvName) == TransportStdio {
+			serveStdio()
+		} else if os.Getenv(PluginTransportEnvName) == TransportNamedPipe {
+			serveNamedPipe()
+		} else if os.Getenv(PluginTypeEnvName) == "rpc" {
+			serveRPC()
+		} else {
+			// default
+			serveGRPC()
+		}
+	}()
+
+	select {
+	case <-done:
+		logger.Info("plugin server stopped")
+	case <-ctx.Done():
+		// hashicorp and JSON-RPC servers can not be stopped,
+		// they are terminated when plugin process exits
+		logger.Info("plugin server canceled", "error", ctx.Err())
 	}
+	return runShutdownHooks()
 }
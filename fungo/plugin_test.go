@@ -0,0 +1,56 @@
+package fungo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type counter struct {
+	count int
+}
+
+func (c *counter) Incr(n int) int {
+	c.count += n
+	return c.count
+}
+
+func (c *counter) Reset() {
+	c.count = 0
+}
+
+func (c *counter) unexported() {}
+
+func TestRegisterStruct(t *testing.T) {
+	RegisterStruct("counter", &counter{})
+	defer func() {
+		for _, name := range []string{"counter.Incr", "counter.incr", "counter.Reset", "counter.reset"} {
+			delete(functions, name)
+		}
+	}()
+	assert.NotContains(t, functions, "counter.unexported")
+
+	p := &functionPlugin{logger: logger, functions: functions}
+	result, err := p.Call("counter.Incr", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, result)
+	// state is kept in receiver between calls
+	result, err = p.Call("counter.incr", 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, result)
+
+	_, err = p.Call("counter.Reset")
+	assert.Nil(t, err)
+	result, err = p.Call("counter.Incr", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, result)
+}
+
+func TestRegisterStructValue(t *testing.T) {
+	// methods with pointer receiver are not in method set of value
+	RegisterStruct("value", counter{})
+	assert.NotContains(t, functions, "value.Incr")
+
+	RegisterStruct("invalid", 1)
+	assert.NotContains(t, functions, "invalid")
+}
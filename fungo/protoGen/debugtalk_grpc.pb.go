@@ -24,6 +24,8 @@ const _ = grpc.SupportPackageIsVersion7
 type DebugTalkClient interface {
 	GetNames(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*GetNamesResponse, error)
 	Call(ctx context.Context, in *CallRequest, opts ...grpc.CallOption) (*CallResponse, error)
+	// multiplexes concurrent calls identified by call id
+	CallStream(ctx context.Context, opts ...grpc.CallOption) (DebugTalk_CallStreamClient, error)
 }
 
 type debugTalkClient struct {
@@ -52,12 +54,45 @@ func (c *debugTalkClient) Call(ctx context.Context, in *CallRequest, opts ...grp
 	return out, nil
 }
 
+func (c *debugTalkClient) CallStream(ctx context.Context, opts ...grpc.CallOption) (DebugTalk_CallStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &DebugTalk_ServiceDesc.Streams[0], "/proto.DebugTalk/CallStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &debugTalkCallStreamClient{stream}
+	return x, nil
+}
+
+type DebugTalk_CallStreamClient interface {
+	Send(*StreamRequest) error
+	Recv() (*StreamResponse, error)
+	grpc.ClientStream
+}
+
+type debugTalkCallStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *debugTalkCallStreamClient) Send(m *StreamRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *debugTalkCallStreamClient) Recv() (*StreamResponse, error) {
+	m := new(StreamResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // DebugTalkServer is the server API for DebugTalk service.
 // All implementations must embed UnimplementedDebugTalkServer
 // for forward compatibility
 type DebugTalkServer interface {
 	GetNames(context.Context, *Empty) (*GetNamesResponse, error)
 	Call(context.Context, *CallRequest) (*CallResponse, error)
+	// multiplexes concurrent calls identified by call id
+	CallStream(DebugTalk_CallStreamServer) error
 	mustEmbedUnimplementedDebugTalkServer()
 }
 
@@ -71,6 +106,9 @@ func (UnimplementedDebugTalkServer) GetNames(context.Context, *Empty) (*GetNames
 func (UnimplementedDebugTalkServer) Call(context.Context, *CallRequest) (*CallResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Call not implemented")
 }
+func (UnimplementedDebugTalkServer) CallStream(DebugTalk_CallStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method CallStream not implemented")
+}
 func (UnimplementedDebugTalkServer) mustEmbedUnimplementedDebugTalkServer() {}
 
 // UnsafeDebugTalkServer may be embedded to opt out of forward compatibility for this service.
@@ -120,6 +158,32 @@ func _DebugTalk_Call_Handler(srv interface{}, ctx context.Context, dec func(inte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _DebugTalk_CallStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(DebugTalkServer).CallStream(&debugTalkCallStreamServer{stream})
+}
+
+type DebugTalk_CallStreamServer interface {
+	Send(*StreamResponse) error
+	Recv() (*StreamRequest, error)
+	grpc.ServerStream
+}
+
+type debugTalkCallStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *debugTalkCallStreamServer) Send(m *StreamResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *debugTalkCallStreamServer) Recv() (*StreamRequest, error) {
+	m := new(StreamRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // DebugTalk_ServiceDesc is the grpc.ServiceDesc for DebugTalk service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -136,6 +200,13 @@ var DebugTalk_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _DebugTalk_Call_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "CallStream",
+			Handler:       _DebugTalk_CallStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
 	Metadata: "proto/debugtalk.proto",
 }
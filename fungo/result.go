@@ -0,0 +1,88 @@
+package fungo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// log levels of LogEntry
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+)
+
+// LogEntry is a log entry generated by plugin function during a call
+type LogEntry struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"` // debug, info, warn or error
+	Message string    `json:"message"`
+}
+
+// CallResult is the result of plugin function with non-fatal warnings and log entries
+// it generated, e.g. data-quality caveats which should not fail the call
+type CallResult struct {
+	Value    interface{}
+	Warnings []string
+	Logs     []LogEntry
+}
+
+// IDetailedCaller is implemented by host side gRPC clients, which return warnings
+// and log entries of plugin functions together with results
+type IDetailedCaller interface {
+	CallDetailed(funcName string, args ...interface{}) (*CallResult, error)
+}
+
+// IDetailedContextCaller is implemented by host side gRPC clients, combining IContextCaller,
+// IMetadataCaller and IDetailedCaller: the call carries call metadata md, is canceled when
+// ctx is done and returns warnings and log entries of plugin function with result
+type IDetailedContextCaller interface {
+	CallDetailedContext(ctx context.Context, md map[string]string, funcName string, args ...interface{}) (*CallResult, error)
+}
+
+// callDetails collects warnings and log entries of a call on plugin side,
+// plugin functions may report them from multiple goroutines
+type callDetails struct {
+	mu       sync.Mutex
+	warnings []string
+	logs     []LogEntry
+}
+
+type callDetailsContextKey struct{}
+
+// withCallDetails returns ctx collecting warnings and log entries to details
+func withCallDetails(ctx context.Context, details *callDetails) context.Context {
+	return context.WithValue(ctx, callDetailsContextKey{}, details)
+}
+
+// AddWarning reports non-fatal warning of current call to host, which is returned by
+// IPlugin.CallDetailed. ctx is the context.Context declared as the first parameter of
+// plugin function; warnings are dropped if the call fails or transport is not gRPC.
+func AddWarning(ctx context.Context, format string, args ...interface{}) {
+	details, ok := ctx.Value(callDetailsContextKey{}).(*callDetails)
+	if !ok {
+		return
+	}
+	details.mu.Lock()
+	defer details.mu.Unlock()
+	details.warnings = append(details.warnings, Mask(fmt.Sprintf(format, args...)))
+}
+
+// AddLog reports log entry of current call to host, which is returned by IPlugin.CallDetailed.
+// It is dropped like warnings of AddWarning if the call fails or transport is not gRPC.
+func AddLog(ctx context.Context, level string, format string, args ...interface{}) {
+	details, ok := ctx.Value(callDetailsContextKey{}).(*callDetails)
+	if !ok {
+		return
+	}
+	details.mu.Lock()
+	defer details.mu.Unlock()
+	details.logs = append(details.logs, LogEntry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: Mask(fmt.Sprintf(format, args...)),
+	})
+}
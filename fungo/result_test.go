@@ -0,0 +1,47 @@
+package fungo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lingcetech/funplugin/fungo/protoGen"
+)
+
+func TestCallDetails(t *testing.T) {
+	Register("parse_rows", func(ctx context.Context, rows []interface{}) int {
+		for i, row := range rows {
+			if row == "" {
+				AddWarning(ctx, "row %d is empty", i)
+			}
+		}
+		AddLog(ctx, LogLevelInfo, "parsed %d rows", len(rows))
+		return len(rows)
+	})
+	defer func() {
+		delete(functions, "parse_rows")
+	}()
+
+	server := &functionGRPCServer{Impl: &functionPlugin{logger: logger, functions: functions}}
+	resp, err := server.Call(context.Background(), &protoGen.CallRequest{
+		Name: "parse_rows",
+		Args: []byte(`[["a", "", "c"]]`),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "3", string(resp.Value))
+	assert.Equal(t, []string{"row 1 is empty"}, resp.Warnings)
+
+	var logs []LogEntry
+	assert.Nil(t, json.Unmarshal(resp.Logs, &logs))
+	assert.Len(t, logs, 1)
+	assert.Equal(t, LogLevelInfo, logs[0].Level)
+	assert.Equal(t, "parsed 3 rows", logs[0].Message)
+	assert.False(t, logs[0].Time.IsZero())
+
+	// warnings are ignored outside gRPC calls
+	p := &functionPlugin{logger: logger, functions: functions}
+	result, err := p.Call("parse_rows", []interface{}{""})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, result)
+}
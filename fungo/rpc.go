@@ -2,13 +2,20 @@ package fungo
 
 import (
 	"encoding/gob"
+	"math/big"
 	"net/rpc"
+	"time"
 
 	"github.com/hashicorp/go-plugin"
 )
 
 func init() {
 	gob.Register(new(funcData))
+	// arbitrary-precision and time values in function arguments and results
+	gob.Register(new(big.Int))
+	gob.Register(new(Decimal))
+	gob.Register(time.Time{})
+	gob.Register(time.Duration(0))
 }
 
 // funcData is used to transfer between plugin and host via RPC.
@@ -51,7 +58,7 @@ func (g *functionRPCClient) Call(funcName string, funcArgs ...interface{}) (inte
 			"funcArgs", funcArgs,
 			"error", err,
 		)
-		return nil, err
+		return nil, parseUserError(err)
 	}
 	logger.Info("rpc_client Call() success", "result", resp)
 	return resp, nil
@@ -0,0 +1,113 @@
+package fungo
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// PluginSecretsFDEnvName is the inherited file descriptor number which host
+	// writes secrets to, secrets are never passed in argv or env
+	PluginSecretsFDEnvName = "HRP_PLUGIN_SECRETS_FD"
+	// PluginSecretsPipeEnvName is the windows named pipe path which host writes secrets to
+	PluginSecretsPipeEnvName = "HRP_PLUGIN_SECRETS_PIPE"
+)
+
+// secretMask replaces secret values in logs
+const secretMask = "******"
+
+var (
+	secretsOnce  sync.Once
+	secrets      map[string]string
+	maskMutex    sync.RWMutex
+	maskedValues []string
+)
+
+// MaskSecrets registers secret values which are masked in all logs written by fungo logger
+func MaskSecrets(values ...string) {
+	maskMutex.Lock()
+	defer maskMutex.Unlock()
+	for _, value := range values {
+		if value != "" {
+			maskedValues = append(maskedValues, value)
+		}
+	}
+}
+
+// Mask replaces registered secret values in s
+func Mask(s string) string {
+	maskMutex.RLock()
+	defer maskMutex.RUnlock()
+	for _, value := range maskedValues {
+		s = strings.ReplaceAll(s, value, secretMask)
+	}
+	return s
+}
+
+// maskWriter masks secret values in log lines, hclog writes a line at a time
+type maskWriter struct {
+	w io.Writer
+}
+
+func (m *maskWriter) Write(p []byte) (int, error) {
+	if _, err := m.w.Write([]byte(Mask(string(p)))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Secret returns secret passed by host with funplugin.WithSecrets
+func Secret(name string) (string, bool) {
+	loadSecrets()
+	value, ok := secrets[name]
+	return value, ok
+}
+
+// loadSecrets reads secrets written by host once, and masks them in plugin logs
+func loadSecrets() {
+	secretsOnce.Do(func() {
+		r, err := openHostPipe(PluginSecretsFDEnvName, PluginSecretsPipeEnvName, "secrets")
+		if err != nil {
+			logger.Error("open secrets pipe failed", "error", err)
+			return
+		}
+		if r == nil {
+			return
+		}
+		defer r.Close()
+
+		if err := json.NewDecoder(r).Decode(&secrets); err != nil {
+			logger.Error("read secrets failed", "error", err)
+			return
+		}
+		for _, value := range secrets {
+			MaskSecrets(value)
+		}
+		logger.Info("load secrets from host", "count", len(secrets))
+	})
+}
+
+// openHostPipe opens inherited file descriptor or windows named pipe which host writes to,
+// it returns nil reader if neither is specified in env
+func openHostPipe(fdEnvName, pipeEnvName, name string) (io.ReadCloser, error) {
+	if fd := os.Getenv(fdEnvName); fd != "" {
+		n, err := strconv.Atoi(fd)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s file descriptor %s", name, fd)
+		}
+		return os.NewFile(uintptr(n), name), nil
+	}
+	if path := os.Getenv(pipeEnvName); path != "" {
+		conn, err := dialPipe(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "connect %s pipe failed", name)
+		}
+		return conn, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,23 @@
+package fungo
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMaskWriter(t *testing.T) {
+	MaskSecrets("s3cr3t", "")
+	var buf bytes.Buffer
+	w := &maskWriter{&buf}
+	line := []byte("[INFO] token=s3cr3t\n")
+	n, err := w.Write(line)
+	if err != nil || n != len(line) {
+		t.Fatalf("unexpected write result: %d, %v", n, err)
+	}
+	if buf.String() != "[INFO] token=******\n" {
+		t.Fatalf("secret not masked: %s", buf.String())
+	}
+	if Mask("no secret") != "no secret" {
+		t.Fatal("unexpected mask")
+	}
+}
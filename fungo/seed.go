@@ -0,0 +1,84 @@
+package fungo
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SeedEnvName is used to pass random seed of deterministic mode set by funplugin.WithSeed
+const SeedEnvName = "HRP_PLUGIN_SEED"
+
+// Seed returns random seed passed by host with funplugin.WithSeed,
+// ok is false if host does not run plugin in deterministic mode
+func Seed() (seed int64, ok bool) {
+	content := os.Getenv(SeedEnvName)
+	if content == "" {
+		return 0, false
+	}
+	seed, err := strconv.ParseInt(content, 10, 64)
+	if err != nil {
+		logger.Error("decode random seed failed", "seed", content, "error", err)
+		return 0, false
+	}
+	return seed, true
+}
+
+var (
+	randOnce   sync.Once
+	sharedRand *rand.Rand
+)
+
+// Rand returns random source shared by plugin functions, seeded with Seed in deterministic
+// mode, otherwise with current time. It is safe for concurrent use except Read, while
+// generated values are reproducible only for the same order of calls.
+func Rand() *rand.Rand {
+	randOnce.Do(func() {
+		seed, ok := Seed()
+		if !ok {
+			seed = time.Now().UnixNano()
+		}
+		sharedRand = rand.New(&lockedSource{src: rand.NewSource(seed).(rand.Source64)})
+	})
+	return sharedRand
+}
+
+// NewRand returns random source seeded with Seed and key, e.g. test case id, thus values
+// generated for the same key are reproducible regardless of order of calls. It is seeded
+// with current time if host does not run plugin in deterministic mode.
+func NewRand(key string) *rand.Rand {
+	seed, ok := Seed()
+	if !ok {
+		return rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return rand.New(rand.NewSource(seed ^ int64(h.Sum64())))
+}
+
+// lockedSource is random source safe for concurrent use
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source64
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
@@ -0,0 +1,33 @@
+package fungo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeed(t *testing.T) {
+	t.Setenv(SeedEnvName, "")
+	_, ok := Seed()
+	assert.False(t, ok)
+
+	t.Setenv(SeedEnvName, "not-a-number")
+	_, ok = Seed()
+	assert.False(t, ok)
+
+	t.Setenv(SeedEnvName, "42")
+	seed, ok := Seed()
+	assert.True(t, ok)
+	assert.Equal(t, int64(42), seed)
+}
+
+func TestNewRand(t *testing.T) {
+	t.Setenv(SeedEnvName, "42")
+	assert.Equal(t, NewRand("TC-1").Int63(), NewRand("TC-1").Int63())
+	assert.NotEqual(t, NewRand("TC-1").Int63(), NewRand("TC-2").Int63())
+
+	t.Setenv(SeedEnvName, "7")
+	first := NewRand("TC-1").Int63()
+	t.Setenv(SeedEnvName, "42")
+	assert.NotEqual(t, first, NewRand("TC-1").Int63())
+}
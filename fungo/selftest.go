@@ -0,0 +1,181 @@
+package fungo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SelfTestFlag makes plugin binary run SelfTest instead of serving host, e.g.
+// `./debugtalk.bin --self-test` in CI smoke-tests a built plugin without a host
+const SelfTestFlag = "--self-test"
+
+// selfTestTimeout cancels context of each sample call during self-test
+const selfTestTimeout = 30 * time.Second
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// samples stores sample arguments of plugin functions for self-test
+var samples = make(map[string][][]interface{})
+
+// RegisterSample registers sample arguments of plugin function, which is called with them
+// during self-test; register multiple samples by calling it repeatedly. Arguments are
+// transferred through JSON like calls of host, e.g. integers are received as float64.
+func RegisterSample(funcName string, args ...interface{}) {
+	samples[funcName] = append(samples[funcName], args)
+}
+
+// selfTestRequested reports whether plugin binary is started with SelfTestFlag
+func selfTestRequested() bool {
+	for _, arg := range os.Args[1:] {
+		if arg == SelfTestFlag {
+			return true
+		}
+	}
+	return false
+}
+
+// runSelfTest runs SelfTest and exits plugin process with its status
+func runSelfTest() {
+	if err := SelfTest(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// SelfTest validates registration of plugin functions and calls each of them with its
+// samples registered with RegisterSample, writing results to w. Functions without samples
+// are skipped. It returns error if any registration is invalid or any sample call fails.
+func SelfTest(w io.Writer) error {
+	p := &functionPlugin{logger: logger.Named("self_test"), functions: functions}
+	return p.selfTest(w, samples)
+}
+
+func (p *functionPlugin) selfTest(w io.Writer, samples map[string][][]interface{}) error {
+	names := p.registeredNames()
+	var passed, failed, skipped int
+
+	for _, name := range names {
+		if err := validateFunction(p.functions[name]); err != nil {
+			fmt.Fprintf(w, "FAIL %s: invalid registration, %v\n", name, err)
+			failed++
+			continue
+		}
+		funcSamples := samples[name]
+		if len(funcSamples) == 0 {
+			fmt.Fprintf(w, "SKIP %s: no samples, register with fungo.RegisterSample\n", name)
+			skipped++
+			continue
+		}
+		for _, args := range funcSamples {
+			if err := p.callSample(w, name, args); err != nil {
+				failed++
+			} else {
+				passed++
+			}
+		}
+	}
+	var missing []string
+	for funcName := range samples {
+		if _, ok := p.functions[funcName]; !ok {
+			missing = append(missing, funcName)
+		}
+	}
+	sort.Strings(missing)
+	for _, funcName := range missing {
+		fmt.Fprintf(w, "FAIL %s: samples registered for function not found\n", funcName)
+		failed++
+	}
+
+	fmt.Fprintf(w, "self-test: %d functions, %d passed, %d failed, %d skipped\n",
+		len(names), passed, failed, skipped)
+	if failed > 0 {
+		return fmt.Errorf("self-test failed: %d failed", failed)
+	}
+	return nil
+}
+
+// callSample calls plugin function with sample arguments transferred through JSON like host
+func (p *functionPlugin) callSample(w io.Writer, funcName string, args []interface{}) error {
+	content, err := json.Marshal(encodeValue(args))
+	if err != nil {
+		fmt.Fprintf(w, "FAIL %s: marshal sample arguments failed, %v\n", funcName, err)
+		return err
+	}
+	var funcArgs []interface{}
+	if err := unmarshalJSON(content, &funcArgs, pluginNumberMode()); err != nil {
+		fmt.Fprintf(w, "FAIL %s%s: unmarshal sample arguments failed, %v\n", funcName, content, err)
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), selfTestTimeout)
+	defer cancel()
+	details := &callDetails{}
+	start := time.Now()
+	result, err := p.CallContext(withCallDetails(ctx, details), funcName, funcArgs...)
+	elapsed := time.Since(start)
+	if err != nil {
+		fmt.Fprintf(w, "FAIL %s%s: %v\n", funcName, content, err)
+		return err
+	}
+	value, err := json.Marshal(encodeValue(result))
+	if err != nil {
+		fmt.Fprintf(w, "FAIL %s%s: result is not serializable, %v\n", funcName, content, err)
+		return err
+	}
+	fmt.Fprintf(w, "PASS %s%s => %s (%v)\n", funcName, content, value, elapsed)
+	for _, warning := range details.warnings {
+		fmt.Fprintf(w, "     warning: %s\n", warning)
+	}
+	return nil
+}
+
+// registeredNames returns sorted names of registered functions,
+// excluding common names registered automatically
+func (p *functionPlugin) registeredNames() []string {
+	aliases := make(map[string]bool)
+	for name := range p.functions {
+		if commonName := ConvertCommonName(name); commonName != name {
+			aliases[commonName] = true
+		}
+	}
+	var names []string
+	for name := range p.functions {
+		if !aliases[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateFunction checks registered value is a function callable by host
+func validateFunction(fn reflect.Value) error {
+	if !fn.IsValid() || fn.Kind() != reflect.Func {
+		return fmt.Errorf("not a function")
+	}
+	if fn.IsNil() {
+		return fmt.Errorf("nil function")
+	}
+	fnType := fn.Type()
+	switch fnType.NumOut() {
+	case 0, 1:
+	case 2:
+		if !fnType.Out(1).Implements(errorType) {
+			return fmt.Errorf("second result should be error, got %v", fnType.Out(1))
+		}
+	default:
+		outs := make([]string, fnType.NumOut())
+		for i := range outs {
+			outs[i] = fnType.Out(i).String()
+		}
+		return fmt.Errorf("function should return at most 2 values, got (%s)", strings.Join(outs, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package fungo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelfTest(t *testing.T) {
+	p := &functionPlugin{logger: logger, functions: functionsMap{
+		"sum_two_int": reflect.ValueOf(func(a, b int) int { return a + b }),
+		"sumtwoint":   reflect.ValueOf(func(a, b int) int { return a + b }),
+		"divide": reflect.ValueOf(func(a, b float64) (float64, error) {
+			if b == 0 {
+				return 0, errors.New("division by zero")
+			}
+			return a / b, nil
+		}),
+		"parse": reflect.ValueOf(func(ctx context.Context, s string) string {
+			AddWarning(ctx, "%s is empty", "row 1")
+			return s
+		}),
+		"teardown":    reflect.ValueOf(func() {}),
+		"not_func":    reflect.ValueOf(1),
+		"three_value": reflect.ValueOf(func() (int, int, error) { return 0, 0, nil }),
+	}}
+	var w bytes.Buffer
+	err := p.selfTest(&w, map[string][][]interface{}{
+		"sum_two_int": {{1, 2}},
+		"divide":      {{1, 2}, {1, 0}},
+		"parse":       {{"abc"}},
+		"missing":     {{}},
+	})
+	assert.EqualError(t, err, "self-test failed: 4 failed")
+
+	output := w.String()
+	assert.Contains(t, output, "PASS sum_two_int[1,2] => 3")
+	assert.Contains(t, output, "PASS divide[1,2] => 0.5")
+	assert.Contains(t, output, "FAIL divide[1,0]: division by zero")
+	assert.Contains(t, output, "PASS parse[\"abc\"] => \"abc\"")
+	assert.Contains(t, output, "warning: row 1 is empty")
+	assert.Contains(t, output, "SKIP teardown: no samples")
+	assert.Contains(t, output, "FAIL not_func: invalid registration, not a function")
+	assert.Contains(t, output, "FAIL three_value: invalid registration, function should return at most 2 values")
+	assert.Contains(t, output, "FAIL missing: samples registered for function not found")
+	assert.NotContains(t, output, "sumtwoint")
+	assert.Contains(t, output, "self-test: 6 functions, 3 passed, 4 failed, 1 skipped")
+}
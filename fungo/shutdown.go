@@ -0,0 +1,52 @@
+package fungo
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ShutdownTimeout limits the time of running shutdown hooks,
+// host kills plugin process not exited in 2 seconds after quitting it
+var ShutdownTimeout = 2 * time.Second
+
+var (
+	shutdownMutex sync.Mutex
+	shutdownHooks []func(ctx context.Context) error
+)
+
+// OnShutdown registers hook called when plugin server stops serving, e.g. to close
+// DB pools or flush logs before plugin process exits. Hooks are called in reverse
+// order of registration like defer, with ctx limited by ShutdownTimeout.
+// Each hook is called once, register it again before serving again.
+func OnShutdown(hook func(ctx context.Context) error) {
+	shutdownMutex.Lock()
+	defer shutdownMutex.Unlock()
+	shutdownHooks = append(shutdownHooks, hook)
+}
+
+// runShutdownHooks calls and clears registered hooks, returns the first hook error
+func runShutdownHooks() error {
+	shutdownMutex.Lock()
+	hooks := shutdownHooks
+	shutdownHooks = nil
+	shutdownMutex.Unlock()
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	logger.Info("run shutdown hooks", "count", len(hooks))
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+
+	var firstErr error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i](ctx); err != nil {
+			logger.Error("run shutdown hook failed", "error", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
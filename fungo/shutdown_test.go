@@ -0,0 +1,37 @@
+package fungo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeContextShutdownHooks(t *testing.T) {
+	t.Setenv(SidecarAddrEnvName, "127.0.0.1:0")
+
+	// serve again after shutdown, hooks are called once per serving
+	for round := 0; round < 2; round++ {
+		var calls []string
+		OnShutdown(func(ctx context.Context) error {
+			calls = append(calls, "close db")
+			return errors.New("close db failed")
+		})
+		OnShutdown(func(ctx context.Context) error {
+			_, ok := ctx.Deadline()
+			assert.True(t, ok)
+			calls = append(calls, "flush logs")
+			return nil
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(100*time.Millisecond, cancel)
+		err := ServeContext(ctx)
+		assert.EqualError(t, err, "close db failed", "round %d", round)
+		assert.Equal(t, []string{"flush logs", "close db"}, calls, "round %d", round)
+	}
+
+	assert.Nil(t, runShutdownHooks())
+}
@@ -0,0 +1,97 @@
+package fungo
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/lingcetech/funplugin/fungo/protoGen"
+)
+
+// SidecarAddrEnvName specifies gRPC address of plugin running as sidecar,
+// plugin server listens on it, e.g. 0.0.0.0:50051, and host connects to it, e.g. 127.0.0.1:50051
+const SidecarAddrEnvName = "HRP_PLUGIN_SIDECAR_ADDR"
+
+// HealthAddrEnvName specifies HTTP address serving /healthz and /readyz for sidecar plugin
+const HealthAddrEnvName = "HRP_PLUGIN_HEALTH_ADDR"
+
+// serveSidecar serves plugin functions over plain gRPC on a fixed address without
+// hashicorp handshake, which is required when running as kubernetes sidecar.
+// It returns after server is stopped gracefully when ctx is done or pod is terminating.
+func serveSidecar(ctx context.Context) {
+	addr := os.Getenv(SidecarAddrEnvName)
+	logger.Info("start plugin server in sidecar mode", "addr", addr)
+	funcPlugin := &functionPlugin{
+		logger:    logger.Named("func_exec"),
+		functions: functions,
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		logger.Error("listen sidecar address failed", "addr", addr, "error", err)
+		os.Exit(1)
+	}
+
+	server := grpc.NewServer()
+	protoGen.RegisterDebugTalkServer(server, &functionGRPCServer{
+		Impl:       funcPlugin,
+		NumberMode: pluginNumberMode(),
+	})
+	// standard gRPC health service for kubernetes gRPC probes
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+
+	var ready int32
+	if healthAddr := os.Getenv(HealthAddrEnvName); healthAddr != "" {
+		go serveHealth(healthAddr, &ready)
+	}
+
+	// stop gracefully when pod is terminating or ctx is done
+	go func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(ch)
+		select {
+		case <-ch:
+		case <-ctx.Done():
+		}
+		logger.Info("stop sidecar plugin server")
+		atomic.StoreInt32(&ready, 0)
+		healthServer.Shutdown()
+		server.GracefulStop()
+	}()
+
+	atomic.StoreInt32(&ready, 1)
+	if err := server.Serve(listener); err != nil {
+		logger.Error("serve sidecar plugin failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+// serveHealth serves liveness endpoint /healthz and readiness endpoint /readyz
+func serveHealth(addr string, ready *int32) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(ready) == 0 {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+
+	logger.Info("serve sidecar health endpoints", "addr", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		logger.Error("serve health endpoints failed", "addr", addr, "error", err)
+	}
+}
@@ -0,0 +1,175 @@
+package fungo
+
+import (
+	stdjson "encoding/json"
+	"fmt"
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// PluginTransportEnvName is used to specify plugin transport, stdio means
+// JSON-RPC over plugin process stdin/stdout without listening sockets
+const PluginTransportEnvName = "HRP_PLUGIN_TRANSPORT"
+
+// PluginPipeEnvName is used to pass named pipe path to plugin process
+// when the plugin transport is npipe
+const PluginPipeEnvName = "HRP_PLUGIN_PIPE"
+
+const (
+	TransportStdio     = "stdio"
+	TransportNamedPipe = "npipe" // windows named pipe, avoids localhost TCP listeners
+)
+
+// stdioConn joins plugin process stdin and stdout as JSON-RPC connection
+type stdioConn struct {
+	io.Reader
+	io.WriteCloser
+}
+
+// NewStdioConn returns connection reading from r and writing to w
+func NewStdioConn(r io.Reader, w io.WriteCloser) io.ReadWriteCloser {
+	return &stdioConn{Reader: r, WriteCloser: w}
+}
+
+// functionStdioClient runs on the host side, it implements FuncCaller interface
+type functionStdioClient struct {
+	client     *rpc.Client
+	numberMode NumberMode
+}
+
+// NewStdioClient returns JSON-RPC function caller over plugin process stdio,
+// JSON numbers in results are decoded in numberMode
+func NewStdioClient(conn io.ReadWriteCloser, numberMode NumberMode) IFuncCaller {
+	return &functionStdioClient{client: jsonrpc.NewClient(conn), numberMode: numberMode}
+}
+
+func (c *functionStdioClient) GetNames() ([]string, error) {
+	logger.Debug("stdio_client GetNames() start")
+	var resp []string
+	err := c.client.Call("Plugin.GetNames", []interface{}{}, &resp)
+	if err != nil {
+		logger.Error("stdio_client GetNames() failed", "error", err)
+		return nil, err
+	}
+	logger.Debug("stdio_client GetNames() success")
+	return resp, nil
+}
+
+// host -> plugin, params is [funcName, args...]
+func (c *functionStdioClient) Call(funcName string, funcArgs ...interface{}) (interface{}, error) {
+	logger.Info("stdio_client Call() start", "funcName", funcName, "funcArgs", funcArgs)
+	params := append([]interface{}{funcName}, encodeValue(funcArgs).([]interface{})...)
+
+	var raw stdjson.RawMessage
+	err := c.client.Call("Plugin.Call", params, &raw)
+	if err != nil {
+		logger.Error("stdio_client Call() failed",
+			"funcName", funcName,
+			"funcArgs", funcArgs,
+			"error", err,
+		)
+		return nil, parseUserError(err)
+	}
+	var resp interface{}
+	if err := unmarshalJSON(raw, &resp, c.numberMode); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal Call() response")
+	}
+	logger.Info("stdio_client Call() success", "result", resp)
+	return resp, nil
+}
+
+func (c *functionStdioClient) Close() error {
+	return c.client.Close()
+}
+
+// functionStdioServer runs on the plugin side, executing the user custom function.
+type functionStdioServer struct {
+	Impl       IFuncCaller
+	NumberMode NumberMode
+}
+
+// plugin execution
+func (s *functionStdioServer) GetNames(args []interface{}, resp *[]string) error {
+	logger.Debug("stdio_server GetNames() start")
+	var err error
+	*resp, err = s.Impl.GetNames()
+	if err != nil {
+		logger.Error("stdio_server GetNames() failed", "error", err)
+		return err
+	}
+	logger.Debug("stdio_server GetNames() success")
+	return nil
+}
+
+// plugin execution, params are decoded raw to handle JSON numbers in NumberMode
+func (s *functionStdioServer) Call(params []stdjson.RawMessage, resp *interface{}) error {
+	logger.Debug("stdio_server Call() start")
+	if len(params) == 0 {
+		return fmt.Errorf("function name missing")
+	}
+	var funcName string
+	if err := json.Unmarshal(params[0], &funcName); err != nil {
+		return fmt.Errorf("invalid function name: %s", params[0])
+	}
+	funcArgs := make([]interface{}, len(params)-1)
+	for i, param := range params[1:] {
+		if err := unmarshalJSON(param, &funcArgs[i], s.NumberMode); err != nil {
+			return errors.Wrapf(err, "invalid function argument %d", i)
+		}
+	}
+
+	var err error
+	*resp, err = s.Impl.Call(funcName, funcArgs...)
+	if err != nil {
+		logger.Error("stdio_server Call() failed", "params", params, "error", err)
+		return err
+	}
+	*resp = encodeValue(*resp)
+	logger.Debug("stdio_server Call() success")
+	return nil
+}
+
+// serveStdio starts a plugin server process speaking JSON-RPC over stdin/stdout.
+func serveStdio() {
+	logger.Info("start plugin server in stdio mode")
+	// keep stdout for JSON-RPC, prints of plugin functions go to stderr
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = stdout }()
+	serveJSONRPC(NewStdioConn(os.Stdin, stdout))
+}
+
+// serveNamedPipe connects to the named pipe created by host and speaks
+// JSON-RPC over it, the pipe path is passed in env HRP_PLUGIN_PIPE.
+func serveNamedPipe() {
+	pipePath := os.Getenv(PluginPipeEnvName)
+	logger.Info("start plugin server in named pipe mode", "pipe", pipePath)
+	conn, err := dialPipe(pipePath)
+	if err != nil {
+		logger.Error("connect named pipe failed", "pipe", pipePath, "error", err)
+		os.Exit(1)
+	}
+	serveJSONRPC(conn)
+}
+
+// serveJSONRPC serves plugin functions on conn, blocks until host closes conn
+func serveJSONRPC(conn io.ReadWriteCloser) {
+	funcPlugin := &functionPlugin{
+		logger:    logger.Named("func_exec"),
+		functions: functions,
+	}
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("Plugin", &functionStdioServer{
+		Impl:       funcPlugin,
+		NumberMode: pluginNumberMode(),
+	}); err != nil {
+		logger.Error("register stdio plugin server failed", "error", err)
+		os.Exit(1)
+	}
+	server.ServeCodec(jsonrpc.NewServerCodec(conn))
+}
@@ -0,0 +1,47 @@
+package fungo
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeStdioPrints(t *testing.T) {
+	Register("print_hello", func() string {
+		fmt.Println("hello")
+		return "world"
+	})
+	defer func() {
+		delete(functions, "print_hello")
+		delete(functions, "printhello")
+	}()
+
+	stdinReader, stdinWriter, err := os.Pipe()
+	assert.Nil(t, err)
+	stdoutReader, stdoutWriter, err := os.Pipe()
+	assert.Nil(t, err)
+	stdin, stdout := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = stdinReader, stdoutWriter
+	defer func() {
+		os.Stdin, os.Stdout = stdin, stdout
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		serveStdio()
+	}()
+
+	// prints of plugin functions do not break JSON-RPC over stdout
+	client := NewStdioClient(NewStdioConn(stdoutReader, stdinWriter), NumberFloat64)
+	result, err := client.Call("print_hello")
+	assert.Nil(t, err)
+	assert.Equal(t, "world", result)
+
+	// stdout is restored after host closes stdin
+	assert.Nil(t, stdinWriter.Close())
+	<-done
+	assert.Equal(t, stdoutWriter, os.Stdout)
+}
@@ -0,0 +1,227 @@
+package fungo
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/lingcetech/funplugin/fungo/protoGen"
+)
+
+// callStream multiplexes concurrent calls of host on a CallStream gRPC stream,
+// responses are dispatched to waiting calls by call id
+type callStream struct {
+	stream protoGen.DebugTalk_CallStreamClient
+	sendMu sync.Mutex // stream.Send is not safe for concurrent use
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan *protoGen.StreamResponse
+	err     error // error breaking stream, later calls fail with it
+}
+
+func newCallStream(client protoGen.DebugTalkClient) (*callStream, error) {
+	stream, err := client.CallStream(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	s := &callStream{stream: stream, pending: make(map[uint64]chan *protoGen.StreamResponse)}
+	go s.receive()
+	return s, nil
+}
+
+// receive dispatches responses until stream breaks, then fails pending calls
+func (s *callStream) receive() {
+	for {
+		resp, err := s.stream.Recv()
+		if err != nil {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.err = err
+			for id, ch := range s.pending {
+				close(ch)
+				delete(s.pending, id)
+			}
+			return
+		}
+		s.mu.Lock()
+		ch, ok := s.pending[resp.Id]
+		delete(s.pending, resp.Id)
+		s.mu.Unlock()
+		if ok {
+			ch <- resp
+		}
+	}
+}
+
+func (s *callStream) broken() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err != nil
+}
+
+func (s *callStream) send(req *protoGen.StreamRequest) error {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	return s.stream.Send(req)
+}
+
+// call sends call request and waits for its response, plugin is requested to cancel
+// the call when ctx is done. Returned error is error of stream instead of the call.
+func (s *callStream) call(ctx context.Context, req *protoGen.CallRequest) (*protoGen.StreamResponse, error) {
+	s.mu.Lock()
+	if s.err != nil {
+		s.mu.Unlock()
+		return nil, s.err
+	}
+	s.nextID++
+	id := s.nextID
+	ch := make(chan *protoGen.StreamResponse, 1)
+	s.pending[id] = ch
+	s.mu.Unlock()
+
+	// io.EOF means stream is broken, whose error is received by receive
+	if err := s.send(&protoGen.StreamRequest{Id: id, Call: req}); err != nil && err != io.EOF {
+		s.mu.Lock()
+		delete(s.pending, id)
+		s.mu.Unlock()
+		return nil, err
+	}
+
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return nil, s.err
+		}
+		return resp, nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		delete(s.pending, id)
+		s.mu.Unlock()
+		if err := s.send(&protoGen.StreamRequest{Id: id, Cancel: true}); err != nil {
+			logger.Warn("send cancel request failed", "funcName", req.Name, "error", err)
+		}
+		s := status.FromContextError(ctx.Err())
+		return &protoGen.StreamResponse{Id: id, Code: uint32(s.Code()), Error: s.Message()}, nil
+	}
+}
+
+// streamCaller returns call stream, or nil if plugin does not implement CallStream
+func (m *functionGRPCClient) streamCaller() (*callStream, error) {
+	m.streamMu.Lock()
+	defer m.streamMu.Unlock()
+	if m.noStream {
+		return nil, nil
+	}
+	if m.stream == nil || m.stream.broken() {
+		stream, err := newCallStream(m.client)
+		if err != nil {
+			return nil, err
+		}
+		m.stream = stream
+	}
+	return m.stream, nil
+}
+
+// CallContext calls plugin function multiplexed on a stream shared by concurrent calls,
+// plugin is requested to cancel the call when ctx is done, thus ctx of plugin function
+// declaring context.Context is canceled. Plugins built with older fungo or funppy
+// without CallStream are called with unary gRPC calls, which are canceled as well.
+func (m *functionGRPCClient) CallContext(ctx context.Context, funcName string, funcArgs ...interface{}) (interface{}, error) {
+	stream, err := m.streamCaller()
+	if err != nil || stream == nil {
+		return m.callValue(ctx, funcName, funcArgs...)
+	}
+	logger.Info("gRPC_client CallStream() start", "funcName", funcName, "funcArgs", funcArgs)
+	req, err := newCallRequest(funcName, funcArgs)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := stream.call(ctx, req)
+	if status.Code(err) == codes.Unimplemented {
+		logger.Debug("plugin does not support call stream, use unary calls")
+		m.streamMu.Lock()
+		m.noStream = true
+		m.streamMu.Unlock()
+		return m.callValue(ctx, funcName, funcArgs...)
+	}
+	if err == nil && resp.Result == nil {
+		err = status.Error(codes.Code(resp.Code), resp.Error)
+	}
+	if err != nil {
+		logger.Error("gRPC_client CallStream() failed",
+			"funcName", funcName,
+			"funcArgs", funcArgs,
+			"error", err,
+		)
+		return nil, parseUserError(err)
+	}
+	result, err := m.callResult(funcName, resp.Result)
+	if err != nil {
+		return nil, err
+	}
+	return result.Value, nil
+}
+
+// CallStream serves calls multiplexed on the stream concurrently, each call is canceled
+// by cancel request of host, or when stream is broken
+func (m *functionGRPCServer) CallStream(stream protoGen.DebugTalk_CallStreamServer) error {
+	ctx, cancelAll := context.WithCancel(stream.Context())
+	defer cancelAll()
+
+	var mu sync.Mutex // guards cancels and stream.Send
+	cancels := make(map[uint64]context.CancelFunc)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			// host closes stream after all calls return
+			return nil
+		}
+		if err != nil {
+			cancelAll()
+			return err
+		}
+		if req.Cancel {
+			mu.Lock()
+			if cancel, ok := cancels[req.Id]; ok {
+				cancel()
+			}
+			mu.Unlock()
+			continue
+		}
+
+		callCtx, cancel := context.WithCancel(ctx)
+		mu.Lock()
+		cancels[req.Id] = cancel
+		mu.Unlock()
+		wg.Add(1)
+		go func(req *protoGen.StreamRequest) {
+			defer wg.Done()
+			resp := &protoGen.StreamResponse{Id: req.Id}
+			if req.Call == nil {
+				resp.Code, resp.Error = uint32(codes.InvalidArgument), "call request is missing"
+			} else if result, err := m.Call(callCtx, req.Call); err != nil {
+				s := status.Convert(err)
+				resp.Code, resp.Error = uint32(s.Code()), s.Message()
+			} else {
+				resp.Result = result
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			delete(cancels, req.Id)
+			cancel()
+			if err := stream.Send(resp); err != nil {
+				logger.Error("gRPC_server CallStream() send failed", "id", req.Id, "error", err)
+			}
+		}(req)
+	}
+}
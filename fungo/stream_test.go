@@ -0,0 +1,76 @@
+package fungo
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/lingcetech/funplugin/fungo/protoGen"
+)
+
+// unaryServer serves plugin functions without CallStream, like plugins built with older fungo
+type unaryServer struct {
+	protoGen.UnimplementedDebugTalkServer
+	server *functionGRPCServer
+}
+
+func (s *unaryServer) Call(ctx context.Context, req *protoGen.CallRequest) (*protoGen.CallResponse, error) {
+	return s.server.Call(ctx, req)
+}
+
+func serveFunctions(t *testing.T, srv protoGen.DebugTalkServer) *functionGRPCClient {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	protoGen.RegisterDebugTalkServer(server, srv)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &functionGRPCClient{client: protoGen.NewDebugTalkClient(conn)}
+}
+
+func TestCallStream(t *testing.T) {
+	canceled := make(chan struct{})
+	Register("block", func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		close(canceled)
+		return "", ctx.Err()
+	})
+	Register("echo", func(s string) string { return s })
+	defer func() {
+		delete(functions, "block")
+		delete(functions, "echo")
+	}()
+	server := &functionGRPCServer{Impl: &functionPlugin{logger: logger, functions: functions}}
+
+	for name, srv := range map[string]protoGen.DebugTalkServer{"stream": server, "unary": &unaryServer{server: server}} {
+		client := serveFunctions(t, srv)
+		result, err := client.CallContext(context.Background(), "echo", "hello")
+		assert.Nil(t, err, name)
+		assert.Equal(t, "hello", result, name)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		_, err = client.CallContext(ctx, "block")
+		cancel()
+		assert.ErrorContains(t, err, "context deadline exceeded", name)
+		select {
+		case <-canceled:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: plugin function is not canceled", name)
+		}
+		canceled = make(chan struct{})
+		assert.Equal(t, name == "unary", client.noStream, name)
+	}
+}
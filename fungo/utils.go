@@ -1,8 +1,10 @@
 package fungo
 
 import (
+	stdjson "encoding/json"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 )
 
@@ -60,6 +62,17 @@ func convertArgs(fn reflect.Value, args ...interface{}) ([]reflect.Value, error)
 			continue
 		}
 
+		// json.Number decoded in NumberJSON mode is parsed as numeric argument
+		if number, ok := argument.(stdjson.Number); ok && isNumberKind(expectArgumentType.Kind()) {
+			value, err := convertNumber(number, expectArgumentType)
+			if err != nil {
+				return nil, fmt.Errorf("function argument %d's number %s is not convertible to %v: %v",
+					index, number, expectArgumentType, err)
+			}
+			argumentsValue[index] = value
+			continue
+		}
+
 		// type not match, check if convertible
 		if !actualArgumentType.ConvertibleTo(expectArgumentType) {
 			// function argument type not match and not convertible
@@ -73,6 +86,41 @@ func convertArgs(fn reflect.Value, args ...interface{}) ([]reflect.Value, error)
 	return argumentsValue, nil
 }
 
+func isNumberKind(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Float64
+}
+
+func convertNumber(number stdjson.Number, typ reflect.Type) (reflect.Value, error) {
+	value := reflect.New(typ).Elem()
+	switch {
+	case typ.Kind() >= reflect.Int && typ.Kind() <= reflect.Int64:
+		i, err := strconv.ParseInt(number.String(), 10, 64)
+		if err != nil {
+			return value, err
+		}
+		if value.OverflowInt(i) {
+			return value, fmt.Errorf("overflow")
+		}
+		value.SetInt(i)
+	case typ.Kind() >= reflect.Uint && typ.Kind() <= reflect.Uintptr:
+		u, err := strconv.ParseUint(number.String(), 10, 64)
+		if err != nil {
+			return value, err
+		}
+		if value.OverflowUint(u) {
+			return value, fmt.Errorf("overflow")
+		}
+		value.SetUint(u)
+	default:
+		f, err := number.Float64()
+		if err != nil {
+			return value, err
+		}
+		value.SetFloat(f)
+	}
+	return value, nil
+}
+
 func call(fn reflect.Value, args []reflect.Value) (interface{}, error) {
 	resultValues := fn.Call(args)
 	if resultValues == nil {
@@ -0,0 +1,205 @@
+package fungo
+
+import (
+	stdjson "encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// keys of JSON objects carrying arbitrary-precision values, which are mangled
+// as JSON numbers by float64, e.g. {"$bigint": "123456789012345678901234567890"},
+// and time values mapped to python datetime and timedelta, e.g.
+// {"$datetime": "2024-05-01T08:30:00.5+08:00"} and {"$duration": "90.5"} in seconds
+const (
+	bigIntKey   = "$bigint"
+	decimalKey  = "$decimal"
+	datetimeKey = "$datetime"
+	durationKey = "$duration"
+)
+
+// datetimeLayout keeps offset of time zone, python before 3.11 does not accept Z
+const datetimeLayout = "2006-01-02T15:04:05.999999999-07:00"
+
+// naiveDatetimeLayout is used by python datetime without time zone, which is parsed as UTC
+const naiveDatetimeLayout = "2006-01-02T15:04:05.999999999"
+
+// Decimal is arbitrary-precision decimal number Unscaled * 10^-Scale,
+// it is mapped to python decimal.Decimal and exact for financial data
+type Decimal struct {
+	Unscaled *big.Int
+	Scale    int32
+}
+
+// ParseDecimal parses decimal literal, e.g. -12.30 or 1.5E-3
+func ParseDecimal(s string) (*Decimal, error) {
+	mantissa, exp := s, 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		var err error
+		if exp, err = strconv.Atoi(s[i+1:]); err != nil {
+			return nil, fmt.Errorf("invalid decimal: %q", s)
+		}
+		mantissa = s[:i]
+	}
+	scale := 0
+	if i := strings.Index(mantissa, "."); i >= 0 {
+		scale = len(mantissa) - i - 1
+		mantissa = mantissa[:i] + mantissa[i+1:]
+	}
+	unscaled, ok := new(big.Int).SetString(mantissa, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid decimal: %q", s)
+	}
+
+	scale -= exp
+	if scale < 0 {
+		// e.g. 1E+2, normalized to 100
+		unscaled.Mul(unscaled, pow10(-scale))
+		scale = 0
+	}
+	if int64(scale) != int64(int32(scale)) {
+		return nil, fmt.Errorf("decimal scale out of range: %q", s)
+	}
+	return &Decimal{Unscaled: unscaled, Scale: int32(scale)}, nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// String returns decimal literal keeping trailing zeros of scale, e.g. 0.10
+func (d *Decimal) String() string {
+	digits := new(big.Int).Abs(d.Unscaled).String()
+	if scale := int(d.Scale); scale > 0 {
+		if len(digits) <= scale {
+			digits = strings.Repeat("0", scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+	}
+	if d.Unscaled.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// Rat returns exact rational value of decimal for arithmetic
+func (d *Decimal) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(d.Unscaled, pow10(int(d.Scale)))
+}
+
+// formatSeconds returns duration in seconds, e.g. 90.5
+func formatSeconds(d time.Duration) string {
+	s := (&Decimal{Unscaled: big.NewInt(int64(d)), Scale: 9}).String()
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+// parseSeconds parses duration in seconds, fractions beyond nanoseconds are truncated
+func parseSeconds(s string) (time.Duration, error) {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		return 0, err
+	}
+	ns := new(big.Int)
+	if d.Scale <= 9 {
+		ns.Mul(d.Unscaled, pow10(9-int(d.Scale)))
+	} else {
+		ns.Quo(d.Unscaled, pow10(int(d.Scale)-9))
+	}
+	if !ns.IsInt64() {
+		return 0, fmt.Errorf("duration out of range: %q", s)
+	}
+	return time.Duration(ns.Int64()), nil
+}
+
+// parseDatetime parses datetime with offset, or without offset as UTC
+func parseDatetime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(naiveDatetimeLayout, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid datetime: %q", s)
+}
+
+// encodeValue converts arbitrary-precision and time values in function arguments
+// or results to JSON objects, value is copied instead of modified
+func encodeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case time.Time:
+		return map[string]interface{}{datetimeKey: v.Format(datetimeLayout)}
+	case time.Duration:
+		return map[string]interface{}{durationKey: formatSeconds(v)}
+	case *big.Int:
+		if v != nil {
+			return map[string]interface{}{bigIntKey: v.String()}
+		}
+	case *Decimal:
+		if v != nil {
+			return map[string]interface{}{decimalKey: v.String()}
+		}
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i := range v {
+			values[i] = encodeValue(v[i])
+		}
+		return values
+	case map[string]interface{}:
+		values := make(map[string]interface{}, len(v))
+		for k := range v {
+			values[k] = encodeValue(v[k])
+		}
+		return values
+	}
+	return value
+}
+
+// decodeValue converts decoded JSON objects of arbitrary-precision and time values back,
+// and JSON numbers to int64 in NumberInt64 mode, recursively in place
+func decodeValue(value interface{}, mode NumberMode) (interface{}, error) {
+	var err error
+	switch v := value.(type) {
+	case stdjson.Number:
+		if mode != NumberInt64 {
+			return v, nil
+		}
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	case []interface{}:
+		for i := range v {
+			if v[i], err = decodeValue(v[i], mode); err != nil {
+				return nil, err
+			}
+		}
+	case map[string]interface{}:
+		if len(v) == 1 {
+			if s, ok := v[bigIntKey].(string); ok {
+				i, ok := new(big.Int).SetString(s, 10)
+				if !ok {
+					return nil, fmt.Errorf("invalid big integer: %q", s)
+				}
+				return i, nil
+			}
+			if s, ok := v[decimalKey].(string); ok {
+				return ParseDecimal(s)
+			}
+			if s, ok := v[datetimeKey].(string); ok {
+				return parseDatetime(s)
+			}
+			if s, ok := v[durationKey].(string); ok {
+				return parseSeconds(s)
+			}
+		}
+		for k := range v {
+			if v[k], err = decodeValue(v[k], mode); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return value, nil
+}
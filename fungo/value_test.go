@@ -0,0 +1,127 @@
+package fungo
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lingcetech/funplugin/fungo/protoGen"
+)
+
+func TestParseDecimal(t *testing.T) {
+	for s, expected := range map[string]string{
+		"0.10":    "0.10",
+		"-12.340": "-12.340",
+		"+7":      "7",
+		".5":      "0.5",
+		"-0.001":  "-0.001",
+		"1.5E-3":  "0.0015",
+		"1E+2":    "100",
+		"12e1":    "120",
+	} {
+		d, err := ParseDecimal(s)
+		if assert.Nil(t, err, s) {
+			assert.Equal(t, expected, d.String(), s)
+		}
+	}
+
+	for _, s := range []string{"", "1.2.3", "abc", "1e", "1_000"} {
+		_, err := ParseDecimal(s)
+		assert.Error(t, err, s)
+	}
+
+	d, _ := ParseDecimal("0.10")
+	assert.Equal(t, big.NewRat(1, 10), d.Rat())
+}
+
+func TestValueRoundTrip(t *testing.T) {
+	bigInt, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	decimal, _ := ParseDecimal("19.99")
+	args := []interface{}{bigInt, decimal, map[string]interface{}{"prices": []interface{}{decimal}}, 1}
+
+	data, err := json.Marshal(encodeValue(args))
+	assert.Nil(t, err)
+	assert.Equal(t, `[{"$bigint":"123456789012345678901234567890"},{"$decimal":"19.99"},`+
+		`{"prices":[{"$decimal":"19.99"}]},1]`, string(data))
+	// arguments are not modified
+	assert.Equal(t, decimal, args[2].(map[string]interface{})["prices"].([]interface{})[0])
+
+	for _, mode := range []NumberMode{NumberFloat64, NumberJSON, NumberInt64} {
+		var values []interface{}
+		assert.Nil(t, unmarshalJSON(data, &values, mode))
+		assert.Equal(t, bigInt, values[0])
+		assert.Equal(t, decimal, values[1])
+		assert.Equal(t, decimal, values[2].(map[string]interface{})["prices"].([]interface{})[0])
+	}
+
+	var value interface{}
+	assert.Error(t, unmarshalJSON([]byte(`{"$bigint": "1.5"}`), &value, NumberFloat64))
+	// objects with other keys are kept
+	assert.Nil(t, unmarshalJSON([]byte(`{"$bigint": "1", "id": 2}`), &value, NumberFloat64))
+	assert.Equal(t, map[string]interface{}{"$bigint": "1", "id": float64(2)}, value)
+}
+
+func TestValueCallFunc(t *testing.T) {
+	p := &functionPlugin{logger: logger, functions: functionsMap{
+		"add": reflect.ValueOf(func(a *big.Int, d *Decimal) *Decimal {
+			return &Decimal{
+				Unscaled: new(big.Int).Add(new(big.Int).Mul(a, pow10(int(d.Scale))), d.Unscaled),
+				Scale:    d.Scale,
+			}
+		}),
+	}}
+	// gRPC server decodes arguments and encodes result
+	server := &functionGRPCServer{Impl: p}
+	resp, err := server.Call(context.Background(), &protoGen.CallRequest{
+		Name: "add",
+		Args: []byte(`[{"$bigint":"10000000000000000000000"},{"$decimal":"0.01"}]`),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"$decimal":"10000000000000000000000.01"}`, string(resp.Value))
+}
+
+func TestValueGob(t *testing.T) {
+	bigInt, _ := new(big.Int).SetString("-98765432109876543210", 10)
+	decimal, _ := ParseDecimal("0.000001")
+
+	// net/rpc transfers function arguments with gob
+	var buf bytes.Buffer
+	assert.Nil(t, gob.NewEncoder(&buf).Encode(&funcData{Name: "f", Args: []interface{}{bigInt, decimal, time.Second}}))
+	var data funcData
+	assert.Nil(t, gob.NewDecoder(&buf).Decode(&data))
+	assert.Equal(t, []interface{}{bigInt, decimal, time.Second}, data.Args)
+}
+
+func TestTimeValueRoundTrip(t *testing.T) {
+	at := time.Date(2024, 5, 1, 8, 30, 0, 500000000, time.FixedZone("", 8*3600))
+	args := []interface{}{at, 90*time.Second + 500*time.Millisecond, -time.Nanosecond}
+
+	data, err := json.Marshal(encodeValue(args))
+	assert.Nil(t, err)
+	assert.Equal(t, `[{"$datetime":"2024-05-01T08:30:00.5+08:00"},{"$duration":"90.5"},{"$duration":"-0.000000001"}]`,
+		string(data))
+
+	var values []interface{}
+	assert.Nil(t, unmarshalJSON(data, &values, NumberFloat64))
+	// time zone offset is preserved
+	assert.True(t, at.Equal(values[0].(time.Time)))
+	_, offset := values[0].(time.Time).Zone()
+	assert.Equal(t, 8*3600, offset)
+	assert.Equal(t, args[1:], values[1:])
+
+	// python datetime without time zone is parsed as UTC
+	var value interface{}
+	assert.Nil(t, unmarshalJSON([]byte(`{"$datetime": "2024-05-01T08:30:00.123456"}`), &value, NumberFloat64))
+	assert.Equal(t, time.Date(2024, 5, 1, 8, 30, 0, 123456000, time.UTC), value)
+	assert.Nil(t, unmarshalJSON([]byte(`{"$duration": "86400.000001"}`), &value, NumberFloat64))
+	assert.Equal(t, 24*time.Hour+time.Microsecond, value)
+
+	assert.Error(t, unmarshalJSON([]byte(`{"$datetime": "yesterday"}`), &value, NumberFloat64))
+	assert.Error(t, unmarshalJSON([]byte(`{"$duration": "1e30"}`), &value, NumberFloat64))
+}
@@ -1,5 +1,63 @@
 __version__ = 'v0.5.2'
 
-from funppy.plugin import register, serve
+from funppy.plugin import (
+    register,
+    register_module,
+    register_package,
+    function,
+    ignore,
+    describe,
+    use,
+    before_call,
+    after_call,
+    serve,
+    serve_kernel,
+    secret,
+    get_config,
+    data_dir,
+    data_file,
+    host_info,
+    host_supports,
+    seed,
+    get_random,
+    new_random,
+    set_build_info,
+    build_info,
+    call_context,
+    CallContext,
+    artifact_dir,
+    create_artifact,
+    UserError,
+    Result,
+)
 
-__all__ = ["register", "serve"]
+__all__ = [
+    "register",
+    "register_module",
+    "register_package",
+    "function",
+    "ignore",
+    "describe",
+    "use",
+    "before_call",
+    "after_call",
+    "serve",
+    "serve_kernel",
+    "secret",
+    "get_config",
+    "data_dir",
+    "data_file",
+    "host_info",
+    "host_supports",
+    "seed",
+    "get_random",
+    "new_random",
+    "set_build_info",
+    "build_info",
+    "call_context",
+    "CallContext",
+    "artifact_dir",
+    "create_artifact",
+    "UserError",
+    "Result",
+]
@@ -14,7 +14,7 @@ _sym_db = _symbol_database.Default()
 
 
 
-DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\x0f\x64\x65\x62ugtalk.proto\x12\x05proto\"\x07\n\x05\x45mpty\"!\n\x10GetNamesResponse\x12\r\n\x05names\x18\x01 \x03(\t\")\n\x0b\x43\x61llRequest\x12\x0c\n\x04name\x18\x01 \x01(\t\x12\x0c\n\x04\x61rgs\x18\x02 \x01(\x0c\"\x1d\n\x0c\x43\x61llResponse\x12\r\n\x05value\x18\x01 \x01(\x0c\x32o\n\tDebugTalk\x12\x31\n\x08GetNames\x12\x0c.proto.Empty\x1a\x17.proto.GetNamesResponse\x12/\n\x04\x43\x61ll\x12\x12.proto.CallRequest\x1a\x13.proto.CallResponseB\rZ\x0bgo/protoGenb\x06proto3')
+DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\x0f\x64\x65\x62ugtalk.proto\x12\x05proto\"\x07\n\x05\x45mpty\"!\n\x10GetNamesResponse\x12\r\n\x05names\x18\x01 \x03(\t\")\n\x0b\x43\x61llRequest\x12\x0c\n\x04name\x18\x01 \x01(\t\x12\x0c\n\x04\x61rgs\x18\x02 \x01(\x0c\"=\n\x0c\x43\x61llResponse\x12\r\n\x05value\x18\x01 \x01(\x0c\x12\x10\n\x08warnings\x18\x02 \x03(\t\x12\x0c\n\x04logs\x18\x03 \x01(\x0c\"M\n\rStreamRequest\x12\n\n\x02id\x18\x01 \x01(\x04\x12 \n\x04\x63\x61ll\x18\x02 \x01(\x0b\x32\x12.proto.CallRequest\x12\x0e\n\x06\x63\x61ncel\x18\x03 \x01(\x08\"^\n\x0eStreamResponse\x12\n\n\x02id\x18\x01 \x01(\x04\x12#\n\x06result\x18\x02 \x01(\x0b\x32\x13.proto.CallResponse\x12\x0c\n\x04\x63ode\x18\x03 \x01(\r\x12\r\n\x05\x65rror\x18\x04 \x01(\t2\xae\x01\n\tDebugTalk\x12\x31\n\x08GetNames\x12\x0c.proto.Empty\x1a\x17.proto.GetNamesResponse\x12/\n\x04\x43\x61ll\x12\x12.proto.CallRequest\x1a\x13.proto.CallResponse\x12=\n\nCallStream\x12\x14.proto.StreamRequest\x1a\x15.proto.StreamResponse(\x01\x30\x01\x42\rZ\x0bgo/protoGenb\x06proto3')
 
 
 
@@ -22,6 +22,8 @@ _EMPTY = DESCRIPTOR.message_types_by_name['Empty']
 _GETNAMESRESPONSE = DESCRIPTOR.message_types_by_name['GetNamesResponse']
 _CALLREQUEST = DESCRIPTOR.message_types_by_name['CallRequest']
 _CALLRESPONSE = DESCRIPTOR.message_types_by_name['CallResponse']
+_STREAMREQUEST = DESCRIPTOR.message_types_by_name['StreamRequest']
+_STREAMRESPONSE = DESCRIPTOR.message_types_by_name['StreamResponse']
 Empty = _reflection.GeneratedProtocolMessageType('Empty', (_message.Message,), {
   'DESCRIPTOR' : _EMPTY,
   '__module__' : 'debugtalk_pb2'
@@ -50,6 +52,20 @@ CallResponse = _reflection.GeneratedProtocolMessageType('CallResponse', (_messag
   })
 _sym_db.RegisterMessage(CallResponse)
 
+StreamRequest = _reflection.GeneratedProtocolMessageType('StreamRequest', (_message.Message,), {
+  'DESCRIPTOR' : _STREAMREQUEST,
+  '__module__' : 'debugtalk_pb2'
+  # @@protoc_insertion_point(class_scope:proto.StreamRequest)
+  })
+_sym_db.RegisterMessage(StreamRequest)
+
+StreamResponse = _reflection.GeneratedProtocolMessageType('StreamResponse', (_message.Message,), {
+  'DESCRIPTOR' : _STREAMRESPONSE,
+  '__module__' : 'debugtalk_pb2'
+  # @@protoc_insertion_point(class_scope:proto.StreamResponse)
+  })
+_sym_db.RegisterMessage(StreamResponse)
+
 _DEBUGTALK = DESCRIPTOR.services_by_name['DebugTalk']
 if _descriptor._USE_C_DESCRIPTORS == False:
 
@@ -62,7 +78,11 @@ if _descriptor._USE_C_DESCRIPTORS == False:
   _CALLREQUEST._serialized_start=70
   _CALLREQUEST._serialized_end=111
   _CALLRESPONSE._serialized_start=113
-  _CALLRESPONSE._serialized_end=142
-  _DEBUGTALK._serialized_start=144
-  _DEBUGTALK._serialized_end=255
+  _CALLRESPONSE._serialized_end=174
+  _STREAMREQUEST._serialized_start=176
+  _STREAMREQUEST._serialized_end=253
+  _STREAMRESPONSE._serialized_start=255
+  _STREAMRESPONSE._serialized_end=349
+  _DEBUGTALK._serialized_start=352
+  _DEBUGTALK._serialized_end=526
 # @@protoc_insertion_point(module_scope)
@@ -24,6 +24,11 @@ class DebugTalkStub(object):
                 request_serializer=debugtalk__pb2.CallRequest.SerializeToString,
                 response_deserializer=debugtalk__pb2.CallResponse.FromString,
                 )
+        self.CallStream = channel.stream_stream(
+                '/proto.DebugTalk/CallStream',
+                request_serializer=debugtalk__pb2.StreamRequest.SerializeToString,
+                response_deserializer=debugtalk__pb2.StreamResponse.FromString,
+                )
 
 
 class DebugTalkServicer(object):
@@ -41,6 +46,13 @@ class DebugTalkServicer(object):
         context.set_details('Method not implemented!')
         raise NotImplementedError('Method not implemented!')
 
+    def CallStream(self, request_iterator, context):
+        """multiplexes concurrent calls identified by call id
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
 
 def add_DebugTalkServicer_to_server(servicer, server):
     rpc_method_handlers = {
@@ -54,6 +66,11 @@ def add_DebugTalkServicer_to_server(servicer, server):
                     request_deserializer=debugtalk__pb2.CallRequest.FromString,
                     response_serializer=debugtalk__pb2.CallResponse.SerializeToString,
             ),
+            'CallStream': grpc.stream_stream_rpc_method_handler(
+                    servicer.CallStream,
+                    request_deserializer=debugtalk__pb2.StreamRequest.FromString,
+                    response_serializer=debugtalk__pb2.StreamResponse.SerializeToString,
+            ),
     }
     generic_handler = grpc.method_handlers_generic_handler(
             'proto.DebugTalk', rpc_method_handlers)
@@ -97,3 +114,20 @@ class DebugTalk(object):
             debugtalk__pb2.CallResponse.FromString,
             options, channel_credentials,
             insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def CallStream(request_iterator,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.stream_stream(request_iterator, target, '/proto.DebugTalk/CallStream',
+            debugtalk__pb2.StreamRequest.SerializeToString,
+            debugtalk__pb2.StreamResponse.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
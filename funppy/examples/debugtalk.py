@@ -1,6 +1,8 @@
 import logging
 from typing import List
 
+import funppy
+
 
 def sum(*args):
     result = 0
@@ -40,9 +42,22 @@ def teardown_hook_example(name):
     logging.warn("teardown_hook_example")
     return f"teardown_hook_example: {name}"
 
+def assert_equal(a, b):
+    # AssertionError is received by host as fungo.UserError
+    assert a == b, f"expect {b}, got {a}"
+
+def divide(a, b):
+    # return value with error, error is received by host as fungo.UserError
+    if b == 0:
+        return funppy.Result(None, "division by zero")
+    return a / b
+
+def min_max(*args):
+    # tuples are values, received by host as lists
+    return min(args), max(args)
+
 
 if __name__ == '__main__':
-    import funppy
     funppy.register("sum", sum)
     funppy.register("sum_ints", sum_ints)
     funppy.register("concatenate", concatenate)
@@ -51,4 +66,7 @@ if __name__ == '__main__':
     funppy.register("sum_strings", sum_strings)
     funppy.register("setup_hook_example", setup_hook_example)
     funppy.register("teardown_hook_example", teardown_hook_example)
+    funppy.register("assert_equal", assert_equal)
+    funppy.register("divide", divide)
+    funppy.register("min_max", min_max)
     funppy.serve()
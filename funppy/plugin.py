@@ -1,51 +1,936 @@
 import json
 import logging
+import os
 import random
+import signal
 import sys
 import time
 import socket
+import threading
+import inspect
+import contextvars
+import io
+import fnmatch
+import re
+import functools
+import importlib
+import pkgutil
+import queue
+import typing
 from concurrent import futures
-from typing import Callable
+from datetime import datetime, timedelta, timezone
+from decimal import Decimal
+from types import ModuleType
+from typing import Callable, Iterable, Union
 
 import grpc
 
 from funppy import debugtalk_pb2, debugtalk_pb2_grpc
 
-__all__ = ["register", "serve"]
+__all__ = [
+    "register",
+    "register_module",
+    "register_package",
+    "function",
+    "ignore",
+    "describe",
+    "use",
+    "before_call",
+    "after_call",
+    "serve",
+    "serve_kernel",
+    "secret",
+    "get_config",
+    "data_dir",
+    "data_file",
+    "host_info",
+    "host_supports",
+    "seed",
+    "get_random",
+    "new_random",
+    "set_build_info",
+    "build_info",
+    "call_context",
+    "CallContext",
+    "artifact_dir",
+    "create_artifact",
+    "UserError",
+]
+
+# marks user errors transferred to host, keep consistent with fungo
+USER_ERROR_PREFIX = "user error: "
 
 functions = {}
 
+# metadata of functions set with @funppy.function, keyed by registered name
+function_metadata = {}
+
+# middlewares registered with funppy.use, the first one is the outermost
+_middlewares = []
+
+# attribute set on functions by @funppy.function and @funppy.ignore
+METADATA_ATTR = "__funppy__"
+
+# set when serving inside a running jupyter kernel or IPython session
+_kernel_server = None
+
+# secrets passed by host with funplugin.WithSecrets, loaded once
+_secrets = None
+
+# plugin config passed by host with funplugin.WithPluginConfig, loaded once
+_config = None
+
+# identity and capabilities of host, loaded once
+_host_info = None
+
+# random source shared by plugin functions, seeded once
+_random = None
+
+# reserved function names host calls to get build_info() and describe(), keep consistent with fungo
+INFO_FUNC_NAME = "__funplugin_info__"
+DESCRIBE_FUNC_NAME = "__funplugin_describe__"
+
+# build manifest set with funppy.set_build_info
+_build_info = {}
+
+# call metadata key k is sent by host as gRPC metadata key hrp-md-<k>-bin, keep consistent with fungo
+METADATA_KEY_PREFIX = "hrp-md-"
+METADATA_KEY_SUFFIX = "-bin"
+
+# original stdout kept by host launcher for hashicorp handshake and JSON-RPC
+_handshake_channel = None
+
+SECRET_MASK = "******"
+
+# keys of JSON objects carrying arbitrary-precision and time values, keep consistent with fungo,
+# e.g. {"$bigint": "123456789012345678901234567890"}, {"$decimal": "19.99"},
+# {"$datetime": "2024-05-01T08:30:00.5+08:00"} and {"$duration": "90.5"} in seconds
+BIGINT_KEY = "$bigint"
+DECIMAL_KEY = "$decimal"
+DATETIME_KEY = "$datetime"
+DURATION_KEY = "$duration"
+
+# integers beyond are mangled by float64 of host, thus sent as big integers
+MAX_SAFE_INTEGER = 2**53 - 1
+
+# JSON-RPC error code of requests which are not valid JSON
+JSONRPC_PARSE_ERROR = -32700
+
+
+class CallContext:
+    """Context of plugin function call being executed.
+
+    metadata is attached by host with IPlugin.CallWithMetadata, e.g. test case id
+    and correlation id for logging correlation with the host's run. Warnings and
+    log entries added are returned to host by IPlugin.CallDetailed if the call
+    succeeds, e.g. data-quality caveats which should not fail the call.
+    """
+
+    def __init__(self, func_name: str = "", metadata: dict = None, grpc_context=None):
+        self.func_name = func_name
+        self.metadata = metadata or {}
+        self._grpc_context = grpc_context
+        self._cancelled = threading.Event()
+        self.warnings = []
+        self.logs = []
+        self._lock = threading.Lock()
+
+    def cancelled(self) -> bool:
+        """Whether host has canceled the call with IPlugin.Cancel, long-running
+        functions check it periodically to return early.
+        """
+        if self._cancelled.is_set():
+            return True
+        return self._grpc_context is not None and not self._grpc_context.is_active()
+
+    def add_warning(self, message: str):
+        """Report non-fatal warning of the call to host."""
+        with self._lock:
+            self.warnings.append(_mask(message))
+
+    def add_log(self, level: str, message: str):
+        """Report log entry of the call to host, level is debug, info, warn or error."""
+        entry = {
+            "time": datetime.now(timezone.utc).isoformat(),
+            "level": level,
+            "message": _mask(message),
+        }
+        with self._lock:
+            self.logs.append(entry)
+
+    def __repr__(self):
+        return f"CallContext(func_name={self.func_name!r}, metadata={self.metadata!r})"
+
+
+# context of current call, set per gRPC call
+_call_context = contextvars.ContextVar("funppy_call_context", default=None)
+
+
+def call_context() -> CallContext:
+    """Get context of plugin function call being executed, it is accessible from
+    plugin functions and middlewares. Metadata is empty outside calls, or if
+    host attaches none or does not call over gRPC.
+    """
+    return _call_context.get() or CallContext()
+
+
+def _call_metadata(context: grpc.ServicerContext) -> dict:
+    metadata = {}
+    for key, value in context.invocation_metadata() or ():
+        if key.startswith(METADATA_KEY_PREFIX) and key.endswith(METADATA_KEY_SUFFIX):
+            if isinstance(value, bytes):
+                value = value.decode("utf-8", "replace")
+            metadata[key[len(METADATA_KEY_PREFIX) : -len(METADATA_KEY_SUFFIX)]] = value
+    return metadata
+
+
+class UserError(Exception):
+    """Expected failure of plugin function, e.g. assertion failure.
+
+    Host receives it as fungo.UserError instead of a generic RPC error,
+    subclass it to specify error type name. AssertionError is treated as
+    user error as well.
+    """
+
+    def __init__(self, message: str, type_name: str = None):
+        super().__init__(message)
+        self.type_name = type_name or type(self).__name__
+
+
+class Result:
+    """Return value of plugin function together with an error, error is None,
+    an error message or an exception received by host as fungo.UserError.
+    Plain tuples returned by plugin functions are values, received as lists.
+
+        def divide(a, b):
+            if b == 0:
+                return funppy.Result(None, "division by zero")
+            return funppy.Result(a / b)
+    """
+
+    def __init__(self, value=None, error=None):
+        self.value = value
+        self.error = error
+
+
+def format_user_error(ex: Exception) -> str:
+    type_name = getattr(ex, "type_name", type(ex).__name__)
+    return f"{USER_ERROR_PREFIX}{type_name}: {ex}"
+
+
+def _mask(message: str) -> str:
+    for value in _load_secrets().values():
+        if value:
+            message = message.replace(str(value), SECRET_MASK)
+    return message
+
+
+def _mask_secrets(values: list):
+    """Mask secret values in all log records."""
+    values = [v for v in values if v]
+    if not values:
+        return
+    factory = logging.getLogRecordFactory()
+
+    def mask_factory(*args, **kwargs):
+        record = factory(*args, **kwargs)
+        message = record.getMessage()
+        for value in values:
+            message = message.replace(value, SECRET_MASK)
+        record.msg, record.args = message, None
+        return record
+
+    logging.setLogRecordFactory(mask_factory)
+
+
+def _stdout_channel():
+    """Channel of hashicorp handshake and JSON-RPC. Host launches python plugins with
+    stdout redirected to stderr, thus prints of plugin modules at import time no longer
+    corrupt the handshake, and the original stdout is passed in env HRP_PLUGIN_HANDSHAKE_FD.
+    """
+    global _handshake_channel
+    if _handshake_channel is None:
+        fd = os.environ.get("HRP_PLUGIN_HANDSHAKE_FD")
+        if not fd:
+            # launched without host launcher, e.g. by an older host
+            return sys.stdout
+        _handshake_channel = os.fdopen(int(fd), "w", buffering=1, encoding="utf-8")
+    return _handshake_channel
+
+
+def _read_host_pipe(fd_env: str, pipe_env: str):
+    """Read content written by host to inherited file descriptor in env fd_env,
+    or windows named pipe in env pipe_env, return None if neither is specified.
+    """
+    fd = os.environ.get(fd_env)
+    pipe_path = os.environ.get(pipe_env)
+    if fd:
+        f = os.fdopen(int(fd), "rb")
+    elif pipe_path:
+        f = open(pipe_path, "rb")
+    else:
+        return None
+    with f:
+        return f.read()
+
+
+def _load_secrets() -> dict:
+    """Read secrets written by host to inherited file descriptor HRP_PLUGIN_SECRETS_FD,
+    or windows named pipe HRP_PLUGIN_SECRETS_PIPE, secrets are never passed in env.
+    """
+    global _secrets
+    if _secrets is not None:
+        return _secrets
+    _secrets = {}
+
+    try:
+        content = _read_host_pipe("HRP_PLUGIN_SECRETS_FD", "HRP_PLUGIN_SECRETS_PIPE")
+        if content is None:
+            return _secrets
+        _secrets = json.loads(content or b"{}")
+    except (OSError, ValueError) as ex:
+        logging.error(f"read secrets failed: {ex}")
+        return _secrets
+
+    _mask_secrets([str(v) for v in _secrets.values()])
+    logging.info(f"load secrets from host, count: {len(_secrets)}")
+    return _secrets
+
+
+def _load_config() -> dict:
+    """Read plugin config written by host to inherited file descriptor HRP_PLUGIN_CONFIG_FD,
+    or windows named pipe HRP_PLUGIN_CONFIG_PIPE.
+    """
+    global _config
+    if _config is not None:
+        return _config
+    _config = {}
+
+    try:
+        content = _read_host_pipe("HRP_PLUGIN_CONFIG_FD", "HRP_PLUGIN_CONFIG_PIPE")
+        if content is None:
+            return _config
+        config = decode_json(content or b"{}")
+    except (OSError, ValueError) as ex:
+        logging.error(f"read plugin config failed: {ex}")
+        return _config
+    if not isinstance(config, dict):
+        logging.error(f"plugin config is not an object: {type(config).__name__}")
+        return _config
+
+    _config = config
+    logging.info(f"load plugin config from host, count: {len(_config)}")
+    return _config
+
+
+def get_config() -> dict:
+    """Get plugin config passed by host with funplugin.WithPluginConfig,
+    it is empty if host passes no config, the returned dict must not be modified.
+    """
+    return _load_config()
+
+
+def host_info() -> dict:
+    """Get identity and capabilities of host sent to plugin at startup, e.g.
+    {"name": "funplugin", "version": "v0.5.4", "os": "linux", "capabilities": [...]},
+    it is empty if plugin is not launched by host. The returned dict must not be modified.
+    """
+    global _host_info
+    if _host_info is not None:
+        return _host_info
+    _host_info = {}
+    content = os.environ.get("HRP_PLUGIN_HOST_INFO")
+    if content:
+        try:
+            _host_info = json.loads(content)
+        except ValueError as ex:
+            logging.error(f"decode host info failed: {ex}")
+    return _host_info
+
+
+def host_supports(capability: str) -> bool:
+    """Check whether host advertises capability, e.g. "call_metadata",
+    thus plugins feature-detect host instead of guessing from its version.
+    """
+    return capability in host_info().get("capabilities", [])
+
+
+def seed() -> typing.Optional[int]:
+    """Get random seed passed by host with funplugin.WithSeed,
+    it is None if host does not run plugin in deterministic mode.
+    """
+    content = os.environ.get("HRP_PLUGIN_SEED")
+    if not content:
+        return None
+    try:
+        return int(content)
+    except ValueError:
+        logging.error(f"decode random seed failed: {content}")
+        return None
+
+
+def get_random() -> random.Random:
+    """Get random source shared by plugin functions, seeded with seed() in deterministic
+    mode, otherwise with system randomness. Generated values are reproducible only for
+    the same order of calls.
+    """
+    global _random
+    if _random is None:
+        _random = random.Random(seed())
+    return _random
+
+
+def new_random(key: str) -> random.Random:
+    """Get random source seeded with seed() and key, e.g. test case id, thus values
+    generated for the same key are reproducible regardless of order of calls.
+    It is seeded with system randomness if host does not run plugin in deterministic mode.
+    """
+    value = seed()
+    if value is None:
+        return random.Random()
+    return random.Random(f"{value}:{key}")
+
+
+def set_build_info(version: str = None, commit: str = None, build_time: str = None, **extra):
+    """Set build manifest of plugin reported to host by build_info(), e.g. by release
+    tooling writing funppy.set_build_info(version="v1.2.0", commit="1a2b3c4",
+    build_time="2024-01-02T03:04:05Z") into the plugin before packaging it.
+    """
+    values = dict(extra, version=version, commit=commit, build_time=build_time)
+    _build_info.update({k: v for k, v in values.items() if v is not None})
+
+
+def build_info() -> dict:
+    """Get build manifest of plugin, which host gets with IPlugin.Info() to tell exactly
+    which plugin build handled calls. version defaults to __version__ of plugin main module.
+    """
+    from funppy import __version__
+
+    info = dict(_build_info)
+    if "version" not in info:
+        version = getattr(sys.modules.get("__main__"), "__version__", None)
+        if version:
+            info["version"] = str(version)
+    info["runtime"] = "python " + ".".join(str(v) for v in sys.version_info[:3])
+    info["sdk"] = "funppy " + __version__
+    return info
+
+
+def data_dir() -> str:
+    """Get directory of data files transferred by host with funplugin.WithDataFiles,
+    it is empty if host declares no data files.
+    """
+    return os.environ.get("HRP_PLUGIN_DATA_DIR", "")
+
+
+def data_file(name: str) -> str:
+    """Get path of data file transferred by host with slash separated name
+    declared in funplugin.WithDataFiles, e.g. "fixtures/users.csv".
+    """
+    return os.path.join(data_dir(), *name.split("/"))
+
+
+def artifact_dir() -> str:
+    """Get artifact directory managed by host with funplugin.WithArtifacts,
+    it is empty if host does not collect artifacts.
+    """
+    return os.environ.get("HRP_PLUGIN_ARTIFACT_DIR", "")
+
+
+def create_artifact(name: str, mode: str = "wb"):
+    """Open file with slash separated name in artifact directory for writing,
+    e.g. "screenshots/login.png", parent directories are created as needed.
+    """
+    path = os.path.join(artifact_dir(), *name.split("/"))
+    os.makedirs(os.path.dirname(path), exist_ok=True)
+    return open(path, mode)
+
+
+def secret(name: str, default: str = None) -> str:
+    """Get secret passed by host with funplugin.WithSecrets."""
+    return _load_secrets().get(name, default)
+
 
 def register(func_name: str, func: Callable):
     logging.info(f"register function: {func_name}")
     functions[func_name] = func
 
 
+def _type_name(hint) -> str:
+    if isinstance(hint, type):
+        return hint.__name__
+    return str(hint).replace("typing.", "")
+
+
+def _type_hints(func: Callable) -> dict:
+    try:
+        return typing.get_type_hints(func)
+    except Exception:
+        # unresolvable forward references
+        return dict(getattr(func, "__annotations__", {}))
+
+
+def _check_type(value, hint) -> bool:
+    """Check JSON decoded argument against type hint, generic types are
+    checked by their origin only, e.g. List[int] accepts any list.
+    """
+    if hint is typing.Any:
+        return True
+    origin = getattr(hint, "__origin__", None)
+    if origin is typing.Union:
+        return any(_check_type(value, h) for h in hint.__args__)
+    if origin is not None:
+        hint = origin
+    if not isinstance(hint, type):
+        # TypeVar, Callable and other hints are not checked
+        return True
+    if isinstance(value, bool) and hint in (int, float):
+        return False
+    if hint is float:
+        return isinstance(value, (int, float))
+    return isinstance(value, hint)
+
+
+def _validated(func: Callable) -> Callable:
+    """Wrap function to validate call arguments against its type hints,
+    mismatches are received by host as fungo.UserError of TypeError.
+    """
+    signature = inspect.signature(func)
+    hints = _type_hints(func)
+
+    @functools.wraps(func)
+    def wrapper(*args):
+        try:
+            bound = signature.bind(*args)
+        except TypeError as ex:
+            raise UserError(f"{func.__name__}: {ex}", "TypeError")
+
+        for name, value in bound.arguments.items():
+            if name not in hints:
+                continue
+            hint, values = hints[name], [value]
+            kind = signature.parameters[name].kind
+            if kind is inspect.Parameter.VAR_POSITIONAL:
+                # *args: List[int] is commonly used for *args: int
+                item_args = getattr(hint, "__args__", None)
+                if getattr(hint, "__origin__", None) in (list, typing.List) and item_args:
+                    hint = item_args[0]
+                values = list(value)
+            for v in values:
+                if not _check_type(v, hint):
+                    raise UserError(
+                        f"{func.__name__}: argument {name} expects "
+                        f"{_type_name(hint)}, got {type(v).__name__}",
+                        "TypeError",
+                    )
+        return func(*args)
+
+    return wrapper
+
+
+def _function_spec(func: Callable) -> dict:
+    """Parameters, return type and docstring of function."""
+    func = inspect.unwrap(func)
+    spec = {"doc": inspect.getdoc(func) or "", "params": []}
+    try:
+        signature = inspect.signature(func)
+    except (TypeError, ValueError):
+        # builtins without signature
+        return spec
+
+    hints = _type_hints(func)
+    for param in signature.parameters.values():
+        item = {"name": param.name, "kind": param.kind.name.lower()}
+        if param.name in hints:
+            item["type"] = _type_name(hints[param.name])
+        if param.default is not param.empty:
+            default = param.default
+            if not isinstance(default, (type(None), bool, int, float, str, list, dict)):
+                default = repr(default)
+            item["default"] = default
+        spec["params"].append(item)
+    if "return" in hints:
+        spec["returns"] = _type_name(hints["return"])
+    return spec
+
+
+def describe() -> dict:
+    """Signatures of registered functions for function discovery, including
+    parameters with type hints, return type, docstring and metadata set with
+    @funppy.function, e.g.
+    {"sum_two_int": {"doc": "", "params": [{"name": "a", "kind": "positional_or_keyword", "type": "int"}, ...], "returns": "int"}}
+    """
+    return {
+        name: dict(function_metadata.get(name, {}), **_function_spec(func))
+        for name, func in functions.items()
+    }
+
+
+def _register_function(func_name: str, func: Callable, metadata: dict):
+    metadata = dict(metadata)
+    validate = metadata.pop("validate", False)
+    existing = functions.get(func_name)
+    if existing is not None and inspect.unwrap(existing) is not func:
+        logging.warning(f"function {func_name} registered already, override it")
+    register(func_name, _validated(func) if validate else func)
+    if metadata:
+        function_metadata[func_name] = metadata
+    else:
+        function_metadata.pop(func_name, None)
+
+
+def function(name: str = None, validate: bool = False, **metadata):
+    """Decorator registering plugin function with its type hints and docstring
+    exported by funppy.describe(), e.g. @funppy.function or
+    @funppy.function(name="sum", validate=True, description="sum numbers").
+
+    When validate is true, call arguments are checked against type hints.
+    """
+
+    def decorator(func: Callable) -> Callable:
+        func_name = name or func.__name__
+        setattr(func, METADATA_ATTR, dict(metadata, name=func_name, validate=validate))
+        _register_function(func_name, func, dict(metadata, validate=validate))
+        return func
+
+    # used without arguments: @funppy.function
+    if callable(name):
+        func, name = name, None
+        return decorator(func)
+    return decorator
+
+
+def ignore(func: Callable) -> Callable:
+    """Decorator excluding public function from register_module and register_package."""
+    setattr(func, METADATA_ATTR, {"ignore": True})
+    return func
+
+
+def _match_patterns(name: str, patterns) -> bool:
+    if isinstance(patterns, str):
+        patterns = [patterns]
+    return any(fnmatch.fnmatchcase(name, pattern) for pattern in patterns)
+
+
+def _module_functions(mod: ModuleType) -> dict:
+    """Public callables defined in module, or listed in its __all__,
+    imported callables are skipped.
+    """
+    names = getattr(mod, "__all__", None)
+    result = {}
+    for name, value in vars(mod).items():
+        if not callable(value) or inspect.isclass(value):
+            continue
+        if names is not None:
+            if name not in names:
+                continue
+        elif name.startswith("_") or getattr(value, "__module__", None) != mod.__name__:
+            continue
+        result[name] = value
+    return result
+
+
+def register_module(
+    mod: Union[ModuleType, str],
+    include: Union[str, Iterable[str]] = None,
+    exclude: Union[str, Iterable[str]] = None,
+) -> list:
+    """Register all public functions of module, which is a module object or name.
+
+    include and exclude are glob patterns of function names, e.g. "sum_*";
+    functions decorated with @funppy.ignore are skipped, and those decorated
+    with @funppy.function are registered with the given name and metadata.
+    Returns registered function names.
+    """
+    if isinstance(mod, str):
+        mod = importlib.import_module(mod)
+
+    registered = []
+    for name, func in _module_functions(mod).items():
+        if include is not None and not _match_patterns(name, include):
+            continue
+        if exclude is not None and _match_patterns(name, exclude):
+            continue
+        metadata = dict(getattr(func, METADATA_ATTR, None) or {})
+        if metadata.pop("ignore", False):
+            continue
+        func_name = metadata.pop("name", None) or name
+        _register_function(func_name, func, metadata)
+        registered.append(func_name)
+    return registered
+
+
+def register_package(
+    pkg: Union[ModuleType, str],
+    include: Union[str, Iterable[str]] = None,
+    exclude: Union[str, Iterable[str]] = None,
+) -> list:
+    """Register all public functions of package and its submodules recursively,
+    see register_module for include, exclude and decorators.
+    Returns registered function names.
+    """
+    if isinstance(pkg, str):
+        pkg = importlib.import_module(pkg)
+
+    registered = register_module(pkg, include, exclude)
+    # plain module has no __path__, register itself only
+    for info in pkgutil.walk_packages(getattr(pkg, "__path__", []), pkg.__name__ + "."):
+        mod = importlib.import_module(info.name)
+        registered.extend(register_module(mod, include, exclude))
+    return registered
+
+
+def _kernel_functions() -> dict:
+    """Public functions defined in the interactive session namespace."""
+    if _kernel_server is None:
+        return {}
+    namespace = vars(sys.modules["__main__"])
+    return {
+        name: value
+        for name, value in namespace.items()
+        if not name.startswith("_") and inspect.isfunction(value)
+    }
+
+
+def _lookup(func_name: str):
+    if func_name in functions:
+        return functions[func_name]
+    # functions (re)defined in kernel cells are resolved at call time
+    return _kernel_functions().get(func_name)
+
+
 class DebugTalkServicer(debugtalk_pb2_grpc.DebugTalkServicer):
     """Implementation of DebugTalk service."""
 
     def GetNames(self, request: debugtalk_pb2.Empty, context: grpc.ServicerContext):
         names = list(functions.keys())
+        names.extend(n for n in _kernel_functions() if n not in functions)
         response = debugtalk_pb2.GetNamesResponse(names=names)
         return response
 
     def Call(self, request: debugtalk_pb2.CallRequest, context: grpc.ServicerContext):
-        if request.name not in functions:
-            raise Exception(f"Function {request.name} not registered!")
+        ctx = CallContext(request.name, _call_metadata(context), context)
+        try:
+            return _execute(request, ctx)
+        except (UserError, AssertionError) as ex:
+            context.abort(grpc.StatusCode.FAILED_PRECONDITION, format_user_error(ex))
 
-        fn = functions[request.name]
-        args = json.loads(request.args)
-        value = fn(*args)
+    def CallStream(self, request_iterator, context: grpc.ServicerContext):
+        """Serve calls multiplexed on the stream concurrently, each call is
+        cancelled by cancel request of host, or when stream is broken.
+        """
+        responses = queue.Queue()
+        running = {}  # call id -> CallContext
+        pending = [0]  # calls whose responses are not sent yet
+        lock = threading.Lock()
+        metadata = _call_metadata(context)
 
-        if isinstance(value, (int, float)):
-            v = str(value).encode("utf-8")
-        elif isinstance(value, (str, dict, list)):
-            v = json.dumps(value).encode("utf-8")
-        else:
-            raise Exception(f"Function return type {type(value)} not supported!")
+        def run(request: debugtalk_pb2.StreamRequest, ctx: CallContext):
+            response = debugtalk_pb2.StreamResponse(id=request.id)
+            try:
+                response.result.CopyFrom(_execute(request.call, ctx))
+            except (UserError, AssertionError) as ex:
+                response.code = grpc.StatusCode.FAILED_PRECONDITION.value[0]
+                response.error = format_user_error(ex)
+            except Exception as ex:
+                logging.exception(f"call {request.call.name} failed")
+                response.code = grpc.StatusCode.UNKNOWN.value[0]
+                response.error = f"Exception calling application: {ex}"
+            finally:
+                with lock:
+                    running.pop(request.id, None)
+                responses.put(response)
 
-        response = debugtalk_pb2.CallResponse(value=v)
-        return response
+        def receive():
+            try:
+                for request in request_iterator:
+                    with lock:
+                        if request.cancel:
+                            if request.id in running:
+                                running[request.id]._cancelled.set()
+                            continue
+                        ctx = CallContext(request.call.name, metadata, context)
+                        running[request.id] = ctx
+                        pending[0] += 1
+                    threading.Thread(target=run, args=(request, ctx), daemon=True).start()
+            except grpc.RpcError:
+                pass  # stream is broken, calls observe it via cancelled()
+            responses.put(None)
+
+        threading.Thread(target=receive, daemon=True).start()
+        ended = False
+        while True:
+            response = responses.get()
+            if response is None:
+                ended = True
+            else:
+                yield response
+                with lock:
+                    pending[0] -= 1
+            with lock:
+                if ended and pending[0] == 0:
+                    return
+
+
+def _execute(request: debugtalk_pb2.CallRequest, ctx: CallContext) -> debugtalk_pb2.CallResponse:
+    """Call plugin function with context, returning its value, warnings and log entries."""
+    args = decode_json(request.args)
+    token = _call_context.set(ctx)
+    try:
+        value = call_function(request.name, args)
+    finally:
+        _call_context.reset(token)
+    response = debugtalk_pb2.CallResponse(value=encode_value(value), warnings=ctx.warnings)
+    if ctx.logs:
+        response.logs = json.dumps(ctx.logs).encode("utf-8")
+    return response
+
+
+def use(middleware: Callable) -> Callable:
+    """Register middleware around every dispatched plugin function, symmetrical to
+    host side interceptors, e.g. for auth checks, logging and metrics.
+
+    middleware(func_name, args, call_next) calls call_next(func_name, args) to
+    continue, or raises an exception to reject the call; the first registered
+    one is the outermost. It can be used as decorator.
+    """
+    _middlewares.append(middleware)
+    return middleware
+
+
+def before_call(hook: Callable) -> Callable:
+    """Register hook(func_name, args) called before every plugin function,
+    the call is rejected if hook raises an exception. It can be used as decorator.
+    """
+
+    def middleware(func_name: str, args: list, call_next: Callable):
+        hook(func_name, args)
+        return call_next(func_name, args)
+
+    use(middleware)
+    return hook
+
+
+def after_call(hook: Callable) -> Callable:
+    """Register hook(func_name, args, result, error) called after every plugin function,
+    error is the raised exception or None. It can be used as decorator.
+    """
+
+    def middleware(func_name: str, args: list, call_next: Callable):
+        try:
+            result = call_next(func_name, args)
+        except Exception as ex:
+            hook(func_name, args, None, ex)
+            raise
+        hook(func_name, args, result, None)
+        return result
+
+    use(middleware)
+    return hook
+
+
+def call_function(func_name: str, args: list):
+    """Call plugin function wrapped by middlewares."""
+    if func_name not in functions and func_name in (INFO_FUNC_NAME, DESCRIBE_FUNC_NAME):
+        # reserved functions are not passed to middlewares
+        return json.dumps(build_info() if func_name == INFO_FUNC_NAME else describe())
+    handler = _dispatch
+    for middleware in reversed(_middlewares):
+        handler = functools.partial(_call_middleware, middleware, handler)
+    return handler(func_name, args)
+
+
+def _call_middleware(middleware: Callable, call_next: Callable, func_name: str, args: list):
+    return middleware(func_name, args, call_next)
+
+
+def _dispatch(func_name: str, args: list):
+    """Call plugin function, a function may return Result with value and error,
+    where error is None, an error message or an exception.
+    """
+    fn = _lookup(func_name)
+    if fn is None:
+        raise Exception(f"Function {func_name} not registered!")
+    result = fn(*args)
+    if not isinstance(result, Result):
+        return result
+
+    value, error = result.value, result.error
+    if error is None:
+        return value
+    if isinstance(error, (UserError, AssertionError)):
+        raise error
+    if isinstance(error, Exception):
+        # returned errors are always user errors, keep original type name
+        raise UserError(str(error), type(error).__name__)
+    raise UserError(str(error))
+
+
+def _parse_datetime(s: str) -> datetime:
+    # fromisoformat before python 3.11 accepts neither Z nor nanoseconds
+    s = re.sub(r"(\.\d{6})\d+", r"\1", s)
+    if s.endswith("Z"):
+        s = s[:-1] + "+00:00"
+    return datetime.fromisoformat(s)
+
+
+def _format_seconds(delta: timedelta) -> str:
+    microseconds = delta // timedelta(microseconds=1)
+    sign = "-" if microseconds < 0 else ""
+    seconds, microseconds = divmod(abs(microseconds), 10**6)
+    return f"{sign}{seconds}.{microseconds:06d}"
+
+
+def _decode_object(obj: dict):
+    """Restore values sent by host to int, Decimal, datetime and timedelta."""
+    if len(obj) == 1:
+        if BIGINT_KEY in obj:
+            return int(obj[BIGINT_KEY])
+        if DECIMAL_KEY in obj:
+            return Decimal(obj[DECIMAL_KEY])
+        if DATETIME_KEY in obj:
+            return _parse_datetime(obj[DATETIME_KEY])
+        if DURATION_KEY in obj:
+            return timedelta(microseconds=int(Decimal(obj[DURATION_KEY]) * 10**6))
+    return obj
+
+
+def decode_json(data):
+    return json.loads(data, object_hook=_decode_object)
+
+
+def _encode_values(value):
+    """Convert big integers, Decimal, datetime and timedelta to JSON objects
+    received by host as *big.Int, *fungo.Decimal, time.Time and time.Duration,
+    datetime without time zone is received as UTC.
+    """
+    if isinstance(value, bool):
+        return value
+    if isinstance(value, int) and abs(value) > MAX_SAFE_INTEGER:
+        return {BIGINT_KEY: str(value)}
+    if isinstance(value, Decimal):
+        return {DECIMAL_KEY: str(value)}
+    if isinstance(value, datetime):
+        return {DATETIME_KEY: value.isoformat()}
+    if isinstance(value, timedelta):
+        return {DURATION_KEY: _format_seconds(value)}
+    if isinstance(value, (list, tuple)):
+        return [_encode_values(v) for v in value]
+    if isinstance(value, dict):
+        return {k: _encode_values(v) for k, v in value.items()}
+    return value
+
+
+def encode_value(value) -> bytes:
+    if value is None:
+        return b"null"
+    value = _encode_values(value)
+    if isinstance(value, (int, float)):
+        return str(value).encode("utf-8")
+    elif isinstance(value, (str, dict, list)):
+        return json.dumps(value).encode("utf-8")
+    else:
+        raise Exception(f"Function return type {type(value)} not supported!")
 
 
 def get_available_port() -> int:
@@ -65,8 +950,156 @@ def get_available_port() -> int:
             continue
 
 
+def enable_reflection(server: grpc.Server):
+    """Expose gRPC reflection service when enabled by host for debugging."""
+    if os.environ.get("HRP_PLUGIN_GRPC_REFLECTION") != "true":
+        return
+
+    try:
+        from grpc_reflection.v1alpha import reflection
+    except ImportError:
+        logging.warning("grpcio-reflection not installed, skip gRPC reflection")
+        return
+
+    service_names = (
+        debugtalk_pb2.DESCRIPTOR.services_by_name["DebugTalk"].full_name,
+        reflection.SERVICE_NAME,
+    )
+    reflection.enable_server_reflection(service_names, server)
+
+
+def _serve_jsonrpc(reader, writer):
+    """Serve JSON-RPC 1.0 requests, one JSON object per line.
+
+    {"method": "Plugin.Call", "params": [["sum", 1, 2]], "id": 1}
+    => {"id": 1, "result": 3, "error": null}
+    """
+    for line in reader:
+        if not line.strip():
+            continue
+        response = {"id": None, "result": None, "error": None}
+        try:
+            request = decode_json(line)
+        except ValueError as ex:
+            # malformed request is answered instead of stopping the server
+            response["error"] = {"code": JSONRPC_PARSE_ERROR, "message": f"Parse error: {ex}"}
+            writer.write(json.dumps(response) + "\n")
+            writer.flush()
+            continue
+
+        try:
+            if not isinstance(request, dict):
+                raise Exception("Request is not a JSON object!")
+            response["id"] = request.get("id")
+            params = request.get("params") or [[]]
+            if request.get("method") == "Plugin.GetNames":
+                names = list(functions.keys())
+                names.extend(n for n in _kernel_functions() if n not in functions)
+                response["result"] = names
+            elif request.get("method") == "Plugin.Call":
+                func_name, *args = params[0]
+                value = call_function(func_name, args)
+                response["result"] = json.loads(encode_value(value))
+            else:
+                raise Exception(f"Method {request.get('method')} not supported!")
+        except (UserError, AssertionError) as ex:
+            response["error"] = format_user_error(ex)
+        except Exception as ex:
+            response["error"] = str(ex)
+
+        writer.write(json.dumps(response) + "\n")
+        writer.flush()
+
+
+def serve_stdio():
+    """Serve JSON-RPC requests over stdin/stdout."""
+    # keep stdout for JSON-RPC, user prints go to stderr
+    channel = _stdout_channel()
+    sys.stdout = sys.stderr
+    _serve_jsonrpc(sys.stdin, channel)
+
+
+def serve_pipe():
+    """Serve JSON-RPC requests over windows named pipe created by host,
+    the pipe path is passed in env HRP_PLUGIN_PIPE.
+    """
+    pipe_path = os.environ["HRP_PLUGIN_PIPE"]
+    # pipes are not seekable, thus wrap raw file for reading and writing separately
+    with open(pipe_path, "r+b", buffering=0) as pipe:
+        reader = io.TextIOWrapper(io.BufferedReader(pipe), encoding="utf-8")
+        writer = io.TextIOWrapper(io.BufferedWriter(pipe), encoding="utf-8")
+        _serve_jsonrpc(reader, writer)
+
+
+def serve_health(addr: str, server_ready: threading.Event):
+    """Serve liveness endpoint /healthz and readiness endpoint /readyz."""
+    from http.server import BaseHTTPRequestHandler, ThreadingHTTPServer
+
+    class HealthHandler(BaseHTTPRequestHandler):
+        def do_GET(self):
+            if self.path == "/healthz":
+                ok = True
+            elif self.path == "/readyz":
+                ok = server_ready.is_set()
+            else:
+                self.send_error(404)
+                return
+            self.send_response(200 if ok else 503)
+            self.end_headers()
+            self.wfile.write(b"ok" if ok else b"not ready")
+
+        def log_message(self, format, *args):
+            logging.debug(format, *args)
+
+    host, _, port = addr.rpartition(":")
+    httpd = ThreadingHTTPServer((host or "0.0.0.0", int(port)), HealthHandler)
+    threading.Thread(target=httpd.serve_forever, daemon=True).start()
+
+
+def serve_sidecar(addr: str):
+    """Serve plain gRPC on a fixed address without hashicorp handshake,
+    which is required when running as kubernetes sidecar.
+    """
+    server = grpc.server(futures.ThreadPoolExecutor(max_workers=10))
+    debugtalk_pb2_grpc.add_DebugTalkServicer_to_server(DebugTalkServicer(), server)
+    enable_reflection(server)
+    server.add_insecure_port(addr)
+
+    server_ready = threading.Event()
+    health_addr = os.environ.get("HRP_PLUGIN_HEALTH_ADDR")
+    if health_addr:
+        serve_health(health_addr, server_ready)
+
+    # stop gracefully when pod is terminating
+    def stop(signum, frame):
+        server_ready.clear()
+        server.stop(grace=5)
+
+    signal.signal(signal.SIGTERM, stop)
+    server.start()
+    server_ready.set()
+    logging.info(f"plugin sidecar serving on {addr}")
+    try:
+        server.wait_for_termination()
+    except KeyboardInterrupt:
+        server.stop(0)
+
+
 def serve():
+    # read secrets before serving, thus they are masked in logs of plugin functions
+    _load_secrets()
+    # read plugin config before serving, host closes windows named pipe after start timeout
+    _load_config()
     # Start the server.
+    if os.environ.get("HRP_PLUGIN_SIDECAR_ADDR"):
+        serve_sidecar(os.environ["HRP_PLUGIN_SIDECAR_ADDR"])
+        return
+    if os.environ.get("HRP_PLUGIN_TRANSPORT") == "stdio":
+        serve_stdio()
+        return
+    if os.environ.get("HRP_PLUGIN_TRANSPORT") == "npipe":
+        serve_pipe()
+        return
 
     # Generate a random port
     random_port = get_available_port()
@@ -74,13 +1107,15 @@ def serve():
     # Create the gRPC server and continue with the rest of your code
     server = grpc.server(futures.ThreadPoolExecutor(max_workers=10))
     debugtalk_pb2_grpc.add_DebugTalkServicer_to_server(DebugTalkServicer(), server)
+    enable_reflection(server)
 
     server.add_insecure_port(f"127.0.0.1:{random_port}")
     server.start()
 
     # Output information
-    print(f"1|1|tcp|127.0.0.1:{random_port}|grpc")
-    sys.stdout.flush()
+    channel = _stdout_channel()
+    print(f"1|1|tcp|127.0.0.1:{random_port}|grpc", file=channel)
+    channel.flush()
 
     try:
         while True:
@@ -89,5 +1124,40 @@ def serve():
         server.stop(0)
 
 
+def serve_kernel(connection_file: str = None) -> dict:
+    """Serve plugin functions from a running jupyter kernel or IPython session.
+
+    The gRPC server runs in background threads, so the session stays interactive.
+    Registered functions and public functions defined in the session namespace
+    can be called by the host with funplugin.Init(connection_file).
+    """
+    global _kernel_server
+
+    if connection_file is None:
+        connection_file = os.path.join(
+            os.path.expanduser("~"), ".funppy", "kernel.json"
+        )
+
+    if _kernel_server is None:
+        random_port = get_available_port()
+        server = grpc.server(futures.ThreadPoolExecutor(max_workers=10))
+        debugtalk_pb2_grpc.add_DebugTalkServicer_to_server(DebugTalkServicer(), server)
+        server.add_insecure_port(f"127.0.0.1:{random_port}")
+        server.start()
+        _kernel_server = (server, f"127.0.0.1:{random_port}")
+
+    connection = {
+        "pid": os.getpid(),
+        "addr": _kernel_server[1],
+        "protocol": "grpc",
+    }
+    os.makedirs(os.path.dirname(os.path.abspath(connection_file)), exist_ok=True)
+    with open(connection_file, "w") as f:
+        json.dump(connection, f)
+
+    logging.info(f"serve kernel plugin functions: {connection}")
+    return connection
+
+
 if __name__ == "__main__":
     serve()
@@ -23,12 +23,12 @@ func newGoPlugin(path string) (*goPlugin, error) {
 	}
 
 	// logger
-	logger = logger.ResetNamed("go-plugin")
+	logger := logger.ResetNamed("go-plugin")
 
 	plg, err := plugin.Open(path)
 	if err != nil {
 		logger.Error("load go plugin failed", "path", path, "error", err)
-		return nil, err
+		return nil, goPluginOpenError(path, err)
 	}
 
 	logger.Info("load go plugin success", "path", path)
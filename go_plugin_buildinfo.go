@@ -0,0 +1,169 @@
+package funplugin
+
+import (
+	"debug/buildinfo"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
+)
+
+// goPluginBuildSettings are build settings which must be identical in host and go plugin
+var goPluginBuildSettings = []string{
+	"GOOS", "GOARCH", "GOAMD64", "GOARM", "GOARM64", "CGO_ENABLED",
+	"-race", "-msan", "-asan", "-trimpath",
+}
+
+// GoPluginMismatchError is returned when go plugin fails to load and is built
+// differently from host, e.g. by another go version or versions of shared modules
+type GoPluginMismatchError struct {
+	Path       string   // plugin file path
+	Mismatches []string // e.g. module github.com/pkg/errors: host v0.9.1, plugin v0.8.1
+	Rebuild    string   // suggested command rebuilding plugin consistent with host
+	Err        error    // error of plugin.Open
+}
+
+func (e *GoPluginMismatchError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "load go plugin %s failed, it is built differently from host:\n", e.Path)
+	for _, mismatch := range e.Mismatches {
+		fmt.Fprintf(&b, "  - %s\n", mismatch)
+	}
+	fmt.Fprintf(&b, "rebuild plugin with: %s\n", e.Rebuild)
+	fmt.Fprintf(&b, "plugin.Open: %v", e.Err)
+	return b.String()
+}
+
+func (e *GoPluginMismatchError) Unwrap() error {
+	return e.Err
+}
+
+// goPluginOpenError explains failure of plugin.Open by comparing build info of
+// host and plugin, the original error is returned if no mismatch is found
+func goPluginOpenError(path string, openErr error) error {
+	host, ok := debug.ReadBuildInfo()
+	if !ok {
+		return openErr
+	}
+	plg, err := buildinfo.ReadFile(path)
+	if err != nil {
+		logger.Warn("read go plugin build info failed", "path", path, "error", err)
+		return openErr
+	}
+	mismatches := goPluginMismatches(host, plg)
+	if len(mismatches) == 0 {
+		return openErr
+	}
+	return &GoPluginMismatchError{
+		Path:       path,
+		Mismatches: mismatches,
+		Rebuild:    goPluginRebuildCommand(host, plg, path),
+		Err:        openErr,
+	}
+}
+
+// goPluginMismatches compares go version, build settings and versions of modules
+// shared by host and plugin, which must be identical for plugin.Open
+func goPluginMismatches(host, plg *debug.BuildInfo) []string {
+	var mismatches []string
+	if host.GoVersion != plg.GoVersion {
+		mismatches = append(mismatches,
+			fmt.Sprintf("go version: host %s, plugin %s", host.GoVersion, plg.GoVersion))
+	}
+
+	hostSettings, plgSettings := buildSettings(host), buildSettings(plg)
+	for _, key := range goPluginBuildSettings {
+		if hostSettings[key] != plgSettings[key] {
+			mismatches = append(mismatches, fmt.Sprintf("build setting %s: host %s, plugin %s",
+				key, settingValue(hostSettings[key]), settingValue(plgSettings[key])))
+		}
+	}
+
+	hostModules, plgModules := moduleVersions(host), moduleVersions(plg)
+	paths := make([]string, 0, len(plgModules))
+	for path := range plgModules {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if version, ok := hostModules[path]; ok && version != plgModules[path] {
+			mismatches = append(mismatches,
+				fmt.Sprintf("module %s: host %s, plugin %s", path, version, plgModules[path]))
+		}
+	}
+	return mismatches
+}
+
+// goPluginRebuildCommand suggests command rebuilding plugin with build flags and
+// module versions of host, plugin main package is built in its module directory
+func goPluginRebuildCommand(host, plg *debug.BuildInfo, path string) string {
+	var commands []string
+	hostModules, plgModules := moduleVersions(host), moduleVersions(plg)
+	for _, dep := range host.Deps {
+		if dep.Replace == nil && plgModules[dep.Path] != "" && plgModules[dep.Path] != hostModules[dep.Path] {
+			commands = append(commands, fmt.Sprintf("go get %s@%s", dep.Path, dep.Version))
+		}
+	}
+
+	args := append([]string{"go", "build", "-buildmode=plugin"}, pluginBuildFlags(host)...)
+	source := plg.Path
+	if source == "command-line-arguments" {
+		// plugin built from go files instead of package
+		source = "<plugin go files>"
+	}
+	args = append(args, "-o", path, source)
+	commands = append(commands, strings.Join(args, " "))
+
+	rebuild := strings.Join(commands, " && ")
+	if host.GoVersion != plg.GoVersion {
+		rebuild += fmt.Sprintf(" (with %s)", host.GoVersion)
+	}
+	return rebuild
+}
+
+// pluginBuildFlags returns go build flags of host which go plugin must be built with
+func pluginBuildFlags(host *debug.BuildInfo) []string {
+	var flags []string
+	settings := buildSettings(host)
+	for _, flag := range []string{"-race", "-msan", "-asan", "-trimpath"} {
+		if settings[flag] == "true" {
+			flags = append(flags, flag)
+		}
+	}
+	if tags := settings["-tags"]; tags != "" {
+		flags = append(flags, "-tags="+tags)
+	}
+	return flags
+}
+
+func buildSettings(info *debug.BuildInfo) map[string]string {
+	settings := make(map[string]string)
+	for _, setting := range info.Settings {
+		settings[setting.Key] = setting.Value
+	}
+	return settings
+}
+
+// settingValue shows unset build setting, e.g. -race of build without race detector
+func settingValue(value string) string {
+	if value == "" {
+		return "unset"
+	}
+	return value
+}
+
+// moduleVersions returns versions of main and dependency modules, with replacements
+func moduleVersions(info *debug.BuildInfo) map[string]string {
+	versions := make(map[string]string)
+	for _, m := range append([]*debug.Module{&info.Main}, info.Deps...) {
+		if m.Path == "" {
+			continue
+		}
+		version := m.Version
+		if m.Replace != nil {
+			version += " => " + m.Replace.Path + " " + m.Replace.Version
+		}
+		versions[m.Path] = strings.TrimSpace(version)
+	}
+	return versions
+}
@@ -6,8 +6,11 @@
 package funplugin
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"runtime/debug"
 	"testing"
 
 	"github.com/lingcetech/funplugin/myexec"
@@ -51,3 +54,68 @@ func TestCallPluginFunction(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestGoPluginMismatches(t *testing.T) {
+	host := &debug.BuildInfo{
+		GoVersion: "go1.22.1",
+		Path:      "github.com/httprunner/hrp",
+		Main:      debug.Module{Path: "github.com/httprunner/hrp", Version: "(devel)"},
+		Deps: []*debug.Module{
+			{Path: "github.com/lingcetech/funplugin", Version: "v0.6.0"},
+			{Path: "github.com/pkg/errors", Version: "v0.9.1"},
+		},
+		Settings: []debug.BuildSetting{{Key: "-race", Value: "true"}, {Key: "GOOS", Value: "linux"}},
+	}
+	plg := &debug.BuildInfo{
+		GoVersion: "go1.21.0",
+		Path:      "command-line-arguments",
+		Main:      debug.Module{Path: "example.com/debugtalk", Version: "(devel)"},
+		Deps: []*debug.Module{
+			{Path: "github.com/lingcetech/funplugin", Version: "v0.5.0"},
+			{Path: "github.com/pkg/errors", Version: "v0.9.1"},
+			{Path: "github.com/google/uuid", Version: "v1.3.0"},
+		},
+		Settings: []debug.BuildSetting{{Key: "GOOS", Value: "linux"}},
+	}
+
+	assert.Equal(t, []string{
+		"go version: host go1.22.1, plugin go1.21.0",
+		"build setting -race: host true, plugin unset",
+		"module github.com/lingcetech/funplugin: host v0.6.0, plugin v0.5.0",
+	}, goPluginMismatches(host, plg))
+	assert.Equal(t, "go get github.com/lingcetech/funplugin@v0.6.0 && "+
+		"go build -buildmode=plugin -race -o debugtalk.so <plugin go files> (with go1.22.1)",
+		goPluginRebuildCommand(host, plg, "debugtalk.so"))
+
+	assert.Empty(t, goPluginMismatches(host, host))
+}
+
+func TestGoPluginMismatchError(t *testing.T) {
+	host, ok := debug.ReadBuildInfo()
+	if !ok {
+		t.Skip("build info not available")
+	}
+	// build plugin with race flag differing from host,
+	// path differs from other tests since plugin.Open caches loaded plugins
+	path := filepath.Join(t.TempDir(), "debugtalk.so")
+	args := []string{"build", "-buildmode=plugin", "-o=" + path, "fungo/examples/debugtalk.go"}
+	race := false
+	for _, setting := range host.Settings {
+		race = race || (setting.Key == "-race" && setting.Value == "true")
+	}
+	if !race {
+		args = append(args[:1], append([]string{"-race"}, args[1:]...)...)
+	}
+	if err := myexec.RunCommand("go", args...); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Init(path)
+	var mismatchErr *GoPluginMismatchError
+	if !assert.True(t, errors.As(err, &mismatchErr), "unexpected error: %v", err) {
+		t.FailNow()
+	}
+	assert.Contains(t, mismatchErr.Mismatches, fmt.Sprintf("build setting -race: host %s, plugin %s",
+		map[bool]string{true: "true", false: "unset"}[race], map[bool]string{true: "unset", false: "true"}[race]))
+	assert.Contains(t, err.Error(), "rebuild plugin with: go build -buildmode=plugin")
+}
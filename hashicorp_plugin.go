@@ -1,10 +1,13 @@
 package funplugin
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hashicorp/go-hclog"
@@ -28,12 +31,18 @@ func (t rpcType) String() string {
 
 // hashicorpPlugin implements hashicorp/go-plugin
 type hashicorpPlugin struct {
+	mu              sync.RWMutex // guards client and funcCaller, which are replaced when plugin process restarts
+	restartMu       sync.Mutex   // serializes restarts of plugin process, e.g. by heartbeat and chaos kill faults
 	client          *plugin.Client
 	rpcType         rpcType
 	funcCaller      fungo.IFuncCaller
 	cachedFunctions sync.Map // cache loaded functions to improve performance, key is function name, value is bool
 	path            string   // plugin file path
 	option          *pluginOption
+	reattach        *plugin.ReattachConfig // attach to a running plugin server instead of launching one
+	restarts        int32                  // restarts of plugin process, accessed atomically
+	lastPing        int64                  // unix nanoseconds of last successful ping, accessed atomically
+	logger          hclog.Logger           // named logger of plugin client, default to host logger
 }
 
 func newHashicorpPlugin(path string, option *pluginOption) (*hashicorpPlugin, error) {
@@ -48,18 +57,69 @@ func newHashicorpPlugin(path string, option *pluginOption) (*hashicorpPlugin, er
 		p.rpcType = rpcTypeGRPC // default
 	}
 	// logger
-	logger = logger.ResetNamed(fmt.Sprintf("hc-%v-%v", p.rpcType, p.option.langType))
+	p.logger = logger.ResetNamed(fmt.Sprintf("hc-%v-%v", p.rpcType, p.option.langType))
 
 	// 失败则继续尝试，连续三次失败则返回错误
 	err := p.startPlugin()
 	if err == nil {
 		return p, err
 	}
-	logger.Info("load hashicorp go plugin success", "path", path)
+	p.logger.Info("load hashicorp go plugin success", "path", path)
 
 	return nil, err
 }
 
+// current returns client and function caller of current plugin process
+func (p *hashicorpPlugin) current() (*plugin.Client, fungo.IFuncCaller) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.client, p.funcCaller
+}
+
+// caller returns function caller of current plugin process
+func (p *hashicorpPlugin) caller() fungo.IFuncCaller {
+	_, funcCaller := p.current()
+	return funcCaller
+}
+
+func (p *hashicorpPlugin) processInfo() *ProcessInfo {
+	client, _ := p.current()
+	if client == nil {
+		return nil
+	}
+	config := client.ReattachConfig()
+	if config == nil {
+		return nil
+	}
+	info := &ProcessInfo{Pid: config.Pid, Running: !client.Exited(), Restarts: int(atomic.LoadInt32(&p.restarts))}
+	if lastPing := atomic.LoadInt64(&p.lastPing); lastPing != 0 {
+		info.LastPing = time.Unix(0, lastPing)
+	}
+	return info
+}
+
+// ping checks plugin server answers over its connection, python plugin servers do not
+// serve grpc health service, thus they are pinged with a round-trip of listing functions
+func (p *hashicorpPlugin) ping() error {
+	client, funcCaller := p.current()
+	if p.option.langType == langTypePython {
+		if _, err := funcCaller.GetNames(); err != nil {
+			return errors.Wrap(err, "ping plugin failed")
+		}
+		atomic.StoreInt64(&p.lastPing, time.Now().UnixNano())
+		return nil
+	}
+	rpcClient, err := client.Client()
+	if err != nil {
+		return errors.Wrap(err, "connect plugin failed")
+	}
+	if err := rpcClient.Ping(); err != nil {
+		return errors.Wrap(err, "ping plugin failed")
+	}
+	atomic.StoreInt64(&p.lastPing, time.Now().UnixNano())
+	return nil
+}
+
 func (p *hashicorpPlugin) Type() string {
 	return fmt.Sprintf("hashicorp-%s-%v", p.rpcType, p.option.langType)
 }
@@ -75,7 +135,7 @@ func (p *hashicorpPlugin) Has(funcName string) bool {
 		return flag.(bool)
 	}
 
-	funcNames, err := p.funcCaller.GetNames()
+	funcNames, err := p.caller().GetNames()
 	if err != nil {
 		return false
 	}
@@ -87,12 +147,70 @@ func (p *hashicorpPlugin) Has(funcName string) bool {
 		}
 	}
 
-	p.cachedFunctions.Store(funcName, false) // cache as not exists
+	// functions may be defined later in an interactive kernel session,
+	// thus missing functions are not cached for reattached plugins
+	if p.reattach == nil {
+		p.cachedFunctions.Store(funcName, false) // cache as not exists
+	}
 	return false
 }
 
+func (p *hashicorpPlugin) GetNames() ([]string, error) {
+	return p.caller().GetNames()
+}
+
 func (p *hashicorpPlugin) Call(funcName string, args ...interface{}) (interface{}, error) {
-	return p.funcCaller.Call(funcName, args...)
+	return p.caller().Call(funcName, args...)
+}
+
+// CallWithMetadata calls function with call metadata, which is ignored by go plugins of RPC type
+func (p *hashicorpPlugin) CallWithMetadata(md map[string]string, funcName string, args ...interface{}) (interface{}, error) {
+	funcCaller := p.caller()
+	caller, ok := funcCaller.(fungo.IMetadataCaller)
+	if !ok {
+		logger.Debug("plugin does not support call metadata, ignored", "funcName", funcName)
+		return funcCaller.Call(funcName, args...)
+	}
+	return caller.CallWithMetadata(md, funcName, args...)
+}
+
+// CallContext calls function canceled when ctx is done, go plugins of RPC type are not canceled
+func (p *hashicorpPlugin) CallContext(ctx context.Context, funcName string, args ...interface{}) (interface{}, error) {
+	funcCaller := p.caller()
+	caller, ok := funcCaller.(fungo.IContextCaller)
+	if !ok {
+		return funcCaller.Call(funcName, args...)
+	}
+	return caller.CallContext(ctx, funcName, args...)
+}
+
+// CallDetailed calls function and returns warnings and log entries, go plugins of RPC type report none
+func (p *hashicorpPlugin) CallDetailed(funcName string, args ...interface{}) (*fungo.CallResult, error) {
+	funcCaller := p.caller()
+	caller, ok := funcCaller.(fungo.IDetailedCaller)
+	if !ok {
+		value, err := funcCaller.Call(funcName, args...)
+		if err != nil {
+			return nil, err
+		}
+		return &fungo.CallResult{Value: value}, nil
+	}
+	return caller.CallDetailed(funcName, args...)
+}
+
+// CallDetailedContext calls function with call metadata, canceled when ctx is done, go plugins
+// of RPC type ignore metadata, are not canceled and report no warnings and log entries
+func (p *hashicorpPlugin) CallDetailedContext(ctx context.Context, md map[string]string, funcName string, args ...interface{}) (*fungo.CallResult, error) {
+	funcCaller := p.caller()
+	caller, ok := funcCaller.(fungo.IDetailedContextCaller)
+	if !ok {
+		value, err := funcCaller.Call(funcName, args...)
+		if err != nil {
+			return nil, err
+		}
+		return &fungo.CallResult{Value: value}, nil
+	}
+	return caller.CallDetailedContext(ctx, md, funcName, args...)
 }
 
 func (p *hashicorpPlugin) StartHeartbeat() {
@@ -103,87 +221,230 @@ func (p *hashicorpPlugin) StartHeartbeat() {
 	for range ticker.C {
 		// Check the client connection status
 		logger.Info("heartbreak......")
-		if p.client.Exited() {
-			logger.Error(fmt.Sprintf("plugin exited, restarting..."))
-			err = p.startPlugin()
+		if client, _ := p.current(); client.Exited() {
+			err = p.restartExited(client)
 			if err != nil {
 				break
 			}
+		} else if err := p.ping(); err != nil {
+			logger.Warn("plugin heartbeat failed", "error", err)
 		}
 	}
 }
 
-func (p *hashicorpPlugin) startPlugin() error {
-	var cmd *exec.Cmd
-	if p.option.langType == langTypePython {
-		// hashicorp python plugin
-		cmd = exec.Command(p.option.python3, p.path)
-		// hashicorp python plugin only supports gRPC
-		p.rpcType = rpcTypeGRPC
-	} else {
-		// hashicorp go plugin
-		cmd = exec.Command(p.path)
-		// hashicorp go plugin supports grpc and rpc
-		p.rpcType = rpcType(os.Getenv(fungo.PluginTypeEnvName))
-		if p.rpcType != rpcTypeRPC {
-			p.rpcType = rpcTypeGRPC // default
+// restartExited launches a new plugin process if exited one is still current,
+// since it may have been restarted meanwhile, e.g. by a chaos kill fault
+func (p *hashicorpPlugin) restartExited(exited *plugin.Client) error {
+	restarted := false
+	// restart hook runs after restart lock is released
+	defer func() {
+		if restarted {
+			p.option.restarted()
+		}
+	}()
+	p.restartMu.Lock()
+	defer p.restartMu.Unlock()
+	if client, _ := p.current(); client != exited {
+		return nil
+	}
+	logger.Error("plugin exited, restarting...")
+	if err := p.startPlugin(); err != nil {
+		return err
+	}
+	atomic.AddInt32(&p.restarts, 1)
+	restarted = true
+	return nil
+}
+
+// restartProcess kills plugin process and launches a new one, e.g. for chaos kill faults
+func (p *hashicorpPlugin) restartProcess() error {
+	if p.reattach != nil {
+		return fmt.Errorf("attached plugin server can not be restarted")
+	}
+	restarted := false
+	// restart hook runs after restart lock is released
+	defer func() {
+		if restarted {
+			p.option.restarted()
 		}
+	}()
+	p.restartMu.Lock()
+	defer p.restartMu.Unlock()
+	client, _ := p.current()
+	client.Kill()
+	if err := p.startPlugin(); err != nil {
+		return err
+	}
+	atomic.AddInt32(&p.restarts, 1)
+	restarted = true
+	return nil
+}
+
+// startPlugin launches plugin process, or attaches to running plugin server, and replaces
+// current one on success, it is called on creation or with restartMu held
+func (p *hashicorpPlugin) startPlugin() error {
+	if p.client == nil {
+		// plugin type is kept on restart, since it is read by concurrent calls
+		p.rpcType = p.startRPCType()
 	}
-	cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%s", fungo.PluginTypeEnvName, p.rpcType))
 
+	logger := p.logger
+	if logger == nil {
+		logger = fungo.Logger
+	}
 	var err error
 	maxRetryCount := 3
 	for i := 0; i < maxRetryCount; i++ {
-		err = p.tryStartPlugin(cmd, logger)
+		err = p.tryStartPlugin(p.newCommand(), logger)
 		if err == nil {
 			return nil
 		}
+		var startupErr *PythonStartupError
+		if errors.Is(err, ErrStartTimeout) || errors.As(err, &startupErr) {
+			// retrying will time out or fail at python startup again
+			break
+		}
 		time.Sleep(time.Second * time.Duration(i*i)) // sleep temporarily before next try
 	}
 	logger.Error("failed to start plugin after max retries")
 	return errors.Wrap(err, "failed to start plugin after max retries")
 }
 
+// startRPCType returns type of plugin started, grpc or rpc
+func (p *hashicorpPlugin) startRPCType() rpcType {
+	if p.reattach != nil {
+		// attach to running plugin server, e.g. jupyter kernel or detached plugin
+		if p.reattach.Protocol == plugin.ProtocolNetRPC {
+			return rpcTypeRPC
+		}
+		return rpcTypeGRPC
+	} else if p.option.langType == langTypePython {
+		// hashicorp python plugin only supports gRPC
+		return rpcTypeGRPC
+	}
+	// hashicorp go plugin supports grpc and rpc
+	if rpcType(os.Getenv(fungo.PluginTypeEnvName)) == rpcTypeRPC {
+		return rpcTypeRPC
+	}
+	return rpcTypeGRPC // default
+}
+
+// newCommand returns plugin command for each start attempt since exec.Cmd
+// can not be reused, nil is returned when attaching to a running plugin server
+func (p *hashicorpPlugin) newCommand() *exec.Cmd {
+	if p.reattach != nil {
+		return nil
+	}
+	cmd := p.option.command(p.path)
+	cmd.Env = append(p.option.environ(), fmt.Sprintf("%s=%s", fungo.PluginTypeEnvName, p.rpcType))
+	if p.option.grpcReflection {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=true", fungo.GRPCReflectionEnvName))
+	}
+	return cmd
+}
+
 func (p *hashicorpPlugin) tryStartPlugin(cmd *exec.Cmd, logger hclog.Logger) error {
-	// launch the plugin process
-	p.client = plugin.NewClient(&plugin.ClientConfig{
+	if cmd != nil {
+		cleanup, err := p.option.passHostData(cmd)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+	}
+
+	// launch the plugin process, stderr is captured for startup errors
+	stderr := &stderrTail{}
+	client := plugin.NewClient(&plugin.ClientConfig{
 		HandshakeConfig: fungo.HandshakeConfig,
 		Plugins: map[string]plugin.Plugin{
 			rpcTypeRPC.String():  &fungo.RPCPlugin{},
-			rpcTypeGRPC.String(): &fungo.GRPCPlugin{},
+			rpcTypeGRPC.String(): &fungo.GRPCPlugin{NumberMode: p.option.jsonNumber},
 		},
-		Cmd:    cmd,
-		Logger: logger,
+		Cmd:          cmd,
+		Reattach:     p.reattach,
+		Logger:       logger,
+		StartTimeout: p.option.getStartTimeout(),
+		Stderr:       stderr,
 		AllowedProtocols: []plugin.Protocol{
 			plugin.ProtocolNetRPC,
 			plugin.ProtocolGRPC,
 		},
 	})
 
+	trackProcess(client, client.Kill)
+
 	// Connect via RPC/gRPC
-	rpcClient, err := p.client.Client()
+	rpcClient, err := client.Client()
 	if err != nil {
-		return errors.Wrap(err, fmt.Sprintf("connect %s plugin failed", p.rpcType))
+		defer p.discard(client)
+		if strings.Contains(err.Error(), "timeout while waiting for plugin to start") {
+			err = fmt.Errorf("%w after %v", ErrStartTimeout, p.option.getStartTimeout())
+		} else if startupErr := p.pythonStartupError(client, stderr); startupErr != nil {
+			return startupErr
+		}
+		return errors.Wrap(withStderr(err, stderr.String()),
+			fmt.Sprintf("connect %s plugin failed", p.rpcType))
 	}
 
 	// Request the plugin
 	raw, err := rpcClient.Dispense(p.rpcType.String())
 	if err != nil {
+		p.discard(client)
 		return errors.Wrap(err, fmt.Sprintf("request %s plugin failed", p.rpcType))
 	}
 
+	if p.option.grpcReflection && p.rpcType == rpcTypeGRPC {
+		if config := client.ReattachConfig(); config != nil {
+			logger.Info("gRPC reflection enabled on plugin server",
+				"network", config.Addr.Network(), "addr", config.Addr.String())
+		}
+	}
+
 	// We should have a Function now! This feels like a normal interface
 	// implementation but is in fact over an RPC connection.
-	p.funcCaller = raw.(fungo.IFuncCaller)
+	p.mu.Lock()
+	previous := p.client
+	p.client, p.funcCaller = client, raw.(fungo.IFuncCaller)
+	p.mu.Unlock()
+	if previous != nil {
+		untrackProcess(previous)
+	}
 
-	p.cachedFunctions = sync.Map{}
+	p.cachedFunctions.Range(func(key, _ interface{}) bool {
+		p.cachedFunctions.Delete(key)
+		return true
+	})
 
 	return nil
 }
 
+// discard kills plugin process failed to start, plugin servers attached to are left running
+func (p *hashicorpPlugin) discard(client *plugin.Client) {
+	if p.reattach == nil {
+		client.Kill()
+	}
+	untrackProcess(client)
+}
+
+// pythonStartupError returns uncaught exception of python plugin before handshake, plugin
+// stderr is read completely after plugin process exited, which is waited for a short while
+func (p *hashicorpPlugin) pythonStartupError(client *plugin.Client, stderr *stderrTail) *PythonStartupError {
+	if p.option.langType != langTypePython || p.reattach != nil {
+		return nil
+	}
+	for deadline := time.Now().Add(time.Second); !client.Exited() && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return stderr.startupError(p.path)
+}
+
 func (p *hashicorpPlugin) Quit() error {
 	// kill hashicorp plugin process
 	logger.Info("quit hashicorp plugin process")
-	p.client.Kill()
-	return fungo.CloseLogFile()
+	p.restartMu.Lock()
+	defer p.restartMu.Unlock()
+	client, _ := p.current()
+	client.Kill()
+	untrackProcess(client)
+	return nil
 }
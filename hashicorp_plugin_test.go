@@ -1,14 +1,21 @@
 package funplugin
 
 import (
+	"context"
+	"errors"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+
 	"github.com/lingcetech/funplugin/fungo"
 	"github.com/lingcetech/funplugin/myexec"
-	"github.com/stretchr/testify/assert"
 )
 
 var pluginBinPath = "fungo/examples/debugtalk.bin"
@@ -63,6 +70,89 @@ func TestHashicorpRPCGoPlugin(t *testing.T) {
 	assertPlugin(t, plugin)
 }
 
+func TestHashicorpUserError(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	for _, rpcType := range []string{"grpc", "rpc"} {
+		os.Setenv(fungo.PluginTypeEnvName, rpcType)
+		plugin, err := Init("fungo/examples/debugtalk.bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = plugin.Call("assert_equal", 1, 1)
+		assert.NoError(t, err)
+
+		_, err = plugin.Call("assert_equal", 1, 2)
+		var userErr *fungo.UserError
+		if assert.True(t, errors.As(err, &userErr), rpcType) {
+			assert.Equal(t, "AssertionError", userErr.Type)
+			assert.Equal(t, "expect 2, got 1", userErr.Message)
+		}
+
+		// infrastructure failures are not user errors
+		_, err = plugin.Call("not_exist")
+		assert.Error(t, err)
+		assert.False(t, fungo.IsUserError(err))
+		plugin.Quit()
+	}
+	os.Setenv(fungo.PluginTypeEnvName, "grpc")
+}
+
+func TestHashicorpGRPCReflection(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	os.Setenv(fungo.PluginTypeEnvName, "grpc")
+	plugin, err := Init("fungo/examples/debugtalk.bin", WithGRPCReflection(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	addr := backendOf(plugin).(*hashicorpPlugin).client.ReattachConfig().Addr
+	conn, err := grpc.Dial(addr.Network()+"://"+addr.String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = stream.Send(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var services []string
+	for _, service := range resp.GetListServicesResponse().GetService() {
+		services = append(services, service.Name)
+	}
+	assert.Contains(t, services, "proto.DebugTalk")
+}
+
+func TestHashicorpGoPluginSelfTest(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	// self-test runs without host
+	output, err := exec.Command(pluginBinPath, fungo.SelfTestFlag).Output()
+	assert.Nil(t, err)
+	assert.Contains(t, string(output), `PASS sum_two_int[1,2] => 3`)
+	assert.Contains(t, string(output), "SKIP auth_header: no samples")
+	assert.Contains(t, string(output), "0 failed")
+}
+
 func TestHashicorpPythonPluginWithVenv(t *testing.T) {
 	dir, err := os.MkdirTemp(os.TempDir(), "prefix")
 	if err != nil {
@@ -153,3 +243,8 @@ func assertPlugin(t *testing.T, plugin IPlugin) {
 		t.Fail()
 	}
 }
+
+// backendOf returns plugin backend wrapped by Init
+func backendOf(plugin IPlugin) pluginBackend {
+	return plugin.(*interceptedPlugin).pluginBackend
+}
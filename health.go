@@ -0,0 +1,171 @@
+package funplugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// status of HealthReport
+const (
+	HealthOK          = "ok"
+	HealthUnavailable = "unavailable"
+)
+
+// HealthConfig configures HealthHandler
+type HealthConfig struct {
+	PingTimeout time.Duration // timeout of pinging each plugin server, default 2s
+	// restarts of a plugin process failing liveness, e.g. crash looping plugin process
+	// makes service restarted by kubernetes, 0 means unlimited
+	MaxRestarts int
+}
+
+// PluginHealth is liveness of a plugin loaded by Manager
+type PluginHealth struct {
+	Tenant string `json:"tenant"`
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	// plugin process is running and handshake with plugin server succeeds, plugins
+	// running in host process are always alive
+	Alive    bool      `json:"alive"`
+	Pid      int       `json:"pid,omitempty"`
+	LastPing time.Time `json:"last_ping,omitempty"` // last time plugin server answered ping
+	Restarts int       `json:"restarts"`            // restarts of plugin process
+	Error    string    `json:"error,omitempty"`
+}
+
+// HealthReport is response body of HealthHandler
+type HealthReport struct {
+	Status  string         `json:"status"`          // HealthOK, or HealthUnavailable with status code 503
+	Error   string         `json:"error,omitempty"` // reason of HealthUnavailable
+	Plugins []PluginHealth `json:"plugins"`
+}
+
+// pinger is implemented by plugins whose plugin server answers ping, e.g. hashicorp plugins
+type pinger interface {
+	ping() error
+}
+
+// ping pings plugin server, plugins without plugin server are not pinged
+func (p *interceptedPlugin) ping() error {
+	if pinger, ok := p.pluginBackend.(pinger); ok {
+		return pinger.ping()
+	}
+	return nil
+}
+
+// HealthHandler returns HTTP handler serving liveness endpoint /healthz and readiness
+// endpoint /readyz of services hosting plugins of manager, aggregating liveness of all
+// managed plugins for load balancers and kubernetes probes:
+//
+//	GET /healthz => 200, or 503 if manager is shut down or a plugin process restarted
+//	                more than MaxRestarts times
+//	GET /readyz  => 200 if manager is not shut down and all plugins are alive, or 503
+//
+// Plugin servers are pinged on each request, and responses are JSON HealthReport.
+func HealthHandler(manager *Manager, config HealthConfig) http.Handler {
+	if config.PingTimeout <= 0 {
+		config.PingTimeout = 2 * time.Second
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		report := checkHealth(manager, config.PingTimeout)
+		if report.Status == HealthOK && config.MaxRestarts > 0 {
+			for _, plugin := range report.Plugins {
+				if plugin.Restarts > config.MaxRestarts {
+					report.Status = HealthUnavailable
+					report.Error = fmt.Sprintf("plugin %s of tenant %s restarted %d times",
+						plugin.Name, plugin.Tenant, plugin.Restarts)
+					break
+				}
+			}
+		}
+		writeHealthReport(w, report)
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		report := checkHealth(manager, config.PingTimeout)
+		if report.Status == HealthOK {
+			for _, plugin := range report.Plugins {
+				if !plugin.Alive {
+					report.Status = HealthUnavailable
+					report.Error = fmt.Sprintf("plugin %s of tenant %s is not alive: %s",
+						plugin.Name, plugin.Tenant, plugin.Error)
+					break
+				}
+			}
+		}
+		writeHealthReport(w, report)
+	})
+	return mux
+}
+
+// checkHealth pings plugins of manager concurrently and reports their liveness,
+// the report is unavailable if manager is shut down
+func checkHealth(manager *Manager, timeout time.Duration) HealthReport {
+	manager.mu.Lock()
+	closed := manager.closed
+	manager.mu.Unlock()
+	if closed {
+		return HealthReport{Status: HealthUnavailable, Error: ErrManagerClosed.Error(), Plugins: []PluginHealth{}}
+	}
+
+	described, plugins := manager.loaded()
+	report := HealthReport{Status: HealthOK, Plugins: make([]PluginHealth, len(plugins))}
+	var wg sync.WaitGroup
+	for i := range plugins {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			report.Plugins[i] = pluginHealth(described[i], plugins[i], timeout)
+		}(i)
+	}
+	wg.Wait()
+	return report
+}
+
+func pluginHealth(managed ManagedPlugin, plugin IPlugin, timeout time.Duration) PluginHealth {
+	health := PluginHealth{Tenant: managed.Tenant, Name: managed.Name, Type: managed.Type, Alive: true}
+	var err error
+	if pinger, ok := plugin.(pinger); ok {
+		err = pingTimeout(pinger, timeout)
+	}
+	if process := plugin.Snapshot().Process; process != nil {
+		health.Pid = process.Pid
+		health.LastPing = process.LastPing
+		health.Restarts = process.Restarts
+		if !process.Running {
+			err = fmt.Errorf("plugin process %d exited", process.Pid)
+		}
+	}
+	if err != nil {
+		health.Alive = false
+		health.Error = err.Error()
+	}
+	return health
+}
+
+// pingTimeout pings plugin server, giving up waiting for its answer after timeout
+func pingTimeout(p pinger, timeout time.Duration) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- p.ping()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("ping plugin timed out after %v", timeout)
+	}
+}
+
+func writeHealthReport(w http.ResponseWriter, report HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status != HealthOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		logger.Error("write health report failed", "error", err)
+	}
+}
@@ -0,0 +1,132 @@
+package funplugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+func TestHealthHandler(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	m := NewManager()
+	defer m.Shutdown()
+	_, err := m.Load("project-a", "lua", "lua/examples/debugtalk.lua")
+	assert.Nil(t, err)
+	plugin, err := m.Load("project-a", "go", pluginBinPath)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+
+	server := httptest.NewServer(HealthHandler(m, HealthConfig{MaxRestarts: 1}))
+	defer server.Close()
+	get := func(path string) (int, HealthReport) {
+		var report HealthReport
+		resp, err := http.Get(server.URL + path)
+		if !assert.Nil(t, err) {
+			return 0, report
+		}
+		defer resp.Body.Close()
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(&report))
+		return resp.StatusCode, report
+	}
+
+	code, report := get("/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, HealthOK, report.Status)
+	if assert.Len(t, report.Plugins, 2) {
+		assert.Equal(t, "go", report.Plugins[0].Name)
+		assert.True(t, report.Plugins[0].Alive)
+		assert.NotZero(t, report.Plugins[0].Pid)
+		assert.False(t, report.Plugins[0].LastPing.IsZero())
+		assert.Equal(t, PluginHealth{Tenant: "project-a", Name: "lua", Type: "lua-plugin", Alive: true}, report.Plugins[1])
+	}
+
+	// service is not ready while plugin process is down, but it is alive
+	backend := backendOf(plugin).(*hashicorpPlugin)
+	backend.client.Kill()
+	code, report = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, HealthUnavailable, report.Status)
+	assert.False(t, report.Plugins[0].Alive)
+	assert.Contains(t, report.Error, "plugin go of tenant project-a is not alive")
+	code, _ = get("/healthz")
+	assert.Equal(t, http.StatusOK, code)
+
+	assert.Nil(t, backend.restartProcess())
+	code, report = get("/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1, report.Plugins[0].Restarts)
+
+	// crash looping plugin fails liveness
+	assert.Nil(t, backend.restartProcess())
+	code, report = get("/healthz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "plugin go of tenant project-a restarted 2 times", report.Error)
+
+	m.Shutdown()
+	code, report = get("/healthz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, ErrManagerClosed.Error(), report.Error)
+	code, _ = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+}
+
+// serveFakeKernel serves lua example functions over gRPC like funppy.serve_kernel(),
+// which registers DebugTalk service only without grpc health service
+func serveFakeKernel(t *testing.T) string {
+	impl, err := newLuaPlugin("lua/examples/debugtalk.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	(&fungo.GRPCPlugin{Impl: impl}).GRPCServer(nil, server)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	connFile := filepath.Join(t.TempDir(), "kernel.json")
+	conn := fmt.Sprintf(`{"pid": %d, "addr": "%s", "protocol": "grpc"}`, os.Getpid(), listener.Addr())
+	if err := os.WriteFile(connFile, []byte(conn), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return connFile
+}
+
+func TestHealthHandlerPython(t *testing.T) {
+	m := NewManager()
+	defer m.Shutdown()
+	_, err := m.Load("project-a", "kernel", serveFakeKernel(t))
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	if exec.Command("python3", "-c", "import funppy").Run() == nil {
+		_, err = m.Load("project-a", "py", "funppy/examples/debugtalk.py", WithPython3("python3"))
+		assert.Nil(t, err)
+	}
+
+	// python plugin servers without grpc health service are alive
+	report := checkHealth(m, time.Second)
+	assert.Equal(t, HealthOK, report.Status)
+	assert.NotEmpty(t, report.Plugins)
+	for _, plugin := range report.Plugins {
+		assert.True(t, plugin.Alive, plugin.Name+": "+plugin.Error)
+		assert.False(t, plugin.LastPing.IsZero())
+	}
+}
@@ -0,0 +1,171 @@
+package history
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/lingcetech/funplugin"
+)
+
+// formats of Export and ExportStats
+const (
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+)
+
+// kinds of exported columns
+const (
+	kindString = iota
+	kindInt64
+	kindFloat64
+	kindTime // microseconds since unix epoch in Parquet
+)
+
+// column is a column of exported table, values are of Go types of its kind
+type column struct {
+	name   string
+	kind   int
+	values []interface{}
+}
+
+// Stats returns statistics of functions over calls matching query sorted by function name,
+// percentiles are calculated over all matching calls
+func (s *Store) Stats(query HistoryQuery) ([]funplugin.FuncStats, error) {
+	records, err := s.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	durations := make(map[string][]time.Duration)
+	stats := make(map[string]*funplugin.FuncStats)
+	for _, record := range records {
+		f, ok := stats[record.Function]
+		if !ok {
+			f = &funplugin.FuncStats{Name: record.Function}
+			stats[record.Function] = f
+		}
+		duration := time.Duration(record.DurationMs) * time.Millisecond
+		f.Calls++
+		if record.Error != "" {
+			f.Errors++
+		}
+		f.Total += duration
+		durations[record.Function] = append(durations[record.Function], duration)
+	}
+	result := make([]funplugin.FuncStats, 0, len(stats))
+	for name, f := range stats {
+		samples := durations[name]
+		sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+		f.P50 = funplugin.Percentile(samples, 0.50)
+		f.P95 = funplugin.Percentile(samples, 0.95)
+		result = append(result, *f)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// Export writes calls matching query to w in format of FormatCSV or FormatParquet,
+// one row per call in order of start time, for analysis in spreadsheets or data pipelines
+func (s *Store) Export(w io.Writer, format string, query HistoryQuery) error {
+	records, err := s.Query(query)
+	if err != nil {
+		return err
+	}
+	columns := []*column{
+		{name: "time", kind: kindTime},
+		{name: "user", kind: kindString},
+		{name: "host", kind: kindString},
+		{name: "plugin_type", kind: kindString},
+		{name: "plugin_path", kind: kindString},
+		{name: "function", kind: kindString},
+		{name: "args_hash", kind: kindString},
+		{name: "result_hash", kind: kindString},
+		{name: "error", kind: kindString},
+		{name: "duration_ms", kind: kindInt64},
+	}
+	for _, r := range records {
+		appendRow(columns, r.Time, r.User, r.Host, r.PluginType, r.PluginPath,
+			r.Function, r.ArgsHash, r.ResultHash, r.Error, r.DurationMs)
+	}
+	return export(w, format, columns, len(records))
+}
+
+// ExportStats writes Stats of calls matching query to w in format of FormatCSV or
+// FormatParquet, one row per function with durations in milliseconds
+func (s *Store) ExportStats(w io.Writer, format string, query HistoryQuery) error {
+	stats, err := s.Stats(query)
+	if err != nil {
+		return err
+	}
+	columns := []*column{
+		{name: "function", kind: kindString},
+		{name: "calls", kind: kindInt64},
+		{name: "errors", kind: kindInt64},
+		{name: "error_rate", kind: kindFloat64},
+		{name: "total_ms", kind: kindInt64},
+		{name: "p50_ms", kind: kindInt64},
+		{name: "p95_ms", kind: kindInt64},
+	}
+	for _, f := range stats {
+		appendRow(columns, f.Name, f.Calls, f.Errors, f.ErrorRate(),
+			f.Total.Milliseconds(), f.P50.Milliseconds(), f.P95.Milliseconds())
+	}
+	return export(w, format, columns, len(stats))
+}
+
+func appendRow(columns []*column, values ...interface{}) {
+	for i, value := range values {
+		columns[i].values = append(columns[i].values, value)
+	}
+}
+
+func export(w io.Writer, format string, columns []*column, rows int) error {
+	var err error
+	switch format {
+	case FormatCSV:
+		err = writeCSV(w, columns, rows)
+	case FormatParquet:
+		err = writeParquet(w, columns, rows)
+	default:
+		return errors.Errorf("unknown export format %s", format)
+	}
+	if err != nil {
+		return errors.Wrap(err, "export call history failed")
+	}
+	return nil
+}
+
+// writeCSV writes header of column names and rows, times are formatted in RFC 3339
+func writeCSV(w io.Writer, columns []*column, rows int) error {
+	writer := csv.NewWriter(w)
+	record := make([]string, len(columns))
+	for i, c := range columns {
+		record[i] = c.name
+	}
+	if err := writer.Write(record); err != nil {
+		return err
+	}
+	for row := 0; row < rows; row++ {
+		for i, c := range columns {
+			switch value := c.values[row].(type) {
+			case string:
+				record[i] = value
+			case int64:
+				record[i] = strconv.FormatInt(value, 10)
+			case float64:
+				record[i] = strconv.FormatFloat(value, 'f', -1, 64)
+			case time.Time:
+				record[i] = value.Format(time.RFC3339Nano)
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,120 @@
+// Package history records summaries of plugin function calls into SQLite for post-run
+// analysis of plugin behavior across many executions. Store is an audit sink of funplugin,
+// attached to plugins with funplugin.WithAuditLog. It requires cgo.
+package history
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+
+	"github.com/lingcetech/funplugin"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS calls (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	time        INTEGER NOT NULL, -- unix nanoseconds of call start
+	user        TEXT NOT NULL,
+	host        TEXT NOT NULL,
+	plugin_type TEXT NOT NULL,
+	plugin_path TEXT NOT NULL,
+	function    TEXT NOT NULL,
+	args_hash   TEXT NOT NULL,
+	result_hash TEXT NOT NULL,
+	error       TEXT NOT NULL,
+	duration_ms INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS calls_time ON calls (time);
+CREATE INDEX IF NOT EXISTS calls_function_time ON calls (function, time);
+`
+
+// HistoryQuery selects calls recorded in Store, zero values match all calls
+type HistoryQuery struct {
+	Func       string    // function name
+	Since      time.Time // calls started at or after
+	ErrorsOnly bool      // failed calls only
+}
+
+// Store records call summaries into SQLite database, it is safe for concurrent use
+// by plugins of a host, and hosts running one after another may share the database
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens or creates SQLite database of path as history store
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, errors.Wrap(err, "open history database failed")
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "create history schema failed")
+	}
+	return &Store{db: db}, nil
+}
+
+// Write records summary of a call, it implements funplugin.AuditSink
+func (s *Store) Write(record *funplugin.AuditRecord) error {
+	_, err := s.db.Exec(`INSERT INTO calls (time, user, host, plugin_type, plugin_path, function,
+		args_hash, result_hash, error, duration_ms) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		record.Time.UnixNano(), record.User, record.Host, record.PluginType, record.PluginPath,
+		record.Function, record.ArgsHash, record.ResultHash, record.Error, record.DurationMs)
+	if err != nil {
+		return errors.Wrap(err, "record call history failed")
+	}
+	return nil
+}
+
+// Query returns calls matching query in order of start time
+func (s *Store) Query(query HistoryQuery) ([]funplugin.AuditRecord, error) {
+	var conditions []string
+	var args []interface{}
+	if query.Func != "" {
+		conditions = append(conditions, "function = ?")
+		args = append(args, query.Func)
+	}
+	if !query.Since.IsZero() {
+		conditions = append(conditions, "time >= ?")
+		args = append(args, query.Since.UnixNano())
+	}
+	if query.ErrorsOnly {
+		conditions = append(conditions, "error != ''")
+	}
+	statement := `SELECT time, user, host, plugin_type, plugin_path, function,
+		args_hash, result_hash, error, duration_ms FROM calls`
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	statement += " ORDER BY time, id"
+
+	rows, err := s.db.Query(statement, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "query call history failed")
+	}
+	defer rows.Close()
+	var records []funplugin.AuditRecord
+	for rows.Next() {
+		var record funplugin.AuditRecord
+		var start int64
+		if err := rows.Scan(&start, &record.User, &record.Host, &record.PluginType, &record.PluginPath,
+			&record.Function, &record.ArgsHash, &record.ResultHash, &record.Error, &record.DurationMs); err != nil {
+			return nil, errors.Wrap(err, "read call history failed")
+		}
+		record.Time = time.Unix(0, start).UTC()
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "read call history failed")
+	}
+	return records, nil
+}
+
+// Close closes the database after plugins recording into it quit
+func (s *Store) Close() error {
+	return s.db.Close()
+}
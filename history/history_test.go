@@ -0,0 +1,120 @@
+package history
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lingcetech/funplugin"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	store, err := Open(path)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	plugin, err := funplugin.Init("../lua/examples/debugtalk.lua", funplugin.WithAuditLog(store, nil))
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	_, err = plugin.Call("sum_ints", 1, 2)
+	assert.Nil(t, err)
+	_, err = plugin.Call("concatenate", "a", "b")
+	assert.Nil(t, err)
+	since := time.Now()
+	_, err = plugin.Call("sum_ints", "a")
+	assert.NotNil(t, err)
+	plugin.Quit()
+	assert.Nil(t, store.Close())
+
+	// history is kept across executions
+	store, err = Open(path)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	defer store.Close()
+	records, err := store.Query(HistoryQuery{})
+	assert.Nil(t, err)
+	if assert.Len(t, records, 3) {
+		assert.Equal(t, "sum_ints", records[0].Function)
+		assert.Equal(t, "concatenate", records[1].Function)
+		assert.Equal(t, "lua-plugin", records[0].PluginType)
+	}
+
+	records, err = store.Query(HistoryQuery{Func: "sum_ints"})
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+	records, err = store.Query(HistoryQuery{ErrorsOnly: true})
+	assert.Nil(t, err)
+	if assert.Len(t, records, 1) {
+		assert.NotEmpty(t, records[0].Error)
+	}
+	records, err = store.Query(HistoryQuery{Func: "concatenate", Since: since})
+	assert.Nil(t, err)
+	assert.Empty(t, records)
+}
+
+func TestExport(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	defer store.Close()
+	start := time.Date(2024, 9, 1, 8, 0, 0, 0, time.UTC)
+	for i, durationMs := range []int64{10, 30, 20} {
+		record := &funplugin.AuditRecord{
+			Time: start.Add(time.Duration(i) * time.Second), User: "tester", Host: "ci",
+			PluginType: "lua-plugin", PluginPath: "debugtalk.lua", Function: "sum_ints", DurationMs: durationMs,
+		}
+		if i == 2 {
+			record.Function = "concatenate"
+			record.Error = "bad, \"quoted\" argument"
+		}
+		assert.Nil(t, store.Write(record))
+	}
+
+	stats, err := store.Stats(HistoryQuery{})
+	assert.Nil(t, err)
+	if assert.Len(t, stats, 2) {
+		assert.Equal(t, "concatenate", stats[0].Name)
+		assert.EqualValues(t, 1, stats[0].Errors)
+		assert.EqualValues(t, 2, stats[1].Calls)
+		assert.Equal(t, 40*time.Millisecond, stats[1].Total)
+		assert.Equal(t, 30*time.Millisecond, stats[1].P95)
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(t, store.Export(&buf, FormatCSV, HistoryQuery{ErrorsOnly: true}))
+	assert.Equal(t, "time,user,host,plugin_type,plugin_path,function,args_hash,result_hash,error,duration_ms\n"+
+		"2024-09-01T08:00:02Z,tester,ci,lua-plugin,debugtalk.lua,concatenate,,,\"bad, \"\"quoted\"\" argument\",20\n",
+		buf.String())
+	buf.Reset()
+	assert.Nil(t, store.ExportStats(&buf, FormatCSV, HistoryQuery{}))
+	assert.Equal(t, "function,calls,errors,error_rate,total_ms,p50_ms,p95_ms\n"+
+		"concatenate,1,1,1,20,20,20\nsum_ints,2,0,0,40,10,30\n", buf.String())
+
+	for _, query := range []HistoryQuery{{}, {Func: "unknown"}} {
+		buf.Reset()
+		assert.Nil(t, store.Export(&buf, FormatParquet, query))
+		data := buf.Bytes()
+		if !assert.Greater(t, len(data), 12) {
+			continue
+		}
+		assert.Equal(t, "PAR1", string(data[:4]))
+		assert.Equal(t, "PAR1", string(data[len(data)-4:]))
+		footer := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+		assert.LessOrEqual(t, footer, len(data)-12)
+		assert.Contains(t, string(data[len(data)-8-footer:]), "duration_ms")
+	}
+	// values of string columns are PLAIN encoded with length prefixes
+	buf.Reset()
+	assert.Nil(t, store.Export(&buf, FormatParquet, HistoryQuery{Func: "sum_ints"}))
+	assert.Contains(t, buf.String(), "\x08\x00\x00\x00sum_ints\x08\x00\x00\x00sum_ints")
+
+	assert.NotNil(t, store.Export(&buf, "xlsx", HistoryQuery{}))
+}
@@ -0,0 +1,269 @@
+package history
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+)
+
+// Parquet files are written with a minimal encoder of the format, instead of depending on
+// a Parquet library: a single row group of required columns, each column in a single
+// uncompressed data page of PLAIN encoding, described by metadata in Thrift compact protocol.
+// See https://github.com/apache/parquet-format for the format.
+
+const parquetMagic = "PAR1"
+
+// values of Parquet enums
+const (
+	parquetInt64     = 2 // Type
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetRequired = 0 // FieldRepetitionType
+
+	parquetUTF8            = 0 // ConvertedType
+	parquetTimestampMicros = 10
+
+	parquetPlain = 0 // Encoding
+	parquetRLE   = 3
+
+	parquetUncompressed = 0 // CompressionCodec
+
+	parquetDataPage = 0 // PageType
+)
+
+// types of Thrift compact protocol
+const (
+	compactI32    = 5
+	compactI64    = 6
+	compactBinary = 8
+	compactList   = 9
+	compactStruct = 12
+)
+
+// compactWriter encodes Thrift structs in compact protocol, fields must be written
+// in order of their ids
+type compactWriter struct {
+	buf  bytes.Buffer
+	last []int16 // ids of last fields written in nested structs
+}
+
+func (w *compactWriter) uvarint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	w.buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func (w *compactWriter) varint(v int64) {
+	w.uvarint(uint64(v<<1) ^ uint64(v>>63))
+}
+
+func (w *compactWriter) field(id int16, typ byte) {
+	last := &w.last[len(w.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.varint(int64(id))
+	}
+	*last = id
+}
+
+// begin starts a struct, as the top level struct, a list element or after structField
+func (w *compactWriter) begin() {
+	w.last = append(w.last, 0)
+}
+
+func (w *compactWriter) end() {
+	w.buf.WriteByte(0)
+	w.last = w.last[:len(w.last)-1]
+}
+
+func (w *compactWriter) i32(id int16, v int32) {
+	w.field(id, compactI32)
+	w.varint(int64(v))
+}
+
+func (w *compactWriter) i64(id int16, v int64) {
+	w.field(id, compactI64)
+	w.varint(v)
+}
+
+func (w *compactWriter) string(s string) {
+	w.uvarint(uint64(len(s)))
+	w.buf.WriteString(s)
+}
+
+func (w *compactWriter) binary(id int16, s string) {
+	w.field(id, compactBinary)
+	w.string(s)
+}
+
+func (w *compactWriter) structField(id int16) {
+	w.field(id, compactStruct)
+	w.begin()
+}
+
+// list starts a list field, followed by size elements of typ
+func (w *compactWriter) list(id int16, typ byte, size int) {
+	w.field(id, compactList)
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | typ)
+	} else {
+		w.buf.WriteByte(0xf0 | typ)
+		w.uvarint(uint64(size))
+	}
+}
+
+// parquetType returns physical type and converted type of column, converted type
+// is -1 if the column has none
+func (c *column) parquetType() (int32, int32) {
+	switch c.kind {
+	case kindInt64:
+		return parquetInt64, -1
+	case kindFloat64:
+		return parquetDouble, -1
+	case kindTime:
+		return parquetInt64, parquetTimestampMicros
+	default:
+		return parquetByteArray, parquetUTF8
+	}
+}
+
+// plain encodes values of column in PLAIN encoding
+func (c *column) plain() []byte {
+	var buf bytes.Buffer
+	var b [8]byte
+	for _, value := range c.values {
+		switch value := value.(type) {
+		case string:
+			binary.LittleEndian.PutUint32(b[:4], uint32(len(value)))
+			buf.Write(b[:4])
+			buf.WriteString(value)
+		case int64:
+			binary.LittleEndian.PutUint64(b[:], uint64(value))
+			buf.Write(b[:])
+		case float64:
+			binary.LittleEndian.PutUint64(b[:], math.Float64bits(value))
+			buf.Write(b[:])
+		case time.Time:
+			binary.LittleEndian.PutUint64(b[:], uint64(value.UnixNano()/int64(time.Microsecond)))
+			buf.Write(b[:])
+		}
+	}
+	return buf.Bytes()
+}
+
+// countingWriter counts bytes written for offsets of column chunks
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	w.err = err
+	return n, err
+}
+
+// columnChunk is position of a column chunk written in file
+type columnChunk struct {
+	offset int64
+	size   int64
+}
+
+// writeParquet writes columns of rows as Parquet file, files without rows have no row group
+func writeParquet(w io.Writer, columns []*column, rows int) error {
+	out := &countingWriter{w: w}
+	io.WriteString(out, parquetMagic)
+
+	var chunks []columnChunk
+	var total int64
+	if rows > 0 {
+		for _, c := range columns {
+			data := c.plain()
+			header := &compactWriter{}
+			header.begin()
+			header.i32(1, parquetDataPage)
+			header.i32(2, int32(len(data)))
+			header.i32(3, int32(len(data)))
+			header.structField(5) // DataPageHeader
+			header.i32(1, int32(rows))
+			header.i32(2, parquetPlain)
+			header.i32(3, parquetRLE) // no levels are written for required columns
+			header.i32(4, parquetRLE)
+			header.end()
+			header.end()
+
+			chunk := columnChunk{offset: out.n, size: int64(header.buf.Len() + len(data))}
+			out.Write(header.buf.Bytes())
+			out.Write(data)
+			chunks = append(chunks, chunk)
+			total += chunk.size
+		}
+	}
+
+	meta := &compactWriter{}
+	meta.begin() // FileMetaData
+	meta.i32(1, 1)
+	meta.list(2, compactStruct, len(columns)+1)
+	meta.begin() // root SchemaElement
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(columns)))
+	meta.end()
+	for _, c := range columns {
+		typ, converted := c.parquetType()
+		meta.begin()
+		meta.i32(1, typ)
+		meta.i32(3, parquetRequired)
+		meta.binary(4, c.name)
+		if converted >= 0 {
+			meta.i32(6, converted)
+		}
+		meta.end()
+	}
+	meta.i64(3, int64(rows))
+	if len(chunks) == 0 {
+		meta.list(4, compactStruct, 0)
+	} else {
+		meta.list(4, compactStruct, 1)
+		meta.begin() // RowGroup
+		meta.list(1, compactStruct, len(columns))
+		for i, c := range columns {
+			typ, _ := c.parquetType()
+			meta.begin() // ColumnChunk
+			meta.i64(2, chunks[i].offset)
+			meta.structField(3) // ColumnMetaData
+			meta.i32(1, typ)
+			meta.list(2, compactI32, 1)
+			meta.varint(parquetPlain)
+			meta.list(3, compactBinary, 1)
+			meta.string(c.name)
+			meta.i32(4, parquetUncompressed)
+			meta.i64(5, int64(rows))
+			meta.i64(6, chunks[i].size)
+			meta.i64(7, chunks[i].size)
+			meta.i64(9, chunks[i].offset)
+			meta.end()
+			meta.end()
+		}
+		meta.i64(2, total)
+		meta.i64(3, int64(rows))
+		meta.end()
+	}
+	meta.binary(6, "funplugin")
+	meta.end()
+
+	out.Write(meta.buf.Bytes())
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(meta.buf.Len()))
+	out.Write(length[:])
+	io.WriteString(out, parquetMagic)
+	return out.err
+}
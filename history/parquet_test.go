@@ -0,0 +1,273 @@
+package history
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lingcetech/funplugin"
+)
+
+// thriftStruct is a decoded thrift struct keyed by field id
+type thriftStruct map[int16]interface{}
+
+// compactReader decodes thrift compact protocol independently of compactWriter,
+// integers are decoded into int64, binaries into string and lists into []interface{}
+type compactReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *compactReader) byte() byte {
+	if r.pos >= len(r.data) {
+		panic(fmt.Sprintf("unexpected end of data at %d", r.pos))
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *compactReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		panic(fmt.Sprintf("bad varint at %d", r.pos))
+	}
+	r.pos += n
+	return v
+}
+
+func (r *compactReader) varint() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *compactReader) bytes(n int) []byte {
+	if n < 0 || r.pos+n > len(r.data) {
+		panic(fmt.Sprintf("bad length %d at %d", n, r.pos))
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *compactReader) readStruct() thriftStruct {
+	s := thriftStruct{}
+	var last int16
+	for {
+		header := r.byte()
+		if header == 0 {
+			return s
+		}
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.varint())
+		}
+		last = id
+		s[id] = r.value(header&0x0f, false)
+	}
+}
+
+func (r *compactReader) value(typ byte, inList bool) interface{} {
+	switch typ {
+	case 1, 2:
+		if inList {
+			return r.byte() == 1
+		}
+		return typ == 1
+	case 3:
+		return int64(int8(r.byte()))
+	case 4, 5, 6:
+		return r.varint()
+	case 7:
+		return math.Float64frombits(binary.LittleEndian.Uint64(r.bytes(8)))
+	case 8:
+		return string(r.bytes(int(r.uvarint())))
+	case 9, 10:
+		header := r.byte()
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		list := make([]interface{}, 0, size)
+		for i := 0; i < size; i++ {
+			list = append(list, r.value(header&0x0f, true))
+		}
+		return list
+	case 12:
+		return r.readStruct()
+	}
+	panic(fmt.Sprintf("unsupported type %d at %d", typ, r.pos))
+}
+
+// decodeStruct decodes one struct at offset of data, returning it with its end offset
+func decodeStruct(t *testing.T, data []byte, offset int) (s thriftStruct, end int) {
+	defer func() {
+		if err := recover(); err != nil {
+			t.Fatalf("decode thrift struct at %d failed: %v", offset, err)
+		}
+	}()
+	r := &compactReader{data: data, pos: offset}
+	s = r.readStruct()
+	return s, r.pos
+}
+
+// decodeFooter checks the magic numbers and decodes FileMetaData of a Parquet file
+func decodeFooter(t *testing.T, data []byte) thriftStruct {
+	if !assert.Greater(t, len(data), 12) {
+		t.FailNow()
+	}
+	assert.Equal(t, "PAR1", string(data[:4]))
+	assert.Equal(t, "PAR1", string(data[len(data)-4:]))
+	footer := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	if !assert.LessOrEqual(t, footer, len(data)-12) {
+		t.FailNow()
+	}
+	meta, end := decodeStruct(t, data, len(data)-8-footer)
+	assert.Equal(t, len(data)-8, end, "footer length should cover exactly FileMetaData")
+	return meta
+}
+
+func TestExportParquet(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	defer store.Close()
+	start := time.Date(2024, 9, 1, 8, 0, 0, 0, time.UTC)
+	functions := []string{"sum_ints", "concatenate", "sum_ints"}
+	for i, function := range functions {
+		assert.Nil(t, store.Write(&funplugin.AuditRecord{
+			Time: start.Add(time.Duration(i) * time.Second), User: "tester", Host: "ci",
+			PluginType: "lua-plugin", PluginPath: "debugtalk.lua", Function: function,
+			DurationMs: int64(10 * (i + 1)),
+		}))
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(t, store.Export(&buf, FormatParquet, HistoryQuery{}))
+	data := buf.Bytes()
+	meta := decodeFooter(t, data)
+	assert.EqualValues(t, 1, meta[1])
+	assert.EqualValues(t, 3, meta[3])
+	assert.Equal(t, "funplugin", meta[6])
+
+	// schema is a root element followed by one required leaf per column
+	names := []string{"time", "user", "host", "plugin_type", "plugin_path",
+		"function", "args_hash", "result_hash", "error", "duration_ms"}
+	schema, _ := meta[2].([]interface{})
+	if !assert.Len(t, schema, len(names)+1) {
+		t.FailNow()
+	}
+	root := schema[0].(thriftStruct)
+	assert.Equal(t, "schema", root[4])
+	assert.EqualValues(t, len(names), root[5])
+	types := map[string]int64{}
+	for i, name := range names {
+		element := schema[i+1].(thriftStruct)
+		assert.Equal(t, name, element[4])
+		assert.EqualValues(t, 0, element[3], "column %s should be required", name)
+		types[name] = element[1].(int64)
+		switch name {
+		case "time":
+			assert.EqualValues(t, 2, element[1])  // INT64
+			assert.EqualValues(t, 10, element[6]) // TIMESTAMP_MICROS
+		case "duration_ms":
+			assert.EqualValues(t, 2, element[1])
+			assert.NotContains(t, element, int16(6))
+		default:
+			assert.EqualValues(t, 6, element[1]) // BYTE_ARRAY
+			assert.EqualValues(t, 0, element[6]) // UTF8
+		}
+	}
+
+	// column chunks are laid out back to back after the leading magic number
+	rowGroups, _ := meta[4].([]interface{})
+	if !assert.Len(t, rowGroups, 1) {
+		t.FailNow()
+	}
+	rowGroup := rowGroups[0].(thriftStruct)
+	assert.EqualValues(t, 3, rowGroup[3])
+	chunks, _ := rowGroup[1].([]interface{})
+	if !assert.Len(t, chunks, len(names)) {
+		t.FailNow()
+	}
+	footer := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	offset, total := int64(4), int64(0)
+	pages := map[string][]byte{}
+	for i, name := range names {
+		chunk := chunks[i].(thriftStruct)
+		columnMeta := chunk[3].(thriftStruct)
+		assert.Equal(t, offset, chunk[2], "file offset of column %s", name)
+		assert.Equal(t, offset, columnMeta[9], "data page offset of column %s", name)
+		assert.Equal(t, types[name], columnMeta[1])
+		assert.Equal(t, []interface{}{name}, columnMeta[3])
+		assert.EqualValues(t, 3, columnMeta[5])
+		size := columnMeta[7].(int64)
+		assert.Equal(t, size, columnMeta[6])
+
+		// each chunk is a single uncompressed PLAIN data page
+		header, end := decodeStruct(t, data, int(offset))
+		assert.EqualValues(t, 0, header[1])
+		pageSize := header[3].(int64)
+		assert.Equal(t, header[2], header[3])
+		assert.Equal(t, size, int64(end)-offset+pageSize)
+		pageHeader := header[5].(thriftStruct)
+		assert.EqualValues(t, 3, pageHeader[1])
+		assert.EqualValues(t, 0, pageHeader[2])
+		if !assert.LessOrEqual(t, int64(end)+pageSize, int64(len(data)-8-footer)) {
+			t.FailNow()
+		}
+		pages[name] = data[end : int64(end)+pageSize]
+		offset += size
+		total += size
+	}
+	assert.Equal(t, int64(len(data)-8-footer), offset)
+	assert.Equal(t, total, rowGroup[2])
+
+	// values are PLAIN encoded: little endian int64 and length prefixed strings
+	for i, function := range functions {
+		page := pages["function"]
+		length := int(binary.LittleEndian.Uint32(page))
+		assert.Equal(t, function, string(page[4:4+length]))
+		pages["function"] = page[4+length:]
+		assert.EqualValues(t, 10*(i+1), int64(binary.LittleEndian.Uint64(pages["duration_ms"][8*i:])))
+		assert.Equal(t, start.Add(time.Duration(i)*time.Second).UnixMicro(),
+			int64(binary.LittleEndian.Uint64(pages["time"][8*i:])))
+	}
+	assert.Empty(t, pages["function"])
+	assert.Len(t, pages["duration_ms"], 24)
+
+	// an empty export keeps the schema without row groups
+	buf.Reset()
+	assert.Nil(t, store.Export(&buf, FormatParquet, HistoryQuery{Func: "unknown"}))
+	meta = decodeFooter(t, buf.Bytes())
+	assert.EqualValues(t, 0, meta[3])
+	assert.Len(t, meta[2], len(names)+1)
+	assert.Empty(t, meta[4])
+}
+
+func TestExportStatsParquet(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	defer store.Close()
+	assert.Nil(t, store.Write(&funplugin.AuditRecord{Time: time.Now(), Function: "sum_ints", DurationMs: 5}))
+
+	var buf bytes.Buffer
+	assert.Nil(t, store.ExportStats(&buf, FormatParquet, HistoryQuery{}))
+	meta := decodeFooter(t, buf.Bytes())
+	assert.EqualValues(t, 1, meta[3])
+	schema, _ := meta[2].([]interface{})
+	if assert.Len(t, schema, 8) {
+		errorRate := schema[4].(thriftStruct)
+		assert.Equal(t, "error_rate", errorRate[4])
+		assert.EqualValues(t, 5, errorRate[1]) // DOUBLE
+	}
+}
@@ -0,0 +1,48 @@
+package funplugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+// hostCapabilities are advertised to plugins, which feature-detect them with
+// fungo.HostSupports or funppy.host_supports
+var hostCapabilities = []string{
+	fungo.CapabilityCallMetadata,
+	fungo.CapabilityPluginConfig,
+	fungo.CapabilitySecrets,
+	fungo.CapabilityDataFiles,
+	fungo.CapabilityArtifacts,
+	fungo.CapabilityBigValues,
+	fungo.CapabilityTimeValues,
+	fungo.CapabilityUserErrors,
+	fungo.CapabilitySeed,
+}
+
+// hostInfo returns identity and capabilities of host
+func hostInfo() fungo.HostInfo {
+	return fungo.HostInfo{
+		Name:         "funplugin",
+		Version:      fungo.Version,
+		GoVersion:    runtime.Version(),
+		OS:           runtime.GOOS,
+		Arch:         runtime.GOARCH,
+		Pid:          os.Getpid(),
+		Capabilities: hostCapabilities,
+	}
+}
+
+// hostInfoJSON returns host info sent to plugin process at startup
+func hostInfoJSON() string {
+	content, _ := json.Marshal(hostInfo())
+	return string(content)
+}
+
+// hostInfoEnv returns env passing host info to plugin process
+func hostInfoEnv() string {
+	return fmt.Sprintf("%s=%s", fungo.HostInfoEnvName, hostInfoJSON())
+}
@@ -0,0 +1,40 @@
+package funplugin
+
+import (
+	"encoding/json"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+func TestHostInfoEnv(t *testing.T) {
+	var info fungo.HostInfo
+	assert.Nil(t, json.Unmarshal([]byte(hostInfoJSON()), &info))
+	assert.Equal(t, "funplugin", info.Name)
+	assert.Equal(t, fungo.Version, info.Version)
+	assert.Equal(t, runtime.GOOS, info.OS)
+	assert.True(t, info.Supports(fungo.CapabilityCallMetadata))
+	assert.False(t, info.Supports("streaming"))
+}
+
+func TestHostInfoGoPlugin(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	for _, transport := range []string{"", "stdio"} {
+		plugin, err := Init(pluginBinPath, WithTransport(transport))
+		if err != nil {
+			t.Fatal(err)
+		}
+		supported, err := plugin.Call("host_supports", fungo.CapabilityArtifacts)
+		assert.Nil(t, err)
+		assert.Equal(t, true, supported)
+		supported, err = plugin.Call("host_supports", "streaming")
+		assert.Nil(t, err)
+		assert.Equal(t, false, supported)
+		plugin.Quit()
+	}
+}
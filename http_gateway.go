@@ -0,0 +1,73 @@
+package funplugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const httpCallPrefix = "/call/"
+
+// httpCallResponse is the JSON body responded by HTTP gateway
+type httpCallResponse struct {
+	Value interface{} `json:"value"`
+	Error string      `json:"error,omitempty"`
+}
+
+// NewHTTPHandler returns HTTP handler exposing plugin functions as REST API.
+//
+//	POST /call/{func} with JSON array arguments, e.g. [1, 2]
+//	=> 200 {"value": 3}, or {"error": "..."} with 400/404/500 status code
+//
+// Besides, GET /ws is upgraded to websocket bridge, see NewWebSocketHandler.
+func NewHTTPHandler(p IPlugin) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/ws", NewWebSocketHandler(p))
+	mux.HandleFunc(httpCallPrefix, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeHTTPResponse(w, http.StatusMethodNotAllowed,
+				httpCallResponse{Error: fmt.Sprintf("method %s not allowed", r.Method)})
+			return
+		}
+
+		funcName := strings.TrimPrefix(r.URL.Path, httpCallPrefix)
+		if funcName == "" || !p.Has(funcName) {
+			writeHTTPResponse(w, http.StatusNotFound,
+				httpCallResponse{Error: fmt.Sprintf("function %s not found", funcName)})
+			return
+		}
+
+		var args []interface{}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+				writeHTTPResponse(w, http.StatusBadRequest,
+					httpCallResponse{Error: fmt.Sprintf("invalid JSON array arguments: %v", err)})
+				return
+			}
+		}
+
+		logger.Debug("http gateway call function", "funcName", funcName, "args", args)
+		value, err := p.Call(funcName, args...)
+		if err != nil {
+			writeHTTPResponse(w, http.StatusInternalServerError, httpCallResponse{Error: err.Error()})
+			return
+		}
+		writeHTTPResponse(w, http.StatusOK, httpCallResponse{Value: value})
+	})
+	return mux
+}
+
+func writeHTTPResponse(w http.ResponseWriter, statusCode int, resp httpCallResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logger.Error("write http gateway response failed", "error", err)
+	}
+}
+
+// ServeHTTP serves plugin functions over HTTP on addr, it blocks until the server fails
+func ServeHTTP(p IPlugin, addr string) error {
+	logger.Info("serve plugin functions over HTTP", "addr", addr, "path", p.Path())
+	return http.ListenAndServe(addr, NewHTTPHandler(p))
+}
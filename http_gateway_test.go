@@ -0,0 +1,61 @@
+package funplugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPGateway(t *testing.T) {
+	plugin, err := Init("lua/examples/debugtalk.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	server := httptest.NewServer(NewHTTPHandler(plugin))
+	defer server.Close()
+
+	testData := []struct {
+		method     string
+		path       string
+		body       string
+		statusCode int
+		expected   httpCallResponse
+	}{
+		{http.MethodPost, "/call/sum_two_int", "[1, 2]", http.StatusOK, httpCallResponse{Value: 3.0}},
+		{http.MethodPost, "/call/concatenate", `["a", 1]`, http.StatusOK, httpCallResponse{Value: "a1"}},
+		{http.MethodPost, "/call/divide", "[1, 0]", http.StatusInternalServerError,
+			httpCallResponse{Error: "division by zero"}},
+		{http.MethodPost, "/call/not_exist", "[]", http.StatusNotFound,
+			httpCallResponse{Error: "function not_exist not found"}},
+		{http.MethodPost, "/call/sum_two_int", "{", http.StatusBadRequest,
+			httpCallResponse{Error: "invalid JSON array arguments: unexpected EOF"}},
+		{http.MethodGet, "/call/sum_two_int", "", http.StatusMethodNotAllowed,
+			httpCallResponse{Error: "method GET not allowed"}},
+	}
+
+	for _, td := range testData {
+		req, err := http.NewRequest(td.method, server.URL+td.path, strings.NewReader(td.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var result httpCallResponse
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, td.statusCode, resp.StatusCode, td.path)
+		assert.Equal(t, td.expected, result, td.path)
+	}
+}
@@ -0,0 +1,419 @@
+package funplugin
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/lingcetech/funplugin/fungo"
+	"github.com/lingcetech/funplugin/fungo/protoGen"
+)
+
+// HubAnonymousClient is the Manager tenant of clients of Hub without tokens
+const HubAnonymousClient = "anonymous"
+
+// hubPluginKey is gRPC metadata key of plugin name in calls to Hub
+const hubPluginKey = "funplugin-hub-plugin"
+
+// Hub serves plugins of Manager to remote hosts connecting with ConnectHub, e.g. for
+// running plugins on a dedicated machine. Each client is a tenant of the Manager, thus
+// quotas of clients are set by WithTenantQuota and WithDefaultQuota of the Manager.
+// Hub denies clients not authenticated by WithHubClient or WithHubAnonymous, and
+// loads plugins only inside WithHubRoot.
+type Hub struct {
+	protoGen.UnimplementedPluginHubServer
+	manager   *Manager
+	tokens    map[string]string // client names by token
+	anonymous bool              // accept clients without tokens, see WithHubAnonymous
+	root      string            // directory of plugin paths, see WithHubRoot
+}
+
+// HubOption configures Hub created by NewHub
+type HubOption func(*Hub)
+
+// WithHubClient authenticates client by token sent with ConnectHub
+func WithHubClient(client, token string) HubOption {
+	return func(h *Hub) {
+		h.tokens[token] = client
+	}
+}
+
+// WithHubAnonymous accepts clients without valid tokens as HubAnonymousClient,
+// which is for trusted networks only
+func WithHubAnonymous() HubOption {
+	return func(h *Hub) {
+		h.anonymous = true
+	}
+}
+
+// WithHubRoot resolves plugin paths of clients in dir, clients can not load plugins
+// outside of it. It is required, hub without root refuses to load plugins.
+func WithHubRoot(dir string) HubOption {
+	return func(h *Hub) {
+		h.root = dir
+	}
+}
+
+// NewHub creates Hub serving plugins loaded by manager
+func NewHub(manager *Manager, options ...HubOption) *Hub {
+	h := &Hub{manager: manager, tokens: make(map[string]string)}
+	for _, o := range options {
+		o(h)
+	}
+	return h
+}
+
+// Register registers services of hub to gRPC server, e.g. server with TLS credentials
+func (h *Hub) Register(s *grpc.Server) {
+	protoGen.RegisterPluginHubServer(s, h)
+	(&fungo.GRPCPlugin{Impl: &hubCaller{hub: h}}).GRPCServer(nil, s)
+}
+
+// Serve serves hub on listener without TLS until listener is closed,
+// plugins stay loaded by manager after Serve returns
+func (h *Hub) Serve(listener net.Listener) error {
+	server := grpc.NewServer()
+	h.Register(server)
+	logger.Info("serve plugin hub", "addr", listener.Addr(), "root", h.root)
+	return server.Serve(listener)
+}
+
+// client authenticates client of request by its token
+func (h *Hub) client(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		token := strings.TrimPrefix(value, "Bearer ")
+		for known, client := range h.tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(known)) == 1 {
+				return client, nil
+			}
+		}
+	}
+	if h.anonymous {
+		return HubAnonymousClient, nil
+	}
+	return "", status.Error(codes.Unauthenticated, "invalid hub token")
+}
+
+// pluginPath resolves plugin path of client in root
+func (h *Hub) pluginPath(path string) string {
+	return filepath.Join(h.root, filepath.Clean(string(filepath.Separator)+path))
+}
+
+// Init loads plugin for client by manager
+func (h *Hub) Init(ctx context.Context, req *protoGen.InitRequest) (*protoGen.InitResponse, error) {
+	client, err := h.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name == "" || req.Path == "" {
+		return nil, status.Error(codes.InvalidArgument, "plugin name and path are required")
+	}
+	if h.root == "" {
+		return nil, status.Error(codes.FailedPrecondition, "plugin root of hub is not set")
+	}
+	path := h.pluginPath(req.Path)
+	plugin, err := h.manager.Load(client, req.Name, path)
+	if err != nil {
+		logger.Error("load plugin for hub client failed", "client", client, "path", path, "error", err)
+		return nil, hubError(err)
+	}
+	logger.Info("plugin loaded for hub client", "client", client, "name", req.Name, "path", path)
+	return &protoGen.InitResponse{Type: plugin.Type()}, nil
+}
+
+// GetNames lists functions of plugin of client
+func (h *Hub) GetNames(ctx context.Context, req *protoGen.PluginRequest) (*protoGen.GetNamesResponse, error) {
+	plugin, err := h.plugin(ctx, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	lister, ok := plugin.(IFuncLister)
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "plugin %s does not support listing functions", plugin.Type())
+	}
+	names, err := lister.GetNames()
+	if err != nil {
+		return nil, err
+	}
+	return &protoGen.GetNamesResponse{Names: names}, nil
+}
+
+// Quit unloads plugin of client
+func (h *Hub) Quit(ctx context.Context, req *protoGen.PluginRequest) (*protoGen.Empty, error) {
+	client, err := h.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.manager.Unload(client, req.Name); err != nil {
+		return nil, hubError(err)
+	}
+	logger.Info("plugin unloaded for hub client", "client", client, "name", req.Name)
+	return &protoGen.Empty{}, nil
+}
+
+// plugin looks up plugin of client by name
+func (h *Hub) plugin(ctx context.Context, name string) (IPlugin, error) {
+	client, err := h.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	plugin, ok := h.manager.Get(client, name)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "plugin %s is not loaded by %s", name, client)
+	}
+	return plugin, nil
+}
+
+// hubError converts manager and call limit errors to gRPC status
+func hubError(err error) error {
+	var quotaErr *QuotaError
+	var code codes.Code
+	switch {
+	case errors.As(err, &quotaErr), errors.Is(err, ErrManagerFull),
+		errors.Is(err, ErrQueueFull), errors.Is(err, ErrQueueTimeout):
+		code = codes.ResourceExhausted
+	case errors.Is(err, ErrPluginExists):
+		code = codes.AlreadyExists
+	case errors.Is(err, ErrPluginNotFound):
+		code = codes.NotFound
+	case errors.Is(err, ErrManagerClosed):
+		code = codes.Unavailable
+	default:
+		return err
+	}
+	return status.Error(code, err.Error())
+}
+
+// hubCaller serves DebugTalk calls of hub clients, routing them to plugins by name
+// in gRPC metadata
+type hubCaller struct {
+	hub *Hub
+}
+
+// detailedContextCaller is implemented by plugins initialized by Init, see callDetailedContext
+type detailedContextCaller interface {
+	callDetailedContext(ctx context.Context, md map[string]string, funcName string, args ...interface{}) (*fungo.CallResult, error)
+}
+
+func (c *hubCaller) GetNames() ([]string, error) {
+	return nil, status.Error(codes.Unimplemented, "list functions with PluginHub.GetNames")
+}
+
+func (c *hubCaller) Call(funcName string, args ...interface{}) (interface{}, error) {
+	return c.CallContext(context.Background(), funcName, args...)
+}
+
+// CallContext calls function of plugin, forwarding call metadata, cancellation of ctx,
+// warnings and logs
+func (c *hubCaller) CallContext(ctx context.Context, funcName string, args ...interface{}) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	names := md.Get(hubPluginKey)
+	if len(names) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "plugin name missing in call metadata")
+	}
+	plugin, err := c.hub.plugin(ctx, names[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var result *fungo.CallResult
+	if caller, ok := plugin.(detailedContextCaller); ok {
+		result, err = caller.callDetailedContext(ctx, fungo.Metadata(ctx), funcName, args...)
+	} else {
+		result, err = plugin.CallDetailed(funcName, args...)
+	}
+	if err != nil {
+		return nil, hubError(err)
+	}
+	for _, warning := range result.Warnings {
+		fungo.AddWarning(ctx, "%s", warning)
+	}
+	for _, entry := range result.Logs {
+		fungo.AddLog(ctx, entry.Level, "%s", entry.Message)
+	}
+	return result.Value, nil
+}
+
+// hubCredentials sends client token and plugin name with each call to hub
+type hubCredentials struct {
+	token string
+	name  string
+}
+
+func (c hubCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	md := map[string]string{hubPluginKey: c.name}
+	if c.token != "" {
+		md["authorization"] = "Bearer " + c.token
+	}
+	return md, nil
+}
+
+func (c hubCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// hubPlugin is plugin loaded on Hub by ConnectHub
+type hubPlugin struct {
+	conn            *grpc.ClientConn
+	hub             protoGen.PluginHubClient
+	funcCaller      fungo.IFuncCaller
+	cachedFunctions sync.Map // cache loaded functions to improve performance, key is function name, value is bool
+	addr            string
+	name            string // plugin name on hub
+	path            string // plugin path on hub machine
+}
+
+// ConnectHub loads plugin of path on hub machine by Hub served on addr, authenticated by
+// token set with WithHubClient of the hub. The plugin is unloaded when it quits, plugins
+// of hosts exiting without quitting are left to WithIdleTTL of hub Manager.
+func ConnectHub(addr, token, path string, options ...Option) (IPlugin, error) {
+	option, err := hubOption(options)
+	if err != nil {
+		return nil, err
+	}
+	p, err := dialHub(addr, token, path, option.jsonNumber)
+	if err != nil {
+		option.releaseLogger()
+		return nil, err
+	}
+	return wrapPlugin(p, option), nil
+}
+
+// hubOption applies options of plugins connected to hubs and initializes logger
+func hubOption(options []Option) (*pluginOption, error) {
+	option := &pluginOption{}
+	for _, o := range options {
+		o(option)
+	}
+	if err := option.validateConnect(); err != nil {
+		return nil, err
+	}
+	option.initLogger()
+	return option, nil
+}
+
+// dialHub loads plugin of path on hub served on addr,
+// JSON numbers in results are decoded in numberMode
+func dialHub(addr, token, path string, numberMode fungo.NumberMode) (*hubPlugin, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("generate plugin name failed: %w", err)
+	}
+	hostname, _ := os.Hostname()
+	name := fmt.Sprintf("%s-%s", hostname, hex.EncodeToString(id))
+
+	logger.Info("connect plugin hub", "addr", addr, "path", path, "name", name)
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithPerRPCCredentials(hubCredentials{token: token, name: name}))
+	if err != nil {
+		return nil, fmt.Errorf("dial plugin hub failed: %w", err)
+	}
+	hub := protoGen.NewPluginHubClient(conn)
+	if _, err := hub.Init(context.Background(), &protoGen.InitRequest{Name: name, Path: path}); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("init plugin on hub failed: %w", err)
+	}
+	raw, _ := (&fungo.GRPCPlugin{NumberMode: numberMode}).GRPCClient(context.Background(), nil, conn)
+	logger.Info("init plugin on hub success", "addr", addr, "name", name)
+	return &hubPlugin{
+		conn:       conn,
+		hub:        hub,
+		funcCaller: raw.(fungo.IFuncCaller),
+		addr:       addr,
+		name:       name,
+		path:       path,
+	}, nil
+}
+
+func (p *hubPlugin) Type() string {
+	return "hub-grpc"
+}
+
+func (p *hubPlugin) Path() string {
+	return p.path
+}
+
+func (p *hubPlugin) Has(funcName string) bool {
+	logger.Debug("check if plugin has function", "funcName", funcName)
+	flag, ok := p.cachedFunctions.Load(funcName)
+	if ok {
+		return flag.(bool)
+	}
+
+	funcNames, err := p.GetNames()
+	if err != nil {
+		return false
+	}
+
+	for _, name := range funcNames {
+		if name == funcName {
+			p.cachedFunctions.Store(funcName, true) // cache as exists
+			return true
+		}
+	}
+
+	p.cachedFunctions.Store(funcName, false) // cache as not exists
+	return false
+}
+
+func (p *hubPlugin) GetNames() ([]string, error) {
+	resp, err := p.hub.GetNames(context.Background(), &protoGen.PluginRequest{Name: p.name})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Names, nil
+}
+
+func (p *hubPlugin) Call(funcName string, args ...interface{}) (interface{}, error) {
+	return p.funcCaller.Call(funcName, args...)
+}
+
+func (p *hubPlugin) CallWithMetadata(md map[string]string, funcName string, args ...interface{}) (interface{}, error) {
+	return p.funcCaller.(fungo.IMetadataCaller).CallWithMetadata(md, funcName, args...)
+}
+
+func (p *hubPlugin) CallContext(ctx context.Context, funcName string, args ...interface{}) (interface{}, error) {
+	return p.funcCaller.(fungo.IContextCaller).CallContext(ctx, funcName, args...)
+}
+
+func (p *hubPlugin) CallDetailed(funcName string, args ...interface{}) (*fungo.CallResult, error) {
+	return p.funcCaller.(fungo.IDetailedCaller).CallDetailed(funcName, args...)
+}
+
+func (p *hubPlugin) CallDetailedContext(ctx context.Context, md map[string]string, funcName string, args ...interface{}) (*fungo.CallResult, error) {
+	return p.funcCaller.(fungo.IDetailedContextCaller).CallDetailedContext(ctx, md, funcName, args...)
+}
+
+func (p *hubPlugin) Quit() error {
+	return p.unload()
+}
+
+// unload unloads plugin on hub and closes connection
+func (p *hubPlugin) unload() error {
+	logger.Info("unload plugin on hub", "addr", p.addr, "name", p.name)
+	_, err := p.hub.Quit(context.Background(), &protoGen.PluginRequest{Name: p.name})
+	p.conn.Close()
+	if err != nil {
+		return fmt.Errorf("unload plugin on hub failed: %w", err)
+	}
+	return nil
+}
+
+func (p *hubPlugin) StartHeartbeat() {
+	// plugin process is managed by hub
+}
@@ -0,0 +1,355 @@
+package funplugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+// policies of BalanceConfig
+const (
+	BalanceRoundRobin  = "round-robin"  // replicas take calls in turn
+	BalanceLeastLoaded = "least-loaded" // replica running the fewest calls takes the call
+)
+
+// ErrNoHealthyReplicas is returned by calls when all hub replicas are ejected
+var ErrNoHealthyReplicas = errors.New("all plugin hub replicas are ejected")
+
+// BalanceConfig configures balancing of calls across hub replicas by ConnectHubReplicas
+type BalanceConfig struct {
+	Policy      string        // BalanceRoundRobin or BalanceLeastLoaded, default round-robin
+	MaxFailures int           // consecutive unavailable errors ejecting a replica, default 3
+	EjectFor    time.Duration // time before ejected replica takes calls again, default 30s
+	// call metadata key of session id, e.g. "session-id", calls of a session are routed to
+	// the same replica by consistent hashing regardless of Policy
+	AffinityKey string
+}
+
+// ringPoints is the number of points of each replica on hash ring of session affinity
+const ringPoints = 100
+
+// hubReplica is a hub replica of balancedPlugin, plugin is nil until loaded on it
+type hubReplica struct {
+	addr     string
+	mu       sync.Mutex // guards loading plugin
+	plugin   *hubPlugin
+	inflight int
+	failures int       // consecutive failures
+	ejected  time.Time // until when replica takes no calls
+}
+
+// balancedPlugin balances calls of plugin loaded on every hub replica
+type balancedPlugin struct {
+	config     BalanceConfig
+	token      string
+	path       string
+	numberMode fungo.NumberMode
+
+	mu       sync.Mutex // guards replica states
+	replicas []*hubReplica
+	next     int        // start of next replica selection
+	ring     []ringNode // points of replicas sorted by hash
+}
+
+// ringNode is a point of replica on hash ring
+type ringNode struct {
+	hash    uint32
+	replica *hubReplica
+}
+
+// ConnectHubReplicas loads plugin of path on hub replicas served on addrs, like ConnectHub,
+// and balances calls across them with policy of config, thus large test farms can scale
+// plugin execution horizontally. Replicas failing with gRPC code Unavailable for MaxFailures
+// consecutive times are ejected for EjectFor, calls failing on a replica being unavailable
+// are retried on other replicas, and plugins lost by restarted replicas are loaded again.
+func ConnectHubReplicas(addrs []string, token, path string, config BalanceConfig, options ...Option) (IPlugin, error) {
+	switch config.Policy {
+	case "":
+		config.Policy = BalanceRoundRobin
+	case BalanceRoundRobin, BalanceLeastLoaded:
+	default:
+		return nil, fmt.Errorf("unknown balance policy %s", config.Policy)
+	}
+	if config.MaxFailures <= 0 {
+		config.MaxFailures = 3
+	}
+	if config.EjectFor <= 0 {
+		config.EjectFor = 30 * time.Second
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("plugin hub addresses missing")
+	}
+	if config.AffinityKey != "" {
+		if err := fungo.ValidateMetadataKey(config.AffinityKey); err != nil {
+			return nil, err
+		}
+	}
+	option, err := hubOption(options)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &balancedPlugin{config: config, token: token, path: path, numberMode: option.jsonNumber}
+	var errs []string
+	for _, addr := range addrs {
+		replica := &hubReplica{addr: addr}
+		if _, err := p.load(replica); err != nil {
+			// replica is tried again after ejection
+			errs = append(errs, err.Error())
+			replica.failures = config.MaxFailures
+			replica.ejected = time.Now().Add(config.EjectFor)
+		}
+		p.replicas = append(p.replicas, replica)
+	}
+	if len(errs) == len(addrs) {
+		option.releaseLogger()
+		return nil, fmt.Errorf("init plugin on all hub replicas failed: %s", strings.Join(errs, "; "))
+	}
+	p.buildRing()
+	return wrapPlugin(p, option), nil
+}
+
+// load returns plugin on replica, loading it if it is not loaded
+func (p *balancedPlugin) load(replica *hubReplica) (*hubPlugin, error) {
+	replica.mu.Lock()
+	defer replica.mu.Unlock()
+	if replica.plugin != nil {
+		return replica.plugin, nil
+	}
+	plugin, err := dialHub(replica.addr, p.token, p.path, p.numberMode)
+	if err != nil {
+		return nil, err
+	}
+	replica.plugin = plugin
+	return plugin, nil
+}
+
+// drop closes plugin lost by replica, which is loaded again by later calls
+func (p *balancedPlugin) drop(replica *hubReplica, plugin *hubPlugin) {
+	replica.mu.Lock()
+	defer replica.mu.Unlock()
+	if replica.plugin == plugin {
+		plugin.conn.Close()
+		replica.plugin = nil
+	}
+}
+
+// buildRing places points of replicas on hash ring, so that sessions of a replica are
+// spread over other replicas when it is ejected, and return when it takes calls again
+func (p *balancedPlugin) buildRing() {
+	p.ring = nil
+	for _, replica := range p.replicas {
+		for i := 0; i < ringPoints; i++ {
+			p.ring = append(p.ring, ringNode{hash: hashKey(replica.addr + "#" + strconv.Itoa(i)), replica: replica})
+		}
+	}
+	sort.Slice(p.ring, func(i, j int) bool {
+		return p.ring[i].hash < p.ring[j].hash
+	})
+}
+
+func hashKey(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32()
+}
+
+// pick selects a healthy replica not tried yet, the replica of session on hash ring
+// or by policy without session, and counts the call on it
+func (p *balancedPlugin) pick(tried map[*hubReplica]bool, session string) (*hubReplica, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	if session != "" {
+		hash := hashKey(session)
+		start := sort.Search(len(p.ring), func(i int) bool { return p.ring[i].hash >= hash })
+		for i := range p.ring {
+			replica := p.ring[(start+i)%len(p.ring)].replica
+			if !tried[replica] && !now.Before(replica.ejected) {
+				replica.inflight++
+				return replica, nil
+			}
+		}
+		return nil, ErrNoHealthyReplicas
+	}
+
+	var picked *hubReplica
+	for i := range p.replicas {
+		replica := p.replicas[(p.next+i)%len(p.replicas)]
+		if tried[replica] || now.Before(replica.ejected) {
+			continue
+		}
+		if picked == nil || (p.config.Policy == BalanceLeastLoaded && replica.inflight < picked.inflight) {
+			picked = replica
+		}
+		if p.config.Policy == BalanceRoundRobin {
+			break
+		}
+	}
+	p.next = (p.next + 1) % len(p.replicas)
+	if picked == nil {
+		return nil, ErrNoHealthyReplicas
+	}
+	picked.inflight++
+	return picked, nil
+}
+
+// done records result of call on replica, ejecting replica failing consecutively
+func (p *balancedPlugin) done(replica *hubReplica, unavailable bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	replica.inflight--
+	if !unavailable {
+		replica.failures = 0
+		return
+	}
+	replica.failures++
+	if replica.failures >= p.config.MaxFailures {
+		// a single failure ejects the replica again after it takes calls
+		replica.failures = p.config.MaxFailures - 1
+		replica.ejected = time.Now().Add(p.config.EjectFor)
+		logger.Warn("plugin hub replica ejected", "addr", replica.addr, "ejectFor", p.config.EjectFor)
+	}
+}
+
+// do runs fn with plugin on replicas selected for session, retrying on other replicas
+// while replicas are unavailable
+func (p *balancedPlugin) do(session string, fn func(plugin *hubPlugin) error) error {
+	tried := make(map[*hubReplica]bool)
+	var err error
+	for len(tried) < len(p.replicas) {
+		replica, pickErr := p.pick(tried, session)
+		if pickErr != nil {
+			if err == nil {
+				err = pickErr
+			}
+			return err
+		}
+		tried[replica] = true
+
+		var plugin *hubPlugin
+		plugin, err = p.load(replica)
+		if err == nil {
+			err = fn(plugin)
+		}
+		code := status.Code(err)
+		if code == codes.NotFound && plugin != nil {
+			// plugin lost by restarted replica or evicted by idle TTL of hub
+			p.drop(replica, plugin)
+		}
+		unavailable := code == codes.Unavailable
+		p.done(replica, unavailable)
+		if !unavailable && code != codes.NotFound {
+			return err
+		}
+		logger.Warn("plugin hub replica failed, retry on another one", "addr", replica.addr, "error", err)
+	}
+	return err
+}
+
+func (p *balancedPlugin) Type() string {
+	return "hub-grpc"
+}
+
+func (p *balancedPlugin) Path() string {
+	return p.path
+}
+
+func (p *balancedPlugin) Has(funcName string) bool {
+	var has bool
+	p.do("", func(plugin *hubPlugin) error {
+		has = plugin.Has(funcName)
+		return nil
+	})
+	return has
+}
+
+func (p *balancedPlugin) GetNames() (names []string, err error) {
+	err = p.do("", func(plugin *hubPlugin) error {
+		names, err = plugin.GetNames()
+		return err
+	})
+	return
+}
+
+func (p *balancedPlugin) Call(funcName string, args ...interface{}) (result interface{}, err error) {
+	err = p.do("", func(plugin *hubPlugin) error {
+		result, err = plugin.Call(funcName, args...)
+		return err
+	})
+	return
+}
+
+func (p *balancedPlugin) CallWithMetadata(md map[string]string, funcName string, args ...interface{}) (result interface{}, err error) {
+	var session string
+	if p.config.AffinityKey != "" {
+		session = md[p.config.AffinityKey]
+	}
+	err = p.do(session, func(plugin *hubPlugin) error {
+		result, err = plugin.CallWithMetadata(md, funcName, args...)
+		return err
+	})
+	return
+}
+
+func (p *balancedPlugin) CallContext(ctx context.Context, funcName string, args ...interface{}) (result interface{}, err error) {
+	err = p.do("", func(plugin *hubPlugin) error {
+		result, err = plugin.CallContext(ctx, funcName, args...)
+		return err
+	})
+	return
+}
+
+func (p *balancedPlugin) CallDetailed(funcName string, args ...interface{}) (result *fungo.CallResult, err error) {
+	err = p.do("", func(plugin *hubPlugin) error {
+		result, err = plugin.CallDetailed(funcName, args...)
+		return err
+	})
+	return
+}
+
+func (p *balancedPlugin) CallDetailedContext(ctx context.Context, md map[string]string, funcName string, args ...interface{}) (result *fungo.CallResult, err error) {
+	var session string
+	if p.config.AffinityKey != "" {
+		session = md[p.config.AffinityKey]
+	}
+	err = p.do(session, func(plugin *hubPlugin) error {
+		result, err = plugin.CallDetailedContext(ctx, md, funcName, args...)
+		return err
+	})
+	return
+}
+
+// Quit unloads plugin on all replicas
+func (p *balancedPlugin) Quit() error {
+	var errs []string
+	for _, replica := range p.replicas {
+		replica.mu.Lock()
+		if replica.plugin != nil {
+			if err := replica.plugin.unload(); err != nil {
+				errs = append(errs, err.Error())
+			}
+			replica.plugin = nil
+		}
+		replica.mu.Unlock()
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("quit plugin on %d hub replicas failed: %s", len(errs), strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (p *balancedPlugin) StartHeartbeat() {
+	// plugin processes are managed by hubs
+}
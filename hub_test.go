@@ -0,0 +1,249 @@
+package funplugin
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/lingcetech/funplugin/fungo/protoGen"
+)
+
+func TestHub(t *testing.T) {
+	m := NewManager(WithTenantQuota("project-a", Quota{MaxPlugins: 1}))
+	defer m.Shutdown()
+	hub := NewHub(m, WithHubClient("project-a", "token-a"), WithHubRoot("lua/examples"))
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go hub.Serve(listener)
+	addr := listener.Addr().String()
+
+	plugin, err := ConnectHub(addr, "token-a", "debugtalk.lua")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "hub-grpc", plugin.Type())
+	assertPlugin(t, plugin)
+
+	loaded := m.List("project-a")
+	if assert.Len(t, loaded, 1) {
+		assert.Equal(t, filepath.Join("lua/examples", "debugtalk.lua"), loaded[0].Path)
+		assert.Equal(t, "lua-plugin", loaded[0].Type)
+	}
+
+	// quota of client is the quota of its tenant
+	_, err = ConnectHub(addr, "token-a", "debugtalk.lua")
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "ResourceExhausted")
+	}
+	_, err = ConnectHub(addr, "token-b", "debugtalk.lua")
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "invalid hub token")
+	}
+	// clients without tokens are denied by default
+	_, err = ConnectHub(addr, "", "debugtalk.lua")
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "invalid hub token")
+	}
+	// paths of clients do not escape root
+	assert.Equal(t, filepath.Join("lua/examples", "etc/passwd"), hub.pluginPath("../../etc/passwd"))
+
+	assert.Nil(t, plugin.Quit())
+	assert.Empty(t, m.List("project-a"))
+
+	// hub without root refuses to load plugins
+	noRoot := NewHub(m, WithHubAnonymous())
+	_, err = noRoot.Init(context.Background(), &protoGen.InitRequest{Name: "lua", Path: "lua/examples/debugtalk.lua"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func TestHubCallContext(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	m := NewManager()
+	defer m.Shutdown()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go NewHub(m, WithHubAnonymous(), WithHubRoot(filepath.Dir(pluginBinPath))).Serve(listener)
+
+	plugin, err := ConnectHub(listener.Addr().String(), "", filepath.Base(pluginBinPath))
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	defer plugin.Quit()
+
+	// call metadata and warnings are forwarded
+	result, err := plugin.CallWithMetadata(map[string]string{"case-id": "TC-1"}, "call_metadata", "case-id")
+	assert.Nil(t, err)
+	assert.Equal(t, "TC-1", result)
+	detailed, err := plugin.CallDetailed("parse_score", "")
+	if assert.Nil(t, err) {
+		assert.Equal(t, []string{"empty score, use 0"}, detailed.Warnings)
+	}
+
+	// canceling call of client cancels call of plugin on hub
+	future, err := plugin.CallAsync("wait_seconds", 60)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	time.Sleep(200 * time.Millisecond)
+	future.Cancel()
+	assert.Eventually(t, func() bool {
+		loaded := m.List(HubAnonymousClient)
+		if len(loaded) != 1 {
+			return false
+		}
+		hubPlugin, _ := m.Get(HubAnonymousClient, loaded[0].Name)
+		for _, f := range hubPlugin.Stats().Funcs {
+			if f.Name == "wait_seconds" {
+				return f.Errors == 1 && f.Total < 10*time.Second
+			}
+		}
+		return false
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestConnectHubReplicas(t *testing.T) {
+	var managers []*Manager
+	var servers []*grpc.Server
+	var addrs []string
+	for i := 0; i < 2; i++ {
+		m := NewManager()
+		defer m.Shutdown()
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		server := grpc.NewServer()
+		NewHub(m, WithHubAnonymous(), WithHubRoot("lua/examples")).Register(server)
+		go server.Serve(listener)
+		defer server.Stop()
+		managers = append(managers, m)
+		servers = append(servers, server)
+		addrs = append(addrs, listener.Addr().String())
+	}
+
+	plugin, err := ConnectHubReplicas(addrs, "", "debugtalk.lua", BalanceConfig{MaxFailures: 1, EjectFor: time.Minute})
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	defer plugin.Quit()
+
+	// replicas take calls in turn
+	for i := 0; i < 4; i++ {
+		result, err := plugin.Call("sum_ints", 1, 2)
+		assert.Nil(t, err)
+		assert.EqualValues(t, 3, result)
+	}
+	calls := func(m *Manager) int64 {
+		loaded := m.List(HubAnonymousClient)
+		if !assert.Len(t, loaded, 1) {
+			return 0
+		}
+		hubPlugin, _ := m.Get(HubAnonymousClient, loaded[0].Name)
+		var calls int64
+		for _, f := range hubPlugin.Stats().Funcs {
+			calls += f.Calls
+		}
+		return calls
+	}
+	assert.EqualValues(t, 2, calls(managers[0]))
+	assert.EqualValues(t, 2, calls(managers[1]))
+
+	// calls fail over to healthy replica, and unavailable replica is ejected
+	servers[1].Stop()
+	for i := 0; i < 4; i++ {
+		result, err := plugin.Call("sum_ints", 1, 2)
+		assert.Nil(t, err)
+		assert.EqualValues(t, 3, result)
+	}
+	assert.EqualValues(t, 6, calls(managers[0]))
+	balanced := backendOf(plugin).(*balancedPlugin)
+	assert.True(t, time.Now().Before(balanced.replicas[1].ejected))
+
+	servers[0].Stop()
+	_, err = plugin.Call("sum_ints", 1, 2)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	_, err = plugin.Call("sum_ints", 1, 2)
+	assert.ErrorIs(t, err, ErrNoHealthyReplicas)
+}
+
+func TestBalanceLeastLoaded(t *testing.T) {
+	p := &balancedPlugin{
+		config:   BalanceConfig{Policy: BalanceLeastLoaded, MaxFailures: 1, EjectFor: time.Minute},
+		replicas: []*hubReplica{{addr: "a", inflight: 2}, {addr: "b", inflight: 1}, {addr: "c", inflight: 1}},
+	}
+	replica, err := p.pick(map[*hubReplica]bool{}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "b", replica.addr)
+	// ties are broken in turn
+	replica, err = p.pick(map[*hubReplica]bool{}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "c", replica.addr)
+
+	// unavailable replica is ejected
+	p.done(replica, true)
+	p.done(p.replicas[1], false)
+	replica, err = p.pick(map[*hubReplica]bool{}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "b", replica.addr)
+}
+
+func TestBalanceAffinity(t *testing.T) {
+	p := &balancedPlugin{config: BalanceConfig{MaxFailures: 1, EjectFor: time.Minute}}
+	for _, addr := range []string{"10.0.0.1:50051", "10.0.0.2:50051", "10.0.0.3:50051"} {
+		p.replicas = append(p.replicas, &hubReplica{addr: addr})
+	}
+	p.buildRing()
+
+	// calls of a session are routed to the same replica
+	sessions := make(map[string]*hubReplica)
+	for i := 0; i < 30; i++ {
+		session := fmt.Sprintf("session-%d", i)
+		replica, err := p.pick(map[*hubReplica]bool{}, session)
+		assert.Nil(t, err)
+		p.done(replica, false)
+		sessions[session] = replica
+		for j := 0; j < 3; j++ {
+			again, _ := p.pick(map[*hubReplica]bool{}, session)
+			p.done(again, false)
+			assert.Same(t, replica, again)
+		}
+	}
+	used := make(map[*hubReplica]bool)
+	for _, replica := range sessions {
+		used[replica] = true
+	}
+	assert.Len(t, used, 3)
+
+	// only sessions of ejected replica move, and they return after ejection
+	ejected := sessions["session-0"]
+	ejected.ejected = time.Now().Add(time.Minute)
+	for session, replica := range sessions {
+		picked, err := p.pick(map[*hubReplica]bool{}, session)
+		assert.Nil(t, err)
+		p.done(picked, false)
+		if replica == ejected {
+			assert.NotSame(t, ejected, picked)
+		} else {
+			assert.Same(t, replica, picked)
+		}
+	}
+	ejected.ejected = time.Time{}
+	picked, _ := p.pick(map[*hubReplica]bool{}, "session-0")
+	assert.Same(t, ejected, picked)
+}
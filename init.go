@@ -2,7 +2,9 @@ package funplugin
 
 import (
 	"fmt"
-	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/pkg/errors"
@@ -16,6 +18,35 @@ var (
 )
 
 type IPlugin interface {
+	pluginBackend
+	Stats() PluginStats       // get per-function call statistics
+	Snapshot() PluginSnapshot // get host side state for bug reports
+	// check all required functions exist, reporting all missing ones
+	ValidateFunctions(funcNames []string) error
+	// copy files written to plugin artifact directory to host, see WithArtifacts
+	CollectArtifacts() ([]string, error)
+	// call function with per-call metadata exposed to plugin function
+	CallWithMetadata(md map[string]string, funcName string, args ...interface{}) (interface{}, error)
+	// call function returning its result with warnings and log entries it reported
+	CallDetailed(funcName string, args ...interface{}) (*fungo.CallResult, error)
+	// start function call in background, returning future to wait for or cancel it
+	CallAsync(funcName string, args ...interface{}) (*Future, error)
+	// start function call in background, returning call id for Wait and Cancel
+	Submit(funcName string, args ...interface{}) string
+	Wait(callID string) (interface{}, error) // wait for result of submitted call
+	Cancel(callID string) error              // abort submitted call without quitting plugin
+	SubmittedCalls() []string                // ids of submitted calls not waited for yet
+	// call function periodically by cron spec until stopped or plugin quits
+	Schedule(spec, funcName string, args ...interface{}) (*ScheduledCall, error)
+	// get build manifest of plugin, e.g. version and commit of plugin build handling calls
+	Info() fungo.PluginInfo
+	// get signatures of plugin functions keyed by function name
+	Describe() (map[string]fungo.FuncSpec, error)
+}
+
+// pluginBackend is implemented by each plugin type, host side features
+// such as statistics are added by wrapping it with interceptedPlugin
+type pluginBackend interface {
 	Type() string                                                   // get plugin type
 	Path() string                                                   // get plugin file path
 	Has(funcName string) bool                                       // check if plugin has function
@@ -24,6 +55,12 @@ type IPlugin interface {
 	StartHeartbeat()                                                // heartbeat to keep the plugin alive
 }
 
+// IFuncLister is implemented by plugins which can list their function names,
+// go plugin does not support listing exported functions
+type IFuncLister interface {
+	GetNames() ([]string, error) // get all plugin function names list
+}
+
 type langType string
 
 const (
@@ -33,11 +70,58 @@ const (
 )
 
 type pluginOption struct {
-	debugLogger    bool     // whether set log level to DEBUG
-	logFile        string   // specify log file path
-	disableLogTime bool     // whether disable log time
-	langType       langType // go or py
-	python3        string   // python3 path with funppy dependency
+	configFile     string                   // yaml config file overriding options
+	debugLogger    bool                     // whether set log level to DEBUG
+	logLevel       hclog.Level              // log level set by config, overrides debugLogger
+	logFile        string                   // specify log file path
+	disableLogTime bool                     // whether disable log time
+	langType       langType                 // go or py
+	python3        string                   // python3 path with funppy dependency
+	pythonPath     *PythonPath              // sys.path entries of python plugin process
+	grpcReflection bool                     // whether expose gRPC reflection service on plugin server
+	transport      string                   // plugin transport, default to hashicorp plugin, or stdio/npipe
+	jsonNumber     fungo.NumberMode         // decoding of JSON numbers in arguments and results
+	auditSink      AuditSink                // audit sink recording every plugin call
+	auditKey       []byte                   // HMAC key to sign audit records
+	tracer         *ChromeTracer            // tracer writing Chrome trace events of plugin calls
+	rateLimit      *rateLimit               // rate limit for all plugin calls
+	funcRateLimits map[string]rateLimit     // rate limit for specified functions
+	maxConcurrency int                      // max concurrent plugin calls, 0 means unlimited
+	queueSize      int                      // max calls waiting for concurrency slots
+	queueTimeout   time.Duration            // max time a call waits in queue
+	funcPriorities map[string]Priority      // queue priority of specified functions
+	scheduleJitter time.Duration            // max random delay of scheduled calls
+	scheduleErrFn  func(string, error)      // handler of scheduled call errors
+	chaos          *ChaosConfig             // faults injected to plugin calls
+	seed           *int64                   // random seed passed to plugin in deterministic mode
+	resultSchemas  map[string]*ResultSchema // expected result schema of functions
+	detachedState  string                   // state file of detached plugin server
+	daemonNetwork  string                   // network of plugin daemon, unix or tcp
+	daemonAddr     string                   // address of plugin daemon
+	dockerImage    string                   // run plugin inside container of the image
+	dockerArgs     []string                 // extra docker run arguments
+	isolation      string                   // isolation backend name launching plugin process
+	sshHost        string                   // run plugin on remote machine over SSH
+	sshKeyPath     string                   // SSH private key path
+	adbEnabled     bool                     // run plugin on android device over adb
+	adbSerial      string                   // android device serial
+	startTimeout   time.Duration            // timeout waiting for plugin handshake
+	licensePolicy  *LicensePolicy           // license policy of plugin dependencies
+	signature      *SignaturePolicy         // sigstore signature policy of plugin artifact
+	autoBuildDir   string                   // go source dir rebuilding stale .bin/.so plugin
+	secrets        map[string]string        // secrets passed to plugin process through pipe
+	pluginConfig   map[string]interface{}   // plugin scoped config passed to plugin process through pipe
+	env            map[string]string        // extra env of plugin process
+	dataFiles      map[string]string        // data files transferred to plugin, keyed by name in data directory
+	dataDir        string                   // data directory seen by plugin process
+	localDataDir   string                   // local data directory removed when plugin quits
+	artifactsDir   string                   // host directory plugin artifacts are collected to
+	artifactDir    string                   // artifact directory seen by plugin process
+	localArtifacts string                   // local artifact directory removed when plugin quits
+	activity       *pluginActivity          // call activity tracked by Manager for eviction
+	restartHook    func()                   // called after plugin process restarted, e.g. by Manager for WithCgroup
+	durableDir     string                   // directory persisting submitted calls
+	loggerInit     int32                    // 1 if Logger is initialized for plugin, accessed atomically
 }
 
 type Option func(*pluginOption)
@@ -66,32 +150,450 @@ func WithPython3(python3 string) Option {
 	}
 }
 
+// WithPythonPath controls sys.path entries local .py plugin process starts with, e.g. adding
+// project root for imports of sibling modules, or excluding current working directory
+func WithPythonPath(pythonPath PythonPath) Option {
+	return func(o *pluginOption) {
+		o.pythonPath = &pythonPath
+	}
+}
+
+// WithGRPCReflection exposes gRPC reflection service on hashicorp gRPC plugin server
+// and logs its address, which is useful to debug plugin functions with tools like grpcurl
+func WithGRPCReflection(enable bool) Option {
+	return func(o *pluginOption) {
+		o.grpcReflection = enable
+	}
+}
+
+// WithTransport specifies plugin transport for .bin and .py plugins,
+// "stdio" speaks JSON-RPC over plugin process stdin/stdout without listening sockets,
+// "npipe" speaks JSON-RPC over windows named pipe without localhost TCP listeners
+func WithTransport(transport string) Option {
+	return func(o *pluginOption) {
+		o.transport = transport
+	}
+}
+
+// WithJSONNumber specifies how JSON numbers in function arguments and results are
+// decoded by gRPC and JSON-RPC transports, fungo.NumberJSON keeps them as json.Number
+// and fungo.NumberInt64 decodes integers as int64, thus large IDs are not mangled by float64
+func WithJSONNumber(mode fungo.NumberMode) Option {
+	return func(o *pluginOption) {
+		o.jsonNumber = mode
+	}
+}
+
+// WithAuditLog writes an audit record of every plugin call to sink,
+// records are signed with HMAC-SHA256 if signingKey is not empty
+func WithAuditLog(sink AuditSink, signingKey []byte) Option {
+	return func(o *pluginOption) {
+		o.auditSink = sink
+		o.auditKey = signingKey
+	}
+}
+
+// WithTrace writes begin/end events of every plugin call to tracer in Chrome trace format,
+// which visualizes call timelines in chrome://tracing or Perfetto UI
+func WithTrace(tracer *ChromeTracer) Option {
+	return func(o *pluginOption) {
+		o.tracer = tracer
+	}
+}
+
+// WithRateLimit limits plugin calls to rps calls per second with burst size, calls
+// exceeding the limit are blocked until allowed, or fail with ErrQueueTimeout if not
+// allowed within queueTimeout of WithConcurrencyLimit
+func WithRateLimit(rps float64, burst int) Option {
+	return func(o *pluginOption) {
+		o.rateLimit = &rateLimit{rps: rps, burst: burst}
+	}
+}
+
+// WithFuncRateLimit limits calls of the specified function, it is applied
+// besides the plugin rate limit set by WithRateLimit
+func WithFuncRateLimit(funcName string, rps float64, burst int) Option {
+	return func(o *pluginOption) {
+		if o.funcRateLimits == nil {
+			o.funcRateLimits = make(map[string]rateLimit)
+		}
+		o.funcRateLimits[funcName] = rateLimit{rps: rps, burst: burst}
+	}
+}
+
+// WithConcurrencyLimit limits concurrent plugin calls to maxConcurrency, excess calls
+// wait in a queue of queueSize for at most queueTimeout (0 means no timeout).
+// Calls fail with ErrQueueFull if queue is full, or ErrQueueTimeout if not started in time.
+func WithConcurrencyLimit(maxConcurrency, queueSize int, queueTimeout time.Duration) Option {
+	return func(o *pluginOption) {
+		o.maxConcurrency = maxConcurrency
+		o.queueSize = queueSize
+		o.queueTimeout = queueTimeout
+	}
+}
+
+// WithDurableQueue persists calls started by Submit in dir until they complete, calls
+// interrupted by host or plugin crashes are replayed by Init of the next run with the same
+// call ids, listed by SubmittedCalls. Calls are delivered at least once, thus plugin functions
+// should be idempotent. The directory must not be shared by hosts running at the same time.
+func WithDurableQueue(dir string) Option {
+	return func(o *pluginOption) {
+		o.durableDir = dir
+	}
+}
+
+// WithFuncPriority sets priority of the specified function in the queue of WithConcurrencyLimit,
+// e.g. health checks and teardown functions jump the queue ahead of bulk data generation calls.
+// When the queue is full, its calls bump the last queued call of lower priority, which fails
+// with ErrQueueFull.
+func WithFuncPriority(funcName string, priority Priority) Option {
+	return func(o *pluginOption) {
+		if o.funcPriorities == nil {
+			o.funcPriorities = make(map[string]Priority)
+		}
+		o.funcPriorities[funcName] = priority
+	}
+}
+
+// WithScheduleJitter delays each run of functions started by IPlugin.Schedule by
+// a random duration in [0, jitter), spreading load of many hosts on the same schedule.
+func WithScheduleJitter(jitter time.Duration) Option {
+	return func(o *pluginOption) {
+		o.scheduleJitter = jitter
+	}
+}
+
+// WithScheduleErrorHandler sets handler of errors returned by functions started by
+// IPlugin.Schedule, which are logged by default. Scheduling continues after errors.
+func WithScheduleErrorHandler(handler func(funcName string, err error)) Option {
+	return func(o *pluginOption) {
+		o.scheduleErrFn = handler
+	}
+}
+
+// WithChaos injects faults of config to plugin calls for testing resilience of hosts to plugin
+// failures: calls are delayed, failed with ErrChaosDropped, or failed with ErrChaosKilled after
+// hashicorp plugin process is killed and restarted. Faults are deterministic by config seed.
+func WithChaos(config ChaosConfig) Option {
+	return func(o *pluginOption) {
+		o.chaos = &config
+	}
+}
+
+// WithSeed runs plugin in deterministic mode, seed is passed to plugin process at startup
+// and plugin functions seed their random sources with fungo.Rand/fungo.NewRand in go or
+// funppy.get_random/funppy.new_random in python, thus generated data is reproducible across runs.
+func WithSeed(seed int64) Option {
+	return func(o *pluginOption) {
+		o.seed = &seed
+	}
+}
+
+// WithResultSchema registers expected result schema of function,
+// mismatched results are returned with *SchemaError
+func WithResultSchema(funcName string, schema *ResultSchema) Option {
+	return func(o *pluginOption) {
+		if o.resultSchemas == nil {
+			o.resultSchemas = make(map[string]*ResultSchema)
+		}
+		o.resultSchemas[funcName] = schema
+	}
+}
+
+// WithDetached keeps .bin/.py plugin server running after host exits, its address
+// is recorded in stateFile and reattached by next host process with the same stateFile.
+// Use StopDetached to stop the detached plugin server.
+func WithDetached(stateFile string) Option {
+	return func(o *pluginOption) {
+		o.detachedState = stateFile
+	}
+}
+
+// WithDaemon acquires .bin/.py plugin server from plugin daemon served by ServeDaemon,
+// thus host processes on the same machine share plugin servers
+func WithDaemon(network, addr string) Option {
+	return func(o *pluginOption) {
+		o.daemonNetwork = network
+		o.daemonAddr = addr
+	}
+}
+
+// WithDockerImage runs .bin/.py plugin inside a container of image, plugin path is
+// the path inside the image. Nothing is mounted by default, use WithDockerArgs to add
+// extra docker run arguments, e.g. "--volume", "/data:/data:ro".
+func WithDockerImage(image string) Option {
+	return func(o *pluginOption) {
+		o.dockerImage = image
+	}
+}
+
+// WithDockerArgs adds extra docker run arguments for WithDockerImage
+func WithDockerArgs(args ...string) Option {
+	return func(o *pluginOption) {
+		o.dockerArgs = append(o.dockerArgs, args...)
+	}
+}
+
+// WithIsolation launches .bin/.py plugin process with the registered isolation backend,
+// "gvisor" is built in, and more backends can be added with RegisterIsolation
+func WithIsolation(name string) Option {
+	return func(o *pluginOption) {
+		o.isolation = name
+	}
+}
+
+// WithRemoteSSH runs .bin/.py plugin on remote host over SSH and tunnels gRPC back,
+// host is the ssh destination, e.g. user@lab-machine, keyPath is optional.
+// Local plugin file is copied to remote machine, otherwise path is located on remote machine.
+func WithRemoteSSH(host, keyPath string) Option {
+	return func(o *pluginOption) {
+		o.sshHost = host
+		o.sshKeyPath = keyPath
+	}
+}
+
+// WithADB runs .bin plugin cross compiled for android on device or emulator over adb,
+// serial selects the device and can be empty if only one device is connected.
+// Local plugin file is pushed to /data/local/tmp, otherwise path is located on device.
+func WithADB(serial string) Option {
+	return func(o *pluginOption) {
+		o.adbEnabled = true
+		o.adbSerial = serial
+	}
+}
+
+// WithStartTimeout sets timeout waiting for plugin process handshake, default 1 minute,
+// ErrStartTimeout with captured plugin stderr is returned when it is exceeded
+func WithStartTimeout(timeout time.Duration) Option {
+	return func(o *pluginOption) {
+		o.startTimeout = timeout
+	}
+}
+
+// WithLicensePolicy fails Init with LicenseError before executing .bin/.so/.py plugin
+// when its go modules or venv python packages have forbidden licenses
+func WithLicensePolicy(policy LicensePolicy) Option {
+	return func(o *pluginOption) {
+		o.licensePolicy = &policy
+	}
+}
+
+// WithSignatureVerification verifies sigstore signature of local plugin file with cosign
+// before Init executes it, see SignaturePolicy for key-based and keyless verification
+func WithSignatureVerification(policy SignaturePolicy) Option {
+	return func(o *pluginOption) {
+		o.signature = &policy
+	}
+}
+
+// WithSecrets passes secrets to .bin/.py plugin process through an inherited pipe instead
+// of argv or env, plugin functions read them with fungo.Secret or funppy.secret.
+// Secret values are masked in all logs and plugin stderr captured by funplugin.
+func WithSecrets(secrets map[string]string) Option {
+	return func(o *pluginOption) {
+		o.secrets = secrets
+	}
+}
+
+// WithPluginConfig passes plugin scoped key/value config to .bin/.py plugin process when
+// it starts, plugin functions read it with fungo.Config or funppy.get_config.
+// Config must be JSON serializable.
+func WithPluginConfig(config map[string]interface{}) Option {
+	return func(o *pluginOption) {
+		o.pluginConfig = config
+	}
+}
+
+// WithDataFiles transfers host data files to data directory of .bin/.py plugin at Init, which
+// is needed when plugin is launched in container or on remote machine and can't see host
+// filesystem. Files are keyed by slash separated names in data directory, e.g.
+// {"fixtures/users.csv": "testdata/users.csv"}, plugin functions locate them with
+// fungo.DataFile(name) or funppy.data_file(name).
+func WithDataFiles(files map[string]string) Option {
+	return func(o *pluginOption) {
+		o.dataFiles = files
+	}
+}
+
+// WithArtifacts provides .bin/.py plugin process a managed artifact directory, plugin functions
+// write files such as screenshots and CSV reports there, located with fungo.ArtifactDir() or
+// funppy.artifact_dir(). Files are collected to hostDir with IPlugin.CollectArtifacts() after
+// calls complete, and finally on Quit, including from container or remote machine.
+func WithArtifacts(hostDir string) Option {
+	return func(o *pluginOption) {
+		o.artifactsDir = hostDir
+	}
+}
+
+// WithEnv adds env of .bin/.py plugin process launched locally or in container,
+// it overrides host env with the same name
+func WithEnv(env map[string]string) Option {
+	return func(o *pluginOption) {
+		if o.env == nil {
+			o.env = make(map[string]string)
+		}
+		for key, value := range env {
+			o.env[key] = value
+		}
+	}
+}
+
+// WithAutoBuild rebuilds local .bin/.so plugin from go package in srcDir with go build
+// when the binary is missing or its sources changed since it was built
+func WithAutoBuild(srcDir string) Option {
+	return func(o *pluginOption) {
+		o.autoBuildDir = srcDir
+	}
+}
+
+// WithConfigFile loads options from yaml config file, see Config for supported keys.
+// Config file is also specified by env FUNPLUGIN_CONFIG, and FUNPLUGIN_* env overrides it.
+func WithConfigFile(path string) Option {
+	return func(o *pluginOption) {
+		o.configFile = path
+	}
+}
+
 // Init initializes plugin with plugin path
 func Init(path string, options ...Option) (plugin IPlugin, err error) {
 	option := &pluginOption{}
 	for _, o := range options {
 		o(option)
 	}
+	// options of config file and env are validated with those in code
+	if err := option.loadConfig(); err != nil {
+		return nil, err
+	}
+	if err := option.validate(path); err != nil {
+		return nil, err
+	}
 
-	// init logger
+	option.initLogger()
+	defer func() {
+		if err != nil {
+			option.releaseLogger()
+		}
+	}()
+
+	logger.Info("init plugin", "path", path)
+
+	switch option.transport {
+	case "", fungo.TransportStdio:
+	case fungo.TransportNamedPipe:
+		if runtime.GOOS != "windows" {
+			return nil, fmt.Errorf("plugin transport %s is only supported on windows", option.transport)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported plugin transport: %s", option.transport)
+	}
+	if option.isolation != "" {
+		if _, ok := getIsolation(option.isolation); !ok {
+			return nil, fmt.Errorf("unsupported isolation backend: %s", option.isolation)
+		}
+	}
+	if (option.detachedState != "" || option.daemonAddr != "") && option.transport != "" {
+		return nil, fmt.Errorf("detached mode does not support transport %s", option.transport)
+	}
+
+	for _, value := range option.secrets {
+		fungo.MaskSecrets(value)
+	}
+	if option.autoBuildDir != "" {
+		if err := autoBuild(path, option.autoBuildDir); err != nil {
+			return nil, err
+		}
+	}
+	if option.signature != nil {
+		if err := VerifySignature(path, *option.signature); err != nil {
+			return nil, err
+		}
+	}
+
+	backend, err := newPlugin(path, option)
+	if err != nil {
+		option.removeLocalDataDir()
+		option.removeLocalArtifactDir()
+		return nil, err
+	}
+	plugin = wrapPlugin(backend, option)
+	if option.durableDir != "" {
+		if err := plugin.(*interceptedPlugin).replayDurable(option.durableDir); err != nil {
+			plugin.Quit()
+			return nil, err
+		}
+	}
+	return plugin, nil
+}
+
+// initLogger initializes Logger shared by plugins of host process for plugin,
+// it is released by releaseLogger when plugin quits
+func (o *pluginOption) initLogger() {
 	logLevel := hclog.Info
-	if option.debugLogger {
+	if o.debugLogger {
 		logLevel = hclog.Debug
 	}
-	logger = fungo.InitLogger(
-		logLevel, option.logFile, option.disableLogTime)
+	if o.logLevel != hclog.NoLevel {
+		logLevel = o.logLevel
+	}
+	fungo.InitLogger(logLevel, o.logFile, o.disableLogTime)
+	atomic.StoreInt32(&o.loggerInit, 1)
+}
 
-	logger.Info("init plugin", "path", path)
+// releaseLogger releases Logger initialized for plugin once, closing log files
+// if no other plugin is using it
+func (o *pluginOption) releaseLogger() error {
+	if !atomic.CompareAndSwapInt32(&o.loggerInit, 1, 0) {
+		return nil
+	}
+	return fungo.CloseLogFile()
+}
+
+// newPlugin creates plugin according to plugin file extension
+func newPlugin(path string, option *pluginOption) (plugin pluginBackend, err error) {
+	if option.adbEnabled {
+		// run plugin on android device
+		return newADBPlugin(path, option)
+	}
+	if option.sshHost != "" {
+		// run plugin on remote machine
+		return newSSHPlugin(path, option)
+	}
+	if option.dockerImage != "" {
+		// found plugin in container image
+		return newDockerPlugin(path, option)
+	}
 
 	// priority: hashicorp plugin > go plugin
-	ext := filepath.Ext(path)
+	ext, err := pluginExt(path)
+	if err != nil {
+		return nil, err
+	}
+	// data files of local plugin process are read from a local temp directory
+	if err := option.prepareLocalDataDir(); err != nil {
+		return nil, err
+	}
+	if err := option.prepareLocalArtifactDir(); err != nil {
+		return nil, err
+	}
 	switch ext {
 	case ".bin":
 		// found hashicorp go plugin file
 		option.langType = langTypeGo
+		if err := option.checkLicenses(path); err != nil {
+			return nil, err
+		}
+		if option.daemonAddr != "" {
+			return newDaemonPlugin(path, option)
+		}
+		if option.detachedState != "" {
+			return newDetachedPlugin(path, option)
+		}
+		if option.transport != "" {
+			return newStdioPlugin(path, option)
+		}
 		return newHashicorpPlugin(path, option)
 	case ".py":
-		// found hashicorp python plugin file
+		// found hashicorp python plugin file or package directory
 		if option.python3 == "" {
 			// create python3 venv with funppy if python3 not specified
 			option.python3, err = myexec.EnsurePython3Venv("", "funppy")
@@ -102,10 +604,50 @@ func Init(path string, options ...Option) (plugin IPlugin, err error) {
 			}
 		}
 		option.langType = langTypePython
+		if option.licensePolicy != nil {
+			venv, err := pythonVenv(option.python3)
+			if err != nil {
+				return nil, err
+			}
+			if err := option.checkLicenses(venv); err != nil {
+				return nil, err
+			}
+		}
+		if option.daemonAddr != "" {
+			return newDaemonPlugin(path, option)
+		}
+		if option.detachedState != "" {
+			return newDetachedPlugin(path, option)
+		}
+		if option.transport != "" {
+			return newStdioPlugin(path, option)
+		}
 		return newHashicorpPlugin(path, option)
+	case ".json":
+		// found jupyter kernel connection file written by funppy.serve_kernel()
+		return newKernelPlugin(path, option)
+	case ".lua":
+		// found lua plugin script, run in process
+		return newLuaPlugin(path)
+	case ".js":
+		// found javascript plugin script, run in process
+		return newJSPlugin(path)
+	case ".star":
+		// found starlark plugin script, run in process without filesystem/network access
+		return newStarlarkPlugin(path)
 	case ".so":
+		// found C shared library following funplugin symbol convention
+		if isCSharedPlugin(path) {
+			return newCSharedPlugin(path)
+		}
 		// found go plugin file
+		if err := option.checkLicenses(path); err != nil {
+			return nil, err
+		}
 		return newGoPlugin(path)
+	case ".dylib":
+		// found C shared library on macOS
+		return newCSharedPlugin(path)
 	default:
 		logger.Error("invalid plugin path", "path", path, "error", err)
 		return nil, fmt.Errorf("unsupported plugin type: %s", ext)
@@ -0,0 +1,141 @@
+package funplugin
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// callHandler calls plugin function
+type callHandler func(funcName string, args ...interface{}) (interface{}, error)
+
+// callInterceptor wraps plugin function calls on the host side, ctx is done when the call is
+// aborted, e.g. Future.Cancel, thus waits of the call end early
+type callInterceptor func(ctx context.Context, next callHandler) callHandler
+
+// interceptors returns host side interceptors configured by options,
+// the first one is the outermost
+func (o *pluginOption) interceptors(p pluginBackend, queue *callQueue, stats *callStats) []callInterceptor {
+	var interceptors []callInterceptor
+	if o.activity != nil {
+		// calls waiting for rate limit or queue are running calls of managed plugin
+		interceptors = append(interceptors, o.activity.interceptor())
+	}
+	if o.rateLimit != nil || len(o.funcRateLimits) > 0 {
+		interceptors = append(interceptors, newRateLimitInterceptor(o.rateLimit, o.funcRateLimits, o.queueTimeout))
+	}
+	if queue != nil {
+		interceptors = append(interceptors, queue.interceptor())
+	}
+	if o.auditSink != nil {
+		interceptors = append(interceptors, newAuditInterceptor(p, o.auditSink, o.auditKey))
+	}
+	if len(o.resultSchemas) > 0 {
+		interceptors = append(interceptors, newSchemaInterceptor(o.resultSchemas))
+	}
+	if o.tracer != nil {
+		interceptors = append(interceptors, newTraceInterceptor(p, o.tracer))
+	}
+	if o.chaos != nil {
+		interceptors = append(interceptors, newChaosInterceptor(p, *o.chaos))
+	}
+	// statistics are innermost to measure plugin function time only
+	interceptors = append(interceptors, stats.interceptor())
+	return interceptors
+}
+
+// interceptedPlugin applies host side interceptors to plugin function calls
+type interceptedPlugin struct {
+	pluginBackend
+	call    callHandler
+	queue   *callQueue // nil if concurrency is not limited
+	stats   *callStats
+	option  *pluginOption
+	created time.Time
+
+	interceptors []callInterceptor // wrapping calls with metadata
+	artifactsMu  sync.Mutex        // serializes artifact collection
+
+	asyncMu    sync.Mutex           // guards calls in background
+	asyncCalls map[string]*Future   // submitted calls by call id, released by Wait or Cancel
+	asyncSeq   int64                // sequence number of call ids
+	running    map[*Future]struct{} // calls in background aborted on Quit
+	durable    *durableQueue        // persists submitted calls, see WithDurableQueue
+
+	schedulesMu sync.Mutex       // guards schedules
+	schedules   []*ScheduledCall // scheduled calls stopped on Quit
+}
+
+// wrapPlugin adds host side features to plugin backend
+func wrapPlugin(p pluginBackend, option *pluginOption) IPlugin {
+	var queue *callQueue
+	if option.maxConcurrency > 0 {
+		queue = newCallQueue(option.maxConcurrency, option.queueSize, option.queueTimeout, option.funcPriorities)
+	}
+	stats := newCallStats()
+
+	interceptors := option.interceptors(p, queue, stats)
+	call := callHandler(p.Call)
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		call = interceptors[i](context.Background(), call)
+	}
+	return &interceptedPlugin{pluginBackend: p, call: call, interceptors: interceptors,
+		queue: queue, stats: stats, option: option, created: time.Now()}
+}
+
+// intercept wraps call of plugin backend with interceptors for a call aborted when ctx is done
+func (p *interceptedPlugin) intercept(ctx context.Context, call callHandler) callHandler {
+	for i := len(p.interceptors) - 1; i >= 0; i-- {
+		call = p.interceptors[i](ctx, call)
+	}
+	return call
+}
+
+func (p *interceptedPlugin) Call(funcName string, args ...interface{}) (interface{}, error) {
+	return p.call(funcName, args...)
+}
+
+func (p *interceptedPlugin) GetNames() ([]string, error) {
+	lister, ok := p.pluginBackend.(IFuncLister)
+	if !ok {
+		return nil, fmt.Errorf("%s does not support listing functions", p.pluginBackend.Type())
+	}
+	return lister.GetNames()
+}
+
+func (p *interceptedPlugin) QueueStats() QueueStats {
+	if p.queue == nil {
+		return QueueStats{}
+	}
+	return p.queue.stats()
+}
+
+func (p *interceptedPlugin) Stats() PluginStats {
+	return p.stats.stats()
+}
+
+// Quit stops scheduled calls, quits plugin and logs call statistics report
+func (p *interceptedPlugin) Quit() (err error) {
+	defer func() {
+		if closeErr := p.option.releaseLogger(); err == nil {
+			err = closeErr
+		}
+	}()
+	p.stopSchedules()
+	p.abortCalls(ErrPluginQuit)
+	if stats := p.Stats(); len(stats.Funcs) > 0 {
+		logger.Info("plugin call statistics\n" + stats.Report())
+	}
+	defer p.option.removeLocalDataDir()
+	// artifacts in container or on remote machine are gone with plugin, while
+	// those of local plugin process are collected after shutdown hooks write them
+	if _, ok := p.pluginBackend.(artifactDownloader); ok {
+		p.collectArtifactsOnQuit()
+		return p.pluginBackend.Quit()
+	}
+	defer p.option.removeLocalArtifactDir()
+	err = p.pluginBackend.Quit()
+	p.collectArtifactsOnQuit()
+	return err
+}
@@ -0,0 +1,64 @@
+package funplugin
+
+import (
+	"os/exec"
+	"sync"
+)
+
+// IsolationBackend launches plugin processes in an isolated sandbox,
+// e.g. gVisor or firecracker, selected with WithIsolation
+type IsolationBackend interface {
+	// Command returns command running plugin command name with args in sandbox
+	Command(name string, args ...string) *exec.Cmd
+}
+
+var (
+	isolationMutex    sync.RWMutex
+	isolationBackends = map[string]IsolationBackend{
+		"gvisor": &GVisorBackend{Runsc: "runsc", Flags: []string{"--network=host"}},
+	}
+)
+
+// RegisterIsolation registers isolation backend with name, it overrides
+// the existing backend with the same name, e.g. to customize gvisor flags
+func RegisterIsolation(name string, backend IsolationBackend) {
+	isolationMutex.Lock()
+	defer isolationMutex.Unlock()
+	isolationBackends[name] = backend
+}
+
+func getIsolation(name string) (IsolationBackend, bool) {
+	isolationMutex.RLock()
+	defer isolationMutex.RUnlock()
+	backend, ok := isolationBackends[name]
+	return backend, ok
+}
+
+// GVisorBackend runs plugin processes with `runsc do`, host network is shared
+// by default so that hashicorp python plugin gRPC server is reachable.
+// Go plugins should use stdio transport since unix sockets in sandbox are invisible to host.
+type GVisorBackend struct {
+	Runsc string   // runsc binary path
+	Flags []string // runsc global flags, e.g. --rootless
+}
+
+func (b *GVisorBackend) Command(name string, args ...string) *exec.Cmd {
+	runscArgs := append([]string{}, b.Flags...)
+	runscArgs = append(runscArgs, "do", name)
+	runscArgs = append(runscArgs, args...)
+	return exec.Command(b.Runsc, runscArgs...)
+}
+
+// command returns command launching plugin process, in sandbox if isolation is specified
+func (o *pluginOption) command(path string) *exec.Cmd {
+	name, args := path, []string{}
+	if o.langType == langTypePython {
+		name, args = o.python3, []string{"-c", pythonLauncher, path}
+	}
+	if o.isolation != "" {
+		if backend, ok := getIsolation(o.isolation); ok {
+			return backend.Command(name, args...)
+		}
+	}
+	return exec.Command(name, args...)
+}
@@ -0,0 +1,56 @@
+package funplugin
+
+import (
+	"os/exec"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// envBackend launches plugin process via env, to verify backend wiring without a sandbox
+type envBackend struct {
+	launched int
+}
+
+func (b *envBackend) Command(name string, args ...string) *exec.Cmd {
+	b.launched++
+	return exec.Command("env", append([]string{name}, args...)...)
+}
+
+func TestGVisorCommand(t *testing.T) {
+	option := &pluginOption{
+		langType:  langTypePython,
+		python3:   "/usr/bin/python3",
+		isolation: "gvisor",
+	}
+	cmd := option.command("debugtalk.py")
+	assert.Equal(t, []string{
+		"runsc", "--network=host", "do", "/usr/bin/python3", "-c", pythonLauncher, "debugtalk.py",
+	}, cmd.Args)
+}
+
+func TestIsolationPlugin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("env is not available on windows")
+	}
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	backend := &envBackend{}
+	RegisterIsolation("env", backend)
+
+	plugin, err := Init(pluginBinPath, WithTransport("stdio"), WithIsolation("env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	assert.Equal(t, 1, backend.launched)
+	assertPlugin(t, plugin)
+}
+
+func TestUnsupportedIsolation(t *testing.T) {
+	_, err := Init(pluginBinPath, WithIsolation("firecracker"))
+	assert.EqualError(t, err, "unsupported isolation backend: firecracker")
+}
@@ -0,0 +1,41 @@
+// plugin functions are global javascript functions,
+// throw an error to make the call fail
+
+function sum(...args) {
+    return args.reduce((a, b) => a + b, 0);
+}
+
+function sum_ints(...args) {
+    return sum(...args);
+}
+
+function sum_two_int(a, b) {
+    return a + b;
+}
+
+function sum_two_string(a, b) {
+    return a + b;
+}
+
+function sum_strings(...args) {
+    return args.join("");
+}
+
+function concatenate(...args) {
+    return args.map(String).join("");
+}
+
+function setup_hook_example(name) {
+    return "setup_hook_example: " + name;
+}
+
+function teardown_hook_example(name) {
+    return "teardown_hook_example: " + name;
+}
+
+function divide(a, b) {
+    if (b === 0) {
+        throw "division by zero";
+    }
+    return a / b;
+}
@@ -0,0 +1,107 @@
+package funplugin
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/dop251/goja"
+	"github.com/pkg/errors"
+)
+
+// jsPlugin runs javascript plugin script in process with goja, no node required
+type jsPlugin struct {
+	runtime *goja.Runtime
+	mutex   sync.Mutex // goja runtime is not goroutine safe
+	path    string     // plugin file path
+}
+
+func newJSPlugin(path string) (*jsPlugin, error) {
+	// logger
+	logger := logger.ResetNamed("js-plugin")
+
+	script, err := os.ReadFile(path)
+	if err != nil {
+		logger.Error("read js plugin failed", "path", path, "error", err)
+		return nil, errors.Wrap(err, "read js plugin failed")
+	}
+
+	runtime := goja.New()
+	if _, err := runtime.RunScript(path, string(script)); err != nil {
+		logger.Error("load js plugin failed", "path", path, "error", err)
+		return nil, errors.Wrap(err, "load js plugin failed")
+	}
+
+	logger.Info("load js plugin success", "path", path)
+	return &jsPlugin{
+		runtime: runtime,
+		path:    path,
+	}, nil
+}
+
+func (p *jsPlugin) Type() string {
+	return "js-plugin"
+}
+
+func (p *jsPlugin) Path() string {
+	return p.path
+}
+
+func (p *jsPlugin) Has(funcName string) bool {
+	logger.Debug("check if plugin has function", "funcName", funcName)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	_, ok := goja.AssertFunction(p.runtime.Get(funcName))
+	return ok
+}
+
+func (p *jsPlugin) GetNames() ([]string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var names []string
+	global := p.runtime.GlobalObject()
+	for _, key := range global.Keys() {
+		if _, ok := goja.AssertFunction(global.Get(key)); ok {
+			names = append(names, key)
+		}
+	}
+	return names, nil
+}
+
+// Call calls javascript global function, thrown exceptions are returned as error
+func (p *jsPlugin) Call(funcName string, args ...interface{}) (interface{}, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	fn, ok := goja.AssertFunction(p.runtime.Get(funcName))
+	if !ok {
+		return nil, fmt.Errorf("function %s not found", funcName)
+	}
+
+	jsArgs := make([]goja.Value, len(args))
+	for i, arg := range args {
+		jsArgs[i] = p.runtime.ToValue(arg)
+	}
+
+	ret, err := fn(goja.Undefined(), jsArgs...)
+	if err != nil {
+		if exception, ok := err.(*goja.Exception); ok {
+			return nil, fmt.Errorf("%s", exception.Value().String())
+		}
+		return nil, err
+	}
+	return ret.Export(), nil
+}
+
+func (p *jsPlugin) Quit() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.runtime.Interrupt("plugin quit")
+	return nil
+}
+
+func (p *jsPlugin) StartHeartbeat() {
+
+}
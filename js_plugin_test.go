@@ -0,0 +1,28 @@
+package funplugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSPlugin(t *testing.T) {
+	plugin, err := Init("js/examples/debugtalk.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	assertPlugin(t, plugin)
+
+	assert.False(t, plugin.Has("not_exist"))
+
+	v, err := plugin.Call("divide", 6, 4)
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+	assert.Equal(t, 1.5, v)
+
+	_, err = plugin.Call("divide", 1, 0)
+	assert.EqualError(t, err, "division by zero")
+}
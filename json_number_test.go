@@ -0,0 +1,56 @@
+package funplugin
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+func TestJSONNumberGoPlugin(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	for _, transport := range []string{"", "stdio"} {
+		plugin, err := Init(pluginBinPath, WithTransport(transport))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// large integers are mangled by float64 by default
+		result, err := plugin.Call("concatenate", 1234567890123456789)
+		assert.Nil(t, err)
+		assert.Equal(t, "1.2345678901234568e+18", result)
+		plugin.Quit()
+
+		plugin, err = Init(pluginBinPath, WithTransport(transport), WithJSONNumber(fungo.NumberInt64))
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err = plugin.Call("concatenate", 1234567890123456789, 1.5)
+		assert.Nil(t, err, transport)
+		assert.Equal(t, "12345678901234567891.5", result, transport)
+		result, err = plugin.Call("sum_two_int", 1234567890123456789, 1)
+		assert.Nil(t, err, transport)
+		assert.Equal(t, int64(1234567890123456790), result, transport)
+		plugin.Quit()
+
+		plugin, err = Init(pluginBinPath, WithTransport(transport), WithJSONNumber(fungo.NumberJSON))
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err = plugin.Call("sum_two_int", 1234567890123456789, 1)
+		assert.Nil(t, err, transport)
+		assert.Equal(t, json.Number("1234567890123456790"), result, transport)
+		plugin.Quit()
+	}
+}
+
+func TestJSONNumberInvalid(t *testing.T) {
+	_, err := Init(pluginBinPath, WithJSONNumber("decimal"))
+	assert.EqualError(t, err, "unsupported JSON number mode: decimal")
+
+	_, err = Init("debugtalk.lua", WithJSONNumber(fungo.NumberInt64))
+	assert.Contains(t, err.Error(), "WithJSONNumber only applies to .bin/.py plugins")
+}
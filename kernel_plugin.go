@@ -0,0 +1,77 @@
+package funplugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/hashicorp/go-plugin"
+	"github.com/pkg/errors"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+// kernelConnection is written by funppy.serve_kernel() in a running
+// Jupyter kernel or IPython session
+type kernelConnection struct {
+	Pid      int    `json:"pid"`      // kernel process id
+	Addr     string `json:"addr"`     // gRPC server address, e.g. 127.0.0.1:50051
+	Protocol string `json:"protocol"` // only grpc is supported
+}
+
+func loadKernelConnection(connFile string) (*kernelConnection, error) {
+	content, err := os.ReadFile(connFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "read kernel connection file failed")
+	}
+
+	conn := &kernelConnection{}
+	if err := json.Unmarshal(content, conn); err != nil {
+		return nil, errors.Wrap(err, "parse kernel connection file failed")
+	}
+	if conn.Protocol != "" && conn.Protocol != string(plugin.ProtocolGRPC) {
+		return nil, fmt.Errorf("unsupported kernel protocol: %s", conn.Protocol)
+	}
+	if conn.Addr == "" {
+		return nil, fmt.Errorf("kernel address missing in %s", connFile)
+	}
+	return conn, nil
+}
+
+// newKernelPlugin attaches to plugin functions served by a running
+// Jupyter kernel, the kernel process is never killed by the host.
+func newKernelPlugin(connFile string, option *pluginOption) (*hashicorpPlugin, error) {
+	conn, err := loadKernelConnection(connFile)
+	if err != nil {
+		logger.Error("load kernel connection failed", "path", connFile, "error", err)
+		return nil, err
+	}
+
+	addr, err := net.ResolveTCPAddr("tcp", conn.Addr)
+	if err != nil {
+		return nil, errors.Wrap(err, "resolve kernel address failed")
+	}
+
+	option.langType = langTypePython
+	p := &hashicorpPlugin{
+		path:    connFile,
+		option:  option,
+		rpcType: rpcTypeGRPC,
+		reattach: &plugin.ReattachConfig{
+			Protocol:        plugin.ProtocolGRPC,
+			ProtocolVersion: int(fungo.HandshakeConfig.ProtocolVersion),
+			Addr:            addr,
+			Pid:             conn.Pid,
+			// test mode avoids killing the kernel process on Quit
+			Test: true,
+		},
+	}
+
+	p.logger = logger.ResetNamed("jupyter-kernel")
+	if err := p.startPlugin(); err != nil {
+		return nil, err
+	}
+	p.logger.Info("attach jupyter kernel success", "addr", conn.Addr, "pid", conn.Pid)
+	return p, nil
+}
@@ -0,0 +1,37 @@
+package funplugin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadKernelConnection(t *testing.T) {
+	dir := t.TempDir()
+
+	connFile := filepath.Join(dir, "kernel.json")
+	err := os.WriteFile(connFile,
+		[]byte(`{"pid": 123, "addr": "127.0.0.1:50051", "protocol": "grpc"}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := loadKernelConnection(connFile)
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+	assert.Equal(t, 123, conn.Pid)
+	assert.Equal(t, "127.0.0.1:50051", conn.Addr)
+
+	invalidFile := filepath.Join(dir, "invalid.json")
+	err = os.WriteFile(invalidFile, []byte(`{"pid": 123, "protocol": "netrpc"}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = loadKernelConnection(invalidFile)
+	assert.Error(t, err)
+
+	_, err = Init(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,252 @@
+package funplugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// LicensePolicy is an allow/deny list of SPDX license identifiers, e.g. MIT, Apache-2.0,
+// common license names such as "Apache Software License" are normalized before matching
+type LicensePolicy struct {
+	Allow        []string // allowed licenses, empty means all licenses not denied
+	Deny         []string // forbidden licenses, e.g. GPL-3.0, AGPL-3.0
+	AllowUnknown bool     // allow packages without detectable license
+}
+
+// LicenseViolation is a plugin dependency with a forbidden or unknown license
+type LicenseViolation struct {
+	Package string `json:"package"`
+	Version string `json:"version"`
+	License string `json:"license"` // empty if unknown
+}
+
+// LicenseError is returned by Init initialized with WithLicensePolicy
+type LicenseError struct {
+	Violations []LicenseViolation
+}
+
+func (e *LicenseError) Error() string {
+	items := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		license := v.License
+		if license == "" {
+			license = "unknown"
+		}
+		items = append(items, fmt.Sprintf("%s@%s (%s)", v.Package, v.Version, license))
+	}
+	return fmt.Sprintf("plugin dependencies violate license policy: %s", strings.Join(items, ", "))
+}
+
+// CheckLicenses inspects licenses of python packages installed in venv directory,
+// or go modules embedded in go plugin binary, against license policy
+func CheckLicenses(path string, policy LicensePolicy) ([]LicenseViolation, error) {
+	sbom, err := GenerateSBOM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var violations []LicenseViolation
+	for _, c := range sbom.Components {
+		var license string
+		if len(c.Licenses) > 0 {
+			license = c.Licenses[0].License.Name
+		}
+		if !policy.allowed(license) {
+			violations = append(violations, LicenseViolation{
+				Package: c.Name,
+				Version: c.Version,
+				License: license,
+			})
+		}
+	}
+	return violations, nil
+}
+
+// allowed checks license expression, e.g. "MIT OR Apache-2.0" is allowed
+// if any alternative is allowed, and all licenses joined by AND must be allowed
+func (p LicensePolicy) allowed(expression string) bool {
+	expression = strings.TrimSpace(expression)
+	if strings.HasPrefix(expression, "(") && strings.HasSuffix(expression, ")") {
+		expression = expression[1 : len(expression)-1]
+	}
+	if expression == "" {
+		return p.AllowUnknown
+	}
+	for _, alternative := range strings.Split(expression, " OR ") {
+		ok := true
+		for _, license := range strings.Split(alternative, " AND ") {
+			if !p.allowedLicense(normalizeLicense(license)) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (p LicensePolicy) allowedLicense(license string) bool {
+	for _, deny := range p.Deny {
+		if strings.EqualFold(normalizeLicense(deny), license) {
+			return false
+		}
+	}
+	if len(p.Allow) == 0 {
+		return true
+	}
+	for _, allow := range p.Allow {
+		if strings.EqualFold(normalizeLicense(allow), license) {
+			return true
+		}
+	}
+	return false
+}
+
+// licenseAliases maps common license names in python package metadata to SPDX identifiers
+var licenseAliases = map[string]string{
+	"mit":                                   "MIT",
+	"mit license":                           "MIT",
+	"apache 2.0":                            "Apache-2.0",
+	"apache-2":                              "Apache-2.0",
+	"apache license 2.0":                    "Apache-2.0",
+	"apache license, version 2.0":           "Apache-2.0",
+	"apache software license":               "Apache-2.0",
+	"bsd":                                   "BSD-3-Clause",
+	"bsd license":                           "BSD-3-Clause",
+	"new bsd license":                       "BSD-3-Clause",
+	"3-clause bsd license":                  "BSD-3-Clause",
+	"isc license (iscl)":                    "ISC",
+	"mozilla public license 2.0 (mpl 2.0)":  "MPL-2.0",
+	"gpl":                                   "GPL-3.0",
+	"gplv2":                                 "GPL-2.0",
+	"gplv3":                                 "GPL-3.0",
+	"gnu general public license v2 (gplv2)": "GPL-2.0",
+	"gnu general public license v3 (gplv3)": "GPL-3.0",
+	"gnu affero general public license v3":  "AGPL-3.0",
+	"gnu lesser general public license v3 (lgplv3)": "LGPL-3.0",
+	"the unlicense (unlicense)":                     "Unlicense",
+}
+
+// normalizeLicense returns SPDX identifier of common license names,
+// trove classifiers such as "OSI Approved :: MIT License" are supported
+func normalizeLicense(license string) string {
+	license = strings.TrimSpace(license)
+	if i := strings.LastIndex(license, "::"); i >= 0 {
+		license = strings.TrimSpace(license[i+2:])
+	}
+	license = strings.TrimSuffix(license, "-only")
+	if spdx, ok := licenseAliases[strings.ToLower(license)]; ok {
+		return spdx
+	}
+	return license
+}
+
+// detectLicense detects SPDX identifier of license text by well-known phrases
+func detectLicense(text string) string {
+	has := func(phrases ...string) bool {
+		for _, phrase := range phrases {
+			if !strings.Contains(text, phrase) {
+				return false
+			}
+		}
+		return true
+	}
+	switch {
+	case has("GNU AFFERO GENERAL PUBLIC LICENSE"):
+		return "AGPL-3.0"
+	case has("GNU LESSER GENERAL PUBLIC LICENSE", "Version 3"):
+		return "LGPL-3.0"
+	case has("GNU LESSER GENERAL PUBLIC LICENSE"):
+		return "LGPL-2.1"
+	case has("GNU GENERAL PUBLIC LICENSE", "Version 3"):
+		return "GPL-3.0"
+	case has("GNU GENERAL PUBLIC LICENSE"):
+		return "GPL-2.0"
+	case has("Mozilla Public License", "2.0"):
+		return "MPL-2.0"
+	case has("Apache License", "Version 2.0"):
+		return "Apache-2.0"
+	case has("Permission is hereby granted, free of charge"):
+		return "MIT"
+	case has("Redistribution and use in source and binary forms", "Neither the name"):
+		return "BSD-3-Clause"
+	case has("Redistribution and use in source and binary forms"):
+		return "BSD-2-Clause"
+	case has("Permission to use, copy, modify, and/or distribute"):
+		return "ISC"
+	case has("This is free and unencumbered software released into the public domain"):
+		return "Unlicense"
+	}
+	return ""
+}
+
+// goModuleLicense detects license of go module in module cache,
+// empty string is returned if module is not downloaded
+func goModuleLicense(modPath, version string) string {
+	modCache := os.Getenv("GOMODCACHE")
+	if modCache == "" {
+		gopath := os.Getenv("GOPATH")
+		if gopath == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return ""
+			}
+			gopath = filepath.Join(home, "go")
+		}
+		modCache = filepath.Join(filepath.SplitList(gopath)[0], "pkg", "mod")
+	}
+
+	dir := filepath.Join(modCache, filepath.FromSlash(escapeModulePath(modPath)+"@"+version))
+	for _, name := range []string{"LICENSE", "LICENSE.txt", "LICENSE.md", "COPYING", "License"} {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return detectLicense(string(content))
+		}
+	}
+	return ""
+}
+
+// escapeModulePath escapes upper case letters as module cache does, e.g. !microsoft
+func escapeModulePath(modPath string) string {
+	var b strings.Builder
+	for _, r := range modPath {
+		if unicode.IsUpper(r) {
+			b.WriteByte('!')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// checkLicenses checks plugin dependencies against license policy before plugin is executed
+func (o *pluginOption) checkLicenses(path string) error {
+	if o.licensePolicy == nil {
+		return nil
+	}
+	violations, err := CheckLicenses(path, *o.licensePolicy)
+	if err != nil {
+		return errors.Wrap(err, "check plugin licenses failed")
+	}
+	if len(violations) > 0 {
+		logger.Error("plugin dependencies violate license policy", "path", path, "violations", violations)
+		return &LicenseError{Violations: violations}
+	}
+	return nil
+}
+
+// pythonVenv returns venv directory of python3 executable, e.g. venv/bin/python3
+func pythonVenv(python3 string) (string, error) {
+	venv := filepath.Dir(filepath.Dir(python3))
+	if _, err := os.Stat(filepath.Join(venv, "pyvenv.cfg")); err != nil {
+		return "", fmt.Errorf("python3 %s is not in a venv", python3)
+	}
+	return venv, nil
+}
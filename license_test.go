@@ -0,0 +1,111 @@
+package funplugin
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeLicense(t *testing.T) {
+	assert.Equal(t, "MIT", normalizeLicense("MIT License"))
+	assert.Equal(t, "MIT", normalizeLicense("License :: OSI Approved :: MIT License"))
+	assert.Equal(t, "Apache-2.0", normalizeLicense("Apache Software License"))
+	assert.Equal(t, "GPL-3.0", normalizeLicense("GPL-3.0-only"))
+	assert.Equal(t, "Zlib", normalizeLicense("Zlib"))
+}
+
+func TestLicensePolicyAllowed(t *testing.T) {
+	policy := LicensePolicy{Deny: []string{"GPL-3.0", "AGPL-3.0"}}
+	assert.True(t, policy.allowed("MIT"))
+	assert.False(t, policy.allowed("GNU General Public License v3 (GPLv3)"))
+	assert.True(t, policy.allowed("GPL-3.0 OR MIT"))
+	assert.False(t, policy.allowed("MIT AND AGPL-3.0"))
+	assert.False(t, policy.allowed(""))
+
+	policy = LicensePolicy{Allow: []string{"MIT", "Apache-2.0"}, AllowUnknown: true}
+	assert.True(t, policy.allowed("Apache License 2.0"))
+	assert.False(t, policy.allowed("MPL-2.0"))
+	assert.True(t, policy.allowed(""))
+}
+
+func TestDetectLicense(t *testing.T) {
+	assert.Equal(t, "MIT", detectLicense("MIT License\n\nPermission is hereby granted, free of charge, to any person"))
+	assert.Equal(t, "Apache-2.0", detectLicense("Apache License\nVersion 2.0, January 2004"))
+	assert.Equal(t, "GPL-3.0", detectLicense("GNU GENERAL PUBLIC LICENSE\nVersion 3, 29 June 2007"))
+	assert.Equal(t, "BSD-3-Clause", detectLicense("Redistribution and use in source and binary forms ... Neither the name of"))
+	assert.Equal(t, "", detectLicense("All rights reserved."))
+}
+
+func TestEscapeModulePath(t *testing.T) {
+	assert.Equal(t, "github.com/!microsoft/go-winio", escapeModulePath("github.com/Microsoft/go-winio"))
+}
+
+// fakeVenv creates venv with packages dist-info metadata, keyed by name==version
+func fakeVenv(t *testing.T, packages map[string]string) string {
+	venv := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(venv, "pyvenv.cfg"), []byte("home = /usr/bin\n"), 0o644))
+	assert.Nil(t, os.MkdirAll(filepath.Join(venv, "bin"), 0o755))
+	for pkg, metadata := range packages {
+		dir := filepath.Join(venv, "lib", "python3.11", "site-packages", pkg+".dist-info")
+		assert.Nil(t, os.MkdirAll(dir, 0o755))
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "METADATA"), []byte(metadata), 0o644))
+	}
+	return venv
+}
+
+func TestCheckLicensesVenv(t *testing.T) {
+	venv := fakeVenv(t, map[string]string{
+		"funppy-0.5.0":   "Name: funppy\nVersion: 0.5.0\nLicense: Apache-2.0\n",
+		"gplthing-1.0.0": "Name: gplthing\nVersion: 1.0.0\nLicense: UNKNOWN\nClassifier: License :: OSI Approved :: GNU General Public License v3 (GPLv3)\n",
+		"mystery-0.1.0":  "Name: mystery\nVersion: 0.1.0\n",
+	})
+
+	violations, err := CheckLicenses(venv, LicensePolicy{Deny: []string{"GPL-3.0"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []LicenseViolation{
+		{Package: "gplthing", Version: "1.0.0", License: "License :: OSI Approved :: GNU General Public License v3 (GPLv3)"},
+		{Package: "mystery", Version: "0.1.0"},
+	}, violations)
+
+	// provisioning fails before plugin is executed
+	_, err = Init("funppy/examples/debugtalk.py",
+		WithPython3(filepath.Join(venv, "bin", "python3")),
+		WithLicensePolicy(LicensePolicy{Deny: []string{"GPL-3.0"}, AllowUnknown: true}))
+	var licenseErr *LicenseError
+	if assert.True(t, errors.As(err, &licenseErr)) {
+		assert.Len(t, licenseErr.Violations, 1)
+		assert.Contains(t, err.Error(), "gplthing@1.0.0")
+	}
+}
+
+func TestCheckLicensesGoPlugin(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	sbom, err := GenerateSBOM(pluginBinPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var goPluginLicense string
+	for _, c := range sbom.Components {
+		if c.Name == "github.com/hashicorp/go-plugin" && len(c.Licenses) > 0 {
+			goPluginLicense = c.Licenses[0].License.Name
+		}
+	}
+	if goPluginLicense == "" {
+		t.Skip("go-plugin module not found in module cache")
+	}
+	assert.Equal(t, "MPL-2.0", goPluginLicense)
+
+	_, err = Init(pluginBinPath, WithLicensePolicy(LicensePolicy{
+		Deny: []string{"MPL-2.0"}, AllowUnknown: true,
+	}))
+	var licenseErr *LicenseError
+	assert.True(t, errors.As(err, &licenseErr))
+	assert.Contains(t, err.Error(), "github.com/hashicorp/go-plugin@")
+}
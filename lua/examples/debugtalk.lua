@@ -0,0 +1,49 @@
+-- plugin functions are global lua functions,
+-- return a value, or nil and an error message
+
+function sum(...)
+    local result = 0
+    for _, v in ipairs({...}) do
+        result = result + v
+    end
+    return result
+end
+
+function sum_ints(...)
+    return sum(...)
+end
+
+function sum_two_int(a, b)
+    return a + b
+end
+
+function sum_two_string(a, b)
+    return a .. b
+end
+
+function sum_strings(...)
+    return table.concat({...})
+end
+
+function concatenate(...)
+    local result = ""
+    for _, v in ipairs({...}) do
+        result = result .. tostring(v)
+    end
+    return result
+end
+
+function setup_hook_example(name)
+    return "setup_hook_example: " .. name
+end
+
+function teardown_hook_example(name)
+    return "teardown_hook_example: " .. name
+end
+
+function divide(a, b)
+    if b == 0 then
+        return nil, "division by zero"
+    end
+    return a / b
+end
@@ -0,0 +1,204 @@
+package funplugin
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/pkg/errors"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// luaBuiltins are global functions opened by lua standard libraries
+var luaBuiltins = func() map[string]bool {
+	builtins := make(map[string]bool)
+	state := lua.NewState()
+	defer state.Close()
+	state.G.Global.ForEach(func(key, _ lua.LValue) {
+		builtins[key.String()] = true
+	})
+	return builtins
+}()
+
+// luaPlugin runs lua plugin script in process with gopher-lua
+type luaPlugin struct {
+	state *lua.LState
+	mutex sync.Mutex // lua state is not goroutine safe
+	path  string     // plugin file path
+}
+
+func newLuaPlugin(path string) (*luaPlugin, error) {
+	// logger
+	logger := logger.ResetNamed("lua-plugin")
+
+	state := lua.NewState()
+	if err := state.DoFile(path); err != nil {
+		state.Close()
+		logger.Error("load lua plugin failed", "path", path, "error", err)
+		return nil, errors.Wrap(err, "load lua plugin failed")
+	}
+
+	logger.Info("load lua plugin success", "path", path)
+	return &luaPlugin{
+		state: state,
+		path:  path,
+	}, nil
+}
+
+func (p *luaPlugin) Type() string {
+	return "lua-plugin"
+}
+
+func (p *luaPlugin) Path() string {
+	return p.path
+}
+
+func (p *luaPlugin) Has(funcName string) bool {
+	logger.Debug("check if plugin has function", "funcName", funcName)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	_, ok := p.state.GetGlobal(funcName).(*lua.LFunction)
+	return ok
+}
+
+func (p *luaPlugin) GetNames() ([]string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var names []string
+	p.state.G.Global.ForEach(func(key, value lua.LValue) {
+		if _, ok := value.(*lua.LFunction); !ok {
+			return
+		}
+		// skip lua builtin functions
+		if name := key.String(); !luaBuiltins[name] {
+			names = append(names, name)
+		}
+	})
+	return names, nil
+}
+
+// Call calls lua global function, the function may return a value and an error message
+func (p *luaPlugin) Call(funcName string, args ...interface{}) (interface{}, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	fn, ok := p.state.GetGlobal(funcName).(*lua.LFunction)
+	if !ok {
+		return nil, fmt.Errorf("function %s not found", funcName)
+	}
+
+	luaArgs := make([]lua.LValue, len(args))
+	for i, arg := range args {
+		v, err := toLuaValue(p.state, reflect.ValueOf(arg))
+		if err != nil {
+			return nil, errors.Wrapf(err, "convert argument %d failed", i)
+		}
+		luaArgs[i] = v
+	}
+
+	err := p.state.CallByParam(lua.P{
+		Fn:      fn,
+		NRet:    2,
+		Protect: true,
+	}, luaArgs...)
+	if err != nil {
+		return nil, err
+	}
+	ret, retErr := p.state.Get(-2), p.state.Get(-1)
+	p.state.Pop(2)
+
+	if retErr != lua.LNil {
+		return fromLuaValue(ret), fmt.Errorf("%s", retErr.String())
+	}
+	return fromLuaValue(ret), nil
+}
+
+func (p *luaPlugin) Quit() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.state.Close()
+	return nil
+}
+
+func (p *luaPlugin) StartHeartbeat() {
+
+}
+
+func toLuaValue(state *lua.LState, v reflect.Value) (lua.LValue, error) {
+	if !v.IsValid() {
+		return lua.LNil, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return lua.LNil, nil
+		}
+		return toLuaValue(state, v.Elem())
+	case reflect.Bool:
+		return lua.LBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return lua.LNumber(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return lua.LNumber(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return lua.LNumber(v.Float()), nil
+	case reflect.String:
+		return lua.LString(v.String()), nil
+	case reflect.Slice, reflect.Array:
+		table := state.NewTable()
+		for i := 0; i < v.Len(); i++ {
+			item, err := toLuaValue(state, v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			table.Append(item)
+		}
+		return table, nil
+	case reflect.Map:
+		table := state.NewTable()
+		iter := v.MapRange()
+		for iter.Next() {
+			item, err := toLuaValue(state, iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			table.RawSetString(fmt.Sprintf("%v", iter.Key().Interface()), item)
+		}
+		return table, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %v", v.Type())
+	}
+}
+
+// fromLuaValue converts lua value to go value in the same way as json decoding
+func fromLuaValue(v lua.LValue) interface{} {
+	switch value := v.(type) {
+	case lua.LBool:
+		return bool(value)
+	case lua.LNumber:
+		return float64(value)
+	case lua.LString:
+		return string(value)
+	case *lua.LTable:
+		count := 0
+		value.ForEach(func(_, _ lua.LValue) { count++ })
+		// array-like table
+		if n := value.MaxN(); n > 0 && n == count {
+			items := make([]interface{}, n)
+			for i := 1; i <= n; i++ {
+				items[i-1] = fromLuaValue(value.RawGetInt(i))
+			}
+			return items
+		}
+		items := make(map[string]interface{})
+		value.ForEach(func(key, item lua.LValue) {
+			items[key.String()] = fromLuaValue(item)
+		})
+		return items
+	default:
+		return nil
+	}
+}
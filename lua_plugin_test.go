@@ -0,0 +1,35 @@
+package funplugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLuaPlugin(t *testing.T) {
+	plugin, err := Init("lua/examples/debugtalk.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	assertPlugin(t, plugin)
+
+	assert.False(t, plugin.Has("not_exist"))
+
+	names, err := plugin.(IFuncLister).GetNames()
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+	assert.Contains(t, names, "sum_ints")
+	assert.NotContains(t, names, "print")
+
+	v, err := plugin.Call("divide", 6, 3)
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+	assert.Equal(t, 2.0, v)
+
+	_, err = plugin.Call("divide", 1, 0)
+	assert.EqualError(t, err, "division by zero")
+}
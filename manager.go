@@ -0,0 +1,462 @@
+package funplugin
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrManagerClosed is returned when loading plugins after Manager is shut down
+	ErrManagerClosed = errors.New("plugin manager is shut down")
+	// ErrPluginExists is returned when loading a plugin with name in use by the tenant
+	ErrPluginExists = errors.New("plugin name is in use")
+	// ErrPluginNotFound is returned when unloading a plugin which is not loaded
+	ErrPluginNotFound = errors.New("plugin is not found")
+)
+
+// Quota limits plugins of a tenant, zero values mean unlimited. Limits of calls are
+// applied to each plugin of the tenant, overriding those of plugin options.
+type Quota struct {
+	MaxPlugins     int     // max plugins loaded by tenant at the same time
+	MaxConcurrency int     // max concurrent calls of each plugin, see WithConcurrencyLimit
+	QueueSize      int     // max calls of each plugin waiting for concurrency slots
+	RateLimit      float64 // max calls per second of each plugin, see WithRateLimit
+	Burst          int     // burst of RateLimit
+}
+
+// options returns plugin options enforcing call limits of quota
+func (q Quota) options() []Option {
+	var options []Option
+	if q.MaxConcurrency > 0 {
+		options = append(options, WithConcurrencyLimit(q.MaxConcurrency, q.QueueSize, 0))
+	}
+	if q.RateLimit > 0 {
+		options = append(options, WithRateLimit(q.RateLimit, q.Burst))
+	}
+	return options
+}
+
+// QuotaError is returned by Manager.Load when tenant reaches MaxPlugins of its quota
+type QuotaError struct {
+	Tenant     string
+	MaxPlugins int
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("tenant %s reaches quota of %d plugins", e.Tenant, e.MaxPlugins)
+}
+
+// ManagedPlugin describes a plugin loaded by Manager
+type ManagedPlugin struct {
+	Tenant   string    `json:"tenant"`
+	Name     string    `json:"name"`
+	Path     string    `json:"path"`
+	Type     string    `json:"type"`
+	Loaded   time.Time `json:"loaded"`
+	Refs     int       `json:"refs,omitempty"` // holders of shared plugin, see Acquire
+	LastUsed time.Time `json:"last_used"`      // last time plugin was called or looked up
+}
+
+// managedPlugin is plugin entry of Manager, plugin is nil while it is being loaded
+type managedPlugin struct {
+	ManagedPlugin
+	plugin   IPlugin
+	activity *pluginActivity // nil for shared plugins
+	cgroup   string          // cgroup directory of plugin process, see WithCgroup
+}
+
+type pluginKey struct {
+	tenant, name string
+}
+
+// ManagerOption configures Manager created by NewManager
+type ManagerOption func(*Manager)
+
+// WithDefaultQuota sets quota of tenants without quota set by WithTenantQuota
+func WithDefaultQuota(quota Quota) ManagerOption {
+	return func(m *Manager) {
+		m.defaultQuota = quota
+	}
+}
+
+// WithTenantQuota sets quota of the tenant
+func WithTenantQuota(tenant string, quota Quota) ManagerOption {
+	return func(m *Manager) {
+		m.quotas[tenant] = quota
+	}
+}
+
+// WithPluginOptions sets options applied to all plugins before options passed to Load
+func WithPluginOptions(options ...Option) ManagerOption {
+	return func(m *Manager) {
+		m.pluginOptions = append(m.pluginOptions, options...)
+	}
+}
+
+// Manager owns named plugins of many tenants, e.g. projects of a server embedding
+// funplugin. Plugins are loaded and looked up by tenant and name, limited by per-tenant
+// quotas, and quit together by Shutdown. It is safe for concurrent use.
+type Manager struct {
+	mu            sync.Mutex
+	plugins       map[pluginKey]*managedPlugin
+	shared        map[string]*sharedPlugin // shared plugins by absolute path, see Acquire
+	quotas        map[string]Quota
+	defaultQuota  Quota
+	pluginOptions []Option
+	maxPlugins    int                   // max plugins of all tenants, see WithMaxPlugins
+	idleTTL       time.Duration         // evict plugins idle longer, see WithIdleTTL
+	evictionHooks []func(EvictionEvent) // called after evicted plugins quit
+	stopJanitor   chan struct{}         // closed by Shutdown to stop evicting idle plugins
+	cgroupParent  string                // cgroup v2 directory of plugin cgroups, see WithCgroup
+	closed        bool
+}
+
+// NewManager creates plugin manager
+func NewManager(options ...ManagerOption) *Manager {
+	m := &Manager{
+		plugins: make(map[pluginKey]*managedPlugin),
+		shared:  make(map[string]*sharedPlugin),
+		quotas:  make(map[string]Quota),
+	}
+	for _, o := range options {
+		o(m)
+	}
+	if m.idleTTL > 0 {
+		m.stopJanitor = make(chan struct{})
+		go m.runJanitor(m.stopJanitor)
+	}
+	return m
+}
+
+// SetQuota sets quota of the tenant, it applies to plugins loaded afterwards
+func (m *Manager) SetQuota(tenant string, quota Quota) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.quotas[tenant] = quota
+}
+
+// Quota returns quota of the tenant
+func (m *Manager) Quota(tenant string) Quota {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.quota(tenant)
+}
+
+func (m *Manager) quota(tenant string) Quota {
+	if quota, ok := m.quotas[tenant]; ok {
+		return quota
+	}
+	return m.defaultQuota
+}
+
+// Load initializes plugin of path with options and registers it as name of the tenant.
+// It fails with ErrPluginExists if the name is in use, *QuotaError if tenant reaches
+// MaxPlugins of its quota, ErrManagerFull if manager reaches WithMaxPlugins and no
+// plugin is idle to be evicted, or ErrManagerClosed after Shutdown.
+func (m *Manager) Load(tenant, name, path string, options ...Option) (IPlugin, error) {
+	key := pluginKey{tenant: tenant, name: name}
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, ErrManagerClosed
+	}
+	if _, ok := m.plugins[key]; ok {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("load plugin %s of tenant %s: %w", name, tenant, ErrPluginExists)
+	}
+	quota := m.quota(tenant)
+	if quota.MaxPlugins > 0 && m.count(tenant) >= quota.MaxPlugins {
+		m.mu.Unlock()
+		return nil, &QuotaError{Tenant: tenant, MaxPlugins: quota.MaxPlugins}
+	}
+	var victim *managedPlugin
+	if m.maxPlugins > 0 && len(m.plugins) >= m.maxPlugins {
+		var ok bool
+		if victim, ok = m.evictLRU(); !ok {
+			m.mu.Unlock()
+			return nil, fmt.Errorf("load plugin %s of tenant %s: %w", name, tenant, ErrManagerFull)
+		}
+	}
+	// reserve the name and quota while plugin is initialized without lock
+	entry := &managedPlugin{
+		ManagedPlugin: ManagedPlugin{Tenant: tenant, Name: name, Path: path},
+		activity:      newPluginActivity(),
+	}
+	m.plugins[key] = entry
+	pluginOptions := append(append([]Option{}, m.pluginOptions...), options...)
+	pluginOptions = append(append(pluginOptions, quota.options()...), withActivity(entry.activity))
+	if m.cgroupParent != "" {
+		pluginOptions = append(pluginOptions, withRestartHook(func() { m.reattachCgroup(entry) }))
+	}
+	m.mu.Unlock()
+
+	if victim != nil {
+		m.evict(victim, EvictLRU)
+	}
+
+	plugin, err := Init(path, pluginOptions...)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		delete(m.plugins, key)
+		return nil, err
+	}
+	if m.closed {
+		// Shutdown has quit other plugins meanwhile
+		delete(m.plugins, key)
+		plugin.Quit()
+		return nil, ErrManagerClosed
+	}
+	entry.plugin = plugin
+	entry.Type = plugin.Type()
+	entry.Loaded = time.Now()
+	m.attachCgroup(entry)
+	logger.Info("plugin loaded by manager", "tenant", tenant, "name", name, "path", path)
+	return plugin, nil
+}
+
+// count returns number of plugins of tenant, including those being loaded
+func (m *Manager) count(tenant string) int {
+	n := 0
+	for key := range m.plugins {
+		if key.tenant == tenant {
+			n++
+		}
+	}
+	return n
+}
+
+// Get returns plugin of the tenant by name, plugins being loaded are not returned
+func (m *Manager) Get(tenant, name string) (IPlugin, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.plugins[pluginKey{tenant: tenant, name: name}]
+	if !ok || entry.plugin == nil {
+		return nil, false
+	}
+	entry.activity.touch()
+	return entry.plugin, true
+}
+
+// List returns plugins of the tenant sorted by name, or plugins of all tenants
+// sorted by tenant and name if tenant is empty, including shared plugins whose
+// tenant is empty and name is path
+func (m *Manager) List(tenant string) []ManagedPlugin {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var plugins []ManagedPlugin
+	for key, entry := range m.plugins {
+		if entry.plugin != nil && (tenant == "" || key.tenant == tenant) {
+			plugin := entry.ManagedPlugin
+			plugin.LastUsed, _ = entry.activity.idle()
+			plugins = append(plugins, plugin)
+		}
+	}
+	if tenant == "" {
+		for _, entry := range m.shared {
+			if entry.plugin != nil {
+				plugin := entry.ManagedPlugin
+				plugin.Refs = entry.refs
+				plugins = append(plugins, plugin)
+			}
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool {
+		if plugins[i].Tenant != plugins[j].Tenant {
+			return plugins[i].Tenant < plugins[j].Tenant
+		}
+		return plugins[i].Name < plugins[j].Name
+	})
+	return plugins
+}
+
+// ManagedSnapshot is snapshot of a plugin loaded by Manager
+type ManagedSnapshot struct {
+	Plugin   ManagedPlugin  `json:"plugin"`
+	Snapshot PluginSnapshot `json:"snapshot"`
+}
+
+// Snapshots returns snapshots of plugins of all tenants sorted like List, taking them
+// is not counted as use of plugins for idle eviction, e.g. when monitoring plugins
+func (m *Manager) Snapshots() []ManagedSnapshot {
+	described, plugins := m.loaded()
+	snapshots := make([]ManagedSnapshot, len(plugins))
+	for i, plugin := range plugins {
+		snapshots[i] = ManagedSnapshot{Plugin: described[i], Snapshot: plugin.Snapshot()}
+	}
+	return snapshots
+}
+
+// loaded returns plugins of all tenants sorted like List with their descriptions,
+// without counting as use of plugins
+func (m *Manager) loaded() ([]ManagedPlugin, []IPlugin) {
+	type loadedPlugin struct {
+		ManagedPlugin
+		plugin IPlugin
+	}
+	m.mu.Lock()
+	var entries []loadedPlugin
+	for _, entry := range m.plugins {
+		if entry.plugin != nil {
+			plugin := entry.ManagedPlugin
+			plugin.LastUsed, _ = entry.activity.idle()
+			entries = append(entries, loadedPlugin{ManagedPlugin: plugin, plugin: entry.plugin})
+		}
+	}
+	for _, entry := range m.shared {
+		if entry.plugin != nil {
+			plugin := entry.ManagedPlugin
+			plugin.Refs = entry.refs
+			entries = append(entries, loadedPlugin{ManagedPlugin: plugin, plugin: entry.plugin})
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Tenant != entries[j].Tenant {
+			return entries[i].Tenant < entries[j].Tenant
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	described := make([]ManagedPlugin, len(entries))
+	plugins := make([]IPlugin, len(entries))
+	for i, entry := range entries {
+		described[i], plugins[i] = entry.ManagedPlugin, entry.plugin
+	}
+	return described, plugins
+}
+
+// lookup returns plugin of the tenant by name like Get, or shared plugin by path if tenant
+// is empty, without counting as use of the plugin
+func (m *Manager) lookup(tenant, name string) (ManagedPlugin, IPlugin, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if entry, ok := m.plugins[pluginKey{tenant: tenant, name: name}]; ok && entry.plugin != nil {
+		plugin := entry.ManagedPlugin
+		plugin.LastUsed, _ = entry.activity.idle()
+		return plugin, entry.plugin, true
+	}
+	if entry, ok := m.shared[name]; ok && tenant == "" && entry.plugin != nil {
+		plugin := entry.ManagedPlugin
+		plugin.Refs = entry.refs
+		return plugin, entry.plugin, true
+	}
+	return ManagedPlugin{}, nil, false
+}
+
+// Tenants returns sorted tenants having loaded plugins
+func (m *Manager) Tenants() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seen := make(map[string]bool)
+	var tenants []string
+	for key, entry := range m.plugins {
+		if entry.plugin != nil && !seen[key.tenant] {
+			seen[key.tenant] = true
+			tenants = append(tenants, key.tenant)
+		}
+	}
+	sort.Strings(tenants)
+	return tenants
+}
+
+// Unload quits plugin of the tenant by name and releases its quota
+func (m *Manager) Unload(tenant, name string) error {
+	key := pluginKey{tenant: tenant, name: name}
+	m.mu.Lock()
+	entry, ok := m.plugins[key]
+	if !ok || entry.plugin == nil {
+		m.mu.Unlock()
+		return fmt.Errorf("unload plugin %s of tenant %s: %w", name, tenant, ErrPluginNotFound)
+	}
+	delete(m.plugins, key)
+	m.mu.Unlock()
+	return quitManaged(entry)
+}
+
+// ShutdownTenant quits all plugins of the tenant concurrently, e.g. when a project is removed
+func (m *Manager) ShutdownTenant(tenant string) error {
+	return m.remove(func(key pluginKey) bool { return key.tenant == tenant })
+}
+
+// Shutdown quits all plugins concurrently, including shared plugins still held, and
+// rejects further loads, e.g. when server embedding the manager stops. Errors of
+// quitting plugins are reported together.
+func (m *Manager) Shutdown() error {
+	m.mu.Lock()
+	if !m.closed && m.stopJanitor != nil {
+		close(m.stopJanitor)
+	}
+	m.closed = true
+	var entries []*managedPlugin
+	for key, entry := range m.plugins {
+		if entry.plugin != nil {
+			entries = append(entries, entry)
+			delete(m.plugins, key)
+		}
+	}
+	for key, entry := range m.shared {
+		if entry.plugin != nil {
+			entries = append(entries, &entry.managedPlugin)
+			delete(m.shared, key)
+		}
+	}
+	m.mu.Unlock()
+	return quitAll(entries)
+}
+
+// remove quits loaded plugins matched concurrently, plugins being loaded are
+// quit by Load if manager is shut down
+func (m *Manager) remove(match func(pluginKey) bool) error {
+	m.mu.Lock()
+	var entries []*managedPlugin
+	for key, entry := range m.plugins {
+		if entry.plugin != nil && match(key) {
+			entries = append(entries, entry)
+			delete(m.plugins, key)
+		}
+	}
+	m.mu.Unlock()
+	return quitAll(entries)
+}
+
+// quitAll quits plugins concurrently and reports errors together
+func quitAll(entries []*managedPlugin) error {
+	errs := make([]error, len(entries))
+	var wg sync.WaitGroup
+	for i, entry := range entries {
+		wg.Add(1)
+		go func(i int, entry *managedPlugin) {
+			defer wg.Done()
+			errs[i] = quitManaged(entry)
+		}(i, entry)
+	}
+	wg.Wait()
+
+	var messages []string
+	for _, err := range errs {
+		if err != nil {
+			messages = append(messages, err.Error())
+		}
+	}
+	if len(messages) > 0 {
+		sort.Strings(messages)
+		return fmt.Errorf("quit %d plugins failed: %s", len(messages), strings.Join(messages, "; "))
+	}
+	return nil
+}
+
+func quitManaged(entry *managedPlugin) error {
+	logger.Info("plugin unloaded by manager", "tenant", entry.Tenant, "name", entry.Name)
+	err := entry.plugin.Quit()
+	detachCgroup(entry)
+	if err != nil {
+		return fmt.Errorf("quit plugin %s of tenant %s: %w", entry.Name, entry.Tenant, err)
+	}
+	return nil
+}
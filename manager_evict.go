@@ -0,0 +1,169 @@
+package funplugin
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// reasons of EvictionEvent
+const (
+	EvictLRU = "lru" // least recently used plugin evicted for loading another one
+	EvictTTL = "ttl" // plugin idle longer than idle TTL
+)
+
+// ErrManagerFull is returned by Manager.Load when manager holds WithMaxPlugins plugins
+// and none of them is idle to be evicted
+var ErrManagerFull = errors.New("plugin manager is full")
+
+// EvictionEvent is passed to eviction hooks after evicted plugin quits
+type EvictionEvent struct {
+	Plugin ManagedPlugin
+	Reason string        // EvictLRU or EvictTTL
+	Idle   time.Duration // time since plugin was last used
+	Err    error         // error of quitting plugin
+}
+
+// WithMaxPlugins bounds plugins loaded by Load of all tenants, loading another plugin
+// evicts the least recently used one not running calls. Shared plugins of Acquire are
+// released by their holders and not evicted.
+func WithMaxPlugins(maxPlugins int) ManagerOption {
+	return func(m *Manager) {
+		m.maxPlugins = maxPlugins
+	}
+}
+
+// WithIdleTTL evicts plugins loaded by Load and not used for ttl, checked in background
+// every ttl/2 until Manager shuts down
+func WithIdleTTL(ttl time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.idleTTL = ttl
+	}
+}
+
+// WithEvictionHook adds hook called after each evicted plugin quits, e.g. for logging
+// and metrics. Hooks are called in order without holding manager lock.
+func WithEvictionHook(hook func(EvictionEvent)) ManagerOption {
+	return func(m *Manager) {
+		m.evictionHooks = append(m.evictionHooks, hook)
+	}
+}
+
+// pluginActivity tracks calls of managed plugin, plugins are used when called
+// or looked up by Get, and not evicted while running calls
+type pluginActivity struct {
+	mu       sync.Mutex
+	inflight int
+	lastUsed time.Time
+}
+
+func newPluginActivity() *pluginActivity {
+	return &pluginActivity{lastUsed: time.Now()}
+}
+
+// withActivity tracks calls of plugin for Manager eviction
+func withActivity(activity *pluginActivity) Option {
+	return func(o *pluginOption) {
+		o.activity = activity
+	}
+}
+
+func (a *pluginActivity) touch() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lastUsed = time.Now()
+}
+
+// idle returns time plugin was last used, and whether it is not running calls
+func (a *pluginActivity) idle() (time.Time, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.lastUsed, a.inflight == 0
+}
+
+func (a *pluginActivity) interceptor() callInterceptor {
+	return func(_ context.Context, next callHandler) callHandler {
+		return func(funcName string, args ...interface{}) (interface{}, error) {
+			a.mu.Lock()
+			a.inflight++
+			a.mu.Unlock()
+			defer func() {
+				a.mu.Lock()
+				a.inflight--
+				a.lastUsed = time.Now()
+				a.mu.Unlock()
+			}()
+			return next(funcName, args...)
+		}
+	}
+}
+
+// evictLRU removes the least recently used idle plugin, called with lock held
+// when loading a plugin beyond WithMaxPlugins
+func (m *Manager) evictLRU() (*managedPlugin, bool) {
+	var victim *managedPlugin
+	var victimUsed time.Time
+	for _, entry := range m.plugins {
+		if entry.plugin == nil {
+			continue
+		}
+		lastUsed, idle := entry.activity.idle()
+		if idle && (victim == nil || lastUsed.Before(victimUsed)) {
+			victim, victimUsed = entry, lastUsed
+		}
+	}
+	if victim == nil {
+		return nil, false
+	}
+	delete(m.plugins, pluginKey{tenant: victim.Tenant, name: victim.Name})
+	return victim, true
+}
+
+// evictIdle quits plugins idle longer than idle TTL
+func (m *Manager) evictIdle() {
+	now := time.Now()
+	m.mu.Lock()
+	var victims []*managedPlugin
+	for key, entry := range m.plugins {
+		if entry.plugin == nil {
+			continue
+		}
+		if lastUsed, idle := entry.activity.idle(); idle && now.Sub(lastUsed) >= m.idleTTL {
+			victims = append(victims, entry)
+			delete(m.plugins, key)
+		}
+	}
+	m.mu.Unlock()
+	for _, victim := range victims {
+		m.evict(victim, EvictTTL)
+	}
+}
+
+// evict quits evicted plugin and calls eviction hooks
+func (m *Manager) evict(entry *managedPlugin, reason string) {
+	lastUsed, _ := entry.activity.idle()
+	event := EvictionEvent{Plugin: entry.ManagedPlugin, Reason: reason, Idle: time.Since(lastUsed)}
+	event.Plugin.LastUsed = lastUsed
+	logger.Info("plugin evicted by manager", "tenant", entry.Tenant, "name", entry.Name,
+		"reason", reason, "idle", event.Idle)
+	event.Err = entry.plugin.Quit()
+	detachCgroup(entry)
+	for _, hook := range m.evictionHooks {
+		hook(event)
+	}
+}
+
+// runJanitor evicts idle plugins periodically until stop is closed
+func (m *Manager) runJanitor(stop <-chan struct{}) {
+	ticker := time.NewTicker(m.idleTTL / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			m.evictIdle()
+		}
+	}
+}
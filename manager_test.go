@@ -0,0 +1,249 @@
+package funplugin
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	m := NewManager(
+		WithDefaultQuota(Quota{MaxPlugins: 2}),
+		WithTenantQuota("project-b", Quota{MaxPlugins: 1, MaxConcurrency: 1}),
+	)
+	defer m.Shutdown()
+
+	plugin, err := m.Load("project-a", "debugtalk", pluginBinPath)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	assertPlugin(t, plugin)
+	_, err = m.Load("project-a", "debugtalk", "lua/examples/debugtalk.lua")
+	assert.True(t, errors.Is(err, ErrPluginExists))
+	_, err = m.Load("project-a", "lua", "lua/examples/debugtalk.lua")
+	assert.Nil(t, err)
+	_, err = m.Load("project-a", "star", "starlark/examples/debugtalk.star")
+	var quotaErr *QuotaError
+	if assert.ErrorAs(t, err, &quotaErr) {
+		assert.Equal(t, &QuotaError{Tenant: "project-a", MaxPlugins: 2}, quotaErr)
+	}
+
+	// failed load releases the name and quota
+	_, err = m.Load("project-b", "debugtalk", "not_found.bin")
+	assert.NotNil(t, err)
+	plugin, err = m.Load("project-b", "debugtalk", "lua/examples/debugtalk.lua")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	// call limits of tenant quota are applied
+	assert.NotNil(t, plugin.(*interceptedPlugin).queue)
+
+	got, ok := m.Get("project-b", "debugtalk")
+	assert.True(t, ok)
+	assert.Same(t, plugin, got)
+	_, ok = m.Get("project-b", "lua")
+	assert.False(t, ok)
+
+	assert.Equal(t, []string{"project-a", "project-b"}, m.Tenants())
+	plugins := m.List("")
+	if assert.Len(t, plugins, 3) {
+		assert.Equal(t, "project-a", plugins[0].Tenant)
+		assert.Equal(t, "debugtalk", plugins[0].Name)
+		assert.Equal(t, "hashicorp-grpc-go", plugins[0].Type)
+		assert.Equal(t, "lua", plugins[1].Name)
+		assert.Equal(t, "project-b", plugins[2].Tenant)
+		assert.False(t, plugins[2].Loaded.IsZero())
+	}
+	assert.Len(t, m.List("project-b"), 1)
+
+	// snapshots are not counted as use of plugins
+	snapshots := m.Snapshots()
+	if assert.Len(t, snapshots, 3) {
+		assert.Equal(t, plugins[1], snapshots[1].Plugin)
+		assert.Equal(t, "lua-plugin", snapshots[1].Snapshot.Type)
+		assert.Equal(t, plugins[1].LastUsed, m.List("project-a")[1].LastUsed)
+	}
+
+	assert.Nil(t, m.Unload("project-a", "lua"))
+	assert.True(t, errors.Is(m.Unload("project-a", "lua"), ErrPluginNotFound))
+	_, err = m.Load("project-a", "star", "starlark/examples/debugtalk.star")
+	assert.Nil(t, err)
+
+	assert.Nil(t, m.ShutdownTenant("project-b"))
+	assert.Equal(t, []string{"project-a"}, m.Tenants())
+
+	m.Shutdown()
+	assert.Empty(t, m.List(""))
+	_, err = m.Load("project-a", "lua", "lua/examples/debugtalk.lua")
+	assert.Equal(t, ErrManagerClosed, err)
+}
+
+func TestManagerConcurrentLoad(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "plugins.log")
+	m := NewManager()
+	defer m.Shutdown()
+
+	var wg sync.WaitGroup
+	errs := make([]error, 32)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = m.Load("project-a", fmt.Sprintf("lua-%d", i), "lua/examples/debugtalk.lua",
+				WithLogFile(logFile))
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		assert.Nil(t, err)
+	}
+	assert.Len(t, m.List("project-a"), len(errs))
+
+	// log file is kept open for other plugins
+	assert.Nil(t, m.Unload("project-a", "lua-0"))
+	logger.Info("log after unloading a plugin")
+	assert.Nil(t, m.Shutdown())
+	content, err := os.ReadFile(logFile)
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "log after unloading a plugin")
+}
+
+func TestManagerAcquire(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	m := NewManager()
+	defer m.Shutdown()
+
+	// concurrent holders share one plugin process
+	const holders = 5
+	plugins := make([]IPlugin, holders)
+	errs := make([]error, holders)
+	var wg sync.WaitGroup
+	for i := 0; i < holders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			plugins[i], errs[i] = m.Acquire(pluginBinPath)
+		}(i)
+	}
+	wg.Wait()
+	for i := 0; i < holders; i++ {
+		if !assert.Nil(t, errs[i]) {
+			t.FailNow()
+		}
+		assert.Same(t, plugins[0].(*sharedHandle).IPlugin, plugins[i].(*sharedHandle).IPlugin)
+	}
+	assertPlugin(t, plugins[0])
+	names, err := plugins[0].(IFuncLister).GetNames()
+	assert.Nil(t, err)
+	assert.Contains(t, names, "sum_ints")
+
+	listed := m.List("")
+	if assert.Len(t, listed, 1) {
+		assert.Equal(t, holders, listed[0].Refs)
+		assert.Equal(t, pluginBinPath, listed[0].Path)
+	}
+
+	// plugin keeps running until the last holder releases it, releasing twice is a no-op
+	for i := 0; i < holders-1; i++ {
+		assert.Nil(t, plugins[i].Quit())
+		assert.Nil(t, plugins[i].Quit())
+	}
+	result, err := plugins[holders-1].Call("sum_two_int", 1, 2)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 3, result)
+	assert.Equal(t, 1, m.List("")[0].Refs)
+	plugins[holders-1].Quit()
+	assert.Empty(t, m.List(""))
+
+	// plugin is initialized again after released
+	plugin, err := m.Acquire(pluginBinPath)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	assert.NotSame(t, plugins[0].(*sharedHandle).IPlugin, plugin.(*sharedHandle).IPlugin)
+	_, err = m.Acquire("not_found.bin")
+	assert.NotNil(t, err)
+
+	// shutdown quits shared plugins still held
+	m.Shutdown()
+	assert.Empty(t, m.List(""))
+	assert.Nil(t, plugin.Quit())
+	_, err = m.Acquire(pluginBinPath)
+	assert.Equal(t, ErrManagerClosed, err)
+}
+
+func TestManagerEviction(t *testing.T) {
+	var mu sync.Mutex
+	var events []EvictionEvent
+	m := NewManager(WithMaxPlugins(2), WithEvictionHook(func(event EvictionEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}))
+	defer m.Shutdown()
+
+	_, err := m.Load("project-a", "lua", "lua/examples/debugtalk.lua")
+	assert.Nil(t, err)
+	star, err := m.Load("project-b", "star", "starlark/examples/debugtalk.star")
+	assert.Nil(t, err)
+	time.Sleep(10 * time.Millisecond)
+	// calls and lookups are uses of plugin
+	_, err = star.Call("sum_two_int", 1, 2)
+	assert.Nil(t, err)
+	_, err = m.Load("project-a", "lua2", "lua/examples/debugtalk.lua")
+	assert.Nil(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "lua", events[0].Plugin.Name)
+		assert.Equal(t, "project-a", events[0].Plugin.Tenant)
+		assert.Equal(t, EvictLRU, events[0].Reason)
+		assert.Nil(t, events[0].Err)
+	}
+	_, ok := m.Get("project-a", "lua")
+	assert.False(t, ok)
+
+	// plugins running calls are not evicted
+	m.mu.Lock()
+	for _, entry := range m.plugins {
+		entry.activity.inflight++
+	}
+	m.mu.Unlock()
+	_, err = m.Load("project-a", "lua3", "lua/examples/debugtalk.lua")
+	assert.True(t, errors.Is(err, ErrManagerFull))
+	assert.Len(t, m.List(""), 2)
+	m.mu.Lock()
+	for _, entry := range m.plugins {
+		entry.activity.inflight--
+	}
+	m.mu.Unlock()
+}
+
+func TestManagerIdleTTL(t *testing.T) {
+	evicted := make(chan EvictionEvent, 1)
+	m := NewManager(WithIdleTTL(200*time.Millisecond), WithEvictionHook(func(event EvictionEvent) {
+		evicted <- event
+	}))
+	defer m.Shutdown()
+
+	_, err := m.Load("project-a", "lua", "lua/examples/debugtalk.lua")
+	assert.Nil(t, err)
+	select {
+	case event := <-evicted:
+		assert.Equal(t, "lua", event.Plugin.Name)
+		assert.Equal(t, EvictTTL, event.Reason)
+		assert.GreaterOrEqual(t, event.Idle, 200*time.Millisecond)
+	case <-time.After(3 * time.Second):
+		t.Fatal("idle plugin is not evicted")
+	}
+	assert.Empty(t, m.List(""))
+}
@@ -0,0 +1,36 @@
+package funplugin
+
+import (
+	"context"
+	"strings"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+// CallWithMetadata calls plugin function with per-call metadata, e.g. test case id, correlation
+// id and user, which plugin functions read for logging correlation with the host's run.
+// Metadata keys are case-insensitive and lowercased. Metadata is transferred as gRPC metadata,
+// and ignored by plugins not called over gRPC, e.g. in-process and stdio transport plugins.
+func (p *interceptedPlugin) CallWithMetadata(md map[string]string, funcName string, args ...interface{}) (interface{}, error) {
+	if len(md) == 0 {
+		return p.Call(funcName, args...)
+	}
+	lowered := make(map[string]string, len(md))
+	for key, value := range md {
+		key = strings.ToLower(key)
+		if err := fungo.ValidateMetadataKey(key); err != nil {
+			return nil, err
+		}
+		lowered[key] = value
+	}
+
+	caller, ok := p.pluginBackend.(fungo.IMetadataCaller)
+	if !ok {
+		logger.Debug("plugin does not support call metadata, ignored", "type", p.Type(), "funcName", funcName)
+		return p.Call(funcName, args...)
+	}
+	call := func(funcName string, args ...interface{}) (interface{}, error) {
+		return caller.CallWithMetadata(lowered, funcName, args...)
+	}
+	return p.intercept(context.Background(), call)(funcName, args...)
+}
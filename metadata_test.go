@@ -0,0 +1,82 @@
+package funplugin
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+func assertCallMetadata(t *testing.T, plugin IPlugin) {
+	md := map[string]string{"Case-ID": "TC-1024", "user": "张三"}
+	value, err := plugin.CallWithMetadata(md, "call_metadata", "case-id")
+	assert.Nil(t, err)
+	assert.Equal(t, "TC-1024", value)
+	value, err = plugin.CallWithMetadata(md, "call_metadata", "user")
+	assert.Nil(t, err)
+	assert.Equal(t, "张三", value)
+
+	// metadata is attached per call
+	_, err = plugin.Call("call_metadata", "user")
+	assert.ErrorContains(t, err, "call metadata user not found")
+}
+
+func TestCallWithMetadata(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	plugin, err := Init(pluginBinPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	assertCallMetadata(t, plugin)
+	// host side interceptors apply to calls with metadata
+	funcs := plugin.Stats().Funcs
+	assert.Len(t, funcs, 1)
+	assert.Equal(t, int64(3), funcs[0].Calls)
+
+	_, err = plugin.CallWithMetadata(map[string]string{"case id": "TC-1"}, "call_metadata", "case id")
+	assert.EqualError(t, err, `invalid call metadata key "case id", it should consist of `+
+		`lowercase letters, digits, '-', '_' and '.'`)
+}
+
+func TestCallWithMetadataSidecar(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	addr := fmt.Sprintf("127.0.0.1:%d", freePort(t))
+	cmd := exec.Command(pluginBinPath)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%s", fungo.SidecarAddrEnvName, addr))
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cmd.Process.Signal(os.Interrupt)
+		cmd.Wait()
+	}()
+
+	plugin, err := Connect(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+	assertCallMetadata(t, plugin)
+}
+
+func TestCallWithMetadataIgnored(t *testing.T) {
+	plugin, err := Init("lua/examples/debugtalk.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	result, err := plugin.CallWithMetadata(map[string]string{"case-id": "TC-1"}, "sum_ints", 1, 2)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 3, result)
+}
@@ -0,0 +1,202 @@
+// Package monitor shows live activity of plugins in a terminal UI, i.e. plugin processes,
+// call throughput, latencies and recent errors, for operators watching long soak tests.
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/lingcetech/funplugin"
+)
+
+// maxErrors bounds recent errors shown across plugins
+const maxErrors = 10
+
+// Source returns snapshots of plugins to show, it is called on every refresh
+type Source func() []funplugin.ManagedSnapshot
+
+// ManagerSource shows plugins loaded by manager, watching them does not keep
+// idle plugins from being evicted
+func ManagerSource(manager *funplugin.Manager) Source {
+	return manager.Snapshots
+}
+
+// Plugins shows plugins initialized by host, named by their paths
+func Plugins(plugins ...funplugin.IPlugin) Source {
+	return func() []funplugin.ManagedSnapshot {
+		snapshots := make([]funplugin.ManagedSnapshot, 0, len(plugins))
+		for _, plugin := range plugins {
+			snapshot := plugin.Snapshot()
+			snapshots = append(snapshots, funplugin.ManagedSnapshot{
+				Plugin:   funplugin.ManagedPlugin{Name: snapshot.Path, Path: snapshot.Path, Type: snapshot.Type},
+				Snapshot: snapshot,
+			})
+		}
+		return snapshots
+	}
+}
+
+// Run shows plugins of source in terminal UI refreshed every interval, default 1s,
+// until q or ctrl+c is pressed or ctx is done
+func Run(ctx context.Context, source Source, interval time.Duration) error {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	_, err := tea.NewProgram(newModel(source, interval), tea.WithContext(ctx), tea.WithAltScreen()).Run()
+	if err == tea.ErrProgramKilled && ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// refreshMsg triggers refresh of plugin snapshots
+type refreshMsg time.Time
+
+// model is state of monitor UI
+type model struct {
+	source    Source
+	interval  time.Duration
+	refreshed time.Time
+	snapshots []funplugin.ManagedSnapshot
+	calls     map[string]int64   // calls keyed by plugin function at last refresh, and plugins seen
+	rates     map[string]float64 // calls per second since previous refresh
+}
+
+func newModel(source Source, interval time.Duration) *model {
+	return &model{source: source, interval: interval}
+}
+
+func (m *model) Init() tea.Cmd {
+	return func() tea.Msg {
+		return refreshMsg(time.Now())
+	}
+}
+
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c", "esc":
+			return m, tea.Quit
+		}
+	case refreshMsg:
+		m.refresh(time.Time(msg))
+		return m, tea.Tick(m.interval, func(t time.Time) tea.Msg {
+			return refreshMsg(t)
+		})
+	}
+	return m, nil
+}
+
+// refresh takes snapshots of plugins and calculates call rates since previous refresh
+func (m *model) refresh(now time.Time) {
+	elapsed := now.Sub(m.refreshed).Seconds()
+	calls := make(map[string]int64)
+	rates := make(map[string]float64)
+	m.snapshots = m.source()
+	for _, snapshot := range m.snapshots {
+		name := label(snapshot.Plugin)
+		// functions called first since previous refresh count from zero, while calls
+		// of plugins appearing since previous refresh are not counted
+		_, seen := m.calls[name]
+		calls[name] = 0
+		for _, f := range snapshot.Snapshot.Stats.Funcs {
+			key := name + "\x00" + f.Name
+			calls[key] = f.Calls
+			if seen && elapsed > 0 {
+				rates[key] = float64(f.Calls-m.calls[key]) / elapsed
+			}
+		}
+	}
+	m.refreshed, m.calls, m.rates = now, calls, rates
+}
+
+// label returns name of plugin shown, prefixed with its tenant
+func label(plugin funplugin.ManagedPlugin) string {
+	if plugin.Tenant == "" {
+		return plugin.Name
+	}
+	return plugin.Tenant + "/" + plugin.Name
+}
+
+func (m *model) View() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "funplugin monitor  %s  refresh every %v  press q to quit\n\n",
+		m.refreshed.Format("15:04:05"), m.interval)
+
+	var errors []errorRow
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PLUGIN\tTYPE\tPROCESS\tUPTIME\tCALLS\tCALLS/S\tERRORS\t")
+	for _, snapshot := range m.snapshots {
+		name := label(snapshot.Plugin)
+		var calls, failures int64
+		var rate float64
+		for _, f := range snapshot.Snapshot.Stats.Funcs {
+			calls += f.Calls
+			failures += f.Errors
+			rate += m.rates[name+"\x00"+f.Name]
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%v\t%d\t%.1f\t%d\t\n", name, snapshot.Snapshot.Type,
+			process(snapshot.Snapshot.Process), snapshot.Snapshot.Uptime.Round(time.Second),
+			calls, rate, failures)
+		for _, e := range snapshot.Snapshot.RecentErrors {
+			errors = append(errors, errorRow{plugin: name, CallError: e})
+		}
+	}
+	w.Flush()
+
+	b.WriteString("\n")
+	w = tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PLUGIN\tFUNCTION\tCALLS\tCALLS/S\tERRORS\tP50\tP95\t")
+	for _, snapshot := range m.snapshots {
+		name := label(snapshot.Plugin)
+		for _, f := range snapshot.Snapshot.Stats.Funcs {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%.1f\t%d\t%v\t%v\t\n", name, f.Name, f.Calls,
+				m.rates[name+"\x00"+f.Name], f.Errors,
+				f.P50.Round(time.Microsecond), f.P95.Round(time.Microsecond))
+		}
+	}
+	w.Flush()
+
+	b.WriteString("\nRECENT ERRORS\n")
+	sort.SliceStable(errors, func(i, j int) bool {
+		return errors[i].Time.After(errors[j].Time)
+	})
+	if len(errors) > maxErrors {
+		errors = errors[:maxErrors]
+	}
+	w = tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	for _, e := range errors {
+		message := strings.SplitN(e.Error, "\n", 2)[0]
+		if len(message) > 120 {
+			message = message[:120] + "..."
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.Time.Format("15:04:05"), e.plugin, e.Function, message)
+	}
+	w.Flush()
+	return b.String()
+}
+
+// errorRow is a recent error of a plugin
+type errorRow struct {
+	funplugin.CallError
+	plugin string
+}
+
+// process returns state of plugin process
+func process(info *funplugin.ProcessInfo) string {
+	switch {
+	case info == nil:
+		return "-"
+	case info.Running:
+		return fmt.Sprintf("pid %d", info.Pid)
+	default:
+		return fmt.Sprintf("pid %d exited", info.Pid)
+	}
+}
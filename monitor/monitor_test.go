@@ -0,0 +1,54 @@
+package monitor
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lingcetech/funplugin"
+)
+
+func TestMonitor(t *testing.T) {
+	plugin, err := funplugin.Init("../lua/examples/debugtalk.lua")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	defer plugin.Quit()
+
+	m := newModel(Plugins(plugin), time.Second)
+	start := time.Now()
+	_, cmd := m.Update(refreshMsg(start))
+	assert.NotNil(t, cmd)
+	for i := 0; i < 4; i++ {
+		_, err = plugin.Call("sum_ints", 1, 2)
+		assert.Nil(t, err)
+	}
+	_, err = plugin.Call("sum_ints", "a")
+	assert.NotNil(t, err)
+	m.Update(refreshMsg(start.Add(2 * time.Second)))
+
+	view := m.View()
+	lines := strings.Split(view, "\n")
+	var pluginLine, funcLine string
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "../lua/examples/debugtalk.lua  lua-plugin"):
+			pluginLine = line
+		case strings.HasPrefix(line, "../lua/examples/debugtalk.lua  sum_ints"):
+			funcLine = line
+		}
+	}
+	// throughput is calls since previous refresh per second
+	assert.Equal(t, []string{"../lua/examples/debugtalk.lua", "lua-plugin", "-", "0s", "5", "2.5", "1"},
+		strings.Fields(pluginLine))
+	assert.Equal(t, []string{"5", "2.5", "1"}, strings.Fields(funcLine)[2:5])
+	assert.Contains(t, view[strings.Index(view, "RECENT ERRORS"):], "sum_ints")
+
+	_, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+	if assert.NotNil(t, cmd) {
+		assert.Equal(t, tea.Quit(), cmd())
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"runtime"
 	"sort"
 	"strings"
 
@@ -18,13 +17,20 @@ import (
 )
 
 var (
-	logger         = fungo.Logger
+	logger = fungo.Logger
+	// PYPI_INDEX_URL is env PYPI_INDEX_URL at program start, env is resolved at call time
+	// unless it is assigned in code, use WithIndexURL to override it per call
 	PYPI_INDEX_URL = os.Getenv("PYPI_INDEX_URL")
-	PATH           = os.Getenv("PATH")
+	// PATH is env PATH at program start.
+	// Deprecated: env PATH is resolved at call time, PATH is no longer used.
+	PATH = os.Getenv("PATH")
 )
 
 var python3Executable string = "python3" // system default python3
 
+// packageVersionScript prints version of installed python distribution
+const packageVersionScript = "import sys; from importlib.metadata import version; print(version(sys.argv[1]))"
+
 func isPython3(python string) bool {
 	out, err := Command(python, "--version").Output()
 	if err != nil {
@@ -36,43 +42,69 @@ func isPython3(python string) bool {
 	return false
 }
 
+// defaultVenvDir returns managed venv directory $HOME/.yf/venv
+func defaultVenvDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.Wrap(err, "get user home dir failed")
+	}
+	return filepath.Join(home, ".yf", "venv"), nil
+}
+
 // EnsurePython3Venv ensures python3 venv with specified packages
 // venv should be directory path of target venv
 func EnsurePython3Venv(venv string, packages ...string) (python3 string, err error) {
-	// priority: specified > $HOME/.hrp/venv
+	return NewExecutor().EnsurePython3Venv(venv, packages...)
+}
+
+// EnsurePython3Venv ensures python3 venv with specified packages
+// venv should be directory path of target venv
+func (e *Executor) EnsurePython3Venv(venv string, packages ...string) (python3 string, err error) {
+	// priority: specified > $HOME/.yf/venv
 	if venv == "" {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return "", errors.Wrap(err, "get user home dir failed")
+		if venv, err = defaultVenvDir(); err != nil {
+			return "", err
 		}
-		venv = filepath.Join(home, ".yf", "venv")
 	}
-	python3, err = ensurePython3Venv(venv, packages...)
+	python3, err = e.ensurePython3Venv(venv, packages...)
 	if err != nil {
 		return "", err
 	}
+	if len(packages) > 0 {
+		e.warnDepsConflicts(python3)
+	}
+	if err := e.auditVenv(python3); err != nil {
+		return "", err
+	}
 	python3Executable = python3
-	logger.Info("set python3 executable path",
+	e.logger.Info("set python3 executable path",
 		"Python3Executable", python3Executable)
 	return python3, nil
 }
 
 func ExecPython3Command(cmdName string, args ...string) error {
+	return NewExecutor().ExecPython3Command(cmdName, args...)
+}
+
+func (e *Executor) ExecPython3Command(cmdName string, args ...string) error {
 	args = append([]string{"-m", cmdName}, args...)
-	return RunCommand(python3Executable, args...)
+	return e.RunCommand(python3Executable, args...)
 }
 
 func AssertPythonPackage(python3 string, pkgName, pkgVersion string) error {
-	out, err := Command(
-		python3, "-c", fmt.Sprintf("\"import %s; print(%s.__version__)\"", pkgName, pkgName),
-	).Output()
+	return NewExecutor().AssertPythonPackage(python3, pkgName, pkgVersion)
+}
+
+func (e *Executor) AssertPythonPackage(python3 string, pkgName, pkgVersion string) error {
+	// package name is passed as argument instead of being quoted into the script
+	out, err := Command(python3, "-c", packageVersionScript, pkgName).Output()
 	if err != nil {
 		return fmt.Errorf("python package %s not found", pkgName)
 	}
 
 	// do not check version if pkgVersion is empty
 	if pkgVersion == "" {
-		logger.Info("python package is ready", "name", pkgName)
+		e.logger.Info("python package is ready", "name", pkgName)
 		return nil
 	}
 
@@ -83,78 +115,45 @@ func AssertPythonPackage(python3 string, pkgName, pkgVersion string) error {
 			pkgName, version, pkgVersion)
 	}
 
-	logger.Info("python package is ready", "name", pkgName, "version", pkgVersion)
+	e.logger.Info("python package is ready", "name", pkgName, "version", pkgVersion)
 	return nil
 }
 
 func InstallPythonPackage(python3 string, pkg string) (err error) {
-	var pkgName, pkgVersion string
-	if strings.Contains(pkg, "==") {
-		// specify package version
-		// funppy==0.5.0
-		pkgInfo := strings.Split(pkg, "==")
-		pkgName = pkgInfo[0]
-		pkgVersion = pkgInfo[1]
-	} else {
-		// package version not specified, install the latest by default
-		// funppy
-		pkgName = pkg
-	}
-
-	// check if package installed and version matched
-	err = AssertPythonPackage(python3, pkgName, pkgVersion)
-	if err == nil {
-		return nil
-	}
+	return NewExecutor().InstallPythonPackage(python3, pkg)
+}
 
-	// check if pip available
-	err = RunCommand(python3, "-m", "pip", "--version")
+func (e *Executor) InstallPythonPackage(python3 string, pkg string) (err error) {
+	spec, err := ParsePackageSpec(pkg)
 	if err != nil {
-		logger.Warn("pip is not available")
-		return errors.Wrap(err, "pip is not available")
-	}
-
-	logger.Info("installing python package", "pkgName",
-		pkgName, "pkgVersion", pkgVersion)
-
-	// install package
-	pypiIndexURL := PYPI_INDEX_URL
-	if pypiIndexURL == "" {
-		pypiIndexURL = "https://pypi.org/simple" // default
-	}
-	err = RunCommand(python3, "-m", "pip", "install", pkg, "--upgrade",
-		"--index-url", pypiIndexURL,
-		"--quiet", "--disable-pip-version-check")
-	if err != nil {
-		return errors.Wrap(err, "pip install package failed")
+		return err
 	}
-
-	return AssertPythonPackage(python3, pkgName, pkgVersion)
+	return e.InstallPythonPackageSpec(python3, spec)
 }
 
 func RunShell(shellString string) (exitCode int, err error) {
-	cmd := initShellExec(shellString)
-	logger.Info("exec shell string", "content", cmd.String())
+	return NewExecutor().RunShell(shellString)
+}
 
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+func (e *Executor) RunShell(shellString string) (exitCode int, err error) {
+	cmd := e.shellCommand(shellString)
+	e.setNetworkEnv(cmd)
+	e.logger.Info("exec shell string", "shell", cmd.Args[0], "content", shellString)
 
-	err = cmd.Start()
-	if err != nil {
-		return 1, errors.Wrap(err, "start running command failed")
-	}
+	cmd.Stdout = e.stdout
+	cmd.Stderr = e.stderr
 
 	// wait command done and get exit code
-	err = cmd.Wait()
+	err = e.run(cmd)
 	if err != nil {
-		exitErr, ok := err.(*exec.ExitError)
+		exitErr, ok := errors.Cause(err).(*exec.ExitError)
 		if !ok {
 			return 1, errors.Wrap(err, "get command exit code failed")
 		}
 
 		// got failed command exit code
 		exitCode := exitErr.ExitCode()
-		logger.Error("exec command failed", "exitCode", exitCode, "error", err)
+		e.logger.Error("exec command failed", "exitCode", exitCode, "error", err)
 		return exitCode, err
 	}
 
@@ -162,38 +161,48 @@ func RunShell(shellString string) (exitCode int, err error) {
 }
 
 func RunCommand(cmdName string, args ...string) error {
-	cmd := Command(cmdName, args...)
-	logger.Info("run command", "cmd", cmd.String())
+	return NewExecutor().RunCommand(cmdName, args...)
+}
+
+// RunCommand runs command with arguments passed as is without shell,
+// thus paths containing spaces or special characters need no quoting
+func (e *Executor) RunCommand(cmdName string, args ...string) error {
+	cmd := e.command(cmdName, args...)
+	e.logger.Info("run command", "cmd", cmd.String())
 
 	// add cmd dir path to $PATH
 	if cmdDir := filepath.Dir(cmdName); cmdDir != "" {
-		var path string
-		if runtime.GOOS == "windows" {
-			path = fmt.Sprintf("%s;%s", cmdDir, PATH)
-		} else {
-			path = fmt.Sprintf("%s:%s", cmdDir, PATH)
-		}
-		if err := os.Setenv("PATH", path); err != nil {
-			logger.Error("set env $PATH failed", "error", err)
+		if err := os.Setenv("PATH", prependPath(os.Getenv("PATH"), cmdDir)); err != nil {
+			e.logger.Error("set env $PATH failed", "error", err)
 			return err
 		}
 	}
 
-	_, err := RunShell(cmd.String())
-	return err
+	cmd.Stdout = e.stdout
+	cmd.Stderr = e.stderr
+	if err := e.run(cmd); err != nil {
+		e.logger.Error("exec command failed", "cmd", cmd.String(), "error", err)
+		return err
+	}
+	return nil
 }
 
 func ExecCommandInDir(cmd *exec.Cmd, dir string) error {
-	logger.Info("exec command", "cmd", cmd.String(), "dir", dir)
+	return NewExecutor().ExecCommandInDir(cmd, dir)
+}
+
+func (e *Executor) ExecCommandInDir(cmd *exec.Cmd, dir string) error {
+	e.logger.Info("exec command", "cmd", cmd.String(), "dir", dir)
 	cmd.Dir = dir
+	e.setNetworkEnv(cmd)
 
 	// print stderr output
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
-	if err := cmd.Run(); err != nil {
+	if err := e.run(cmd); err != nil {
 		stderrStr := stderr.String()
-		logger.Error("exec command failed",
+		e.logger.Error("exec command failed",
 			"error", err, "stderr", stderrStr)
 		if stderrStr != "" {
 			err = errors.Wrap(err, stderrStr)
@@ -205,142 +214,241 @@ func ExecCommandInDir(cmd *exec.Cmd, dir string) error {
 }
 
 func UninstallPythonPackage(python3 string, pkg string) (err error) {
-	// 提取包名（忽略版本信息，卸载只需要包名）
-	pkgName := pkg
-	if strings.Contains(pkg, "==") {
-		// 如果包含版本号，只取包名部分
-		pkgInfo := strings.Split(pkg, "==")
-		pkgName = pkgInfo[0]
+	return NewExecutor().UninstallPythonPackage(python3, pkg)
+}
+
+func (e *Executor) UninstallPythonPackage(python3 string, pkg string) (err error) {
+	// 提取包名（忽略extras、版本及marker信息，卸载只需要包名）
+	spec, err := ParsePackageSpec(pkg)
+	if err != nil {
+		return err
 	}
+	pkgName := spec.Name
 
 	// 检查包是否已安装（无论版本，只要安装了就需要卸载）
 	// 注意：这里复用AssertPythonPackage时，若传入空版本，会检查是否存在任意版本
-	err = AssertPythonPackage(python3, pkgName, "")
+	err = e.AssertPythonPackage(python3, pkgName, "")
 	if err != nil {
 		// 包未安装，无需卸载
-		logger.Info("python package is not installed, no need to uninstall", "pkgName", pkgName)
+		e.logger.Info("python package is not installed, no need to uninstall", "pkgName", pkgName)
 		return nil
 	}
+	if isExternallyManaged(python3) {
+		return errors.Wrapf(ErrExternallyManaged, "uninstall %s from %s refused", pkgName, python3)
+	}
+	if err := e.confirm(Operation{Action: ActionUninstallPackage, Python3: python3, Package: pkgName}); err != nil {
+		return err
+	}
 
 	// 检查pip是否可用
-	err = RunCommand(python3, "-m", "pip", "--version")
+	err = e.RunCommand(python3, "-m", "pip", "--version")
 	if err != nil {
-		logger.Warn("pip is not available")
+		e.logger.Warn("pip is not available")
 		return errors.Wrap(err, "pip is not available")
 	}
 
-	logger.Info("uninstalling python package", "pkgName", pkgName)
+	e.logger.Info("uninstalling python package", "pkgName", pkgName)
 
 	// 执行卸载命令
-	err = RunCommand(python3, "-m", "pip", "uninstall", pkgName, "-y",
+	err = e.RunCommand(python3, "-m", "pip", "uninstall", pkgName, "-y",
 		"--quiet", "--disable-pip-version-check")
 	if err != nil {
 		return errors.Wrap(err, "pip uninstall package failed")
 	}
 
 	// 验证卸载是否成功（检查包是否已不存在）
-	err = AssertPythonPackage(python3, pkgName, "")
+	err = e.AssertPythonPackage(python3, pkgName, "")
 	if err == nil {
 		// 若检查结果为未报错，说明包仍存在，卸载失败
 		return errors.New("package still exists after uninstall")
 	}
 
-	logger.Info("python package uninstalled successfully", "pkgName", pkgName)
+	e.logger.Info("python package uninstalled successfully", "pkgName", pkgName)
 	return nil
 }
 
+// PackageInfo is python package installed in environment, parsed from pip list
+type PackageInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// EditableProjectLocation is project path of package installed in editable mode
+	EditableProjectLocation string `json:"editable_project_location,omitempty"`
+}
+
+// ListPythonPackages lists python packages installed in python3 environment
+func ListPythonPackages(python3 string) ([]PackageInfo, error) {
+	return NewExecutor().ListPythonPackages(python3)
+}
+
+func (e *Executor) ListPythonPackages(python3 string) ([]PackageInfo, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := e.command(python3, "-m", "pip", "list", "--format=json", "--disable-pip-version-check")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := e.run(cmd); err != nil {
+		e.logger.Error("failed to list python packages", "python3", python3, "stderr", stderr.String())
+		return nil, errors.Wrapf(err, "pip list failed: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	var packages []PackageInfo
+	if err := json.Unmarshal(stdout.Bytes(), &packages); err != nil {
+		return nil, errors.Wrap(err, "parse pip list output failed")
+	}
+	return packages, nil
+}
+
+// GetPythonPackage prints python packages installed in python3 environment
+//
+// Deprecated: use ListPythonPackages instead
 func GetPythonPackage(python3 string) {
-	err := RunCommand(python3, "-m", "pip", "list")
+	NewExecutor().GetPythonPackage(python3)
+}
+
+// GetPythonPackage prints python packages installed in python3 environment
+//
+// Deprecated: use ListPythonPackages instead
+func (e *Executor) GetPythonPackage(python3 string) {
+	err := e.RunCommand(python3, "-m", "pip", "list")
 	if err != nil {
-		logger.Error("failed to list python packages", "name", python3)
+		e.logger.Error("failed to list python packages", "name", python3)
 		return
 	}
 }
 
-// InstallPip 安装pip（修复SSL证书验证错误版本）
+// InstallPip 安装pip，优先使用ensurepip，其次本地或下载的get-pip脚本（校验SSL证书）
 func InstallPip(python3 string) error {
-	logger.Info("检查pip是否已安装", "python3", python3)
-	if err := RunCommand(python3, "-m", "pip", "--version"); err == nil {
-		logger.Info("pip已安装，无需重复操作", "python3", python3)
+	return NewExecutor().InstallPip(python3)
+}
+
+// InstallPip 安装pip，优先使用ensurepip，其次本地或下载的get-pip脚本（校验SSL证书）
+func (e *Executor) InstallPip(python3 string) error {
+	e.logger.Info("检查pip是否已安装", "python3", python3)
+	if err := e.RunCommand(python3, "-m", "pip", "--version"); err == nil {
+		e.logger.Info("pip已安装，无需重复操作", "python3", python3)
 		return nil
 	}
 
+	// ensurepip随python发行，无需网络；部分发行版（如Debian）移除了ensurepip
+	if err := e.ensurePip(python3); err != nil {
+		e.logger.Warn("ensurepip安装pip失败，回退到get-pip脚本", "python3", python3, "error", err)
+		if err := e.runGetPip(python3); err != nil {
+			return err
+		}
+	}
+
+	e.logger.Info("验证pip安装状态")
+	if err := e.RunCommand(python3, "-m", "pip", "--version"); err != nil {
+		return errors.Wrap(err, "pip安装成功但验证失败")
+	}
+
+	e.logger.Info("pip安装完成", "python3", python3)
+	return nil
+}
+
+// ensurePip 使用python自带的ensurepip安装pip
+func (e *Executor) ensurePip(python3 string) error {
+	e.logger.Info("使用ensurepip安装pip", "python3", python3)
+	var output bytes.Buffer
+	cmd := e.command(python3, "-m", "ensurepip", "--upgrade")
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := e.run(cmd); err != nil {
+		return errors.Wrapf(err, "ensurepip失败: %s", strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
+// runGetPip 运行get-pip脚本安装pip，优先使用WithGetPipFile指定的本地脚本，否则从网络下载
+func (e *Executor) runGetPip(python3 string) error {
+	if e.getPipFile != "" {
+		return e.runGetPipFile(python3)
+	}
+
 	getPipURL := "https://bootstrap.pypa.io/get-pip.py"
 	if customURL := os.Getenv("GET_PIP_URL"); customURL != "" {
 		getPipURL = customURL
-		logger.Info("使用自定义get-pip脚本地址", "url", getPipURL)
-	}
-
-	pythonScript := fmt.Sprintf(`
-import urllib.request, sys, ssl
-try:
-    # 忽略SSL证书验证（适用于内部环境/无证书场景）
-    ssl_context = ssl.create_default_context()
-    ssl_context.check_hostname = False
-    ssl_context.verify_mode = ssl.CERT_NONE
-    
-    # 使用带SSL上下文的请求获取脚本
-    with urllib.request.urlopen("%s", context=ssl_context) as response:
-        exec(response.read())
-    print("pip安装成功")
-except Exception as e:
-    print(f"pip安装失败: {str(e)}", file=sys.stderr)
-    sys.exit(1)
-`, getPipURL)
-
-	logger.Info("开始安装pip（已忽略SSL证书验证）", "url", getPipURL)
-	cmd := exec.Command(python3, "-c", pythonScript)
+		e.logger.Info("使用自定义get-pip脚本地址", "url", getPipURL)
+	}
+
+	// 默认校验SSL证书，可通过WithCABundle信任企业代理证书，或显式WithInsecureSkipVerify跳过校验
+	insecure := "0"
+	if e.insecureSkipVerify {
+		insecure = "1"
+		e.logger.Warn("已显式跳过SSL证书验证，仅适用于可信内部网络", "url", getPipURL)
+	}
+	e.logger.Info("开始下载get-pip脚本安装pip", "url", getPipURL, "caBundle", e.caBundle)
+	cmd := e.command(python3, "-c", getPipScript, getPipURL, e.caBundle, insecure)
 
 	// 捕获输出，方便调试
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
-	if err := cmd.Run(); err != nil {
-		logger.Error("pip安装失败",
+	if err := e.run(cmd); err != nil {
+		e.logger.Error("pip安装失败",
 			"stdout", stdout.String(),
-			"stderr", stderr.String(),
-			"执行脚本", pythonScript)
+			"stderr", stderr.String())
 		return errors.Wrapf(err, "pip安装失败: %s", stderr.String())
 	}
+	return nil
+}
 
-	logger.Info("验证pip安装状态")
-	if err := RunCommand(python3, "-m", "pip", "--version"); err != nil {
-		return errors.Wrap(err, "pip安装成功但验证失败")
+// runGetPipFile 运行本地get-pip脚本，脚本所在目录包含wheel时离线安装
+func (e *Executor) runGetPipFile(python3 string) error {
+	args := []string{e.getPipFile}
+	dir := filepath.Dir(e.getPipFile)
+	if wheels, _ := filepath.Glob(filepath.Join(dir, "*.whl")); len(wheels) > 0 {
+		args = append(args, "--no-index", "--find-links", dir)
+	}
+	e.logger.Info("使用本地get-pip脚本安装pip", "file", e.getPipFile, "args", args[1:])
+	var output bytes.Buffer
+	cmd := e.command(python3, args...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := e.run(cmd); err != nil {
+		e.logger.Error("pip安装失败", "output", output.String())
+		return errors.Wrapf(err, "pip安装失败: %s", output.String())
 	}
-
-	logger.Info("pip安装完成", "python3", python3)
 	return nil
 }
 
 // UninstallPip uninstalls pip from the specified Python3 executable
 // It uses pip's own uninstall command for clean removal
 func UninstallPip(python3 string) error {
+	return NewExecutor().UninstallPip(python3)
+}
+
+// UninstallPip uninstalls pip from the specified Python3 executable
+// It uses pip's own uninstall command for clean removal
+func (e *Executor) UninstallPip(python3 string) error {
 	// Step 1: Check if pip is installed (skip if not present)
-	logger.Info("checking if pip is installed", "python3", python3)
-	err := RunCommand(python3, "-m", "pip", "--version")
+	e.logger.Info("checking if pip is installed", "python3", python3)
+	err := e.RunCommand(python3, "-m", "pip", "--version")
 	if err != nil {
-		logger.Info("pip is not installed, no need to uninstall", "python3", python3)
+		e.logger.Info("pip is not installed, no need to uninstall", "python3", python3)
 		return nil
 	}
+	if err := e.confirm(Operation{Action: ActionUninstallPip, Python3: python3}); err != nil {
+		return err
+	}
 
 	// Step 2: Uninstall pip (use -y to skip confirmation)
-	logger.Info("uninstalling pip", "python3", python3)
-	err = RunCommand(python3, "-m", "pip", "uninstall", "pip", "-y",
+	e.logger.Info("uninstalling pip", "python3", python3)
+	err = e.RunCommand(python3, "-m", "pip", "uninstall", "pip", "-y",
 		"--quiet", "--disable-pip-version-check")
 	if err != nil {
 		return errors.Wrap(err, "failed to uninstall pip via pip command")
 	}
 
 	// Step 3: Verify pip uninstallation
-	logger.Info("verifying pip uninstallation")
-	err = RunCommand(python3, "-m", "pip", "--version")
+	e.logger.Info("verifying pip uninstallation")
+	err = e.RunCommand(python3, "-m", "pip", "--version")
 	if err == nil {
 		// If no error, pip is still present (uninstall failed)
 		return errors.New("pip still exists after uninstallation")
 	}
 
-	logger.Info("pip uninstalled successfully", "python3", python3)
+	e.logger.Info("pip uninstalled successfully", "python3", python3)
 	return nil
 }
 
@@ -352,13 +460,23 @@ type pypiProject struct {
 // GetPythonPackageVersions 查询指定Python包的所有可用版本
 // 返回版本号切片（按版本号从旧到新排序）
 func GetPythonPackageVersions(pkgName string) ([]string, error) {
+	return NewExecutor().GetPythonPackageVersions(pkgName)
+}
+
+// GetPythonPackageVersions 查询指定Python包的所有可用版本
+// 返回版本号切片（按版本号从旧到新排序）
+func (e *Executor) GetPythonPackageVersions(pkgName string) ([]string, error) {
 	if pkgName == "" {
 		return nil, errors.New("package name cannot be empty")
 	}
 
 	// 调用PyPI的JSON API
 	url := fmt.Sprintf("https://pypi.org/pypi/%s/json", pkgName)
-	resp, err := http.Get(url)
+	client, err := e.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Get(url)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch package info for %s", pkgName)
 	}
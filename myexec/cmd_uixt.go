@@ -16,38 +16,51 @@ func getPython3Executable(venvDir string) string {
 	return filepath.Join(venvDir, "bin", "python3")
 }
 
-func ensurePython3Venv(venv string, packages ...string) (python3 string, err error) {
+// venvScriptCandidates returns possible paths of console script in venv
+func venvScriptCandidates(venvDir, scriptName string) []string {
+	return []string{filepath.Join(venvDir, "bin", scriptName)}
+}
+
+func (e *Executor) ensurePython3Venv(venv string, packages ...string) (python3 string, err error) {
 	python3 = getPython3Executable(venv)
 
-	logger.Info("ensure python3 venv",
+	e.logger.Info("ensure python3 venv",
 		"python3", python3,
 		"packages", packages)
 
 	// check if python3 venv is available
-	if !isPython3(python3) {
+	if !e.isVenvPython(python3) {
 		// python3 venv not available, create one
 		// check if system python3 is available
-		if err := RunCommand("python3", "--version"); err != nil {
+		systemPython := "python3"
+		if e.pythonVersion != "" {
+			if systemPython, err = e.findPython(e.pythonVersion); err != nil {
+				return "", err
+			}
+		} else if err := e.RunCommand(systemPython, "--version"); err != nil {
 			return "", errors.Wrap(err, "python3 not found")
 		}
 
 		// check if .venv exists
 		if _, err := os.Stat(venv); err == nil {
 			// .venv exists, remove first
-			if err := RunCommand("rm", "-rf", venv); err != nil {
+			if err := e.confirm(Operation{Action: ActionRemoveVenv, Venv: venv}); err != nil {
+				return "", err
+			}
+			if err := os.RemoveAll(venv); err != nil {
 				return "", errors.Wrap(err, "remove existed venv failed")
 			}
 		}
 
 		// create python3 .venv
-		if err := RunCommand("python3", "-m", "venv", venv); err != nil {
+		if err := e.RunCommand(systemPython, "-m", "venv", venv); err != nil {
 			return "", errors.Wrap(err, "create python3 venv failed")
 		}
 	}
 
 	// install default python packages
 	for _, pkg := range packages {
-		err := InstallPythonPackage(python3, pkg)
+		err := e.InstallPythonPackage(python3, pkg)
 		if err != nil {
 			return "", errors.Wrap(err, fmt.Sprintf("pip install %s failed", pkg))
 		}
@@ -64,6 +77,15 @@ func Command(name string, arg ...string) *exec.Cmd {
 	return cmd
 }
 
+// setProcessGroup starts command in a new process group, thus it is killed
+// with its children by KillProcessesByGpid
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
 func KillProcessesByGpid(cmd *exec.Cmd) error {
 	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
 }
@@ -8,6 +8,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"syscall"
 
 	"github.com/pkg/errors"
@@ -28,21 +29,39 @@ func getPython3Executable(venvDir string) string {
 	return filepath.Join(venvDir, "Scripts", "python.exe")
 }
 
-func ensurePython3Venv(venvDir string, packages ...string) (python3 string, err error) {
+// venvScriptCandidates returns possible paths of console script in venv,
+// pip installs console scripts as .exe launchers on windows
+func venvScriptCandidates(venvDir, scriptName string) []string {
+	scriptsDir := filepath.Join(venvDir, "Scripts")
+	if filepath.Ext(scriptName) != "" {
+		return []string{filepath.Join(scriptsDir, scriptName)}
+	}
+	var candidates []string
+	for _, ext := range []string{".exe", ".cmd", ".bat", ""} {
+		candidates = append(candidates, filepath.Join(scriptsDir, scriptName+ext))
+	}
+	return candidates
+}
+
+func (e *Executor) ensurePython3Venv(venvDir string, packages ...string) (python3 string, err error) {
 	python3 = getPython3Executable(venvDir)
-	logger.Info("ensure python3 venv",
+	e.logger.Info("ensure python3 venv",
 		"python3", python3,
 		"packages", packages)
 
 	systemPython := "python3"
 
 	// check if python3 venv is available
-	if !isPython3(python3) {
+	if !e.isVenvPython(python3) {
 		// python3 venv not available, create one
 		// check if system python3 is available
-		logger.Warn("python3 venv is not available, try to check system python3",
+		e.logger.Warn("python3 venv is not available, try to check system python3",
 			"pythonPath", python3)
-		if !isPython3(systemPython) {
+		if e.pythonVersion != "" {
+			if systemPython, err = e.findPython(e.pythonVersion); err != nil {
+				return "", err
+			}
+		} else if !isPython3(systemPython) {
 			if !isPython3("python") {
 				return "", errors.Wrap(err, "python3 not found")
 			}
@@ -52,7 +71,10 @@ func ensurePython3Venv(venvDir string, packages ...string) (python3 string, err
 		// check if .venv exists
 		if _, err := os.Stat(venvDir); err == nil {
 			// .venv exists, remove first
-			if err := RunCommand("del", "/q", venvDir); err != nil {
+			if err := e.confirm(Operation{Action: ActionRemoveVenv, Venv: venvDir}); err != nil {
+				return "", err
+			}
+			if err := os.RemoveAll(venvDir); err != nil {
 				return "", errors.Wrap(err, "remove existed venv failed")
 			}
 		}
@@ -60,17 +82,17 @@ func ensurePython3Venv(venvDir string, packages ...string) (python3 string, err
 		// create python3 .venv
 		// notice: --symlinks should be specified for windows
 		// https://github.com/actions/virtual-environments/issues/2690
-		if err := RunCommand(systemPython, "-m", "venv", "--symlinks", venvDir); err != nil {
+		if err := e.RunCommand(systemPython, "-m", "venv", "--symlinks", venvDir); err != nil {
 			// fix: failed to symlink on Windows
-			logger.Warn("failed to create python3 .venv by using --symlinks, try to use --copies")
-			if err := RunCommand(systemPython, "-m", "venv", "--copies", venvDir); err != nil {
+			e.logger.Warn("failed to create python3 .venv by using --symlinks, try to use --copies")
+			if err := e.RunCommand(systemPython, "-m", "venv", "--copies", venvDir); err != nil {
 				return "", errors.Wrap(err, "create python3 venv failed")
 			}
 		}
 
 		// fix: python3 doesn't exist in .venv on Windows
 		if _, err := os.Stat(python3); err != nil {
-			logger.Warn("python3 doesn't exist, try to link python")
+			e.logger.Warn("python3 doesn't exist, try to link python")
 			err := os.Link(filepath.Join(venvDir, "Scripts", "python.exe"), python3)
 			if err != nil {
 				return "", errors.Wrap(err, "python3 doesn't exist in .venv")
@@ -80,7 +102,7 @@ func ensurePython3Venv(venvDir string, packages ...string) (python3 string, err
 
 	// install default python packages
 	for _, pkg := range packages {
-		err := InstallPythonPackage(python3, pkg)
+		err := e.InstallPythonPackage(python3, pkg)
 		if err != nil {
 			return "", errors.Wrap(err, fmt.Sprintf("pip install %s failed", pkg))
 		}
@@ -91,14 +113,34 @@ func ensurePython3Venv(venvDir string, packages ...string) (python3 string, err
 
 func Command(name string, arg ...string) *exec.Cmd {
 	cmd := exec.Command(name, arg...)
+	// executable in venv deeper than MAX_PATH can not be started otherwise
+	cmd.Path = longPath(cmd.Path)
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		HideWindow: true,
 	}
 	return cmd
 }
 
+// longPath adds extended-length prefix to absolute path exceeding MAX_PATH,
+// the path is cleaned since the prefix disables path normalization
+func longPath(path string) string {
+	const maxPath = 260
+	if len(path) < maxPath || !filepath.IsAbs(path) || strings.HasPrefix(path, `\\?\`) {
+		return path
+	}
+	path = filepath.Clean(path)
+	if strings.HasPrefix(path, `\\`) {
+		// UNC path \\server\share
+		return `\\?\UNC\` + path[2:]
+	}
+	return `\\?\` + path
+}
+
+// setProcessGroup is a no-op on windows, taskkill /T kills the process tree
+func setProcessGroup(cmd *exec.Cmd) {}
+
 func KillProcessesByGpid(cmd *exec.Cmd) error {
-	killCmd := Command("taskkill", "/T", "/F", "/PID ", strconv.Itoa(cmd.Process.Pid))
+	killCmd := Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid))
 	return killCmd.Run()
 }
 
@@ -2,7 +2,10 @@
 
 package myexec
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestRunShellWindows(t *testing.T) {
 	exitCode, err := RunShell("echo hello world")
@@ -19,3 +22,22 @@ func TestRunShellWindows(t *testing.T) {
 	}
 	t.Log(exitCode)
 }
+
+func TestLongPath(t *testing.T) {
+	short := `C:\Users\dev\.yf\venv\Scripts\python.exe`
+	if longPath(short) != short {
+		t.Fatalf("short path should not be changed: %s", longPath(short))
+	}
+
+	deep := `C:\Users\dev\` + strings.Repeat(`nested dir\`, 30) + `venv\Scripts\python.exe`
+	if got := longPath(deep); got != `\\?\`+deep {
+		t.Fatalf("unexpected long path: %s", got)
+	}
+	unc := `\\server\share\` + strings.Repeat(`nested dir\`, 30) + `python.exe`
+	if got := longPath(unc); got != `\\?\UNC\`+unc[2:] {
+		t.Fatalf("unexpected long UNC path: %s", got)
+	}
+	if got := longPath(`\\?\` + deep); got != `\\?\`+deep {
+		t.Fatalf("prefixed path should not be changed: %s", got)
+	}
+}
@@ -0,0 +1,39 @@
+package myexec
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ErrNotConfirmed is returned when destructive operation is declined by ConfirmFunc
+var ErrNotConfirmed = errors.New("operation not confirmed")
+
+// OperationAction is kind of destructive operation modifying python environment
+type OperationAction string
+
+const (
+	ActionUninstallPackage OperationAction = "uninstall-package" // pip uninstall package
+	ActionUninstallPip     OperationAction = "uninstall-pip"     // pip uninstall pip
+	ActionRemoveVenv       OperationAction = "remove-venv"       // remove existing venv before recreating it
+)
+
+// Operation describes destructive operation to be confirmed
+type Operation struct {
+	Action  OperationAction
+	Python3 string // python3 executable modified, empty for ActionRemoveVenv
+	Package string // package name, only for ActionUninstallPackage
+	Venv    string // venv directory, only for ActionRemoveVenv
+}
+
+// ConfirmFunc is called before destructive operation, e.g. to prompt users
+// or enforce policy, the operation is skipped with ErrNotConfirmed if it returns false
+type ConfirmFunc func(op Operation) bool
+
+// confirm asks ConfirmFunc specified by WithConfirm, operations are confirmed without it
+func (e *Executor) confirm(op Operation) error {
+	if e.confirmFunc == nil || e.confirmFunc(op) {
+		return nil
+	}
+	e.logger.Warn("operation declined", "action", op.Action,
+		"python3", op.Python3, "package", op.Package, "venv", op.Venv)
+	return errors.Wrapf(ErrNotConfirmed, "%s declined", op.Action)
+}
@@ -0,0 +1,77 @@
+package myexec
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestConfirmUninstallPythonPackage(t *testing.T) {
+	venv := newVenv(t)
+	info, err := VenvInfo(venv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// fake installed package found by importlib.metadata
+	distInfo := filepath.Join(info.SitePackages, "fakepkg-1.0.dist-info")
+	if err := os.MkdirAll(distInfo, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	metadata := "Metadata-Version: 2.1\nName: fakepkg\nVersion: 1.0\n"
+	if err := os.WriteFile(filepath.Join(distInfo, "METADATA"), []byte(metadata), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var ops []Operation
+	e := NewExecutor(WithConfirm(func(op Operation) bool {
+		ops = append(ops, op)
+		return false
+	}))
+	err = e.UninstallPythonPackage(info.Python, "fakepkg==1.0")
+	if !errors.Is(err, ErrNotConfirmed) {
+		t.Fatalf("expected ErrNotConfirmed, got %v", err)
+	}
+	expected := Operation{Action: ActionUninstallPackage, Python3: info.Python, Package: "fakepkg"}
+	if len(ops) != 1 || ops[0] != expected {
+		t.Fatalf("unexpected operations: %+v", ops)
+	}
+	if err := e.AssertPythonPackage(info.Python, "fakepkg", "1.0"); err != nil {
+		t.Fatalf("declined package should be kept: %v", err)
+	}
+
+	// package not installed and pip not installed, nothing to confirm
+	if err := e.UninstallPythonPackage(info.Python, "notinstalled"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.UninstallPip(info.Python); err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 1 {
+		t.Fatalf("unexpected operations: %+v", ops)
+	}
+}
+
+func TestConfirmRemoveVenv(t *testing.T) {
+	// recreating broken venv removes it after confirmation
+	venv := filepath.Join(t.TempDir(), "venv")
+	if err := os.MkdirAll(venv, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var ops []Operation
+	e := NewExecutor(WithConfirm(func(op Operation) bool {
+		ops = append(ops, op)
+		return false
+	}))
+	_, err := e.EnsurePython3Venv(venv)
+	if !errors.Is(err, ErrNotConfirmed) {
+		t.Fatalf("expected ErrNotConfirmed, got %v", err)
+	}
+	if len(ops) != 1 || ops[0] != (Operation{Action: ActionRemoveVenv, Venv: venv}) {
+		t.Fatalf("unexpected operations: %+v", ops)
+	}
+	if _, err := os.Stat(venv); err != nil {
+		t.Fatalf("declined venv should be kept: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package myexec
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// depsTreeScript prints installed distributions with their requirements,
+// optional requirements of extras are skipped
+const depsTreeScript = `import json
+from importlib import metadata
+packages = []
+for dist in metadata.distributions():
+    requires = [r for r in (dist.requires or []) if ";" not in r or "extra" not in r.split(";", 1)[1]]
+    packages.append({"name": dist.metadata["Name"], "version": dist.version, "requires": requires})
+print(json.dumps(packages))`
+
+var (
+	// e.g. "funppy 0.6.0 has requirement grpcio<2, but you have grpcio 2.1."
+	pipCheckMismatch = regexp.MustCompile(`^(\S+) (\S+) has requirement (.+), but you have (\S+) (\S+)\.$`)
+	// e.g. "funppy 0.6.0 requires grpcio, which is not installed."
+	pipCheckMissing = regexp.MustCompile(`^(\S+) (\S+) requires (.+), which is not installed\.$`)
+)
+
+// PackageDeps is installed python package with its requirements
+type PackageDeps struct {
+	Name     string   `json:"name"`
+	Version  string   `json:"version"`
+	Requires []string `json:"requires"` // requirement specifiers, e.g. grpcio>=1.4
+}
+
+// DepConflict is broken requirement reported by pip check
+type DepConflict struct {
+	Package     string // package declaring requirement
+	Version     string // version of package declaring requirement
+	Requirement string // requirement specifier, e.g. grpcio<2
+	Installed   string // installed version of required package, empty if not installed
+	Message     string // pip check output line
+}
+
+// DepsReport is dependency tree and conflicts of python environment returned by CheckPythonDeps
+type DepsReport struct {
+	Packages  []PackageDeps
+	Conflicts []DepConflict
+}
+
+// CheckPythonDeps inspects dependency tree of python3 environment and reports
+// conflicts found by pip check, e.g. package X requires Y<2 but 3.1 installed,
+// error is only returned when the environment can not be inspected
+func CheckPythonDeps(python3 string) (*DepsReport, error) {
+	return NewExecutor().CheckPythonDeps(python3)
+}
+
+func (e *Executor) CheckPythonDeps(python3 string) (*DepsReport, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := e.command(python3, "-c", depsTreeScript)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := e.run(cmd); err != nil {
+		return nil, errors.Wrapf(err, "inspect python dependency tree failed: %s", strings.TrimSpace(stderr.String()))
+	}
+	report := &DepsReport{}
+	if err := json.Unmarshal(stdout.Bytes(), &report.Packages); err != nil {
+		return nil, errors.Wrap(err, "parse python dependency tree failed")
+	}
+
+	// pip check exits with 1 when broken requirements are found
+	stdout.Reset()
+	stderr.Reset()
+	cmd = e.command(python3, "-m", "pip", "check", "--disable-pip-version-check")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := e.run(cmd)
+	report.Conflicts = parsePipCheck(stdout.String())
+	if err != nil && len(report.Conflicts) == 0 {
+		return nil, errors.Wrapf(err, "pip check failed: %s", strings.TrimSpace(stderr.String()))
+	}
+	return report, nil
+}
+
+// parsePipCheck parses broken requirements of pip check output
+func parsePipCheck(output string) []DepConflict {
+	var conflicts []DepConflict
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "No broken requirements found") {
+			continue
+		}
+		conflict := DepConflict{Message: line}
+		if m := pipCheckMismatch.FindStringSubmatch(line); m != nil {
+			conflict.Package, conflict.Version = m[1], m[2]
+			conflict.Requirement, conflict.Installed = m[3], m[5]
+		} else if m := pipCheckMissing.FindStringSubmatch(line); m != nil {
+			conflict.Package, conflict.Version, conflict.Requirement = m[1], m[2], m[3]
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	return conflicts
+}
+
+// warnDepsConflicts logs dependency conflicts of provisioned venv,
+// thus they are surfaced before plugin fails at runtime
+func (e *Executor) warnDepsConflicts(python3 string) {
+	report, err := e.CheckPythonDeps(python3)
+	if err != nil {
+		e.logger.Warn("check python dependencies failed", "python3", python3, "error", err)
+		return
+	}
+	for _, conflict := range report.Conflicts {
+		e.logger.Warn("python dependency conflict", "python3", python3, "conflict", conflict.Message)
+	}
+}
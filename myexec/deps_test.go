@@ -0,0 +1,102 @@
+package myexec
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParsePipCheck(t *testing.T) {
+	output := `funppy 0.6.0 has requirement grpcio<2,>=1.4, but you have grpcio 2.1.
+funppy 0.6.0 requires protobuf, which is not installed.
+pyobjc 10.0 is not supported on this platform
+`
+	conflicts := parsePipCheck(output)
+	expected := []DepConflict{
+		{Package: "funppy", Version: "0.6.0", Requirement: "grpcio<2,>=1.4", Installed: "2.1",
+			Message: "funppy 0.6.0 has requirement grpcio<2,>=1.4, but you have grpcio 2.1."},
+		{Package: "funppy", Version: "0.6.0", Requirement: "protobuf",
+			Message: "funppy 0.6.0 requires protobuf, which is not installed."},
+		{Message: "pyobjc 10.0 is not supported on this platform"},
+	}
+	if len(conflicts) != len(expected) {
+		t.Fatalf("unexpected conflicts: %+v", conflicts)
+	}
+	for i := range expected {
+		if conflicts[i] != expected[i] {
+			t.Fatalf("unexpected conflict %d: %+v", i, conflicts[i])
+		}
+	}
+
+	if conflicts := parsePipCheck("No broken requirements found.\n"); len(conflicts) != 0 {
+		t.Fatalf("unexpected conflicts: %+v", conflicts)
+	}
+}
+
+func TestCheckPythonDeps(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake python3 script requires a POSIX shell")
+	}
+	// fake python3 prints dependency tree and broken requirement of pip check
+	python3 := filepath.Join(t.TempDir(), "python3")
+	script := `#!/bin/sh
+if [ "$1" = "-c" ]; then
+  echo '[{"name": "funppy", "version": "0.6.0", "requires": ["grpcio<2"]}, {"name": "grpcio", "version": "2.1", "requires": []}]'
+else
+  echo 'funppy 0.6.0 has requirement grpcio<2, but you have grpcio 2.1.'
+  exit 1
+fi
+`
+	if err := os.WriteFile(python3, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	report, err := CheckPythonDeps(python3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Packages) != 2 || report.Packages[0].Name != "funppy" ||
+		strings.Join(report.Packages[0].Requires, ",") != "grpcio<2" {
+		t.Fatalf("unexpected dependency tree: %+v", report.Packages)
+	}
+	if len(report.Conflicts) != 1 || report.Conflicts[0].Requirement != "grpcio<2" ||
+		report.Conflicts[0].Installed != "2.1" {
+		t.Fatalf("unexpected conflicts: %+v", report.Conflicts)
+	}
+
+	// pip not available
+	script = "#!/bin/sh\nif [ \"$1\" = \"-c\" ]; then echo '[]'; else echo 'No module named pip' >&2; exit 1; fi\n"
+	if err := os.WriteFile(python3, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CheckPythonDeps(python3); err == nil || !strings.Contains(err.Error(), "No module named pip") {
+		t.Fatalf("expected pip check error, got %v", err)
+	}
+}
+
+func TestDepsTreeScript(t *testing.T) {
+	venv := newVenv(t)
+	info, err := VenvInfo(venv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// fake installed package with optional requirement of extra
+	distInfo := filepath.Join(info.SitePackages, "fakepkg-1.0.dist-info")
+	if err := os.MkdirAll(distInfo, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	metadata := "Metadata-Version: 2.1\nName: fakepkg\nVersion: 1.0\n" +
+		"Requires-Dist: grpcio<2\nRequires-Dist: pytest; extra == \"test\"\n"
+	if err := os.WriteFile(filepath.Join(distInfo, "METADATA"), []byte(metadata), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// venv without pip fails pip check, tree is inspected by the script alone
+	out, err := Command(info.Python, "-c", depsTreeScript).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `{"name": "fakepkg", "version": "1.0", "requires": ["grpcio<2"]}`) {
+		t.Fatalf("unexpected dependency tree: %s", out)
+	}
+}
@@ -0,0 +1,43 @@
+package myexec
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// env at program start, package vars differing from them are assigned in code
+var (
+	initPypiIndexURL = PYPI_INDEX_URL
+	initPipAudit     = PIP_AUDIT
+)
+
+// pypiIndexURL resolves pip index url at call time,
+// PYPI_INDEX_URL assigned in code takes precedence over env
+func pypiIndexURL() string {
+	if PYPI_INDEX_URL != initPypiIndexURL {
+		return PYPI_INDEX_URL
+	}
+	return os.Getenv("PYPI_INDEX_URL")
+}
+
+// pipAuditMode resolves pip-audit mode at call time,
+// PIP_AUDIT assigned in code takes precedence over env
+func pipAuditMode() PipAuditMode {
+	if PIP_AUDIT != initPipAudit {
+		return PIP_AUDIT
+	}
+	return PipAuditMode(os.Getenv("PIP_AUDIT"))
+}
+
+// prependPath moves dir to the front of path list, thus commands in dir are preferred
+// and repeated calls do not grow path
+func prependPath(path, dir string) string {
+	dirs := []string{dir}
+	for _, d := range filepath.SplitList(path) {
+		if d != dir {
+			dirs = append(dirs, d)
+		}
+	}
+	return strings.Join(dirs, string(os.PathListSeparator))
+}
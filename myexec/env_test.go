@@ -0,0 +1,57 @@
+package myexec
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEnvResolvedAtCallTime(t *testing.T) {
+	t.Setenv("PYPI_INDEX_URL", "https://mirror.example.com/simple")
+	t.Setenv("PIP_AUDIT", "report")
+	e := NewExecutor()
+	if e.indexURL != "https://mirror.example.com/simple" {
+		t.Fatalf("env PYPI_INDEX_URL set after import ignored, got %s", e.indexURL)
+	}
+	if e.pipAudit != PipAuditReport {
+		t.Fatalf("env PIP_AUDIT set after import ignored, got %s", e.pipAudit)
+	}
+
+	// package vars assigned in code take precedence over env
+	defer func(url string) { PYPI_INDEX_URL = url }(PYPI_INDEX_URL)
+	PYPI_INDEX_URL = "https://assigned.example.com/simple"
+	if e := NewExecutor(); e.indexURL != PYPI_INDEX_URL {
+		t.Fatalf("assigned PYPI_INDEX_URL ignored, got %s", e.indexURL)
+	}
+	// options take precedence over both
+	if e := NewExecutor(WithIndexURL("https://option.example.com/simple")); e.indexURL != "https://option.example.com/simple" {
+		t.Fatalf("WithIndexURL ignored, got %s", e.indexURL)
+	}
+}
+
+func TestRunCommandPath(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	cmdDir := filepath.Join(t.TempDir(), "bin")
+	for i := 0; i < 2; i++ {
+		// command not found is fine, only $PATH is checked
+		_ = RunCommand(filepath.Join(cmdDir, "missing-command"))
+	}
+	dirs := filepath.SplitList(os.Getenv("PATH"))
+	if dirs[0] != cmdDir || dirs[1] != dir {
+		t.Fatalf("PATH set after import ignored: %v", dirs[:2])
+	}
+	if strings.Count(os.Getenv("PATH"), cmdDir) != 1 {
+		t.Fatalf("PATH grows on repeated calls: %s", os.Getenv("PATH"))
+	}
+}
+
+func TestPrependPath(t *testing.T) {
+	sep := string(os.PathListSeparator)
+	path := prependPath(strings.Join([]string{"/usr/bin", "/venv/bin", "/bin"}, sep), "/venv/bin")
+	if path != strings.Join([]string{"/venv/bin", "/usr/bin", "/bin"}, sep) {
+		t.Fatalf("unexpected path: %s", path)
+	}
+}
@@ -0,0 +1,81 @@
+package myexec
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+func TestExecutorOutput(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	e := NewExecutor(WithOutput(&stdout, &stderr))
+	if _, err := e.RunShell("echo hello world"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(stdout.String()) != "hello world" {
+		t.Fatalf("unexpected stdout: %q", stdout.String())
+	}
+}
+
+func TestExecutorTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sleep requires a POSIX shell")
+	}
+	var logs bytes.Buffer
+	e := NewExecutor(WithTimeout(500*time.Millisecond),
+		WithLogger(hclog.New(&hclog.LoggerOptions{Output: &logs})))
+
+	start := time.Now()
+	exitCode, err := e.RunShell("sleep 10 | cat")
+	if err == nil || !strings.Contains(err.Error(), "command timeout after 500ms") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if exitCode == 0 {
+		t.Fatal("expected non-zero exit code")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("command not killed after timeout, elapsed %v", elapsed)
+	}
+	if !strings.Contains(logs.String(), "exec shell string") {
+		t.Fatalf("expected logs written to logger, got %q", logs.String())
+	}
+}
+
+func TestExecutorPipInstallOptions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake python3 script requires a POSIX shell")
+	}
+	// fake python3 prints pip install arguments and proxy env
+	python3 := filepath.Join(t.TempDir(), "python3")
+	script := "#!/bin/sh\necho \"$@\"\necho \"proxy=$HTTPS_PROXY\"\n"
+	if err := os.WriteFile(python3, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	e := NewExecutor(
+		WithIndexURL("https://mirror.example.com/simple"),
+		WithProxy("http://proxy.example.com:8080"),
+		WithOutput(&stdout, &stdout))
+	if err := e.pipInstall(python3, "funppy", "--index-url", e.indexURL); err != nil {
+		t.Fatal(err)
+	}
+	output := stdout.String()
+	if !strings.Contains(output, "-m pip install funppy --index-url https://mirror.example.com/simple") {
+		t.Fatalf("index url not passed to pip: %q", output)
+	}
+	if !strings.Contains(output, "proxy=http://proxy.example.com:8080") {
+		t.Fatalf("proxy not passed to pip: %q", output)
+	}
+
+	// defaults to pypi.org
+	if e := NewExecutor(); PYPI_INDEX_URL == "" && e.indexURL != defaultIndexURL {
+		t.Fatalf("unexpected default index url: %s", e.indexURL)
+	}
+}
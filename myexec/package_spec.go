@@ -0,0 +1,256 @@
+package myexec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// PackageSpec specifies python package to install, from package index by name and version,
+// or from local project directory, sdist, wheel or VCS url
+type PackageSpec struct {
+	Name      string   // distribution name, required for index and VCS packages
+	Version   string   // exact version of index package, empty for latest
+	Specifier string   // version specifiers of index package, e.g. >=0.5,<0.6
+	Extras    []string // optional features, e.g. grpc of funppy[grpc]
+	Marker    string   // environment marker, e.g. python_version >= "3.8", package is skipped if not matched
+	Path      string   // local project directory, sdist or wheel
+	VCSURL    string   // VCS or direct url, e.g. git+https://github.com/httprunner/funppy@v0.6.0
+	Editable  bool     // pip install -e, only for local project directory or VCS url
+}
+
+// pep508Pattern matches PEP 508 requirement: name, extras, version specifiers or url, and marker
+var pep508Pattern = regexp.MustCompile(
+	`^([A-Za-z0-9](?:[A-Za-z0-9._-]*[A-Za-z0-9])?)\s*(?:\[([^\]]*)\])?\s*(?:@\s*(\S+)|([\s()<>=!~.,*+\w-]*))\s*(?:;\s*(.+))?$`)
+
+// exactVersionPattern matches single exact version specifier, e.g. ==0.5.0
+var exactVersionPattern = regexp.MustCompile(`^==\s*([^,*=<>!~\s]+)$`)
+
+// ParsePackageSpec parses PEP 508 requirement as accepted by InstallPythonPackage,
+// e.g. funppy==0.5.0, funppy[grpc]>=0.5,<0.6 or funppy; python_version >= "3.8"
+func ParsePackageSpec(pkg string) (PackageSpec, error) {
+	m := pep508Pattern.FindStringSubmatch(strings.TrimSpace(pkg))
+	if m == nil {
+		return PackageSpec{}, fmt.Errorf("invalid python package requirement: %s", pkg)
+	}
+	spec := PackageSpec{Name: m[1], VCSURL: m[3], Marker: strings.TrimSpace(m[5])}
+	for _, extra := range strings.Split(m[2], ",") {
+		if extra = strings.TrimSpace(extra); extra != "" {
+			spec.Extras = append(spec.Extras, extra)
+		}
+	}
+
+	specifier := strings.Join(strings.Fields(m[4]), "")
+	// specifiers may be enclosed in parentheses, e.g. funppy (>=0.5)
+	specifier = strings.TrimSuffix(strings.TrimPrefix(specifier, "("), ")")
+	if exact := exactVersionPattern.FindStringSubmatch(specifier); exact != nil {
+		// specify package version
+		// funppy==0.5.0
+		spec.Version = exact[1]
+	} else {
+		// package version not specified or ranged, install the latest matched by default
+		// funppy, funppy>=0.5,<0.6
+		spec.Specifier = specifier
+	}
+	return spec, nil
+}
+
+func (s PackageSpec) validate() error {
+	if s.Path != "" && s.VCSURL != "" {
+		return fmt.Errorf("package spec path and VCS url are mutually exclusive")
+	}
+	if s.Path == "" && s.VCSURL == "" {
+		if s.Name == "" {
+			return fmt.Errorf("package spec requires name, path or VCS url")
+		}
+		if s.Editable {
+			return fmt.Errorf("editable install requires path or VCS url of %s", s.Name)
+		}
+	}
+	if s.VCSURL != "" && s.Name == "" {
+		return fmt.Errorf("package spec of VCS url %s requires name", s.VCSURL)
+	}
+	if (s.Version != "" || s.Specifier != "") && (s.Path != "" || s.VCSURL != "") {
+		return fmt.Errorf("version only applies to index package, pin VCS url revision instead")
+	}
+	if s.Version != "" && s.Specifier != "" {
+		return fmt.Errorf("version and specifier of %s are mutually exclusive", s.Name)
+	}
+	if s.Marker != "" && (s.Path != "" || s.Editable) {
+		return fmt.Errorf("marker does not apply to local or editable package")
+	}
+	return nil
+}
+
+// remote checks if package is installed from index by name and version
+func (s PackageSpec) remote() bool {
+	return s.Path == "" && s.VCSURL == ""
+}
+
+func (s PackageSpec) extras() string {
+	if len(s.Extras) == 0 {
+		return ""
+	}
+	return "[" + strings.Join(s.Extras, ",") + "]"
+}
+
+// requirement returns PEP 508 requirement of index package without url
+func (s PackageSpec) requirement() string {
+	requirement := s.Name + s.extras() + s.Specifier
+	if s.Version != "" {
+		requirement += "==" + s.Version
+	}
+	if s.Marker != "" {
+		requirement += "; " + s.Marker
+	}
+	return requirement
+}
+
+// pipArgs returns pip install arguments of package
+func (s PackageSpec) pipArgs() []string {
+	var requirement string
+	switch {
+	case s.Path != "":
+		requirement = s.Path + s.extras()
+	case s.Editable:
+		// editable VCS install names package with egg fragment
+		requirement = s.VCSURL + "#egg=" + s.Name + s.extras()
+	case s.VCSURL != "":
+		// space is required between url and marker
+		requirement = s.Name + s.extras() + " @ " + s.VCSURL
+		if s.Marker != "" {
+			requirement += " ; " + s.Marker
+		}
+	default:
+		requirement = s.requirement()
+	}
+	if s.Editable {
+		return []string{"-e", requirement}
+	}
+	return []string{requirement}
+}
+
+// String returns package requirement, e.g. funppy[grpc]>=0.5,<0.6 or -e ./funppy
+func (s PackageSpec) String() string {
+	return strings.Join(s.pipArgs(), " ")
+}
+
+// InstallPythonPackageSpec installs package specified by PackageSpec, e.g. plugin's own
+// python package in editable mode, packages from index already installed are skipped
+func InstallPythonPackageSpec(python3 string, spec PackageSpec) error {
+	return NewExecutor().InstallPythonPackageSpec(python3, spec)
+}
+
+func (e *Executor) InstallPythonPackageSpec(python3 string, spec PackageSpec) (err error) {
+	if err := spec.validate(); err != nil {
+		return err
+	}
+
+	// skip package not required by environment marker
+	if spec.Marker != "" {
+		status, err := e.requirementStatus(python3, spec)
+		if err != nil {
+			return err
+		}
+		if !status.Marker {
+			e.logger.Info("python package skipped for environment marker not matched",
+				"pkgName", spec.Name, "marker", spec.Marker)
+			return nil
+		}
+	}
+
+	// check if package installed and version matched,
+	// local and VCS packages are always reinstalled since their version may not change
+	if spec.remote() {
+		if err = e.assertPackageSpec(python3, spec); err == nil {
+			return nil
+		}
+	}
+
+	// pip refuses to install into externally managed python
+	if isExternallyManaged(python3) {
+		return e.installIntoManagedVenv(python3, spec)
+	}
+
+	// check if pip available
+	err = e.RunCommand(python3, "-m", "pip", "--version")
+	if err != nil {
+		e.logger.Warn("pip is not available")
+		return errors.Wrap(err, "pip is not available")
+	}
+
+	e.logger.Info("installing python package", "pkgName",
+		spec.Name, "pkgVersion", spec.Version, "spec", spec.String())
+
+	// install package
+	args := append(spec.pipArgs(), "--upgrade",
+		"--index-url", e.indexURL,
+		"--quiet", "--disable-pip-version-check")
+	if err = e.pipInstall(python3, args...); err != nil {
+		return errors.Wrap(err, "pip install package failed")
+	}
+
+	if spec.Name == "" {
+		return nil
+	}
+	return e.assertPackageSpec(python3, spec)
+}
+
+// requirementScript evaluates marker and version specifiers of requirement with
+// packaging vendored by pip, since neither is supported by python stdlib
+const requirementScript = `import json, sys
+from importlib import metadata
+from pip._vendor.packaging.requirements import Requirement
+req = Requirement(sys.argv[1])
+try:
+    version = metadata.version(req.name)
+except metadata.PackageNotFoundError:
+    version = ""
+print(json.dumps({
+    "marker": req.marker is None or req.marker.evaluate(),
+    "version": version,
+    "satisfied": bool(version) and req.specifier.contains(version, prereleases=True),
+}))`
+
+// requirementStatus is result of requirementScript
+type requirementStatus struct {
+	Marker    bool   `json:"marker"`    // environment marker matched
+	Version   string `json:"version"`   // installed version, empty if not installed
+	Satisfied bool   `json:"satisfied"` // installed version matches specifiers
+}
+
+func (e *Executor) requirementStatus(python3 string, spec PackageSpec) (*requirementStatus, error) {
+	var stderr bytes.Buffer
+	cmd := Command(python3, "-c", requirementScript, spec.requirement())
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "evaluate python requirement %s failed: %s",
+			spec.requirement(), strings.TrimSpace(stderr.String()))
+	}
+	var status requirementStatus
+	if err := json.Unmarshal(out, &status); err != nil {
+		return nil, errors.Wrap(err, "parse python requirement status failed")
+	}
+	return &status, nil
+}
+
+// assertPackageSpec checks if package installed and matched version or specifiers
+func (e *Executor) assertPackageSpec(python3 string, spec PackageSpec) error {
+	if spec.Specifier == "" {
+		return e.AssertPythonPackage(python3, spec.Name, spec.Version)
+	}
+	status, err := e.requirementStatus(python3, spec)
+	if err != nil {
+		return err
+	}
+	if !status.Satisfied {
+		return fmt.Errorf("python package %s version %q not matched %s", spec.Name, status.Version, spec.Specifier)
+	}
+	e.logger.Info("python package is ready", "name", spec.Name, "version", status.Version)
+	return nil
+}
@@ -0,0 +1,163 @@
+package myexec
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestPackageSpecString(t *testing.T) {
+	testCases := []struct {
+		spec     PackageSpec
+		expected string
+	}{
+		{PackageSpec{Name: "funppy"}, "funppy"},
+		{PackageSpec{Name: "funppy", Version: "0.5.0"}, "funppy==0.5.0"},
+		{PackageSpec{Name: "funppy", Specifier: ">=0.5,<0.6", Marker: `python_version >= "3.8"`},
+			`funppy>=0.5,<0.6; python_version >= "3.8"`},
+		{PackageSpec{Name: "funppy", Version: "0.6.0", Extras: []string{"grpc", "dev"}}, "funppy[grpc,dev]==0.6.0"},
+		{PackageSpec{Path: "./plugin", Editable: true}, "-e ./plugin"},
+		{PackageSpec{Path: "dist/funppy-0.6.0-py3-none-any.whl", Extras: []string{"grpc"}},
+			"dist/funppy-0.6.0-py3-none-any.whl[grpc]"},
+		{PackageSpec{Name: "funppy", VCSURL: "git+https://github.com/httprunner/funppy@v0.6.0"},
+			"funppy @ git+https://github.com/httprunner/funppy@v0.6.0"},
+		{PackageSpec{Name: "funppy", VCSURL: "https://example.com/funppy.whl", Marker: `os_name == "nt"`},
+			`funppy @ https://example.com/funppy.whl ; os_name == "nt"`},
+		{PackageSpec{Name: "funppy", VCSURL: "git+https://github.com/httprunner/funppy", Editable: true},
+			"-e git+https://github.com/httprunner/funppy#egg=funppy"},
+	}
+	for _, tc := range testCases {
+		if err := tc.spec.validate(); err != nil {
+			t.Fatal(err)
+		}
+		if tc.spec.String() != tc.expected {
+			t.Fatalf("expected %s, got %s", tc.expected, tc.spec.String())
+		}
+	}
+}
+
+func TestParsePackageSpec(t *testing.T) {
+	testCases := []struct {
+		pkg      string
+		expected PackageSpec
+	}{
+		{"funppy", PackageSpec{Name: "funppy"}},
+		{"funppy==0.5.0", PackageSpec{Name: "funppy", Version: "0.5.0"}},
+		{"funppy == 0.5.0", PackageSpec{Name: "funppy", Version: "0.5.0"}},
+		{"funppy==0.5.*", PackageSpec{Name: "funppy", Specifier: "==0.5.*"}},
+		{"funppy[grpc]>=0.5,<0.6", PackageSpec{Name: "funppy", Specifier: ">=0.5,<0.6", Extras: []string{"grpc"}}},
+		{"funppy [grpc, dev] (>= 0.5, < 0.6)", PackageSpec{Name: "funppy", Specifier: ">=0.5,<0.6", Extras: []string{"grpc", "dev"}}},
+		{`funppy~=0.5; python_version >= "3.8" and os_name != "nt"`,
+			PackageSpec{Name: "funppy", Specifier: "~=0.5", Marker: `python_version >= "3.8" and os_name != "nt"`}},
+		{"funppy @ git+https://github.com/httprunner/funppy@v0.6.0",
+			PackageSpec{Name: "funppy", VCSURL: "git+https://github.com/httprunner/funppy@v0.6.0"}},
+		{"zope.interface", PackageSpec{Name: "zope.interface"}},
+	}
+	for _, tc := range testCases {
+		spec, err := ParsePackageSpec(tc.pkg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprintf("%+v", spec) != fmt.Sprintf("%+v", tc.expected) {
+			t.Fatalf("parse %s: expected %+v, got %+v", tc.pkg, tc.expected, spec)
+		}
+	}
+
+	for _, pkg := range []string{"", "-e .", "funppy[grpc", "./dist/funppy.whl"} {
+		if _, err := ParsePackageSpec(pkg); err == nil {
+			t.Fatalf("expected invalid requirement error of %q", pkg)
+		}
+	}
+}
+
+func TestPackageSpecValidate(t *testing.T) {
+	testCases := []struct {
+		spec PackageSpec
+		err  string
+	}{
+		{PackageSpec{}, "package spec requires name, path or VCS url"},
+		{PackageSpec{Name: "funppy", Editable: true}, "editable install requires path or VCS url of funppy"},
+		{PackageSpec{Path: ".", VCSURL: "git+https://example.com/repo"}, "package spec path and VCS url are mutually exclusive"},
+		{PackageSpec{VCSURL: "git+https://example.com/repo"}, "package spec of VCS url git+https://example.com/repo requires name"},
+		{PackageSpec{Path: ".", Version: "1.0"}, "version only applies to index package, pin VCS url revision instead"},
+		{PackageSpec{Name: "funppy", Version: "1.0", Specifier: "<2"}, "version and specifier of funppy are mutually exclusive"},
+		{PackageSpec{Path: ".", Marker: `os_name == "nt"`}, "marker does not apply to local or editable package"},
+	}
+	for _, tc := range testCases {
+		if err := tc.spec.validate(); err == nil || err.Error() != tc.err {
+			t.Fatalf("expected error %q, got %v", tc.err, err)
+		}
+		if err := InstallPythonPackageSpec("python3", tc.spec); err == nil || err.Error() != tc.err {
+			t.Fatalf("expected install error %q, got %v", tc.err, err)
+		}
+	}
+}
+
+func TestInstallPythonPackageSpecEditable(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake python3 script requires a POSIX shell")
+	}
+	// fake python3 prints pip install arguments, and reports package installed
+	python3 := filepath.Join(t.TempDir(), "python3")
+	script := "#!/bin/sh\ncase \"$*\" in *\"pip install\"*) echo \"$@\";; esac\n"
+	if err := os.WriteFile(python3, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	e := NewExecutor(WithIndexURL("https://mirror.example.com/simple"), WithOutput(&stdout, &stdout))
+	// local package is installed even if package of the name exists
+	spec := PackageSpec{Name: "debugtalk", Path: "/src/plugin", Extras: []string{"test"}, Editable: true}
+	if err := e.InstallPythonPackageSpec(python3, spec); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout.String(), "-m pip install -e /src/plugin[test] --upgrade --index-url https://mirror.example.com/simple") {
+		t.Fatalf("unexpected pip install arguments: %q", stdout.String())
+	}
+
+	// installed index package is skipped
+	stdout.Reset()
+	if err := e.InstallPythonPackageSpec(python3, PackageSpec{Name: "funppy"}); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.Len() != 0 {
+		t.Fatalf("installed package should be skipped: %q", stdout.String())
+	}
+}
+
+func TestInstallPythonPackageSpecRequirement(t *testing.T) {
+	venv := newVenv(t)
+	info, err := VenvInfo(venv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// pip is required to evaluate markers and specifiers
+	var output bytes.Buffer
+	e := NewExecutor(WithIndexURL("https://127.0.0.1:1/simple"), WithOutput(&output, &output))
+	if err := e.ensurePip(info.Python); err != nil {
+		t.Skip("ensurepip not available")
+	}
+	distInfo := filepath.Join(info.SitePackages, "fakepkg-1.0.dist-info")
+	if err := os.MkdirAll(distInfo, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	metadata := "Metadata-Version: 2.1\nName: fakepkg\nVersion: 1.0\n"
+	if err := os.WriteFile(filepath.Join(distInfo, "METADATA"), []byte(metadata), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// installed version in range is not reinstalled, unreachable index is never requested
+	for _, pkg := range []string{"fakepkg>=0.5,<2", `notinstalled; python_version < "3"`} {
+		if err := e.InstallPythonPackage(info.Python, pkg); err != nil {
+			t.Fatalf("install %s: %v", pkg, err)
+		}
+	}
+	err = e.assertPackageSpec(info.Python, PackageSpec{Name: "fakepkg", Specifier: "<1"})
+	if err == nil || err.Error() != `python package fakepkg version "1.0" not matched <1` {
+		t.Fatalf("expected version not matched error, got %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package myexec
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestVenvPathWithSpaces covers venv paths containing spaces and non-ASCII characters
+func TestVenvPathWithSpaces(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 not found")
+	}
+	t.Setenv("PIP_AUDIT", "")
+	defer func(python string) { python3Executable = python }(python3Executable)
+
+	for _, name := range []string{"dir with space", "vénv ünïcode (x86)"} {
+		venv := filepath.Join(t.TempDir(), name, "venv")
+		python3, err := EnsurePython3Venv(venv)
+		if err != nil {
+			t.Fatalf("create venv at %s failed: %v", venv, err)
+		}
+		info, err := VenvInfo(venv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Python != python3 || !strings.HasPrefix(info.SitePackages, venv) {
+			t.Fatalf("unexpected venv info: %+v", info)
+		}
+
+		// arguments with spaces are passed as is
+		out := filepath.Join(filepath.Dir(venv), "out file.txt")
+		if err := RunCommand(python3, "-c", "import sys; open(sys.argv[1], 'w').write(sys.argv[2])",
+			out, "hello world"); err != nil {
+			t.Fatal(err)
+		}
+		if content, err := os.ReadFile(out); err != nil || string(content) != "hello world" {
+			t.Fatalf("unexpected output %q: %v", content, err)
+		}
+
+		if err := AssertPythonPackage(python3, "pip", ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := AssertPythonPackage(python3, "not-installed-package", ""); err == nil {
+			t.Fatal("expected package not found")
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package myexec
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrExternallyManaged is returned when pip refuses to modify python marked as
+// externally managed by PEP 668, e.g. system python of Debian/Ubuntu or homebrew
+var ErrExternallyManaged = errors.New("python is externally managed (PEP 668), use a venv")
+
+// externallyManagedScript checks PEP 668 marker, which does not apply inside venv
+const externallyManagedScript = `import os, sys, sysconfig
+marker = os.path.join(sysconfig.get_path("stdlib"), "EXTERNALLY-MANAGED")
+print(sys.prefix == sys.base_prefix and os.path.isfile(marker))`
+
+// isExternallyManaged checks if python3 is marked as externally managed by PEP 668
+func isExternallyManaged(python3 string) bool {
+	out, err := Command(python3, "-c", externallyManagedScript).Output()
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(string(out)) == "True"
+}
+
+// isPipExternallyManagedError checks if pip refused to install for PEP 668
+func isPipExternallyManagedError(output string) bool {
+	return strings.Contains(output, "externally-managed-environment")
+}
+
+// installIntoManagedVenv routes package install for externally managed python3
+// to the managed venv, since pip refuses to install into it
+func (e *Executor) installIntoManagedVenv(python3 string, spec PackageSpec) error {
+	venv, err := defaultVenvDir()
+	if err != nil {
+		return err
+	}
+	e.logger.Warn("python3 is externally managed (PEP 668), install package into managed venv instead",
+		"python3", python3, "package", spec.String(), "venv", venv)
+	venvPython, err := e.EnsurePython3Venv(venv)
+	if err == nil {
+		err = e.InstallPythonPackageSpec(venvPython, spec)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "install %s into managed venv %s failed", spec, venv)
+	}
+	e.logger.Warn("python package is installed into managed venv, use its python3",
+		"package", spec.String(), "python3", venvPython)
+	return nil
+}
@@ -0,0 +1,87 @@
+package myexec
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+const fakeManagedPython = `#!/bin/sh
+case "$*" in
+  *EXTERNALLY-MANAGED*) echo True; exit 0;;
+  *"pip install"*) echo "error: externally-managed-environment" >&2; exit 1;;
+esac
+exit 1
+`
+
+const fakeVenvPython = `#!/bin/sh
+case "$*" in
+  --version) echo "Python 3.11.0";;
+  *EXTERNALLY-MANAGED*) echo False;;
+esac
+exit 0
+`
+
+func TestInstallIntoManagedVenv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake python scripts require a POSIX shell")
+	}
+	python3 := filepath.Join(t.TempDir(), "python3")
+	if err := os.WriteFile(python3, []byte(fakeManagedPython), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if !isExternallyManaged(python3) {
+		t.Fatal("expected externally managed python")
+	}
+
+	// managed venv with package installed
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("PIP_AUDIT", "")
+	venvBin := filepath.Join(home, ".yf", "venv", "bin")
+	if err := os.MkdirAll(venvBin, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(venvBin, "python3"), []byte(fakeVenvPython), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	defer func(python string) { python3Executable = python }(python3Executable)
+
+	var logs bytes.Buffer
+	e := NewExecutor(WithOutput(&logs, &logs))
+	if err := e.InstallPythonPackage(python3, "funppy"); err != nil {
+		t.Fatal(err)
+	}
+	if python3Executable != filepath.Join(venvBin, "python3") {
+		t.Fatalf("expected managed venv python3 used, got %s", python3Executable)
+	}
+
+	if err := e.UninstallPythonPackage(filepath.Join(venvBin, "python3"), "funppy"); errors.Is(err, ErrExternallyManaged) {
+		t.Fatal("venv python is not externally managed")
+	}
+}
+
+func TestPipExternallyManagedError(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake python scripts require a POSIX shell")
+	}
+	python3 := filepath.Join(t.TempDir(), "python3")
+	if err := os.WriteFile(python3, []byte(fakeManagedPython), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var output bytes.Buffer
+	err := NewExecutor(WithOutput(&output, &output)).pipInstall(python3, "funppy")
+	if !errors.Is(err, ErrExternallyManaged) {
+		t.Fatalf("expected externally managed error, got %v", err)
+	}
+}
+
+func TestVenvNotExternallyManaged(t *testing.T) {
+	venv := newVenv(t)
+	if isExternallyManaged(getPython3Executable(venv)) {
+		t.Fatal("venv python should not be externally managed")
+	}
+}
@@ -0,0 +1,82 @@
+package myexec
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	pipMaxRetries   = 3               // max pip install attempts on network errors
+	pipRetryBackoff = 2 * time.Second // doubled after each failed attempt
+)
+
+// pipNetworkErrors are pip output patterns of transient network failures,
+// other failures such as resolution errors are not retried
+var pipNetworkErrors = []string{
+	"Read timed out",
+	"ReadTimeoutError",
+	"ConnectTimeoutError",
+	"NewConnectionError",
+	"ProtocolError",
+	"Connection reset by peer",
+	"Connection aborted",
+	"Connection refused",
+	"connection broken by",
+	"Temporary failure in name resolution",
+	"Name or service not known",
+	"nodename nor servname provided",
+	"ProxyError",
+	"IncompleteRead",
+	"too many 5xx error responses",
+}
+
+// pip5xxError matches 5xx responses from package index, e.g. "503 Server Error"
+var pip5xxError = regexp.MustCompile(`\b5\d\d (Server Error|Service Unavailable|Bad Gateway|Gateway Time-?out)|HTTP error 5\d\d`)
+
+// isPipNetworkError checks if pip failed with network-class errors,
+// pip prints resolution errors after its own retries are exhausted,
+// thus network patterns are checked in the whole output
+func isPipNetworkError(output string) bool {
+	for _, pattern := range pipNetworkErrors {
+		if strings.Contains(output, pattern) {
+			return true
+		}
+	}
+	return pip5xxError.MatchString(output)
+}
+
+// pipInstall runs pip install, it retries with backoff on network errors
+func (e *Executor) pipInstall(python3 string, args ...string) error {
+	args = append([]string{"-m", "pip", "install"}, args...)
+	backoff := pipRetryBackoff
+	var err error
+	for i := 1; ; i++ {
+		var output bytes.Buffer
+		cmd := e.command(python3, args...)
+		cmd.Stdout = io.MultiWriter(e.stdout, &output)
+		cmd.Stderr = io.MultiWriter(e.stderr, &output)
+		e.logger.Info("run command", "cmd", cmd.String(), "attempt", i)
+		err = e.run(cmd)
+		if err == nil {
+			return nil
+		}
+		if isPipExternallyManagedError(output.String()) {
+			return errors.Wrapf(ErrExternallyManaged, "pip install with %s refused", python3)
+		}
+		if !isPipNetworkError(output.String()) {
+			return err
+		}
+		if i >= pipMaxRetries {
+			return errors.Wrapf(err, "pip network error after %d attempts", i)
+		}
+		e.logger.Warn("pip install failed with network error, retry later",
+			"attempt", i, "backoff", backoff, "error", err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
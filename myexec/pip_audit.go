@@ -0,0 +1,133 @@
+package myexec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// PipAuditMode controls the optional pip-audit step of python venv provisioning
+type PipAuditMode string
+
+const (
+	PipAuditOff    PipAuditMode = ""       // skip pip-audit, default
+	PipAuditReport PipAuditMode = "report" // log known-vulnerable packages
+	PipAuditBlock  PipAuditMode = "block"  // fail provisioning on known-vulnerable packages
+)
+
+// PIP_AUDIT is env PIP_AUDIT at program start, env is resolved at call time
+// unless it is assigned in code, use WithPipAudit to override it per call
+var PIP_AUDIT = PipAuditMode(os.Getenv("PIP_AUDIT"))
+
+// Vulnerability is a known vulnerability of an installed python package reported by pip-audit
+type Vulnerability struct {
+	Package     string   `json:"package"`
+	Version     string   `json:"version"`
+	ID          string   `json:"id"` // e.g. PYSEC-2023-74 or GHSA-xxxx
+	Aliases     []string `json:"aliases,omitempty"`
+	FixVersions []string `json:"fix_versions,omitempty"`
+	Description string   `json:"description,omitempty"`
+}
+
+// VulnerabilityError is returned by EnsurePython3Venv in PipAuditBlock mode
+type VulnerabilityError struct {
+	Vulnerabilities []Vulnerability
+}
+
+func (e *VulnerabilityError) Error() string {
+	ids := make([]string, 0, len(e.Vulnerabilities))
+	for _, v := range e.Vulnerabilities {
+		ids = append(ids, fmt.Sprintf("%s==%s (%s)", v.Package, v.Version, v.ID))
+	}
+	return fmt.Sprintf("found %d known vulnerabilities in python packages: %s",
+		len(e.Vulnerabilities), strings.Join(ids, ", "))
+}
+
+// pipAuditReport is pip-audit json output
+type pipAuditReport struct {
+	Dependencies []struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+		Vulns   []struct {
+			ID          string   `json:"id"`
+			FixVersions []string `json:"fix_versions"`
+			Aliases     []string `json:"aliases"`
+			Description string   `json:"description"`
+		} `json:"vulns"`
+	} `json:"dependencies"`
+}
+
+// AuditPythonPackages scans packages installed for python3 with pip-audit,
+// pip-audit in $PATH is preferred, otherwise it should be installed for python3
+func AuditPythonPackages(python3 string) ([]Vulnerability, error) {
+	return NewExecutor().AuditPythonPackages(python3)
+}
+
+// AuditPythonPackages scans packages installed for python3 with pip-audit,
+// pip-audit in $PATH is preferred, otherwise it should be installed for python3
+func (e *Executor) AuditPythonPackages(python3 string) ([]Vulnerability, error) {
+	var cmd *exec.Cmd
+	args := []string{"--format", "json", "--progress-spinner", "off"}
+	if pipAudit, err := exec.LookPath("pip-audit"); err == nil {
+		cmd = Command(pipAudit, args...)
+		// audit python3 environment instead of pip-audit's own
+		cmd.Env = append(os.Environ(), "PIPAPI_PYTHON_LOCATION="+python3)
+	} else {
+		cmd = Command(python3, append([]string{"-m", "pip_audit"}, args...)...)
+	}
+	e.setNetworkEnv(cmd)
+	e.logger.Info("audit python packages", "cmd", cmd.String())
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// pip-audit exits with 1 when vulnerabilities are found
+	runErr := e.run(cmd)
+
+	var report pipAuditReport
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		if runErr != nil {
+			return nil, errors.Wrapf(runErr, "run pip-audit failed: %s", strings.TrimSpace(stderr.String()))
+		}
+		return nil, errors.Wrap(err, "parse pip-audit report failed")
+	}
+
+	var vulns []Vulnerability
+	for _, dep := range report.Dependencies {
+		for _, v := range dep.Vulns {
+			vulns = append(vulns, Vulnerability{
+				Package:     dep.Name,
+				Version:     dep.Version,
+				ID:          v.ID,
+				Aliases:     v.Aliases,
+				FixVersions: v.FixVersions,
+				Description: v.Description,
+			})
+		}
+	}
+	return vulns, nil
+}
+
+// auditVenv runs pip-audit step according to pip-audit mode
+func (e *Executor) auditVenv(python3 string) error {
+	if e.pipAudit == PipAuditOff {
+		return nil
+	}
+	vulns, err := e.AuditPythonPackages(python3)
+	if err != nil {
+		return err
+	}
+	for _, v := range vulns {
+		e.logger.Warn("found known-vulnerable python package", "package", v.Package,
+			"version", v.Version, "id", v.ID, "fixVersions", v.FixVersions)
+	}
+	if len(vulns) > 0 && e.pipAudit == PipAuditBlock {
+		return &VulnerabilityError{Vulnerabilities: vulns}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package myexec
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+const fakePipAudit = `#!/bin/sh
+[ "$PIPAPI_PYTHON_LOCATION" = "/venv/bin/python3" ] || exit 2
+cat <<'JSON'
+{"dependencies": [
+  {"name": "funppy", "version": "0.5.0", "vulns": []},
+  {"name": "requests", "version": "2.30.0", "vulns": [
+    {"id": "PYSEC-2023-74", "fix_versions": ["2.31.0"], "aliases": ["CVE-2023-32681"], "description": "leaks Proxy-Authorization header"}
+  ]}
+], "fixes": []}
+JSON
+exit 1
+`
+
+func TestAuditPythonPackages(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake pip-audit script requires a POSIX shell")
+	}
+	binDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(binDir, "pip-audit"), []byte(fakePipAudit), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	vulns, err := AuditPythonPackages("/venv/bin/python3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vulns) != 1 || vulns[0].Package != "requests" || vulns[0].ID != "PYSEC-2023-74" ||
+		vulns[0].FixVersions[0] != "2.31.0" || vulns[0].Aliases[0] != "CVE-2023-32681" {
+		t.Fatalf("unexpected vulnerabilities: %+v", vulns)
+	}
+
+	defer func() { PIP_AUDIT = PipAuditOff }()
+	PIP_AUDIT = PipAuditReport
+	if err := NewExecutor().auditVenv("/venv/bin/python3"); err != nil {
+		t.Fatal(err)
+	}
+	PIP_AUDIT = PipAuditBlock
+	err = NewExecutor().auditVenv("/venv/bin/python3")
+	var vulnErr *VulnerabilityError
+	if !errors.As(err, &vulnErr) || len(vulnErr.Vulnerabilities) != 1 {
+		t.Fatalf("expected vulnerability error, got %v", err)
+	}
+
+	// pip-audit failed without report
+	if _, err := AuditPythonPackages("/other/bin/python3"); err == nil {
+		t.Fatal("expected pip-audit error")
+	}
+}
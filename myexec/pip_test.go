@@ -0,0 +1,207 @@
+package myexec
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsPipNetworkError(t *testing.T) {
+	testData := []struct {
+		output  string
+		network bool
+	}{
+		{"WARNING: Retrying (Retry(total=4, connect=None, read=None, redirect=None, status=None)) after connection broken by 'ConnectTimeoutError(...)': /simple/funppy/\n" +
+			"ERROR: Could not find a version that satisfies the requirement funppy", true},
+		{"ERROR: HTTP error 503 while getting https://mirror/funppy.whl", true},
+		{"requests.exceptions.HTTPError: 502 Server Error: Bad Gateway for url", true},
+		{"pip._vendor.urllib3.exceptions.ReadTimeoutError: HTTPSConnectionPool(host='pypi.org', port=443): Read timed out.", true},
+		{"ERROR: Could not find a version that satisfies the requirement funppy==9.9.9\nERROR: No matching distribution found for funppy==9.9.9", false},
+		{"ERROR: Cannot install a and b because these package versions have conflicting dependencies.", false},
+		{"ERROR: HTTP error 404 while getting https://mirror/funppy.whl", false},
+	}
+	for _, td := range testData {
+		if got := isPipNetworkError(td.output); got != td.network {
+			t.Fatalf("expected network error %v, got %v for: %s", td.network, got, td.output)
+		}
+	}
+}
+
+// fakePython3 writes a python3 script failing pip install with output
+// for the first failures attempts, attempts are counted in the returned file
+func fakePython3(t *testing.T, failures int, output string) (python3, counter string) {
+	dir := t.TempDir()
+	python3 = filepath.Join(dir, "python3")
+	counter = filepath.Join(dir, "attempts")
+	script := "#!/bin/sh\necho x >> " + counter + "\n" +
+		"[ $(wc -l < " + counter + ") -gt " + strconv.Itoa(failures) + " ] && exit 0\n" +
+		"echo '" + output + "' >&2\nexit 1\n"
+	if err := os.WriteFile(python3, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return python3, counter
+}
+
+func attempts(t *testing.T, counter string) int {
+	content, err := os.ReadFile(counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(content), "\n")
+}
+
+func TestPipInstallRetry(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake python3 script requires a POSIX shell")
+	}
+	pipRetryBackoff = 10 * time.Millisecond
+	defer func() { pipRetryBackoff = 2 * time.Second }()
+
+	// recovered after transient network errors
+	python3, counter := fakePython3(t, 2, "ERROR: HTTP error 503 while getting https://mirror/funppy.whl")
+	if err := NewExecutor().pipInstall(python3, "funppy"); err != nil {
+		t.Fatal(err)
+	}
+	if n := attempts(t, counter); n != 3 {
+		t.Fatalf("expected 3 attempts, got %d", n)
+	}
+
+	// network errors exceeding max retries
+	python3, counter = fakePython3(t, 5, "Read timed out.")
+	if err := NewExecutor().pipInstall(python3, "funppy"); err == nil ||
+		!strings.Contains(err.Error(), "pip network error after 3 attempts") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := attempts(t, counter); n != 3 {
+		t.Fatalf("expected 3 attempts, got %d", n)
+	}
+
+	// resolution errors are not retried
+	python3, counter = fakePython3(t, 5, "ERROR: No matching distribution found for funppy==9.9.9")
+	if err := NewExecutor().pipInstall(python3, "funppy==9.9.9"); err == nil {
+		t.Fatal("expected resolution error")
+	}
+	if n := attempts(t, counter); n != 1 {
+		t.Fatalf("expected 1 attempt, got %d", n)
+	}
+}
+
+// disableEnsurepip shadows ensurepip module with one failing like distributions removing it
+func disableEnsurepip(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "ensurepip")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "__main__.py"), []byte("raise SystemExit('ensurepip disabled')\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "__init__.py"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PYTHONPATH", filepath.Dir(dir))
+}
+
+func TestInstallPipEnsurepip(t *testing.T) {
+	venv := newVenv(t)
+	python3 := getPython3Executable(venv)
+	if err := exec.Command(python3, "-c", "import ensurepip").Run(); err != nil {
+		t.Skip("ensurepip not available")
+	}
+	// ensurepip installs bundled pip without network, get-pip.py url is never reached
+	t.Setenv("GET_PIP_URL", "https://127.0.0.1:1/get-pip.py")
+	var output bytes.Buffer
+	e := NewExecutor(WithOutput(&output, &output))
+	if err := e.InstallPip(python3); err != nil {
+		t.Fatal(err)
+	}
+
+	packages, err := e.ListPythonPackages(python3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pkg := range packages {
+		if pkg.Name == "pip" && pkg.Version != "" {
+			return
+		}
+	}
+	t.Fatalf("pip not listed: %+v", packages)
+}
+
+func TestListPythonPackages(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake python3 script requires a POSIX shell")
+	}
+	// fake python3 prints pip list json
+	python3 := filepath.Join(t.TempDir(), "python3")
+	script := `#!/bin/sh
+echo '[{"name": "funppy", "version": "0.6.0"}, {"name": "demo", "version": "0.1", "editable_project_location": "/src/demo"}]'
+`
+	if err := os.WriteFile(python3, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	packages, err := ListPythonPackages(python3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []PackageInfo{
+		{Name: "funppy", Version: "0.6.0"},
+		{Name: "demo", Version: "0.1", EditableProjectLocation: "/src/demo"},
+	}
+	if fmt.Sprint(packages) != fmt.Sprint(expected) {
+		t.Fatalf("unexpected packages: %+v", packages)
+	}
+
+	// pip not available
+	script = "#!/bin/sh\necho 'No module named pip' >&2\nexit 1\n"
+	if err := os.WriteFile(python3, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ListPythonPackages(python3); err == nil || !strings.Contains(err.Error(), "No module named pip") {
+		t.Fatalf("expected pip list error, got %v", err)
+	}
+}
+
+func TestInstallPipGetPipFile(t *testing.T) {
+	venv := newVenv(t)
+	python3 := getPython3Executable(venv)
+	disableEnsurepip(t)
+
+	// fake get-pip.py writes its arguments instead of installing pip
+	dir := t.TempDir()
+	getPip := filepath.Join(dir, "get-pip.py")
+	argsFile := filepath.Join(dir, "args")
+	script := fmt.Sprintf("import sys\nopen(%q, 'w').write(' '.join(sys.argv[1:]))\n", argsFile)
+	if err := os.WriteFile(getPip, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GET_PIP_URL", "https://127.0.0.1:1/get-pip.py")
+
+	var output bytes.Buffer
+	e := NewExecutor(WithGetPipFile(getPip), WithOutput(&output, &output))
+	// fake get-pip.py does not install pip
+	if err := e.InstallPip(python3); err == nil || !strings.Contains(err.Error(), "pip安装成功但验证失败") {
+		t.Fatalf("expected pip verification error, got %v", err)
+	}
+	if args, err := os.ReadFile(argsFile); err != nil || string(args) != "" {
+		t.Fatalf("unexpected get-pip.py arguments %q: %v", args, err)
+	}
+
+	// wheels next to get-pip.py are installed offline, env GET_PIP_FILE is default
+	if err := os.WriteFile(filepath.Join(dir, "pip-24.0-py3-none-any.whl"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GET_PIP_FILE", getPip)
+	if err := NewExecutor(WithOutput(&output, &output)).InstallPip(python3); err == nil {
+		t.Fatal("expected pip verification error")
+	}
+	if args, err := os.ReadFile(argsFile); err != nil || string(args) != "--no-index --find-links "+dir {
+		t.Fatalf("unexpected get-pip.py arguments %q: %v", args, err)
+	}
+}
@@ -0,0 +1,105 @@
+package myexec
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// pythonVersionPattern matches requested python version, e.g. 3.11 or 3.11.4
+var pythonVersionPattern = regexp.MustCompile(`^3\.\d+(\.\d+)?$`)
+
+// pythonInfoScript prints python version and interpreter path
+const pythonInfoScript = "import platform, sys; print(platform.python_version()); print(sys.executable)"
+
+// pythonInfo returns version and interpreter path of python command,
+// the command may be a launcher with arguments, e.g. py -3.11 on windows
+func pythonInfo(name string, args ...string) (version, executable string, err error) {
+	args = append(args, "-c", pythonInfoScript)
+	out, err := Command(name, args...).Output()
+	if err != nil {
+		return "", "", err
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 2 {
+		return "", "", fmt.Errorf("unexpected python info: %s", out)
+	}
+	return strings.TrimSpace(lines[0]), strings.TrimSpace(lines[1]), nil
+}
+
+// matchPythonVersion checks if version matches requested minor or patch version
+func matchPythonVersion(version, requested string) bool {
+	return version == requested || strings.HasPrefix(version, requested+".")
+}
+
+// isVenvPython checks if venv python is available and matches requested version
+func (e *Executor) isVenvPython(python3 string) bool {
+	if e.pythonVersion == "" {
+		return isPython3(python3)
+	}
+	version, _, err := pythonInfo(python3)
+	if err != nil {
+		return false
+	}
+	if !matchPythonVersion(version, e.pythonVersion) {
+		e.logger.Warn("venv python version not matched, recreate venv",
+			"python3", python3, "version", version, "requested", e.pythonVersion)
+		return false
+	}
+	return true
+}
+
+// findPython returns interpreter of requested python version, candidates are
+// pythonX.Y, python3 and python in $PATH, and py launcher on windows.
+// If none matches and uv is available, a standalone python build is downloaded
+// with uv python install.
+func (e *Executor) findPython(version string) (string, error) {
+	if !pythonVersionPattern.MatchString(version) {
+		return "", fmt.Errorf("invalid python version %s, expect 3.X or 3.X.Y", version)
+	}
+	minor := strings.Join(strings.Split(version, ".")[:2], ".")
+
+	candidates := [][]string{{"python" + minor}, {"python3"}, {"python"}}
+	if runtime.GOOS == "windows" {
+		candidates = append(candidates, []string{"py", "-" + minor})
+	}
+	for _, candidate := range candidates {
+		if _, err := exec.LookPath(candidate[0]); err != nil {
+			continue
+		}
+		found, executable, err := pythonInfo(candidate[0], candidate[1:]...)
+		if err != nil {
+			continue
+		}
+		if matchPythonVersion(found, version) {
+			e.logger.Info("found python", "version", found, "python", executable)
+			return executable, nil
+		}
+	}
+
+	// download standalone python build with uv
+	if _, err := exec.LookPath("uv"); err != nil {
+		return "", fmt.Errorf("python %s not found, install it or uv to download it", version)
+	}
+	e.logger.Info("python not found, install standalone build with uv", "version", version)
+	var out strings.Builder
+	cmd := e.command("uv", "python", "install", version)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := e.run(cmd); err != nil {
+		return "", errors.Wrapf(err, "uv python install %s failed: %s", version, strings.TrimSpace(out.String()))
+	}
+	path, err := Command("uv", "python", "find", version).Output()
+	if err != nil {
+		return "", errors.Wrapf(err, "uv python find %s failed", version)
+	}
+	executable := strings.TrimSpace(string(path))
+	if found, _, err := pythonInfo(executable); err != nil || !matchPythonVersion(found, version) {
+		return "", fmt.Errorf("python %s installed by uv not matched: %s", version, executable)
+	}
+	return executable, nil
+}
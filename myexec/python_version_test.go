@@ -0,0 +1,82 @@
+package myexec
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// writeFakePython writes a fake interpreter printing version and its path
+func writeFakePython(t *testing.T, path, version string) {
+	script := "#!/bin/sh\necho " + version + "\necho " + path + "\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindPython(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake python scripts require a POSIX shell")
+	}
+	binDir := t.TempDir()
+	t.Setenv("PATH", binDir)
+	writeFakePython(t, filepath.Join(binDir, "python3.11"), "3.11.9")
+	writeFakePython(t, filepath.Join(binDir, "python3"), "3.9.2")
+
+	e := NewExecutor()
+	for version, expected := range map[string]string{
+		"3.11":   filepath.Join(binDir, "python3.11"),
+		"3.11.9": filepath.Join(binDir, "python3.11"),
+		"3.9":    filepath.Join(binDir, "python3"),
+	} {
+		python, err := e.findPython(version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if python != expected {
+			t.Fatalf("expected %s for python %s, got %s", expected, version, python)
+		}
+	}
+
+	if _, err := e.findPython("3.12"); err == nil ||
+		!strings.Contains(err.Error(), "python 3.12 not found, install it or uv to download it") {
+		t.Fatalf("expected python not found error, got %v", err)
+	}
+	if _, err := e.findPython("3"); err == nil || !strings.Contains(err.Error(), "invalid python version") {
+		t.Fatalf("expected invalid version error, got %v", err)
+	}
+
+	// standalone build downloaded by uv
+	uvPython := filepath.Join(binDir, "uv-python3.12")
+	uv := "#!/bin/sh\n" +
+		"if [ \"$2\" = install ]; then printf '#!/bin/sh\\necho 3.12.1\\necho " + uvPython + "\\n' > " + uvPython +
+		" && /bin/chmod +x " + uvPython + "; exit 0; fi\n" +
+		"[ -x " + uvPython + " ] && echo " + uvPython + "\n"
+	if err := os.WriteFile(filepath.Join(binDir, "uv"), []byte(uv), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	python, err := e.findPython("3.12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if python != uvPython {
+		t.Fatalf("expected python installed by uv, got %s", python)
+	}
+}
+
+func TestIsVenvPythonVersion(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake python scripts require a POSIX shell")
+	}
+	python3 := filepath.Join(t.TempDir(), "python3")
+	writeFakePython(t, python3, "3.9.2")
+
+	if !NewExecutor(WithPythonVersion("3.9")).isVenvPython(python3) {
+		t.Fatal("expected venv python 3.9 matched")
+	}
+	if NewExecutor(WithPythonVersion("3.11")).isVenvPython(python3) {
+		t.Fatal("expected venv python 3.9 recreated for 3.11")
+	}
+}
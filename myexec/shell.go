@@ -0,0 +1,60 @@
+package myexec
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"os/exec"
+	"strings"
+	"unicode/utf16"
+)
+
+// powershellPrelude makes PowerShell write UTF-8 output to pipes instead of
+// the console code page, and resets exit code of previous native commands
+const powershellPrelude = `[Console]::OutputEncoding = [System.Text.Encoding]::UTF8
+$OutputEncoding = [System.Text.Encoding]::UTF8
+$global:LASTEXITCODE = 0
+`
+
+// powershellEpilogue propagates exit code of the last native command,
+// or 1 if the last statement failed, since -Command only exits with 0 or 1
+const powershellEpilogue = `
+if (-not $?) { if ($LASTEXITCODE) { exit $LASTEXITCODE } else { exit 1 } }
+exit $LASTEXITCODE
+`
+
+// shellCommand returns command running shell string with the shell specified by
+// WithShell, bash on unix or cmd on windows by default
+func (e *Executor) shellCommand(shellString string) *exec.Cmd {
+	if e.shell == "" {
+		return initShellExec(shellString)
+	}
+	switch shellName(e.shell) {
+	case "pwsh", "powershell":
+		// encoded command keeps quotes and non-ASCII characters intact
+		script := powershellPrelude + shellString + powershellEpilogue
+		return exec.Command(e.shell, "-NoProfile", "-NonInteractive",
+			"-EncodedCommand", encodePowerShell(script))
+	case "cmd":
+		return exec.Command(e.shell, "/C", shellString)
+	default:
+		// POSIX shells, e.g. sh, bash or zsh
+		return exec.Command(e.shell, "-c", shellString)
+	}
+}
+
+// shellName returns lower case shell name without directory and .exe suffix,
+// windows separators are handled on all platforms
+func shellName(shell string) string {
+	name := strings.ToLower(shell[strings.LastIndexAny(shell, `/\`)+1:])
+	return strings.TrimSuffix(name, ".exe")
+}
+
+// encodePowerShell encodes script for -EncodedCommand as base64 of UTF-16LE
+func encodePowerShell(script string) string {
+	codes := utf16.Encode([]rune(script))
+	buf := make([]byte, len(codes)*2)
+	for i, code := range codes {
+		binary.LittleEndian.PutUint16(buf[i*2:], code)
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
@@ -0,0 +1,80 @@
+package myexec
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestPowerShellCommand(t *testing.T) {
+	cmd := NewExecutor(WithShell("pwsh")).shellCommand(`Write-Output "héllo 'world'"; exit 3`)
+	if len(cmd.Args) != 5 || cmd.Args[1] != "-NoProfile" || cmd.Args[3] != "-EncodedCommand" {
+		t.Fatalf("unexpected pwsh args: %v", cmd.Args)
+	}
+	raw, err := base64.StdEncoding.DecodeString(cmd.Args[4])
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes := make([]uint16, len(raw)/2)
+	for i := range codes {
+		codes[i] = uint16(raw[2*i]) | uint16(raw[2*i+1])<<8
+	}
+	script := string(utf16.Decode(codes))
+	if !strings.Contains(script, `Write-Output "héllo 'world'"; exit 3`) ||
+		!strings.Contains(script, "[Console]::OutputEncoding = [System.Text.Encoding]::UTF8") ||
+		!strings.HasSuffix(script, "exit $LASTEXITCODE\n") {
+		t.Fatalf("unexpected pwsh script: %s", script)
+	}
+
+	if cmd := NewExecutor(WithShell(`C:\Windows\System32\cmd.exe`)).shellCommand("echo hi"); cmd.Args[1] != "/C" {
+		t.Fatalf("unexpected cmd args: %v", cmd.Args)
+	}
+}
+
+func TestRunShellWithShell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available on windows")
+	}
+	var stdout bytes.Buffer
+	exitCode, err := NewExecutor(WithShell("sh"), WithOutput(&stdout, &stdout)).RunShell("echo $0; exit 4")
+	if exitCode != 4 || err == nil {
+		t.Fatalf("expected exit code 4, got %d: %v", exitCode, err)
+	}
+	if strings.TrimSpace(stdout.String()) != "sh" {
+		t.Fatalf("expected shell string run by sh, got %q", stdout.String())
+	}
+}
+
+func TestRunShellPowerShell(t *testing.T) {
+	pwsh, err := exec.LookPath("pwsh")
+	if err != nil {
+		t.Skip("pwsh not found")
+	}
+	var stdout bytes.Buffer
+	e := NewExecutor(WithShell(pwsh), WithOutput(&stdout, &stdout))
+	if _, err := e.RunShell(`Write-Output "héllo wörld"`); err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(stdout.String()) != "héllo wörld" {
+		t.Fatalf("unexpected UTF-8 output: %q", stdout.String())
+	}
+
+	// exit code of native command is propagated
+	native := "sh -c 'exit 5'"
+	if runtime.GOOS == "windows" {
+		native = "cmd /c exit 5"
+	}
+	if exitCode, _ := e.RunShell(native); exitCode != 5 {
+		t.Fatalf("expected exit code 5, got %d", exitCode)
+	}
+	if exitCode, _ := e.RunShell("exit 3"); exitCode != 3 {
+		t.Fatalf("expected exit code 3, got %d", exitCode)
+	}
+	if exitCode, _ := e.RunShell("Get-Item ./not-exist"); exitCode != 1 {
+		t.Fatalf("expected exit code 1, got %d", exitCode)
+	}
+}
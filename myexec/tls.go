@@ -0,0 +1,81 @@
+package myexec
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// getPipScript downloads and runs get-pip.py with url, CA bundle and insecure flag
+// passed in argv, certificates are verified against system roots unless insecure
+const getPipScript = `import ssl, sys, urllib.request
+url, cafile, insecure = sys.argv[1], sys.argv[2], sys.argv[3] == "1"
+if insecure:
+    context = ssl._create_unverified_context()
+else:
+    context = ssl.create_default_context()
+    if cafile:
+        context.load_verify_locations(cafile=cafile)
+try:
+    with urllib.request.urlopen(url, context=context) as response:
+        script = response.read()
+except Exception as e:
+    print(f"download get-pip.py from {url} failed: {e}", file=sys.stderr)
+    sys.exit(1)
+sys.argv = ["get-pip.py"]
+exec(compile(script, "get-pip.py", "exec"))
+`
+
+// trustedHosts returns hosts of package index and pypi.org skipping TLS verification
+func (e *Executor) trustedHosts() []string {
+	hosts := []string{"pypi.org", "files.pythonhosted.org"}
+	if u, err := url.Parse(e.indexURL); err == nil && u.Hostname() != "" && u.Hostname() != "pypi.org" {
+		hosts = append(hosts, u.Hostname())
+	}
+	return hosts
+}
+
+// setTLSEnv passes CA bundle and trusted hosts to pip, pip-audit and uv
+func (e *Executor) setTLSEnv(cmd *exec.Cmd) {
+	if e.caBundle == "" && !e.insecureSkipVerify {
+		return
+	}
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	if e.caBundle != "" {
+		cmd.Env = append(cmd.Env,
+			"PIP_CERT="+e.caBundle, "REQUESTS_CA_BUNDLE="+e.caBundle, "SSL_CERT_FILE="+e.caBundle)
+	}
+	if e.insecureSkipVerify {
+		hosts := strings.Join(e.trustedHosts(), " ")
+		cmd.Env = append(cmd.Env, "PIP_TRUSTED_HOST="+hosts, "UV_INSECURE_HOST="+hosts)
+	}
+}
+
+// tlsConfig returns TLS config of package index requests,
+// CA bundle is trusted in addition to system roots
+func (e *Executor) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: e.insecureSkipVerify}
+	if e.caBundle == "" {
+		return config, nil
+	}
+	pem, err := os.ReadFile(e.caBundle)
+	if err != nil {
+		return nil, errors.Wrap(err, "read CA bundle failed")
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.Errorf("no PEM certificates found in CA bundle %s", e.caBundle)
+	}
+	config.RootCAs = pool
+	return config, nil
+}
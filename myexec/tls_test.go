@@ -0,0 +1,126 @@
+package myexec
+
+import (
+	"bytes"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// newTLSServer serves body over TLS and returns its url and CA bundle
+func newTLSServer(t *testing.T, body string) (string, string) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+	caBundle := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caBundle, cert, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return server.URL, caBundle
+}
+
+func TestExecutorHTTPClientTLS(t *testing.T) {
+	url, caBundle := newTLSServer(t, "ok")
+
+	for _, e := range []*Executor{NewExecutor(WithCABundle(caBundle)), NewExecutor(WithInsecureSkipVerify())} {
+		client, err := e.httpClient()
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	// certificates are verified by default
+	client, err := NewExecutor().httpClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get(url); err == nil {
+		t.Fatal("expected certificate verification error")
+	}
+
+	invalid := filepath.Join(t.TempDir(), "invalid.pem")
+	if err := os.WriteFile(invalid, []byte("not a certificate"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewExecutor(WithCABundle(invalid)).httpClient(); err == nil ||
+		!strings.Contains(err.Error(), "no PEM certificates found in CA bundle") {
+		t.Fatalf("expected invalid CA bundle error, got %v", err)
+	}
+}
+
+func TestExecutorPipTLSEnv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake python3 script requires a POSIX shell")
+	}
+	// fake python3 prints TLS env
+	python3 := filepath.Join(t.TempDir(), "python3")
+	script := "#!/bin/sh\necho \"cert=$PIP_CERT\"\necho \"trusted=$PIP_TRUSTED_HOST\"\n"
+	if err := os.WriteFile(python3, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	e := NewExecutor(WithCABundle("/etc/corp-ca.pem"), WithOutput(&stdout, &stdout))
+	if err := e.pipInstall(python3, "funppy"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout.String(), "cert=/etc/corp-ca.pem\ntrusted=\n") {
+		t.Fatalf("CA bundle not passed to pip: %q", stdout.String())
+	}
+
+	stdout.Reset()
+	e = NewExecutor(WithIndexURL("https://mirror.example.com/simple"),
+		WithInsecureSkipVerify(), WithOutput(&stdout, &stdout))
+	if err := e.pipInstall(python3, "funppy"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout.String(), "trusted=pypi.org files.pythonhosted.org mirror.example.com") {
+		t.Fatalf("trusted hosts not passed to pip: %q", stdout.String())
+	}
+}
+
+func TestInstallPipVerifiesCertificate(t *testing.T) {
+	venv := newVenv(t)
+	python3 := getPython3Executable(venv)
+	disableEnsurepip(t)
+	// fake get-pip.py writes marker instead of installing pip
+	marker := filepath.Join(t.TempDir(), "get-pip-ran")
+	url, caBundle := newTLSServer(t, fmt.Sprintf("open(%q, 'w').close()\n", marker))
+	t.Setenv("GET_PIP_URL", url)
+
+	var stderr bytes.Buffer
+	err := NewExecutor(WithOutput(&stderr, &stderr)).InstallPip(python3)
+	if err == nil || !strings.Contains(err.Error(), "CERTIFICATE_VERIFY_FAILED") {
+		t.Fatalf("expected certificate verification error, got %v", err)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("get-pip.py should not run with unverified certificate")
+	}
+
+	for _, e := range []*Executor{
+		NewExecutor(WithCABundle(caBundle), WithOutput(&stderr, &stderr)),
+		NewExecutor(WithInsecureSkipVerify(), WithOutput(&stderr, &stderr)),
+	} {
+		os.Remove(marker)
+		// fake get-pip.py does not install pip
+		if err := e.InstallPip(python3); err == nil {
+			t.Fatal("expected pip verification error")
+		}
+		if _, err := os.Stat(marker); err != nil {
+			t.Fatalf("get-pip.py not run: %v", err)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package myexec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// venvInfoScript prints venv layout reported by its interpreter
+const venvInfoScript = `import json, platform, sysconfig
+paths = sysconfig.get_paths()
+print(json.dumps({"version": platform.python_version(), "purelib": paths["purelib"], "scripts": paths["scripts"]}))`
+
+// Venv is layout of a python venv returned by VenvInfo
+type Venv struct {
+	Path         string // venv directory
+	Python       string // interpreter path
+	Version      string // python version, e.g. 3.11.4
+	SitePackages string // site-packages directory of installed packages
+	ScriptsDir   string // bin on unix or Scripts on windows where console scripts are installed
+}
+
+// VenvInfo returns interpreter, version, site-packages and scripts dir of python venv,
+// e.g. to locate console scripts such as locust installed into the venv
+func VenvInfo(venvPath string) (*Venv, error) {
+	venvPath, err := filepath.Abs(venvPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "get venv absolute path failed")
+	}
+	if _, err := os.Stat(filepath.Join(venvPath, "pyvenv.cfg")); err != nil {
+		return nil, errors.Wrapf(err, "%s is not a python venv", venvPath)
+	}
+
+	python := getPython3Executable(venvPath)
+	out, err := Command(python, "-c", venvInfoScript).Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "query venv python %s failed", python)
+	}
+	var paths struct {
+		Version string `json:"version"`
+		Purelib string `json:"purelib"`
+		Scripts string `json:"scripts"`
+	}
+	if err := json.Unmarshal(out, &paths); err != nil {
+		return nil, errors.Wrap(err, "parse venv info failed")
+	}
+
+	return &Venv{
+		Path:         venvPath,
+		Python:       python,
+		Version:      paths.Version,
+		SitePackages: paths.Purelib,
+		ScriptsDir:   paths.Scripts,
+	}, nil
+}
+
+// ExecVenvScript runs console script installed into venv, e.g. locust or har2case,
+// and returns its combined stdout and stderr output
+func ExecVenvScript(venv, scriptName string, args ...string) (output string, err error) {
+	return NewExecutor().ExecVenvScript(venv, scriptName, args...)
+}
+
+// ExecVenvScript runs console script installed into venv, e.g. locust or har2case,
+// and returns its combined stdout and stderr output
+func (e *Executor) ExecVenvScript(venv, scriptName string, args ...string) (output string, err error) {
+	venv, err = filepath.Abs(venv)
+	if err != nil {
+		return "", errors.Wrap(err, "get venv absolute path failed")
+	}
+	script, err := findVenvScript(venv, scriptName)
+	if err != nil {
+		return "", err
+	}
+
+	cmd := e.command(script, args...)
+	// run as if venv is activated, thus subprocesses of script use venv python
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env,
+		"VIRTUAL_ENV="+venv,
+		"PATH="+prependPath(os.Getenv("PATH"), filepath.Dir(script)))
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	e.logger.Info("exec venv script", "cmd", cmd.String())
+
+	if err := e.run(cmd); err != nil {
+		e.logger.Error("exec venv script failed", "script", scriptName, "error", err)
+		return out.String(), errors.Wrapf(err, "exec venv script %s failed: %s",
+			scriptName, strings.TrimSpace(out.String()))
+	}
+	return out.String(), nil
+}
+
+// findVenvScript resolves console script in venv bin/Scripts dir
+func findVenvScript(venv, scriptName string) (string, error) {
+	for _, script := range venvScriptCandidates(venv, scriptName) {
+		if info, err := os.Stat(script); err == nil && !info.IsDir() {
+			return script, nil
+		}
+	}
+	return "", fmt.Errorf("script %s not found in venv %s", scriptName, venv)
+}
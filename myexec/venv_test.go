@@ -0,0 +1,84 @@
+package myexec
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// newVenv creates a python venv without pip for tests
+func newVenv(t *testing.T) string {
+	python3, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not found")
+	}
+	venv := filepath.Join(t.TempDir(), "venv")
+	if out, err := exec.Command(python3, "-m", "venv", "--without-pip", venv).CombinedOutput(); err != nil {
+		t.Skipf("create venv failed: %s", out)
+	}
+	return venv
+}
+
+func TestVenvInfo(t *testing.T) {
+	venv := newVenv(t)
+	info, err := VenvInfo(venv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scriptsDir := "bin"
+	if runtime.GOOS == "windows" {
+		scriptsDir = "Scripts"
+	}
+	if info.ScriptsDir != filepath.Join(venv, scriptsDir) {
+		t.Fatalf("unexpected scripts dir: %s", info.ScriptsDir)
+	}
+	if filepath.Dir(info.Python) != info.ScriptsDir {
+		t.Fatalf("unexpected python: %s", info.Python)
+	}
+	if !strings.HasPrefix(info.SitePackages, venv) || filepath.Base(info.SitePackages) != "site-packages" {
+		t.Fatalf("unexpected site-packages: %s", info.SitePackages)
+	}
+	if !strings.HasPrefix(info.Version, "3.") {
+		t.Fatalf("unexpected version: %s", info.Version)
+	}
+
+	if _, err := VenvInfo(t.TempDir()); err == nil || !strings.Contains(err.Error(), "is not a python venv") {
+		t.Fatalf("expected not a venv error, got %v", err)
+	}
+}
+
+func TestExecVenvScript(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake console script requires a POSIX shell")
+	}
+	venv := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(venv, "bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	script := "#!/bin/sh\necho \"args: $@\"\necho \"venv: $VIRTUAL_ENV\"\n[ \"$1\" = fail ] && echo boom >&2 && exit 3\nexit 0\n"
+	if err := os.WriteFile(filepath.Join(venv, "bin", "har2case"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := ExecVenvScript(venv, "har2case", "demo.har", "-2y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "args: demo.har -2y") || !strings.Contains(output, "venv: "+venv) {
+		t.Fatalf("unexpected output: %q", output)
+	}
+
+	output, err = ExecVenvScript(venv, "har2case", "fail")
+	if err == nil || !strings.Contains(err.Error(), "boom") || !strings.Contains(output, "boom") {
+		t.Fatalf("expected script error with output, got %v", err)
+	}
+
+	if _, err := ExecVenvScript(venv, "locust"); err == nil ||
+		!strings.Contains(err.Error(), "script locust not found in venv") {
+		t.Fatalf("expected script not found error, got %v", err)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package funplugin
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+)
+
+func listenPipe(path string) (net.Listener, error) {
+	return nil, fmt.Errorf("named pipe transport is not supported on %s", runtime.GOOS)
+}
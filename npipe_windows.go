@@ -0,0 +1,14 @@
+//go:build windows
+
+package funplugin
+
+import (
+	"net"
+
+	"github.com/Microsoft/go-winio"
+)
+
+// listenPipe creates windows named pipe, e.g. \\.\pipe\funplugin-1234
+func listenPipe(path string) (net.Listener, error) {
+	return winio.ListenPipe(path, nil)
+}
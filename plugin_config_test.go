@@ -0,0 +1,64 @@
+package funplugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPluginConfigGoPlugin(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	config := map[string]interface{}{
+		"base_url": "https://httpbin.org",
+		"retries":  3,
+		"headers":  map[string]interface{}{"X-Env": "staging"},
+	}
+	for _, transport := range []string{"", "stdio"} {
+		plugin, err := Init(pluginBinPath, WithTransport(transport), WithPluginConfig(config))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		value, err := plugin.Call("plugin_config", "base_url")
+		assert.Nil(t, err)
+		assert.Equal(t, "https://httpbin.org", value)
+		value, err = plugin.Call("plugin_config", "retries")
+		assert.Nil(t, err)
+		assert.Equal(t, float64(3), value)
+		value, err = plugin.Call("plugin_config", "headers")
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]interface{}{"X-Env": "staging"}, value)
+		_, err = plugin.Call("plugin_config", "missing")
+		assert.Error(t, err)
+		plugin.Quit()
+	}
+}
+
+func TestPluginConfigWithSecretsGoPlugin(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	// secrets and plugin config are passed through separate pipes
+	plugin, err := Init(pluginBinPath,
+		WithSecrets(map[string]string{"api_token": "tok-7f3a9c"}),
+		WithPluginConfig(map[string]interface{}{"region": "eu-west-1"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	header, err := plugin.Call("auth_header", "api_token")
+	assert.Nil(t, err)
+	assert.Equal(t, "Bearer tok-7f3a9c", header)
+	value, err := plugin.Call("plugin_config", "region")
+	assert.Nil(t, err)
+	assert.Equal(t, "eu-west-1", value)
+}
+
+func TestPluginConfigUnsupported(t *testing.T) {
+	_, err := Init("lua/examples/debugtalk.lua", WithPluginConfig(map[string]interface{}{"k": "v"}))
+	assert.EqualError(t, err,
+		"WithPluginConfig only applies to local .bin/.py plugin processes, got lua/examples/debugtalk.lua")
+}
@@ -0,0 +1,242 @@
+package funplugin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+// kinds of FuncChange
+const (
+	FuncRemoved = "removed"
+	FuncAdded   = "added"
+	FuncChanged = "changed"
+)
+
+// FuncChange is change of a plugin function between two plugin versions
+type FuncChange struct {
+	Name     string   `json:"name"`
+	Kind     string   `json:"kind"`     // FuncRemoved, FuncAdded or FuncChanged
+	Breaking bool     `json:"breaking"` // hosts calling the function of old version may fail
+	Details  []string `json:"details,omitempty"`
+}
+
+// PluginDiff is difference of functions between two plugin versions
+type PluginDiff struct {
+	Old     fungo.PluginInfo `json:"old"`
+	New     fungo.PluginInfo `json:"new"`
+	Changes []FuncChange     `json:"changes"` // sorted by function name
+}
+
+// Breaking reports whether any function is removed or changed incompatibly
+func (d *PluginDiff) Breaking() bool {
+	for _, c := range d.Changes {
+		if c.Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+// Report formats changes one per line, breaking ones are marked with !
+func (d *PluginDiff) Report() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "plugin diff %s -> %s: %d changes\n",
+		versionName(d.Old), versionName(d.New), len(d.Changes))
+	for _, c := range d.Changes {
+		mark := " "
+		if c.Breaking {
+			mark = "!"
+		}
+		fmt.Fprintf(&b, "%s %-7s %s", mark, c.Kind, c.Name)
+		if len(c.Details) > 0 {
+			fmt.Fprintf(&b, ": %s", strings.Join(c.Details, "; "))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func versionName(info fungo.PluginInfo) string {
+	switch {
+	case info.Version != "":
+		return info.Version
+	case info.Commit != "":
+		return info.Commit
+	}
+	return "unknown"
+}
+
+// DiffPlugins loads two plugin artifacts, e.g. the deployed and the new debugtalk.bin,
+// and compares their functions and signatures to catch accidental removals and renames
+// before rolling out the new version. Options are applied to both plugins; go plugins
+// (.so) are not supported since they can not list functions.
+func DiffPlugins(oldPath, newPath string, options ...Option) (*PluginDiff, error) {
+	oldSpecs, oldInfo, err := describePlugin(oldPath, options)
+	if err != nil {
+		return nil, err
+	}
+	newSpecs, newInfo, err := describePlugin(newPath, options)
+	if err != nil {
+		return nil, err
+	}
+	return &PluginDiff{
+		Old:     oldInfo,
+		New:     newInfo,
+		Changes: DiffFuncSpecs(oldSpecs, newSpecs),
+	}, nil
+}
+
+func describePlugin(path string, options []Option) (map[string]fungo.FuncSpec, fungo.PluginInfo, error) {
+	plugin, err := Init(path, options...)
+	if err != nil {
+		return nil, fungo.PluginInfo{}, errors.Wrapf(err, "init plugin %s failed", path)
+	}
+	defer plugin.Quit()
+	specs, err := plugin.Describe()
+	if err != nil {
+		return nil, fungo.PluginInfo{}, errors.Wrapf(err, "describe plugin %s failed", path)
+	}
+	return specs, plugin.Info(), nil
+}
+
+// DiffFuncSpecs compares function signatures of two plugin versions returned by
+// IPlugin.Describe. Removed functions and changes requiring callers to pass more
+// arguments, accepting fewer arguments, or changing parameter or result types are
+// breaking; functions whose signature is unknown are compared by name only.
+func DiffFuncSpecs(oldSpecs, newSpecs map[string]fungo.FuncSpec) []FuncChange {
+	var removed, added []string
+	changes := []FuncChange{}
+	for name, oldSpec := range oldSpecs {
+		newSpec, ok := newSpecs[name]
+		if !ok {
+			removed = append(removed, name)
+			continue
+		}
+		details, breaking := specChanges(oldSpec, newSpec)
+		if len(details) > 0 {
+			changes = append(changes, FuncChange{
+				Name: name, Kind: FuncChanged, Breaking: breaking, Details: details,
+			})
+		}
+	}
+	for name := range newSpecs {
+		if _, ok := oldSpecs[name]; !ok {
+			added = append(added, name)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+
+	for _, name := range removed {
+		change := FuncChange{Name: name, Kind: FuncRemoved, Breaking: true}
+		// function added with the same signature is likely the renamed one
+		var candidates []string
+		for _, addedName := range added {
+			if sameSignature(oldSpecs[name], newSpecs[addedName]) {
+				candidates = append(candidates, addedName)
+			}
+		}
+		if len(candidates) == 1 {
+			change.Details = []string{"possibly renamed to " + candidates[0]}
+		}
+		changes = append(changes, change)
+	}
+	for _, name := range added {
+		changes = append(changes, FuncChange{Name: name, Kind: FuncAdded})
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+// specChanges describes changes of function signature, host calls plugin
+// functions with positional arguments only
+func specChanges(oldSpec, newSpec fungo.FuncSpec) (details []string, breaking bool) {
+	if oldSpec.Params == nil || newSpec.Params == nil {
+		return nil, false
+	}
+	oldMin, oldMax := arity(oldSpec)
+	newMin, newMax := arity(newSpec)
+	if newMin != oldMin {
+		details = append(details, fmt.Sprintf("requires %d arguments instead of %d", newMin, oldMin))
+		breaking = breaking || newMin > oldMin
+	}
+	if newMax != oldMax {
+		details = append(details, fmt.Sprintf("accepts %s arguments instead of %s",
+			arityName(newMax), arityName(oldMax)))
+		// fewer arguments are accepted
+		breaking = breaking || oldMax < 0 || (newMax >= 0 && newMax < oldMax)
+	}
+
+	oldParams, newParams := positionalParams(oldSpec), positionalParams(newSpec)
+	for i := 0; i < len(oldParams) && i < len(newParams); i++ {
+		oldType, newType := oldParams[i].Type, newParams[i].Type
+		if oldType != "" && newType != "" && oldType != newType {
+			details = append(details, fmt.Sprintf("parameter %d type %s -> %s", i+1, oldType, newType))
+			breaking = true
+		}
+	}
+	if oldSpec.Returns != "" && newSpec.Returns != "" && oldSpec.Returns != newSpec.Returns {
+		details = append(details, fmt.Sprintf("result type %s -> %s", oldSpec.Returns, newSpec.Returns))
+		breaking = true
+	}
+	return details, breaking
+}
+
+// arity returns minimum and maximum number of positional arguments,
+// maximum is -1 if function accepts variadic arguments
+func arity(spec fungo.FuncSpec) (minArgs, maxArgs int) {
+	for _, param := range spec.Params {
+		switch param.Kind {
+		case fungo.ParamPositionalOnly, fungo.ParamPositionalOrKeyword:
+			if param.Default == nil {
+				minArgs++
+			}
+			if maxArgs >= 0 {
+				maxArgs++
+			}
+		case fungo.ParamVarPositional:
+			maxArgs = -1
+		}
+	}
+	return minArgs, maxArgs
+}
+
+func arityName(maxArgs int) string {
+	if maxArgs < 0 {
+		return "variadic"
+	}
+	return fmt.Sprintf("at most %d", maxArgs)
+}
+
+// positionalParams returns parameters receiving positional arguments in order,
+// variadic parameter is the last one
+func positionalParams(spec fungo.FuncSpec) []fungo.FuncParam {
+	var params []fungo.FuncParam
+	for _, param := range spec.Params {
+		switch param.Kind {
+		case fungo.ParamPositionalOnly, fungo.ParamPositionalOrKeyword, fungo.ParamVarPositional:
+			params = append(params, param)
+		}
+	}
+	return params
+}
+
+// sameSignature compares kinds and types of parameters and result type, ignoring names and docs
+func sameSignature(a, b fungo.FuncSpec) bool {
+	if a.Params == nil || b.Params == nil || a.Returns != b.Returns || len(a.Params) != len(b.Params) {
+		return false
+	}
+	for i := range a.Params {
+		if a.Params[i].Kind != b.Params[i].Kind || a.Params[i].Type != b.Params[i].Type {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package funplugin
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+func TestDiffFuncSpecs(t *testing.T) {
+	param := func(kind, typ string) fungo.FuncParam {
+		return fungo.FuncParam{Kind: kind, Type: typ}
+	}
+	optional := fungo.FuncParam{
+		Name: "scale", Kind: fungo.ParamPositionalOrKeyword, Type: "int", Default: json.RawMessage("null"),
+	}
+	oldSpecs := map[string]fungo.FuncSpec{
+		"sum_two_int": {Params: []fungo.FuncParam{
+			param(fungo.ParamPositionalOnly, "int"), param(fungo.ParamPositionalOnly, "int"),
+		}, Returns: "int"},
+		"sum_ints":  {Params: []fungo.FuncParam{param(fungo.ParamVarPositional, "int")}, Returns: "int"},
+		"get_user":  {Params: []fungo.FuncParam{param(fungo.ParamPositionalOnly, "string")}, Returns: "string"},
+		"divide":    {Params: []fungo.FuncParam{param(fungo.ParamPositionalOnly, "float64")}, Returns: "float64"},
+		"setup":     {Params: []fungo.FuncParam{}},
+		"teardown":  {Params: []fungo.FuncParam{}},
+		"lua_hello": {},
+	}
+	newSpecs := map[string]fungo.FuncSpec{
+		// optional parameter is added
+		"sum_two_int": {Params: []fungo.FuncParam{
+			param(fungo.ParamPositionalOnly, "int"), param(fungo.ParamPositionalOnly, "int"), optional,
+		}, Returns: "int"},
+		"sum_ints":   {Params: []fungo.FuncParam{param(fungo.ParamPositionalOnly, "int")}, Returns: "int"},
+		"fetch_user": {Params: []fungo.FuncParam{param(fungo.ParamPositionalOnly, "string")}, Returns: "string"},
+		"divide":     {Params: []fungo.FuncParam{param(fungo.ParamPositionalOnly, "int")}, Returns: "float64"},
+		"setup":      {Params: []fungo.FuncParam{}},
+		"lua_hello":  {Params: []fungo.FuncParam{param(fungo.ParamPositionalOnly, "int")}},
+	}
+	changes := DiffFuncSpecs(oldSpecs, newSpecs)
+	assert.Equal(t, []FuncChange{
+		{Name: "divide", Kind: FuncChanged, Breaking: true, Details: []string{"parameter 1 type float64 -> int"}},
+		{Name: "fetch_user", Kind: FuncAdded},
+		{Name: "get_user", Kind: FuncRemoved, Breaking: true, Details: []string{"possibly renamed to fetch_user"}},
+		{Name: "sum_ints", Kind: FuncChanged, Breaking: true, Details: []string{
+			"requires 1 arguments instead of 0", "accepts at most 1 arguments instead of variadic",
+		}},
+		{Name: "sum_two_int", Kind: FuncChanged, Details: []string{
+			"accepts at most 3 arguments instead of at most 2",
+		}},
+		{Name: "teardown", Kind: FuncRemoved, Breaking: true},
+	}, changes)
+
+	diff := &PluginDiff{Old: fungo.PluginInfo{Version: "v1.0.0"}, New: fungo.PluginInfo{Commit: "1a2b3c4"}, Changes: changes}
+	assert.True(t, diff.Breaking())
+	assert.Contains(t, diff.Report(), "plugin diff v1.0.0 -> 1a2b3c4: 6 changes\n")
+	assert.Contains(t, diff.Report(), "! removed get_user: possibly renamed to fetch_user\n")
+	assert.Contains(t, diff.Report(), "  added   fetch_user\n")
+
+	assert.Empty(t, DiffFuncSpecs(oldSpecs, oldSpecs))
+}
+
+func TestDiffPlugins(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	diff, err := DiffPlugins(pluginBinPath, pluginBinPath)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	assert.Empty(t, diff.Changes)
+	assert.Equal(t, "fungo "+fungo.Version, diff.New.SDK)
+
+	// lua plugin lists functions without signatures, which are compared by name
+	diff, err = DiffPlugins(pluginBinPath, "lua/examples/debugtalk.lua")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	assert.True(t, diff.Breaking())
+	assert.Contains(t, diff.Changes, FuncChange{Name: "wait_seconds", Kind: FuncRemoved, Breaking: true})
+	assert.Contains(t, diff.Changes, FuncChange{Name: "divide", Kind: FuncAdded})
+
+	_, err = DiffPlugins(pluginBinPath, "not_found.bin")
+	assert.Contains(t, err.Error(), "init plugin not_found.bin failed")
+}
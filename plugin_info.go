@@ -0,0 +1,78 @@
+package funplugin
+
+import (
+	"debug/buildinfo"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+// Info returns build manifest of plugin, e.g. version, commit and build time of the plugin
+// build handling calls, which is embedded by WithAutoBuild or set with funppy.set_build_info.
+// It is reported by plugins built with fungo or funppy of the same release, and read from
+// build info of local go plugin binaries otherwise; unknown fields are empty.
+func (p *interceptedPlugin) Info() fungo.PluginInfo {
+	// reserved function is called without interceptors, thus it is not counted in stats
+	result, err := p.pluginBackend.Call(fungo.InfoFuncName)
+	if err == nil {
+		info, err := decodePluginInfo(result)
+		if err == nil {
+			return info
+		}
+		logger.Warn("decode plugin info failed", "path", p.Path(), "error", err)
+	}
+	logger.Debug("plugin does not report info, read it from binary", "path", p.Path(), "error", err)
+
+	bi, err := buildinfo.ReadFile(p.Path())
+	if err != nil {
+		return fungo.PluginInfo{}
+	}
+	return fungo.InfoFromBuildInfo(bi)
+}
+
+// Describe returns signatures of plugin functions keyed by function name, which are
+// reported by plugins built with fungo or funppy of the same release. For other plugins
+// listing their functions, signatures are unknown and Params of FuncSpec are nil.
+func (p *interceptedPlugin) Describe() (map[string]fungo.FuncSpec, error) {
+	result, err := p.pluginBackend.Call(fungo.DescribeFuncName)
+	if err == nil {
+		content, ok := result.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected plugin functions description type %T", result)
+		}
+		specs := make(map[string]fungo.FuncSpec)
+		if err := json.Unmarshal([]byte(content), &specs); err != nil {
+			return nil, errors.Wrap(err, "decode plugin functions description failed")
+		}
+		return specs, nil
+	}
+	logger.Debug("plugin does not describe functions, list them", "path", p.Path(), "error", err)
+
+	lister, ok := p.pluginBackend.(IFuncLister)
+	if !ok {
+		return nil, fmt.Errorf("plugin %s does not describe nor list functions", p.Path())
+	}
+	names, err := lister.GetNames()
+	if err != nil {
+		return nil, errors.Wrap(err, "list plugin functions failed")
+	}
+	specs := make(map[string]fungo.FuncSpec, len(names))
+	for _, name := range names {
+		specs[name] = fungo.FuncSpec{}
+	}
+	return specs, nil
+}
+
+// decodePluginInfo decodes plugin info returned by fungo.InfoFuncName as JSON string
+func decodePluginInfo(result interface{}) (fungo.PluginInfo, error) {
+	var info fungo.PluginInfo
+	content, ok := result.(string)
+	if !ok {
+		return info, fmt.Errorf("unexpected plugin info type %T", result)
+	}
+	err := json.Unmarshal([]byte(content), &info)
+	return info, err
+}
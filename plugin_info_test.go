@@ -0,0 +1,47 @@
+package funplugin
+
+import (
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+func TestPluginInfo(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	for _, rpcType := range []string{"grpc", "rpc"} {
+		os.Setenv(fungo.PluginTypeEnvName, rpcType)
+		plugin, err := Init(pluginBinPath)
+		if !assert.Nil(t, err) {
+			t.FailNow()
+		}
+		info := plugin.Info()
+		assert.Equal(t, "fungo "+fungo.Version, info.SDK, rpcType)
+		assert.Equal(t, runtime.Version(), info.Runtime, rpcType)
+		// reserved function is not listed nor counted
+		assert.False(t, plugin.Has(fungo.InfoFuncName))
+		assert.Empty(t, plugin.Stats().Funcs)
+		plugin.Quit()
+	}
+	os.Setenv(fungo.PluginTypeEnvName, "grpc")
+
+	plugin, err := Init(pluginBinPath, WithTransport("stdio"))
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "fungo "+fungo.Version, plugin.Info().SDK)
+	plugin.Quit()
+
+	// plugins without build manifest
+	plugin, err = Init("starlark/examples/debugtalk.star")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, fungo.PluginInfo{}, plugin.Info())
+	plugin.Quit()
+}
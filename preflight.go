@@ -0,0 +1,54 @@
+package funplugin
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MissingFunctionsError is returned by ValidateFunctions when plugin lacks required functions
+type MissingFunctionsError struct {
+	Path    string   // plugin file path
+	Missing []string // missing function names in order of required names
+}
+
+func (e *MissingFunctionsError) Error() string {
+	return fmt.Sprintf("plugin %s missing %d functions: %s",
+		e.Path, len(e.Missing), strings.Join(e.Missing, ", "))
+}
+
+// ValidateFunctions checks all required functions at once, e.g. when a test suite is loaded,
+// and returns *MissingFunctionsError reporting all missing ones. Plugins listing their
+// functions are checked with one GetNames call, and unlike Has, failure of listing functions
+// is returned instead of treating functions as missing.
+func (p *interceptedPlugin) ValidateFunctions(funcNames []string) error {
+	has := p.pluginBackend.Has
+	if lister, ok := p.pluginBackend.(IFuncLister); ok {
+		names, err := lister.GetNames()
+		if err != nil {
+			return errors.Wrap(err, "list plugin functions failed")
+		}
+		exists := make(map[string]bool, len(names))
+		for _, name := range names {
+			exists[name] = true
+		}
+		has = func(funcName string) bool { return exists[funcName] }
+	}
+
+	var missing []string
+	checked := make(map[string]bool, len(funcNames))
+	for _, name := range funcNames {
+		if checked[name] {
+			continue
+		}
+		checked[name] = true
+		if !has(name) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return &MissingFunctionsError{Path: p.Path(), Missing: missing}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package funplugin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// listingPlugin lists function names without checking functions one by one
+type listingPlugin struct {
+	luaPlugin
+	names    []string
+	err      error
+	getNames int
+}
+
+func (p *listingPlugin) Path() string {
+	return "debugtalk.bin"
+}
+
+func (p *listingPlugin) Has(funcName string) bool {
+	panic("Has should not be called")
+}
+
+func (p *listingPlugin) GetNames() ([]string, error) {
+	p.getNames++
+	return p.names, p.err
+}
+
+func TestValidateFunctions(t *testing.T) {
+	p := &listingPlugin{names: []string{"sum", "concatenate"}}
+	plugin := wrapPlugin(p, &pluginOption{})
+
+	assert.NoError(t, plugin.ValidateFunctions([]string{"sum", "concatenate"}))
+	assert.NoError(t, plugin.ValidateFunctions(nil))
+
+	err := plugin.ValidateFunctions([]string{"sum", "setup", "teardown", "setup"})
+	var missingErr *MissingFunctionsError
+	if !assert.True(t, errors.As(err, &missingErr)) {
+		t.Fatal()
+	}
+	assert.Equal(t, []string{"setup", "teardown"}, missingErr.Missing)
+	assert.EqualError(t, err, "plugin debugtalk.bin missing 2 functions: setup, teardown")
+	// one listing call for each validation
+	assert.Equal(t, 3, p.getNames)
+
+	p.err = errors.New("plugin exited")
+	err = plugin.ValidateFunctions([]string{"sum"})
+	assert.EqualError(t, err, "list plugin functions failed: plugin exited")
+}
+
+func TestValidateFunctionsLuaPlugin(t *testing.T) {
+	plugin, err := Init("lua/examples/debugtalk.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	assert.NoError(t, plugin.ValidateFunctions([]string{"sum_ints", "divide"}))
+	assert.EqualError(t, plugin.ValidateFunctions([]string{"sum_ints", "not_exist", "print"}),
+		"plugin lua/examples/debugtalk.lua missing 2 functions: not_exist, print")
+}
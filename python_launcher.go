@@ -0,0 +1,155 @@
+package funplugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+// pythonLauncher runs python plugin script or package directory passed in argv with stdout redirected to stderr,
+// thus prints at import time of plugin modules, including those of C extensions, do not
+// corrupt hashicorp handshake or JSON-RPC. funppy writes them to the original stdout,
+// whose file descriptor is passed in env HRP_PLUGIN_HANDSHAKE_FD.
+// sys.path is adjusted as specified by WithPythonPath in env HRP_PLUGIN_PYTHON_PATH and
+// HRP_PLUGIN_EXCLUDE_CWD, the directory of plugin script or package is always sys.path[0].
+// Uncaught exceptions, e.g. SyntaxError or missing dependency at import time, are written
+// to stderr as a JSON line prefixed with pythonStartupErrorPrefix.
+const pythonLauncher = `import json, os, runpy, sys, traceback
+sys.stdout.flush()
+os.environ["HRP_PLUGIN_HANDSHAKE_FD"] = str(os.dup(1))
+os.dup2(2, 1)
+path = os.path.abspath(sys.argv[1])
+sys.argv = sys.argv[1:]
+if os.path.isfile(os.path.join(path, "__init__.py")):
+    # run package as python3 -m package, thus its modules are imported relatively
+    sys.path[0] = os.path.dirname(path)
+    run = lambda: runpy.run_module(os.path.basename(path), run_name="__main__", alter_sys=True)
+else:
+    sys.path[0] = path if os.path.isdir(path) else os.path.dirname(path)
+    run = lambda: runpy.run_path(path, run_name="__main__")
+if os.environ.pop("HRP_PLUGIN_EXCLUDE_CWD", "") == "true":
+    cwd = os.getcwd()
+    sys.path[1:] = [p for p in sys.path[1:] if os.path.abspath(p or ".") != cwd]
+sys.path[1:1] = [p for p in os.environ.pop("HRP_PLUGIN_PYTHON_PATH", "").split(os.pathsep) if p]
+try:
+    run()
+except (SystemExit, KeyboardInterrupt):
+    raise
+except BaseException as e:
+    tb = e.__traceback__
+    # skip frames of launcher and runpy
+    while tb is not None and (tb.tb_frame.f_code.co_filename == "<string>"
+                              or tb.tb_frame.f_globals.get("__name__") == "runpy"):
+        tb = tb.tb_next
+    frames = traceback.extract_tb(tb)
+    file, line = (frames[-1].filename, frames[-1].lineno) if frames else ("", 0)
+    if isinstance(e, SyntaxError):
+        file, line = e.filename or file, e.lineno or line
+    report = {
+        "type": type(e).__name__,
+        "message": str(e),
+        "file": file,
+        "line": line,
+        "traceback": "".join(traceback.format_exception(type(e), e, tb)),
+    }
+    sys.stderr.write("funppy startup error: " + json.dumps(report) + "\n")
+    sys.stderr.flush()
+    sys.exit(1)`
+
+const (
+	pythonPathEnvName = "HRP_PLUGIN_PYTHON_PATH"
+	excludeCWDEnvName = "HRP_PLUGIN_EXCLUDE_CWD"
+)
+
+// PythonPath controls sys.path entries python plugin process starts with,
+// the directory of plugin script or package is always the first entry
+type PythonPath struct {
+	// directories inserted after the plugin directory, e.g. project root for imports
+	// of sibling packages, relative paths are resolved against current working directory
+	Prepend []string `json:"prepend,omitempty"`
+	// remove current working directory from sys.path, thus modules in it do not
+	// shadow those of plugin and site-packages
+	ExcludeCWD bool `json:"exclude_cwd,omitempty"`
+}
+
+// env returns env passing python path to pythonLauncher
+func (p *PythonPath) env() []string {
+	var env []string
+	if len(p.Prepend) > 0 {
+		paths := make([]string, 0, len(p.Prepend))
+		for _, path := range p.Prepend {
+			if abs, err := filepath.Abs(path); err == nil {
+				path = abs
+			}
+			paths = append(paths, path)
+		}
+		env = append(env, fmt.Sprintf("%s=%s", pythonPathEnvName,
+			strings.Join(paths, string(os.PathListSeparator))))
+	}
+	if p.ExcludeCWD {
+		env = append(env, fmt.Sprintf("%s=true", excludeCWDEnvName))
+	}
+	return env
+}
+
+// pluginExt returns extension of plugin path, python plugin package directories are
+// treated as .py plugins, whose entry module is __main__.py
+func pluginExt(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		return filepath.Ext(path), nil
+	}
+	if _, err := os.Stat(filepath.Join(path, "__main__.py")); err == nil {
+		return ".py", nil
+	}
+	if _, err := os.Stat(filepath.Join(path, "__init__.py")); err == nil {
+		return "", fmt.Errorf("python plugin package %s has no __main__.py calling funppy.serve()", path)
+	}
+	return "", fmt.Errorf("unsupported plugin directory: %s", path)
+}
+
+// pythonStartupErrorPrefix marks uncaught exception of python plugin reported by pythonLauncher
+const pythonStartupErrorPrefix = "funppy startup error: "
+
+// PythonStartupError is returned by Init when python plugin fails before handshake,
+// e.g. SyntaxError or missing dependency when importing debugtalk.py
+type PythonStartupError struct {
+	Path      string `json:"-"`         // plugin file path
+	Type      string `json:"type"`      // exception type, e.g. ModuleNotFoundError
+	Message   string `json:"message"`   // exception message
+	File      string `json:"file"`      // file raising exception, or file with syntax error
+	Line      int    `json:"line"`      // line number in File
+	Traceback string `json:"traceback"` // full python traceback
+}
+
+func (e *PythonStartupError) Error() string {
+	return fmt.Sprintf("python plugin %s failed at startup with %s: %s (%s:%d)\n%s",
+		e.Path, e.Type, e.Message, e.File, e.Line, strings.TrimSpace(e.Traceback))
+}
+
+// parsePythonStartupError returns startup error reported by pythonLauncher in plugin
+// output, secret values are masked. It returns nil if no startup error is reported.
+func parsePythonStartupError(path string, output []byte) *PythonStartupError {
+	prefix := []byte(pythonStartupErrorPrefix)
+	for _, line := range bytes.Split(output, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if !bytes.HasPrefix(line, prefix) {
+			continue
+		}
+		startupErr := &PythonStartupError{}
+		if err := json.Unmarshal(line[len(prefix):], startupErr); err != nil {
+			logger.Warn("parse python startup error failed", "error", err)
+			continue
+		}
+		startupErr.Path = path
+		startupErr.Message = fungo.Mask(startupErr.Message)
+		startupErr.Traceback = fungo.Mask(startupErr.Traceback)
+		return startupErr
+	}
+	return nil
+}
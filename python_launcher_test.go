@@ -0,0 +1,229 @@
+package funplugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPythonLauncher(t *testing.T) {
+	python3, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not found")
+	}
+	// plugin prints at import time before writing handshake line
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "helper.py"),
+		[]byte("import os\nprint('loading helper')\nos.system('echo subprocess output')\n"), 0o644))
+	script := `import os, sys
+import helper
+print("argv:", os.path.basename(sys.argv[0]), len(sys.argv))
+channel = os.fdopen(int(os.environ["HRP_PLUGIN_HANDSHAKE_FD"]), "w")
+channel.write("1|1|tcp|127.0.0.1:1234|grpc\n")
+channel.flush()
+`
+	path := filepath.Join(dir, "debugtalk.py")
+	assert.Nil(t, os.WriteFile(path, []byte(script), 0o644))
+
+	option := &pluginOption{langType: langTypePython, python3: python3}
+	cmd := option.command(path)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	assert.Nil(t, cmd.Run(), stderr.String())
+
+	assert.Equal(t, "1|1|tcp|127.0.0.1:1234|grpc\n", stdout.String())
+	assert.Contains(t, stderr.String(), "loading helper")
+	assert.Contains(t, stderr.String(), "subprocess output")
+	assert.Contains(t, stderr.String(), "argv: debugtalk.py 1")
+}
+
+func TestPythonStartupError(t *testing.T) {
+	python3, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not found")
+	}
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "helper.py"),
+		[]byte("import os\n\nimport funplugin_missing_dependency\n"), 0o644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "broken.py"),
+		[]byte("def sum(a, b)\n    return a + b\n"), 0o644))
+
+	testCases := []struct {
+		script   string
+		errType  string
+		message  string
+		file     string
+		line     int
+		contains string
+	}{
+		{"print('loading')\nimport helper\n", "ModuleNotFoundError",
+			"No module named 'funplugin_missing_dependency'", "helper.py", 3,
+			"import funplugin_missing_dependency"},
+		{"import broken\n", "SyntaxError", "", "broken.py", 1, "def sum(a, b)"},
+	}
+	for _, tc := range testCases {
+		path := filepath.Join(dir, "debugtalk.py")
+		assert.Nil(t, os.WriteFile(path, []byte(tc.script), 0o644))
+
+		start := time.Now()
+		_, err := Init(path, WithPython3(python3))
+		var startupErr *PythonStartupError
+		if !assert.True(t, errors.As(err, &startupErr), "%v", err) {
+			continue
+		}
+		assert.Equal(t, path, startupErr.Path)
+		assert.Equal(t, tc.errType, startupErr.Type)
+		assert.Contains(t, startupErr.Message, tc.message)
+		assert.Equal(t, tc.file, filepath.Base(startupErr.File))
+		assert.Equal(t, tc.line, startupErr.Line)
+		assert.Contains(t, startupErr.Traceback, tc.contains)
+		assert.NotContains(t, startupErr.Traceback, "runpy")
+		// start is not retried after python startup error
+		assert.Less(t, time.Since(start), 5*time.Second)
+	}
+}
+
+func TestStderrTailStartupError(t *testing.T) {
+	tail := &stderrTail{}
+	traceback := strings.Repeat("x", 2*maxStderrTail)
+	report := fmt.Sprintf(`%s{"type": "ImportError", "message": "boom", "file": "a.py", "line": 2, "traceback": "%s"}`,
+		pythonStartupErrorPrefix, traceback)
+	// go-plugin writes stderr in chunks without newline
+	tail.Write([]byte("funppy start"))
+	tail.Write([]byte("ing\n"))
+	tail.Write([]byte(report[:10]))
+	tail.Write([]byte(report[10:]))
+	tail.Write([]byte("\nexit\n"))
+
+	startupErr := tail.startupError("debugtalk.py")
+	if !assert.NotNil(t, startupErr) {
+		t.Fatal()
+	}
+	assert.Equal(t, "ImportError", startupErr.Type)
+	assert.Equal(t, traceback, startupErr.Traceback)
+	assert.Regexp(t, "^python plugin debugtalk.py failed at startup with ImportError: boom \\(a.py:2\\)\nxxx",
+		startupErr.Error())
+
+	assert.Nil(t, (&stderrTail{}).startupError("debugtalk.py"))
+}
+
+func TestPythonLauncherPackage(t *testing.T) {
+	python3, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not found")
+	}
+	// plugin package with modules imported relatively
+	dir := filepath.Join(t.TempDir(), "plugins")
+	files := map[string]string{
+		"__init__.py": "",
+		"utils.py":    "HANDSHAKE = '1|1|tcp|127.0.0.1:1234|grpc'\n",
+		"__main__.py": `import os
+from .utils import HANDSHAKE
+from plugins import utils
+print("package:", __package__, utils.HANDSHAKE == HANDSHAKE)
+channel = os.fdopen(int(os.environ["HRP_PLUGIN_HANDSHAKE_FD"]), "w")
+channel.write(HANDSHAKE + "\n")
+channel.flush()
+`,
+	}
+	assert.Nil(t, os.Mkdir(dir, 0o755))
+	for name, content := range files {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+
+	option := &pluginOption{langType: langTypePython, python3: python3}
+	cmd := option.command(dir + string(filepath.Separator))
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	assert.Nil(t, cmd.Run(), stderr.String())
+	assert.Equal(t, "1|1|tcp|127.0.0.1:1234|grpc\n", stdout.String())
+	assert.Contains(t, stderr.String(), "package: plugins True")
+
+	// errors of package modules are reported by Init
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "utils.py"), []byte("import funplugin_missing\n"), 0o644))
+	_, err = Init(dir, WithPython3(python3))
+	var startupErr *PythonStartupError
+	if assert.True(t, errors.As(err, &startupErr), "%v", err) {
+		assert.Equal(t, "ModuleNotFoundError", startupErr.Type)
+		assert.Equal(t, "utils.py", filepath.Base(startupErr.File))
+	}
+}
+
+func TestPluginExt(t *testing.T) {
+	dir := t.TempDir()
+	ext, err := pluginExt(filepath.Join(dir, "debugtalk.py"))
+	assert.Nil(t, err)
+	assert.Equal(t, ".py", ext)
+
+	_, err = pluginExt(dir)
+	assert.EqualError(t, err, "unsupported plugin directory: "+dir)
+
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "__init__.py"), nil, 0o644))
+	_, err = pluginExt(dir)
+	assert.EqualError(t, err, "python plugin package "+dir+" has no __main__.py calling funppy.serve()")
+
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "__main__.py"), nil, 0o644))
+	ext, err = pluginExt(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, ".py", ext)
+}
+
+func TestPythonLauncherPythonPath(t *testing.T) {
+	python3, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not found")
+	}
+	pluginDir, projectRoot, cwd := t.TempDir(), t.TempDir(), t.TempDir()
+	// sibling module in project root shadowed by module in current working directory
+	assert.Nil(t, os.WriteFile(filepath.Join(projectRoot, "shared.py"), []byte("NAME = 'project'\n"), 0o644))
+	assert.Nil(t, os.WriteFile(filepath.Join(cwd, "shared.py"), []byte("NAME = 'cwd'\n"), 0o644))
+	script := `import json, os, sys
+import shared
+channel = os.fdopen(int(os.environ["HRP_PLUGIN_HANDSHAKE_FD"]), "w")
+channel.write(json.dumps({"name": shared.NAME, "path": sys.path, "env": "HRP_PLUGIN_PYTHON_PATH" in os.environ}))
+channel.flush()
+`
+	path := filepath.Join(pluginDir, "debugtalk.py")
+	assert.Nil(t, os.WriteFile(path, []byte(script), 0o644))
+
+	run := func(option *pluginOption) (result struct {
+		Name string   `json:"name"`
+		Path []string `json:"path"`
+		Env  bool     `json:"env"`
+	}) {
+		option.langType, option.python3 = langTypePython, python3
+		// current working directory is in PYTHONPATH, e.g. PYTHONPATH=.
+		option.env = map[string]string{"PYTHONPATH": "."}
+		cmd := option.command(path)
+		cmd.Env = option.environ()
+		cmd.Dir = cwd
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		output, err := cmd.Output()
+		if !assert.Nil(t, err, stderr.String()) {
+			t.FailNow()
+		}
+		assert.Nil(t, json.Unmarshal(output, &result))
+		return result
+	}
+
+	result := run(&pluginOption{})
+	assert.Equal(t, "cwd", result.Name)
+
+	result = run(&pluginOption{pythonPath: &PythonPath{Prepend: []string{projectRoot}, ExcludeCWD: true}})
+	assert.Equal(t, "project", result.Name)
+	assert.Equal(t, []string{pluginDir, projectRoot}, result.Path[:2])
+	assert.NotContains(t, result.Path, cwd)
+	assert.False(t, result.Env)
+}
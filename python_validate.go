@@ -0,0 +1,294 @@
+package funplugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/lingcetech/funplugin/myexec"
+)
+
+// pythonValidator checks python plugin script or package directory passed in argv without
+// running it: sources are byte-compiled, funppy registrations and signatures of registered
+// functions are inspected with ast, and imports are resolved with importlib finders, which
+// locate modules without executing them. sys.path is adjusted like pythonLauncher.
+// Diagnostics are written to stdout as a JSON array.
+const pythonValidator = `import ast, importlib.machinery, importlib.util, json, os, sys
+path = os.path.abspath(sys.argv[1])
+if os.path.isdir(path):
+    entry = os.path.join(path, "__main__.py")
+    files = []
+    for root, dirs, names in os.walk(path):
+        dirs[:] = sorted(d for d in dirs if not d.startswith(".") and d != "__pycache__"
+                         and not os.path.isfile(os.path.join(root, d, "pyvenv.cfg")))
+        files.extend(os.path.join(root, n) for n in sorted(names) if n.endswith(".py"))
+    package = os.path.isfile(os.path.join(path, "__init__.py"))
+    sys.path[0] = os.path.dirname(path) if package else path
+else:
+    entry, files = path, [path]
+    sys.path[0] = os.path.dirname(path)
+if os.environ.pop("HRP_PLUGIN_EXCLUDE_CWD", "") == "true":
+    cwd = os.getcwd()
+    sys.path[1:] = [p for p in sys.path[1:] if os.path.abspath(p or ".") != cwd]
+sys.path[1:1] = [p for p in os.environ.pop("HRP_PLUGIN_PYTHON_PATH", "").split(os.pathsep) if p]
+
+SERVE = {"serve", "serve_stdio", "serve_pipe", "serve_sidecar", "serve_kernel"}
+IMPORT_ERRORS = {"ImportError", "ModuleNotFoundError", "Exception", "BaseException"}
+FUNCS = (ast.FunctionDef, ast.AsyncFunctionDef, ast.Lambda)
+diagnostics, registered, resolved = [], {}, {}
+served = registrations = 0
+
+def report(file, node, severity, message, line=None, column=None):
+    diagnostics.append({
+        "file": file,
+        "line": line if line is not None else getattr(node, "lineno", 1),
+        "column": column if column is not None else getattr(node, "col_offset", 0) + 1,
+        "severity": severity,
+        "message": message,
+    })
+
+def find(module, path=None):
+    parts = module.split(".")
+    try:
+        if path is None:
+            spec = importlib.util.find_spec(parts[0])
+        else:
+            spec = importlib.machinery.PathFinder.find_spec(parts[0], path)
+        for part in parts[1:]:
+            if spec is None or spec.submodule_search_locations is None:
+                # submodules of plain modules, e.g. os.path, are known after import only
+                break
+            spec = importlib.machinery.PathFinder.find_spec(
+                spec.name + "." + part, spec.submodule_search_locations)
+        return spec is not None
+    except (ImportError, ValueError):
+        return False
+
+def catches_import_error(node):
+    for handler in node.handlers:
+        types = handler.type.elts if isinstance(handler.type, ast.Tuple) else [handler.type]
+        for t in types:
+            if t is None or getattr(t, "id", getattr(t, "attr", None)) in IMPORT_ERRORS:
+                return True
+    return False
+
+def check_import(file, node, nested):
+    if isinstance(node, ast.Import):
+        modules, search = [alias.name for alias in node.names], None
+    elif node.level == 0:
+        modules, search = [node.module], None
+    elif node.module:
+        base = os.path.dirname(file)
+        for _ in range(node.level - 1):
+            base = os.path.dirname(base)
+        modules, search = [node.module], [base]
+    else:
+        # from . import name, name may be attribute of package
+        return
+    for module in modules:
+        if module == "__future__":
+            continue
+        key = (module, tuple(search or ()))
+        if key not in resolved:
+            resolved[key] = find(module, search)
+        if resolved[key]:
+            continue
+        if nested:
+            report(file, node, "warning",
+                   "module %s imported in function is not found in python3 environment" % module)
+        else:
+            report(file, node, "error", "module %s is not found in python3 environment" % module)
+
+def check_imports(file, node, guarded=False, nested=False):
+    if isinstance(node, (ast.Import, ast.ImportFrom)) and not guarded:
+        check_import(file, node, nested)
+    if isinstance(node, ast.Try):
+        for child in node.body:
+            check_imports(file, child, guarded or catches_import_error(node), nested)
+        for child in node.handlers + node.orelse + node.finalbody:
+            check_imports(file, child, guarded, nested)
+        return
+    if isinstance(node, ast.If) and "TYPE_CHECKING" in (
+            getattr(node.test, "id", None), getattr(node.test, "attr", None)):
+        guarded = True
+    nested = nested or isinstance(node, FUNCS)
+    for child in ast.iter_child_nodes(node):
+        check_imports(file, child, guarded, nested)
+
+def is_generator(func):
+    nodes = list(ast.iter_child_nodes(func))
+    while nodes:
+        node = nodes.pop()
+        if isinstance(node, (ast.Yield, ast.YieldFrom)):
+            return True
+        if not isinstance(node, FUNCS + (ast.ClassDef,)):
+            nodes.extend(ast.iter_child_nodes(node))
+    return False
+
+def check_signature(file, name, func, node):
+    if isinstance(func, ast.AsyncFunctionDef):
+        report(file, node, "error", "function %s is a coroutine function, which is not supported" % name)
+    elif not isinstance(func, ast.Lambda) and is_generator(func):
+        report(file, node, "error",
+               "function %s is a generator function, whose result is not serializable" % name)
+    args = func.args
+    for arg, default in zip(args.kwonlyargs, args.kw_defaults):
+        if default is None:
+            report(file, node, "error", "function %s: keyword-only parameter %s has no default, "
+                   "host passes arguments positionally" % (name, arg.arg))
+    if args.kwarg is not None:
+        report(file, node, "warning", "function %s: **%s never receives values, "
+               "host passes arguments positionally" % (name, args.kwarg.arg))
+
+def top_level(stmts):
+    for stmt in stmts:
+        yield stmt
+        for block in ("body", "orelse", "finalbody"):
+            if isinstance(stmt, (ast.If, ast.Try, ast.With)) and hasattr(stmt, block):
+                yield from top_level(getattr(stmt, block))
+
+def check_file(file):
+    global served, registrations
+    try:
+        with open(file, "rb") as f:
+            source = f.read()
+        tree = ast.parse(source, file)
+        compile(tree, file, "exec", dont_inherit=True)
+    except SyntaxError as e:
+        report(file, None, "error", "syntax error: %s" % e.msg, e.lineno or 1, e.offset or 1)
+        return
+    except (OSError, ValueError) as e:
+        report(file, None, "error", "compile failed: %s" % e)
+        return
+    check_imports(file, tree)
+
+    modules, members = set(), {}
+    for node in ast.walk(tree):
+        if isinstance(node, ast.Import):
+            modules.update(a.asname or a.name for a in node.names if a.name == "funppy")
+        elif isinstance(node, ast.ImportFrom) and node.level == 0 and node.module == "funppy":
+            members.update((a.asname or a.name, a.name) for a in node.names)
+    def funppy_attr(node):
+        if isinstance(node, ast.Call):
+            node = node.func
+        if isinstance(node, ast.Attribute) and getattr(node.value, "id", None) in modules:
+            return node.attr
+        if isinstance(node, ast.Name):
+            return members.get(node.id)
+        return None
+
+    defs = {s.name: s for s in top_level(tree.body) if isinstance(s, (ast.FunctionDef, ast.AsyncFunctionDef))}
+    bound = set(defs)
+    for node in ast.walk(tree):
+        if isinstance(node, ast.Name) and isinstance(node.ctx, ast.Store):
+            bound.add(node.id)
+        elif isinstance(node, (ast.Import, ast.ImportFrom)):
+            bound.update((a.asname or a.name).split(".")[0] for a in node.names)
+        elif isinstance(node, (ast.FunctionDef, ast.AsyncFunctionDef, ast.ClassDef)):
+            bound.add(node.name)
+        elif isinstance(node, ast.arg):
+            bound.add(node.arg)
+
+    def register(name, node):
+        if name in registered:
+            report(file, node, "warning", "function %s is registered twice, "
+                   "the later registration overrides" % name)
+        registered[name] = True
+
+    for node in ast.walk(tree):
+        if isinstance(node, (ast.FunctionDef, ast.AsyncFunctionDef)):
+            for decorator in node.decorator_list:
+                if funppy_attr(decorator) != "function":
+                    continue
+                registrations += 1
+                name = node.name
+                for keyword in getattr(decorator, "keywords", []):
+                    if keyword.arg == "name" and isinstance(keyword.value, ast.Constant):
+                        name = keyword.value.value
+                if isinstance(decorator, ast.Call) and decorator.args and \
+                        isinstance(decorator.args[0], ast.Constant):
+                    name = decorator.args[0].value
+                register(name, node)
+                check_signature(file, name, node, node)
+        if not isinstance(node, ast.Call):
+            continue
+        attr = funppy_attr(node)
+        if attr in SERVE:
+            served += 1
+        elif attr in ("register_module", "register_package"):
+            registrations += 1
+        elif attr == "register":
+            registrations += 1
+            if len(node.args) < 2 or not isinstance(node.args[0], ast.Constant):
+                continue
+            name, func = node.args[0].value, node.args[1]
+            register(name, node)
+            if isinstance(func, ast.Lambda):
+                check_signature(file, name, func, node)
+            elif isinstance(func, ast.Name) and func.id in defs:
+                check_signature(file, name, defs[func.id], node)
+            elif isinstance(func, ast.Name) and func.id not in bound:
+                report(file, node, "error", "function %s: %s is not defined" % (name, func.id))
+
+for file in files:
+    check_file(file)
+if not any(d["message"].startswith("syntax error") for d in diagnostics):
+    if registrations == 0:
+        report(entry, None, "error", "no functions are registered with funppy.register, "
+               "funppy.function, funppy.register_module or funppy.register_package")
+    if served == 0:
+        report(entry, None, "warning", "funppy.serve is not called, plugin does not serve host")
+diagnostics.sort(key=lambda d: (d["file"], d["line"], d["column"]))
+json.dump(diagnostics, sys.stdout)`
+
+// ValidatePythonPlugin checks python plugin script or package directory without launching
+// it: sources are byte-compiled, funppy registrations must exist, registered functions with
+// signatures host cannot call, e.g. coroutine functions or keyword-only parameters without
+// defaults, are reported, and imports are resolved with python3 of WithPython3, or the
+// funppy venv created by Init, taking WithPythonPath into account. Imports guarded by
+// try/except ImportError are skipped. Returned error is for failures to run python3 only.
+func ValidatePythonPlugin(path string, options ...Option) ([]Diagnostic, error) {
+	option := &pluginOption{}
+	for _, o := range options {
+		o(option)
+	}
+	ext, err := pluginExt(path)
+	if err != nil {
+		return nil, err
+	}
+	if ext != ".py" {
+		return nil, errors.Errorf("not a python plugin: %s", path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, errors.Wrap(err, "stat python plugin failed")
+	}
+
+	python3 := option.python3
+	if python3 == "" {
+		python3, err = myexec.EnsurePython3Venv("", "funppy")
+		if err != nil {
+			return nil, errors.Wrap(err, "miss python3, create python3 funppy venv failed")
+		}
+	}
+	cmd := exec.Command(python3, "-c", pythonValidator, path)
+	cmd.Env = os.Environ()
+	if option.pythonPath != nil {
+		cmd.Env = append(cmd.Env, option.pythonPath.env()...)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "validate python plugin %s failed: %s",
+			path, strings.TrimSpace(stderr.String()))
+	}
+	var diagnostics []Diagnostic
+	if err := json.Unmarshal(output, &diagnostics); err != nil {
+		return nil, errors.Wrap(err, "parse python plugin diagnostics failed")
+	}
+	return diagnostics, nil
+}
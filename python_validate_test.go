@@ -0,0 +1,116 @@
+package funplugin
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const validatedPlugin = `import json
+from typing import List
+
+try:
+    import ujson
+except ImportError:
+    ujson = None
+
+import missing_dependency
+import funppy
+
+
+def lookup(id):
+    import missing_optional
+    return missing_optional.get(id)
+
+async def fetch(url):
+    return url
+
+def items(*args):
+    yield from args
+
+def query(sql, *, timeout):
+    return sql
+
+def tagged(name, **labels):
+    return name
+
+@funppy.function(name="sum")
+def sum_ints(*args: List[int]) -> int:
+    return sum(args)
+
+if __name__ == "__main__":
+    funppy.register("lookup", lookup)
+    funppy.register("fetch", fetch)
+    funppy.register("items", items)
+    funppy.register("query", query)
+    funppy.register("tagged", tagged)
+    funppy.register("upper", upper)
+    funppy.register("lookup", lambda id: id)
+    funppy.serve()
+`
+
+func TestValidatePythonPlugin(t *testing.T) {
+	python3, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not found")
+	}
+	root, _ := os.Getwd()
+	options := []Option{WithPython3(python3), WithPythonPath(PythonPath{Prepend: []string{root}})}
+
+	path := filepath.Join(t.TempDir(), "debugtalk.py")
+	assert.Nil(t, os.WriteFile(path, []byte(validatedPlugin), 0o644))
+	diagnostics, err := ValidatePythonPlugin(path, options...)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	var messages []string
+	for _, d := range diagnostics {
+		assert.Equal(t, path, d.File)
+		messages = append(messages, d.Severity+": "+d.Message)
+	}
+	assert.Equal(t, []string{
+		"error: module missing_dependency is not found in python3 environment",
+		"warning: module missing_optional imported in function is not found in python3 environment",
+		"error: function fetch is a coroutine function, which is not supported",
+		"error: function items is a generator function, whose result is not serializable",
+		"error: function query: keyword-only parameter timeout has no default, host passes arguments positionally",
+		"warning: function tagged: **labels never receives values, host passes arguments positionally",
+		"error: function upper: upper is not defined",
+		"warning: function lookup is registered twice, the later registration overrides",
+	}, messages)
+	assert.Equal(t, 9, diagnostics[0].Line)
+	assert.Equal(t, 1, diagnostics[0].Column)
+
+	// syntax error
+	assert.Nil(t, os.WriteFile(path, []byte("import funppy\ndef broken(:\n    pass\n"), 0o644))
+	diagnostics, err = ValidatePythonPlugin(path, options...)
+	assert.Nil(t, err)
+	if assert.Len(t, diagnostics, 1) {
+		assert.Equal(t, SeverityError, diagnostics[0].Severity)
+		assert.Equal(t, 2, diagnostics[0].Line)
+		assert.Contains(t, diagnostics[0].Message, "syntax error")
+	}
+
+	// no registrations
+	assert.Nil(t, os.WriteFile(path, []byte("def hello():\n    return 'hi'\n"), 0o644))
+	diagnostics, err = ValidatePythonPlugin(path, options...)
+	assert.Nil(t, err)
+	if assert.Len(t, diagnostics, 2) {
+		assert.Equal(t, "error: no functions are registered with funppy.register, funppy.function, "+
+			"funppy.register_module or funppy.register_package",
+			diagnostics[0].Severity+": "+diagnostics[0].Message)
+		assert.Equal(t, "warning: funppy.serve is not called, plugin does not serve host",
+			diagnostics[1].Severity+": "+diagnostics[1].Message)
+	}
+
+	// examples are fine
+	diagnostics, err = ValidatePythonPlugin("funppy/examples/debugtalk.py", options...)
+	assert.Nil(t, err)
+	assert.Empty(t, diagnostics)
+
+	_, err = ValidatePythonPlugin("fungo/examples/debugtalk.go", options...)
+	assert.Contains(t, err.Error(), "not a python plugin")
+}
@@ -0,0 +1,190 @@
+package funplugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrQueueFull is returned when max concurrent calls are running and the wait queue is full
+	ErrQueueFull = errors.New("plugin call queue is full")
+	// ErrQueueTimeout is returned when a queued call is not started before its deadline
+	ErrQueueTimeout = errors.New("plugin call queue timeout")
+)
+
+// QueueStats is a snapshot of plugin call queue metrics
+type QueueStats struct {
+	MaxConcurrency int   `json:"max_concurrency"`
+	QueueSize      int   `json:"queue_size"`
+	Running        int   `json:"running"`   // calls being executed
+	Queued         int   `json:"queued"`    // calls waiting for a free slot, i.e. queue depth
+	Rejected       int64 `json:"rejected"`  // calls rejected with ErrQueueFull
+	TimedOut       int64 `json:"timed_out"` // calls failed with ErrQueueTimeout
+}
+
+// IQueueMonitor is implemented by plugins returned from Init,
+// stats are zero unless initialized with WithConcurrencyLimit
+type IQueueMonitor interface {
+	QueueStats() QueueStats
+}
+
+// Priority of calls waiting in the queue of WithConcurrencyLimit, calls of higher
+// priority are started first, and those of the same priority in order of arrival
+type Priority int
+
+const (
+	PriorityLow    Priority = -10 // e.g. bulk data generation
+	PriorityNormal Priority = 0   // default priority of functions
+	PriorityHigh   Priority = 10  // e.g. health checks and teardown functions
+)
+
+// callQueue bounds concurrent plugin calls, excess calls wait in a bounded queue ordered
+// by priority until a slot is free or their deadline is exceeded
+type callQueue struct {
+	maxConcurrency int
+	queueSize      int
+	timeout        time.Duration
+	priorities     map[string]Priority // priority of functions, others are PriorityNormal
+
+	mutex    sync.Mutex
+	running  int
+	waiters  []*queuedCall // sorted by priority in descending order, then arrival
+	rejected int64
+	timedOut int64
+}
+
+// queuedCall is a call waiting in queue, ready receives nil when it is granted a slot,
+// or ErrQueueFull when it is bumped by a call of higher priority
+type queuedCall struct {
+	priority Priority
+	ready    chan error
+}
+
+func newCallQueue(maxConcurrency, queueSize int, timeout time.Duration, priorities map[string]Priority) *callQueue {
+	return &callQueue{
+		maxConcurrency: maxConcurrency,
+		queueSize:      queueSize,
+		timeout:        timeout,
+		priorities:     priorities,
+	}
+}
+
+// acquire waits for a free slot until deadline or ctx is done, zero deadline means no deadline.
+// If queue is full, the last waiting call of lower priority is bumped.
+func (q *callQueue) acquire(ctx context.Context, priority Priority, deadline time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	q.mutex.Lock()
+	if q.running < q.maxConcurrency && len(q.waiters) == 0 {
+		q.running++
+		q.mutex.Unlock()
+		return nil
+	}
+	if len(q.waiters) >= q.queueSize {
+		last := len(q.waiters) - 1
+		if last < 0 || q.waiters[last].priority >= priority {
+			q.rejected++
+			q.mutex.Unlock()
+			return ErrQueueFull
+		}
+		q.waiters[last].ready <- ErrQueueFull
+		q.waiters = q.waiters[:last]
+		q.rejected++
+	}
+	call := &queuedCall{priority: priority, ready: make(chan error, 1)}
+	i := sort.Search(len(q.waiters), func(i int) bool {
+		return q.waiters[i].priority < priority
+	})
+	q.waiters = append(q.waiters, nil)
+	copy(q.waiters[i+1:], q.waiters[i:])
+	q.waiters[i] = call
+	q.mutex.Unlock()
+
+	var expired <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case err := <-call.ready:
+		return err
+	case <-expired:
+		return q.leave(call, ErrQueueTimeout)
+	case <-ctx.Done():
+		return q.leave(call, ctx.Err())
+	}
+}
+
+// leave removes call from queue for reason, e.g. ErrQueueTimeout. If the call is granted a
+// slot or bumped at the same time, result of that is returned instead.
+func (q *callQueue) leave(call *queuedCall, reason error) error {
+	q.mutex.Lock()
+	for i, waiter := range q.waiters {
+		if waiter == call {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			if reason == ErrQueueTimeout {
+				q.timedOut++
+			}
+			q.mutex.Unlock()
+			return reason
+		}
+	}
+	q.mutex.Unlock()
+	err := <-call.ready
+	if err == nil && reason != ErrQueueTimeout {
+		// the slot granted to an aborted call is passed on
+		q.release()
+		return reason
+	}
+	return err
+}
+
+// release passes slot to the first waiting call
+func (q *callQueue) release() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if len(q.waiters) == 0 {
+		q.running--
+		return
+	}
+	call := q.waiters[0]
+	q.waiters = q.waiters[1:]
+	call.ready <- nil
+}
+
+func (q *callQueue) stats() QueueStats {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return QueueStats{
+		MaxConcurrency: q.maxConcurrency,
+		QueueSize:      q.queueSize,
+		Running:        q.running,
+		Queued:         len(q.waiters),
+		Rejected:       q.rejected,
+		TimedOut:       q.timedOut,
+	}
+}
+
+func (q *callQueue) interceptor() callInterceptor {
+	return func(ctx context.Context, next callHandler) callHandler {
+		return func(funcName string, args ...interface{}) (interface{}, error) {
+			var deadline time.Time
+			if q.timeout > 0 {
+				deadline = time.Now().Add(q.timeout)
+			}
+			if err := q.acquire(ctx, q.priorities[funcName], deadline); err != nil {
+				logger.Warn("plugin call not started", "funcName", funcName, "error", err)
+				return nil, fmt.Errorf("call %s failed: %w", funcName, err)
+			}
+			defer q.release()
+			return next(funcName, args...)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package funplugin
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingPlugin blocks function calls until release is closed
+type blockingPlugin struct {
+	luaPlugin
+	release chan struct{}
+}
+
+func (p *blockingPlugin) Call(funcName string, args ...interface{}) (interface{}, error) {
+	<-p.release
+	return funcName, nil
+}
+
+func (p *blockingPlugin) Has(funcName string) bool {
+	return true
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	p := &blockingPlugin{release: make(chan struct{})}
+	plugin := wrapPlugin(p, &pluginOption{
+		maxConcurrency: 1,
+		queueSize:      1,
+		queueTimeout:   200 * time.Millisecond,
+	})
+	monitor := plugin.(IQueueMonitor)
+
+	var wg sync.WaitGroup
+	results := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := plugin.Call("slow")
+			results <- err
+		}()
+		// make sure calls are started in order
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	stats := monitor.QueueStats()
+	assert.Equal(t, 1, stats.Running)
+	assert.Equal(t, 1, stats.Queued)
+
+	// queue is full
+	_, err := plugin.Call("slow")
+	assert.True(t, errors.Is(err, ErrQueueFull))
+
+	// queued call exceeds its deadline
+	err = <-results
+	assert.True(t, errors.Is(err, ErrQueueTimeout))
+
+	close(p.release)
+	wg.Wait()
+	assert.NoError(t, <-results)
+
+	stats = monitor.QueueStats()
+	assert.Equal(t, 0, stats.Running)
+	assert.Equal(t, 0, stats.Queued)
+	assert.Equal(t, int64(1), stats.Rejected)
+	assert.Equal(t, int64(1), stats.TimedOut)
+}
+
+func TestQueuePriority(t *testing.T) {
+	p := &blockingPlugin{release: make(chan struct{})}
+	plugin := wrapPlugin(p, &pluginOption{
+		maxConcurrency: 1,
+		queueSize:      3,
+		funcPriorities: map[string]Priority{
+			"health_check": PriorityHigh,
+			"gen_data":     PriorityLow,
+		},
+	})
+	monitor := plugin.(IQueueMonitor)
+
+	var mutex sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	errs := make(map[string]error)
+	call := func(funcName, key string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := plugin.Call(funcName)
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				errs[key] = err
+				return
+			}
+			order = append(order, key)
+		}()
+		// make sure calls are queued in order
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	call("slow", "running")
+	call("gen_data", "gen_data-1")
+	call("gen_data", "gen_data-2")
+	call("slow", "normal")
+	assert.Equal(t, 3, monitor.QueueStats().Queued)
+
+	// queue is full, health check bumps the last call of lower priority
+	call("health_check", "health_check")
+	assert.Equal(t, 3, monitor.QueueStats().Queued)
+	mutex.Lock()
+	assert.True(t, errors.Is(errs["gen_data-2"], ErrQueueFull))
+	mutex.Unlock()
+	// calls of lower or the same priority are rejected
+	_, err := plugin.Call("gen_data")
+	assert.True(t, errors.Is(err, ErrQueueFull))
+
+	// release calls one by one to observe start order
+	for i := 0; i < 4; i++ {
+		p.release <- struct{}{}
+		time.Sleep(20 * time.Millisecond)
+	}
+	wg.Wait()
+	assert.Equal(t, []string{"running", "health_check", "normal", "gen_data-1"}, order)
+	stats := monitor.QueueStats()
+	assert.Equal(t, 0, stats.Running)
+	assert.Equal(t, 0, stats.Queued)
+	assert.Equal(t, int64(2), stats.Rejected)
+}
+
+func TestQueueCancel(t *testing.T) {
+	p := &blockingPlugin{release: make(chan struct{})}
+	plugin := wrapPlugin(p, &pluginOption{maxConcurrency: 1, queueSize: 2})
+	monitor := plugin.(IQueueMonitor)
+
+	running, err := plugin.CallAsync("slow")
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool { return monitor.QueueStats().Running == 1 },
+		time.Second, 10*time.Millisecond)
+
+	// canceled calls leave the queue without waiting for a free slot
+	future, err := plugin.CallAsync("queued")
+	assert.Nil(t, err)
+	id := plugin.Submit("submitted")
+	assert.Eventually(t, func() bool { return monitor.QueueStats().Queued == 2 },
+		time.Second, 10*time.Millisecond)
+	future.Cancel()
+	_, err = future.Result()
+	assert.True(t, errors.Is(err, ErrCallCanceled))
+	assert.Nil(t, plugin.Cancel(id))
+	assert.Eventually(t, func() bool { return monitor.QueueStats().Queued == 0 },
+		time.Second, 10*time.Millisecond)
+
+	close(p.release)
+	result, err := running.Result()
+	assert.Nil(t, err)
+	assert.Equal(t, "slow", result)
+	_, err = plugin.Call("slow")
+	assert.Nil(t, err)
+	stats := monitor.QueueStats()
+	assert.Equal(t, 0, stats.Running)
+	assert.Equal(t, int64(0), stats.TimedOut)
+}
@@ -0,0 +1,74 @@
+package funplugin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimit is token bucket config, rps is allowed calls per second
+type rateLimit struct {
+	rps   float64
+	burst int
+}
+
+// newRateLimitInterceptor blocks plugin calls exceeding the plugin limit or the function
+// specific limit until tokens are available, calls not allowed within timeout fail with
+// ErrQueueTimeout, 0 means no timeout
+func newRateLimitInterceptor(limit *rateLimit, funcLimits map[string]rateLimit, timeout time.Duration) callInterceptor {
+	var limiter *rate.Limiter
+	if limit != nil {
+		limiter = rate.NewLimiter(rate.Limit(limit.rps), limit.burst)
+	}
+	funcLimiters := make(map[string]*rate.Limiter, len(funcLimits))
+	for funcName, l := range funcLimits {
+		funcLimiters[funcName] = rate.NewLimiter(rate.Limit(l.rps), l.burst)
+	}
+
+	return func(ctx context.Context, next callHandler) callHandler {
+		return func(funcName string, args ...interface{}) (interface{}, error) {
+			waitCtx := ctx
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				waitCtx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+			if l, ok := funcLimiters[funcName]; ok {
+				if err := waitRateLimit(ctx, waitCtx, l, funcName); err != nil {
+					return nil, err
+				}
+			}
+			if limiter != nil {
+				if err := waitRateLimit(ctx, waitCtx, limiter, funcName); err != nil {
+					return nil, err
+				}
+			}
+			return next(funcName, args...)
+		}
+	}
+}
+
+// waitRateLimit waits for a token until waitCtx is done, which is derived from ctx of the call
+// with the queue timeout
+func waitRateLimit(ctx, waitCtx context.Context, limiter *rate.Limiter, funcName string) error {
+	if limiter.Allow() {
+		return nil
+	}
+	logger.Debug("plugin call rate limited", "funcName", funcName)
+	if err := limiter.Wait(waitCtx); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("call %s aborted waiting for rate limit: %w", funcName, ctx.Err())
+		}
+		// Wait fails at once if tokens are not available before deadline,
+		// which is either deadline of the call or the queue timeout
+		if deadline, ok := ctx.Deadline(); ok {
+			if waitDeadline, _ := waitCtx.Deadline(); !deadline.After(waitDeadline) {
+				return fmt.Errorf("call %s aborted waiting for rate limit: %w", funcName, context.DeadlineExceeded)
+			}
+		}
+		return fmt.Errorf("call %s rate limited: %w", funcName, ErrQueueTimeout)
+	}
+	return nil
+}
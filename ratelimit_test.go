@@ -0,0 +1,83 @@
+package funplugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	plugin, err := Init("lua/examples/debugtalk.lua",
+		WithRateLimit(1000, 1), WithFuncRateLimit("sum_two_int", 10, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	assertPlugin(t, plugin)
+
+	// 1 burst + 5 calls at 10 calls per second take at least 500ms
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		_, err := plugin.Call("sum_two_int", 1, 2)
+		assert.NoError(t, err)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 450*time.Millisecond)
+
+	// other functions are only limited by plugin rate limit
+	start = time.Now()
+	for i := 0; i < 6; i++ {
+		_, err := plugin.Call("sum_ints", 1, 2)
+		assert.NoError(t, err)
+	}
+	assert.Less(t, time.Since(start), 200*time.Millisecond)
+}
+
+func TestRateLimitTimeout(t *testing.T) {
+	plugin, err := Init("lua/examples/debugtalk.lua",
+		WithFuncRateLimit("sum_two_int", 1, 1), WithConcurrencyLimit(2, 10, 100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	_, err = plugin.Call("sum_two_int", 1, 2)
+	assert.NoError(t, err)
+
+	// next token is not available within queue timeout
+	start := time.Now()
+	_, err = plugin.Call("sum_two_int", 1, 2)
+	assert.True(t, errors.Is(err, ErrQueueTimeout))
+	assert.EqualError(t, err, "call sum_two_int rate limited: plugin call queue timeout")
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+}
+
+func TestRateLimitCanceled(t *testing.T) {
+	plugin, err := Init("lua/examples/debugtalk.lua", WithFuncRateLimit("sum_two_int", 1, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	_, err = plugin.Call("sum_two_int", 1, 2)
+	assert.NoError(t, err)
+
+	// call waiting for next token is aborted with its ctx
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err = plugin.(*interceptedPlugin).callContext(ctx, "sum_two_int", 1, 2)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+	// next token is not available before deadline of the call
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = plugin.(*interceptedPlugin).callContext(ctx, "sum_two_int", 1, 2)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.False(t, errors.Is(err, ErrQueueTimeout))
+}
@@ -0,0 +1,56 @@
+package funplugin
+
+import (
+	"context"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+// CallDetailed calls plugin function and returns its result with non-fatal warnings and log
+// entries it reported, e.g. data-quality caveats, see fungo.AddWarning and funppy CallContext.
+// Plugins not called over gRPC report no warnings or log entries.
+func (p *interceptedPlugin) CallDetailed(funcName string, args ...interface{}) (*fungo.CallResult, error) {
+	caller, ok := p.pluginBackend.(fungo.IDetailedCaller)
+	if !ok {
+		value, err := p.Call(funcName, args...)
+		if err != nil {
+			return nil, err
+		}
+		return &fungo.CallResult{Value: value}, nil
+	}
+	return p.callDetailed(context.Background(), caller.CallDetailed, funcName, args...)
+}
+
+// callDetailedContext calls function like CallDetailed, the call carries call metadata md and
+// is canceled when ctx is done if plugin backend supports them, e.g. gRPC plugins
+func (p *interceptedPlugin) callDetailedContext(ctx context.Context, md map[string]string, funcName string, args ...interface{}) (*fungo.CallResult, error) {
+	caller, ok := p.pluginBackend.(fungo.IDetailedContextCaller)
+	if !ok {
+		return p.CallDetailed(funcName, args...)
+	}
+	return p.callDetailed(ctx, func(funcName string, args ...interface{}) (*fungo.CallResult, error) {
+		return caller.CallDetailedContext(ctx, md, funcName, args...)
+	}, funcName, args...)
+}
+
+// callDetailed calls function by detailed call of plugin backend through interceptors,
+// waits of the call in interceptors end when ctx is done
+func (p *interceptedPlugin) callDetailed(ctx context.Context, detailedCall func(string, ...interface{}) (*fungo.CallResult, error),
+	funcName string, args ...interface{}) (*fungo.CallResult, error) {
+	result := &fungo.CallResult{}
+	call := func(funcName string, args ...interface{}) (interface{}, error) {
+		detailed, err := detailedCall(funcName, args...)
+		if err != nil {
+			return nil, err
+		}
+		result = detailed
+		return detailed.Value, nil
+	}
+	value, err := p.intercept(ctx, call)(funcName, args...)
+	if err != nil {
+		return nil, err
+	}
+	// interceptors may replace result, e.g. cached value
+	result.Value = value
+	return result, nil
+}
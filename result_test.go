@@ -0,0 +1,56 @@
+package funplugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+func TestCallDetailed(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	plugin, err := Init(pluginBinPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	result, err := plugin.CallDetailed("parse_score", "")
+	assert.Nil(t, err)
+	assert.Equal(t, float64(0), result.Value)
+	assert.Equal(t, []string{"empty score, use 0"}, result.Warnings)
+	assert.Empty(t, result.Logs)
+
+	result, err = plugin.CallDetailed("parse_score", "92.5")
+	assert.Nil(t, err)
+	assert.Equal(t, 92.5, result.Value)
+	assert.Empty(t, result.Warnings)
+	if assert.Len(t, result.Logs, 1) {
+		assert.Equal(t, fungo.LogLevelDebug, result.Logs[0].Level)
+		assert.Equal(t, "parse score 92.5", result.Logs[0].Message)
+	}
+
+	_, err = plugin.CallDetailed("parse_score", "abc")
+	assert.ErrorContains(t, err, "invalid syntax")
+
+	// host side interceptors apply to detailed calls
+	funcs := plugin.Stats().Funcs
+	assert.Len(t, funcs, 1)
+	assert.Equal(t, int64(3), funcs[0].Calls)
+	assert.Equal(t, int64(1), funcs[0].Errors)
+}
+
+func TestCallDetailedNotGRPC(t *testing.T) {
+	plugin, err := Init("lua/examples/debugtalk.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	result, err := plugin.CallDetailed("sum_two_int", 1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, &fungo.CallResult{Value: float64(3)}, result)
+}
@@ -0,0 +1,196 @@
+package funplugin
+
+import (
+	"bufio"
+	"debug/buildinfo"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SBOM is a CycloneDX software bill of materials of a plugin artifact
+type SBOM struct {
+	BOMFormat   string          `json:"bomFormat"`
+	SpecVersion string          `json:"specVersion"`
+	Version     int             `json:"version"`
+	Metadata    SBOMMetadata    `json:"metadata"`
+	Components  []SBOMComponent `json:"components"`
+}
+
+// SBOMMetadata describes the plugin artifact the SBOM is generated for
+type SBOMMetadata struct {
+	Timestamp string         `json:"timestamp"`
+	Component *SBOMComponent `json:"component,omitempty"`
+}
+
+// SBOMComponent is a python package or go module embedded in plugin
+type SBOMComponent struct {
+	Type     string              `json:"type"` // application or library
+	Name     string              `json:"name"`
+	Version  string              `json:"version,omitempty"`
+	PURL     string              `json:"purl,omitempty"` // package url, e.g. pkg:pypi/funppy@0.5.0
+	Licenses []SBOMLicenseChoice `json:"licenses,omitempty"`
+}
+
+// SBOMLicenseChoice is CycloneDX license entry
+type SBOMLicenseChoice struct {
+	License SBOMLicense `json:"license"`
+}
+
+// SBOMLicense is a license name declared by package metadata
+type SBOMLicense struct {
+	Name string `json:"name"`
+}
+
+// GenerateSBOM generates CycloneDX SBOM of python packages installed in venv
+// directory, or go modules embedded in go plugin binary (.bin/.so) build info,
+// go module licenses are detected from module cache if downloaded
+func GenerateSBOM(path string) (*SBOM, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "stat sbom target failed")
+	}
+
+	var main *SBOMComponent
+	var components []SBOMComponent
+	if info.IsDir() {
+		main = &SBOMComponent{Type: "application", Name: filepath.Base(path)}
+		components, err = venvComponents(path)
+	} else {
+		main, components, err = goBinaryComponents(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(components, func(i, j int) bool {
+		return components[i].Name < components[j].Name
+	})
+
+	return &SBOM{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.4",
+		Version:     1,
+		Metadata: SBOMMetadata{
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Component: main,
+		},
+		Components: components,
+	}, nil
+}
+
+// goBinaryComponents reads go modules from build info of go plugin binary
+func goBinaryComponents(path string) (*SBOMComponent, []SBOMComponent, error) {
+	info, err := buildinfo.ReadFile(path)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "read go build info of %s failed", path)
+	}
+
+	main := &SBOMComponent{
+		Type:    "application",
+		Name:    info.Main.Path,
+		Version: info.Main.Version,
+	}
+	if main.Name == "" {
+		main.Name = info.Path
+	}
+	components := make([]SBOMComponent, 0, len(info.Deps))
+	for _, dep := range info.Deps {
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+		component := SBOMComponent{
+			Type:    "library",
+			Name:    dep.Path,
+			Version: dep.Version,
+			PURL:    fmt.Sprintf("pkg:golang/%s@%s", dep.Path, dep.Version),
+		}
+		if license := goModuleLicense(dep.Path, dep.Version); license != "" {
+			component.Licenses = []SBOMLicenseChoice{{License: SBOMLicense{Name: license}}}
+		}
+		components = append(components, component)
+	}
+	return main, components, nil
+}
+
+// venvComponents reads python packages from dist-info metadata in venv site-packages
+func venvComponents(venv string) ([]SBOMComponent, error) {
+	var distInfos []string
+	for _, pattern := range []string{
+		filepath.Join(venv, "lib", "python*", "site-packages", "*.dist-info"),
+		filepath.Join(venv, "Lib", "site-packages", "*.dist-info"), // windows
+	} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		distInfos = append(distInfos, matches...)
+	}
+	if len(distInfos) == 0 {
+		return nil, fmt.Errorf("no python packages found in venv %s", venv)
+	}
+
+	components := make([]SBOMComponent, 0, len(distInfos))
+	for _, distInfo := range distInfos {
+		metadata, err := readPackageMetadata(filepath.Join(distInfo, "METADATA"))
+		if err != nil {
+			return nil, err
+		}
+		component := SBOMComponent{
+			Type:    "library",
+			Name:    metadata["Name"],
+			Version: metadata["Version"],
+			PURL: fmt.Sprintf("pkg:pypi/%s@%s",
+				strings.ToLower(strings.ReplaceAll(metadata["Name"], "_", "-")), metadata["Version"]),
+		}
+		license := metadata["License-Expression"]
+		if license == "" || license == "UNKNOWN" {
+			license = metadata["License"]
+		}
+		if license == "" || license == "UNKNOWN" {
+			license = metadata["License-Classifier"]
+		}
+		if license != "" && license != "UNKNOWN" {
+			component.Licenses = []SBOMLicenseChoice{{License: SBOMLicense{Name: license}}}
+		}
+		components = append(components, component)
+	}
+	return components, nil
+}
+
+// readPackageMetadata reads header fields of python core metadata file
+func readPackageMetadata(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "open python package metadata failed")
+	}
+	defer file.Close()
+
+	metadata := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break // description body follows headers
+		}
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			continue
+		}
+		// e.g. Classifier: License :: OSI Approved :: MIT License
+		if key == "Classifier" && strings.HasPrefix(value, "License ::") {
+			key = "License-Classifier"
+		}
+		if _, exists := metadata[key]; !exists {
+			metadata[key] = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "read python package metadata failed")
+	}
+	return metadata, nil
+}
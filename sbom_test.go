@@ -0,0 +1,61 @@
+package funplugin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateSBOMGoPlugin(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	sbom, err := GenerateSBOM(pluginBinPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "CycloneDX", sbom.BOMFormat)
+	assert.NotEmpty(t, sbom.Metadata.Component.Name)
+
+	var found bool
+	for _, c := range sbom.Components {
+		if c.Name == "github.com/hashicorp/go-plugin" {
+			found = true
+			assert.Equal(t, "pkg:golang/github.com/hashicorp/go-plugin@"+c.Version, c.PURL)
+		}
+	}
+	assert.True(t, found)
+}
+
+func TestGenerateSBOMVenv(t *testing.T) {
+	venv := t.TempDir()
+	sitePackages := filepath.Join(venv, "lib", "python3.11", "site-packages")
+	writeMetadata := func(distInfo, metadata string) {
+		dir := filepath.Join(sitePackages, distInfo)
+		assert.Nil(t, os.MkdirAll(dir, 0o755))
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "METADATA"), []byte(metadata), 0o644))
+	}
+	writeMetadata("grpcio-1.56.0.dist-info",
+		"Metadata-Version: 2.1\nName: grpcio\nVersion: 1.56.0\nLicense: Apache License 2.0\n\nLicense: not a header\n")
+	writeMetadata("funppy-0.5.0.dist-info",
+		"Metadata-Version: 2.1\nName: funppy\nVersion: 0.5.0\nLicense: UNKNOWN\n")
+
+	sbom, err := GenerateSBOM(venv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []SBOMComponent{
+		{Type: "library", Name: "funppy", Version: "0.5.0", PURL: "pkg:pypi/funppy@0.5.0"},
+		{Type: "library", Name: "grpcio", Version: "1.56.0", PURL: "pkg:pypi/grpcio@1.56.0",
+			Licenses: []SBOMLicenseChoice{{License: SBOMLicense{Name: "Apache License 2.0"}}}},
+	}, sbom.Components)
+}
+
+func TestGenerateSBOMUnsupported(t *testing.T) {
+	_, err := GenerateSBOM("lua/examples/debugtalk.lua")
+	assert.Error(t, err)
+	_, err = GenerateSBOM(t.TempDir())
+	assert.Error(t, err)
+}
@@ -0,0 +1,230 @@
+package funplugin
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cronDescriptors are predefined cron expressions
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronSchedule is a parsed cron expression with fields minute, hour, day of month,
+// month and day of week, or a fixed interval specified by @every
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64 // bit sets of matching values
+	domStar, dowStar              bool   // day of month or day of week is *
+	every                         time.Duration
+}
+
+// parseCron parses standard 5-field cron expression in local time, e.g. "*/15 9-18 * * 1-5",
+// descriptors such as @hourly and @daily, or fixed interval such as "@every 30s"
+func parseCron(spec string) (*cronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		every, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil || every <= 0 {
+			return nil, fmt.Errorf("invalid cron spec %q, @every requires positive duration", spec)
+		}
+		return &cronSchedule{every: every}, nil
+	}
+	if expr, ok := cronDescriptors[spec]; ok {
+		spec = expr
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron spec %q, expect 5 fields: minute hour day-of-month month day-of-week", spec)
+	}
+	s := &cronSchedule{domStar: fields[2] == "*", dowStar: fields[4] == "*"}
+	bounds := []struct {
+		bits     *uint64
+		min, max int
+	}{
+		{&s.minute, 0, 59},
+		{&s.hour, 0, 23},
+		{&s.dom, 1, 31},
+		{&s.month, 1, 12},
+		{&s.dow, 0, 7},
+	}
+	for i, b := range bounds {
+		bits, err := parseCronField(fields[i], b.min, b.max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron spec %q: %v", spec, err)
+		}
+		*b.bits = bits
+	}
+	// both 0 and 7 are sunday
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+// parseCronField parses comma separated values, ranges and steps, e.g. "1,5-10,*/15"
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		expr, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			expr = part[:i]
+		}
+
+		lo, hi := min, max
+		if expr != "*" {
+			bounds := strings.SplitN(expr, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			switch {
+			case len(bounds) == 2:
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid value %q", part)
+				}
+			case step == 1:
+				hi = lo
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("value %q out of range [%d, %d]", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// next returns the first time matching schedule after t,
+// or zero time if it never matches, e.g. on February 30
+func (s *cronSchedule) next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Add(s.every)
+	}
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches follows cron convention, day matches if either day of month or
+// day of week matches when both are restricted
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// ScheduledCall is a plugin function executed periodically, started by Schedule
+type ScheduledCall struct {
+	Spec     string
+	FuncName string
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{} // closed when scheduling goroutine exits
+}
+
+// Stop stops scheduling the function and waits for its running call
+func (c *ScheduledCall) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+	<-c.done
+}
+
+// Schedule executes plugin function periodically by cron spec for the lifetime of plugin,
+// e.g. "*/5 * * * *" or "@every 30s" to refresh tokens, until it is stopped or plugin quits.
+// Runs are delayed by random jitter set by WithScheduleJitter, and a run is skipped if the
+// previous one is still running. Errors are passed to handler of WithScheduleErrorHandler.
+func (p *interceptedPlugin) Schedule(spec, funcName string, args ...interface{}) (*ScheduledCall, error) {
+	schedule, err := parseCron(spec)
+	if err != nil {
+		return nil, err
+	}
+	if schedule.next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("cron spec %q never matches", spec)
+	}
+	if !p.Has(funcName) {
+		return nil, fmt.Errorf("function %s not found", funcName)
+	}
+
+	call := &ScheduledCall{Spec: spec, FuncName: funcName,
+		stop: make(chan struct{}), done: make(chan struct{})}
+	p.schedulesMu.Lock()
+	p.schedules = append(p.schedules, call)
+	p.schedulesMu.Unlock()
+
+	go func() {
+		defer close(call.done)
+		for {
+			now := time.Now()
+			delay := schedule.next(now).Sub(now)
+			if jitter := p.option.scheduleJitter; jitter > 0 {
+				delay += time.Duration(rand.Int63n(int64(jitter)))
+			}
+			timer := time.NewTimer(delay)
+			select {
+			case <-call.stop:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			logger.Debug("call scheduled plugin function", "spec", spec, "funcName", funcName)
+			if _, err := p.Call(funcName, args...); err != nil {
+				if handler := p.option.scheduleErrFn; handler != nil {
+					handler(funcName, err)
+				} else {
+					logger.Error("scheduled plugin call failed", "spec", spec, "funcName", funcName, "error", err)
+				}
+			}
+		}
+	}()
+	logger.Info("schedule plugin function", "spec", spec, "funcName", funcName)
+	return call, nil
+}
+
+// stopSchedules stops all scheduled calls before plugin quits
+func (p *interceptedPlugin) stopSchedules() {
+	p.schedulesMu.Lock()
+	schedules := p.schedules
+	p.schedules = nil
+	p.schedulesMu.Unlock()
+	for _, call := range schedules {
+		call.Stop()
+	}
+}
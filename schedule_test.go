@@ -0,0 +1,122 @@
+package funplugin
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCronNext(t *testing.T) {
+	// Wednesday
+	now := time.Date(2024, 5, 15, 10, 7, 30, 0, time.UTC)
+	testCases := []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2024, 5, 15, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 5, 15, 10, 15, 0, 0, time.UTC)},
+		{"0,5 9-18 * * *", time.Date(2024, 5, 15, 11, 0, 0, 0, time.UTC)},
+		{"30 2 * * *", time.Date(2024, 5, 16, 2, 30, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2024, 5, 16, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC)},
+		// either day of month or day of week matches
+		{"0 0 1 * 5", time.Date(2024, 5, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 5, 15, 11, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 90s", now.Add(90 * time.Second)},
+		// never matches
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tc := range testCases {
+		schedule, err := parseCron(tc.spec)
+		if !assert.Nil(t, err, tc.spec) {
+			continue
+		}
+		assert.Equal(t, tc.next, schedule.next(now), tc.spec)
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *",
+		"*/0 * * * *", "a * * * *", "@every", "@every -1s", "@weekday"} {
+		_, err := parseCron(spec)
+		assert.NotNil(t, err, spec)
+	}
+}
+
+// funcCalls returns number of calls to the function
+func funcCalls(plugin IPlugin, funcName string) int64 {
+	for _, funcStats := range plugin.Stats().Funcs {
+		if funcStats.Name == funcName {
+			return funcStats.Calls
+		}
+	}
+	return 0
+}
+
+func TestSchedule(t *testing.T) {
+	var mu sync.Mutex
+	var errs []error
+	plugin, err := Init("lua/examples/debugtalk.lua",
+		WithScheduleJitter(10*time.Millisecond),
+		WithScheduleErrorHandler(func(funcName string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, "divide", funcName)
+			errs = append(errs, err)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	_, err = plugin.Schedule("@every 1m", "not_found")
+	assert.NotNil(t, err)
+	_, err = plugin.Schedule("0 0 31 4 *", "sum_two_int", 1, 2)
+	assert.NotNil(t, err)
+
+	call, err := plugin.Schedule("@every 20ms", "sum_two_int", 1, 2)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "sum_two_int", call.FuncName)
+	failing, err := plugin.Schedule("@every 20ms", "divide", 1, 0)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	call.Stop()
+	failing.Stop()
+	calls := funcCalls(plugin, "sum_two_int")
+	assert.GreaterOrEqual(t, calls, int64(3))
+	mu.Lock()
+	assert.GreaterOrEqual(t, len(errs), 3)
+	mu.Unlock()
+
+	// stopped schedule is not called any more
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, calls, funcCalls(plugin, "sum_two_int"))
+}
+
+func TestScheduleStoppedOnQuit(t *testing.T) {
+	plugin, err := Init("lua/examples/debugtalk.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	call, err := plugin.Schedule("@every 10ms", "sum_two_int", 1, 2)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Nil(t, plugin.Quit())
+
+	select {
+	case <-call.done:
+	default:
+		t.Fatal("scheduled call should be stopped on quit")
+	}
+	// stopping again is a noop
+	call.Stop()
+}
@@ -0,0 +1,165 @@
+package funplugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ResultSchema describes expected function result after JSON decoding,
+// it is a small subset of JSON Schema
+type ResultSchema struct {
+	// Type is one of null, boolean, number, integer, string, array, object,
+	// empty type accepts any value
+	Type       string                   `json:"type,omitempty"`
+	Nullable   bool                     `json:"nullable,omitempty"`   // whether null is accepted besides Type
+	Items      *ResultSchema            `json:"items,omitempty"`      // schema of array items
+	Properties map[string]*ResultSchema `json:"properties,omitempty"` // schema of object properties
+	Required   []string                 `json:"required,omitempty"`   // required object properties
+}
+
+// SchemaError is returned when function result does not match its registered schema
+type SchemaError struct {
+	Func     string // function name
+	Path     string // JSON path of mismatched value, e.g. $.items[0].name
+	Expected string
+	Actual   string
+}
+
+func (e *SchemaError) Error() string {
+	msg := fmt.Sprintf("result schema mismatch at %s: expected %s, got %s",
+		e.Path, e.Expected, e.Actual)
+	if e.Func != "" {
+		msg = fmt.Sprintf("function %s %s", e.Func, msg)
+	}
+	return msg
+}
+
+// Validate checks value against schema, value is normalized to JSON types first
+func (s *ResultSchema) Validate(value interface{}) error {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return &SchemaError{Path: "$", Expected: "JSON value", Actual: fmt.Sprintf("%T", value)}
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(content, &normalized); err != nil {
+		return &SchemaError{Path: "$", Expected: "JSON value", Actual: fmt.Sprintf("%T", value)}
+	}
+	return s.validate("$", normalized)
+}
+
+func (s *ResultSchema) validate(path string, value interface{}) error {
+	if s == nil || s.Type == "" {
+		return nil
+	}
+	if value == nil && (s.Nullable || s.Type == "null") {
+		return nil
+	}
+
+	mismatch := func() error {
+		return &SchemaError{Path: path, Expected: s.Type, Actual: jsonType(value)}
+	}
+	switch s.Type {
+	case "null":
+		return mismatch()
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return mismatch()
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return mismatch()
+		}
+	case "integer":
+		if v, ok := value.(float64); !ok || v != math.Trunc(v) {
+			return mismatch()
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return mismatch()
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return mismatch()
+		}
+		for i, item := range items {
+			if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+				return err
+			}
+		}
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return mismatch()
+		}
+		for _, key := range s.Required {
+			if _, ok := object[key]; !ok {
+				return &SchemaError{Path: path + "." + key, Expected: "required property", Actual: "missing"}
+			}
+		}
+		keys := make([]string, 0, len(s.Properties))
+		for key := range s.Properties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys) // report mismatches deterministically
+		for _, key := range keys {
+			item, ok := object[key]
+			if !ok {
+				continue
+			}
+			if err := s.Properties[key].validate(path+"."+key, item); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported schema type: %s", s.Type)
+	}
+	return nil
+}
+
+// jsonType returns JSON type name of decoded JSON value
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// newSchemaInterceptor validates results of functions with registered schemas
+func newSchemaInterceptor(schemas map[string]*ResultSchema) callInterceptor {
+	return func(_ context.Context, next callHandler) callHandler {
+		return func(funcName string, args ...interface{}) (interface{}, error) {
+			result, err := next(funcName, args...)
+			schema, ok := schemas[funcName]
+			if err != nil || !ok {
+				return result, err
+			}
+			if err := schema.Validate(result); err != nil {
+				if schemaErr, ok := err.(*SchemaError); ok {
+					schemaErr.Func = funcName
+				}
+				logger.Error("validate function result failed", "funcName", funcName, "error", err)
+				return result, err
+			}
+			return result, nil
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package funplugin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResultSchema(t *testing.T) {
+	plugin, err := Init("lua/examples/debugtalk.lua",
+		WithResultSchema("sum_two_int", &ResultSchema{Type: "integer"}),
+		WithResultSchema("sum_two_string", &ResultSchema{Type: "integer"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	v, err := plugin.Call("sum_two_int", 1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 3.0, v)
+
+	_, err = plugin.Call("sum_two_int", 1.5, 2)
+	var schemaErr *SchemaError
+	if assert.True(t, errors.As(err, &schemaErr)) {
+		assert.Equal(t, "sum_two_int", schemaErr.Func)
+		assert.Equal(t, "number", schemaErr.Actual)
+	}
+
+	_, err = plugin.Call("sum_two_string", "a", "b")
+	assert.EqualError(t, err,
+		"function sum_two_string result schema mismatch at $: expected integer, got string")
+}
+
+func TestResultSchemaValidate(t *testing.T) {
+	schema := &ResultSchema{
+		Type:     "object",
+		Required: []string{"name"},
+		Properties: map[string]*ResultSchema{
+			"name": {Type: "string"},
+			"tags": {Type: "array", Items: &ResultSchema{Type: "string"}},
+			"note": {Type: "string", Nullable: true},
+		},
+	}
+
+	type user struct {
+		Name string      `json:"name"`
+		Tags []string    `json:"tags"`
+		Note interface{} `json:"note"`
+	}
+	assert.NoError(t, schema.Validate(user{Name: "a", Tags: []string{"x"}}))
+	assert.NoError(t, schema.Validate(map[string]interface{}{"name": "a"}))
+
+	err := schema.Validate(map[string]interface{}{"tags": []string{}})
+	assert.EqualError(t, err,
+		"result schema mismatch at $.name: expected required property, got missing")
+
+	err = schema.Validate(map[string]interface{}{"name": "a", "tags": []interface{}{"x", 1}})
+	var schemaErr *SchemaError
+	if assert.True(t, errors.As(err, &schemaErr)) {
+		assert.Equal(t, "$.tags[1]", schemaErr.Path)
+		assert.Equal(t, "integer", schemaErr.Actual)
+	}
+}
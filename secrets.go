@@ -0,0 +1,106 @@
+package funplugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+// passHostData passes secrets and plugin config to plugin process, it should be called
+// after cmd.Env is set, and the returned cleanup function should be called after cmd started.
+func (o *pluginOption) passHostData(cmd *exec.Cmd) (cleanup func(), err error) {
+	secretsCleanup, err := o.passSecrets(cmd)
+	if err != nil {
+		return nil, err
+	}
+	configCleanup, err := o.passPluginConfig(cmd)
+	if err != nil {
+		secretsCleanup()
+		return nil, err
+	}
+	return func() {
+		secretsCleanup()
+		configCleanup()
+	}, nil
+}
+
+// passSecrets passes secrets to plugin process through an inherited pipe, or a windows
+// named pipe, thus they never appear in argv or env dumps.
+func (o *pluginOption) passSecrets(cmd *exec.Cmd) (cleanup func(), err error) {
+	if len(o.secrets) == 0 {
+		return func() {}, nil
+	}
+	content, err := json.Marshal(o.secrets)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal secrets failed")
+	}
+	return o.passPipe(cmd, "secrets", content,
+		fungo.PluginSecretsFDEnvName, fungo.PluginSecretsPipeEnvName)
+}
+
+// passPipe writes content to plugin process through an inherited pipe, whose file descriptor
+// number is set to fdEnvName, or a windows named pipe, whose path is set to pipeEnvName
+func (o *pluginOption) passPipe(cmd *exec.Cmd, name string, content []byte,
+	fdEnvName, pipeEnvName string) (cleanup func(), err error) {
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+
+	if runtime.GOOS == "windows" {
+		// inherited file descriptors are not supported on windows
+		pipePath := fmt.Sprintf(`\\.\pipe\funplugin-%s-%d-%d`, name, os.Getpid(), time.Now().UnixNano())
+		listener, err := listenPipe(pipePath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "create %s pipe failed", name)
+		}
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			conn.Write(content)
+		}()
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", pipeEnvName, pipePath))
+		// plugin process reads content when it starts serving
+		return func() {
+			time.AfterFunc(o.getStartTimeout(), func() { listener.Close() })
+		}, nil
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, errors.Wrapf(err, "create %s pipe failed", name)
+	}
+	go func() {
+		defer w.Close()
+		w.Write(content)
+	}()
+	cmd.ExtraFiles = append(cmd.ExtraFiles, r)
+	// file descriptors 0-2 are stdin, stdout and stderr
+	fd := 2 + len(cmd.ExtraFiles)
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%d", fdEnvName, fd))
+	// plugin process holds its own copy of read end after started
+	return func() { r.Close() }, nil
+}
+
+// passPluginConfig passes plugin config to plugin process the same way as secrets,
+// thus config is not limited by env size
+func (o *pluginOption) passPluginConfig(cmd *exec.Cmd) (cleanup func(), err error) {
+	if len(o.pluginConfig) == 0 {
+		return func() {}, nil
+	}
+	content, err := json.Marshal(o.pluginConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal plugin config failed")
+	}
+	return o.passPipe(cmd, "config", content,
+		fungo.PluginConfigFDEnvName, fungo.PluginConfigPipeEnvName)
+}
@@ -0,0 +1,44 @@
+package funplugin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecretsGoPlugin(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	for _, transport := range []string{"", "stdio"} {
+		logFile := filepath.Join(t.TempDir(), "host.log")
+		plugin, err := Init(pluginBinPath,
+			WithTransport(transport),
+			WithDebugLogger(true),
+			WithLogFile(logFile),
+			WithSecrets(map[string]string{"api_token": "tok-7f3a9c"}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		header, err := plugin.Call("auth_header", "api_token")
+		assert.Nil(t, err)
+		assert.Equal(t, "Bearer tok-7f3a9c", header)
+		_, err = plugin.Call("auth_header", "missing")
+		assert.Error(t, err)
+		plugin.Quit()
+
+		// plugin logs forwarded to host are masked
+		content, err := os.ReadFile(logFile)
+		assert.Nil(t, err)
+		assert.Contains(t, string(content), "build auth header with token ******")
+		assert.NotContains(t, string(content), "tok-7f3a9c")
+	}
+}
+
+func TestSecretsUnsupported(t *testing.T) {
+	_, err := Init("lua/examples/debugtalk.lua", WithSecrets(map[string]string{"token": "x"}))
+	assert.EqualError(t, err, "secrets are only supported for local .bin/.py plugin processes")
+}
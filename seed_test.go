@@ -0,0 +1,30 @@
+package funplugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// randomUserID returns user id generated by plugin launched with options
+func randomUserID(t *testing.T, caseID string, options ...Option) interface{} {
+	plugin, err := Init(pluginBinPath, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	userID, err := plugin.Call("random_user_id", caseID)
+	assert.Nil(t, err)
+	return userID
+}
+
+func TestSeed(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	userID := randomUserID(t, "TC-1", WithSeed(42))
+	assert.Equal(t, userID, randomUserID(t, "TC-1", WithSeed(42)))
+	assert.NotEqual(t, userID, randomUserID(t, "TC-2", WithSeed(42)))
+	assert.NotEqual(t, userID, randomUserID(t, "TC-1", WithSeed(7)))
+}
@@ -0,0 +1,123 @@
+package funplugin
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// sharedPlugin is plugin entry of Manager shared by holders of Acquire
+type sharedPlugin struct {
+	managedPlugin
+	refs  int           // holders not released yet
+	ready chan struct{} // closed when plugin is initialized or failed
+	err   error         // error of initializing plugin
+}
+
+// Acquire returns plugin of path shared by all holders, e.g. goroutines or test suites
+// of a process reusing one plugin process. The plugin is initialized with options by the
+// first holder, and later holders get it regardless of their options. Each holder calls
+// Quit of the returned plugin to release it, and the plugin quits when the last holder
+// releases it or Manager shuts down.
+func (m *Manager) Acquire(path string, options ...Option) (IPlugin, error) {
+	key := path
+	if abs, err := filepath.Abs(path); err == nil {
+		key = abs
+	}
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, ErrManagerClosed
+	}
+	if entry, ok := m.shared[key]; ok {
+		entry.refs++
+		m.mu.Unlock()
+		// wait for plugin initialized by the first holder
+		<-entry.ready
+		if entry.err != nil {
+			return nil, entry.err
+		}
+		m.mu.Lock()
+		closed := m.closed
+		m.mu.Unlock()
+		if closed {
+			return nil, ErrManagerClosed
+		}
+		return &sharedHandle{IPlugin: entry.plugin, m: m, key: key, entry: entry}, nil
+	}
+	entry := &sharedPlugin{
+		managedPlugin: managedPlugin{ManagedPlugin: ManagedPlugin{Name: key, Path: path}},
+		refs:          1,
+		ready:         make(chan struct{}),
+	}
+	m.shared[key] = entry
+	pluginOptions := append(append([]Option{}, m.pluginOptions...), options...)
+	if m.cgroupParent != "" {
+		pluginOptions = append(pluginOptions, withRestartHook(func() { m.reattachCgroup(&entry.managedPlugin) }))
+	}
+	m.mu.Unlock()
+
+	plugin, err := Init(path, pluginOptions...)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	defer close(entry.ready)
+	if err == nil && m.closed {
+		// Shutdown has quit other plugins meanwhile
+		plugin.Quit()
+		err = ErrManagerClosed
+	}
+	if err != nil {
+		delete(m.shared, key)
+		entry.err = err
+		return nil, err
+	}
+	entry.plugin = plugin
+	entry.Type = plugin.Type()
+	entry.Loaded = time.Now()
+	m.attachCgroup(&entry.managedPlugin)
+	logger.Info("shared plugin acquired by manager", "path", path)
+	return &sharedHandle{IPlugin: plugin, m: m, key: key, entry: entry}, nil
+}
+
+// release drops a holder of shared plugin, and quits the plugin if it is the last one
+func (m *Manager) release(key string, entry *sharedPlugin) error {
+	m.mu.Lock()
+	if m.shared[key] != entry {
+		// quit by Shutdown already
+		m.mu.Unlock()
+		return nil
+	}
+	entry.refs--
+	if entry.refs > 0 {
+		m.mu.Unlock()
+		return nil
+	}
+	delete(m.shared, key)
+	m.mu.Unlock()
+	return quitManaged(&entry.managedPlugin)
+}
+
+// sharedHandle is plugin returned by Acquire to a holder, quitting it releases the holder
+type sharedHandle struct {
+	IPlugin
+	m     *Manager
+	key   string
+	entry *sharedPlugin
+	once  sync.Once
+}
+
+// Quit releases the holder, plugin quits when the last holder releases it.
+// Calling Quit again is a no-op.
+func (h *sharedHandle) Quit() error {
+	var err error
+	h.once.Do(func() {
+		err = h.m.release(h.key, h.entry)
+	})
+	return err
+}
+
+func (h *sharedHandle) GetNames() ([]string, error) {
+	return h.IPlugin.(IFuncLister).GetNames()
+}
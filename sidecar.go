@@ -0,0 +1,287 @@
+package funplugin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+const (
+	connectMaxRetries = 10
+	callMaxRetries    = 3
+)
+
+// remotePlugin connects plugin gRPC server by address without managing its process,
+// e.g. plugin running as kubernetes sidecar
+type remotePlugin struct {
+	conn            *grpc.ClientConn
+	funcCaller      fungo.IFuncCaller
+	cachedFunctions sync.Map // cache loaded functions to improve performance, key is function name, value is bool
+	addr            string
+}
+
+// Connect connects plugin gRPC server running as sidecar, addr defaults to
+// env HRP_PLUGIN_SIDECAR_ADDR. It retries until the sidecar is ready.
+func Connect(addr string, options ...Option) (IPlugin, error) {
+	option := &pluginOption{}
+	for _, o := range options {
+		o(option)
+	}
+	if err := option.validateConnect(); err != nil {
+		return nil, err
+	}
+
+	if addr == "" {
+		addr = os.Getenv(fungo.SidecarAddrEnvName)
+	}
+	if addr == "" {
+		return nil, fmt.Errorf("plugin address missing, set env %s", fungo.SidecarAddrEnvName)
+	}
+
+	option.initLogger()
+	p, err := connectPlugin(addr, option.jsonNumber)
+	if err != nil {
+		option.releaseLogger()
+		return nil, err
+	}
+	return wrapPlugin(p, option), nil
+}
+
+// connectPlugin connects plugin gRPC server with retries until it is ready,
+// JSON numbers in results are decoded in numberMode
+func connectPlugin(addr string, numberMode fungo.NumberMode) (*remotePlugin, error) {
+	logger.Info("connect plugin", "addr", addr)
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, errors.Wrap(err, "dial plugin failed")
+	}
+	raw, _ := (&fungo.GRPCPlugin{NumberMode: numberMode}).GRPCClient(context.Background(), nil, conn)
+	p := &remotePlugin{
+		conn:       conn,
+		funcCaller: raw.(fungo.IFuncCaller),
+		addr:       addr,
+	}
+
+	// wait for plugin server ready
+	backoff := 200 * time.Millisecond
+	for i := 0; ; i++ {
+		_, err = p.funcCaller.GetNames()
+		if err == nil {
+			break
+		}
+		if i == connectMaxRetries-1 {
+			conn.Close()
+			return nil, errors.Wrap(err, "connect plugin failed after max retries")
+		}
+		logger.Warn("plugin not ready, retry later", "addr", addr, "error", err, "backoff", backoff)
+		time.Sleep(backoff)
+		if backoff < 5*time.Second {
+			backoff *= 2
+		}
+	}
+
+	logger.Info("connect plugin success", "addr", addr)
+	return p, nil
+}
+
+func (p *remotePlugin) Type() string {
+	return "remote-grpc"
+}
+
+func (p *remotePlugin) Path() string {
+	return p.addr
+}
+
+func (p *remotePlugin) Has(funcName string) bool {
+	logger.Debug("check if plugin has function", "funcName", funcName)
+	flag, ok := p.cachedFunctions.Load(funcName)
+	if ok {
+		return flag.(bool)
+	}
+
+	funcNames, err := p.GetNames()
+	if err != nil {
+		return false
+	}
+
+	for _, name := range funcNames {
+		if name == funcName {
+			p.cachedFunctions.Store(funcName, true) // cache as exists
+			return true
+		}
+	}
+
+	p.cachedFunctions.Store(funcName, false) // cache as not exists
+	return false
+}
+
+func (p *remotePlugin) GetNames() (names []string, err error) {
+	err = p.retry(func() error {
+		names, err = p.funcCaller.GetNames()
+		return err
+	})
+	return
+}
+
+func (p *remotePlugin) Call(funcName string, args ...interface{}) (result interface{}, err error) {
+	err = p.retry(func() error {
+		result, err = p.funcCaller.Call(funcName, args...)
+		return err
+	})
+	return
+}
+
+func (p *remotePlugin) CallWithMetadata(md map[string]string, funcName string, args ...interface{}) (result interface{}, err error) {
+	err = p.retry(func() error {
+		result, err = p.funcCaller.(fungo.IMetadataCaller).CallWithMetadata(md, funcName, args...)
+		return err
+	})
+	return
+}
+
+func (p *remotePlugin) CallContext(ctx context.Context, funcName string, args ...interface{}) (result interface{}, err error) {
+	err = p.retry(func() error {
+		result, err = p.funcCaller.(fungo.IContextCaller).CallContext(ctx, funcName, args...)
+		return err
+	})
+	return
+}
+
+func (p *remotePlugin) CallDetailed(funcName string, args ...interface{}) (result *fungo.CallResult, err error) {
+	err = p.retry(func() error {
+		result, err = p.funcCaller.(fungo.IDetailedCaller).CallDetailed(funcName, args...)
+		return err
+	})
+	return
+}
+
+func (p *remotePlugin) CallDetailedContext(ctx context.Context, md map[string]string, funcName string, args ...interface{}) (result *fungo.CallResult, err error) {
+	err = p.retry(func() error {
+		result, err = p.funcCaller.(fungo.IDetailedContextCaller).CallDetailedContext(ctx, md, funcName, args...)
+		return err
+	})
+	return
+}
+
+// retry retries fn when plugin is temporarily unavailable, e.g. sidecar restarting
+func (p *remotePlugin) retry(fn func() error) error {
+	var err error
+	for i := 0; i < callMaxRetries; i++ {
+		err = fn()
+		if status.Code(err) != codes.Unavailable {
+			return err
+		}
+		logger.Warn("plugin unavailable, retry later", "addr", p.addr, "error", err)
+		time.Sleep(time.Duration(i+1) * 500 * time.Millisecond)
+	}
+	return err
+}
+
+func (p *remotePlugin) Quit() error {
+	logger.Info("close plugin connection", "addr", p.addr)
+	return p.conn.Close()
+}
+
+func (p *remotePlugin) StartHeartbeat() {
+	// remote plugin process is managed by kubernetes
+}
+
+// SidecarConfig configures pod manifest generated by SidecarManifest
+type SidecarConfig struct {
+	Name          string   // pod name
+	HostImage     string   // image of host container, e.g. hrp
+	HostCommand   []string // command of host container
+	PluginImage   string   // image of plugin sidecar container
+	PluginCommand []string // command of plugin sidecar container, e.g. ["python3", "debugtalk.py"]
+	Port          int      // plugin gRPC port, default 50051
+	HealthPort    int      // plugin health endpoints port, default 8081
+}
+
+var sidecarManifestTemplate = template.Must(template.New("sidecar").Parse(`apiVersion: v1
+kind: Pod
+metadata:
+  name: {{ .Name }}
+spec:
+  containers:
+    - name: host
+      image: {{ .HostImage }}
+{{- if .HostCommand }}
+      command: [{{ .HostCommand }}]
+{{- end }}
+      env:
+        - name: {{ .AddrEnv }}
+          value: "127.0.0.1:{{ .Port }}"
+    - name: plugin
+      image: {{ .PluginImage }}
+{{- if .PluginCommand }}
+      command: [{{ .PluginCommand }}]
+{{- end }}
+      env:
+        - name: {{ .AddrEnv }}
+          value: "0.0.0.0:{{ .Port }}"
+        - name: {{ .HealthEnv }}
+          value: ":{{ .HealthPort }}"
+      ports:
+        - name: grpc
+          containerPort: {{ .Port }}
+        - name: health
+          containerPort: {{ .HealthPort }}
+      readinessProbe:
+        httpGet:
+          path: /readyz
+          port: health
+        periodSeconds: 2
+      livenessProbe:
+        httpGet:
+          path: /healthz
+          port: health
+        periodSeconds: 10
+`))
+
+// SidecarManifest generates an example kubernetes pod manifest running
+// plugin as sidecar of host container
+func SidecarManifest(config SidecarConfig) (string, error) {
+	if config.Port == 0 {
+		config.Port = 50051
+	}
+	if config.HealthPort == 0 {
+		config.HealthPort = 8081
+	}
+	quote := func(items []string) string {
+		quoted := make([]string, len(items))
+		for i, item := range items {
+			quoted[i] = fmt.Sprintf("%q", item)
+		}
+		return strings.Join(quoted, ", ")
+	}
+
+	var buf bytes.Buffer
+	err := sidecarManifestTemplate.Execute(&buf, map[string]interface{}{
+		"Name":          config.Name,
+		"HostImage":     config.HostImage,
+		"HostCommand":   quote(config.HostCommand),
+		"PluginImage":   config.PluginImage,
+		"PluginCommand": quote(config.PluginCommand),
+		"Port":          config.Port,
+		"HealthPort":    config.HealthPort,
+		"AddrEnv":       fungo.SidecarAddrEnvName,
+		"HealthEnv":     fungo.HealthAddrEnvName,
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "generate sidecar manifest failed")
+	}
+	return buf.String(), nil
+}
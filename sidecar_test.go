@@ -0,0 +1,85 @@
+package funplugin
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+func freePort(t *testing.T) int {
+	port, err := freeLocalPort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return port
+}
+
+func TestConnectSidecar(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	addr := fmt.Sprintf("127.0.0.1:%d", freePort(t))
+	healthAddr := fmt.Sprintf("127.0.0.1:%d", freePort(t))
+	cmd := exec.Command(pluginBinPath)
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("%s=%s", fungo.SidecarAddrEnvName, addr),
+		fmt.Sprintf("%s=%s", fungo.HealthAddrEnvName, healthAddr),
+	)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cmd.Process.Signal(os.Interrupt)
+		cmd.Wait()
+	}()
+
+	// sidecar address is discovered from env, connecting retries until sidecar is ready
+	os.Setenv(fungo.SidecarAddrEnvName, addr)
+	defer os.Unsetenv(fungo.SidecarAddrEnvName)
+	plugin, err := Connect("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	assert.Equal(t, "remote-grpc", plugin.Type())
+	assertPlugin(t, plugin)
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		var resp *http.Response
+		for i := 0; i < 10; i++ {
+			if resp, err = http.Get("http://" + healthAddr + path); err == nil {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			resp.Body.Close()
+		}
+	}
+}
+
+func TestSidecarManifest(t *testing.T) {
+	manifest, err := SidecarManifest(SidecarConfig{
+		Name:          "hrp",
+		HostImage:     "hrp:latest",
+		PluginImage:   "debugtalk:latest",
+		PluginCommand: []string{"python3", "debugtalk.py"},
+	})
+	if !assert.NoError(t, err) {
+		t.Fatal()
+	}
+	assert.Contains(t, manifest, `value: "127.0.0.1:50051"`)
+	assert.Contains(t, manifest, `command: ["python3", "debugtalk.py"]`)
+	assert.Contains(t, manifest, "path: /readyz")
+	assert.Contains(t, manifest, "containerPort: 8081")
+	assert.NotContains(t, manifest, "command: []")
+}
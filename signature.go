@@ -0,0 +1,84 @@
+package funplugin
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SignaturePolicy verifies sigstore signatures of plugin artifacts and manifests with cosign,
+// either against a public key, or keyless against the signer identity and OIDC issuer
+// recorded in the fulcio certificate, e.g. a github actions workflow
+type SignaturePolicy struct {
+	Key                       string // public key path or KMS URI for key-based verification
+	CertificateIdentity       string // keyless signer identity, e.g. email or workflow URL
+	CertificateIdentityRegexp string // keyless signer identity regexp
+	CertificateOIDCIssuer     string // keyless OIDC issuer, e.g. https://token.actions.githubusercontent.com
+	Cosign                    string // cosign binary path, defaults to cosign in $PATH
+}
+
+// cosignArgs returns cosign verify-blob arguments, signature is read from sigstore bundle
+// <path>.sigstore.json or <path>.bundle, otherwise from <path>.sig with certificate <path>.pem
+func (p SignaturePolicy) cosignArgs(path string) ([]string, error) {
+	args := []string{"verify-blob"}
+	if p.Key != "" {
+		args = append(args, "--key", p.Key)
+	} else {
+		if p.CertificateIdentity == "" && p.CertificateIdentityRegexp == "" {
+			return nil, errors.New("signature policy requires key or certificate identity")
+		}
+		if p.CertificateOIDCIssuer == "" {
+			return nil, errors.New("signature policy requires certificate OIDC issuer for keyless verification")
+		}
+		if p.CertificateIdentity != "" {
+			args = append(args, "--certificate-identity", p.CertificateIdentity)
+		} else {
+			args = append(args, "--certificate-identity-regexp", p.CertificateIdentityRegexp)
+		}
+		args = append(args, "--certificate-oidc-issuer", p.CertificateOIDCIssuer)
+	}
+
+	if bundle := firstExisting(path+".sigstore.json", path+".bundle"); bundle != "" {
+		args = append(args, "--bundle", bundle)
+	} else if sig := firstExisting(path + ".sig"); sig != "" {
+		args = append(args, "--signature", sig)
+		if cert := firstExisting(path + ".pem"); cert != "" {
+			args = append(args, "--certificate", cert)
+		}
+	} else {
+		return nil, fmt.Errorf("signature of %s not found", path)
+	}
+	return append(args, path), nil
+}
+
+func firstExisting(paths ...string) string {
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// VerifySignature verifies sigstore signature of plugin artifact or manifest at path
+func VerifySignature(path string, policy SignaturePolicy) error {
+	args, err := policy.cosignArgs(path)
+	if err != nil {
+		return err
+	}
+	cosign := policy.Cosign
+	if cosign == "" {
+		cosign = "cosign"
+	}
+
+	logger.Info("verify plugin signature", "path", path, "args", args)
+	output, err := exec.Command(cosign, args...).CombinedOutput()
+	if err != nil {
+		return errors.Wrapf(err, "verify signature of %s failed: %s", path, strings.TrimSpace(string(output)))
+	}
+	logger.Info("verify plugin signature success", "path", path)
+	return nil
+}
@@ -0,0 +1,78 @@
+package funplugin
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCosignArgs(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "debugtalk.bin")
+	keyless := SignaturePolicy{
+		CertificateIdentity:   "https://github.com/lingcetech/funplugin/.github/workflows/release.yml@refs/heads/main",
+		CertificateOIDCIssuer: "https://token.actions.githubusercontent.com",
+	}
+
+	_, err := keyless.cosignArgs(path)
+	assert.EqualError(t, err, "signature of "+path+" not found")
+
+	assert.Nil(t, os.WriteFile(path+".sig", []byte("sig"), 0o644))
+	assert.Nil(t, os.WriteFile(path+".pem", []byte("cert"), 0o644))
+	args, err := keyless.cosignArgs(path)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"verify-blob",
+		"--certificate-identity", keyless.CertificateIdentity,
+		"--certificate-oidc-issuer", keyless.CertificateOIDCIssuer,
+		"--signature", path + ".sig", "--certificate", path + ".pem",
+		path,
+	}, args)
+
+	// sigstore bundle is preferred
+	assert.Nil(t, os.WriteFile(path+".sigstore.json", []byte("{}"), 0o644))
+	args, err = SignaturePolicy{Key: "cosign.pub"}.cosignArgs(path)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"verify-blob", "--key", "cosign.pub", "--bundle", path + ".sigstore.json", path,
+	}, args)
+
+	_, err = SignaturePolicy{CertificateIdentityRegexp: ".*"}.cosignArgs(path)
+	assert.EqualError(t, err, "signature policy requires certificate OIDC issuer for keyless verification")
+	_, err = SignaturePolicy{}.cosignArgs(path)
+	assert.Error(t, err)
+}
+
+func TestSignatureVerification(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake cosign script requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	// fake cosign accepts blobs unless they contain "tampered"
+	cosign := filepath.Join(dir, "cosign")
+	script := "#!/bin/sh\nfor last; do :; done\n" +
+		"grep -q tampered \"$last\" && { echo 'error: invalid signature when validating ASN.1 encoded signature' >&2; exit 1; }\nexit 0\n"
+	assert.Nil(t, os.WriteFile(cosign, []byte(script), 0o755))
+
+	path := filepath.Join(dir, "debugtalk.lua")
+	content, err := os.ReadFile("lua/examples/debugtalk.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, os.WriteFile(path, content, 0o644))
+	assert.Nil(t, os.WriteFile(path+".sigstore.json", []byte("{}"), 0o644))
+	policy := SignaturePolicy{Key: "cosign.pub", Cosign: cosign}
+
+	plugin, err := Init(path, WithSignatureVerification(policy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugin.Quit()
+
+	assert.Nil(t, os.WriteFile(path, append(content, []byte("\n-- tampered\n")...), 0o644))
+	_, err = Init(path, WithSignatureVerification(policy))
+	assert.ErrorContains(t, err, "invalid signature")
+}
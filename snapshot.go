@@ -0,0 +1,173 @@
+package funplugin
+
+import (
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxRecentErrors bounds recent call errors kept for plugin snapshot
+const maxRecentErrors = 20
+
+// CallError is a failed plugin call kept for plugin snapshot
+type CallError struct {
+	Time     time.Time `json:"time"`
+	Function string    `json:"function"`
+	Error    string    `json:"error"`
+}
+
+// ProcessInfo is plugin process launched by host
+type ProcessInfo struct {
+	Pid      int       `json:"pid"`
+	Running  bool      `json:"running"`
+	Restarts int       `json:"restarts"`            // restarts of plugin process after it exited or was killed
+	LastPing time.Time `json:"last_ping,omitempty"` // last time plugin server answered ping, zero if not pinged
+}
+
+// HostInfo is host process loading plugin
+type HostInfo struct {
+	Pid       int    `json:"pid"`
+	GoVersion string `json:"go_version"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+}
+
+// OptionsSnapshot is options of plugin, secret values, env values and plugin config values are omitted
+type OptionsSnapshot struct {
+	ConfigFile     string        `json:"config_file,omitempty"`
+	LogFile        string        `json:"log_file,omitempty"`
+	Python3        string        `json:"python3,omitempty"`
+	PythonPath     *PythonPath   `json:"python_path,omitempty"`
+	GRPCReflection bool          `json:"grpc_reflection,omitempty"`
+	JSONNumber     string        `json:"json_number,omitempty"`
+	Isolation      string        `json:"isolation,omitempty"`
+	StartTimeout   time.Duration `json:"start_timeout,omitempty"`
+	MaxConcurrency int           `json:"max_concurrency,omitempty"`
+	QueueSize      int           `json:"queue_size,omitempty"`
+	QueueTimeout   time.Duration `json:"queue_timeout,omitempty"`
+	RateLimited    bool          `json:"rate_limited,omitempty"`
+	Audited        bool          `json:"audited,omitempty"`
+	Traced         bool          `json:"traced,omitempty"`
+	Chaos          bool          `json:"chaos,omitempty"`
+	Seed           *int64        `json:"seed,omitempty"`
+	DetachedState  string        `json:"detached_state,omitempty"`
+	DaemonAddr     string        `json:"daemon_addr,omitempty"`
+	DockerImage    string        `json:"docker_image,omitempty"`
+	SSHHost        string        `json:"ssh_host,omitempty"`
+	ADBSerial      string        `json:"adb_serial,omitempty"`
+	AutoBuildDir   string        `json:"auto_build_dir,omitempty"`
+	EnvNames       []string      `json:"env_names,omitempty"`
+	SecretNames    []string      `json:"secret_names,omitempty"`
+	ConfigKeys     []string      `json:"config_keys,omitempty"`
+	DataFiles      []string      `json:"data_files,omitempty"`
+	ArtifactsDir   string        `json:"artifacts_dir,omitempty"`
+}
+
+// PluginSnapshot is serializable host side state of plugin for bug reports and support tooling
+type PluginSnapshot struct {
+	Time         time.Time       `json:"time"`
+	Type         string          `json:"type"`
+	Path         string          `json:"path"`
+	Transport    string          `json:"transport"`
+	Uptime       time.Duration   `json:"uptime"`
+	Options      OptionsSnapshot `json:"options"`
+	Stats        PluginStats     `json:"stats"`
+	Queue        *QueueStats     `json:"queue,omitempty"`
+	RecentErrors []CallError     `json:"recent_errors"`
+	Process      *ProcessInfo    `json:"process,omitempty"` // nil if plugin is not a local process
+	Host         HostInfo        `json:"host"`
+}
+
+// processInspector is implemented by plugins launching local plugin process
+type processInspector interface {
+	processInfo() *ProcessInfo
+}
+
+func (p *interceptedPlugin) Snapshot() PluginSnapshot {
+	snapshot := PluginSnapshot{
+		Time:         time.Now(),
+		Type:         p.Type(),
+		Path:         p.Path(),
+		Transport:    p.transport(),
+		Uptime:       time.Since(p.created),
+		Options:      p.option.snapshot(),
+		Stats:        p.Stats(),
+		RecentErrors: p.stats.recentErrors(),
+		Host: HostInfo{
+			Pid:       os.Getpid(),
+			GoVersion: runtime.Version(),
+			OS:        runtime.GOOS,
+			Arch:      runtime.GOARCH,
+		},
+	}
+	if p.queue != nil {
+		queue := p.queue.stats()
+		snapshot.Queue = &queue
+	}
+	if inspector, ok := p.pluginBackend.(processInspector); ok {
+		snapshot.Process = inspector.processInfo()
+	}
+	return snapshot
+}
+
+// transport returns transport between host and plugin
+func (p *interceptedPlugin) transport() string {
+	if p.option.transport != "" {
+		return p.option.transport
+	}
+	pluginType := p.Type()
+	switch {
+	case strings.HasPrefix(pluginType, "hashicorp-"), strings.HasPrefix(pluginType, "daemon-"):
+		return "hashicorp"
+	case strings.Contains(pluginType, "grpc"):
+		return "grpc"
+	default:
+		return "in-process"
+	}
+}
+
+func (o *pluginOption) snapshot() OptionsSnapshot {
+	snapshot := OptionsSnapshot{
+		ConfigFile:     o.configFile,
+		LogFile:        o.logFile,
+		Python3:        o.python3,
+		PythonPath:     o.pythonPath,
+		GRPCReflection: o.grpcReflection,
+		JSONNumber:     string(o.jsonNumber),
+		Isolation:      o.isolation,
+		StartTimeout:   o.startTimeout,
+		MaxConcurrency: o.maxConcurrency,
+		QueueSize:      o.queueSize,
+		QueueTimeout:   o.queueTimeout,
+		RateLimited:    o.rateLimit != nil || len(o.funcRateLimits) > 0,
+		Audited:        o.auditSink != nil,
+		Traced:         o.tracer != nil,
+		Chaos:          o.chaos != nil,
+		Seed:           o.seed,
+		DetachedState:  o.detachedState,
+		DaemonAddr:     o.daemonAddr,
+		DockerImage:    o.dockerImage,
+		SSHHost:        o.sshHost,
+		ADBSerial:      o.adbSerial,
+		AutoBuildDir:   o.autoBuildDir,
+		ArtifactsDir:   o.artifactsDir,
+	}
+	for name := range o.env {
+		snapshot.EnvNames = append(snapshot.EnvNames, name)
+	}
+	sort.Strings(snapshot.EnvNames)
+	for name := range o.secrets {
+		snapshot.SecretNames = append(snapshot.SecretNames, name)
+	}
+	sort.Strings(snapshot.SecretNames)
+	for key := range o.pluginConfig {
+		snapshot.ConfigKeys = append(snapshot.ConfigKeys, key)
+	}
+	sort.Strings(snapshot.ConfigKeys)
+	if len(o.dataFiles) > 0 {
+		snapshot.DataFiles = sortedDataFiles(o.dataFiles)
+	}
+	return snapshot
+}
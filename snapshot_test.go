@@ -0,0 +1,81 @@
+package funplugin
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPluginSnapshot(t *testing.T) {
+	plugin, err := Init("lua/examples/debugtalk.lua",
+		WithConcurrencyLimit(2, 10, time.Second), WithRateLimit(100, 10))
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	defer plugin.Quit()
+
+	_, err = plugin.Call("divide", 6, 3)
+	assert.Nil(t, err)
+	for i := 0; i < maxRecentErrors+5; i++ {
+		_, err = plugin.Call("divide", i, 0)
+		assert.NotNil(t, err)
+	}
+
+	snapshot := plugin.Snapshot()
+	assert.Equal(t, "lua-plugin", snapshot.Type)
+	assert.Equal(t, "lua/examples/debugtalk.lua", snapshot.Path)
+	assert.Equal(t, "in-process", snapshot.Transport)
+	assert.Equal(t, 2, snapshot.Options.MaxConcurrency)
+	assert.True(t, snapshot.Options.RateLimited)
+	assert.Equal(t, int64(maxRecentErrors+6), snapshot.Stats.Funcs[0].Calls)
+	assert.Equal(t, &QueueStats{MaxConcurrency: 2, QueueSize: 10}, snapshot.Queue)
+	assert.Len(t, snapshot.RecentErrors, maxRecentErrors)
+	assert.Equal(t, "divide", snapshot.RecentErrors[0].Function)
+	assert.Equal(t, "division by zero", snapshot.RecentErrors[0].Error)
+	assert.Nil(t, snapshot.Process)
+	assert.Equal(t, os.Getpid(), snapshot.Host.Pid)
+
+	// snapshot is serializable
+	_, err = json.Marshal(snapshot)
+	assert.Nil(t, err)
+}
+
+func TestPluginSnapshotOmitsSecrets(t *testing.T) {
+	plugin := wrapPlugin(&luaPlugin{}, &pluginOption{
+		secrets: map[string]string{"token": "s3cr3t"},
+		env:     map[string]string{"B": "env-value", "A": "1"},
+	})
+	snapshot := plugin.Snapshot()
+	assert.Equal(t, []string{"token"}, snapshot.Options.SecretNames)
+	assert.Equal(t, []string{"A", "B"}, snapshot.Options.EnvNames)
+
+	content, err := json.Marshal(snapshot)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(content), "s3cr3t")
+	assert.NotContains(t, string(content), "env-value")
+}
+
+func TestPluginSnapshotProcess(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	plugin, err := Init("fungo/examples/debugtalk.bin")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	snapshot := plugin.Snapshot()
+	assert.Equal(t, "hashicorp", snapshot.Transport)
+	if assert.NotNil(t, snapshot.Process) {
+		assert.True(t, snapshot.Process.Running)
+		assert.NotEqual(t, os.Getpid(), snapshot.Process.Pid)
+		assert.Greater(t, snapshot.Process.Pid, 0)
+	}
+
+	plugin.Quit()
+	if snapshot := plugin.Snapshot(); snapshot.Process != nil {
+		assert.False(t, snapshot.Process.Running)
+	}
+}
@@ -0,0 +1,211 @@
+package funplugin
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+// sshPlugin runs .bin/.py plugin on a remote machine in sidecar mode,
+// the plugin gRPC port is tunneled back to a random localhost port over SSH
+type sshPlugin struct {
+	*remotePlugin
+	cmd    *exec.Cmd // ssh session running plugin process
+	path   string    // plugin file path on remote machine
+	option *pluginOption
+}
+
+// sshOptions returns common ssh/scp options, batch mode avoids blocking on password prompts
+func sshOptions(option *pluginOption) []string {
+	args := []string{"-o", "BatchMode=yes"}
+	if option.sshKeyPath != "" {
+		args = append(args, "-i", option.sshKeyPath)
+	}
+	return args
+}
+
+// sshRunArgs returns ssh arguments launching plugin at remote path and forwarding localPort to it.
+// A tty is allocated so that the remote plugin process is hung up when the session is closed.
+func sshRunArgs(remotePath string, option *pluginOption, localPort, remotePort int) []string {
+	args := sshOptions(option)
+	args = append(args,
+		"-tt", "-o", "ExitOnForwardFailure=yes",
+		"-L", fmt.Sprintf("127.0.0.1:%d:127.0.0.1:%d", localPort, remotePort),
+		option.sshHost,
+	)
+	command := remoteEnvCommand(option, remotePort) + " exec "
+	if option.langType == langTypePython {
+		command += "python3 "
+	}
+	return append(args, command+shellQuote(remotePath))
+}
+
+// remoteEnvCommand returns shell command prefix of plugin process on remote machine or device,
+// which creates artifact directory and passes host info, sidecar address, random seed, data and artifact directories in env
+func remoteEnvCommand(option *pluginOption, remotePort int) string {
+	command := fmt.Sprintf("%s=127.0.0.1:%d", fungo.SidecarAddrEnvName, remotePort)
+	command = fmt.Sprintf("%s=%s %s", fungo.HostInfoEnvName, shellQuote(hostInfoJSON()), command)
+	if option.dataDir != "" {
+		command = fmt.Sprintf("%s=%s %s", fungo.DataDirEnvName, shellQuote(option.dataDir), command)
+	}
+	if option.seed != nil {
+		command = fmt.Sprintf("%s=%d %s", fungo.SeedEnvName, *option.seed, command)
+	}
+	if option.artifactDir != "" {
+		command = fmt.Sprintf("mkdir -p %s && %s=%s %s", shellQuote(option.artifactDir),
+			fungo.ArtifactDirEnvName, shellQuote(option.artifactDir), command)
+	}
+	return command
+}
+
+// remoteTempDir returns a new directory path in parent directory on remote machine or device
+func remoteTempDir(parent, suffix string) string {
+	return path.Join(parent, fmt.Sprintf("funplugin-%d-%d-%s", os.Getpid(), time.Now().UnixNano(), suffix))
+}
+
+// shellQuote quotes s for POSIX shell on remote machine
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// copyToRemote copies local plugin file to remote temp directory and returns remote path
+func copyToRemote(localPath string, option *pluginOption) (string, error) {
+	remotePath := path.Join("/tmp", fmt.Sprintf("funplugin-%d-%s", os.Getpid(), filepath.Base(localPath)))
+	args := append(sshOptions(option), "-p", localPath, fmt.Sprintf("%s:%s", option.sshHost, remotePath))
+	logger.Info("copy plugin to remote", "host", option.sshHost, "path", remotePath)
+	if output, err := exec.Command("scp", args...).CombinedOutput(); err != nil {
+		return "", errors.Wrap(fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output))),
+			"copy plugin to remote failed")
+	}
+	return remotePath, nil
+}
+
+// copyDataFilesToRemote copies data files to a new remote temp directory, which is set as data directory
+func copyDataFilesToRemote(option *pluginOption) error {
+	localDir, err := stageDataFiles(option.dataFiles)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(localDir)
+
+	remoteDir := remoteTempDir("/tmp", "data")
+	args := append(sshOptions(option), "-r", "-p", localDir, fmt.Sprintf("%s:%s", option.sshHost, remoteDir))
+	logger.Info("copy data files to remote", "host", option.sshHost, "dir", remoteDir)
+	if output, err := exec.Command("scp", args...).CombinedOutput(); err != nil {
+		return errors.Wrap(fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output))),
+			"copy data files to remote failed")
+	}
+	option.dataDir = remoteDir
+	return nil
+}
+
+func newSSHPlugin(pluginPath string, option *pluginOption) (*sshPlugin, error) {
+	switch filepath.Ext(pluginPath) {
+	case ".bin":
+		option.langType = langTypeGo
+	case ".py":
+		option.langType = langTypePython
+	default:
+		return nil, fmt.Errorf("ssh plugin only supports .bin and .py, got %s", pluginPath)
+	}
+	logger := logger.ResetNamed(fmt.Sprintf("ssh-%v", option.langType))
+
+	// copy local plugin file, otherwise locate it on remote machine
+	remotePath := filepath.ToSlash(pluginPath)
+	if _, err := os.Stat(pluginPath); err == nil {
+		remotePath, err = copyToRemote(pluginPath, option)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(option.dataFiles) > 0 {
+		if err := copyDataFilesToRemote(option); err != nil {
+			return nil, err
+		}
+	}
+
+	if option.artifactsDir != "" {
+		option.artifactDir = remoteTempDir("/tmp", "artifacts")
+	}
+
+	// the same port number is used on both sides
+	port, err := freeLocalPort()
+	if err != nil {
+		return nil, errors.Wrap(err, "get free port failed")
+	}
+	args := sshRunArgs(remotePath, option, port, port)
+	logger.Info("start remote plugin", "host", option.sshHost, "args", args)
+	cmd := exec.Command("ssh", args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "get ssh stdout failed")
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "get ssh stderr failed")
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, errors.Wrap(err, "start ssh session failed")
+	}
+	trackProcess(cmd, func() { _ = cmd.Process.Kill() })
+	go logPluginOutput(logger, stdout)
+	go logPluginOutput(logger, stderr)
+
+	remote, err := connectPlugin(fmt.Sprintf("127.0.0.1:%d", port), option.jsonNumber)
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		untrackProcess(cmd)
+		return nil, err
+	}
+	logger.Info("start remote plugin success", "host", option.sshHost, "path", remotePath)
+	return &sshPlugin{
+		remotePlugin: remote,
+		cmd:          cmd,
+		path:         remotePath,
+		option:       option,
+	}, nil
+}
+
+func (p *sshPlugin) Type() string {
+	return fmt.Sprintf("ssh-grpc-%v", p.option.langType)
+}
+
+func (p *sshPlugin) Path() string {
+	return p.path
+}
+
+func (p *sshPlugin) Quit() error {
+	logger.Info("close ssh session", "host", p.option.sshHost)
+	err := p.remotePlugin.Quit()
+	_ = p.cmd.Process.Kill()
+	_ = p.cmd.Wait()
+	untrackProcess(p.cmd)
+	if p.option.artifactDir != "" {
+		args := append(sshOptions(p.option), p.option.sshHost, "rm -rf "+shellQuote(p.option.artifactDir))
+		if output, err := exec.Command("ssh", args...).CombinedOutput(); err != nil {
+			logger.Error("remove remote artifact directory failed", "dir", p.option.artifactDir,
+				"error", err, "output", strings.TrimSpace(string(output)))
+		}
+	}
+	return err
+}
+
+// downloadArtifacts copies artifact directory on remote machine to local path
+func (p *sshPlugin) downloadArtifacts(localPath string) error {
+	args := append(sshOptions(p.option), "-r", "-p",
+		fmt.Sprintf("%s:%s", p.option.sshHost, p.option.artifactDir), localPath)
+	if output, err := exec.Command("scp", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
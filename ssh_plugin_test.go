@@ -0,0 +1,77 @@
+package funplugin
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSSHRunArgs(t *testing.T) {
+	option := &pluginOption{
+		langType:   langTypePython,
+		sshHost:    "tester@lab-machine",
+		sshKeyPath: "/home/tester/.ssh/id_ed25519",
+	}
+	args := sshRunArgs("/tmp/debug talk.py", option, 12345, 23456)
+	assert.Equal(t, []string{
+		"-o", "BatchMode=yes", "-i", "/home/tester/.ssh/id_ed25519",
+		"-tt", "-o", "ExitOnForwardFailure=yes",
+		"-L", "127.0.0.1:12345:127.0.0.1:23456",
+		"tester@lab-machine",
+		"HRP_PLUGIN_HOST_INFO=" + shellQuote(hostInfoJSON()) +
+			" HRP_PLUGIN_SIDECAR_ADDR=127.0.0.1:23456 exec python3 '/tmp/debug talk.py'",
+	}, args)
+}
+
+func TestSSHPluginUnsupported(t *testing.T) {
+	_, err := Init("debugtalk.lua", WithRemoteSSH("lab-machine", ""))
+	assert.EqualError(t, err, "ssh plugin only supports .bin and .py, got debugtalk.lua")
+}
+
+// TestSSHGoPlugin runs plugin with fake ssh/scp commands executing locally,
+// the forwarded port is the same as the remote port on localhost
+func TestSSHGoPlugin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ssh scripts require a POSIX shell")
+	}
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	binDir := t.TempDir()
+	fakeSSH := "#!/bin/sh\nfor last; do :; done\nexec sh -c \"$last\"\n"
+	fakeSCP := "#!/bin/sh\nfor last; do :; done\n" +
+		"eval src=\\${$(($# - 1))}\nexec cp -Rp \"${src#*:}\" \"${last#*:}\"\n"
+	assert.Nil(t, os.WriteFile(filepath.Join(binDir, "ssh"), []byte(fakeSSH), 0o755))
+	assert.Nil(t, os.WriteFile(filepath.Join(binDir, "scp"), []byte(fakeSCP), 0o755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	artifactsDir := t.TempDir()
+	plugin, err := Init(pluginBinPath, WithRemoteSSH("lab-machine", ""),
+		WithDataFiles(writeDataFiles(t)), WithArtifacts(artifactsDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		remoteArtifactDir := plugin.(*interceptedPlugin).option.artifactDir
+		plugin.Quit()
+		assert.NoDirExists(t, remoteArtifactDir)
+		os.Remove(plugin.Path())
+		os.RemoveAll(plugin.(*interceptedPlugin).option.dataDir)
+	}()
+
+	assert.Equal(t, "ssh-grpc-go", plugin.Type())
+	assertPlugin(t, plugin)
+	content, err := plugin.Call("read_data_file", "fixtures/users.csv")
+	assert.Nil(t, err)
+	assert.Equal(t, "id,name\n1,alice\n", content)
+
+	_, err = plugin.Call("write_artifact", "screenshots/login.png", "png")
+	assert.Nil(t, err)
+	names, err := plugin.CollectArtifacts()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"screenshots/login.png"}, names)
+	assertArtifact(t, artifactsDir, "screenshots/login.png", "png")
+}
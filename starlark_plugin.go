@@ -0,0 +1,197 @@
+package funplugin
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"go.starlark.net/starlark"
+)
+
+// starlarkMaxExecutionSteps limits computation of each function call,
+// which prevents untrusted expressions from running forever
+const starlarkMaxExecutionSteps = 100000000
+
+// starlarkPlugin runs starlark plugin script in process, the script is
+// hermetic and deterministic: no filesystem, network or load() access
+type starlarkPlugin struct {
+	globals starlark.StringDict // frozen after loading, safe for concurrent calls
+	path    string              // plugin file path
+}
+
+func newStarlarkPlugin(path string) (*starlarkPlugin, error) {
+	// logger
+	logger := logger.ResetNamed("starlark-plugin")
+
+	thread := &starlark.Thread{Name: path}
+	globals, err := starlark.ExecFile(thread, path, nil, nil)
+	if err != nil {
+		logger.Error("load starlark plugin failed", "path", path, "error", err)
+		return nil, errors.Wrap(err, "load starlark plugin failed")
+	}
+
+	logger.Info("load starlark plugin success", "path", path)
+	return &starlarkPlugin{
+		globals: globals,
+		path:    path,
+	}, nil
+}
+
+func (p *starlarkPlugin) Type() string {
+	return "starlark-plugin"
+}
+
+func (p *starlarkPlugin) Path() string {
+	return p.path
+}
+
+func (p *starlarkPlugin) Has(funcName string) bool {
+	logger.Debug("check if plugin has function", "funcName", funcName)
+	_, ok := p.globals[funcName].(starlark.Callable)
+	return ok
+}
+
+func (p *starlarkPlugin) GetNames() ([]string, error) {
+	var names []string
+	for _, name := range p.globals.Keys() {
+		if _, ok := p.globals[name].(starlark.Callable); ok {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// Call calls starlark global function in a new thread, fail() is returned as error
+func (p *starlarkPlugin) Call(funcName string, args ...interface{}) (interface{}, error) {
+	fn, ok := p.globals[funcName].(starlark.Callable)
+	if !ok {
+		return nil, fmt.Errorf("function %s not found", funcName)
+	}
+
+	starArgs := make(starlark.Tuple, len(args))
+	for i, arg := range args {
+		v, err := toStarlarkValue(reflect.ValueOf(arg))
+		if err != nil {
+			return nil, errors.Wrapf(err, "convert argument %d failed", i)
+		}
+		starArgs[i] = v
+	}
+
+	thread := &starlark.Thread{Name: funcName}
+	thread.SetMaxExecutionSteps(starlarkMaxExecutionSteps)
+	ret, err := starlark.Call(thread, fn, starArgs, nil)
+	if err != nil {
+		if evalErr, ok := err.(*starlark.EvalError); ok {
+			return nil, fmt.Errorf("%s", evalErr.Msg)
+		}
+		return nil, err
+	}
+	return fromStarlarkValue(ret)
+}
+
+func (p *starlarkPlugin) Quit() error {
+	// no need to quit for starlark plugin
+	return nil
+}
+
+func (p *starlarkPlugin) StartHeartbeat() {
+
+}
+
+func toStarlarkValue(v reflect.Value) (starlark.Value, error) {
+	if !v.IsValid() {
+		return starlark.None, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return starlark.None, nil
+		}
+		return toStarlarkValue(v.Elem())
+	case reflect.Bool:
+		return starlark.Bool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return starlark.MakeInt64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return starlark.MakeUint64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return starlark.Float(v.Float()), nil
+	case reflect.String:
+		return starlark.String(v.String()), nil
+	case reflect.Slice, reflect.Array:
+		items := make([]starlark.Value, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			item, err := toStarlarkValue(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return starlark.NewList(items), nil
+	case reflect.Map:
+		dict := starlark.NewDict(v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			item, err := toStarlarkValue(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			key := starlark.String(fmt.Sprintf("%v", iter.Key().Interface()))
+			if err := dict.SetKey(key, item); err != nil {
+				return nil, err
+			}
+		}
+		return dict, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %v", v.Type())
+	}
+}
+
+func fromStarlarkValue(v starlark.Value) (interface{}, error) {
+	switch value := v.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(value), nil
+	case starlark.Int:
+		if i, ok := value.Int64(); ok {
+			return i, nil
+		}
+		return value.Float(), nil
+	case starlark.Float:
+		if math.IsInf(float64(value), 0) || math.IsNaN(float64(value)) {
+			return nil, fmt.Errorf("invalid float result %v", value)
+		}
+		return float64(value), nil
+	case starlark.String:
+		return string(value), nil
+	case starlark.Indexable: // list, tuple
+		items := make([]interface{}, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			item, err := fromStarlarkValue(value.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	case *starlark.Dict:
+		items := make(map[string]interface{}, value.Len())
+		for _, kv := range value.Items() {
+			key, ok := starlark.AsString(kv[0])
+			if !ok {
+				key = kv[0].String()
+			}
+			item, err := fromStarlarkValue(kv[1])
+			if err != nil {
+				return nil, err
+			}
+			items[key] = item
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unsupported starlark result type %s", v.Type())
+	}
+}
@@ -0,0 +1,101 @@
+package funplugin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+const (
+	// defaultStartTimeout is the same as go-plugin default
+	defaultStartTimeout = time.Minute
+	// maxStderrTail bounds plugin stderr captured for startup errors
+	maxStderrTail = 4096
+)
+
+// ErrStartTimeout is returned when plugin does not complete handshake within
+// start timeout, e.g. slow python imports, see WithStartTimeout
+var ErrStartTimeout = errors.New("plugin start timeout")
+
+// getStartTimeout returns plugin start timeout configured by WithStartTimeout
+func (o *pluginOption) getStartTimeout() time.Duration {
+	if o.startTimeout > 0 {
+		return o.startTimeout
+	}
+	return defaultStartTimeout
+}
+
+// stderrTail keeps the last bytes of plugin stderr during startup,
+// and the complete python startup error report which may be longer
+type stderrTail struct {
+	mutex  sync.Mutex
+	buf    []byte
+	line   []byte // current line if it may be python startup error report
+	skip   bool   // whether current line is not python startup error report
+	report []byte // python startup error report line
+}
+
+func (t *stderrTail) Write(p []byte) (int, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > maxStderrTail {
+		t.buf = t.buf[len(t.buf)-maxStderrTail:]
+	}
+	t.scanReport(p)
+	return len(p), nil
+}
+
+// scanReport keeps line of python startup error report written by pythonLauncher
+func (t *stderrTail) scanReport(p []byte) {
+	prefix := []byte(pythonStartupErrorPrefix)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		chunk := p
+		if i >= 0 {
+			chunk = p[:i]
+		}
+		if !t.skip {
+			t.line = append(t.line, chunk...)
+			n := len(t.line)
+			if n > len(prefix) {
+				n = len(prefix)
+			}
+			t.skip = !bytes.Equal(t.line[:n], prefix[:n])
+		}
+		if i < 0 {
+			return
+		}
+		if !t.skip && len(t.line) > len(prefix) {
+			t.report = append([]byte(nil), t.line...)
+		}
+		t.line, t.skip = t.line[:0], false
+		p = p[i+1:]
+	}
+}
+
+// startupError returns python startup error reported in plugin stderr, or nil
+func (t *stderrTail) startupError(path string) *PythonStartupError {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return parsePythonStartupError(path, t.report)
+}
+
+func (t *stderrTail) String() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return strings.TrimSpace(string(t.buf))
+}
+
+// withStderr appends captured plugin stderr to startup error
+func withStderr(err error, stderr string) error {
+	if stderr == "" {
+		return err
+	}
+	return fmt.Errorf("%w, plugin stderr:\n%s", err, fungo.Mask(stderr))
+}
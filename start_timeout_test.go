@@ -0,0 +1,41 @@
+package funplugin
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartTimeout(t *testing.T) {
+	python3, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not found")
+	}
+	// plugin stuck in slow imports never completes handshake
+	path := filepath.Join(t.TempDir(), "slow.py")
+	script := "import sys, time\nprint('importing heavy module', file=sys.stderr, flush=True)\ntime.sleep(30)\n"
+	assert.Nil(t, os.WriteFile(path, []byte(script), 0o644))
+
+	start := time.Now()
+	_, err = Init(path, WithPython3(python3), WithStartTimeout(time.Second))
+	assert.True(t, errors.Is(err, ErrStartTimeout))
+	assert.Contains(t, err.Error(), "plugin start timeout after 1s")
+	assert.Contains(t, err.Error(), "importing heavy module")
+	// start is not retried after timeout
+	assert.Less(t, time.Since(start), 10*time.Second)
+}
+
+func TestStderrTail(t *testing.T) {
+	tail := &stderrTail{}
+	for i := 0; i < maxStderrTail; i++ {
+		tail.Write([]byte("x"))
+	}
+	tail.Write([]byte("last line\n"))
+	assert.Len(t, tail.buf, maxStderrTail)
+	assert.Regexp(t, "xlast line$", tail.String())
+}
@@ -0,0 +1,162 @@
+package funplugin
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// maxLatencySamples bounds latency samples kept per function,
+// percentiles of long running plugins are estimated with reservoir sampling
+const maxLatencySamples = 1024
+
+// FuncStats is call statistics of a plugin function during plugin lifetime
+type FuncStats struct {
+	Name   string        `json:"name"`
+	Calls  int64         `json:"calls"`
+	Errors int64         `json:"errors"`
+	Total  time.Duration `json:"total"` // total time spent in function
+	P50    time.Duration `json:"p50"`
+	P95    time.Duration `json:"p95"`
+}
+
+// ErrorRate returns ratio of failed calls
+func (s FuncStats) ErrorRate() float64 {
+	if s.Calls == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Calls)
+}
+
+// PluginStats is a snapshot of plugin call statistics
+type PluginStats struct {
+	Funcs []FuncStats `json:"funcs"` // sorted by total time in descending order
+}
+
+// Report formats statistics as a table, the slowest functions come first
+func (s PluginStats) Report() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "function\tcalls\terrors\terror rate\tp50\tp95\ttotal\t")
+	for _, f := range s.Funcs {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.1f%%\t%v\t%v\t%v\t\n",
+			f.Name, f.Calls, f.Errors, f.ErrorRate()*100,
+			f.P50.Round(time.Microsecond), f.P95.Round(time.Microsecond), f.Total.Round(time.Microsecond))
+	}
+	w.Flush()
+	return b.String()
+}
+
+// funcStats accumulates calls of a plugin function
+type funcStats struct {
+	calls   int64
+	errors  int64
+	total   time.Duration
+	samples []time.Duration
+}
+
+func (s *funcStats) add(elapsed time.Duration, err error) {
+	s.calls++
+	if err != nil {
+		s.errors++
+	}
+	s.total += elapsed
+	if len(s.samples) < maxLatencySamples {
+		s.samples = append(s.samples, elapsed)
+	} else if i := rand.Int63n(s.calls); i < maxLatencySamples {
+		s.samples[i] = elapsed
+	}
+}
+
+// callStats collects per-function call statistics on the host side
+type callStats struct {
+	mutex  sync.Mutex
+	funcs  map[string]*funcStats
+	errors []CallError // recent call errors, the oldest first
+}
+
+func newCallStats() *callStats {
+	return &callStats{funcs: make(map[string]*funcStats)}
+}
+
+// interceptor records elapsed time and error of each plugin call
+func (c *callStats) interceptor() callInterceptor {
+	return func(_ context.Context, next callHandler) callHandler {
+		return func(funcName string, args ...interface{}) (interface{}, error) {
+			start := time.Now()
+			result, err := next(funcName, args...)
+			elapsed := time.Since(start)
+
+			c.mutex.Lock()
+			s, ok := c.funcs[funcName]
+			if !ok {
+				s = &funcStats{}
+				c.funcs[funcName] = s
+			}
+			s.add(elapsed, err)
+			if err != nil {
+				if len(c.errors) == maxRecentErrors {
+					c.errors = c.errors[1:]
+				}
+				c.errors = append(c.errors, CallError{Time: start, Function: funcName, Error: err.Error()})
+			}
+			c.mutex.Unlock()
+			return result, err
+		}
+	}
+}
+
+// recentErrors returns copy of recent call errors
+func (c *callStats) recentErrors() []CallError {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]CallError{}, c.errors...)
+}
+
+func (c *callStats) stats() PluginStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	stats := PluginStats{Funcs: make([]FuncStats, 0, len(c.funcs))}
+	for name, s := range c.funcs {
+		samples := append([]time.Duration{}, s.samples...)
+		sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+		stats.Funcs = append(stats.Funcs, FuncStats{
+			Name:   name,
+			Calls:  s.calls,
+			Errors: s.errors,
+			Total:  s.total,
+			P50:    Percentile(samples, 0.50),
+			P95:    Percentile(samples, 0.95),
+		})
+	}
+	sort.Slice(stats.Funcs, func(i, j int) bool {
+		if stats.Funcs[i].Total != stats.Funcs[j].Total {
+			return stats.Funcs[i].Total > stats.Funcs[j].Total
+		}
+		return stats.Funcs[i].Name < stats.Funcs[j].Name
+	})
+	return stats
+}
+
+// Percentile returns nearest-rank percentile p in [0, 1] of sorted samples, 0 if there are
+// no samples, it is used for P50 and P95 of FuncStats
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
@@ -0,0 +1,72 @@
+package funplugin
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPercentile(t *testing.T) {
+	samples := make([]time.Duration, 100)
+	for i := range samples {
+		samples[i] = time.Duration(i+1) * time.Millisecond
+	}
+	assert.Equal(t, 50*time.Millisecond, Percentile(samples, 0.50))
+	assert.Equal(t, 95*time.Millisecond, Percentile(samples, 0.95))
+	assert.Equal(t, time.Duration(0), Percentile(nil, 0.95))
+}
+
+func TestCallStats(t *testing.T) {
+	stats := newCallStats()
+	call := stats.interceptor()(context.Background(), func(funcName string, args ...interface{}) (interface{}, error) {
+		if funcName == "slow" {
+			time.Sleep(10 * time.Millisecond)
+			return nil, errors.New("failed")
+		}
+		return nil, nil
+	})
+	for i := 0; i < 4; i++ {
+		call("fast")
+	}
+	call("slow")
+	call("slow")
+
+	funcs := stats.stats().Funcs
+	if !assert.Len(t, funcs, 2) {
+		t.FailNow()
+	}
+	// sorted by total time
+	assert.Equal(t, "slow", funcs[0].Name)
+	assert.EqualValues(t, 2, funcs[0].Calls)
+	assert.EqualValues(t, 2, funcs[0].Errors)
+	assert.Equal(t, 1.0, funcs[0].ErrorRate())
+	assert.GreaterOrEqual(t, funcs[0].P95, 10*time.Millisecond)
+	assert.Equal(t, "fast", funcs[1].Name)
+	assert.EqualValues(t, 4, funcs[1].Calls)
+	assert.Equal(t, 0.0, funcs[1].ErrorRate())
+}
+
+func TestPluginStats(t *testing.T) {
+	plugin, err := Init("lua/examples/debugtalk.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	assert.Empty(t, plugin.Stats().Funcs)
+	_, err = plugin.Call("sum_two_int", 1, 2)
+	assert.Nil(t, err)
+	_, err = plugin.Call("not_exist")
+	assert.Error(t, err)
+
+	stats := plugin.Stats()
+	assert.Len(t, stats.Funcs, 2)
+	report := stats.Report()
+	assert.True(t, strings.HasPrefix(strings.TrimSpace(report), "function"))
+	assert.Contains(t, report, "sum_two_int")
+	assert.Contains(t, report, "100.0%")
+}
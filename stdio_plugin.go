@@ -0,0 +1,315 @@
+package funplugin
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/pkg/errors"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+// stdioPlugin launches plugin process and speaks JSON-RPC over its stdin/stdout,
+// or over a windows named pipe, no localhost TCP listeners are required
+type stdioPlugin struct {
+	mu              sync.RWMutex  // guards process, which is replaced when plugin process restarts
+	restartMu       sync.Mutex    // serializes restarts of plugin process and Quit
+	process         *stdioProcess // current plugin process
+	quit            bool          // plugin is quit and not restarted any more, guarded by restartMu
+	cachedFunctions sync.Map      // cache loaded functions to improve performance, key is function name, value is bool
+	path            string        // plugin file path
+	option          *pluginOption
+	restarts        int32 // restarts of plugin process, accessed atomically
+}
+
+// stdioProcess is a launched plugin process and its JSON-RPC connection
+type stdioProcess struct {
+	cmd        *exec.Cmd
+	funcCaller fungo.IFuncCaller
+	conn       io.ReadWriteCloser
+	done       chan struct{} // closed when plugin process exited
+}
+
+func (proc *stdioProcess) exited() bool {
+	select {
+	case <-proc.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func newStdioPlugin(path string, option *pluginOption) (*stdioPlugin, error) {
+	p := &stdioPlugin{
+		path:   path,
+		option: option,
+	}
+
+	// logger
+	logger := logger.ResetNamed(p.Type())
+
+	if err := p.startPlugin(); err != nil {
+		return nil, err
+	}
+	logger.Info("load stdio plugin success", "path", path, "transport", option.transport)
+	return p, nil
+}
+
+// current returns current plugin process
+func (p *stdioPlugin) current() *stdioProcess {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.process
+}
+
+// startPlugin launches plugin process and replaces current one on success,
+// it is called on creation or with restartMu held
+func (p *stdioPlugin) startPlugin() error {
+	proc := &stdioProcess{cmd: p.option.command(p.path)}
+	proc.cmd.Env = append(p.option.environ(),
+		fmt.Sprintf("%s=%s", fungo.PluginTransportEnvName, p.option.transport))
+	cleanup, err := p.option.passHostData(proc.cmd)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if p.option.transport == fungo.TransportNamedPipe {
+		err = p.startPipePlugin(proc)
+	} else {
+		err = p.startStdioPlugin(proc)
+	}
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.process = proc
+	p.mu.Unlock()
+	p.cachedFunctions.Range(func(key, _ interface{}) bool {
+		p.cachedFunctions.Delete(key)
+		return true
+	})
+	return nil
+}
+
+// startStdioPlugin starts plugin process speaking JSON-RPC over its stdin/stdout
+func (p *stdioPlugin) startStdioPlugin(proc *stdioProcess) error {
+	stdin, err := proc.cmd.StdinPipe()
+	if err != nil {
+		return errors.Wrap(err, "get plugin stdin failed")
+	}
+	stdout, err := proc.cmd.StdoutPipe()
+	if err != nil {
+		return errors.Wrap(err, "get plugin stdout failed")
+	}
+	stderr, err := proc.cmd.StderrPipe()
+	if err != nil {
+		return errors.Wrap(err, "get plugin stderr failed")
+	}
+
+	if err := proc.cmd.Start(); err != nil {
+		return errors.Wrap(err, "start stdio plugin failed")
+	}
+	go logPluginOutput(logger, stderr)
+	p.waitPlugin(proc)
+
+	proc.conn = fungo.NewStdioConn(stdout, stdin)
+	proc.funcCaller = fungo.NewStdioClient(proc.conn, p.option.jsonNumber)
+	return nil
+}
+
+// startPipePlugin creates a named pipe and waits for plugin process to connect
+func (p *stdioPlugin) startPipePlugin(proc *stdioProcess) error {
+	pipePath := fmt.Sprintf(`\\.\pipe\funplugin-%d-%d`, os.Getpid(), time.Now().UnixNano())
+	listener, err := listenPipe(pipePath)
+	if err != nil {
+		return errors.Wrap(err, "create named pipe failed")
+	}
+	defer listener.Close()
+
+	proc.cmd.Env = append(proc.cmd.Env, fmt.Sprintf("%s=%s", fungo.PluginPipeEnvName, pipePath))
+	stdout, err := proc.cmd.StdoutPipe()
+	if err != nil {
+		return errors.Wrap(err, "get plugin stdout failed")
+	}
+	stderr, err := proc.cmd.StderrPipe()
+	if err != nil {
+		return errors.Wrap(err, "get plugin stderr failed")
+	}
+
+	if err := proc.cmd.Start(); err != nil {
+		return errors.Wrap(err, "start named pipe plugin failed")
+	}
+	go logPluginOutput(logger, stdout)
+	go logPluginOutput(logger, stderr)
+	p.waitPlugin(proc)
+
+	type acceptResult struct {
+		conn net.Conn
+		err  error
+	}
+	accepted := make(chan acceptResult, 1)
+	go func() {
+		conn, err := listener.Accept()
+		accepted <- acceptResult{conn, err}
+	}()
+
+	select {
+	case result := <-accepted:
+		if result.err != nil {
+			proc.cmd.Process.Kill()
+			return errors.Wrap(result.err, "accept named pipe connection failed")
+		}
+		proc.conn = result.conn
+	case <-proc.done:
+		return fmt.Errorf("plugin exited before connecting named pipe %s", pipePath)
+	case <-time.After(10 * time.Second):
+		proc.cmd.Process.Kill()
+		return fmt.Errorf("wait plugin connecting named pipe %s timeout", pipePath)
+	}
+
+	logger.Info("plugin connected named pipe", "pipe", pipePath)
+	proc.funcCaller = fungo.NewStdioClient(proc.conn, p.option.jsonNumber)
+	return nil
+}
+
+// waitPlugin closes proc.done when plugin process exited
+func (p *stdioPlugin) waitPlugin(proc *stdioProcess) {
+	cmd := proc.cmd
+	trackProcess(cmd, func() { cmd.Process.Kill() })
+	done := make(chan struct{})
+	go func() {
+		err := cmd.Wait()
+		untrackProcess(cmd)
+		logger.Info("plugin process exited", "path", p.path, "error", err)
+		close(done)
+	}()
+	proc.done = done
+}
+
+// logPluginOutput forwards plugin logs to host logger, the logger is passed
+// in since the global one is replaced when another plugin is initialized
+func logPluginOutput(logger hclog.Logger, r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		logger.Debug(scanner.Text())
+	}
+}
+
+func (p *stdioPlugin) processInfo() *ProcessInfo {
+	proc := p.current()
+	if proc == nil || proc.cmd.Process == nil {
+		return nil
+	}
+	return &ProcessInfo{Pid: proc.cmd.Process.Pid, Running: !proc.exited(),
+		Restarts: int(atomic.LoadInt32(&p.restarts))}
+}
+
+func (p *stdioPlugin) Type() string {
+	return fmt.Sprintf("%s-%v", p.option.transport, p.option.langType)
+}
+
+func (p *stdioPlugin) Path() string {
+	return p.path
+}
+
+func (p *stdioPlugin) Has(funcName string) bool {
+	logger.Debug("check if plugin has function", "funcName", funcName)
+	flag, ok := p.cachedFunctions.Load(funcName)
+	if ok {
+		return flag.(bool)
+	}
+
+	funcNames, err := p.current().funcCaller.GetNames()
+	if err != nil {
+		return false
+	}
+
+	for _, name := range funcNames {
+		if name == funcName {
+			p.cachedFunctions.Store(funcName, true) // cache as exists
+			return true
+		}
+	}
+
+	p.cachedFunctions.Store(funcName, false) // cache as not exists
+	return false
+}
+
+func (p *stdioPlugin) GetNames() ([]string, error) {
+	return p.current().funcCaller.GetNames()
+}
+
+func (p *stdioPlugin) Call(funcName string, args ...interface{}) (interface{}, error) {
+	return p.current().funcCaller.Call(funcName, args...)
+}
+
+func (p *stdioPlugin) StartHeartbeat() {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if !p.current().exited() {
+			continue
+		}
+		restarted, err := p.restartExited()
+		if err != nil {
+			logger.Error("restart stdio plugin failed", "error", err)
+			break
+		}
+		if !restarted {
+			// plugin is quit
+			break
+		}
+	}
+}
+
+// restartExited launches a new plugin process unless plugin is quit
+func (p *stdioPlugin) restartExited() (restarted bool, err error) {
+	// restart hook runs after restart lock is released
+	defer func() {
+		if restarted {
+			p.option.restarted()
+		}
+	}()
+	p.restartMu.Lock()
+	defer p.restartMu.Unlock()
+	if p.quit {
+		return false, nil
+	}
+	logger.Error("plugin exited, restarting...")
+	if err := p.startPlugin(); err != nil {
+		return false, err
+	}
+	atomic.AddInt32(&p.restarts, 1)
+	return true, nil
+}
+
+func (p *stdioPlugin) Quit() error {
+	logger.Info("quit stdio plugin process")
+	// wait for restart in progress, and plugin process is not restarted afterwards
+	p.restartMu.Lock()
+	defer p.restartMu.Unlock()
+	p.quit = true
+	proc := p.current()
+	// closing stdin or named pipe makes plugin server exit
+	proc.conn.Close()
+
+	select {
+	case <-proc.done:
+	case <-time.After(2 * time.Second):
+		logger.Warn("stdio plugin not exited, kill it")
+		proc.cmd.Process.Kill()
+		<-proc.done
+	}
+	return nil
+}
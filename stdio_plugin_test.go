@@ -0,0 +1,109 @@
+package funplugin
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+func TestStdioGoPlugin(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	plugin, err := Init(pluginBinPath, WithTransport("stdio"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	assert.Equal(t, "stdio-go", plugin.Type())
+	assertPlugin(t, plugin)
+
+	_, err = plugin.Call("not_exist")
+	assert.Error(t, err)
+	assert.False(t, fungo.IsUserError(err))
+
+	_, err = plugin.Call("assert_equal", "a", "b")
+	assert.True(t, fungo.IsUserError(err))
+}
+
+func TestStdioPluginRestart(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	plugin, err := Init(pluginBinPath, WithTransport("stdio"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := backendOf(plugin).(*stdioPlugin)
+
+	// calls and health checks run while plugin process restarts
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				_, _ = plugin.Call("sum_two_int", 1, 2)
+				_ = plugin.Snapshot()
+				_ = plugin.Has("sum_two_int")
+			}
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		proc := backend.current()
+		proc.cmd.Process.Kill()
+		<-proc.done
+		restarted, err := backend.restartExited()
+		assert.True(t, restarted)
+		assert.Nil(t, err)
+	}
+	close(done)
+	wg.Wait()
+
+	result, err := plugin.Call("sum_two_int", 1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(3), result)
+	assert.Equal(t, 3, plugin.Snapshot().Process.Restarts)
+
+	// quit plugin is not restarted
+	assert.Nil(t, plugin.Quit())
+	restarted, err := backend.restartExited()
+	assert.False(t, restarted)
+	assert.Nil(t, err)
+}
+
+func TestUnsupportedTransport(t *testing.T) {
+	_, err := Init(pluginBinPath, WithTransport("carrier-pigeon"))
+	assert.EqualError(t, err, "unsupported plugin transport: carrier-pigeon")
+}
+
+func TestNamedPipeGoPlugin(t *testing.T) {
+	if runtime.GOOS != "windows" {
+		_, err := Init(pluginBinPath, WithTransport("npipe"))
+		assert.EqualError(t, err, "plugin transport npipe is only supported on windows")
+		return
+	}
+
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	plugin, err := Init(pluginBinPath, WithTransport("npipe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	assert.Equal(t, "npipe-go", plugin.Type())
+	assertPlugin(t, plugin)
+}
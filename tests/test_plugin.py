@@ -0,0 +1,55 @@
+import io
+import json
+
+import pytest
+
+import funppy
+from funppy.plugin import _serve_jsonrpc, call_function, encode_value
+
+
+def test_tuple_is_value():
+    funppy.register("min_max", lambda *args: (min(args), max(args)))
+    assert call_function("min_max", [3, 1, 2]) == (1, 3)
+    assert encode_value(call_function("min_max", [3, 1, 2])) == b"[1, 3]"
+
+    # tuple of value and None is not mistaken for result with error
+    funppy.register("pair", lambda value: (value, None))
+    assert call_function("pair", [1]) == (1, None)
+
+
+def test_result():
+    def divide(a, b):
+        if b == 0:
+            return funppy.Result(None, "division by zero")
+        return funppy.Result(a / b)
+
+    funppy.register("divide", divide)
+    assert call_function("divide", [6, 4]) == 1.5
+    with pytest.raises(funppy.UserError, match="division by zero"):
+        call_function("divide", [1, 0])
+
+
+def test_result_exception():
+    funppy.register("lookup", lambda key: funppy.Result(None, KeyError(key)))
+    with pytest.raises(funppy.UserError) as info:
+        call_function("lookup", ["user"])
+    # type name of returned exception is kept
+    assert info.value.type_name == "KeyError"
+
+
+def test_serve_jsonrpc_parse_error():
+    funppy.register("sum_two", lambda a, b: a + b)
+    reader = io.StringIO(
+        "not json\n"
+        '[1, 2]\n'
+        '{"method": "Plugin.Call", "params": [["sum_two", 1, 2]], "id": 1}\n'
+    )
+    writer = io.StringIO()
+    _serve_jsonrpc(reader, writer)
+
+    # malformed requests are answered and the server keeps serving
+    parse_error, invalid, result = [json.loads(line) for line in writer.getvalue().splitlines()]
+    assert parse_error["id"] is None
+    assert parse_error["error"]["code"] == -32700
+    assert invalid["error"] == "Request is not a JSON object!"
+    assert result == {"id": 1, "result": 3, "error": None}
@@ -0,0 +1,143 @@
+package funplugin
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TraceEvent is a Chrome trace event, see Trace Event Format,
+// timestamps are microseconds since tracer created
+type TraceEvent struct {
+	Name  string                 `json:"name"`
+	Cat   string                 `json:"cat,omitempty"`
+	Phase string                 `json:"ph"` // B begin, E end, M metadata
+	Ts    int64                  `json:"ts"`
+	Pid   int                    `json:"pid"`
+	Tid   int                    `json:"tid"`
+	Args  map[string]interface{} `json:"args,omitempty"`
+}
+
+// ChromeTracer writes begin/end events of plugin calls in Chrome trace JSON array format,
+// which is loaded by chrome://tracing and Perfetto UI. Concurrent calls are traced on
+// separate threads, thus one tracer may be shared by plugins of a whole test run.
+type ChromeTracer struct {
+	mutex   sync.Mutex
+	w       io.Writer
+	closer  io.Closer // file opened by NewChromeTraceFile
+	start   time.Time
+	pid     int
+	threads []bool // busy threads of concurrent calls
+	count   int    // written events
+	err     error  // first write error
+}
+
+// NewChromeTracer writes trace events to w, the closing bracket is written by Close,
+// trace of crashed process without it is still loaded by trace viewers
+func NewChromeTracer(w io.Writer) *ChromeTracer {
+	t := &ChromeTracer{w: w, start: time.Now(), pid: os.Getpid()}
+	t.write(TraceEvent{Name: "process_name", Phase: "M", Pid: t.pid,
+		Args: map[string]interface{}{"name": "funplugin host"}})
+	return t
+}
+
+// NewChromeTraceFile writes trace events to file created at path
+func NewChromeTraceFile(path string) (*ChromeTracer, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "create trace file failed")
+	}
+	t := NewChromeTracer(file)
+	t.closer = file
+	return t, nil
+}
+
+func (t *ChromeTracer) write(event TraceEvent) {
+	if t.err != nil {
+		return
+	}
+	content, err := json.Marshal(event)
+	if err != nil {
+		t.err = errors.Wrap(err, "marshal trace event failed")
+		return
+	}
+	prefix := ",\n"
+	if t.count == 0 {
+		prefix = "[\n"
+	}
+	if _, err := io.WriteString(t.w, prefix+string(content)); err != nil {
+		t.err = errors.Wrap(err, "write trace event failed")
+		return
+	}
+	t.count++
+}
+
+// begin writes begin event of call on a free thread and returns the thread id
+func (t *ChromeTracer) begin(funcName, pluginType, pluginPath string) int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	tid := 0
+	for tid < len(t.threads) && t.threads[tid] {
+		tid++
+	}
+	if tid == len(t.threads) {
+		t.threads = append(t.threads, false)
+		t.write(TraceEvent{Name: "thread_name", Phase: "M", Pid: t.pid, Tid: tid + 1,
+			Args: map[string]interface{}{"name": "plugin calls"}})
+	}
+	t.threads[tid] = true
+	t.write(TraceEvent{Name: funcName, Cat: pluginType, Phase: "B",
+		Ts: time.Since(t.start).Microseconds(), Pid: t.pid, Tid: tid + 1,
+		Args: map[string]interface{}{"plugin": pluginPath}})
+	return tid + 1
+}
+
+// end writes end event of call and releases its thread
+func (t *ChromeTracer) end(funcName, pluginType string, tid int, callErr error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	event := TraceEvent{Name: funcName, Cat: pluginType, Phase: "E",
+		Ts: time.Since(t.start).Microseconds(), Pid: t.pid, Tid: tid}
+	if callErr != nil {
+		event.Args = map[string]interface{}{"error": callErr.Error()}
+	}
+	t.write(event)
+	t.threads[tid-1] = false
+}
+
+// Close writes closing bracket of JSON array and closes file opened by NewChromeTraceFile,
+// the first error writing events is returned
+func (t *ChromeTracer) Close() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.err == nil {
+		if _, err := io.WriteString(t.w, "\n]\n"); err != nil {
+			t.err = errors.Wrap(err, "write trace event failed")
+		}
+	}
+	if t.closer != nil {
+		if err := t.closer.Close(); err != nil && t.err == nil {
+			t.err = errors.Wrap(err, "close trace file failed")
+		}
+	}
+	return t.err
+}
+
+func newTraceInterceptor(p pluginBackend, tracer *ChromeTracer) callInterceptor {
+	return func(_ context.Context, next callHandler) callHandler {
+		return func(funcName string, args ...interface{}) (interface{}, error) {
+			tid := tracer.begin(funcName, p.Type(), p.Path())
+			result, err := next(funcName, args...)
+			tracer.end(funcName, p.Type(), tid, err)
+			return result, err
+		}
+	}
+}
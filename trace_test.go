@@ -0,0 +1,94 @@
+package funplugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChromeTrace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.json")
+	tracer, err := NewChromeTraceFile(path)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	plugin, err := Init("lua/examples/debugtalk.lua", WithTrace(tracer))
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	_, err = plugin.Call("divide", 6, 3)
+	assert.Nil(t, err)
+	_, err = plugin.Call("divide", 1, 0)
+	assert.NotNil(t, err)
+	assert.Nil(t, plugin.Quit())
+	assert.Nil(t, tracer.Close())
+
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	var events []TraceEvent
+	if !assert.Nil(t, json.Unmarshal(content, &events), string(content)) {
+		t.FailNow()
+	}
+	// process and thread names, then begin/end of two calls
+	if !assert.Len(t, events, 6) {
+		t.FailNow()
+	}
+	assert.Equal(t, "process_name", events[0].Name)
+	assert.Equal(t, "thread_name", events[1].Name)
+	for i, phase := range []string{"B", "E", "B", "E"} {
+		event := events[i+2]
+		assert.Equal(t, "divide", event.Name)
+		assert.Equal(t, phase, event.Phase)
+		assert.Equal(t, os.Getpid(), event.Pid)
+		assert.Equal(t, 1, event.Tid)
+		assert.Equal(t, plugin.Type(), event.Cat)
+		assert.LessOrEqual(t, events[i+1].Ts, event.Ts)
+	}
+	assert.Equal(t, "lua/examples/debugtalk.lua", events[2].Args["plugin"])
+	assert.Nil(t, events[3].Args)
+	assert.Equal(t, "division by zero", events[5].Args["error"])
+}
+
+func TestChromeTraceConcurrentCalls(t *testing.T) {
+	var buf bytes.Buffer
+	tracer := NewChromeTracer(&buf)
+	p := &blockingPlugin{release: make(chan struct{})}
+	plugin := wrapPlugin(p, &pluginOption{tracer: tracer})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			plugin.Call("slow")
+		}()
+	}
+	// make sure calls overlap
+	time.Sleep(50 * time.Millisecond)
+	close(p.release)
+	wg.Wait()
+	assert.Nil(t, tracer.Close())
+
+	var events []TraceEvent
+	if !assert.Nil(t, json.Unmarshal(buf.Bytes(), &events)) {
+		t.FailNow()
+	}
+	// overlapping calls are traced on separate threads, begin/end balanced per thread
+	depth := map[int]int{}
+	for _, event := range events {
+		switch event.Phase {
+		case "B":
+			depth[event.Tid]++
+			assert.Equal(t, 1, depth[event.Tid])
+		case "E":
+			depth[event.Tid]--
+		}
+	}
+	assert.Equal(t, map[int]int{1: 0, 2: 0, 3: 0}, depth)
+}
@@ -0,0 +1,162 @@
+package funplugin
+
+import (
+	"fmt"
+)
+
+// validate detects option combinations making no sense for plugin path,
+// which would be silently ignored otherwise
+func (o *pluginOption) validate(path string) error {
+	ext, err := pluginExt(path)
+	if err != nil {
+		return err
+	}
+	process := ext == ".bin" || ext == ".py" // plugin launched as a process
+
+	remotes := 0
+	for _, enabled := range []bool{o.dockerImage != "", o.sshHost != "", o.adbEnabled} {
+		if enabled {
+			remotes++
+		}
+	}
+	if remotes > 1 {
+		return fmt.Errorf("WithDockerImage, WithRemoteSSH and WithADB are mutually exclusive")
+	}
+	remote := remotes > 0
+	if len(o.dockerArgs) > 0 && o.dockerImage == "" {
+		return fmt.Errorf("WithDockerArgs requires WithDockerImage")
+	}
+
+	if o.detachedState != "" && o.daemonAddr != "" {
+		return fmt.Errorf("WithDetached and WithDaemon are mutually exclusive")
+	}
+	if (o.detachedState != "" || o.daemonAddr != "") && (remote || !process) {
+		return fmt.Errorf("detached mode only supports local .bin/.py plugins, got %s", path)
+	}
+
+	if o.pythonPath != nil && (ext != ".py" || remote || o.daemonAddr != "") {
+		return fmt.Errorf("WithPythonPath only applies to local .py plugins launched by host, got %s", path)
+	}
+	if o.python3 != "" && (ext != ".py" || remote) {
+		return fmt.Errorf("WithPython3 only applies to local .py plugins, got %s", path)
+	}
+	if o.transport != "" && (remote || !process) {
+		return fmt.Errorf("transport %s only applies to local .bin/.py plugins, got %s", o.transport, path)
+	}
+	if o.grpcReflection && (o.transport != "" || remote || !process) {
+		return fmt.Errorf("WithGRPCReflection only applies to local .bin/.py plugins over hashicorp gRPC")
+	}
+	if o.isolation != "" && (remote || !process || o.daemonAddr != "") {
+		return fmt.Errorf("WithIsolation only applies to .bin/.py plugin processes launched by host")
+	}
+	if o.startTimeout != 0 && (!process || o.daemonAddr != "") {
+		return fmt.Errorf("WithStartTimeout only applies to .bin/.py plugin processes launched by host")
+	}
+	if len(o.env) > 0 && (!process || o.sshHost != "" || o.adbEnabled || o.daemonAddr != "") {
+		return fmt.Errorf("WithEnv only applies to .bin/.py plugin processes launched by host or in container")
+	}
+	if o.jsonNumber != "" && (!process || o.daemonAddr != "") {
+		return fmt.Errorf("WithJSONNumber only applies to .bin/.py plugins over gRPC or JSON-RPC, got %s", path)
+	}
+	if len(o.secrets) > 0 && (!process || remote || o.daemonAddr != "") {
+		return fmt.Errorf("secrets are only supported for local .bin/.py plugin processes")
+	}
+	if len(o.pluginConfig) > 0 && (!process || remote || o.daemonAddr != "") {
+		return fmt.Errorf("WithPluginConfig only applies to local .bin/.py plugin processes, got %s", path)
+	}
+	if len(o.dataFiles) > 0 && (!process || o.detachedState != "" || o.daemonAddr != "") {
+		return fmt.Errorf("WithDataFiles only applies to .bin/.py plugin processes launched by host, got %s", path)
+	}
+	if err := validateDataFiles(o.dataFiles); err != nil {
+		return err
+	}
+	if o.artifactsDir != "" && (!process || o.detachedState != "" || o.daemonAddr != "") {
+		return fmt.Errorf("WithArtifacts only applies to .bin/.py plugin processes launched by host, got %s", path)
+	}
+	if o.autoBuildDir != "" && (remote || (ext != ".bin" && ext != ".so")) {
+		return fmt.Errorf("WithAutoBuild only applies to local .bin/.so plugins, got %s", path)
+	}
+	if o.autoBuildDir != "" && o.signature != nil {
+		return fmt.Errorf("WithAutoBuild and WithSignatureVerification are mutually exclusive")
+	}
+	if o.licensePolicy != nil && (remote || (!process && ext != ".so")) {
+		return fmt.Errorf("WithLicensePolicy only applies to local .bin/.so/.py plugins, got %s", path)
+	}
+
+	return o.validateCalls()
+}
+
+// validateConnect detects options launching plugin processes,
+// which do not apply to plugin sidecar connected by Connect
+func (o *pluginOption) validateConnect() error {
+	launchOptions := []struct {
+		name string
+		set  bool
+	}{
+		{"WithConfigFile", o.configFile != ""},
+		{"WithPython3", o.python3 != ""},
+		{"WithPythonPath", o.pythonPath != nil},
+		{"WithTransport", o.transport != ""},
+		{"WithGRPCReflection", o.grpcReflection},
+		{"WithDetached", o.detachedState != ""},
+		{"WithDaemon", o.daemonAddr != ""},
+		{"WithDockerImage", o.dockerImage != "" || len(o.dockerArgs) > 0},
+		{"WithIsolation", o.isolation != ""},
+		{"WithRemoteSSH", o.sshHost != ""},
+		{"WithADB", o.adbEnabled},
+		{"WithStartTimeout", o.startTimeout != 0},
+		{"WithLicensePolicy", o.licensePolicy != nil},
+		{"WithSignatureVerification", o.signature != nil},
+		{"WithAutoBuild", o.autoBuildDir != ""},
+		{"WithSecrets", len(o.secrets) > 0},
+		{"WithPluginConfig", len(o.pluginConfig) > 0},
+		{"WithEnv", len(o.env) > 0},
+		{"WithDataFiles", len(o.dataFiles) > 0},
+		{"WithArtifacts", o.artifactsDir != ""},
+		{"WithDurableQueue", o.durableDir != ""},
+	}
+	for _, option := range launchOptions {
+		if option.set {
+			return fmt.Errorf("%s does not apply to plugin sidecar connected by Connect", option.name)
+		}
+	}
+	return o.validateCalls()
+}
+
+// validateCalls validates options of plugin calls on the host side
+func (o *pluginOption) validateCalls() error {
+	if !o.jsonNumber.Valid() {
+		return fmt.Errorf("unsupported JSON number mode: %s", o.jsonNumber)
+	}
+	if o.maxConcurrency < 0 || o.queueSize < 0 || o.queueTimeout < 0 {
+		return fmt.Errorf("WithConcurrencyLimit arguments should not be negative")
+	}
+	if o.maxConcurrency == 0 && (o.queueSize > 0 || o.queueTimeout > 0) {
+		return fmt.Errorf("WithConcurrencyLimit queue requires maxConcurrency > 0")
+	}
+	if o.maxConcurrency == 0 && len(o.funcPriorities) > 0 {
+		return fmt.Errorf("WithFuncPriority requires WithConcurrencyLimit")
+	}
+	if o.scheduleJitter < 0 {
+		return fmt.Errorf("WithScheduleJitter jitter should not be negative")
+	}
+	if c := o.chaos; c != nil {
+		for _, rate := range []float64{c.DelayRate, c.DropRate, c.KillRate} {
+			if rate < 0 || rate > 1 {
+				return fmt.Errorf("WithChaos rates should be in [0, 1]")
+			}
+		}
+		if c.DelayRate > 0 && c.MaxDelay <= 0 {
+			return fmt.Errorf("WithChaos DelayRate requires positive MaxDelay")
+		}
+	}
+	if o.rateLimit != nil && (o.rateLimit.rps <= 0 || o.rateLimit.burst <= 0) {
+		return fmt.Errorf("WithRateLimit rps and burst should be positive")
+	}
+	for funcName, limit := range o.funcRateLimits {
+		if limit.rps <= 0 || limit.burst <= 0 {
+			return fmt.Errorf("WithFuncRateLimit rps and burst should be positive for %s", funcName)
+		}
+	}
+	return nil
+}
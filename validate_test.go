@@ -0,0 +1,107 @@
+package funplugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateOptions(t *testing.T) {
+	testCases := []struct {
+		path    string
+		options []Option
+		err     string
+	}{
+		{"debugtalk.so", []Option{WithPython3("/usr/bin/python3")},
+			"WithPython3 only applies to local .py plugins, got debugtalk.so"},
+		{"debugtalk.py", []Option{WithPython3("/usr/bin/python3"), WithDockerImage("debugtalk")},
+			"WithPython3 only applies to local .py plugins, got debugtalk.py"},
+		{"debugtalk.bin", []Option{WithPythonPath(PythonPath{ExcludeCWD: true})},
+			"WithPythonPath only applies to local .py plugins launched by host, got debugtalk.bin"},
+		{"debugtalk.py", []Option{WithPythonPath(PythonPath{}), WithDaemon("unix", "daemon.sock")},
+			"WithPythonPath only applies to local .py plugins launched by host, got debugtalk.py"},
+		{"debugtalk.lua", []Option{WithDataFiles(map[string]string{"users.csv": "users.csv"})},
+			"WithDataFiles only applies to .bin/.py plugin processes launched by host, got debugtalk.lua"},
+		{"debugtalk.bin", []Option{WithDataFiles(map[string]string{"users.csv": "users.csv"}), WithDetached("state.json")},
+			"WithDataFiles only applies to .bin/.py plugin processes launched by host, got debugtalk.bin"},
+		{"debugtalk.bin", []Option{WithDataFiles(map[string]string{"../users.csv": "users.csv"})},
+			"invalid data file name ../users.csv, it should be a relative slash separated path"},
+		{"debugtalk.lua", []Option{WithArtifacts("artifacts")},
+			"WithArtifacts only applies to .bin/.py plugin processes launched by host, got debugtalk.lua"},
+		{"debugtalk.py", []Option{WithArtifacts("artifacts"), WithDaemon("unix", "plugin.sock")},
+			"WithArtifacts only applies to .bin/.py plugin processes launched by host, got debugtalk.py"},
+		{"debugtalk.lua", []Option{WithTransport("stdio")},
+			"transport stdio only applies to local .bin/.py plugins, got debugtalk.lua"},
+		{"debugtalk.bin", []Option{WithTransport("stdio"), WithRemoteSSH("lab-machine", "")},
+			"transport stdio only applies to local .bin/.py plugins, got debugtalk.bin"},
+		{"debugtalk.bin", []Option{WithTransport("stdio"), WithGRPCReflection(true)},
+			"WithGRPCReflection only applies to local .bin/.py plugins over hashicorp gRPC"},
+		{"debugtalk.bin", []Option{WithDockerImage("debugtalk"), WithADB("")},
+			"WithDockerImage, WithRemoteSSH and WithADB are mutually exclusive"},
+		{"debugtalk.bin", []Option{WithDockerArgs("--network", "none")},
+			"WithDockerArgs requires WithDockerImage"},
+		{"debugtalk.bin", []Option{WithDetached("state.json"), WithDaemon("unix", "daemon.sock")},
+			"WithDetached and WithDaemon are mutually exclusive"},
+		{"debugtalk.js", []Option{WithDetached("state.json")},
+			"detached mode only supports local .bin/.py plugins, got debugtalk.js"},
+		{"debugtalk.bin", []Option{WithDaemon("unix", "daemon.sock"), WithIsolation("gvisor")},
+			"WithIsolation only applies to .bin/.py plugin processes launched by host"},
+		{"debugtalk.star", []Option{WithStartTimeout(time.Minute)},
+			"WithStartTimeout only applies to .bin/.py plugin processes launched by host"},
+		{"debugtalk.bin", []Option{WithADB(""), WithEnv(map[string]string{"A": "1"})},
+			"WithEnv only applies to .bin/.py plugin processes launched by host or in container"},
+		{"debugtalk.lua", []Option{WithLicensePolicy(LicensePolicy{AllowUnknown: true})},
+			"WithLicensePolicy only applies to local .bin/.so/.py plugins, got debugtalk.lua"},
+		{"debugtalk.py", []Option{WithAutoBuild("plugin")},
+			"WithAutoBuild only applies to local .bin/.so plugins, got debugtalk.py"},
+		{"debugtalk.bin", []Option{WithAutoBuild("plugin"), WithSignatureVerification(SignaturePolicy{})},
+			"WithAutoBuild and WithSignatureVerification are mutually exclusive"},
+		{"debugtalk.lua", []Option{WithConcurrencyLimit(0, 10, time.Second)},
+			"WithConcurrencyLimit queue requires maxConcurrency > 0"},
+		{"debugtalk.lua", []Option{WithFuncPriority("health_check", PriorityHigh)},
+			"WithFuncPriority requires WithConcurrencyLimit"},
+		{"debugtalk.lua", []Option{WithScheduleJitter(-time.Second)},
+			"WithScheduleJitter jitter should not be negative"},
+		{"debugtalk.lua", []Option{WithChaos(ChaosConfig{DropRate: 1.5})},
+			"WithChaos rates should be in [0, 1]"},
+		{"debugtalk.lua", []Option{WithChaos(ChaosConfig{DelayRate: 0.5})},
+			"WithChaos DelayRate requires positive MaxDelay"},
+		{"debugtalk.lua", []Option{WithRateLimit(0, 1)},
+			"WithRateLimit rps and burst should be positive"},
+		{"debugtalk.lua", []Option{WithFuncRateLimit("sum", 10, 0)},
+			"WithFuncRateLimit rps and burst should be positive for sum"},
+		// valid combinations
+		{"debugtalk.py", []Option{WithPython3("/usr/bin/python3"), WithTransport("stdio"),
+			WithPythonPath(PythonPath{Prepend: []string{"."}, ExcludeCWD: true}),
+			WithIsolation("gvisor"), WithStartTimeout(time.Minute), WithEnv(map[string]string{"A": "1"})}, ""},
+		{"debugtalk.bin", []Option{WithDockerImage("debugtalk"), WithDockerArgs("--network", "none"),
+			WithEnv(map[string]string{"A": "1"})}, ""},
+		{"debugtalk.so", []Option{WithLicensePolicy(LicensePolicy{AllowUnknown: true}), WithAutoBuild("plugin")}, ""},
+		{"debugtalk.lua", []Option{WithConcurrencyLimit(2, 10, time.Second), WithRateLimit(10, 1),
+			WithFuncPriority("health_check", PriorityHigh)}, ""},
+	}
+
+	for _, tc := range testCases {
+		option := &pluginOption{}
+		for _, o := range tc.options {
+			o(option)
+		}
+		err := option.validate(tc.path)
+		if tc.err == "" {
+			assert.Nil(t, err, tc.path)
+		} else {
+			assert.EqualError(t, err, tc.err)
+		}
+	}
+}
+
+func TestInitInvalidOptions(t *testing.T) {
+	_, err := Init("lua/examples/debugtalk.lua", WithPython3("/usr/bin/python3"))
+	assert.EqualError(t, err, "WithPython3 only applies to local .py plugins, got lua/examples/debugtalk.lua")
+}
+
+func TestConnectInvalidOptions(t *testing.T) {
+	_, err := Connect("127.0.0.1:50051", WithTransport("stdio"))
+	assert.EqualError(t, err, "WithTransport does not apply to plugin sidecar connected by Connect")
+}
@@ -0,0 +1,36 @@
+package funplugin
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lingcetech/funplugin/fungo"
+)
+
+func TestValueTypesGoPlugin(t *testing.T) {
+	buildHashicorpGoPlugin()
+	defer removeHashicorpGoPlugin()
+
+	bigInt, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	decimal, _ := fungo.ParseDecimal("0.10")
+	for _, transport := range []string{"", "stdio"} {
+		plugin, err := Init(pluginBinPath, WithTransport(transport))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// plugin function receives *big.Int and *fungo.Decimal
+		result, err := plugin.Call("concatenate", bigInt, "|", decimal)
+		assert.Nil(t, err, transport)
+		assert.Equal(t, "123456789012345678901234567890|0.10", result, transport)
+
+		// time.Time keeps offset of time zone
+		at := time.Date(2024, 5, 1, 8, 30, 0, 0, time.FixedZone("", 8*3600))
+		result, err = plugin.Call("concatenate", at, "|", 90*time.Second)
+		assert.Nil(t, err, transport)
+		assert.Equal(t, "2024-05-01 08:30:00 +0800 +0800|1m30s", result, transport)
+		plugin.Quit()
+	}
+}
@@ -0,0 +1,104 @@
+package funplugin
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// websocket message types
+const (
+	wsTypeList    = "list"    // client requests function names
+	wsTypeCall    = "call"    // client calls function
+	wsTypeNames   = "names"   // server responds function names
+	wsTypeStarted = "started" // server starts executing call
+	wsTypeResult  = "result"  // server responds call result
+	wsTypeError   = "error"   // server responds error
+)
+
+// wsMessage is the JSON message exchanged over websocket, id is set by
+// client to correlate responses since calls are executed concurrently
+type wsMessage struct {
+	ID    string        `json:"id"`
+	Type  string        `json:"type"`
+	Func  string        `json:"func,omitempty"`
+	Args  []interface{} `json:"args,omitempty"`
+	Names []string      `json:"names,omitempty"`
+	Value interface{}   `json:"value,omitempty"`
+	Error string        `json:"error,omitempty"`
+}
+
+var wsUpgrader = websocket.Upgrader{}
+
+// NewWebSocketHandler returns websocket handler for browser-hosted tooling.
+//
+//	{"id": "1", "type": "list"} => {"id": "1", "type": "names", "names": [...]}
+//	{"id": "2", "type": "call", "func": "sum", "args": [1, 2]}
+//	=> {"id": "2", "type": "started", "func": "sum"}
+//	=> {"id": "2", "type": "result", "func": "sum", "value": 3}
+//
+// Calls run concurrently and results are streamed back as soon as they finish.
+func NewWebSocketHandler(p IPlugin) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := wsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			logger.Error("upgrade websocket failed", "error", err)
+			return
+		}
+		defer conn.Close()
+
+		var writeMutex sync.Mutex // websocket supports one concurrent writer
+		send := func(msg wsMessage) {
+			writeMutex.Lock()
+			defer writeMutex.Unlock()
+			if err := conn.WriteJSON(msg); err != nil {
+				logger.Error("write websocket message failed", "error", err)
+			}
+		}
+
+		var wg sync.WaitGroup
+		defer wg.Wait()
+		for {
+			var msg wsMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					logger.Error("read websocket message failed", "error", err)
+				}
+				return
+			}
+
+			switch msg.Type {
+			case wsTypeList:
+				lister, ok := p.(IFuncLister)
+				if !ok {
+					send(wsMessage{ID: msg.ID, Type: wsTypeError,
+						Error: fmt.Sprintf("%s does not support listing functions", p.Type())})
+					continue
+				}
+				names, err := lister.GetNames()
+				if err != nil {
+					send(wsMessage{ID: msg.ID, Type: wsTypeError, Error: err.Error()})
+					continue
+				}
+				send(wsMessage{ID: msg.ID, Type: wsTypeNames, Names: names})
+			case wsTypeCall:
+				wg.Add(1)
+				go func(msg wsMessage) {
+					defer wg.Done()
+					send(wsMessage{ID: msg.ID, Type: wsTypeStarted, Func: msg.Func})
+					value, err := p.Call(msg.Func, msg.Args...)
+					if err != nil {
+						send(wsMessage{ID: msg.ID, Type: wsTypeError, Func: msg.Func, Error: err.Error()})
+						return
+					}
+					send(wsMessage{ID: msg.ID, Type: wsTypeResult, Func: msg.Func, Value: value})
+				}(msg)
+			default:
+				send(wsMessage{ID: msg.ID, Type: wsTypeError,
+					Error: fmt.Sprintf("unsupported message type: %s", msg.Type)})
+			}
+		}
+	})
+}
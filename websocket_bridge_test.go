@@ -0,0 +1,67 @@
+package funplugin
+
+import (
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebSocketBridge(t *testing.T) {
+	plugin, err := Init("js/examples/debugtalk.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Quit()
+
+	server := httptest.NewServer(NewHTTPHandler(plugin))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var msg wsMessage
+	if err := conn.WriteJSON(wsMessage{ID: "1", Type: wsTypeList}); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, wsTypeNames, msg.Type)
+	assert.Contains(t, msg.Names, "sum_two_int")
+
+	requests := []wsMessage{
+		{ID: "2", Type: wsTypeCall, Func: "sum_two_int", Args: []interface{}{1, 2}},
+		{ID: "3", Type: wsTypeCall, Func: "divide", Args: []interface{}{1, 0}},
+	}
+	for _, req := range requests {
+		if err := conn.WriteJSON(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// calls run concurrently, collect started and finished events of both
+	var events []string
+	results := make(map[string]wsMessage)
+	for i := 0; i < 4; i++ {
+		var msg wsMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, msg.ID+":"+msg.Type)
+		if msg.Type != wsTypeStarted {
+			results[msg.ID] = msg
+		}
+	}
+	sort.Strings(events)
+	assert.Equal(t, []string{"2:result", "2:started", "3:error", "3:started"}, events)
+	assert.EqualValues(t, 3, results["2"].Value)
+	assert.Equal(t, "division by zero", results["3"].Error)
+}